@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
 )
 
@@ -87,13 +89,18 @@ func NewAppsCommand() *cobra.Command {
 	cmd.AddCommand(newAppsUnsetEnvCommand())
 	cmd.AddCommand(newAppsLogsCommand())
 	cmd.AddCommand(newAppsSSHCommand())
+	cmd.AddCommand(newAppsSCPCommand())
 	cmd.AddCommand(newAppsProcessesCommand())
 	cmd.AddCommand(newAppsManifestCommand())
 	cmd.AddCommand(newAppsStatsCommand())
+	cmd.AddCommand(newAppsMetricsCommand())
 	cmd.AddCommand(newAppsEventsCommand())
+	cmd.AddCommand(newAppsCrashesCommand())
 	cmd.AddCommand(newAppsHealthCheckCommand())
 	cmd.AddCommand(newAppsTasksCommand())
 	cmd.AddCommand(newAppsDeploymentsCommand())
+	cmd.AddCommand(newAppsDeployCommand())
+	cmd.AddCommand(newAppsRollbackCommand())
 	cmd.AddCommand(newAppsPackagesCommand())
 	cmd.AddCommand(newAppsDropletsCommand())
 	cmd.AddCommand(newAppsBuildsCommand())
@@ -115,6 +122,7 @@ func newAppsListCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&spaceName, "space", "s", "", "filter by space name")
+	addSelectorFlag(cmd, "only applications matching this label selector")
 
 	return cmd
 }
@@ -132,6 +140,11 @@ func runAppsList(cmd *cobra.Command, spaceName string) error {
 		return err
 	}
 
+	err = applySelectorFlag(cmd, params)
+	if err != nil {
+		return err
+	}
+
 	apps, err := client.Apps().List(ctx, params)
 	if err != nil {
 		return fmt.Errorf("failed to list applications: %w", err)
@@ -315,49 +328,39 @@ func formatTime(t time.Time) string {
 }
 
 func newAppsStartCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "start APP_NAME_OR_GUID",
-		Short: "Start an application",
-		Long:  "Start a Cloud Foundry application",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			nameOrGUID := args[0]
+	return newAppsLifecycleCommand("start", capi.AppsClient.Start)
+}
 
-			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
-			if err != nil {
-				return err
-			}
+func newAppsStopCommand() *cobra.Command {
+	return newAppsLifecycleCommand("stop", capi.AppsClient.Stop)
+}
 
-			ctx := context.Background()
+func newAppsRestartCommand() *cobra.Command {
+	return newAppsLifecycleCommand("restart", capi.AppsClient.Restart)
+}
 
-			// Find application
-			appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
-			if err != nil {
-				return err
-			}
+// newAppsLifecycleCommand creates start, stop or restart, which act on one
+// application or on every application matching --selector. CF v3
+// /actions/{verb} returns a job.
+func newAppsLifecycleCommand(verb string, action func(capi.AppsClient, context.Context, string) (*capi.Job, error)) *cobra.Command {
+	opts := &bulkOptions{}
 
-			// Start application — CF v3 /actions/start returns a job.
-			job, err := client.Apps().Start(ctx, appGUID)
+	cmd := &cobra.Command{
+		Use:   verb + " [APP_NAME_OR_GUID]",
+		Short: capitalize(verb) + " an application",
+		Long: fmt.Sprintf(`%s a Cloud Foundry application, or every application matching --selector.
+
+With --selector the matching applications are listed first, and nothing
+happens until you confirm or pass --force.`, capitalize(verb)),
+		Example: fmt.Sprintf(`  capi apps %[1]s api
+  capi apps %[1]s --selector tier=web --space prod --parallel 5`, verb),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selector, err := bulkSelector(cmd, args, "application")
 			if err != nil {
-				return fmt.Errorf("failed to start application: %w", err)
+				return err
 			}
 
-			_, _ = fmt.Fprintf(os.Stdout, "Queued start of application '%s' (job %s)\n", appName, job.GUID)
-
-			return nil
-		},
-	}
-}
-
-func newAppsStopCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "stop APP_NAME_OR_GUID",
-		Short: "Stop an application",
-		Long:  "Stop a Cloud Foundry application",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			nameOrGUID := args[0]
-
 			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
 			if err != nil {
 				return err
@@ -365,58 +368,65 @@ func newAppsStopCommand() *cobra.Command {
 
 			ctx := context.Background()
 
+			if selector != nil {
+				return runAppsBulkLifecycle(cmd, client, selector, verb, action, opts)
+			}
+
 			// Find application
-			appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
+			appGUID, appName, err := resolveApp(ctx, client, args[0])
 			if err != nil {
 				return err
 			}
 
-			// Stop application — CF v3 /actions/stop returns a job.
-			job, err := client.Apps().Stop(ctx, appGUID)
+			job, err := action(client.Apps(), ctx, appGUID)
 			if err != nil {
-				return fmt.Errorf("failed to stop application: %w", err)
+				return fmt.Errorf("failed to %s application: %w", verb, err)
 			}
 
-			_, _ = fmt.Fprintf(os.Stdout, "Queued stop of application '%s' (job %s)\n", appName, job.GUID)
+			_, _ = fmt.Fprintf(os.Stdout, "Queued %s of application '%s' (job %s)\n", verb, appName, job.GUID)
 
 			return nil
 		},
 	}
-}
 
-func newAppsRestartCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "restart APP_NAME_OR_GUID",
-		Short: "Restart an application",
-		Long:  "Restart a Cloud Foundry application",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			nameOrGUID := args[0]
+	addBulkFlags(cmd, opts, "application")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "with --selector, act without asking for confirmation")
 
-			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
-			if err != nil {
-				return err
-			}
+	return cmd
+}
 
-			ctx := context.Background()
+func runAppsBulkLifecycle(cmd *cobra.Command, client capi.Client, selector *capi.LabelSelector, verb string, action func(capi.AppsClient, context.Context, string) (*capi.Job, error), opts *bulkOptions) error {
+	ctx := context.Background()
 
-			// Find application
-			appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
-			if err != nil {
-				return err
-			}
+	spaceGUID, err := bulkSpaceGUID(ctx, client, opts)
+	if err != nil {
+		return err
+	}
 
-			// Restart application — CF v3 /actions/restart returns a job.
-			job, err := client.Apps().Restart(ctx, appGUID)
-			if err != nil {
-				return fmt.Errorf("failed to restart application: %w", err)
-			}
+	params := capi.NewQueryParams().WithSelector(selector)
+	if spaceGUID != "" {
+		params.WithFilter("space_guids", spaceGUID)
+	}
+
+	apps, err := capi.CollectAllPages(ctx, params, func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.App], error) {
+		return client.Apps().List(ctx, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list applications: %w", err)
+	}
 
-			_, _ = fmt.Fprintf(os.Stdout, "Queued restart of application '%s' (job %s)\n", appName, job.GUID)
+	targets := make([]bulkTarget, 0, len(apps))
+	for _, app := range apps {
+		targets = append(targets, bulkTarget{GUID: app.GUID, Name: app.Name})
+	}
 
-			return nil
+	return runBulkAction(cmd, selector, targets, bulkAction{
+		verb: verb,
+		noun: "application",
+		run: func(ctx context.Context, target bulkTarget) (*capi.Job, error) {
+			return action(client.Apps(), ctx, target.GUID)
 		},
-	}
+	}, opts)
 }
 
 // Helper function to resolve app name or GUID.
@@ -1173,19 +1183,31 @@ func newAppsLogsCommand() *cobra.Command {
 		follow   bool
 		recent   bool
 		numLines int
+		parse    bool
+		status   string
+		summary  bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "logs APP_NAME_OR_GUID",
 		Short: "Show application logs",
-		Long:  "Display recent logs or stream logs for a Cloud Foundry application",
-		Args:  cobra.ExactArgs(1),
-		RunE:  runAppsLogs,
+		Long: `Display recent logs or stream logs for a Cloud Foundry application.
+
+--parse decodes JSON application logs into key=value fields and gorouter (RTR)
+access logs into status, method, path and response time. --status keeps only
+access log lines with a matching status, e.g. 5xx or 404,429. --summary prints
+per-path request counts, 5xx error rates and latency percentiles instead of
+the lines; when following, the summary is printed on Ctrl+C.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runAppsLogs,
 	}
 
 	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream logs continuously")
 	cmd.Flags().BoolVarP(&recent, "recent", "r", false, "Show recent logs only")
 	cmd.Flags().IntVarP(&numLines, "lines", "n", defaultLogLines, "Number of recent log lines to show")
+	cmd.Flags().BoolVar(&parse, "parse", false, "Decode JSON and gorouter access logs")
+	cmd.Flags().StringVar(&status, "status", "", "Only access log lines with these statuses (e.g. 5xx, 404,429)")
+	cmd.Flags().BoolVar(&summary, "summary", false, "Print per-path latency percentiles and error rates")
 
 	return cmd
 }
@@ -1193,6 +1215,11 @@ func newAppsLogsCommand() *cobra.Command {
 func runAppsLogs(cmd *cobra.Command, args []string) error {
 	nameOrGUID := args[0]
 
+	view, err := newLogView(cmd)
+	if err != nil {
+		return err
+	}
+
 	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
 	if err != nil {
 		return err
@@ -1210,28 +1237,37 @@ func runAppsLogs(cmd *cobra.Command, args []string) error {
 	numLines, _ := cmd.Flags().GetInt("lines")
 
 	if recent || !follow {
-		return showRecentLogs(ctx, client, appGUID, appName, numLines)
+		return showRecentLogs(ctx, client, appGUID, appName, numLines, view)
 	}
 
 	if follow {
-		return streamLogs(ctx, client, appGUID, appName)
+		return streamLogs(ctx, client, appGUID, appName, view)
 	}
 
 	return nil
 }
 
-func showRecentLogs(ctx context.Context, client capi.Client, appGUID, appName string, numLines int) error {
+func showRecentLogs(ctx context.Context, client capi.Client, appGUID, appName string, numLines int, view *logView) error {
 	logs, err := client.Apps().GetRecentLogs(ctx, appGUID, numLines)
 	if err != nil {
 		return fmt.Errorf("failed to get recent logs: %w", err)
 	}
 
+	if view.parse {
+		for _, logMsg := range logs.Messages {
+			err = view.handle(logMsg)
+			if err != nil {
+				return err
+			}
+		}
+
+		return view.finish()
+	}
+
 	_, _ = fmt.Fprintf(os.Stdout, "Recent logs for application '%s':\n\n", appName)
 
 	for _, logMsg := range logs.Messages {
-		timestamp := logMsg.Timestamp.Format("2006-01-02T15:04:05.00-0700")
-		_, _ = fmt.Fprintf(os.Stdout, "   %s [%s] %s %s\n",
-			timestamp, logMsg.SourceType, logMsg.MessageType, logMsg.Message)
+		_ = view.handle(logMsg)
 	}
 
 	_, _ = fmt.Fprintf(os.Stdout, "\nNote: Logs streaming requires WebSocket/SSE connection to CF API.\n")
@@ -1240,11 +1276,14 @@ func showRecentLogs(ctx context.Context, client capi.Client, appGUID, appName st
 	return nil
 }
 
-func streamLogs(ctx context.Context, client capi.Client, appGUID, appName string) error {
-	_, _ = fmt.Fprintf(os.Stdout, "Streaming logs for application '%s'...\n", appName)
-	_, _ = os.Stdout.WriteString("Press Ctrl+C to stop streaming.\n")
+func streamLogs(ctx context.Context, client capi.Client, appGUID, appName string, view *logView) error {
+	if !view.parse {
+		_, _ = fmt.Fprintf(os.Stdout, "Streaming logs for application '%s'...\n", appName)
+		_, _ = os.Stdout.WriteString("Press Ctrl+C to stop streaming.\n")
+	}
 
-	ctx, cancel := context.WithCancel(ctx)
+	// Stop on Ctrl+C rather than exiting so that a summary can be printed.
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
 
 	logChan, err := client.Apps().StreamLogs(ctx, appGUID)
@@ -1253,9 +1292,14 @@ func streamLogs(ctx context.Context, client capi.Client, appGUID, appName string
 	}
 
 	for logMsg := range logChan {
-		timestamp := logMsg.Timestamp.Format("2006-01-02T15:04:05.00-0700")
-		_, _ = fmt.Fprintf(os.Stdout, "   %s [%s] %s %s\n",
-			timestamp, logMsg.SourceType, logMsg.MessageType, logMsg.Message)
+		err = view.handle(logMsg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if view.parse {
+		return view.finish()
 	}
 
 	_, _ = os.Stdout.WriteString("\nLog streaming stopped.\n")
@@ -1264,29 +1308,57 @@ func streamLogs(ctx context.Context, client capi.Client, appGUID, appName string
 }
 
 func newAppsSSHCommand() *cobra.Command {
-	var (
-		index       int
-		processType string
-		command     string
-	)
+	opts := &sshOptions{}
 
 	cmd := &cobra.Command{
 		Use:   "ssh APP_NAME_OR_GUID",
 		Short: "SSH into an application instance",
-		Long:  "Open an SSH connection to a Cloud Foundry application instance",
-		Args:  cobra.ExactArgs(1),
-		RunE:  runAppsSSH,
+		Long: `Open an SSH session to a Cloud Foundry application instance through the
+Diego SSH proxy. The proxy address and host key fingerprint are discovered
+from the API, and a one-time code from UAA is used to authenticate.
+
+Without --command an interactive shell is opened, with a PTY when run from a
+terminal. With --command the command is run and capi exits with its exit
+status.
+
+-L forwards a local port through the instance, e.g. to reach a bound service
+instance from a laptop; with --skip-remote-execution (-N) only the forwards
+are kept open, until interrupted.`,
+		Example: `  # Open a shell in the first instance of the web process
+  capi apps ssh my-app
+
+  # Run a command in instance 2 of the worker process
+  capi apps ssh my-app --process worker --index 2 --command 'ps aux'
+
+  # Reach the app's database on localhost:5432 without opening a shell
+  capi apps ssh my-app -L 5432:db.internal:5432 -N`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAppsSSH(cmd, args[0], opts)
+		},
 	}
 
-	cmd.Flags().IntVarP(&index, "index", "i", 0, "Instance index to connect to")
-	cmd.Flags().StringVarP(&processType, "process", "p", "", "Process type (defaults to first process)")
-	cmd.Flags().StringVar(&command, "command", "", "Command to run in the SSH session")
+	cmd.Flags().IntVarP(&opts.index, "index", "i", 0, "Instance index to connect to")
+	cmd.Flags().StringVarP(&opts.processType, "process", "p", "", "Process type (defaults to first process)")
+	cmd.Flags().StringVar(&opts.command, "command", "", "Command to run in the SSH session")
+	cmd.Flags().StringArrayVarP(&opts.forwardSpecs, "forward", "L", nil,
+		"forward a local port: [LOCAL_ADDRESS:]LOCAL_PORT:REMOTE_ADDRESS:REMOTE_PORT (repeatable)")
+	cmd.Flags().BoolVarP(&opts.skipRemoteExecution, "skip-remote-execution", "N", false,
+		"do not run a shell or command; only forward ports")
+	cmd.MarkFlagsMutuallyExclusive("command", "skip-remote-execution")
 
 	return cmd
 }
 
-func runAppsSSH(cmd *cobra.Command, args []string) error {
-	nameOrGUID := args[0]
+func runAppsSSH(cmd *cobra.Command, nameOrGUID string, opts *sshOptions) error {
+	for _, spec := range opts.forwardSpecs {
+		forward, err := capi.ParseSSHLocalForward(spec)
+		if err != nil {
+			return fmt.Errorf("invalid -L: %w", err)
+		}
+
+		opts.forwards = append(opts.forwards, forward)
+	}
 
 	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
 	if err != nil {
@@ -1295,32 +1367,40 @@ func runAppsSSH(cmd *cobra.Command, args []string) error {
 
 	ctx := context.Background()
 
-	appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
+	sshClient, err := dialAppInstance(ctx, client, nameOrGUID, opts.processType, opts.index)
 	if err != nil {
 		return err
 	}
 
-	index, _ := cmd.Flags().GetInt("index")
-	processType, _ := cmd.Flags().GetString("process")
-	command, _ := cmd.Flags().GetString("command")
+	defer func() { _ = sshClient.Close() }()
 
-	return establishSSHConnection(ctx, client, appGUID, appName, processType, index, command)
+	return runSSHSession(ctx, sshClient, opts)
 }
 
-func establishSSHConnection(ctx context.Context, client capi.Client, appGUID, appName, processType string, index int, command string) error {
+// dialAppInstance connects to an instance of an app's process through the
+// SSH proxy.
+func dialAppInstance(ctx context.Context, client capi.Client, nameOrGUID, processType string, index int) (*ssh.Client, error) {
+	appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
+	if err != nil {
+		return nil, err
+	}
+
 	targetProcess, err := findTargetProcessForSSH(ctx, client, appGUID, appName, processType)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if index >= targetProcess.Instances {
-		return fmt.Errorf("%w: %d is out of range (0-%d) for process '%s'",
+		return nil, fmt.Errorf("%w: %d is out of range (0-%d) for process '%s'",
 			capi.ErrInstanceIndexOutOfRange, index, targetProcess.Instances-1, targetProcess.Type)
 	}
 
-	displaySSHInfo(appName, targetProcess, index, command, appGUID)
+	sshClient, err := client.SSH().Dial(ctx, targetProcess.GUID, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to instance %d of process '%s': %w", index, targetProcess.Type, err)
+	}
 
-	return nil
+	return sshClient, nil
 }
 
 func findTargetProcessForSSH(ctx context.Context, client capi.Client, appGUID, appName, processType string) (*capi.Process, error) {
@@ -1344,22 +1424,6 @@ func findTargetProcessForSSH(ctx context.Context, client capi.Client, appGUID, a
 	return nil, fmt.Errorf("%w '%s' for application '%s'", capi.ErrProcessTypeNotFound, processType, appName)
 }
 
-func displaySSHInfo(appName string, targetProcess *capi.Process, index int, command, appGUID string) {
-	_, _ = fmt.Fprintf(os.Stdout, "Connecting to application '%s' instance %d via SSH...\n", appName, index)
-	_, _ = fmt.Fprintf(os.Stdout, "Process: %s/%d\n", targetProcess.Type, index)
-
-	if command != "" {
-		_, _ = fmt.Fprintf(os.Stdout, "Command: %s\n", command)
-	}
-
-	_, _ = os.Stdout.WriteString("\nNote: SSH connection requires:\n")
-	_, _ = os.Stdout.WriteString("1. SSH to be enabled for the space and application\n")
-	_, _ = os.Stdout.WriteString("2. Authentication with Diego SSH proxy\n")
-	_, _ = os.Stdout.WriteString("3. Network access to Diego cells\n")
-	_, _ = fmt.Fprintf(os.Stdout, "4. App GUID: %s\n", appGUID)
-	_, _ = fmt.Fprintf(os.Stdout, "5. Process GUID: %s\n", targetProcess.GUID)
-}
-
 func newAppsProcessesCommand() *cobra.Command {
 	var showStats bool
 
@@ -1965,15 +2029,37 @@ func displayManifestDiff(spaceName string, diff *capi.ManifestDiff) {
 }
 
 func newAppsStatsCommand() *cobra.Command {
-	return &cobra.Command{
+	opts := &statsWatchOptions{}
+
+	cmd := &cobra.Command{
 		Use:   "stats APP_NAME_OR_GUID",
 		Short: "Show application statistics",
-		Long:  "Display resource usage statistics for all instances of a Cloud Foundry application",
-		Args:  cobra.ExactArgs(1),
+		Long: `Display resource usage statistics for all instances of a Cloud Foundry application.
+
+With --watch the stats are polled every --interval and one record is printed
+per instance and sample, until Ctrl-C or --count samples. --output ndjson or
+csv prints machine-readable records; a min/avg/p95 summary per instance is
+printed at the end (to stderr for ndjson and csv).`,
+		Example: `  # Current stats
+  capi apps stats my-app
+
+  # Record an hour of samples for a capacity review
+  capi apps stats my-app --watch --interval 10s --count 360 --output csv > my-app.csv`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.watch {
+				return runAppsStatsWatch(cmd, args[0], opts)
+			}
+
 			return runAppsStats(cmd, args[0])
 		},
 	}
+
+	cmd.Flags().BoolVarP(&opts.watch, "watch", "w", false, "poll stats continuously and print one record per instance sample")
+	cmd.Flags().DurationVar(&opts.interval, "interval", defaultStatsWatchInterval, "polling interval with --watch")
+	cmd.Flags().IntVar(&opts.count, "count", 0, "stop after this many recorded samples with --watch; failed polls do not count (0 polls until interrupted)")
+
+	return cmd
 }
 
 // InstanceStat represents statistics for a single application instance.
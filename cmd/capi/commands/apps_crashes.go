@@ -0,0 +1,216 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultCrashesSince     = 24 * time.Hour
+	defaultCrashesLogLines  = 10
+	defaultCrashesLogWindow = 30 * time.Second
+)
+
+type crashesOptions struct {
+	since     time.Duration
+	logLines  int
+	logWindow time.Duration
+}
+
+func newAppsCrashesCommand() *cobra.Command {
+	opts := &crashesOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "crashes APP_NAME_OR_GUID",
+		Short: "Analyze application crashes",
+		Long: `Correlate the audit.app.process.crash events of an application with the
+current state of its instances and the logs around each crash.
+
+Crashes are grouped by reason and exit status with the instance indexes they
+hit and when they started and last happened. Each crash is listed with the
+log lines its instance wrote within --log-window of it, read from Log Cache;
+lines written at or after the crash are marked with ">".`,
+		Example: `  # Crashes in the last day
+  capi apps crashes my-app
+
+  # The last week, without logs
+  capi apps crashes my-app --since 168h --logs 0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAppsCrashes(cmd, args[0], opts)
+		},
+	}
+
+	cmd.Flags().DurationVar(&opts.since, "since", defaultCrashesSince, "only crashes this recent")
+	cmd.Flags().IntVar(&opts.logLines, "logs", defaultCrashesLogLines, "log lines to show per crash (0 skips logs)")
+	cmd.Flags().DurationVar(&opts.logWindow, "log-window", defaultCrashesLogWindow, "how far before and after a crash to read logs")
+
+	return cmd
+}
+
+func runAppsCrashes(cmd *cobra.Command, nameOrGUID string, opts *crashesOptions) error {
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
+	if err != nil {
+		return err
+	}
+
+	crashOpts := capi.AppCrashOptions{
+		LogWindow: opts.logWindow,
+		LogLines:  opts.logLines,
+		OnError: func(err error) {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		},
+	}
+
+	if opts.since > 0 {
+		crashOpts.Since = time.Now().Add(-opts.since)
+	}
+
+	if opts.logLines <= 0 {
+		crashOpts.LogWindow = -1
+	}
+
+	report, err := capi.AnalyzeAppCrashes(ctx, client, appGUID, crashOpts)
+	if err != nil {
+		return fmt.Errorf("failed to analyze crashes: %w", err)
+	}
+
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(report)
+		if err != nil {
+			return fmt.Errorf("failed to encode crashes as JSON: %w", err)
+		}
+
+		return nil
+	case OutputFormatYAML:
+		err = yaml.NewEncoder(os.Stdout).Encode(report)
+		if err != nil {
+			return fmt.Errorf("failed to encode crashes as YAML: %w", err)
+		}
+
+		return nil
+	default:
+		return renderCrashReport(report, appName, opts.since)
+	}
+}
+
+func renderCrashReport(report *capi.AppCrashReport, appName string, since time.Duration) error {
+	window := "recorded"
+	if since > 0 {
+		window = "in the last " + since.String()
+	}
+
+	if len(report.Crashes) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "No crashes of application '%s' %s\n", appName, window)
+
+		return renderCrashInstances(report.Instances)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d crash(es) of application '%s' %s\n\n", len(report.Crashes), appName, window)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Reason", "Exit Status", "Count", "Process", "Instances", "First", "Last", "Description")
+
+	for _, group := range report.Groups {
+		_ = table.Append(
+			group.Reason,
+			formatExitStatus(group.ExitStatus),
+			strconv.Itoa(group.Count),
+			strings.Join(group.ProcessTypes, ", "),
+			joinInts(group.Indexes),
+			group.First.Local().Format(TimeFormatDisplay),
+			group.Last.Local().Format(TimeFormatDisplay),
+			group.ExitDescription,
+		)
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render crash groups: %w", err)
+	}
+
+	err = renderCrashInstances(report.Instances)
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, "\nCrashes (newest first):")
+
+	for _, crash := range report.Crashes {
+		_, _ = fmt.Fprintf(os.Stdout, "\n%s  %s/%d  %s  exit status %s  %s\n", crash.Time.Local().Format(TimeFormatDisplay),
+			crash.ProcessType, crash.Index, crash.Reason, formatExitStatus(crash.ExitStatus), crash.ExitDescription)
+
+		for _, message := range crash.Logs {
+			marker := " "
+			if !message.Timestamp.Before(crash.Time) {
+				marker = ">"
+			}
+
+			_, _ = fmt.Fprintf(os.Stdout, "  %s %s [%s] %s %s\n", marker, message.Timestamp.Format(logTimestampLayout),
+				message.SourceType, message.MessageType, strings.TrimRight(message.Message, "\n"))
+		}
+	}
+
+	return nil
+}
+
+func renderCrashInstances(instances []capi.AppInstanceState) error {
+	if len(instances) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, "\nCurrent instances:")
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Process", "Index", "State", "Since")
+
+	for _, instance := range instances {
+		_ = table.Append(instance.ProcessType, strconv.Itoa(instance.Index), instance.State, instance.Since.String())
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render instances: %w", err)
+	}
+
+	return nil
+}
+
+func formatExitStatus(status int) string {
+	if status < 0 {
+		return "-"
+	}
+
+	return strconv.Itoa(status)
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, strconv.Itoa(value))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,51 @@
+//nolint:testpackage // exercises the unexported crash report renderer
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderCrashReport(t *testing.T) {
+	crashedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	crash := capi.AppCrash{
+		Time:            crashedAt,
+		ProcessType:     "web",
+		Index:           1,
+		Reason:          "CRASHED",
+		ExitStatus:      137,
+		ExitDescription: "out of memory",
+		Logs: []capi.LogMessage{
+			{Timestamp: crashedAt.Add(-time.Second), SourceType: "APP/PROC/WEB", MessageType: "ERR", Message: "allocating\n"},
+			{Timestamp: crashedAt.Add(time.Second), SourceType: "CELL", MessageType: "OUT", Message: "restarting"},
+		},
+	}
+	report := &capi.AppCrashReport{
+		Crashes:   []capi.AppCrash{crash},
+		Groups:    capi.GroupAppCrashes([]capi.AppCrash{crash}),
+		Instances: []capi.AppInstanceState{{ProcessType: "web", Index: 1, State: "CRASHED", Since: time.Minute}},
+	}
+
+	output := captureStdout(t, func() {
+		require.NoError(t, renderCrashReport(report, "api", 24*time.Hour))
+	})
+
+	assert.Contains(t, output, "1 crash(es) of application 'api' in the last 24h0m0s")
+	assert.Contains(t, output, "137")
+	assert.Contains(t, output, "Current instances:")
+	assert.Contains(t, output, "web/1  CRASHED  exit status 137  out of memory")
+	assert.Contains(t, output, "    "+crashedAt.Add(-time.Second).Format(logTimestampLayout)+" [APP/PROC/WEB] ERR allocating\n")
+	assert.Contains(t, output, "  > "+crashedAt.Add(time.Second).Format(logTimestampLayout)+" [CELL] OUT restarting\n")
+}
+
+func TestRenderCrashReport_NoCrashes(t *testing.T) {
+	output := captureStdout(t, func() {
+		require.NoError(t, renderCrashReport(&capi.AppCrashReport{}, "api", time.Hour))
+	})
+
+	assert.Equal(t, "No crashes of application 'api' in the last 1h0m0s\n", output)
+}
@@ -0,0 +1,272 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+type appsDeployOptions struct {
+	strategy       string
+	maxInFlight    int
+	canarySteps    string
+	dropletGUID    string
+	revisionGUID   string
+	healthURL      string
+	healthAttempts int
+	maxCrashes     int
+	timeout        time.Duration
+	pollInterval   time.Duration
+	noRollback     bool
+}
+
+func newAppsDeployCommand() *cobra.Command {
+	opts := &appsDeployOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "deploy APP_NAME_OR_GUID",
+		Short: "Deploy an application with health gates",
+		Long: `Create a rolling or canary deployment and follow it until it finishes.
+
+For canary deployments every pause is gated by a health check: an HTTP probe
+against --health-url and/or a crash count of the new instances (--max-crashes).
+A healthy canary is continued automatically; an unhealthy one is canceled, which
+rolls the app back to its previous droplet. Without a health check each pause is
+continued immediately.`,
+		Example: `  # Rolling deployment of the current droplet, two instances at a time
+  capi apps deploy my-app --max-in-flight 2
+
+  # Three-step canary gated by an HTTP probe and zero tolerated crashes
+  capi apps deploy my-app --strategy canary --canary-steps 1,3:60,5 \
+    --health-url https://my-app.example.com/health --max-crashes 0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAppsDeploy(cmd, args[0], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.strategy, "strategy", capi.DeploymentStrategyRolling, "deployment strategy (rolling, canary)")
+	cmd.Flags().IntVar(&opts.maxInFlight, "max-in-flight", 0, "maximum number of instances replaced at once")
+	cmd.Flags().StringVar(&opts.canarySteps, "canary-steps", "", "comma-separated canary steps as INSTANCES[:WAIT_SECONDS]")
+	cmd.Flags().StringVar(&opts.dropletGUID, "droplet", "", "droplet to deploy (defaults to the current droplet)")
+	cmd.Flags().StringVar(&opts.revisionGUID, "revision", "", "revision to deploy instead of a droplet")
+	cmd.Flags().StringVar(&opts.healthURL, "health-url", "", "URL probed at every canary pause; non-2xx fails the gate")
+	cmd.Flags().IntVar(&opts.healthAttempts, "health-attempts", 0, "number of probes that must all succeed (default 3)")
+	cmd.Flags().IntVar(&opts.maxCrashes, "max-crashes", -1, "crashed new instances tolerated at every canary pause (-1 disables)")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "cancel the deployment if it has not finished within this duration")
+	cmd.Flags().DurationVar(&opts.pollInterval, "poll-interval", constants.DefaultPollInterval, "deployment status polling interval")
+	cmd.Flags().BoolVar(&opts.noRollback, "no-rollback", false, "leave a failing deployment paused instead of canceling it")
+
+	return cmd
+}
+
+func runAppsDeploy(cmd *cobra.Command, appNameOrGUID string, opts *appsDeployOptions) error {
+	request, err := buildDeploymentRequest(opts)
+	if err != nil {
+		return err
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	appGUID, appName, err := resolveApp(ctx, client, appNameOrGUID)
+	if err != nil {
+		return err
+	}
+
+	request.Relationships.App = &capi.Relationship{Data: &capi.RelationshipData{GUID: appGUID}}
+
+	output := viper.GetString("output")
+	quiet := output == OutputFormatJSON || output == OutputFormatYAML
+
+	runnerOpts := &capi.DeploymentRunnerOptions{
+		PollInterval:    opts.pollInterval,
+		Timeout:         opts.timeout,
+		HealthCheck:     buildDeploymentHealthCheck(client, opts),
+		DisableRollback: opts.noRollback,
+	}
+
+	if !quiet {
+		_, _ = fmt.Fprintf(os.Stdout, "Deploying application '%s' (%s strategy)\n", appName, opts.strategy)
+		runnerOpts.OnEvent = printDeploymentEvent
+	}
+
+	result, runErr := capi.NewDeploymentRunner(client, runnerOpts).Run(ctx, request)
+	if result == nil {
+		return fmt.Errorf("failed to deploy application '%s': %w", appName, runErr)
+	}
+
+	err = outputDeploymentResult(output, result)
+	if err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("deployment %s of application '%s' failed: %w", result.Deployment.GUID, appName, runErr)
+	}
+
+	if !quiet {
+		_, _ = fmt.Fprintf(os.Stdout, "Application '%s' deployed successfully\n", appName)
+	}
+
+	return nil
+}
+
+func buildDeploymentRequest(opts *appsDeployOptions) (*capi.DeploymentCreateRequest, error) {
+	if opts.strategy != capi.DeploymentStrategyRolling && opts.strategy != capi.DeploymentStrategyCanary {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDeploymentStrategy, opts.strategy)
+	}
+
+	if opts.dropletGUID != "" && opts.revisionGUID != "" {
+		return nil, ErrDropletRevisionConflict
+	}
+
+	if opts.canarySteps != "" && opts.strategy != capi.DeploymentStrategyCanary {
+		return nil, ErrCanaryStepsRequireCanary
+	}
+
+	strategy := opts.strategy
+	request := &capi.DeploymentCreateRequest{Strategy: &strategy}
+
+	if opts.dropletGUID != "" {
+		request.Droplet = &capi.DeploymentDropletRef{GUID: opts.dropletGUID}
+	}
+
+	if opts.revisionGUID != "" {
+		request.Revision = &capi.DeploymentRevisionRef{GUID: opts.revisionGUID}
+	}
+
+	deploymentOptions := &capi.DeploymentOptions{}
+
+	if opts.maxInFlight > 0 {
+		maxInFlight := opts.maxInFlight
+		deploymentOptions.MaxInFlight = &maxInFlight
+	}
+
+	if opts.canarySteps != "" {
+		steps, err := parseCanarySteps(opts.canarySteps)
+		if err != nil {
+			return nil, err
+		}
+
+		deploymentOptions.Canary = &capi.DeploymentCanaryOptions{Steps: steps}
+	}
+
+	if deploymentOptions.MaxInFlight != nil || deploymentOptions.Canary != nil {
+		request.Options = deploymentOptions
+	}
+
+	return request, nil
+}
+
+// parseCanarySteps parses "1,3:60,5" into canary steps; the optional
+// ":WAIT_SECONDS" suffix sets the step's wait time.
+func parseCanarySteps(value string) ([]capi.DeploymentCanaryStep, error) {
+	var steps []capi.DeploymentCanaryStep
+
+	for _, part := range strings.Split(value, ",") {
+		instancesText, waitText, hasWait := strings.Cut(strings.TrimSpace(part), ":")
+
+		instances, err := strconv.Atoi(instancesText)
+		if err != nil || instances <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCanaryStep, part)
+		}
+
+		step := capi.DeploymentCanaryStep{Instances: instances}
+
+		if hasWait {
+			step.WaitTime, err = strconv.Atoi(waitText)
+			if err != nil || step.WaitTime < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidCanaryStep, part)
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// buildDeploymentHealthCheck combines the requested gates; it returns nil when
+// no gate was requested so that pauses are continued immediately.
+func buildDeploymentHealthCheck(client capi.Client, opts *appsDeployOptions) capi.DeploymentHealthCheck { //nolint:ireturn // composite of optional gates
+	var checks []capi.DeploymentHealthCheck
+
+	if opts.maxCrashes >= 0 {
+		checks = append(checks, &capi.CrashCountHealthCheck{Client: client, MaxCrashed: opts.maxCrashes})
+	}
+
+	if opts.healthURL != "" {
+		checks = append(checks, &capi.HTTPProbeHealthCheck{URL: opts.healthURL, Attempts: opts.healthAttempts})
+	}
+
+	if len(checks) == 0 {
+		return nil
+	}
+
+	return capi.DeploymentHealthCheckFunc(func(ctx context.Context, deployment *capi.Deployment) error {
+		for _, check := range checks {
+			err := check.Check(ctx, deployment)
+			if err != nil {
+				return fmt.Errorf("health check failed: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func printDeploymentEvent(event capi.DeploymentEvent) {
+	line := fmt.Sprintf("%s  %-20s %s/%s", event.Time.Format(time.TimeOnly), event.Type, event.State, event.Reason)
+
+	if event.Steps > 0 {
+		line += fmt.Sprintf("  step %d/%d", event.Step, event.Steps)
+	}
+
+	if event.Message != "" {
+		line += "  " + event.Message
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, line)
+}
+
+func outputDeploymentResult(output string, result *capi.DeploymentResult) error {
+	switch output {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode deployment result as JSON: %w", err)
+		}
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(defaultJSONIndent)
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode deployment result as YAML: %w", err)
+		}
+	default:
+		if result.RolledBack {
+			_, _ = fmt.Fprintf(os.Stdout, "Deployment %s was canceled; the app was rolled back to its previous droplet\n", result.Deployment.GUID)
+		}
+	}
+
+	return nil
+}
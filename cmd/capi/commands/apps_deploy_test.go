@@ -0,0 +1,56 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppsDeployCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewAppsCommand()
+	cmd := findSubcommand(root, "deploy")
+	require.NotNil(t, cmd)
+	assert.Equal(t, "deploy APP_NAME_OR_GUID", cmd.Use)
+	require.Error(t, cmd.Args(cmd, []string{}))
+
+	for _, name := range []string{"strategy", "max-in-flight", "canary-steps", "droplet", "revision", "health-url", "health-attempts", "max-crashes", "timeout", "poll-interval", "no-rollback"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+
+	assert.Equal(t, "rolling", cmd.Flags().Lookup("strategy").DefValue)
+	assert.Equal(t, "-1", cmd.Flags().Lookup("max-crashes").DefValue)
+}
+
+func TestAppsDeployCommandValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{"unknown strategy", []string{"deploy", "app", "--strategy", "blue-green"}, commands.ErrInvalidDeploymentStrategy},
+		{"droplet and revision", []string{"deploy", "app", "--droplet", "d", "--revision", "r"}, commands.ErrDropletRevisionConflict},
+		{"steps without canary", []string{"deploy", "app", "--canary-steps", "1"}, commands.ErrCanaryStepsRequireCanary},
+		{"bad step", []string{"deploy", "app", "--strategy", "canary", "--canary-steps", "1,x:5"}, commands.ErrInvalidCanaryStep},
+		{"negative wait", []string{"deploy", "app", "--strategy", "canary", "--canary-steps", "2:-1"}, commands.ErrInvalidCanaryStep},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := commands.NewAppsCommand()
+			root.SetArgs(tt.args)
+			root.SilenceUsage = true
+			root.SilenceErrors = true
+
+			err := root.Execute()
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const logTimestampLayout = "2006-01-02T15:04:05.00-0700"
+
+// logView prints log messages, parsing them for --parse, --status and
+// --summary.
+type logView struct {
+	parse   bool
+	status  *capi.StatusFilter
+	summary *capi.AccessLogSummary
+	output  string
+	encoder *json.Encoder
+}
+
+func newLogView(cmd *cobra.Command) (*logView, error) {
+	parse, _ := cmd.Flags().GetBool("parse")
+	statusSpec, _ := cmd.Flags().GetString("status")
+	summary, _ := cmd.Flags().GetBool("summary")
+
+	view := &logView{
+		parse:   parse || statusSpec != "" || summary,
+		output:  viper.GetString("output"),
+		encoder: json.NewEncoder(os.Stdout),
+	}
+
+	if statusSpec != "" {
+		filter, err := capi.ParseStatusFilter(statusSpec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --status: %w", err)
+		}
+
+		view.status = filter
+	}
+
+	if summary {
+		view.summary = capi.NewAccessLogSummary()
+	}
+
+	return view, nil
+}
+
+// handle prints one message, or adds it to the summary.
+func (v *logView) handle(message capi.LogMessage) error {
+	timestamp := message.Timestamp.Format(logTimestampLayout)
+
+	if !v.parse {
+		_, _ = fmt.Fprintf(os.Stdout, "   %s [%s] %s %s\n", timestamp, message.SourceType, message.MessageType, message.Message)
+
+		return nil
+	}
+
+	parsed := capi.ParseLogMessage(message)
+
+	if v.status != nil && (parsed.Access == nil || !v.status.Match(parsed.Access.Status)) {
+		return nil
+	}
+
+	if v.summary != nil {
+		if parsed.Access != nil {
+			v.summary.Add(parsed.Access)
+		}
+
+		return nil
+	}
+
+	if v.output == OutputFormatJSON {
+		err := v.encoder.Encode(parsed)
+		if err != nil {
+			return fmt.Errorf("failed to encode log message: %w", err)
+		}
+
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "   %s [%s] %s\n", timestamp, message.SourceType, formatParsedLog(&parsed))
+
+	return nil
+}
+
+// finish prints the summary, if one was requested.
+func (v *logView) finish() error {
+	if v.summary == nil {
+		return nil
+	}
+
+	paths := v.summary.Paths()
+
+	switch v.output {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(paths)
+		if err != nil {
+			return fmt.Errorf("failed to encode summary as JSON: %w", err)
+		}
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(defaultJSONIndent)
+
+		err := encoder.Encode(paths)
+		if err != nil {
+			return fmt.Errorf("failed to encode summary as YAML: %w", err)
+		}
+	default:
+		if len(paths) == 0 {
+			_, _ = os.Stdout.WriteString("No access log lines found\n")
+
+			return nil
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Method", "Path", "Requests", "5xx", "Error Rate", "p50", "p95", "p99")
+
+		for _, path := range paths {
+			_ = table.Append(path.Method, path.Path, strconv.Itoa(path.Requests), strconv.Itoa(path.Errors),
+				fmt.Sprintf("%.1f%%", path.ErrorRate*100), //nolint:mnd // percent
+				path.P50.String(), path.P95.String(), path.P99.String())
+		}
+
+		_ = table.Render()
+	}
+
+	return nil
+}
+
+func formatParsedLog(parsed *capi.ParsedLogMessage) string {
+	switch parsed.Format {
+	case capi.LogFormatAccess:
+		access := parsed.Access
+		line := fmt.Sprintf("%d %s %s %s", access.Status, access.Method, access.Path, access.ResponseTime)
+
+		if access.AppIndex >= 0 {
+			line += " app_index=" + strconv.Itoa(access.AppIndex)
+		}
+
+		if access.VcapRequestID != "" {
+			line += " vcap_request_id=" + access.VcapRequestID
+		}
+
+		return line
+	case capi.LogFormatJSON:
+		keys := make([]string, 0, len(parsed.Fields))
+		for key := range parsed.Fields {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		pairs := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			value, ok := parsed.Fields[key].(string)
+			if !ok {
+				encoded, _ := json.Marshal(parsed.Fields[key])
+				value = string(encoded)
+			}
+
+			pairs = append(pairs, key+"="+value)
+		}
+
+		return strings.Join(pairs, " ")
+	default:
+		return parsed.MessageType + " " + parsed.Message
+	}
+}
@@ -0,0 +1,33 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppsLogsParseFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := findSubcommand(commands.NewAppsCommand(), "logs")
+	require.NotNil(t, cmd)
+
+	for _, name := range []string{"follow", "recent", "lines", "parse", "status", "summary"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+}
+
+func TestAppsLogsInvalidStatus(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewAppsCommand()
+	root.SetArgs([]string{"logs", "app", "--status", "6xx"})
+	root.SilenceUsage = true
+	root.SilenceErrors = true
+
+	err := root.Execute()
+	require.ErrorIs(t, err, capi.ErrInvalidStatusFilter)
+}
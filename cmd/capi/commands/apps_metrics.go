@@ -0,0 +1,317 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultMetricsStep = time.Minute
+
+var (
+	// sourceIDMatcherPattern matches equality matchers on the source_id label
+	// so that app names can be replaced by their GUIDs.
+	sourceIDMatcherPattern = regexp.MustCompile(`source_id\s*(!?=)\s*"([^"]*)"`)
+	guidPattern            = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	sparklineLevels        = []rune("▁▂▃▄▅▆▇█")
+)
+
+type appsMetricsOptions struct {
+	query     string
+	rangeDur  time.Duration
+	step      time.Duration
+	sparkline bool
+}
+
+func newAppsMetricsCommand() *cobra.Command {
+	opts := &appsMetricsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "metrics [APP_NAME_OR_GUID]",
+		Short: "Query application metrics with PromQL",
+		Long: `Run a PromQL query against Log Cache for an application.
+
+Without --query the CPU usage of every instance of APP is shown. With --query
+the query is run as written and APP is not accepted: name the applications in
+source_id matchers instead. Values of source_id matchers that are not GUIDs
+are treated as application names and replaced with the application's GUID.
+Without --range an instant query is
+run; with --range the query covers that window ending now, sampled every
+--step, and is shown as a table or, with --sparkline, as one sparkline per
+series.`,
+		Example: `  # Current CPU usage per instance
+  capi apps metrics my-app
+
+  # Average CPU over the last hour, one point per minute, as sparklines
+  capi apps metrics --query 'avg(cpu{source_id="my-app"})' --range 1h --step 1m --sparkline
+
+  # Memory of every instance of another app, by name
+  capi apps metrics --query 'memory{source_id="other-app"}'`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAppsMetrics(cmd, args, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.query, "query", "", `PromQL query (default cpu{source_id="APP_GUID"})`)
+	cmd.Flags().DurationVar(&opts.rangeDur, "range", 0, "query this window ending now instead of a single instant")
+	cmd.Flags().DurationVar(&opts.step, "step", defaultMetricsStep, "resolution of a range query")
+	cmd.Flags().BoolVar(&opts.sparkline, "sparkline", false, "render range results as sparklines")
+
+	return cmd
+}
+
+func runAppsMetrics(cmd *cobra.Command, args []string, opts *appsMetricsOptions) error {
+	switch {
+	case opts.query == "" && len(args) == 0:
+		return ErrMetricsAppRequired
+	case opts.query != "" && len(args) > 0:
+		return ErrMetricsAppWithQuery
+	}
+
+	if opts.rangeDur < 0 {
+		return ErrInvalidMetricsRange
+	}
+
+	if opts.step <= 0 {
+		return ErrInvalidMetricsStep
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	query, err := metricsQuery(ctx, client, args, opts.query)
+	if err != nil {
+		return err
+	}
+
+	var result *capi.PromQLResult
+
+	now := time.Now()
+
+	if opts.rangeDur > 0 {
+		result, err = client.LogCache().QueryRange(ctx, query, now.Add(-opts.rangeDur), now, opts.step)
+	} else {
+		result, err = client.LogCache().Query(ctx, query, now)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to query metrics: %w", err)
+	}
+
+	return outputMetricsResult(result, opts.sparkline)
+}
+
+// metricsQuery returns the CPU query of the app in args, or query with its
+// source_id matchers resolved.
+func metricsQuery(ctx context.Context, client capi.Client, args []string, query string) (string, error) {
+	if query != "" {
+		return resolveMetricsSourceIDs(ctx, client, query)
+	}
+
+	appGUID, _, err := resolveApp(ctx, client, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`cpu{source_id=%q}`, appGUID), nil
+}
+
+// resolveMetricsSourceIDs replaces app names in source_id matchers with app
+// GUIDs. Values that already are GUIDs are left alone.
+func resolveMetricsSourceIDs(ctx context.Context, client capi.Client, query string) (string, error) {
+	resolved := map[string]string{}
+
+	for _, match := range sourceIDMatcherPattern.FindAllStringSubmatch(query, -1) {
+		value := match[2]
+		if guidPattern.MatchString(value) {
+			continue
+		}
+
+		if _, ok := resolved[value]; ok {
+			continue
+		}
+
+		guid, _, err := resolveApp(ctx, client, value)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve source_id %q: %w", value, err)
+		}
+
+		resolved[value] = guid
+	}
+
+	return sourceIDMatcherPattern.ReplaceAllStringFunc(query, func(matcher string) string {
+		parts := sourceIDMatcherPattern.FindStringSubmatch(matcher)
+
+		guid, ok := resolved[parts[2]]
+		if !ok {
+			return matcher
+		}
+
+		return fmt.Sprintf("source_id%s%q", parts[1], guid)
+	}), nil
+}
+
+func outputMetricsResult(result *capi.PromQLResult, sparkline bool) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode metrics as JSON: %w", err)
+		}
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(defaultJSONIndent)
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode metrics as YAML: %w", err)
+		}
+	default:
+		switch {
+		case result.Scalar != nil:
+			_, _ = fmt.Fprintf(os.Stdout, "%s  %s\n", formatDeploymentTime(result.Scalar.Time), formatMetricValue(result.Scalar.Value))
+		case len(result.Vector) > 0:
+			renderMetricsVector(result.Vector)
+		case len(result.Matrix) > 0 && sparkline:
+			renderMetricsSparklines(result.Matrix)
+		case len(result.Matrix) > 0:
+			renderMetricsMatrix(result.Matrix)
+		default:
+			_, _ = os.Stdout.WriteString("No data\n")
+		}
+	}
+
+	return nil
+}
+
+func renderMetricsVector(samples []capi.PromQLSample) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Series", "Value", "Time")
+
+	for _, sample := range samples {
+		_ = table.Append(formatMetricLabels(sample.Metric), formatMetricValue(sample.Point.Value), formatDeploymentTime(sample.Point.Time))
+	}
+
+	_ = table.Render()
+}
+
+// renderMetricsMatrix prints one row per point, grouped by series.
+func renderMetricsMatrix(series []capi.PromQLSeries) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Series", "Time", "Value")
+
+	for _, s := range series {
+		labels := formatMetricLabels(s.Metric)
+
+		for _, point := range s.Points {
+			_ = table.Append(labels, formatDeploymentTime(point.Time), formatMetricValue(point.Value))
+		}
+	}
+
+	_ = table.Render()
+}
+
+func renderMetricsSparklines(series []capi.PromQLSeries) {
+	for _, s := range series {
+		values := make([]float64, 0, len(s.Points))
+		for _, point := range s.Points {
+			values = append(values, point.Value)
+		}
+
+		_, _ = fmt.Fprintln(os.Stdout, formatMetricLabels(s.Metric))
+
+		low, high, ok := finiteRange(values)
+		if !ok {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(os.Stdout, "  %s  min %s  max %s  last %s\n", renderSparkline(values),
+			formatMetricValue(low), formatMetricValue(high), formatMetricValue(values[len(values)-1]))
+	}
+}
+
+// renderSparkline scales values between their finite minimum and maximum
+// onto the eight block characters. NaN and infinite values, such as a 0/0
+// CPU ratio, are left as gaps.
+func renderSparkline(values []float64) string {
+	low, high, _ := finiteRange(values)
+
+	var builder strings.Builder
+
+	for _, value := range values {
+		if !isFinite(value) {
+			builder.WriteRune(' ')
+
+			continue
+		}
+
+		level := 0
+		if high > low {
+			level = int((value - low) / (high - low) * float64(len(sparklineLevels)-1))
+		}
+
+		builder.WriteRune(sparklineLevels[level])
+	}
+
+	return builder.String()
+}
+
+// finiteRange returns the minimum and maximum of the finite values, and false
+// when there are none.
+func finiteRange(values []float64) (float64, float64, bool) {
+	low, high := math.Inf(1), math.Inf(-1)
+
+	for _, value := range values {
+		if isFinite(value) {
+			low, high = min(low, value), max(high, value)
+		}
+	}
+
+	return low, high, low <= high
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// formatMetricLabels renders labels as {a="1", b="2"} in key order.
+func formatMetricLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", key, labels[key]))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', 6, 64) //nolint:mnd // six significant digits
+}
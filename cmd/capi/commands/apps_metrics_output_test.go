@@ -0,0 +1,59 @@
+//nolint:testpackage // metrics rendering is unexported
+package commands
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSparkline(t *testing.T) {
+	assert.Equal(t, "▁▄█", renderSparkline([]float64{0, 0.5, 1}))
+	assert.Equal(t, "▁▁", renderSparkline([]float64{3, 3}))
+	assert.Equal(t, "▁ █ ", renderSparkline([]float64{1, math.NaN(), 2, math.Inf(1)}))
+	assert.Equal(t, "  ", renderSparkline([]float64{math.NaN(), math.Inf(-1)}))
+}
+
+func TestRenderMetricsSparklines_NonFinite(t *testing.T) {
+	out := captureStdout(t, func() {
+		renderMetricsSparklines([]capi.PromQLSeries{{
+			Metric: map[string]string{"instance_id": "0"},
+			Points: []capi.PromQLPoint{{Value: math.NaN()}, {Value: 2}, {Value: 4}},
+		}})
+	})
+
+	assert.Equal(t, "{instance_id=\"0\"}\n   ▁█  min 2  max 4  last 4\n", out)
+}
+
+func TestOutputMetricsResult_NonFinite(t *testing.T) {
+	result := &capi.PromQLResult{ResultType: capi.PromQLResultTypeMatrix, Matrix: []capi.PromQLSeries{{
+		Metric: map[string]string{"instance_id": "0"},
+		Points: []capi.PromQLPoint{
+			{Time: time.Unix(0, 0).UTC(), Value: math.NaN()},
+			{Time: time.Unix(60, 0).UTC(), Value: math.Inf(-1)},
+			{Time: time.Unix(120, 0).UTC(), Value: 0.5},
+		},
+	}}}
+
+	withOutputFormat(t, OutputFormatJSON)
+
+	var err error
+
+	out := captureStdout(t, func() { err = outputMetricsResult(result, false) })
+	require.NoError(t, err)
+	assert.Contains(t, out, `"value": "NaN"`)
+	assert.Contains(t, out, `"value": "-Inf"`)
+	assert.Contains(t, out, `"value": 0.5`)
+
+	withOutputFormat(t, OutputFormatYAML)
+
+	out = captureStdout(t, func() { err = outputMetricsResult(result, false) })
+	require.NoError(t, err)
+	assert.Contains(t, out, `value: NaN`)
+	assert.Contains(t, out, `value: -Inf`)
+	assert.Contains(t, out, `value: 0.5`)
+}
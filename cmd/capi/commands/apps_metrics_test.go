@@ -0,0 +1,55 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppsMetricsCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewAppsCommand()
+	cmd := findSubcommand(root, "metrics")
+	require.NotNil(t, cmd)
+	assert.Equal(t, "metrics [APP_NAME_OR_GUID]", cmd.Use)
+	require.NoError(t, cmd.Args(cmd, []string{}))
+	require.Error(t, cmd.Args(cmd, []string{"a", "b"}))
+
+	for _, name := range []string{"query", "range", "step", "sparkline"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+
+	assert.Equal(t, "1m0s", cmd.Flags().Lookup("step").DefValue)
+}
+
+func TestAppsMetricsCommandValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{"negative range", []string{"metrics", "app", "--range", "-1h"}, commands.ErrInvalidMetricsRange},
+		{"zero step", []string{"metrics", "app", "--range", "1h", "--step", "0s"}, commands.ErrInvalidMetricsStep},
+		{"no app or query", []string{"metrics"}, commands.ErrMetricsAppRequired},
+		{"app and query", []string{"metrics", "app", "--query", "cpu"}, commands.ErrMetricsAppWithQuery},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := commands.NewAppsCommand()
+			root.SetArgs(tt.args)
+			root.SilenceUsage = true
+			root.SilenceErrors = true
+
+			err := root.Execute()
+			require.ErrorIs(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRollbackRevisionLimit = 10
+
+type appsRollbackOptions struct {
+	revision     int
+	dropletGUID  string
+	strategy     string
+	maxInFlight  int
+	limit        int
+	list         bool
+	force        bool
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// rollbackRevisionInfo is one row of the revision history shown before a
+// rollback. Only environment variable names are included, never values.
+type rollbackRevisionInfo struct {
+	Version     int       `json:"version"               yaml:"version"`
+	GUID        string    `json:"guid"                  yaml:"guid"`
+	Droplet     string    `json:"droplet"               yaml:"droplet"`
+	Deployable  bool      `json:"deployable"            yaml:"deployable"`
+	Deployed    bool      `json:"deployed"              yaml:"deployed"`
+	CreatedAt   time.Time `json:"created_at"            yaml:"created_at"`
+	Description string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Env         []string  `json:"env"                   yaml:"env"`
+}
+
+func newAppsRollbackCommand() *cobra.Command {
+	opts := &appsRollbackOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rollback APP_NAME_OR_GUID",
+		Short: "Roll an application back to a previous revision or droplet",
+		Long: `Deploy an earlier revision or droplet of an application and wait for the
+deployment to finish.
+
+Without --revision or --droplet the recent revisions are listed with their
+droplet, environment variable names and description, and you are asked which
+version to roll back to; the default is the newest deployable revision older
+than the one currently deployed. --force skips the question and uses that
+default.`,
+		Example: `  # Choose interactively from the last ten revisions
+  capi apps rollback my-app
+
+  # Roll back to revision 7 with a canary deployment
+  capi apps rollback my-app --revision 7 --strategy canary
+
+  # Redeploy a specific droplet
+  capi apps rollback my-app --droplet 3f1c...`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAppsRollback(cmd, args[0], opts)
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.revision, "revision", 0, "revision version to roll back to")
+	cmd.Flags().StringVar(&opts.dropletGUID, "droplet", "", "droplet GUID to roll back to")
+	cmd.Flags().StringVar(&opts.strategy, "strategy", capi.DeploymentStrategyRolling, "deployment strategy (rolling, canary)")
+	cmd.Flags().IntVar(&opts.maxInFlight, "max-in-flight", 0, "maximum number of instances replaced at once")
+	cmd.Flags().IntVar(&opts.limit, "limit", defaultRollbackRevisionLimit, "number of recent revisions to show")
+	cmd.Flags().BoolVar(&opts.list, "list", false, "only list recent revisions")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "roll back to the previous revision without asking")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 0, "cancel the rollback deployment if it has not finished within this duration")
+	cmd.Flags().DurationVar(&opts.pollInterval, "poll-interval", constants.DefaultPollInterval, "deployment status polling interval")
+
+	return cmd
+}
+
+func runAppsRollback(cmd *cobra.Command, appNameOrGUID string, opts *appsRollbackOptions) error {
+	if opts.strategy != capi.DeploymentStrategyRolling && opts.strategy != capi.DeploymentStrategyCanary {
+		return fmt.Errorf("%w: %s", ErrInvalidDeploymentStrategy, opts.strategy)
+	}
+
+	if opts.dropletGUID != "" && opts.revision > 0 {
+		return ErrDropletRevisionConflict
+	}
+
+	output := viper.GetString("output")
+	quiet := output == OutputFormatJSON || output == OutputFormatYAML
+
+	// JSON and YAML output do not show the history to choose from, so the
+	// target has to be given up front.
+	if quiet && !opts.list && !opts.force && opts.revision == 0 && opts.dropletGUID == "" {
+		return ErrRollbackTargetRequired
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	appGUID, appName, err := resolveApp(ctx, client, appNameOrGUID)
+	if err != nil {
+		return err
+	}
+
+	var revision *capi.Revision
+
+	if opts.dropletGUID == "" {
+		revision, err = chooseRollbackRevision(ctx, client, appGUID, opts, quiet)
+		if err != nil || opts.list {
+			return err
+		}
+	}
+
+	runnerOpts := &capi.DeploymentRunnerOptions{PollInterval: opts.pollInterval, Timeout: opts.timeout}
+
+	if !quiet {
+		target := "droplet " + opts.dropletGUID
+		if revision != nil {
+			target = fmt.Sprintf("revision %d", revision.Version)
+		}
+
+		_, _ = fmt.Fprintf(os.Stdout, "Rolling back application '%s' to %s\n", appName, target)
+		runnerOpts.OnEvent = printDeploymentEvent
+	}
+
+	rollbackOpts := capi.RollbackOptions{
+		DropletGUID: opts.dropletGUID,
+		Strategy:    opts.strategy,
+		MaxInFlight: opts.maxInFlight,
+		Runner:      runnerOpts,
+	}
+
+	if revision != nil {
+		rollbackOpts.RevisionGUID = revision.GUID
+	}
+
+	result, runErr := capi.Rollback(ctx, client, appGUID, rollbackOpts)
+	if result == nil {
+		return fmt.Errorf("failed to roll back application '%s': %w", appName, runErr)
+	}
+
+	err = outputDeploymentResult(output, result)
+	if err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("rollback of application '%s' failed: %w", appName, runErr)
+	}
+
+	if !quiet {
+		_, _ = fmt.Fprintf(os.Stdout, "Application '%s' rolled back successfully\n", appName)
+	}
+
+	return nil
+}
+
+// chooseRollbackRevision lists the revisions once, shows the recent ones and
+// returns the revision to roll back to: --revision when given, otherwise the
+// user's choice with the previous deployable revision as the default.
+func chooseRollbackRevision(ctx context.Context, client capi.Client, appGUID string, opts *appsRollbackOptions, quiet bool) (*capi.Revision, error) {
+	revisions, err := capi.AppRevisions(ctx, client, appGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	deployedVersion, err := capi.DeployedRevisionVersion(ctx, client, appGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployed revision: %w", err)
+	}
+
+	if opts.list || !quiet {
+		infos, err := buildRollbackRevisionInfos(ctx, client, revisions, deployedVersion, opts.limit)
+		if err != nil {
+			return nil, err
+		}
+
+		err = outputRollbackRevisions(infos)
+		if err != nil || opts.list {
+			return nil, err
+		}
+	}
+
+	target, err := capi.RollbackRevision(revisions, deployedVersion, opts.revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select revision: %w", err)
+	}
+
+	if opts.revision > 0 || opts.force {
+		return target, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) { //nolint:gosec // file descriptors fit in int
+		return nil, ErrRollbackConfirmationRequired
+	}
+
+	return promptRollbackRevision(revisions, deployedVersion, target.Version)
+}
+
+func promptRollbackRevision(revisions []capi.Revision, deployedVersion, defaultVersion int) (*capi.Revision, error) {
+	_, _ = fmt.Fprintf(os.Stderr, "Revision to roll back to [%d]: ", defaultVersion)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	version := defaultVersion
+
+	answer = strings.TrimSpace(answer)
+	if answer != "" {
+		var err error
+
+		version, err = strconv.Atoi(answer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid revision version %q: %w", answer, err)
+		}
+	}
+
+	target, err := capi.RollbackRevision(revisions, deployedVersion, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select revision: %w", err)
+	}
+
+	return target, nil
+}
+
+func buildRollbackRevisionInfos(ctx context.Context, client capi.Client, revisions []capi.Revision, deployedVersion, limit int) ([]rollbackRevisionInfo, error) {
+	if limit > 0 && len(revisions) > limit {
+		revisions = revisions[:limit]
+	}
+
+	infos := make([]rollbackRevisionInfo, 0, len(revisions))
+
+	for _, revision := range revisions {
+		env, err := client.Revisions().GetEnvironmentVariables(ctx, revision.GUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get environment variables of revision %d: %w", revision.Version, err)
+		}
+
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		info := rollbackRevisionInfo{
+			Version:    revision.Version,
+			GUID:       revision.GUID,
+			Droplet:    revision.Droplet.GUID,
+			Deployable: revision.Deployable,
+			Deployed:   revision.Version == deployedVersion,
+			CreatedAt:  revision.CreatedAt,
+			Env:        names,
+		}
+
+		if revision.Description != nil {
+			info.Description = *revision.Description
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func outputRollbackRevisions(infos []rollbackRevisionInfo) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(infos)
+		if err != nil {
+			return fmt.Errorf("failed to encode revisions as JSON: %w", err)
+		}
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(defaultJSONIndent)
+
+		err := encoder.Encode(infos)
+		if err != nil {
+			return fmt.Errorf("failed to encode revisions as YAML: %w", err)
+		}
+	default:
+		if len(infos) == 0 {
+			_, _ = os.Stdout.WriteString("No revisions found\n")
+
+			return nil
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.Header("Version", "Droplet", "Deployable", "Deployed", "Created", "Description", "Env")
+
+		for _, info := range infos {
+			deployed := ""
+			if info.Deployed {
+				deployed = "*"
+			}
+
+			_ = table.Append(strconv.Itoa(info.Version), info.Droplet, strconv.FormatBool(info.Deployable), deployed,
+				formatDeploymentTime(info.CreatedAt), info.Description, strings.Join(info.Env, ", "))
+		}
+
+		_ = table.Render()
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rollbackClient serves an app, its revision history and deployments that
+// finish as soon as they are created.
+type rollbackClient struct {
+	fakeClient
+
+	revisions   *rollbackRevisionsStub
+	deployments *rollbackDeploymentsStub
+}
+
+func (c *rollbackClient) Revisions() capi.RevisionsClient     { return c.revisions }
+func (c *rollbackClient) Deployments() capi.DeploymentsClient { return c.deployments }
+
+type rollbackRevisionsStub struct {
+	capi.RevisionsClient
+
+	lists int
+}
+
+func (s *rollbackRevisionsStub) ListForApp(_ context.Context, _ string, _ *capi.QueryParams) (*capi.ListResponse[capi.Revision], error) {
+	s.lists++
+
+	return &capi.ListResponse[capi.Revision]{Resources: []capi.Revision{
+		{Resource: capi.Resource{GUID: "rev-3"}, Version: 3, Deployable: true},
+		{Resource: capi.Resource{GUID: "rev-2"}, Version: 2, Deployable: true},
+	}}, nil
+}
+
+// Get serves rev-3 of app-guid and rev-other of another app.
+func (s *rollbackRevisionsStub) Get(_ context.Context, guid string) (*capi.Revision, error) {
+	app := map[string]string{"rev-3": "app-guid", "rev-other": "other-app-guid"}[guid]
+	if app == "" {
+		return nil, capi.ErrNotFound
+	}
+
+	revision := &capi.Revision{Resource: capi.Resource{GUID: guid}}
+	revision.Relationships.App.Data = &capi.RelationshipData{GUID: app}
+
+	return revision, nil
+}
+
+func (s *rollbackRevisionsStub) GetDeployedForApp(_ context.Context, _ string) (*capi.ListResponse[capi.Revision], error) {
+	return &capi.ListResponse[capi.Revision]{Resources: []capi.Revision{{Version: 3}}}, nil
+}
+
+type rollbackDeploymentsStub struct {
+	capi.DeploymentsClient
+
+	created *capi.DeploymentCreateRequest
+}
+
+func (s *rollbackDeploymentsStub) Create(_ context.Context, request *capi.DeploymentCreateRequest) (*capi.Deployment, error) {
+	s.created = request
+
+	return &capi.Deployment{
+		Resource: capi.Resource{GUID: "deployment-guid"},
+		State:    string(capi.DeploymentStateDeployed),
+		Status: capi.DeploymentStatus{
+			Value:  string(capi.DeploymentStatusValueFinalized),
+			Reason: string(capi.DeploymentStatusReasonDeployed),
+		},
+	}, nil
+}
+
+func TestAppsRollback_ListsRevisionsOnce(t *testing.T) {
+	client := &rollbackClient{
+		fakeClient:  fakeClient{apps: &statsAppsStub{}},
+		revisions:   &rollbackRevisionsStub{},
+		deployments: &rollbackDeploymentsStub{},
+	}
+
+	withStubClient(t, client)
+	withOutputFormat(t, OutputFormatJSON)
+
+	out, err := runCommand(t, NewAppsCommand(), "rollback", "app-guid", "--force")
+	require.NoError(t, err)
+
+	var result capi.DeploymentResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, 1, client.revisions.lists)
+	assert.Equal(t, "rev-2", client.deployments.created.Revision.GUID)
+}
+
+func TestAppsRollback_StructuredOutputNeedsTarget(t *testing.T) {
+	withOutputFormat(t, OutputFormatYAML)
+
+	_, err := runCommand(t, NewAppsCommand(), "rollback", "app-guid")
+	require.ErrorIs(t, err, ErrRollbackTargetRequired)
+}
+
+func TestResolveRevisionGUID_ChecksApp(t *testing.T) {
+	client := &rollbackClient{revisions: &rollbackRevisionsStub{}}
+	ctx := context.Background()
+
+	guid, err := resolveRevisionGUID(ctx, client, "app-guid", "2")
+	require.NoError(t, err)
+	assert.Equal(t, "rev-2", guid)
+
+	guid, err = resolveRevisionGUID(ctx, client, "app-guid", "rev-3")
+	require.NoError(t, err)
+	assert.Equal(t, "rev-3", guid)
+
+	_, err = resolveRevisionGUID(ctx, client, "app-guid", "rev-other")
+	require.ErrorIs(t, err, ErrRevisionNotForApp)
+
+	_, err = resolveRevisionGUID(ctx, client, "app-guid", "rev-missing")
+	require.ErrorIs(t, err, capi.ErrNotFound)
+}
@@ -0,0 +1,52 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppsRollbackCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewAppsCommand()
+	cmd := findSubcommand(root, "rollback")
+	require.NotNil(t, cmd)
+	assert.Equal(t, "rollback APP_NAME_OR_GUID", cmd.Use)
+	require.Error(t, cmd.Args(cmd, []string{}))
+
+	for _, name := range []string{"revision", "droplet", "strategy", "max-in-flight", "limit", "list", "force", "timeout", "poll-interval"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+
+	assert.Equal(t, "f", cmd.Flags().Lookup("force").Shorthand)
+	assert.Equal(t, "10", cmd.Flags().Lookup("limit").DefValue)
+}
+
+func TestAppsRollbackCommandValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{"unknown strategy", []string{"rollback", "app", "--strategy", "recreate"}, commands.ErrInvalidDeploymentStrategy},
+		{"droplet and revision", []string{"rollback", "app", "--droplet", "d", "--revision", "3"}, commands.ErrDropletRevisionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := commands.NewAppsCommand()
+			root.SetArgs(tt.args)
+			root.SilenceUsage = true
+			root.SilenceErrors = true
+
+			require.ErrorIs(t, root.Execute(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+)
+
+const (
+	scpExactArgs       = 2
+	scpDirectoryMode   = 0o755
+	scpDefaultFileMode = 0o644
+)
+
+// scpLocation is one side of apps scp: a local path, or a path inside an
+// app instance when app is set.
+type scpLocation struct {
+	app  string
+	path string
+}
+
+// parseSCPLocation splits APP:PATH. Anything without a colon, or whose part
+// before the colon is a single letter (a Windows drive) or contains a path
+// separator, is a local path.
+func parseSCPLocation(arg string) scpLocation {
+	app, remotePath, found := strings.Cut(arg, ":")
+	if !found || len(app) <= 1 || strings.ContainsAny(app, `/\`) {
+		return scpLocation{path: arg}
+	}
+
+	if remotePath == "" {
+		remotePath = "."
+	}
+
+	return scpLocation{app: app, path: remotePath}
+}
+
+func newAppsSCPCommand() *cobra.Command {
+	var (
+		index       int
+		processType string
+		recursive   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "scp SOURCE DESTINATION",
+		Short: "Copy files to or from an application instance",
+		Long: `Copy files between the local machine and an application instance using SFTP
+over the same SSH connection as 'capi apps ssh'. Exactly one of SOURCE and
+DESTINATION is APP:PATH; relative remote paths start in the instance's home
+directory (/home/vcap). Copying into an existing directory keeps the source's
+name.`,
+		Example: `  # Download a heap dump
+  capi apps scp my-app:/tmp/heap.hprof ./heap.hprof
+
+  # Upload a directory to instance 1 of the worker process
+  capi apps scp --recursive ./fixtures my-app:app/fixtures --process worker --index 1`,
+		Args: cobra.ExactArgs(scpExactArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			source, destination := parseSCPLocation(args[0]), parseSCPLocation(args[1])
+			if (source.app == "") == (destination.app == "") {
+				return ErrSCPRemoteRequired
+			}
+
+			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+
+			sshClient, err := dialAppInstance(ctx, client, source.app+destination.app, processType, index)
+			if err != nil {
+				return err
+			}
+
+			defer func() { _ = sshClient.Close() }()
+
+			sftpClient, err := sftp.NewClient(sshClient)
+			if err != nil {
+				return fmt.Errorf("failed to start SFTP: %w", err)
+			}
+
+			defer func() { _ = sftpClient.Close() }()
+
+			copier := &scpCopier{sftp: sftpClient, recursive: recursive}
+
+			if source.app != "" {
+				err = copier.download(source.path, destination.path)
+			} else {
+				err = copier.upload(source.path, destination.path)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(os.Stdout, "Copied %d file(s), %d bytes\n", copier.files, copier.bytes)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&index, "index", "i", 0, "Instance index to copy to or from")
+	cmd.Flags().StringVarP(&processType, "process", "p", "", "Process type (defaults to first process)")
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "copy directories recursively")
+
+	return cmd
+}
+
+// scpCopier copies files over SFTP and counts what it copied.
+type scpCopier struct {
+	sftp      *sftp.Client
+	recursive bool
+	files     int
+	bytes     int64
+}
+
+// download copies remotePath to localPath.
+func (c *scpCopier) download(remotePath, localPath string) error {
+	info, err := c.sftp.Stat(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", remotePath, err)
+	}
+
+	target := localPath
+
+	localInfo, err := os.Stat(localPath)
+	if err == nil && localInfo.IsDir() {
+		target = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	if !info.IsDir() {
+		return c.downloadFile(remotePath, target, info.Mode())
+	}
+
+	if !c.recursive {
+		return fmt.Errorf("%w: %s", ErrSCPDirectoryNeedsRecursive, remotePath)
+	}
+
+	walker := c.sftp.Walk(remotePath)
+
+	for walker.Step() {
+		err = walker.Err()
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %w", walker.Path(), err)
+		}
+
+		relative := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), remotePath), "/")
+		destination := filepath.Join(target, filepath.FromSlash(relative))
+
+		if walker.Stat().IsDir() {
+			err = os.MkdirAll(destination, scpDirectoryMode)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", destination, err)
+			}
+
+			continue
+		}
+
+		err = c.downloadFile(walker.Path(), destination, walker.Stat().Mode())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *scpCopier) downloadFile(remotePath, localPath string, mode fs.FileMode) error {
+	source, err := c.sftp.Open(remotePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", remotePath, err)
+	}
+
+	defer func() { _ = source.Close() }()
+
+	destination, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, permOrDefault(mode))
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", localPath, err)
+	}
+
+	written, err := io.Copy(destination, source)
+	if err != nil {
+		_ = destination.Close()
+
+		return fmt.Errorf("failed to download %s: %w", remotePath, err)
+	}
+
+	err = destination.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", localPath, err)
+	}
+
+	c.files++
+	c.bytes += written
+
+	return nil
+}
+
+// upload copies localPath to remotePath.
+func (c *scpCopier) upload(localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", localPath, err)
+	}
+
+	target := remotePath
+
+	remoteInfo, err := c.sftp.Stat(remotePath)
+	if err == nil && remoteInfo.IsDir() {
+		target = path.Join(remotePath, filepath.Base(localPath))
+	}
+
+	if !info.IsDir() {
+		return c.uploadFile(localPath, target, info.Mode())
+	}
+
+	if !c.recursive {
+		return fmt.Errorf("%w: %s", ErrSCPDirectoryNeedsRecursive, localPath)
+	}
+
+	return filepath.WalkDir(localPath, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(localPath, current)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", current, err)
+		}
+
+		destination := path.Join(target, filepath.ToSlash(relative))
+
+		if entry.IsDir() {
+			err = c.sftp.MkdirAll(destination)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %w", destination, err)
+			}
+
+			return nil
+		}
+
+		entryInfo, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", current, err)
+		}
+
+		return c.uploadFile(current, destination, entryInfo.Mode())
+	})
+}
+
+func (c *scpCopier) uploadFile(localPath, remotePath string, mode fs.FileMode) error {
+	source, err := os.Open(localPath) //nolint:gosec // the user chose the file to upload
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", localPath, err)
+	}
+
+	defer func() { _ = source.Close() }()
+
+	destination, err := c.sftp.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", remotePath, err)
+	}
+
+	written, err := io.Copy(destination, source)
+	if err != nil {
+		_ = destination.Close()
+
+		return fmt.Errorf("failed to upload %s: %w", localPath, err)
+	}
+
+	// Not every SFTP server supports setting modes; the content is what
+	// matters.
+	_ = destination.Chmod(permOrDefault(mode))
+
+	err = destination.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", remotePath, err)
+	}
+
+	c.files++
+	c.bytes += written
+
+	return nil
+}
+
+func permOrDefault(mode fs.FileMode) fs.FileMode {
+	if mode.Perm() == 0 {
+		return scpDefaultFileMode
+	}
+
+	return mode.Perm()
+}
@@ -0,0 +1,156 @@
+//nolint:testpackage // needs access to unexported scp helpers
+package commands
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSCPLocation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		arg  string
+		want scpLocation
+	}{
+		{arg: "my-app:/tmp/heap.hprof", want: scpLocation{app: "my-app", path: "/tmp/heap.hprof"}},
+		{arg: "my-app:", want: scpLocation{app: "my-app", path: "."}},
+		{arg: "./local", want: scpLocation{path: "./local"}},
+		{arg: "./dir:with-colon", want: scpLocation{path: "./dir:with-colon"}},
+		{arg: `C:\Users\me`, want: scpLocation{path: `C:\Users\me`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, parseSCPLocation(tt.arg))
+		})
+	}
+}
+
+// newInMemorySFTPClient returns a client of an in-memory SFTP server.
+func newInMemorySFTPClient(t *testing.T) *sftp.Client {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+
+	server := sftp.NewRequestServer(serverConn, sftp.InMemHandler())
+
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client
+}
+
+func TestSCPCopier_UploadAndDownloadDirectory(t *testing.T) {
+	t.Parallel()
+
+	client := newInMemorySFTPClient(t)
+
+	source := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(source, "nested"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "a.txt"), []byte("alpha"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(source, "nested", "b.txt"), []byte("beta"), 0o600))
+
+	uploader := &scpCopier{sftp: client, recursive: true}
+	require.NoError(t, uploader.upload(source, "/app/fixtures"))
+	assert.Equal(t, 2, uploader.files)
+	assert.Equal(t, int64(9), uploader.bytes)
+
+	remote, err := client.Open("/app/fixtures/nested/b.txt")
+	require.NoError(t, err)
+
+	content, err := io.ReadAll(remote)
+	require.NoError(t, err)
+	assert.Equal(t, "beta", string(content))
+	_ = remote.Close()
+
+	// Downloading into an existing directory keeps the remote name.
+	destination := t.TempDir()
+	downloader := &scpCopier{sftp: client, recursive: true}
+	require.NoError(t, downloader.download("/app/fixtures", destination))
+
+	content, err = os.ReadFile(filepath.Join(destination, "fixtures", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(content))
+
+	content, err = os.ReadFile(filepath.Join(destination, "fixtures", "nested", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "beta", string(content))
+}
+
+func TestSCPCopier_DirectoryNeedsRecursive(t *testing.T) {
+	t.Parallel()
+
+	client := newInMemorySFTPClient(t)
+	require.NoError(t, client.MkdirAll("/app/logs"))
+
+	copier := &scpCopier{sftp: client}
+
+	require.ErrorIs(t, copier.download("/app/logs", t.TempDir()), ErrSCPDirectoryNeedsRecursive)
+	require.ErrorIs(t, copier.upload(t.TempDir(), "/app"), ErrSCPDirectoryNeedsRecursive)
+}
+
+func TestSCPCopier_UploadFileIntoDirectory(t *testing.T) {
+	t.Parallel()
+
+	client := newInMemorySFTPClient(t)
+	require.NoError(t, client.MkdirAll("/tmp"))
+
+	local := filepath.Join(t.TempDir(), "dump.sql")
+	require.NoError(t, os.WriteFile(local, []byte("select 1;"), 0o600))
+
+	copier := &scpCopier{sftp: client}
+	require.NoError(t, copier.upload(local, "/tmp"))
+
+	info, err := client.Stat("/tmp/dump.sql")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), info.Size())
+}
+
+func TestAppsSSHCommand_ForwardFlags(t *testing.T) {
+	t.Parallel()
+
+	cmd := newAppsSSHCommand()
+
+	forward := cmd.Flags().Lookup("forward")
+	require.NotNil(t, forward)
+	assert.Equal(t, "L", forward.Shorthand)
+
+	skip := cmd.Flags().Lookup("skip-remote-execution")
+	require.NotNil(t, skip)
+	assert.Equal(t, "N", skip.Shorthand)
+
+	cmd.SetArgs([]string{"my-app", "-N", "--command", "ls"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	require.Error(t, cmd.Execute())
+}
+
+func TestAppsSSHCommand_InvalidForward(t *testing.T) {
+	t.Parallel()
+
+	cmd := newAppsSSHCommand()
+	cmd.SetArgs([]string{"my-app", "-L", "5432"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid -L")
+}
@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+	terminalBaudRate      = 115200
+	// terminalResizeInterval is how often the local terminal size is checked
+	// and forwarded to the remote PTY.
+	terminalResizeInterval = 250 * time.Millisecond
+)
+
+// sshOptions are the flags of apps ssh.
+type sshOptions struct {
+	index               int
+	processType         string
+	command             string
+	forwardSpecs        []string
+	forwards            []capi.SSHLocalForward
+	skipRemoteExecution bool
+}
+
+// runSSHSession starts the requested port forwards, then runs the command
+// or, when none is given, an interactive shell. With skipRemoteExecution it
+// only waits for an interrupt. A remote non-zero exit status is returned as
+// an ExitCodeError.
+func runSSHSession(ctx context.Context, sshClient *ssh.Client, opts *sshOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	err := startSSHForwards(ctx, sshClient, opts.forwards)
+	if err != nil {
+		return err
+	}
+
+	if opts.skipRemoteExecution {
+		return waitForInterrupt(ctx, sshClient)
+	}
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session: %w", err)
+	}
+
+	defer func() { _ = session.Close() }()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	if opts.command != "" {
+		return sshExitError(session.Run(opts.command))
+	}
+
+	return sshExitError(runInteractiveShell(ctx, session))
+}
+
+// startSSHForwards listens on every local address and relays connections
+// through sshClient until ctx is canceled.
+func startSSHForwards(ctx context.Context, sshClient *ssh.Client, forwards []capi.SSHLocalForward) error {
+	for _, forward := range forwards {
+		listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", forward.LocalAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", forward.LocalAddress, err)
+		}
+
+		_, _ = fmt.Fprintf(os.Stderr, "Forwarding %s -> %s\n", listener.Addr(), forward.RemoteAddress)
+
+		go func() {
+			err := capi.ForwardSSHPort(ctx, sshClient, listener, forward.RemoteAddress, func(err error) {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			})
+			if err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// waitForInterrupt keeps the connection, and so its forwards, open until
+// Ctrl-C or until the proxy closes it.
+func waitForInterrupt(ctx context.Context, sshClient *ssh.Client) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	closed := make(chan error, 1)
+
+	go func() { closed <- sshClient.Wait() }()
+
+	_, _ = os.Stderr.WriteString("Press Ctrl-C to stop forwarding\n")
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-closed:
+		return fmt.Errorf("SSH connection closed: %w", err)
+	}
+}
+
+// runInteractiveShell starts a login shell, allocating a PTY sized like the
+// local terminal when stdin is one.
+func runInteractiveShell(ctx context.Context, session *ssh.Session) error {
+	stdin := int(os.Stdin.Fd())
+
+	if term.IsTerminal(stdin) {
+		width, height := terminalSize()
+
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm"
+		}
+
+		err := session.RequestPty(termType, height, width, ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: terminalBaudRate,
+			ssh.TTY_OP_OSPEED: terminalBaudRate,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to allocate a PTY: %w", err)
+		}
+
+		state, err := term.MakeRaw(stdin)
+		if err != nil {
+			return fmt.Errorf("failed to put the terminal into raw mode: %w", err)
+		}
+
+		defer func() { _ = term.Restore(stdin, state) }()
+
+		resizeCtx, stopResizing := context.WithCancel(ctx)
+		defer stopResizing()
+
+		go forwardTerminalResizes(resizeCtx, session, width, height)
+	}
+
+	err := session.Shell()
+	if err != nil {
+		return fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	return session.Wait() //nolint:wrapcheck // translated by sshExitError
+}
+
+// forwardTerminalResizes keeps the remote PTY the size of the local
+// terminal until ctx is canceled.
+func forwardTerminalResizes(ctx context.Context, session *ssh.Session, width, height int) {
+	ticker := time.NewTicker(terminalResizeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			newWidth, newHeight := terminalSize()
+			if newWidth == width && newHeight == height {
+				continue
+			}
+
+			width, height = newWidth, newHeight
+			_ = session.WindowChange(height, width)
+		}
+	}
+}
+
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultTerminalWidth, defaultTerminalHeight
+	}
+
+	return width, height
+}
+
+// sshExitError propagates the remote exit status: a non-zero status becomes
+// a silent ExitCodeError so that capi exits with it.
+func sshExitError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitCodeError{Code: exitErr.ExitStatus()}
+	}
+
+	return fmt.Errorf("SSH session failed: %w", err)
+}
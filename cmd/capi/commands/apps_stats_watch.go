@@ -0,0 +1,250 @@
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultStatsWatchInterval = 10 * time.Second
+
+// statsWatchOptions are the flags of apps stats --watch.
+type statsWatchOptions struct {
+	watch    bool
+	interval time.Duration
+	count    int
+}
+
+// statsRecord is one instance sample as printed by apps stats --watch.
+type statsRecord struct {
+	App string `json:"app"`
+	capi.StatsSample
+}
+
+// statsCSVHeader lists the columns of --output csv.
+var statsCSVHeader = []string{
+	"time", "app", "process_type", "process_guid", "index", "state",
+	"cpu", "mem", "mem_quota", "disk", "disk_quota", "log_rate", "uptime",
+}
+
+// runAppsStatsWatch polls the stats of an app's processes until interrupted
+// or until opts.count samples were recorded, printing every instance sample and
+// finally a summary per instance.
+func runAppsStatsWatch(cmd *cobra.Command, nameOrGUID string, opts *statsWatchOptions) error {
+	format := viper.GetString("output")
+	if format != OutputFormatNDJSON && format != OutputFormatCSV && format != "table" && format != "" {
+		return fmt.Errorf("%w: %q", ErrInvalidStatsWatchOutput, format)
+	}
+
+	if opts.interval <= 0 {
+		return ErrInvalidStatsWatchInterval
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	appGUID, appName, err := resolveApp(ctx, client, nameOrGUID)
+	if err != nil {
+		return err
+	}
+
+	writer := newStatsSampleWriter(format, os.Stdout)
+	recorder := capi.NewStatsRecorder(opts.count)
+
+	err = writer.header()
+	if err != nil {
+		return err
+	}
+
+	// Only samples that were fetched count toward --count.
+	taken := 0
+
+	for first := true; opts.count <= 0 || taken < opts.count; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return printStatsSummary(format, appName, recorder)
+			case <-time.After(opts.interval):
+			}
+		}
+
+		samples, err := sampleAppStats(ctx, client, appGUID)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+
+			continue
+		}
+
+		taken++
+
+		recorder.Record(samples...)
+
+		err = writer.write(appName, samples)
+		if err != nil {
+			return err
+		}
+	}
+
+	return printStatsSummary(format, appName, recorder)
+}
+
+// sampleAppStats takes one sample of every instance of every process of an
+// app.
+func sampleAppStats(ctx context.Context, client capi.Client, appGUID string) ([]capi.StatsSample, error) {
+	processes, err := fetchAppProcesses(ctx, client, appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []capi.StatsSample
+
+	for _, process := range processes.Resources {
+		if process.Instances == 0 {
+			continue
+		}
+
+		stats, err := client.Processes().GetStats(ctx, process.GUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats of the %s process: %w", process.Type, err)
+		}
+
+		samples = append(samples, capi.NewStatsSamples(process.GUID, process.Type, stats, time.Now().UTC())...)
+	}
+
+	return samples, nil
+}
+
+// statsSampleWriter prints samples in one of the --watch output formats.
+type statsSampleWriter struct {
+	format string
+	out    io.Writer
+	json   *json.Encoder
+	csv    *csv.Writer
+}
+
+func newStatsSampleWriter(format string, out io.Writer) *statsSampleWriter {
+	return &statsSampleWriter{format: format, out: out, json: json.NewEncoder(out), csv: csv.NewWriter(out)}
+}
+
+func (w *statsSampleWriter) header() error {
+	if w.format != OutputFormatCSV {
+		return nil
+	}
+
+	err := w.csv.Write(statsCSVHeader)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	w.csv.Flush()
+
+	return nil
+}
+
+func (w *statsSampleWriter) write(appName string, samples []capi.StatsSample) error {
+	for _, sample := range samples {
+		var err error
+
+		switch w.format {
+		case OutputFormatNDJSON:
+			err = w.json.Encode(statsRecord{App: appName, StatsSample: sample})
+		case OutputFormatCSV:
+			err = w.csv.Write(statsCSVRow(appName, sample))
+		default:
+			_, err = fmt.Fprintf(w.out, "%s  %s/%d  %-8s  cpu %6.2f%%  mem %s  disk %s  logs %s/s\n",
+				sample.Time.Local().Format(TimeFormatDisplay), sample.ProcessType, sample.Index, sample.State,
+				sample.CPU, formatUsage(sample.Mem, sample.MemQuota), formatUsage(sample.Disk, sample.DiskQuota),
+				formatBytes(int64(sample.LogRate)))
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write stats record: %w", err)
+		}
+	}
+
+	if w.format == OutputFormatCSV {
+		w.csv.Flush()
+
+		err := w.csv.Error()
+		if err != nil {
+			return fmt.Errorf("failed to write stats record: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func statsCSVRow(appName string, sample capi.StatsSample) []string {
+	return []string{
+		sample.Time.Format(time.RFC3339),
+		appName,
+		sample.ProcessType,
+		sample.ProcessGUID,
+		strconv.Itoa(sample.Index),
+		sample.State,
+		strconv.FormatFloat(sample.CPU, 'f', 2, 64), //nolint:mnd // two decimals, float64
+		strconv.FormatInt(sample.Mem, 10),
+		strconv.FormatInt(sample.MemQuota, 10),
+		strconv.FormatInt(sample.Disk, 10),
+		strconv.FormatInt(sample.DiskQuota, 10),
+		strconv.Itoa(sample.LogRate),
+		strconv.Itoa(sample.Uptime),
+	}
+}
+
+// printStatsSummary prints min/avg/p95 per instance. The machine-readable
+// formats keep stdout for records, so their summary goes to stderr.
+func printStatsSummary(format, appName string, recorder *capi.StatsRecorder) error {
+	summaries := recorder.Summaries()
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	out := os.Stdout
+	if format == OutputFormatNDJSON || format == OutputFormatCSV {
+		out = os.Stderr
+	}
+
+	_, _ = fmt.Fprintf(out, "\nSummary for application '%s' (min / avg / p95):\n\n", appName)
+
+	table := tablewriter.NewWriter(out)
+	table.Header("Process", "Index", "Samples", "CPU%", "Memory", "Disk")
+
+	for _, summary := range summaries {
+		_ = table.Append(
+			summary.ProcessType,
+			strconv.Itoa(summary.Index),
+			strconv.Itoa(summary.Samples),
+			fmt.Sprintf("%.2f / %.2f / %.2f", summary.CPU.Min, summary.CPU.Avg, summary.CPU.P95),
+			formatAggregateBytes(summary.Mem),
+			formatAggregateBytes(summary.Disk),
+		)
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render stats summary: %w", err)
+	}
+
+	return nil
+}
+
+func formatAggregateBytes(aggregate capi.StatsAggregate) string {
+	return formatBytes(int64(aggregate.Min)) + " / " + formatBytes(int64(aggregate.Avg)) + " / " + formatBytes(int64(aggregate.P95))
+}
@@ -0,0 +1,146 @@
+//nolint:testpackage // needs the newClientFunc seam and the unexported stats writer
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statsAppsStub struct {
+	capi.AppsClient
+}
+
+func (s *statsAppsStub) Get(_ context.Context, guid string, _ ...capi.AppGetOption) (*capi.App, error) {
+	return &capi.App{Resource: capi.Resource{GUID: guid}, Name: "api"}, nil
+}
+
+func statsWatchClient() *fakeClient {
+	return &fakeClient{
+		apps: &statsAppsStub{},
+		processes: &topProcessesStub{
+			processes: []capi.Process{
+				topProcess("api-web", "api-guid", "web", 2),
+				topProcess("api-task", "api-guid", "task", 0),
+			},
+			stats: map[string][]capi.ProcessStatsDetail{
+				"api-web": {topStat(0, "RUNNING", 0.10, 200, 60), topStat(1, "RUNNING", 0.30, 400, 60)},
+			},
+		},
+	}
+}
+
+func TestAppsStatsWatch_NDJSON(t *testing.T) {
+	withStubClient(t, statsWatchClient())
+	withOutputFormat(t, OutputFormatNDJSON)
+
+	root := newTestRootCommand(NewAppsCommand())
+	root.SetArgs([]string{"apps", "stats", "api-guid", "--watch", "--interval", "1ms", "--count", "2"})
+
+	output := captureStdout(t, func() {
+		require.NoError(t, root.Execute())
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 4)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "api", record["app"])
+	assert.Equal(t, "api-web", record["process_guid"])
+	assert.Equal(t, "web", record["process_type"])
+	assert.InDelta(t, 1.0, record["index"], 0)
+	assert.InDelta(t, 30.0, record["cpu"], 0.001)
+	assert.InDelta(t, float64(400*mib), record["mem"], 0)
+	assert.NotEmpty(t, record["time"])
+}
+
+// flakyProcessesStub fails the first stats request.
+type flakyProcessesStub struct {
+	*topProcessesStub
+
+	calls int
+}
+
+func (s *flakyProcessesStub) GetStats(ctx context.Context, guid string) (*capi.ProcessStats, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, capi.ErrNotFound
+	}
+
+	return s.topProcessesStub.GetStats(ctx, guid)
+}
+
+func TestAppsStatsWatch_CountsRecordedSamplesOnly(t *testing.T) {
+	client := statsWatchClient()
+	processes := &flakyProcessesStub{topProcessesStub: client.processes.(*topProcessesStub)} //nolint:forcetypeassert // set by statsWatchClient
+	client.processes = processes
+
+	withStubClient(t, client)
+	withOutputFormat(t, OutputFormatNDJSON)
+
+	root := newTestRootCommand(NewAppsCommand())
+	root.SetArgs([]string{"apps", "stats", "api-guid", "--watch", "--interval", "1ms", "--count", "2"})
+
+	output := captureStdout(t, func() {
+		require.NoError(t, root.Execute())
+	})
+
+	assert.Equal(t, 3, processes.calls)
+	assert.Len(t, strings.Split(strings.TrimSpace(output), "\n"), 4)
+}
+
+func TestAppsStatsWatch_CSV(t *testing.T) {
+	withStubClient(t, statsWatchClient())
+	withOutputFormat(t, OutputFormatCSV)
+
+	root := newTestRootCommand(NewAppsCommand())
+	root.SetArgs([]string{"apps", "stats", "api-guid", "--watch", "--interval", "1ms", "--count", "1"})
+
+	output := captureStdout(t, func() {
+		require.NoError(t, root.Execute())
+	})
+
+	rows, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, statsCSVHeader, rows[0])
+	assert.Equal(t, []string{"api", "web", "api-web", "0", "RUNNING", "10.00"}, rows[1][1:7])
+}
+
+func TestAppsStatsWatch_RejectsYAML(t *testing.T) {
+	withOutputFormat(t, OutputFormatYAML)
+
+	root := newTestRootCommand(NewAppsCommand())
+	root.SetArgs([]string{"apps", "stats", "api", "--watch"})
+
+	require.ErrorIs(t, root.Execute(), ErrInvalidStatsWatchOutput)
+}
+
+func TestStatsSampleWriter_Table(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer := newStatsSampleWriter("table", &buf)
+	require.NoError(t, writer.header())
+	require.NoError(t, writer.write("api", []capi.StatsSample{{
+		Time:        time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ProcessType: "web",
+		Index:       1,
+		State:       "RUNNING",
+		CPU:         12.5,
+		Mem:         512 * mib,
+		MemQuota:    1024 * mib,
+	}}))
+
+	assert.Contains(t, buf.String(), "web/1")
+	assert.Contains(t, buf.String(), "cpu  12.50%")
+	assert.Contains(t, buf.String(), "mem 512.0M/1.0G")
+}
@@ -0,0 +1,331 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultBatchConcurrency = 5
+
+type batchApplyOptions struct {
+	concurrency int
+	dryRun      bool
+	transaction bool
+}
+
+// batchResultRow is the printable form of a capi.BatchResult.
+type batchResultRow struct {
+	ID       string `json:"id"                yaml:"id"`
+	Type     string `json:"type"              yaml:"type"`
+	Resource string `json:"resource"          yaml:"resource"`
+	Level    int    `json:"level"             yaml:"level"`
+	Status   string `json:"status"            yaml:"status"`
+	GUID     string `json:"guid,omitempty"    yaml:"guid,omitempty"`
+	Duration string `json:"duration"          yaml:"duration"`
+	Error    string `json:"error,omitempty"   yaml:"error,omitempty"`
+}
+
+// NewBatchCommand creates the batch command group.
+func NewBatchCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run many operations in one go",
+		Long:  "Create, update and delete many resources from a file of batch operations.",
+	}
+
+	cmd.AddCommand(newBatchApplyCommand())
+
+	return cmd
+}
+
+func newBatchApplyCommand() *cobra.Command {
+	opts := &batchApplyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "apply FILE",
+		Short: "Apply a YAML or JSON file of batch operations",
+		Long: `Apply a list of operations read from a YAML or JSON file ("-" for stdin).
+
+Each operation has an id, a type (create, update, delete or get), a resource
+and, depending on the type, a guid and the request as data, in the API's field
+names. Operations run concurrently unless they depend on each other, either
+through depends_on or by referencing another operation's result as
+"${ref:ID.GUID}" in their data. Dependents of a failed operation are skipped.
+
+Resources: app, space, organization, route, route_destination, domain,
+service_instance, service_credential_binding, role, security_group,
+security_group_binding, organization_quota, space_quota, metadata,
+feature_flag, environment_variable_group, isolation_segment_entitlement,
+organization_quota_assignment and space_quota_assignment.
+
+With --transaction, the resources updated or deleted are snapshotted first and
+every change is undone when an operation fails.`,
+		Example: `  # ops.yaml
+  operations:
+    - id: org
+      type: create
+      resource: organization
+      data: {name: team}
+    - id: dev
+      type: create
+      resource: space
+      data:
+        name: dev
+        relationships: {organization: {data: {guid: "${ref:org.GUID}"}}}
+    - id: old
+      type: delete
+      resource: space
+      guid: 2a7b1c1e-5d1f-4f43-9a1e-0d6c3f0f6a11
+
+  # Show the schedule without running anything
+  capi batch apply ops.yaml --dry-run
+
+  # Apply, undoing everything if one operation fails
+  capi batch apply ops.yaml --transaction --concurrency 10`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBatchApply(cmd, args[0], opts)
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", defaultBatchConcurrency, "operations to run at once")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "validate and show the schedule without running anything")
+	cmd.Flags().BoolVar(&opts.transaction, "transaction", false, "roll every change back when an operation fails")
+
+	return cmd
+}
+
+func runBatchApply(cmd *cobra.Command, path string, opts *batchApplyOptions) error {
+	if opts.concurrency <= 0 {
+		return ErrInvalidBatchConcurrency
+	}
+
+	data, err := readFileOrStdin(cmd.InOrStdin(), path, "operations")
+	if err != nil {
+		return err
+	}
+
+	operations, err := capi.ParseBatchOperations(data)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	plan, err := capi.PlanBatchOperations(operations)
+	if err != nil {
+		return fmt.Errorf("invalid batch: %w", err)
+	}
+
+	if opts.dryRun {
+		return renderBatchPlan(plan)
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	executor := capi.NewBatchExecutor(client, opts.concurrency)
+
+	var (
+		results []capi.BatchResult
+		journal *capi.BatchJournal
+		runErr  error
+	)
+
+	if opts.transaction {
+		transaction := capi.NewBatchTransaction(executor).SetRollback(true)
+		for _, operation := range operations {
+			transaction.Add(operation)
+		}
+
+		results, runErr = transaction.Execute(ctx)
+		journal = transaction.Journal()
+	} else {
+		results, runErr = executor.Execute(ctx, operations)
+	}
+
+	if results == nil {
+		return runErr
+	}
+
+	err = renderBatchResults(operations, results, journal)
+	if err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+
+	return batchFailures(results)
+}
+
+func batchFailures(results []capi.BatchResult) error {
+	failed := 0
+
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	if failed == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d of %d", ErrBatchOperationsFailed, failed, len(results))
+}
+
+func renderBatchPlan(plan []capi.BatchPlanStep) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		return encodeBatchJSON(plan)
+	case OutputFormatYAML:
+		return encodeBatchYAML(plan)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("ID", "Type", "Resource", "Level", "Depends On")
+
+	for _, step := range plan {
+		_ = table.Append(step.ID, step.Type, step.Resource, strconv.Itoa(step.Level), strings.Join(step.DependsOn, ", "))
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render batch plan: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "\n%d operation(s) would run; nothing was changed (--dry-run)\n", len(plan))
+
+	return nil
+}
+
+// newBatchResultRows builds a row per operation. With the journal of a
+// transaction, operations that succeeded show whether their rollback undid
+// them.
+func newBatchResultRows(operations []capi.BatchOperation, results []capi.BatchResult, journal *capi.BatchJournal) []batchResultRow {
+	rows := make([]batchResultRow, len(results))
+
+	for i, result := range results {
+		row := batchResultRow{
+			ID:       result.ID,
+			Type:     operations[i].Type,
+			Resource: operations[i].Resource,
+			Level:    result.Level,
+			Status:   "ok",
+			Duration: result.Duration.Round(time.Millisecond).String(),
+		}
+
+		switch {
+		case result.Skipped:
+			row.Status = "skipped"
+		case !result.Success:
+			row.Status = "failed"
+		case operations[i].Type == "create":
+			row.GUID = batchResultGUID(result.Data)
+		}
+
+		if result.Error != nil {
+			row.Error = result.Error.Error()
+		}
+
+		if result.Success && journal != nil && i < len(journal.Entries) {
+			applyBatchRollbackState(&row, journal.Entries[i])
+		}
+
+		rows[i] = row
+	}
+
+	return rows
+}
+
+// applyBatchRollbackState reports the rollback of a succeeded operation.
+func applyBatchRollbackState(row *batchResultRow, entry capi.BatchJournalEntry) {
+	switch entry.State {
+	case capi.BatchJournalRolledBack:
+		row.Status = "rolled back"
+	case capi.BatchJournalRollbackFailed:
+		row.Status = "rollback failed"
+		row.Error = entry.Error
+	case capi.BatchJournalIrreversible:
+		row.Status = "irreversible"
+		row.Error = entry.Error
+	case capi.BatchJournalPending, capi.BatchJournalApplied, capi.BatchJournalFailed:
+	}
+}
+
+// batchResultGUID returns the GUID of a created resource, or "" when the
+// result is not a resource, like the job of a managed service instance.
+func batchResultGUID(data interface{}) string {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+
+	var resource struct {
+		GUID string `json:"guid"`
+	}
+
+	_ = json.Unmarshal(encoded, &resource)
+
+	return resource.GUID
+}
+
+func renderBatchResults(operations []capi.BatchOperation, results []capi.BatchResult, journal *capi.BatchJournal) error {
+	rows := newBatchResultRows(operations, results, journal)
+
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		return encodeBatchJSON(rows)
+	case OutputFormatYAML:
+		return encodeBatchYAML(rows)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("ID", "Type", "Resource", "Level", "Status", "GUID", "Duration", "Error")
+
+	for _, row := range rows {
+		_ = table.Append(row.ID, row.Type, row.Resource, strconv.Itoa(row.Level), row.Status, row.GUID, row.Duration, row.Error)
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render batch results: %w", err)
+	}
+
+	return nil
+}
+
+func encodeBatchJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch as JSON: %w", err)
+	}
+
+	return nil
+}
+
+func encodeBatchYAML(value interface{}) error {
+	err := yaml.NewEncoder(os.Stdout).Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch as YAML: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBatchAppRename = errors.New("name taken")
+
+// batchAppsClient renames apps, failing for the GUID "taken".
+type batchAppsClient struct {
+	capi.AppsClient
+
+	renamed []string
+}
+
+func (s *batchAppsClient) Get(_ context.Context, guid string, _ ...capi.AppGetOption) (*capi.App, error) {
+	return &capi.App{Resource: capi.Resource{GUID: guid}, Name: strings.TrimSuffix(guid, "-guid")}, nil
+}
+
+func (s *batchAppsClient) Update(_ context.Context, guid string, request *capi.AppUpdateRequest) (*capi.App, error) {
+	if guid == "taken" {
+		return nil, errBatchAppRename
+	}
+
+	s.renamed = append(s.renamed, guid+"="+*request.Name)
+
+	return &capi.App{Resource: capi.Resource{GUID: guid}, Name: *request.Name}, nil
+}
+
+func writeBatchFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ops.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+const batchRenameOps = `
+- id: api
+  type: update
+  resource: app
+  guid: api-guid
+  data: {name: api-v2}
+- id: worker
+  type: update
+  resource: app
+  guid: taken
+  data: {name: worker-v2}
+- id: label
+  type: update
+  resource: metadata
+  guid: taken
+  depends_on: [worker]
+  data: {resource: app, metadata: {labels: {version: v2}}}
+`
+
+func TestBatchApply_DryRunShowsPlan(t *testing.T) {
+	withOutputFormat(t, "table")
+	withClientError(t, errors.New("dry runs must not connect")) //nolint:err113 // test-only sentinel
+
+	out, err := runCommand(t, NewBatchCommand(), "apply", writeBatchFile(t, batchRenameOps), "--dry-run")
+	require.NoError(t, err)
+
+	assert.Contains(t, out, "label")
+	assert.Contains(t, out, "worker")
+	assert.Contains(t, out, "3 operation(s) would run")
+}
+
+func TestBatchApply_ReportsResults(t *testing.T) {
+	apps := &batchAppsClient{}
+
+	withStubClient(t, &fakeClient{apps: apps})
+	withOutputFormat(t, OutputFormatJSON)
+
+	out, err := runCommand(t, NewBatchCommand(), "apply", writeBatchFile(t, batchRenameOps))
+	require.ErrorIs(t, err, ErrBatchOperationsFailed)
+
+	assert.Equal(t, []string{"api-guid=api-v2"}, apps.renamed)
+	assert.Contains(t, out, `"status": "ok"`)
+	assert.Contains(t, out, `"status": "failed"`)
+	assert.Contains(t, out, `"status": "skipped"`)
+	assert.Contains(t, out, "name taken")
+}
+
+func TestBatchApply_TransactionReportsRollback(t *testing.T) {
+	apps := &batchAppsClient{}
+
+	withStubClient(t, &fakeClient{apps: apps})
+	withOutputFormat(t, OutputFormatJSON)
+
+	out, err := runCommand(t, NewBatchCommand(), "apply", writeBatchFile(t, batchRenameOps), "--transaction")
+	require.ErrorIs(t, err, capi.ErrTransactionFailed)
+
+	assert.Equal(t, []string{"api-guid=api-v2", "api-guid=api"}, apps.renamed)
+	assert.Contains(t, out, `"status": "rolled back"`)
+	assert.NotContains(t, out, `"status": "ok"`)
+	assert.Contains(t, out, `"status": "failed"`)
+	assert.Contains(t, out, `"status": "skipped"`)
+}
+
+func TestBatchApply_RejectsInvalidInput(t *testing.T) {
+	_, err := runCommand(t, NewBatchCommand(), "apply", writeBatchFile(t, batchRenameOps), "--concurrency", "0")
+	require.ErrorIs(t, err, ErrInvalidBatchConcurrency)
+
+	_, err = runCommand(t, NewBatchCommand(), "apply", writeBatchFile(t, `[{id: a, type: get, resource: widget, guid: g}]`))
+	require.ErrorIs(t, err, capi.ErrUnsupportedResourceType)
+}
@@ -91,6 +91,7 @@ func newBuildpacksListCommand() *cobra.Command {
 	cmd.Flags().IntVar(&perPage, "per-page", constants.DefaultPageSize, "results per page")
 	cmd.Flags().BoolVar(&enabled, "enabled", false, "filter by enabled buildpacks")
 	cmd.Flags().StringVar(&stack, "stack", "", "filter by stack")
+	addSelectorFlag(cmd, "only buildpacks matching this label selector")
 
 	return cmd
 }
@@ -112,6 +113,11 @@ func runBuildpacksList(filters *buildpacksListFilters) error {
 	ctx := context.Background()
 	params := buildBuildpacksListParams(filters)
 
+	err = applySelectorFlag(filters.cmd, params)
+	if err != nil {
+		return err
+	}
+
 	buildpacks, err := client.Buildpacks().List(ctx, params)
 	if err != nil {
 		return fmt.Errorf("failed to list buildpacks: %w", err)
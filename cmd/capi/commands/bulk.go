@@ -0,0 +1,302 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// bulkOptions are the flags of commands acting on every resource matching
+// the global --selector.
+type bulkOptions struct {
+	force    bool
+	parallel int
+	space    string
+}
+
+// bulkTarget is a resource matched by --selector.
+type bulkTarget struct {
+	GUID string
+	Name string
+}
+
+// bulkAction describes what a bulk command does to each target.
+type bulkAction struct {
+	// verb and noun make up the messages, e.g. "restart" and "application".
+	verb string
+	noun string
+	run  func(ctx context.Context, target bulkTarget) (*capi.Job, error)
+}
+
+// bulkResult is the outcome of the action on one target.
+type bulkResult struct {
+	Name   string `json:"name"            yaml:"name"`
+	GUID   string `json:"guid"            yaml:"guid"`
+	Status string `json:"status"          yaml:"status"`
+	Job    string `json:"job,omitempty"   yaml:"job,omitempty"`
+	Error  string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// addSelectorFlag registers -l/--selector on the commands that filter by
+// labels.
+func addSelectorFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringP("selector", "l", "", usage+", e.g. tier=web,env!=dev")
+}
+
+// selectorFlag parses the -l/--selector flag. Without the flag the selector
+// is empty.
+func selectorFlag(cmd *cobra.Command) (*capi.LabelSelector, error) {
+	flag := cmd.Flag("selector")
+	if flag == nil {
+		return capi.NewLabelSelector(), nil
+	}
+
+	selector, err := capi.ParseLabelSelector(flag.Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("--selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+// applySelectorFlag restricts a list to the resources matching the
+// -l/--selector flag.
+func applySelectorFlag(cmd *cobra.Command, params *capi.QueryParams) error {
+	selector, err := selectorFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.WithSelector(selector)
+
+	return nil
+}
+
+// bulkSelector returns the selector of a command accepting either one
+// resource name or --selector, or nil when a name was given.
+func bulkSelector(cmd *cobra.Command, args []string, noun string) (*capi.LabelSelector, error) {
+	selector, err := selectorFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(args) > 0 && !selector.IsEmpty():
+		return nil, fmt.Errorf("%w: give a %s name or --selector", ErrBulkTargetConflict, noun)
+	case len(args) == 0 && selector.IsEmpty():
+		return nil, fmt.Errorf("%w: give a %s name or --selector", ErrBulkTargetRequired, noun)
+	case len(args) > 0:
+		return nil, nil //nolint:nilnil // a name was given: not a bulk operation
+	default:
+		return selector, nil
+	}
+}
+
+func addBulkFlags(cmd *cobra.Command, opts *bulkOptions, noun string) {
+	addSelectorFlag(cmd, "act on every "+noun+" matching this label selector")
+	cmd.Flags().IntVar(&opts.parallel, "parallel", 1, "with --selector, how many "+noun+"s to act on at once")
+	cmd.Flags().StringVarP(&opts.space, "space", "s", "", "with --selector, only match "+noun+"s in this space (default: targeted space)")
+}
+
+// bulkSpaceGUID returns the space a bulk command is restricted to: --space,
+// or the targeted space, or none.
+func bulkSpaceGUID(ctx context.Context, client capi.Client, opts *bulkOptions) (string, error) {
+	if opts.space != "" {
+		return resolveSpaceGUIDWithOrgFilter(ctx, client, opts.space)
+	}
+
+	return viper.GetString("space_guid"), nil
+}
+
+// runBulkAction previews the targets, asks for confirmation unless --force
+// is given, then applies the action to at most --parallel targets at a time
+// and reports every outcome. It fails if any target failed.
+func runBulkAction(cmd *cobra.Command, selector *capi.LabelSelector, targets []bulkTarget, action bulkAction, opts *bulkOptions) error {
+	if opts.parallel <= 0 {
+		return ErrInvalidBulkParallel
+	}
+
+	if len(targets) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "No %ss match %s\n", action.noun, selector)
+
+		return nil
+	}
+
+	err := previewBulkTargets(selector, targets, action)
+	if err != nil {
+		return err
+	}
+
+	if !opts.force {
+		confirmed, err := confirmBulkAction(cmd.InOrStdin(), targets, action)
+		if err != nil || !confirmed {
+			return err
+		}
+	}
+
+	results := applyBulkAction(targets, action, opts.parallel)
+
+	err = renderBulkResults(results)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %s failed for %d of %d %ss", ErrBulkActionFailed, action.verb, failed, len(results), action.noun)
+	}
+
+	return nil
+}
+
+func previewBulkTargets(selector *capi.LabelSelector, targets []bulkTarget, action bulkAction) error {
+	if viper.GetString("output") == OutputFormatJSON || viper.GetString("output") == OutputFormatYAML {
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "%d %s(s) match %s:\n", len(targets), action.noun, selector)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Name", "GUID")
+
+	for _, target := range targets {
+		_ = table.Append(target.Name, target.GUID)
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render %s preview: %w", action.noun, err)
+	}
+
+	return nil
+}
+
+// confirmBulkAction asks on the terminal whether to go ahead; without a
+// terminal --force is required.
+func confirmBulkAction(stdin io.Reader, targets []bulkTarget, action bulkAction) (bool, error) {
+	return confirmOnTerminal(stdin,
+		fmt.Sprintf("%s %d %s(s)?", capitalize(action.verb), len(targets), action.noun),
+		fmt.Sprintf("%s %d %ss", action.verb, len(targets), action.noun))
+}
+
+// confirmOnTerminal asks question on the terminal and reports whether the
+// answer was yes. Without a terminal it fails with ErrConfirmationRequired,
+// saying it was about to do what.
+func confirmOnTerminal(stdin io.Reader, question, what string) (bool, error) {
+	if file, ok := stdin.(*os.File); ok && !term.IsTerminal(int(file.Fd())) { //nolint:gosec // file descriptors fit in int
+		return false, fmt.Errorf("%w to %s", ErrConfirmationRequired, what)
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s (y/N): ", question)
+
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer == "y" || answer == "yes" {
+		return true, nil
+	}
+
+	_, _ = os.Stdout.WriteString("Cancelled\n")
+
+	return false, nil
+}
+
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+func applyBulkAction(targets []bulkTarget, action bulkAction, parallel int) []bulkResult {
+	ctx := context.Background()
+	results := make([]bulkResult, len(targets))
+	slots := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+
+	for i, target := range targets {
+		wg.Add(1)
+
+		slots <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result := bulkResult{Name: target.Name, GUID: target.GUID, Status: "ok"}
+
+			job, err := action.run(ctx, target)
+
+			switch {
+			case err != nil:
+				result.Status = "failed"
+				result.Error = err.Error()
+			case job != nil:
+				result.Job = job.GUID
+			}
+
+			results[i] = result
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func renderBulkResults(results []bulkResult) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(results)
+		if err != nil {
+			return fmt.Errorf("failed to encode results as JSON: %w", err)
+		}
+
+		return nil
+	case OutputFormatYAML:
+		err := yaml.NewEncoder(os.Stdout).Encode(results)
+		if err != nil {
+			return fmt.Errorf("failed to encode results as YAML: %w", err)
+		}
+
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Name", "GUID", "Status", "Job", "Error")
+
+	for _, result := range results {
+		_ = table.Append(result.Name, result.GUID, result.Status, result.Job, result.Error)
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render results: %w", err)
+	}
+
+	return nil
+}
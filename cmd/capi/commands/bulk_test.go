@@ -0,0 +1,119 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBulkAppCrashed = errors.New("app crashed")
+
+// bulkAppsClient lists web-1, web-2 and broken, records the query and
+// restarts every app but broken.
+type bulkAppsClient struct {
+	capi.AppsClient
+
+	mu        sync.Mutex
+	query     string
+	restarted []string
+}
+
+func (s *bulkAppsClient) List(_ context.Context, params *capi.QueryParams, _ ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	s.query = params.ToValues().Encode()
+
+	apps := make([]capi.App, 0, 3)
+	for _, name := range []string{"web-1", "web-2", "broken"} {
+		apps = append(apps, capi.App{Resource: capi.Resource{GUID: name + "-guid"}, Name: name})
+	}
+
+	return &capi.ListResponse[capi.App]{Resources: apps}, nil
+}
+
+func (s *bulkAppsClient) Restart(_ context.Context, guid string) (*capi.Job, error) {
+	if guid == "broken-guid" {
+		return nil, errBulkAppCrashed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restarted = append(s.restarted, guid)
+
+	return &capi.Job{Resource: capi.Resource{GUID: "job-" + guid}}, nil
+}
+
+func TestAppsRestart_SelectorForceRestartsEveryMatch(t *testing.T) {
+	apps := &bulkAppsClient{}
+
+	withStubClient(t, &fakeClient{apps: apps})
+	withOutputFormat(t, OutputFormatJSON)
+
+	out, err := runCommand(t, NewAppsCommand(), "restart", "--selector", "tier=web", "--parallel", "2", "--force")
+	require.ErrorIs(t, err, ErrBulkActionFailed)
+	assert.Contains(t, err.Error(), "1 of 3")
+
+	assert.Contains(t, apps.query, "label_selector=tier%3Dweb")
+
+	sort.Strings(apps.restarted)
+	assert.Equal(t, []string{"web-1-guid", "web-2-guid"}, apps.restarted)
+	assert.Contains(t, out, `"job": "job-web-1-guid"`)
+	assert.Contains(t, out, `"error": "app crashed"`)
+}
+
+func TestAppsRestart_SelectorNeedsConfirmation(t *testing.T) {
+	apps := &bulkAppsClient{}
+
+	withStubClient(t, &fakeClient{apps: apps})
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	t.Cleanup(func() { _ = reader.Close() })
+
+	cmd := NewAppsCommand()
+	cmd.SetIn(reader)
+
+	_, err = runCommand(t, cmd, "restart", "-l", "tier=web")
+	require.ErrorIs(t, err, ErrConfirmationRequired)
+
+	cmd = NewAppsCommand()
+	cmd.SetIn(strings.NewReader("n\n"))
+
+	out, err := runCommand(t, cmd, "restart", "-l", "tier=web")
+	require.NoError(t, err)
+	assert.Contains(t, out, "web-2-guid")
+	assert.Contains(t, out, "Cancelled")
+	assert.Empty(t, apps.restarted)
+}
+
+func TestAppsRestart_RejectsNameWithSelector(t *testing.T) {
+	_, err := runCommand(t, NewAppsCommand(), "restart", "api", "--selector", "tier=web")
+	require.ErrorIs(t, err, ErrBulkTargetConflict)
+
+	_, err = runCommand(t, NewAppsCommand(), "restart")
+	require.ErrorIs(t, err, ErrBulkTargetRequired)
+
+	_, err = runCommand(t, NewAppsCommand(), "restart", "--selector", "tier in (web")
+	require.ErrorIs(t, err, capi.ErrInvalidLabelSelector)
+}
+
+func TestAppsList_PassesSelector(t *testing.T) {
+	apps := &bulkAppsClient{}
+
+	withStubClient(t, &fakeClient{apps: apps})
+	withOutputFormat(t, OutputFormatJSON)
+
+	_, err := runCommand(t, NewAppsCommand(), "list", "--selector", "tier=web,env!=dev")
+	require.NoError(t, err)
+	assert.Contains(t, apps.query, "label_selector=tier%3Dweb%2Cenv%21%3Ddev")
+}
@@ -0,0 +1,229 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configAsCodeOptions are the flags shared by plan and apply.
+type configAsCodeOptions struct {
+	file        string
+	prune       bool
+	exitCode    bool
+	concurrency int
+	force       bool
+}
+
+// foundationPlanReport is the JSON and YAML form of a plan.
+type foundationPlanReport struct {
+	HasChanges bool                    `json:"has_changes" yaml:"has_changes"`
+	Summary    string                  `json:"summary"     yaml:"summary"`
+	Changes    []capi.FoundationChange `json:"changes"     yaml:"changes"`
+}
+
+// NewConfigAsCodeCommand creates the config-as-code command group.
+func NewConfigAsCodeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config-as-code",
+		Short: "Manage foundation configuration from a desired-state document",
+		Long: `Keep organizations, spaces, quotas, role assignments, security groups,
+isolation segment entitlements, feature flags and environment variable groups
+in line with a YAML document.
+
+plan shows what would change; apply makes the changes. Resources the document
+leaves out are never touched, and neither are the fields it leaves out, except
+with --prune: then organizations, spaces and quotas that carry the
+` + capi.FoundationManagedLabel + ` label of the document but are no longer in it
+are deleted. Declared resources that already exist are given the label.`,
+	}
+
+	cmd.AddCommand(newConfigAsCodePlanCommand())
+	cmd.AddCommand(newConfigAsCodeApplyCommand())
+
+	return cmd
+}
+
+func addConfigAsCodeFlags(cmd *cobra.Command, opts *configAsCodeOptions) {
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", `foundation document ("-" for stdin)`)
+	cmd.Flags().BoolVar(&opts.prune, "prune", false, "delete managed organizations, spaces and quotas left out of the document")
+
+	_ = cmd.MarkFlagRequired("file")
+}
+
+func newConfigAsCodePlanCommand() *cobra.Command {
+	opts := &configAsCodeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show the changes that would bring the foundation in line with a document",
+		Long: `Compare a foundation document with the current state and show the
+changes: "+" to create, "~" to update and "-" to delete. Nothing is changed.
+With --exit-code the command exits with status 2 when there are changes.`,
+		Example: `  # foundation.yaml
+  managed_by: platform-team
+  feature_flags:
+    diego_docker: true
+  organization_quotas:
+    - name: small
+      apps: {total_memory_in_mb: 10240}
+  organizations:
+    - name: payments
+      quota: small
+      users: {managers: [alice]}
+      spaces:
+        - name: prod
+          users: {developers: [bob, carol]}
+
+  capi config-as-code plan -f foundation.yaml
+
+  # Fail a CI job when the foundation drifted
+  capi config-as-code plan -f foundation.yaml --prune --exit-code`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runConfigAsCodePlan(cmd, opts)
+		},
+	}
+
+	addConfigAsCodeFlags(cmd, opts)
+	cmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "exit with status 2 when there are changes")
+
+	return cmd
+}
+
+func newConfigAsCodeApplyCommand() *cobra.Command {
+	opts := &configAsCodeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Bring the foundation in line with a document",
+		Long: `Show the plan, ask for confirmation unless --force is given, then make the
+changes with the batch executor: resources are created before what depends on
+them, and the dependents of a failed change are skipped.`,
+		Example: `  capi config-as-code apply -f foundation.yaml
+  capi config-as-code apply -f foundation.yaml --prune --force`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runConfigAsCodeApply(cmd, opts)
+		},
+	}
+
+	addConfigAsCodeFlags(cmd, opts)
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", defaultBatchConcurrency, "changes to make at once")
+	cmd.Flags().BoolVar(&opts.force, "force", false, "apply without asking for confirmation")
+
+	return cmd
+}
+
+// planFoundation reads the document, fetches the current state and plans.
+func planFoundation(cmd *cobra.Command, opts *configAsCodeOptions) (capi.Client, *capi.FoundationPlan, error) {
+	data, err := readFileOrStdin(cmd.InOrStdin(), opts.file, "foundation document")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	foundation, err := capi.ParseFoundation(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", opts.file, err)
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state, err := capi.FetchFoundationState(context.Background(), client, foundation, opts.prune)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{Prune: opts.prune})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, plan, nil
+}
+
+func runConfigAsCodePlan(cmd *cobra.Command, opts *configAsCodeOptions) error {
+	_, plan, err := planFoundation(cmd, opts)
+	if err != nil {
+		return err
+	}
+
+	err = renderFoundationPlan(plan)
+	if err != nil {
+		return err
+	}
+
+	if opts.exitCode && plan.HasChanges() {
+		return &ExitCodeError{Code: ExitCodeDifferences}
+	}
+
+	return nil
+}
+
+func runConfigAsCodeApply(cmd *cobra.Command, opts *configAsCodeOptions) error {
+	if opts.concurrency <= 0 {
+		return ErrInvalidBatchConcurrency
+	}
+
+	client, plan, err := planFoundation(cmd, opts)
+	if err != nil {
+		return err
+	}
+
+	output := viper.GetString("output")
+	if output != OutputFormatJSON && output != OutputFormatYAML {
+		err = plan.WriteText(os.Stdout, colorOutputEnabled())
+		if err != nil {
+			return err
+		}
+	}
+
+	if !plan.HasChanges() {
+		return nil
+	}
+
+	if !opts.force {
+		confirmed, err := confirmOnTerminal(cmd.InOrStdin(), "Apply these changes?", "apply "+plan.Summary())
+		if err != nil || !confirmed {
+			return err
+		}
+	}
+
+	operations := plan.Operations()
+
+	results, runErr := capi.NewBatchExecutor(client, opts.concurrency).Execute(context.Background(), operations)
+	if results == nil {
+		return runErr
+	}
+
+	err = renderBatchResults(operations, results, nil)
+	if err != nil {
+		return err
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+
+	return batchFailures(results)
+}
+
+func renderFoundationPlan(plan *capi.FoundationPlan) error {
+	report := foundationPlanReport{HasChanges: plan.HasChanges(), Summary: plan.Summary(), Changes: plan.Changes}
+
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		return encodeBatchJSON(report)
+	case OutputFormatYAML:
+		return encodeBatchYAML(report)
+	}
+
+	return plan.WriteText(os.Stdout, colorOutputEnabled())
+}
@@ -0,0 +1,126 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cacFeatureFlagsClient serves diego_docker, disabled, and records updates.
+type cacFeatureFlagsClient struct {
+	capi.FeatureFlagsClient
+
+	updated map[string]bool
+}
+
+func (s *cacFeatureFlagsClient) List(_ context.Context, _ *capi.QueryParams) (*capi.ListResponse[capi.FeatureFlag], error) {
+	return &capi.ListResponse[capi.FeatureFlag]{Resources: []capi.FeatureFlag{{Name: "diego_docker"}}}, nil
+}
+
+func (s *cacFeatureFlagsClient) Update(_ context.Context, name string, request *capi.FeatureFlagUpdateRequest) (*capi.FeatureFlag, error) {
+	if s.updated == nil {
+		s.updated = map[string]bool{}
+	}
+
+	s.updated[name] = request.Enabled
+
+	return &capi.FeatureFlag{Name: name, Enabled: request.Enabled}, nil
+}
+
+func writeFoundationFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "foundation.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestConfigAsCodePlan_ShowsChangesAndExitCode(t *testing.T) {
+	flags := &cacFeatureFlagsClient{}
+
+	withStubClient(t, &fakeClient{featureFlags: flags})
+	withOutputFormat(t, "table")
+
+	path := writeFoundationFile(t, "feature_flags: {diego_docker: true}\n")
+
+	out, err := runCommand(t, NewConfigAsCodeCommand(), "plan", "-f", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, `~ feature_flag "diego_docker"`)
+	assert.Contains(t, out, "~ enabled: false -> true")
+	assert.Contains(t, out, "Plan: 0 to create, 1 to update, 0 to delete.")
+
+	_, err = runCommand(t, NewConfigAsCodeCommand(), "plan", "-f", path, "--exit-code")
+
+	var exitErr *ExitCodeError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitCodeDifferences, exitErr.Code)
+	assert.Empty(t, flags.updated, "plan changes nothing")
+
+	path = writeFoundationFile(t, "feature_flags: {diego_docker: false}\n")
+
+	out, err = runCommand(t, NewConfigAsCodeCommand(), "plan", "-f", path, "--exit-code")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No changes.")
+}
+
+func TestConfigAsCodePlan_RejectsInvalidDocument(t *testing.T) {
+	path := writeFoundationFile(t, "organisations: []\n")
+
+	_, err := runCommand(t, NewConfigAsCodeCommand(), "plan", "-f", path)
+	require.ErrorIs(t, err, capi.ErrInvalidFoundation)
+
+	_, err = runCommand(t, NewConfigAsCodeCommand(), "plan")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"file" not set`)
+}
+
+func TestConfigAsCodeApply_AppliesPlan(t *testing.T) {
+	flags := &cacFeatureFlagsClient{}
+
+	withStubClient(t, &fakeClient{featureFlags: flags})
+	withOutputFormat(t, OutputFormatJSON)
+
+	path := writeFoundationFile(t, "feature_flags: {diego_docker: true}\n")
+
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	t.Cleanup(func() { _ = reader.Close() })
+
+	cmd := NewConfigAsCodeCommand()
+	cmd.SetIn(reader)
+
+	_, err = runCommand(t, cmd, "apply", "-f", path)
+	require.ErrorIs(t, err, ErrConfirmationRequired)
+	assert.Empty(t, flags.updated)
+
+	out, err := runCommand(t, NewConfigAsCodeCommand(), "apply", "-f", path, "--force")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"diego_docker": true}, flags.updated)
+	assert.Contains(t, out, `"id": "feature_flag:diego_docker"`)
+	assert.Contains(t, out, `"status": "ok"`)
+}
+
+func TestConfigAsCodeApply_ClientError(t *testing.T) {
+	errNoAPI := errors.New("no api")
+
+	withClientError(t, errNoAPI)
+
+	path := writeFoundationFile(t, "feature_flags: {diego_docker: true}\n")
+
+	cmd := NewConfigAsCodeCommand()
+	cmd.SetIn(strings.NewReader(""))
+
+	_, err := runCommand(t, cmd, "apply", "-f", path, "--force")
+	require.ErrorIs(t, err, errNoAPI)
+}
@@ -65,6 +65,7 @@ func newDomainsListCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&allPages, "all", false, "fetch all pages")
 	cmd.Flags().IntVar(&perPage, "per-page", constants.StandardPageSize, "results per page")
 	cmd.Flags().BoolVar(&internal, "internal", false, "filter by internal domains")
+	addSelectorFlag(cmd, "only domains matching this label selector")
 
 	return cmd
 }
@@ -90,6 +91,11 @@ func runDomainsList(filters *domainsListFilters) error {
 		return err
 	}
 
+	err = applySelectorFlag(filters.cmd, params)
+	if err != nil {
+		return err
+	}
+
 	domains, err := client.Domains().List(ctx, params)
 	if err != nil {
 		return fmt.Errorf("failed to list domains: %w", err)
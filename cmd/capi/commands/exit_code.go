@@ -0,0 +1,27 @@
+package commands
+
+import "fmt"
+
+// ExitCodeDifferences is the exit status used by commands that can signal
+// detected differences (drift) to scripts, following terraform's
+// -detailed-exitcode convention: 0 no changes, 1 error, 2 changes present.
+const ExitCodeDifferences = 2
+
+// ExitCodeError requests a specific process exit status from main. A nil Err
+// exits silently; otherwise Err is printed to stderr first.
+type ExitCodeError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitCodeError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+
+	return e.Err.Error()
+}
+
+func (e *ExitCodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// exportOptions are the flags of the export command.
+type exportOptions struct {
+	orgs      []string
+	allOrgs   bool
+	outputDir string
+}
+
+// exportOrganizationsDir holds one document per organization.
+const exportOrganizationsDir = "organizations"
+
+// NewExportCommand creates the export command.
+func NewExportCommand() *cobra.Command {
+	opts := &exportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the foundation configuration to YAML files",
+		Long: `Write the current configuration of a foundation to a directory of YAML
+files that can be checked into git:
+
+  foundation.yaml               feature flags, environment variable groups,
+                                organization quotas and security groups
+  organizations/ORG.yaml        an organization with its quota, isolation
+                                segments, roles, space quotas and spaces
+  domains.yaml                  domains and the organizations owning them
+  isolation-segments.yaml       isolation segments and their organizations
+  service-plan-visibility.yaml  who can see each service plan
+
+foundation.yaml and the organization files are config-as-code documents.
+Every list is sorted and nothing time-dependent is written, so exporting an
+unchanged foundation rewrites identical files. With --all-orgs the files of
+organizations that no longer exist are removed. Slashes in organization names
+become underscores; the export fails when two organizations would share a
+file.`,
+		Example: `  capi export --all-orgs -o foundation/
+  capi export --org payments --org shipping -o foundation/`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runExport(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&opts.orgs, "org", nil, "organization to export (repeatable)")
+	cmd.Flags().BoolVar(&opts.allOrgs, "all-orgs", false, "export every organization")
+	cmd.Flags().StringVarP(&opts.outputDir, "output-dir", "o", "", "directory to write the files into")
+
+	_ = cmd.MarkFlagRequired("output-dir")
+
+	return cmd
+}
+
+func runExport(cmd *cobra.Command, opts *exportOptions) error {
+	if opts.allOrgs == (len(opts.orgs) > 0) {
+		return ErrExportScopeRequired
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	export, err := capi.ExportFoundation(context.Background(), client, capi.FoundationExportOptions{Organizations: opts.orgs})
+	if err != nil {
+		return err
+	}
+
+	written, err := writeFoundationExport(export, opts.outputDir, opts.allOrgs)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range written {
+		_, _ = fmt.Fprintln(os.Stdout, path)
+	}
+
+	return nil
+}
+
+// writeFoundationExport writes the export into dir and returns the paths
+// written. With prune it removes the organization files it did not write.
+func writeFoundationExport(export *capi.FoundationExport, dir string, prune bool) ([]string, error) {
+	orgDir := filepath.Join(dir, exportOrganizationsDir)
+
+	err := checkExportFileNames(export.Foundation.Organizations)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(orgDir, constants.ConfigDirPerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", orgDir, err)
+	}
+
+	settings := *export.Foundation
+	settings.Organizations = nil
+
+	files := map[string]interface{}{
+		filepath.Join(dir, "foundation.yaml"): &settings,
+		filepath.Join(dir, "domains.yaml"): map[string]interface{}{
+			"domains": export.Domains,
+		},
+		filepath.Join(dir, "isolation-segments.yaml"): map[string]interface{}{
+			"isolation_segments": export.IsolationSegments,
+		},
+		filepath.Join(dir, "service-plan-visibility.yaml"): map[string]interface{}{
+			"service_plan_visibility": export.ServicePlanVisibility,
+		},
+	}
+
+	for _, org := range export.Foundation.Organizations {
+		path := filepath.Join(orgDir, manifestFileName(org.Name)+".yaml")
+		files[path] = &capi.Foundation{Organizations: []capi.FoundationOrganization{org}}
+	}
+
+	written := make([]string, 0, len(files))
+	for path := range files {
+		written = append(written, path)
+	}
+
+	sort.Strings(written)
+
+	for _, path := range written {
+		err = writeExportFile(path, files[path])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if prune {
+		err = pruneExportOrganizations(orgDir, files)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return written, nil
+}
+
+// checkExportFileNames fails when two organizations map to the same file,
+// like "a/b" and "a_b", or names differing only in case, which share a file
+// on case-insensitive file systems.
+func checkExportFileNames(orgs []capi.FoundationOrganization) error {
+	owners := make(map[string]string, len(orgs))
+
+	for _, org := range orgs {
+		file := strings.ToLower(manifestFileName(org.Name))
+		if owner, ok := owners[file]; ok {
+			return fmt.Errorf("%w: %q and %q", ErrExportFileConflict, owner, org.Name)
+		}
+
+		owners[file] = org.Name
+	}
+
+	return nil
+}
+
+func writeExportFile(path string, value interface{}) error {
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	err := encoder.Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	err = os.WriteFile(path, buf.Bytes(), constants.ConfigFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// pruneExportOrganizations removes the organization files of a previous
+// export that this one did not write.
+func pruneExportOrganizations(orgDir string, written map[string]interface{}) error {
+	entries, err := os.ReadDir(orgDir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory %s: %w", orgDir, err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(orgDir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+
+		if _, ok := written[path]; ok {
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
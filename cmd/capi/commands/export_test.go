@@ -0,0 +1,88 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport_RequiresOneScope(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := runCommand(t, NewExportCommand(), "-o", dir)
+	require.ErrorIs(t, err, ErrExportScopeRequired)
+
+	_, err = runCommand(t, NewExportCommand(), "--org", "payments", "--all-orgs", "-o", dir)
+	require.ErrorIs(t, err, ErrExportScopeRequired)
+
+	_, err = runCommand(t, NewExportCommand(), "--all-orgs")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"output-dir" not set`)
+}
+
+func TestWriteFoundationExport_WritesStableFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, exportOrganizationsDir, "retired.yaml")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0o750))
+	require.NoError(t, os.WriteFile(stale, []byte("organizations: []\n"), 0o600))
+
+	export := &capi.FoundationExport{
+		Foundation: &capi.Foundation{
+			FeatureFlags: map[string]bool{"diego_docker": true, "app_scaling": false},
+			Organizations: []capi.FoundationOrganization{{
+				Name:   "payments/eu",
+				Users:  capi.FoundationOrganizationUsers{Managers: []string{"alice"}},
+				Spaces: []capi.FoundationSpace{{Name: "prod"}},
+			}},
+		},
+		Domains: []capi.FoundationDomain{{Name: "apps.example.com"}},
+	}
+
+	written, err := writeFoundationExport(export, dir, true)
+	require.NoError(t, err)
+
+	orgFile := filepath.Join(dir, exportOrganizationsDir, "payments_eu.yaml")
+	assert.Equal(t, []string{
+		filepath.Join(dir, "domains.yaml"),
+		filepath.Join(dir, "foundation.yaml"),
+		filepath.Join(dir, "isolation-segments.yaml"),
+		orgFile,
+		filepath.Join(dir, "service-plan-visibility.yaml"),
+	}, written)
+	assert.NoFileExists(t, stale)
+
+	settings, err := os.ReadFile(filepath.Join(dir, "foundation.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "feature_flags:\n  app_scaling: false\n  diego_docker: true\n", string(settings))
+
+	data, err := os.ReadFile(orgFile)
+	require.NoError(t, err)
+
+	foundation, err := capi.ParseFoundation(data)
+	require.NoError(t, err, "organization files are config-as-code documents")
+	assert.Equal(t, export.Foundation.Organizations, foundation.Organizations)
+
+	domains, err := os.ReadFile(filepath.Join(dir, "domains.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "domains:\n  - name: apps.example.com\n", string(domains))
+}
+
+func TestWriteFoundationExport_RejectsSharedFiles(t *testing.T) {
+	for _, names := range [][]string{{"a/b", "a_b"}, {"Team", "team"}} {
+		dir := t.TempDir()
+		export := &capi.FoundationExport{Foundation: &capi.Foundation{
+			Organizations: []capi.FoundationOrganization{{Name: names[0]}, {Name: names[1]}},
+		}}
+
+		_, err := writeFoundationExport(export, dir, false)
+		require.ErrorIs(t, err, ErrExportFileConflict)
+		assert.Contains(t, err.Error(), names[0])
+		assert.NoDirExists(t, filepath.Join(dir, exportOrganizationsDir), "nothing is written")
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 
+	"golang.org/x/term"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
@@ -28,6 +31,10 @@ const (
 	// Output formats.
 	OutputFormatJSON = "json"
 	OutputFormatYAML = "yaml"
+	// OutputFormatNDJSON and OutputFormatCSV are the streaming formats of
+	// apps stats --watch.
+	OutputFormatNDJSON = "ndjson"
+	OutputFormatCSV    = "csv"
 
 	// TimeFormatDisplay is the layout used to render timestamps in CLI output.
 	TimeFormatDisplay = "2006-01-02 15:04:05"
@@ -117,6 +124,41 @@ var (
 	ErrNoUAAEndpoint                 = errors.New("no UAA endpoint configured")
 	ErrNotAuthenticated              = errors.New("not authenticated")
 	ErrNotImplemented                = errors.New("not implemented yet")
+	ErrManifestScopeRequired         = errors.New("an app GUID, --space or --org is required (or target a space)")
+	ErrManifestScopeConflict         = errors.New("an app GUID cannot be combined with --space or --org")
+	ErrManifestFileConflict          = errors.New("app manifest would overwrite services.yml; drop --include-services or use --output-file")
+	ErrInvalidDeploymentStrategy     = errors.New("invalid deployment strategy (expected rolling or canary)")
+	ErrInvalidCanaryStep             = errors.New("invalid canary step (expected INSTANCES or INSTANCES:WAIT_SECONDS)")
+	ErrDropletRevisionConflict       = errors.New("--droplet and --revision are mutually exclusive")
+	ErrCanaryStepsRequireCanary      = errors.New("--canary-steps requires --strategy canary")
+	ErrRollbackConfirmationRequired  = errors.New("not a terminal: pass --revision, --droplet or --force to choose the rollback target")
+	ErrRollbackTargetRequired        = errors.New("--revision, --droplet or --force is required with JSON or YAML output")
+	ErrRevisionNotForApp             = errors.New("revision does not belong to the application")
+	ErrInvalidMetricsRange           = errors.New("--range must be positive")
+	ErrInvalidMetricsStep            = errors.New("--step must be positive")
+	ErrMetricsAppRequired            = errors.New("an app name or GUID, or --query, is required")
+	ErrMetricsAppWithQuery           = errors.New("an app cannot be combined with --query; name it in a source_id matcher")
+	ErrLogsSpaceRequired             = errors.New("--space is required (or target a space)")
+	ErrSCPRemoteRequired             = errors.New("exactly one of SOURCE and DESTINATION must be APP:PATH")
+	ErrSCPDirectoryNeedsRecursive    = errors.New("source is a directory: pass --recursive")
+	ErrInvalidTopSort                = errors.New("--sort must be cpu, mem or name")
+	ErrInvalidTopInterval            = errors.New("--interval must be positive")
+	ErrInvalidStatsWatchOutput       = errors.New("--watch supports --output table, ndjson or csv")
+	ErrInvalidStatsWatchInterval     = errors.New("--interval must be positive")
+	ErrBatchOperationsFailed         = errors.New("batch operations failed")
+	ErrInvalidBatchConcurrency       = errors.New("--concurrency must be positive")
+	ErrMetadataTargetRequired        = errors.New("a resource name or GUID, or --selector, is required")
+	ErrInvalidMetadataArgument       = errors.New("invalid metadata argument")
+	ErrMetadataKeyExists             = errors.New("already set to another value; pass --overwrite to change it")
+	ErrMetadataResourceNotFound      = errors.New("resource not found")
+	ErrMetadataTargetAmbiguous       = errors.New("name matches more than one resource; pass a GUID or target a space")
+	ErrBulkTargetRequired            = errors.New("no target")
+	ErrBulkTargetConflict            = errors.New("both a name and --selector given")
+	ErrInvalidBulkParallel           = errors.New("--parallel must be positive")
+	ErrConfirmationRequired          = errors.New("no terminal to confirm on; pass --force")
+	ErrBulkActionFailed              = errors.New("bulk action failed")
+	ErrExportScopeRequired           = errors.New("exactly one of --org and --all-orgs is required")
+	ErrExportFileConflict            = errors.New("organizations would share an export file")
 )
 
 // AppLimitsConfig defines the interface for app limit configurations used by quota commands.
@@ -1999,3 +2041,33 @@ func resolveSpaceGUIDForServices(ctx context.Context, client capi.Client, spaceN
 
 	return "", ErrSpaceRequired
 }
+
+// colorOutputEnabled reports whether ANSI colors should be written to stdout:
+// only when stdout is a terminal and --no-color was not given.
+func colorOutputEnabled() bool {
+	if viper.GetBool("no-color") {
+		return false
+	}
+
+	return term.IsTerminal(int(os.Stdout.Fd())) //nolint:gosec // file descriptors fit in int
+}
+
+// readFileOrStdin reads path, or stdin when path is "-". what names the
+// content in errors.
+func readFileOrStdin(stdin io.Reader, path, what string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from stdin: %w", what, err)
+		}
+
+		return data, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+
+	return data, nil
+}
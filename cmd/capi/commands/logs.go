@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultLogsRefreshInterval = 30 * time.Second
+
+type logsOptions struct {
+	space       string
+	apps        []string
+	grep        string
+	sourceTypes []string
+	since       time.Duration
+	json        bool
+	refresh     time.Duration
+}
+
+// NewLogsCommand creates the command that tails the logs of many apps.
+func NewLogsCommand() *cobra.Command {
+	opts := &logsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Tail the logs of all applications in a space",
+		Long: `Follow the logs of every application in a space, or of those matching a
+label selector or --app, concurrently. Each line is prefixed with the
+application name and instance index. Applications created while tailing are
+picked up automatically.
+
+--source-type keeps lines whose source type starts with one of the given
+values, e.g. APP/PROC keeps APP/PROC/WEB and APP/PROC/WORKER. --since first
+replays that much history from Log Cache.`,
+		Example: `  # Tail every app in the targeted space
+  capi logs
+
+  # Router logs of the payments team's apps from the last ten minutes on
+  capi logs --space prod --selector team=payments --source-type RTR --since 10m
+
+  # Errors of two apps as JSON lines
+  capi logs --app api --app worker --grep 'ERROR|panic' --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runLogs(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.space, "space", "s", "", "space name or GUID (defaults to the targeted space)")
+	addSelectorFlag(cmd, "only apps matching this label selector")
+	cmd.Flags().StringArrayVar(&opts.apps, "app", nil, "only this app (repeatable)")
+	cmd.Flags().StringVar(&opts.grep, "grep", "", "only lines matching this regular expression")
+	cmd.Flags().StringSliceVar(&opts.sourceTypes, "source-type", nil, "only these source types, e.g. APP/PROC, RTR, STG")
+	cmd.Flags().DurationVar(&opts.since, "since", 0, "replay logs this old before following")
+	cmd.Flags().BoolVar(&opts.json, "json", false, "print one JSON log message per line")
+	cmd.Flags().DurationVar(&opts.refresh, "refresh-interval", defaultLogsRefreshInterval, "how often to look for new apps")
+
+	return cmd
+}
+
+func runLogs(cmd *cobra.Command, opts *logsOptions) error {
+	filter, err := buildLogsFilter(opts.grep, opts.sourceTypes)
+	if err != nil {
+		return err
+	}
+
+	selector, err := selectorFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	spaceGUID := viper.GetString("space_guid")
+
+	if opts.space != "" {
+		space, err := findSpaceByNameOrGUID(ctx, client, opts.space)
+		if err != nil {
+			return err
+		}
+
+		spaceGUID = space.GUID
+	}
+
+	if spaceGUID == "" {
+		return ErrLogsSpaceRequired
+	}
+
+	messages, err := capi.TailLogs(ctx, client, capi.LogTailOptions{
+		SpaceGUID:       spaceGUID,
+		LabelSelector:   selector.String(),
+		AppNames:        opts.apps,
+		Since:           opts.since,
+		RefreshInterval: opts.refresh,
+		Filter:          filter,
+		OnError: func(err error) {
+			_, _ = fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to tail logs: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	for message := range messages {
+		if opts.json {
+			err = encoder.Encode(message)
+			if err != nil {
+				return fmt.Errorf("failed to encode log message: %w", err)
+			}
+
+			continue
+		}
+
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s/%s [%s] %s %s\n", message.Timestamp.Format(logTimestampLayout),
+			message.AppName, message.SourceID, message.SourceType, message.MessageType, message.Message)
+	}
+
+	return nil
+}
+
+// buildLogsFilter combines --grep and --source-type.
+func buildLogsFilter(grep string, sourceTypes []string) (func(*capi.AppLogMessage) bool, error) {
+	var pattern *regexp.Regexp
+
+	if grep != "" {
+		var err error
+
+		pattern, err = regexp.Compile(grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep expression: %w", err)
+		}
+	}
+
+	return func(message *capi.AppLogMessage) bool {
+		if pattern != nil && !pattern.MatchString(message.Message) {
+			return false
+		}
+
+		if len(sourceTypes) == 0 {
+			return true
+		}
+
+		for _, sourceType := range sourceTypes {
+			if strings.HasPrefix(strings.ToUpper(message.SourceType), strings.ToUpper(sourceType)) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
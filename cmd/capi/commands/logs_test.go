@@ -0,0 +1,59 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsCommand(t *testing.T) {
+	t.Parallel()
+
+	cmd := commands.NewLogsCommand()
+	assert.Equal(t, "logs", cmd.Use)
+	require.Error(t, cmd.Args(cmd, []string{"extra"}))
+
+	for _, name := range []string{"space", "selector", "app", "grep", "source-type", "since", "json", "refresh-interval"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+
+	assert.NotNil(t, cmd.Flags().ShorthandLookup("l"))
+
+	require.NoError(t, cmd.Flags().Parse([]string{"--app", "api", "--app", "worker", "--source-type", "APP/PROC,RTR"}))
+
+	apps, err := cmd.Flags().GetStringArray("app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "worker"}, apps)
+
+	sourceTypes, err := cmd.Flags().GetStringSlice("source-type")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"APP/PROC", "RTR"}, sourceTypes)
+}
+
+func TestLogsCommandInvalidGrep(t *testing.T) {
+	t.Parallel()
+
+	cmd := commands.NewLogsCommand()
+	cmd.SetArgs([]string{"--grep", "("})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid --grep expression")
+}
+
+func TestLogsCommandInvalidSelector(t *testing.T) {
+	t.Parallel()
+
+	cmd := commands.NewLogsCommand()
+	cmd.SetArgs([]string{"-l", "tier in (web"})
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	err := cmd.Execute()
+	require.ErrorIs(t, err, capi.ErrInvalidLabelSelector)
+}
@@ -7,16 +7,20 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
-	"strconv"
+	"regexp"
+	"strings"
 
 	"github.com/fivetwenty-io/capi/v3/internal/constants"
 	"github.com/fivetwenty-io/capi/v3/pkg/capi"
-	"github.com/olekukonko/tablewriter"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
+// manifestServicesFile is the file --include-services writes next to the app
+// manifests of a space with --output-dir.
+const manifestServicesFile = "services.yml"
+
 // NewManifestsCommand creates the manifests command group.
 func NewManifestsCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -141,18 +145,39 @@ func newManifestsApplyCommand() *cobra.Command {
 	return cmd
 }
 
+// manifestGenerateOptions holds the flags of the manifests generate command.
+type manifestGenerateOptions struct {
+	outputFile      string
+	outputDir       string
+	space           string
+	org             string
+	stripSecrets    bool
+	secretPatterns  []string
+	includeServices bool
+	concurrency     int
+}
+
 func newManifestsGenerateCommand() *cobra.Command {
-	var outputFile string
+	var opts manifestGenerateOptions
 
 	cmd := &cobra.Command{
-		Use:   "generate APP_GUID",
-		Short: "Generate a manifest for an app",
-		Long:  "Generate a manifest file from an existing application's current configuration",
-		Args:  cobra.ExactArgs(1),
+		Use:   "generate [APP_GUID]",
+		Short: "Generate a manifest for an app, space or organization",
+		Long: `Generate a manifest file from an existing application's current configuration.
+
+With --space or --org, the manifest of every application in the space (or in
+every space of the organization) is collected concurrently and merged into one
+multi-application manifest per space, or written as one file per application
+with --output-dir (DIR/APP.yml for a space, DIR/SPACE/APP.yml for an org).
+
+--strip-secrets removes environment variables whose names look like
+credentials (password, secret, token, api_key, ...); --secret-pattern replaces
+the default pattern. --include-services adds the definitions of the space's
+service instances (name, offering, plan, tags; never credentials) as a
+separate YAML document, or as services.yml with --output-dir; an app named
+"services" cannot be combined with that.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			appGUID := args[0]
-
-			// Create client
 			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
 			if err != nil {
 				return fmt.Errorf("failed to create client: %w", err)
@@ -160,97 +185,354 @@ func newManifestsGenerateCommand() *cobra.Command {
 
 			ctx := context.Background()
 
-			// Generate manifest
-			manifestContent, err := client.Manifests().GenerateManifest(ctx, appGUID)
-			if err != nil {
-				return fmt.Errorf("failed to generate manifest: %w", err)
-			}
-
-			// Write to file or stdout
-			if outputFile != "" {
-				err = os.WriteFile(outputFile, manifestContent, constants.ConfigFilePerm)
-				if err != nil {
-					return fmt.Errorf("failed to write manifest file: %w", err)
+			if len(args) == 1 {
+				if opts.space != "" || opts.org != "" {
+					return ErrManifestScopeConflict
 				}
 
-				_, _ = fmt.Fprintf(os.Stdout, "Manifest written to %s\n", outputFile)
-			} else {
-				_, err := os.Stdout.Write(manifestContent)
-				if err != nil {
-					return fmt.Errorf("failed to write manifest to stdout: %w", err)
-				}
+				return generateAppManifest(ctx, client, args[0], opts.outputFile)
 			}
 
-			return nil
+			return generateScopedManifests(ctx, client, opts)
 		},
 	}
 
-	cmd.Flags().StringVarP(&outputFile, "output-file", "o", "", "Output file path (defaults to stdout)")
+	cmd.Flags().StringVarP(&opts.outputFile, "output-file", "o", "", "Output file path (defaults to stdout)")
+	cmd.Flags().StringVar(&opts.outputDir, "output-dir", "", "Write one manifest file per app into this directory")
+	cmd.Flags().StringVarP(&opts.space, "space", "s", "", "Generate manifests for every app in this space (name or GUID)")
+	cmd.Flags().StringVar(&opts.org, "org", "", "Generate manifests for every app in this organization (name or GUID)")
+	cmd.Flags().BoolVar(&opts.stripSecrets, "strip-secrets", false, "Remove environment variables with secret-like names")
+	cmd.Flags().StringArrayVar(&opts.secretPatterns, "secret-pattern", nil, "Regular expression for secret-like env var names (repeatable)")
+	cmd.Flags().BoolVar(&opts.includeServices, "include-services", false, "Include service instance definitions")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", constants.DefaultConcurrencyLimit, "Number of manifests to generate concurrently")
+
+	cmd.MarkFlagsMutuallyExclusive("space", "org")
+	cmd.MarkFlagsMutuallyExclusive("output-file", "output-dir")
 
 	return cmd
 }
 
-// handleDiffOutput handles the output formatting for manifest diff operations.
-func handleDiffOutput(diff *capi.ManifestDiff) error {
+// generateAppManifest writes the manifest of a single app to outputFile or stdout.
+func generateAppManifest(ctx context.Context, client capi.Client, appGUID, outputFile string) error {
+	manifestContent, err := client.Manifests().GenerateManifest(ctx, appGUID)
+	if err != nil {
+		return fmt.Errorf("failed to generate manifest: %w", err)
+	}
+
+	return writeManifestOutput(manifestContent, outputFile)
+}
+
+// writeManifestOutput writes content to outputFile, or to stdout when empty.
+func writeManifestOutput(content []byte, outputFile string) error {
+	if outputFile == "" {
+		_, err := os.Stdout.Write(content)
+		if err != nil {
+			return fmt.Errorf("failed to write manifest to stdout: %w", err)
+		}
+
+		return nil
+	}
+
+	err := os.WriteFile(outputFile, content, constants.ConfigFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest file: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Manifest written to %s\n", outputFile)
+
+	return nil
+}
+
+// generateScopedManifests generates the manifests of every app in a space or
+// organization and writes them merged or per app.
+func generateScopedManifests(ctx context.Context, client capi.Client, opts manifestGenerateOptions) error {
+	spaceGUIDs, err := resolveManifestScope(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	generateOpts := capi.ManifestGenerateOptions{
+		Concurrency:             opts.concurrency,
+		StripSecrets:            opts.stripSecrets || len(opts.secretPatterns) > 0,
+		IncludeServiceInstances: opts.includeServices,
+	}
+
+	for _, pattern := range opts.secretPatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --secret-pattern %q: %w", pattern, err)
+		}
+
+		generateOpts.SecretPatterns = append(generateOpts.SecretPatterns, compiled)
+	}
+
+	spaces, err := capi.GenerateSpaceManifests(ctx, client, spaceGUIDs, generateOpts)
+	if err != nil {
+		return fmt.Errorf("failed to generate manifests: %w", err)
+	}
+
+	for _, space := range spaces {
+		for _, app := range space.Apps {
+			if len(app.StrippedEnv) > 0 {
+				_, _ = fmt.Fprintf(os.Stderr, "Stripped %d secret-like environment variable(s) from %s: %s\n",
+					len(app.StrippedEnv), app.AppName, strings.Join(app.StrippedEnv, ", "))
+			}
+		}
+	}
+
+	if opts.outputDir != "" {
+		return writeManifestDirectory(spaces, opts.outputDir, opts.org != "")
+	}
+
+	content, err := mergeSpaceManifests(spaces)
+	if err != nil {
+		return err
+	}
+
+	return writeManifestOutput(content, opts.outputFile)
+}
+
+// resolveManifestScope returns the GUIDs of the spaces selected by --space,
+// --org, or the targeted space.
+func resolveManifestScope(ctx context.Context, client capi.Client, opts manifestGenerateOptions) ([]string, error) {
+	if opts.org != "" {
+		org, err := findOrganizationByNameOrGUID(ctx, client, opts.org)
+		if err != nil {
+			return nil, err
+		}
+
+		spaces, err := capi.CollectAllPages(ctx, nil, func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.Space], error) {
+			return client.Spaces().List(ctx, params, capi.WithSpaceOrganizationGUIDs(org.GUID))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list spaces in organization %s: %w", org.Name, err)
+		}
+
+		guids := make([]string, 0, len(spaces))
+		for _, space := range spaces {
+			guids = append(guids, space.GUID)
+		}
+
+		return guids, nil
+	}
+
+	if opts.space != "" {
+		space, err := findSpaceByNameOrGUID(ctx, client, opts.space)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{space.GUID}, nil
+	}
+
+	if targetedSpaceGUID := viper.GetString("space_guid"); targetedSpaceGUID != "" {
+		return []string{targetedSpaceGUID}, nil
+	}
+
+	return nil, ErrManifestScopeRequired
+}
+
+// mergeSpaceManifests renders one merged manifest per space, each followed by
+// its service instance document, as a single YAML stream.
+func mergeSpaceManifests(spaces []capi.SpaceManifest) ([]byte, error) {
+	var out strings.Builder
+
+	for i, space := range spaces {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+
+		if len(spaces) > 1 {
+			_, _ = fmt.Fprintf(&out, "# space: %s\n", space.SpaceName)
+		}
+
+		merged, err := space.Merged()
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge manifests for space %s: %w", space.SpaceName, err)
+		}
+
+		out.Write(merged)
+
+		if space.ServiceInstances != nil {
+			services, err := space.ServiceInstancesYAML()
+			if err != nil {
+				return nil, err
+			}
+
+			out.WriteString("---\n")
+			out.Write(services)
+		}
+	}
+
+	return []byte(out.String()), nil
+}
+
+// writeManifestDirectory writes one manifest per app into dir, nesting each
+// space in its own subdirectory when perSpace is set.
+func writeManifestDirectory(spaces []capi.SpaceManifest, dir string, perSpace bool) error {
+	err := checkManifestFileNames(spaces)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+
+	for _, space := range spaces {
+		spaceDir := dir
+		if perSpace {
+			spaceDir = filepath.Join(dir, manifestFileName(space.SpaceName))
+		}
+
+		err = os.MkdirAll(spaceDir, constants.ConfigDirPerm)
+		if err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", spaceDir, err)
+		}
+
+		for _, app := range space.Apps {
+			path := filepath.Join(spaceDir, manifestFileName(app.AppName)+".yml")
+
+			err = os.WriteFile(path, app.Manifest, constants.ConfigFilePerm)
+			if err != nil {
+				return fmt.Errorf("failed to write manifest file: %w", err)
+			}
+
+			written++
+		}
+
+		if space.ServiceInstances != nil {
+			services, err := space.ServiceInstancesYAML()
+			if err != nil {
+				return err
+			}
+
+			err = os.WriteFile(filepath.Join(spaceDir, manifestServicesFile), services, constants.ConfigFilePerm)
+			if err != nil {
+				return fmt.Errorf("failed to write services file: %w", err)
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintf(os.Stdout, "Wrote %d manifest(s) for %d space(s) to %s\n", written, len(spaces), dir)
+
+	return nil
+}
+
+// checkManifestFileNames rejects an app whose manifest would overwrite the
+// services.yml of its space, before any file is written.
+func checkManifestFileNames(spaces []capi.SpaceManifest) error {
+	for _, space := range spaces {
+		if space.ServiceInstances == nil {
+			continue
+		}
+
+		for _, app := range space.Apps {
+			if manifestFileName(app.AppName)+".yml" == manifestServicesFile {
+				return fmt.Errorf("%w: app %s in space %s", ErrManifestFileConflict, app.AppName, space.SpaceName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// manifestFileName turns a CF resource name into a safe file name.
+func manifestFileName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+
+		return r
+	}, name)
+
+	if safe == "" || safe == "." || safe == ".." {
+		return "_"
+	}
+
+	return safe
+}
+
+// manifestDiffOptions holds the flags of the manifests diff command.
+type manifestDiffOptions struct {
+	manifestPath string
+	contextLines int
+	exitCode     bool
+}
+
+// handleDiffOutput renders a manifest diff resolved against the manifest it
+// was computed from. When exitCode is set and the diff is not empty it returns
+// an ExitCodeError so scripts can gate on drift.
+func handleDiffOutput(manifest []byte, diff *capi.ManifestDiff, opts manifestDiffOptions) error {
+	renderer, err := capi.NewManifestDiffRenderer(manifest, diff)
+	if err != nil {
+		return fmt.Errorf("failed to render manifest diff: %w", err)
+	}
+
 	output := viper.GetString("output")
 	switch output {
 	case OutputFormatJSON:
 		encoder := json.NewEncoder(os.Stdout)
 		encoder.SetIndent("", "  ")
 
-		err := encoder.Encode(diff)
+		err := encoder.Encode(renderer.Report())
 		if err != nil {
 			return fmt.Errorf("failed to encode diff to JSON: %w", err)
 		}
-
-		return nil
 	case OutputFormatYAML:
 		encoder := yaml.NewEncoder(os.Stdout)
 
-		err := encoder.Encode(diff)
+		err := encoder.Encode(renderer.Report())
 		if err != nil {
 			return fmt.Errorf("failed to encode diff to YAML: %w", err)
 		}
-
-		return nil
 	default:
-		if len(diff.Diff) == 0 {
-			_, _ = os.Stdout.WriteString("No differences found\n")
-		} else {
-			_, _ = fmt.Fprintf(os.Stdout, "Found %d difference(s):\n\n", len(diff.Diff))
-
-			table := tablewriter.NewWriter(os.Stdout)
-			table.Header("Operation", "Path", "Current Value", "New Value")
-
-			for _, entry := range diff.Diff {
-				wasStr := formatValue(entry.Was)
-				valueStr := formatValue(entry.Value)
-				_ = table.Append(entry.Op, entry.Path, wasStr, valueStr)
-			}
+		renderOpts := capi.ManifestDiffRenderOptions{
+			ContextLines: opts.contextLines,
+			Color:        colorOutputEnabled(),
+			FromLabel:    "current",
+			ToLabel:      opts.manifestPath,
+		}
 
-			err := table.Render()
+		err := renderer.WriteSummary(os.Stdout, renderOpts)
+		if err != nil {
+			return fmt.Errorf("failed to write diff summary: %w", err)
+		}
+
+		if renderer.HasChanges() {
+			_, _ = os.Stdout.WriteString("\n")
+
+			err = renderer.WriteUnified(os.Stdout, renderOpts)
 			if err != nil {
-				return fmt.Errorf("failed to render table: %w", err)
+				return fmt.Errorf("failed to write unified diff: %w", err)
 			}
 		}
 	}
 
+	if opts.exitCode && renderer.HasChanges() {
+		return &ExitCodeError{Code: ExitCodeDifferences}
+	}
+
 	return nil
 }
 
 func newManifestsDiffCommand() *cobra.Command {
-	var manifestPath string
+	var opts manifestDiffOptions
 
 	cmd := &cobra.Command{
 		Use:   "diff SPACE_GUID",
 		Short: "Create a diff between current and proposed manifest",
-		Long:  "Compare the current state of applications in a space with a proposed manifest to see what would change",
-		Args:  cobra.ExactArgs(1),
+		Long: `Compare the current state of applications in a space with a proposed manifest to see what would change.
+
+Each change is listed against the application, process, route, sidecar or
+service it touches, followed by a unified diff of the manifest. With
+--exit-code the command exits with status 2 when differences exist (0 when
+none, 1 on error), so CI pipelines can gate on drift.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			spaceGUID := args[0]
 
 			// Read manifest file
-			manifestContent, err := readManifestFileBytes(manifestPath)
+			manifestContent, err := readManifestFileBytes(opts.manifestPath)
 			if err != nil {
 				return err
 			}
@@ -270,11 +552,13 @@ func newManifestsDiffCommand() *cobra.Command {
 			}
 
 			// Handle output
-			return handleDiffOutput(diff)
+			return handleDiffOutput(manifestContent, diff, opts)
 		},
 	}
 
-	cmd.Flags().StringVarP(&manifestPath, "file", "f", "manifest.yml", "Path to manifest file")
+	cmd.Flags().StringVarP(&opts.manifestPath, "file", "f", "manifest.yml", "Path to manifest file")
+	cmd.Flags().IntVarP(&opts.contextLines, "context", "U", capi.DefaultManifestDiffContextLines, "Lines of context around each change")
+	cmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "Exit with status 2 when differences are found")
 
 	return cmd
 }
@@ -311,32 +595,3 @@ func handleJobCompletion(completedJob interface{}) {
 		}
 	}
 }
-
-// formatValue formats a value for display in the diff table.
-func formatValue(v interface{}) string {
-	if v == nil {
-		return "-"
-	}
-
-	switch val := v.(type) {
-	case string:
-		return val
-	case bool:
-		return strconv.FormatBool(val)
-	case float64:
-		// Check if it's an integer
-		if val == float64(int(val)) {
-			return strconv.Itoa(int(val))
-		}
-
-		return fmt.Sprintf("%g", val)
-	default:
-		// For complex types, use JSON
-		b, err := json.Marshal(val)
-		if err != nil {
-			return fmt.Sprintf("%v", val)
-		}
-
-		return string(b)
-	}
-}
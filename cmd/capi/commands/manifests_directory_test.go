@@ -0,0 +1,35 @@
+//nolint:testpackage // writeManifestDirectory is unexported
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteManifestDirectory_ServicesFileConflict(t *testing.T) {
+	dir := t.TempDir()
+	spaces := []capi.SpaceManifest{{
+		SpaceName:        "dev",
+		Apps:             []capi.GeneratedAppManifest{{AppName: "api", Manifest: []byte("api\n")}, {AppName: "services", Manifest: []byte("services\n")}},
+		ServiceInstances: []capi.ManifestServiceInstance{},
+	}}
+
+	err := writeManifestDirectory(spaces, dir, false)
+	require.ErrorIs(t, err, ErrManifestFileConflict)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing is written")
+
+	spaces[0].ServiceInstances = nil
+	require.NoError(t, writeManifestDirectory(spaces, dir, false))
+
+	manifest, err := os.ReadFile(filepath.Join(dir, "services.yml"))
+	require.NoError(t, err)
+	assert.Equal(t, "services\n", string(manifest))
+}
@@ -0,0 +1,55 @@
+package commands_test
+
+import (
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/cmd/capi/commands"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestsDiffCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewManifestsCommand()
+	cmd := findSubcommand(root, "diff")
+	require.NotNil(t, cmd)
+	assert.Equal(t, "diff SPACE_GUID", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+
+	contextFlag := cmd.Flags().Lookup("context")
+	require.NotNil(t, contextFlag)
+	assert.Equal(t, "U", contextFlag.Shorthand)
+	assert.Equal(t, "3", contextFlag.DefValue)
+
+	exitCodeFlag := cmd.Flags().Lookup("exit-code")
+	require.NotNil(t, exitCodeFlag)
+	assert.Equal(t, "false", exitCodeFlag.DefValue)
+}
+
+func TestExitCodeError(t *testing.T) {
+	t.Parallel()
+
+	silent := &commands.ExitCodeError{Code: commands.ExitCodeDifferences}
+	assert.Equal(t, "exit status 2", silent.Error())
+	assert.NoError(t, silent.Unwrap())
+
+	wrapped := &commands.ExitCodeError{Code: 3, Err: commands.ErrNotImplemented}
+	assert.ErrorIs(t, wrapped, commands.ErrNotImplemented)
+	assert.Equal(t, commands.ErrNotImplemented.Error(), wrapped.Error())
+}
+
+func TestManifestsGenerateCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewManifestsCommand()
+	cmd := findSubcommand(root, "generate")
+	require.NotNil(t, cmd)
+	assert.Equal(t, "generate [APP_GUID]", cmd.Use)
+	require.NoError(t, cmd.Args(cmd, []string{}))
+	require.Error(t, cmd.Args(cmd, []string{"a", "b"}))
+
+	for _, name := range []string{"space", "org", "output-file", "output-dir", "strip-secrets", "secret-pattern", "include-services", "concurrency"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag %s", name)
+	}
+}
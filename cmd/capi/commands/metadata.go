@@ -0,0 +1,484 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	metadataLabels      = "label"
+	metadataAnnotations = "annotation"
+	metadataPageSize    = 5000
+)
+
+// metadataResourceAliases are the short resource type names the CLI accepts
+// besides the singular and collection names of capi.MetadataResourceTypes.
+var metadataResourceAliases = map[string]string{
+	"org":  "organization",
+	"orgs": "organizations",
+}
+
+// metadataNameScopes lists the collections whose resources can be named
+// instead of given by GUID, with the targeted scope a name is looked up in:
+// app and service instance names are unique per space, space names per
+// organization.
+var metadataNameScopes = map[string]string{
+	"apps":               "space",
+	"service_instances":  "space",
+	"spaces":             "organization",
+	"organizations":      "",
+	"domains":            "",
+	"buildpacks":         "",
+	"stacks":             "",
+	"isolation_segments": "",
+	"service_brokers":    "",
+	"service_offerings":  "",
+	"service_plans":      "",
+}
+
+type metadataCommandOptions struct {
+	kind      string
+	unset     bool
+	overwrite bool
+	list      bool
+	selector  *capi.LabelSelector
+}
+
+// metadataTarget is a resource a metadata command changes.
+type metadataTarget struct {
+	Type     string         `json:"type"           yaml:"type"`
+	GUID     string         `json:"guid"           yaml:"guid"`
+	Name     string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Metadata *capi.Metadata `json:"metadata"       yaml:"metadata"`
+}
+
+func (t metadataTarget) String() string {
+	if t.Name != "" {
+		return t.Type + "/" + t.Name
+	}
+
+	return t.Type + "/" + t.GUID
+}
+
+// NewLabelCommand creates the label command.
+func NewLabelCommand() *cobra.Command {
+	return newMetadataCommand(&metadataCommandOptions{kind: metadataLabels}, &cobra.Command{
+		Use:   "label TYPE [NAME_OR_GUID] KEY=VALUE... [KEY-]...",
+		Short: "Add, change or remove labels of any resource",
+		Long: `Set labels with KEY=VALUE and remove them with KEY-, on one resource given by
+name or GUID, or on every resource of TYPE matching --selector.
+
+Changing the value of an existing label requires --overwrite. TYPE is a
+resource type such as app, space, org, route, domain, buildpack, stack or
+service_instance.`,
+		Example: `  capi label app api team=payments tier=backend
+  capi label app api tier=frontend --overwrite
+  capi label space dev owner-
+  capi label app --selector team=payments env=prod
+  capi label route 3b3e1f0a-... --list`,
+	})
+}
+
+// NewUnlabelCommand creates the unlabel command.
+func NewUnlabelCommand() *cobra.Command {
+	return newMetadataCommand(&metadataCommandOptions{kind: metadataLabels, unset: true}, &cobra.Command{
+		Use:   "unlabel TYPE [NAME_OR_GUID] KEY...",
+		Short: "Remove labels from any resource",
+		Long: `Remove labels from one resource given by name or GUID, or from every resource
+of TYPE matching --selector. Keys the resource does not have are ignored.`,
+		Example: `  capi unlabel app api tier
+  capi unlabel app --selector team=payments env`,
+	})
+}
+
+// NewAnnotateCommand creates the annotate command.
+func NewAnnotateCommand() *cobra.Command {
+	return newMetadataCommand(&metadataCommandOptions{kind: metadataAnnotations}, &cobra.Command{
+		Use:   "annotate TYPE [NAME_OR_GUID] KEY=VALUE... [KEY-]...",
+		Short: "Add, change or remove annotations of any resource",
+		Long: `Set annotations with KEY=VALUE and remove them with KEY-, on one resource given
+by name or GUID, or on every resource of TYPE matching --selector.
+
+Changing the value of an existing annotation requires --overwrite.`,
+		Example: `  capi annotate app api contact=payments@example.com
+  capi annotate space dev description="Shared sandbox" --overwrite
+  capi annotate app api contact-
+  capi annotate app --selector team=payments runbook=https://wiki.example.com/payments`,
+	})
+}
+
+func newMetadataCommand(opts *metadataCommandOptions, cmd *cobra.Command) *cobra.Command {
+	cmd.Args = cobra.MinimumNArgs(1)
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		return runMetadataCommand(cmd, args, opts)
+	}
+
+	addSelectorFlag(cmd, "act on every resource of TYPE matching this label selector")
+
+	if !opts.unset {
+		cmd.Flags().BoolVar(&opts.overwrite, "overwrite", false, fmt.Sprintf("allow changing the value of existing %ss", opts.kind))
+		cmd.Flags().BoolVar(&opts.list, "list", false, fmt.Sprintf("show the %ss of the resources instead of changing them", opts.kind))
+	}
+
+	return cmd
+}
+
+func runMetadataCommand(cmd *cobra.Command, args []string, opts *metadataCommandOptions) error {
+	resourceType, err := metadataResourceType(args[0])
+	if err != nil {
+		return err
+	}
+
+	opts.selector, err = selectorFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	name, specs := "", args[1:]
+	if opts.selector.IsEmpty() {
+		if len(specs) == 0 {
+			return ErrMetadataTargetRequired
+		}
+
+		name, specs = specs[0], specs[1:]
+	}
+
+	set, remove, err := parseMetadataChanges(specs, opts)
+	if err != nil {
+		return err
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	targets, err := findMetadataTargets(ctx, client.Metadata(), resourceType, name, opts.selector)
+	if err != nil {
+		return err
+	}
+
+	if opts.list {
+		return renderMetadataList(targets, opts.kind)
+	}
+
+	return patchMetadataTargets(ctx, client.Metadata(), targets, set, remove, opts)
+}
+
+func metadataResourceType(resourceType string) (string, error) {
+	if alias, ok := metadataResourceAliases[resourceType]; ok {
+		resourceType = alias
+	}
+
+	path, err := capi.MetadataResourcePath(resourceType)
+	if err != nil {
+		return "", fmt.Errorf("%w (expected one of %s)", err, strings.Join(capi.MetadataResourceTypes(), ", "))
+	}
+
+	return path, nil
+}
+
+// parseMetadataChanges splits KEY=VALUE and KEY- arguments into keys to set
+// and keys to remove; for unlabel every argument is a key to remove.
+func parseMetadataChanges(specs []string, opts *metadataCommandOptions) (map[string]string, []string, error) {
+	set := map[string]string{}
+
+	var remove []string
+
+	for _, spec := range specs {
+		key, value, isSet := strings.Cut(spec, "=")
+
+		switch {
+		case opts.unset && !isSet && key != "":
+			remove = append(remove, strings.TrimSuffix(key, "-"))
+		case opts.unset:
+			return nil, nil, fmt.Errorf("%w: %q (expected KEY)", ErrInvalidMetadataArgument, spec)
+		case isSet && key != "":
+			set[key] = value
+		case strings.HasSuffix(key, "-") && len(key) > 1:
+			remove = append(remove, strings.TrimSuffix(key, "-"))
+		default:
+			return nil, nil, fmt.Errorf("%w: %q (expected KEY=VALUE or KEY-)", ErrInvalidMetadataArgument, spec)
+		}
+	}
+
+	err := validateMetadataChanges(set, opts.kind)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(set) == 0 && len(remove) == 0 && !opts.list {
+		return nil, nil, fmt.Errorf("%w: no %ss to change", ErrInvalidMetadataArgument, opts.kind)
+	}
+
+	return set, remove, nil
+}
+
+// validateMetadataChanges checks the keys to set, and the values of labels;
+// annotation values are free-form.
+func validateMetadataChanges(set map[string]string, kind string) error {
+	for key, value := range set {
+		err := capi.ValidateLabelKey(key)
+		if err == nil && kind == metadataLabels {
+			err = capi.ValidateLabelValue(value)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidMetadataArgument, err)
+		}
+	}
+
+	return nil
+}
+
+// findMetadataTargets returns the resources a command applies to: those
+// matching the selector, or the one named by nameOrGUID. A name shared by
+// several resources in scope is an error rather than a guess.
+func findMetadataTargets(ctx context.Context, client capi.MetadataClient, resourceType, nameOrGUID string, selector *capi.LabelSelector) ([]metadataTarget, error) {
+	if !selector.IsEmpty() {
+		return listMetadataTargets(ctx, client, resourceType, capi.NewQueryParams().WithSelector(selector))
+	}
+
+	metadata, err := client.Get(ctx, resourceType, nameOrGUID)
+	if err == nil {
+		return []metadataTarget{{Type: resourceType, GUID: nameOrGUID, Metadata: metadata}}, nil
+	}
+
+	if !capi.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get %s %s: %w", resourceType, nameOrGUID, err)
+	}
+
+	scope, nameable := metadataNameScopes[resourceType]
+	if !nameable {
+		return nil, fmt.Errorf("%s %s: %w", resourceType, nameOrGUID, err)
+	}
+
+	params := capi.NewQueryParams().WithFilter("names", nameOrGUID)
+
+	switch scope {
+	case "space":
+		if spaceGUID := viper.GetString("space_guid"); spaceGUID != "" {
+			params.WithFilter("space_guids", spaceGUID)
+		}
+	case "organization":
+		if orgGUID := viper.GetString("organization_guid"); orgGUID != "" {
+			params.WithFilter("organization_guids", orgGUID)
+		}
+	}
+
+	targets, err := listMetadataTargets(ctx, client, resourceType, params)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(targets) {
+	case 0:
+		return nil, fmt.Errorf("%w: %s %s", ErrMetadataResourceNotFound, resourceType, nameOrGUID)
+	case 1:
+		return targets, nil
+	default:
+		guids := make([]string, 0, len(targets))
+		for _, target := range targets {
+			guids = append(guids, target.GUID)
+		}
+
+		return nil, fmt.Errorf("%w: %s %s matches %s", ErrMetadataTargetAmbiguous, resourceType, nameOrGUID, strings.Join(guids, ", "))
+	}
+}
+
+func listMetadataTargets(ctx context.Context, client capi.MetadataClient, resourceType string, params *capi.QueryParams) ([]metadataTarget, error) {
+	params.WithPerPage(metadataPageSize)
+
+	var targets []metadataTarget
+
+	for page := 1; ; page++ {
+		params.WithPage(page)
+
+		list, err := client.List(ctx, resourceType, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", resourceType, err)
+		}
+
+		for _, resource := range list.Resources {
+			targets = append(targets, metadataTarget{
+				Type:     resourceType,
+				GUID:     resource.GUID,
+				Name:     resource.Name,
+				Metadata: resource.Metadata,
+			})
+		}
+
+		if list.Pagination.Next == nil || list.Pagination.Next.Href == "" {
+			return targets, nil
+		}
+	}
+}
+
+// existingMetadataConflicts returns the keys of set target already has with
+// another value.
+func existingMetadataConflicts(target metadataTarget, kind string, set map[string]string) []string {
+	if target.Metadata == nil {
+		return nil
+	}
+
+	existing := target.Metadata.Labels
+	if kind == metadataAnnotations {
+		existing = target.Metadata.Annotations
+	}
+
+	var conflicts []string
+
+	for key, value := range set {
+		if current, ok := existing[key]; ok && current != value {
+			conflicts = append(conflicts, fmt.Sprintf("%s=%s", key, current))
+		}
+	}
+
+	sort.Strings(conflicts)
+
+	return conflicts
+}
+
+// patchMetadataTargets applies the changes to every target, carrying on
+// past failures, and reports each changed resource.
+func patchMetadataTargets(ctx context.Context, client capi.MetadataClient, targets []metadataTarget, set map[string]string, remove []string, opts *metadataCommandOptions) error {
+	patch := capi.MetadataPatch{SetLabels: set, RemoveLabels: remove}
+	if opts.kind == metadataAnnotations {
+		patch = capi.MetadataPatch{SetAnnotations: set, RemoveAnnotations: remove}
+	}
+
+	var (
+		changed []metadataTarget
+		errs    []error
+	)
+
+	for _, target := range targets {
+		conflicts := existingMetadataConflicts(target, opts.kind, set)
+		if len(conflicts) > 0 && !opts.overwrite {
+			errs = append(errs, fmt.Errorf("%s: %w: %s", target, ErrMetadataKeyExists, strings.Join(conflicts, ", ")))
+
+			continue
+		}
+
+		metadata, err := client.Patch(ctx, target.Type, target.GUID, patch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target, err))
+
+			continue
+		}
+
+		target.Metadata = metadata
+		changed = append(changed, target)
+	}
+
+	err := renderMetadataChanges(changed, opts)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		_, _ = fmt.Fprintf(os.Stdout, "No resources match %s\n", opts.selector)
+	}
+
+	return errors.Join(errs...)
+}
+
+func renderMetadataChanges(changed []metadataTarget, opts *metadataCommandOptions) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		return outputMetadataTargetsJSON(changed)
+	case OutputFormatYAML:
+		return outputMetadataTargetsYAML(changed)
+	}
+
+	verb := "labeled"
+
+	switch {
+	case opts.kind == metadataAnnotations:
+		verb = "annotated"
+	case opts.unset:
+		verb = "unlabeled"
+	}
+
+	for _, target := range changed {
+		_, _ = fmt.Fprintf(os.Stdout, "%s %s\n", target, verb)
+	}
+
+	return nil
+}
+
+func renderMetadataList(targets []metadataTarget, kind string) error {
+	switch viper.GetString("output") {
+	case OutputFormatJSON:
+		return outputMetadataTargetsJSON(targets)
+	case OutputFormatYAML:
+		return outputMetadataTargetsYAML(targets)
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Resource", "Key", "Value")
+
+	for _, target := range targets {
+		values := map[string]string{}
+		if target.Metadata != nil {
+			values = target.Metadata.Labels
+			if kind == metadataAnnotations {
+				values = target.Metadata.Annotations
+			}
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			_ = table.Append(target.String(), key, values[key])
+		}
+	}
+
+	err := table.Render()
+	if err != nil {
+		return fmt.Errorf("failed to render %ss: %w", kind, err)
+	}
+
+	return nil
+}
+
+func outputMetadataTargetsJSON(targets []metadataTarget) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(targets)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata as JSON: %w", err)
+	}
+
+	return nil
+}
+
+func outputMetadataTargetsYAML(targets []metadataTarget) error {
+	encoder := yaml.NewEncoder(os.Stdout)
+
+	err := encoder.Encode(targets)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata as YAML: %w", err)
+	}
+
+	return nil
+}
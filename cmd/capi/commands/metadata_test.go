@@ -0,0 +1,176 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errMetadataForbidden = errors.New("forbidden")
+
+// memoryMetadataClient keeps the metadata of apps in memory, keyed by GUID,
+// with their names for lookups by name, evaluating label selectors the way
+// the API does.
+type memoryMetadataClient struct {
+	names    map[string]string
+	metadata map[string]*capi.Metadata
+	patched  []string
+	getErr   error
+}
+
+func (m *memoryMetadataClient) Get(_ context.Context, _, guid string) (*capi.Metadata, error) {
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+
+	metadata, ok := m.metadata[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	return metadata, nil
+}
+
+func (m *memoryMetadataClient) Patch(_ context.Context, _, guid string, patch capi.MetadataPatch) (*capi.Metadata, error) {
+	m.patched = append(m.patched, guid)
+	metadata := m.metadata[guid]
+
+	for key, value := range patch.SetLabels {
+		metadata.Labels[key] = value
+	}
+
+	for _, key := range patch.RemoveLabels {
+		delete(metadata.Labels, key)
+	}
+
+	for key, value := range patch.SetAnnotations {
+		metadata.Annotations[key] = value
+	}
+
+	return metadata, nil
+}
+
+func (m *memoryMetadataClient) List(_ context.Context, _ string, params *capi.QueryParams) (*capi.ListResponse[capi.MetadataResource], error) {
+	list := &capi.ListResponse[capi.MetadataResource]{}
+	names := params.Filters["names"]
+
+	for _, guid := range []string{"api-guid", "worker-guid", "web-guid"} {
+		metadata := m.metadata[guid]
+
+		switch {
+		case len(names) > 0 && names[0] != m.names[guid]:
+			continue
+		case params.LabelSelector != "" && !metadataMatches(metadata, params.LabelSelector):
+			continue
+		}
+
+		list.Resources = append(list.Resources, capi.MetadataResource{
+			Resource: capi.Resource{GUID: guid},
+			Name:     m.names[guid],
+			Metadata: metadata,
+		})
+	}
+
+	return list, nil
+}
+
+func metadataMatches(metadata *capi.Metadata, selector string) bool {
+	parsed, err := capi.ParseLabelSelector(selector)
+
+	return err == nil && parsed.Matches(metadata)
+}
+
+func newMemoryMetadataClient() *memoryMetadataClient {
+	return &memoryMetadataClient{
+		names: map[string]string{"api-guid": "api", "worker-guid": "worker", "web-guid": "web"},
+		metadata: map[string]*capi.Metadata{
+			"api-guid":    {Labels: map[string]string{"team": "payments", "tier": "backend"}, Annotations: map[string]string{}},
+			"worker-guid": {Labels: map[string]string{"team": "payments"}, Annotations: map[string]string{}},
+			"web-guid":    {Labels: map[string]string{"team": "storefront"}, Annotations: map[string]string{}},
+		},
+	}
+}
+
+func TestLabel_ByNameRequiresOverwrite(t *testing.T) {
+	metadata := newMemoryMetadataClient()
+
+	withStubClient(t, &fakeClient{metadata: metadata})
+	withOutputFormat(t, "table")
+
+	_, err := runCommand(t, NewLabelCommand(), "app", "api", "tier=frontend", "env=prod")
+	require.ErrorIs(t, err, ErrMetadataKeyExists)
+	assert.Contains(t, err.Error(), "tier=backend")
+	assert.Empty(t, metadata.patched)
+
+	out, err := runCommand(t, NewLabelCommand(), "app", "api", "tier=frontend", "env=prod", "team-", "--overwrite")
+	require.NoError(t, err)
+	assert.Equal(t, "apps/api labeled\n", out)
+	assert.Equal(t, map[string]string{"tier": "frontend", "env": "prod"}, metadata.metadata["api-guid"].Labels)
+}
+
+func TestLabel_SelectorUpdatesEveryMatch(t *testing.T) {
+	metadata := newMemoryMetadataClient()
+
+	withStubClient(t, &fakeClient{metadata: metadata})
+	withOutputFormat(t, "table")
+
+	out, err := runCommand(t, NewAnnotateCommand(), "apps", "--selector", "team=payments", "contact=payments@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "apps/api annotated\napps/worker annotated\n", out)
+	assert.Equal(t, []string{"api-guid", "worker-guid"}, metadata.patched)
+
+	out, err = runCommand(t, NewUnlabelCommand(), "app", "worker-guid", "team")
+	require.NoError(t, err)
+	assert.Equal(t, "apps/worker-guid unlabeled\n", out)
+	assert.Empty(t, metadata.metadata["worker-guid"].Labels)
+}
+
+func TestLabel_RejectsInvalidArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want error
+	}{
+		{name: "no target", args: []string{"app"}, want: ErrMetadataTargetRequired},
+		{name: "no changes", args: []string{"app", "api"}, want: ErrInvalidMetadataArgument},
+		{name: "bare key", args: []string{"app", "api", "team"}, want: ErrInvalidMetadataArgument},
+		{name: "unknown type", args: []string{"security_group", "sg", "a=b"}, want: capi.ErrUnsupportedMetadataResource},
+		{name: "invalid value", args: []string{"app", "api", "team=pay ments"}, want: capi.ErrInvalidLabelValue},
+		{name: "invalid selector", args: []string{"app", "-l", "team in (a", "a=b"}, want: capi.ErrInvalidLabelSelector},
+	}
+
+	for _, tt := range tests {
+		_, err := runCommand(t, NewLabelCommand(), tt.args...)
+		require.ErrorIs(t, err, tt.want, tt.name)
+	}
+}
+
+func TestLabel_AmbiguousNameIsRejected(t *testing.T) {
+	metadata := newMemoryMetadataClient()
+	metadata.names["web-guid"] = "api"
+
+	withStubClient(t, &fakeClient{metadata: metadata})
+	withOutputFormat(t, "table")
+
+	_, err := runCommand(t, NewLabelCommand(), "app", "api", "env=prod")
+	require.ErrorIs(t, err, ErrMetadataTargetAmbiguous)
+	assert.Contains(t, err.Error(), "api-guid, web-guid")
+	assert.Empty(t, metadata.patched)
+}
+
+func TestLabel_LookupErrorIsReturned(t *testing.T) {
+	metadata := newMemoryMetadataClient()
+	metadata.getErr = errMetadataForbidden
+
+	withStubClient(t, &fakeClient{metadata: metadata})
+	withOutputFormat(t, "table")
+
+	_, err := runCommand(t, NewLabelCommand(), "app", "api", "env=prod")
+	require.ErrorIs(t, err, errMetadataForbidden)
+	assert.Empty(t, metadata.patched)
+}
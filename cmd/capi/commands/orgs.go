@@ -54,6 +54,7 @@ func newOrgsListCommand() *cobra.Command {
 
 	cmd.Flags().BoolVar(&allPages, "all", false, "fetch all pages")
 	cmd.Flags().IntVar(&perPage, "per-page", constants.StandardPageSize, "results per page")
+	addSelectorFlag(cmd, "only organizations matching this label selector")
 
 	return cmd
 }
@@ -69,6 +70,11 @@ func runOrgsListCommand(cmd *cobra.Command, allPages bool, perPage int) error {
 	params := capi.NewQueryParams()
 	params.PerPage = perPage
 
+	err = applySelectorFlag(cmd, params)
+	if err != nil {
+		return err
+	}
+
 	orgs, err := client.Organizations().List(ctx, params)
 	if err != nil {
 		return fmt.Errorf("failed to list organizations: %w", err)
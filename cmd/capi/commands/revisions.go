@@ -27,6 +27,7 @@ func NewRevisionsCommand() *cobra.Command {
 	cmd.AddCommand(newRevisionsGetCommand())
 	cmd.AddCommand(newRevisionsUpdateCommand())
 	cmd.AddCommand(newRevisionsGetEnvCommand())
+	cmd.AddCommand(newRevisionsDiffCommand())
 
 	return cmd
 }
@@ -306,3 +307,139 @@ func renderRevisionEnvVarsTable(revisionGUID string, envVarsList []EnvVar) error
 
 	return nil
 }
+
+func newRevisionsDiffCommand() *cobra.Command {
+	var showValues bool
+
+	cmd := &cobra.Command{
+		Use:   "diff APP_NAME_OR_GUID REV_A REV_B",
+		Short: "Compare two revisions of an application",
+		Long: `Show what changed between two revisions of an application: droplet and
+buildpacks, process commands, sidecars, environment variables and metadata.
+
+Revisions are given by version number or GUID. Environment variable values are
+masked unless --show-values is set.`,
+		Example: `  # Compare revision 3 with revision 5
+  capi revisions diff my-app 3 5
+
+  # Include environment variable values
+  capi revisions diff my-app 3 5 --show-values`,
+		Args: cobra.ExactArgs(3), //nolint:mnd // app and two revisions
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+
+			appGUID, _, err := resolveApp(ctx, client, args[0])
+			if err != nil {
+				return err
+			}
+
+			fromGUID, err := resolveRevisionGUID(ctx, client, appGUID, args[1])
+			if err != nil {
+				return err
+			}
+
+			toGUID, err := resolveRevisionGUID(ctx, client, appGUID, args[2])
+			if err != nil {
+				return err
+			}
+
+			diff, err := capi.DiffRevisions(ctx, client, fromGUID, toGUID, capi.RevisionDiffOptions{ShowValues: showValues})
+			if err != nil {
+				return fmt.Errorf("failed to compare revisions: %w", err)
+			}
+
+			return renderRevisionDiff(diff)
+		},
+	}
+
+	cmd.Flags().BoolVar(&showValues, "show-values", false, "show environment variable values instead of masking them")
+
+	return cmd
+}
+
+// resolveRevisionGUID accepts a revision version number or GUID. Version
+// numbers are looked up among the app's revisions; GUIDs must belong to the
+// app.
+func resolveRevisionGUID(ctx context.Context, client capi.Client, appGUID, versionOrGUID string) (string, error) {
+	version, err := strconv.Atoi(versionOrGUID)
+	if err != nil {
+		revision, err := client.Revisions().Get(ctx, versionOrGUID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get revision %s: %w", versionOrGUID, err)
+		}
+
+		data := revision.Relationships.App.Data
+		if data == nil || data.GUID != appGUID {
+			return "", fmt.Errorf("%w: %s", ErrRevisionNotForApp, versionOrGUID)
+		}
+
+		return revision.GUID, nil
+	}
+
+	revisions, err := capi.AppRevisions(ctx, client, appGUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to list revisions: %w", err)
+	}
+
+	for _, revision := range revisions {
+		if revision.Version == version {
+			return revision.GUID, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: version %d", capi.ErrRevisionNotFound, version)
+}
+
+func renderRevisionDiff(diff *capi.RevisionDiff) error {
+	output := viper.GetString("output")
+	switch output {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(diff)
+		if err != nil {
+			return fmt.Errorf("failed to encode revision diff as JSON: %w", err)
+		}
+
+		return nil
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+
+		err := encoder.Encode(diff)
+		if err != nil {
+			return fmt.Errorf("failed to encode revision diff as YAML: %w", err)
+		}
+
+		return nil
+	default:
+		return renderRevisionDiffTable(diff)
+	}
+}
+
+func renderRevisionDiffTable(diff *capi.RevisionDiff) error {
+	_, _ = fmt.Fprintf(os.Stdout, "Comparing revision %d (%s) to revision %d (%s)\n\n",
+		diff.From.Version, diff.From.GUID, diff.To.Version, diff.To.GUID)
+
+	if !diff.HasChanges() {
+		_, _ = os.Stdout.WriteString("No differences found\n")
+
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Section", "Key", "Change", "From", "To")
+
+	for _, change := range diff.Changes {
+		_ = table.Append(change.Section, change.Key, string(change.Kind), change.From, change.To)
+	}
+
+	_ = table.Render()
+
+	return nil
+}
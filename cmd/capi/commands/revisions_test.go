@@ -18,7 +18,7 @@ func TestNewRevisionsCommand(t *testing.T) {
 
 	// Check subcommands are added
 	subcommands := cmd.Commands()
-	assert.Len(t, subcommands, 3)
+	assert.Len(t, subcommands, 4)
 
 	commandNames := make([]string, 0, len(subcommands))
 	for _, subcmd := range subcommands {
@@ -28,6 +28,22 @@ func TestNewRevisionsCommand(t *testing.T) {
 	assert.Contains(t, commandNames, "get")
 	assert.Contains(t, commandNames, "update")
 	assert.Contains(t, commandNames, "get-env")
+	assert.Contains(t, commandNames, "diff")
+}
+
+func TestRevisionsDiffCommand(t *testing.T) {
+	t.Parallel()
+
+	root := commands.NewRevisionsCommand()
+	cmd := findSubcommand(root, "diff")
+	assert.Equal(t, "diff APP_NAME_OR_GUID REV_A REV_B", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+	assert.Error(t, cmd.Args(cmd, []string{"app", "3"}))
+	assert.NoError(t, cmd.Args(cmd, []string{"app", "3", "5"}))
+
+	showValues := cmd.Flags().Lookup("show-values")
+	assert.NotNil(t, showValues)
+	assert.Equal(t, "false", showValues.DefValue)
 }
 
 func TestRevisionsGetCommand(t *testing.T) {
@@ -53,6 +53,7 @@ func newRoutesListCommand() *cobra.Command {
 
 	cmd.Flags().StringVarP(&spaceName, "space", "s", "", "filter by space name")
 	cmd.Flags().StringVarP(&domainName, "domain", "d", "", "filter by domain name")
+	addSelectorFlag(cmd, "only routes matching this label selector")
 
 	return cmd
 }
@@ -78,6 +79,11 @@ func runRoutesList(cmd *cobra.Command, spaceName, domainName string) error {
 		return err
 	}
 
+	err = applySelectorFlag(cmd, params)
+	if err != nil {
+		return err
+	}
+
 	routes, err := client.Routes().List(ctx, params)
 	if err != nil {
 		return fmt.Errorf("failed to list routes: %w", err)
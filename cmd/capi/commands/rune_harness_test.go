@@ -34,6 +34,10 @@ type fakeClient struct {
 
 	sidecars          capi.SidecarsClient
 	isolationSegments capi.IsolationSegmentsClient
+	apps              capi.AppsClient
+	processes         capi.ProcessesClient
+	metadata          capi.MetadataClient
+	featureFlags      capi.FeatureFlagsClient
 }
 
 func (f *fakeClient) Sidecars() capi.SidecarsClient {
@@ -52,6 +56,38 @@ func (f *fakeClient) IsolationSegments() capi.IsolationSegmentsClient {
 	return f.isolationSegments
 }
 
+func (f *fakeClient) Apps() capi.AppsClient {
+	if f.apps == nil {
+		panic("fakeClient.Apps() called but no stub was configured")
+	}
+
+	return f.apps
+}
+
+func (f *fakeClient) Processes() capi.ProcessesClient {
+	if f.processes == nil {
+		panic("fakeClient.Processes() called but no stub was configured")
+	}
+
+	return f.processes
+}
+
+func (f *fakeClient) Metadata() capi.MetadataClient {
+	if f.metadata == nil {
+		panic("fakeClient.Metadata() called but no stub was configured")
+	}
+
+	return f.metadata
+}
+
+func (f *fakeClient) FeatureFlags() capi.FeatureFlagsClient {
+	if f.featureFlags == nil {
+		panic("fakeClient.FeatureFlags() called but no stub was configured")
+	}
+
+	return f.featureFlags
+}
+
 // withStubClient installs client as the value returned by CreateClientWithAPI
 // for the duration of the test.
 func withStubClient(t *testing.T, client capi.Client) {
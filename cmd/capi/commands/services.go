@@ -274,6 +274,11 @@ func newServicesListCommand() *cobra.Command {
 				return err
 			}
 
+			err = applySelectorFlag(cmd, params)
+			if err != nil {
+				return err
+			}
+
 			// Fetch services (all pages if requested)
 			services, pagination, err := fetchAllServicePages(ctx, client, params, config.allPages)
 			if err != nil {
@@ -288,6 +293,7 @@ func newServicesListCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&config.spaceName, "space", "s", "", "filter by space name")
 	cmd.Flags().BoolVar(&config.allPages, "all", false, "fetch all pages")
 	cmd.Flags().IntVar(&config.perPage, "per-page", constants.StandardPageSize, "results per page")
+	addSelectorFlag(cmd, "only service instances matching this label selector")
 
 	return cmd
 }
@@ -1016,23 +1022,78 @@ func handleServiceUpdateResult(result interface{}, serviceType, serviceName stri
 }
 
 func newServicesDeleteCommand() *cobra.Command {
-	var force bool
+	opts := &bulkOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "delete SERVICE_NAME_OR_GUID",
+		Use:   "delete [SERVICE_NAME_OR_GUID]",
 		Short: "Delete a service instance",
-		Long:  "Delete a service instance",
-		Args:  cobra.ExactArgs(1),
+		Long: `Delete a service instance, or every service instance matching --selector.
+
+With --selector the matching service instances are listed first, and nothing
+is deleted until you confirm or pass --force.`,
+		Example: `  capi services delete my-db
+  capi services delete --selector env=dev --space sandbox --parallel 3`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return executeServiceDelete(cmd, args[0], force)
+			selector, err := bulkSelector(cmd, args, "service instance")
+			if err != nil {
+				return err
+			}
+
+			if selector != nil {
+				return executeServicesBulkDelete(cmd, selector, opts)
+			}
+
+			return executeServiceDelete(cmd, args[0], opts.force)
 		},
 	}
 
-	cmd.Flags().BoolVarP(&force, "force", "f", false, "force deletion without confirmation")
+	addBulkFlags(cmd, opts, "service instance")
+	cmd.Flags().BoolVarP(&opts.force, "force", "f", false, "force deletion without confirmation")
 
 	return cmd
 }
 
+// executeServicesBulkDelete deletes every service instance matching selector.
+func executeServicesBulkDelete(cmd *cobra.Command, selector *capi.LabelSelector, opts *bulkOptions) error {
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	spaceGUID, err := bulkSpaceGUID(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	params := capi.NewQueryParams().WithSelector(selector)
+	if spaceGUID != "" {
+		params.WithFilter("space_guids", spaceGUID)
+	}
+
+	instances, err := capi.CollectAllPages(ctx, params, func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.ServiceInstance], error) {
+		return client.ServiceInstances().List(ctx, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list service instances: %w", err)
+	}
+
+	targets := make([]bulkTarget, 0, len(instances))
+	for _, instance := range instances {
+		targets = append(targets, bulkTarget{GUID: instance.GUID, Name: instance.Name})
+	}
+
+	return runBulkAction(cmd, selector, targets, bulkAction{
+		verb: "delete",
+		noun: "service instance",
+		run: func(ctx context.Context, target bulkTarget) (*capi.Job, error) {
+			return client.ServiceInstances().Delete(ctx, target.GUID)
+		},
+	}, opts)
+}
+
 // executeServiceDelete handles the service deletion logic.
 func executeServiceDelete(cmd *cobra.Command, nameOrGUID string, force bool) error {
 	if !force {
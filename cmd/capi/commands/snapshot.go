@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// snapshotCreateOptions are the flags of the snapshot create command.
+type snapshotCreateOptions struct {
+	outputFile  string
+	concurrency int
+}
+
+// snapshotDiffOptions are the flags of the snapshot diff command.
+type snapshotDiffOptions struct {
+	exitCode bool
+}
+
+const (
+	// snapshotTimeLayout names default snapshot files after the UTC time
+	// they were taken at, so they sort chronologically.
+	snapshotTimeLayout = "20060102T150405Z"
+
+	snapshotDiffExactArgs = 2
+)
+
+// NewSnapshotCommand creates the snapshot command group.
+func NewSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record and compare foundation inventories",
+		Long: `Record the apps, routes, service instances, service bindings and roles of a
+foundation in a timestamped JSON file, and compare two such files to see
+what changed in between.`,
+	}
+
+	cmd.AddCommand(newSnapshotCreateCommand())
+	cmd.AddCommand(newSnapshotDiffCommand())
+
+	return cmd
+}
+
+func newSnapshotCreateCommand() *cobra.Command {
+	opts := &snapshotCreateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Save an inventory of the foundation",
+		Long: `Save an inventory of every resource visible to the current user:
+
+  apps               state, lifecycle, stack, current droplet and the
+                     instances and memory of each process
+  routes             URL, space and the apps they map to
+  service instances  type, offering and plan
+  service bindings   app bindings and service keys
+  roles              user and organization or space
+
+Resources are given by name. The file defaults to snapshot-TIMESTAMP.json
+in the current directory.`,
+		Example: `  capi snapshot create
+  capi snapshot create -o before-upgrade.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runSnapshotCreate(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.outputFile, "output-file", "o", "", "file to write the snapshot to")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", constants.DefaultConcurrencyLimit, "current droplet lookups to run in parallel")
+
+	return cmd
+}
+
+func runSnapshotCreate(cmd *cobra.Command, opts *snapshotCreateOptions) error {
+	apiFlag := cmd.Flag("api").Value.String()
+
+	client, err := CreateClientWithAPI(apiFlag)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := capi.CreateSnapshot(context.Background(), client, capi.SnapshotOptions{Concurrency: opts.concurrency})
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	apiConfig, err := getAPIConfigByFlag(apiFlag)
+	if err == nil {
+		snapshot.API = apiConfig.Endpoint
+	}
+
+	path := opts.outputFile
+	if path == "" {
+		path = "snapshot-" + snapshot.CreatedAt.Format(snapshotTimeLayout) + ".json"
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), constants.ConfigFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Snapshot of %d apps, %d routes, %d service instances, %d service bindings and %d roles written to %s\n",
+		len(snapshot.Apps), len(snapshot.Routes), len(snapshot.ServiceInstances), len(snapshot.ServiceBindings), len(snapshot.Roles), path)
+
+	return nil
+}
+
+func newSnapshotDiffCommand() *cobra.Command {
+	opts := &snapshotDiffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff FROM_FILE TO_FILE",
+		Short: "Compare two snapshots",
+		Long: `Report the resources added, removed or changed between two snapshots.
+
+Apps and service instances are matched by organization, space and name,
+routes by URL, bindings by service instance and app or key name, and roles
+by type, user and organization or space. A changed guid means the resource
+was deleted and recreated under the same name.
+
+With --exit-code the command exits with status 2 when there are differences.`,
+		Example: `  capi snapshot diff snapshot-20261001T000000Z.json snapshot-20261018T000000Z.json
+  capi snapshot diff before.json after.json --output json`,
+		Args: cobra.ExactArgs(snapshotDiffExactArgs),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return runSnapshotDiff(args[0], args[1], opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.exitCode, "exit-code", false, "exit with status 2 when there are differences")
+
+	return cmd
+}
+
+func runSnapshotDiff(fromFile, toFile string, opts *snapshotDiffOptions) error {
+	from, err := readSnapshotFile(fromFile)
+	if err != nil {
+		return err
+	}
+
+	to, err := readSnapshotFile(toFile)
+	if err != nil {
+		return err
+	}
+
+	diff := capi.DiffSnapshots(from, to)
+
+	err = renderSnapshotDiff(diff)
+	if err != nil {
+		return err
+	}
+
+	if opts.exitCode && diff.HasChanges() {
+		return &ExitCodeError{Code: ExitCodeDifferences}
+	}
+
+	return nil
+}
+
+func readSnapshotFile(path string) (*capi.Snapshot, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is a user-supplied snapshot file
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	snapshot, err := capi.ParseSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return snapshot, nil
+}
+
+func renderSnapshotDiff(diff *capi.SnapshotDiff) error {
+	output := viper.GetString("output")
+	switch output {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(diff)
+		if err != nil {
+			return fmt.Errorf("failed to encode snapshot diff as JSON: %w", err)
+		}
+
+		return nil
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+
+		err := encoder.Encode(diff)
+		if err != nil {
+			return fmt.Errorf("failed to encode snapshot diff as YAML: %w", err)
+		}
+
+		return nil
+	default:
+		return renderSnapshotDiffTable(diff)
+	}
+}
+
+func renderSnapshotDiffTable(diff *capi.SnapshotDiff) error {
+	_, _ = fmt.Fprintf(os.Stdout, "Comparing snapshot of %s to snapshot of %s\n\n",
+		diff.From.CreatedAt.Format(time.RFC3339), diff.To.CreatedAt.Format(time.RFC3339))
+
+	if !diff.HasChanges() {
+		_, _ = os.Stdout.WriteString("No differences found\n")
+
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header("Section", "Key", "Change", "Field", "From", "To")
+
+	for _, change := range diff.Changes {
+		_ = table.Append(change.Section, change.Key, string(change.Kind), change.Field, change.From, change.To)
+	}
+
+	_ = table.Render()
+
+	_, _ = fmt.Fprintf(os.Stdout, "\n%d added, %d removed, %d changed\n",
+		diff.Count(capi.SnapshotChangeAdded), diff.Count(capi.SnapshotChangeRemoved), diff.Count(capi.SnapshotChangeChanged))
+
+	return nil
+}
@@ -0,0 +1,91 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSnapshotFile(t *testing.T, name string, snapshot *capi.Snapshot) string {
+	t.Helper()
+
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	return path
+}
+
+func TestSnapshotDiff_ReportsChangesAndExitCode(t *testing.T) {
+	withOutputFormat(t, "table")
+
+	before := writeSnapshotFile(t, "before.json", &capi.Snapshot{
+		Version: capi.SnapshotVersion,
+		Apps: []capi.SnapshotApp{{
+			Space: "payments/prod", Name: "api", State: "STARTED",
+			Processes: map[string]capi.SnapshotProcess{"web": {Instances: 2, MemoryInMB: 512}},
+		}},
+	})
+	after := writeSnapshotFile(t, "after.json", &capi.Snapshot{
+		Version: capi.SnapshotVersion,
+		Apps: []capi.SnapshotApp{{
+			Space: "payments/prod", Name: "api", State: "STOPPED",
+			Processes: map[string]capi.SnapshotProcess{"web": {Instances: 2, MemoryInMB: 512}},
+		}},
+		Routes: []capi.SnapshotRoute{{URL: "api.example.com", Space: "payments/prod"}},
+	})
+
+	out, err := runCommand(t, NewSnapshotCommand(), "diff", before, after)
+	require.NoError(t, err)
+	assert.Contains(t, out, "payments/prod/api")
+	assert.Contains(t, out, "STOPPED")
+	assert.Contains(t, out, "api.example.com")
+	assert.Contains(t, out, "1 added, 0 removed, 1 changed")
+
+	_, err = runCommand(t, NewSnapshotCommand(), "diff", before, after, "--exit-code")
+
+	var exitErr *ExitCodeError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, ExitCodeDifferences, exitErr.Code)
+
+	out, err = runCommand(t, NewSnapshotCommand(), "diff", before, before, "--exit-code")
+	require.NoError(t, err)
+	assert.Contains(t, out, "No differences found")
+}
+
+func TestSnapshotDiff_JSONOutput(t *testing.T) {
+	withOutputFormat(t, OutputFormatJSON)
+
+	before := writeSnapshotFile(t, "before.json", &capi.Snapshot{Version: capi.SnapshotVersion})
+	after := writeSnapshotFile(t, "after.json", &capi.Snapshot{
+		Version: capi.SnapshotVersion,
+		Roles:   []capi.SnapshotRole{{Type: "space_developer", User: "alice", Space: "payments/prod"}},
+	})
+
+	out, err := runCommand(t, NewSnapshotCommand(), "diff", before, after)
+	require.NoError(t, err)
+
+	var diff capi.SnapshotDiff
+	require.NoError(t, json.Unmarshal([]byte(out), &diff))
+	assert.Equal(t, []capi.SnapshotChange{{
+		Section: capi.SnapshotSectionRoles,
+		Key:     "space_developer alice in payments/prod",
+		Kind:    capi.SnapshotChangeAdded,
+	}}, diff.Changes)
+}
+
+func TestSnapshotDiff_RejectsInvalidSnapshot(t *testing.T) {
+	valid := writeSnapshotFile(t, "valid.json", &capi.Snapshot{Version: capi.SnapshotVersion})
+	invalid := writeSnapshotFile(t, "invalid.json", &capi.Snapshot{Version: capi.SnapshotVersion + 1})
+
+	_, err := runCommand(t, NewSnapshotCommand(), "diff", valid, invalid)
+	require.ErrorIs(t, err, capi.ErrInvalidSnapshot)
+}
@@ -63,6 +63,7 @@ func NewSpacesCommand() *cobra.Command {
 	cmd.AddCommand(newSpacesListAppsCommand())
 	cmd.AddCommand(newSpacesListServicesCommand())
 	cmd.AddCommand(newSpacesApplyManifestCommand())
+	cmd.AddCommand(newSpacesCloneCommand())
 
 	return cmd
 }
@@ -199,6 +200,11 @@ func newSpacesListCommand() *cobra.Command {
 				params.WithFilter("organization_guids", orgGUID)
 			}
 
+			err = applySelectorFlag(cmd, params)
+			if err != nil {
+				return err
+			}
+
 			spaces, err := client.Spaces().List(ctx, params)
 			if err != nil {
 				return fmt.Errorf("failed to list spaces: %w", err)
@@ -229,6 +235,7 @@ func newSpacesListCommand() *cobra.Command {
 	cmd.Flags().StringVarP(&orgName, "org", "o", "", "filter by organization name")
 	cmd.Flags().BoolVar(&allPages, "all", false, "fetch all pages")
 	cmd.Flags().IntVar(&perPage, "per-page", constants.StandardPageSize, "results per page")
+	addSelectorFlag(cmd, "only spaces matching this label selector")
 
 	return cmd
 }
@@ -0,0 +1,170 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// spacesCloneOptions are the flags of the spaces clone command.
+type spacesCloneOptions struct {
+	org     string
+	destOrg string
+	destAPI string
+	domain  string
+}
+
+const spacesCloneExactArgs = 2
+
+func newSpacesCloneCommand() *cobra.Command {
+	opts := &spacesCloneOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "clone SOURCE_SPACE DEST_SPACE",
+		Short: "Create a copy of a space",
+		Long: `Create DEST_SPACE as a copy of SOURCE_SPACE, with:
+
+  the same space quota, security group bindings and roles
+  user-provided service instances, with their credentials
+  new managed service instances of the same offering and plan
+  apps with their environment variables, process scale and current droplet
+  the apps' service bindings and routes, on --domain when given
+
+Apps that were started are started once everything is in place. Service
+keys and managed service instance parameters are not copied. Routes that
+already exist on the destination, as all do on the same foundation without
+--domain, are skipped.
+
+The source space is read from --api, by default the current API. With
+--dest-api the copy is created on another foundation: droplets are
+downloaded and uploaded, and users, security groups, service plans, domains,
+buildpacks and stacks are matched by name. Whatever cannot be matched is
+skipped and reported. Each droplet is held in memory while it is
+transferred, so the command needs as much memory as the largest droplet.`,
+		Example: `  capi spaces clone dev feature-x --domain feature-x.apps.example.com
+  capi spaces clone dev dev --org payments --dest-api staging
+  capi spaces clone dev dev --api staging --dest-api production`,
+		Args: cobra.ExactArgs(spacesCloneExactArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSpacesClone(cmd.Flag("api").Value.String(), args[0], args[1], opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.org, "org", "", "organization of the source space (default: the targeted organization)")
+	cmd.Flags().StringVar(&opts.destOrg, "dest-org", "", "organization to create the copy in (default: --org)")
+	cmd.Flags().StringVar(&opts.destAPI, "dest-api", "", "API to create the copy on (default: --api)")
+	cmd.Flags().StringVar(&opts.domain, "domain", "", "domain to create the copied routes on")
+
+	return cmd
+}
+
+func runSpacesClone(api, sourceSpace, destSpace string, opts *spacesCloneOptions) error {
+	org := opts.org
+	if org == "" {
+		current, err := getAPIConfigByFlag(api)
+		if err == nil {
+			org = current.Organization
+		}
+	}
+
+	if org == "" {
+		return ErrOrganizationRequired
+	}
+
+	source, target, err := spacesCloneClients(api, opts.destAPI)
+	if err != nil {
+		return err
+	}
+
+	cloneOpts := capi.SpaceCloneOptions{
+		SourceOrganization: org,
+		SourceSpace:        sourceSpace,
+		TargetOrganization: opts.destOrg,
+		TargetSpace:        destSpace,
+		Domain:             opts.domain,
+	}
+
+	output := viper.GetString("output")
+	if output != OutputFormatJSON && output != OutputFormatYAML {
+		cloneOpts.OnStep = printSpaceCloneStep
+	}
+
+	result, err := capi.CloneSpace(context.Background(), source, target, cloneOpts)
+	if err != nil {
+		return fmt.Errorf("failed to clone space %s: %w", sourceSpace, err)
+	}
+
+	return renderSpaceCloneResult(result, output)
+}
+
+// spacesCloneClients returns the client of the source API and the one to
+// create the copy with, which is the same client unless destAPI names
+// another foundation.
+func spacesCloneClients(api, destAPI string) (capi.Client, capi.Client, error) {
+	source, err := CreateClientWithAPI(api)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if destAPI == "" || destAPI == api {
+		return source, source, nil
+	}
+
+	current, currentErr := getAPIConfigByFlag(api)
+	requested, requestedErr := getAPIConfigByFlag(destAPI)
+
+	if currentErr == nil && requestedErr == nil && current.Endpoint == requested.Endpoint {
+		return source, source, nil
+	}
+
+	target, err := CreateClientWithAPI(destAPI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return source, target, nil
+}
+
+func printSpaceCloneStep(step capi.SpaceCloneStep) {
+	line := fmt.Sprintf("%-8s %s %s", step.Action, step.Resource, step.Name)
+	if step.Detail != "" {
+		line += " (" + step.Detail + ")"
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout, line)
+}
+
+func renderSpaceCloneResult(result *capi.SpaceCloneResult, output string) error {
+	switch output {
+	case OutputFormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode clone result as JSON: %w", err)
+		}
+
+		return nil
+	case OutputFormatYAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+
+		err := encoder.Encode(result)
+		if err != nil {
+			return fmt.Errorf("failed to encode clone result as YAML: %w", err)
+		}
+
+		return nil
+	default:
+		_, _ = fmt.Fprintf(os.Stdout, "\nSpace '%s' created with GUID %s (%d skipped)\n",
+			result.Space.Name, result.Space.GUID, len(result.Skipped()))
+
+		return nil
+	}
+}
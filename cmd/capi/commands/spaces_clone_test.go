@@ -0,0 +1,104 @@
+//nolint:testpackage // RunE behavior tests need the unexported newClientFunc seam
+package commands
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cloneLookupClient serves the organization lookup and the space lookups
+// CloneSpace makes before creating anything.
+type cloneLookupClient struct {
+	fakeClient
+
+	orgs   *cloneLookupOrgs
+	spaces *cloneLookupSpaces
+}
+
+func (c *cloneLookupClient) Organizations() capi.OrganizationsClient { return c.orgs }
+func (c *cloneLookupClient) Spaces() capi.SpacesClient               { return c.spaces }
+
+type cloneLookupOrgs struct {
+	capi.OrganizationsClient
+
+	names []string
+}
+
+func (o *cloneLookupOrgs) List(_ context.Context, _ *capi.QueryParams, opts ...capi.OrganizationListOption) (*capi.ListResponse[capi.Organization], error) {
+	name := capi.ApplyQueryOptions(url.Values{}, opts).Get("names")
+	o.names = append(o.names, name)
+
+	return &capi.ListResponse[capi.Organization]{Resources: []capi.Organization{{Resource: capi.Resource{GUID: "org-" + name}, Name: name}}}, nil
+}
+
+// cloneLookupSpaces knows the named spaces.
+type cloneLookupSpaces struct {
+	capi.SpacesClient
+
+	existing map[string]bool
+}
+
+func (s *cloneLookupSpaces) List(_ context.Context, _ *capi.QueryParams, opts ...capi.SpaceListOption) (*capi.ListResponse[capi.Space], error) {
+	name := capi.ApplyQueryOptions(url.Values{}, opts).Get("names")
+	if !s.existing[name] {
+		return &capi.ListResponse[capi.Space]{}, nil
+	}
+
+	return &capi.ListResponse[capi.Space]{Resources: []capi.Space{{Resource: capi.Resource{GUID: "space-" + name}, Name: name}}}, nil
+}
+
+func TestSpacesClone_ChecksSpacesBeforeCreating(t *testing.T) {
+	orgs := &cloneLookupOrgs{}
+	client := &cloneLookupClient{orgs: orgs, spaces: &cloneLookupSpaces{existing: map[string]bool{"dev": true, "feature-x": true}}}
+
+	withStubClient(t, client)
+	withOutputFormat(t, "table")
+
+	_, err := runCommand(t, NewSpacesCommand(), "clone", "dev", "feature-x", "--org", "payments", "--dest-org", "sandbox")
+	require.ErrorIs(t, err, capi.ErrSpaceAlreadyExists)
+	assert.Equal(t, []string{"payments", "sandbox"}, orgs.names)
+
+	_, err = runCommand(t, NewSpacesCommand(), "clone", "staging", "feature-y", "--org", "payments")
+	require.ErrorIs(t, err, capi.ErrNotFound)
+}
+
+func TestSpacesClone_ReadsFromAPIAndCreatesOnDestAPI(t *testing.T) {
+	client := &cloneLookupClient{orgs: &cloneLookupOrgs{}, spaces: &cloneLookupSpaces{existing: map[string]bool{"dev": true, "dev-copy": true}}}
+
+	var apis []string
+
+	original := newClientFunc
+	newClientFunc = func(api string) (capi.Client, error) {
+		apis = append(apis, api)
+
+		return client, nil
+	}
+
+	t.Cleanup(func() { newClientFunc = original })
+
+	withOutputFormat(t, "table")
+
+	_, err := runCommand(t, NewSpacesCommand(), "clone", "dev", "dev-copy", "--org", "payments", "--api", "source", "--dest-api", "dest")
+	require.ErrorIs(t, err, capi.ErrSpaceAlreadyExists)
+	assert.Equal(t, []string{"source", "dest"}, apis)
+
+	apis = nil
+
+	_, err = runCommand(t, NewSpacesCommand(), "clone", "dev", "dev-copy", "--org", "payments", "--api", "source")
+	require.ErrorIs(t, err, capi.ErrSpaceAlreadyExists)
+	assert.Equal(t, []string{"source"}, apis, "without --dest-api the copy is made on --api")
+}
+
+func TestSpacesClone_PrintsSteps(t *testing.T) {
+	out := captureStdout(t, func() {
+		printSpaceCloneStep(capi.SpaceCloneStep{Resource: capi.SpaceCloneResourceApp, Name: "api", Action: capi.SpaceCloneCopied, Detail: "droplet"})
+		printSpaceCloneStep(capi.SpaceCloneStep{Resource: capi.SpaceCloneResourceRoute, Name: "api.example.com", Action: capi.SpaceCloneMapped})
+	})
+
+	assert.Equal(t, "copied   app api (droplet)\nmapped   route api.example.com\n", out)
+}
@@ -233,6 +233,11 @@ func newStacksListCommand() *cobra.Command {
 				params.WithFilter("names", name)
 			}
 
+			err = applySelectorFlag(cmd, params)
+			if err != nil {
+				return err
+			}
+
 			stacks, err := client.Stacks().List(ctx, params)
 			if err != nil {
 				return fmt.Errorf("failed to list stacks: %w", err)
@@ -252,6 +257,7 @@ func newStacksListCommand() *cobra.Command {
 	cmd.Flags().BoolVar(&allPages, "all-pages", false, "fetch all pages")
 	cmd.Flags().IntVar(&perPage, "per-page", constants.StandardPageSize, "number of results per page")
 	cmd.Flags().StringVar(&name, "name", "", "filter by stack name")
+	addSelectorFlag(cmd, "only stacks matching this label selector")
 
 	return cmd
 }
@@ -0,0 +1,366 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTopInterval = 5 * time.Second
+	// topStatsConcurrency bounds the concurrent process stats requests.
+	topStatsConcurrency = 8
+	topKeyBufferSize    = 16
+
+	ansiAltScreenOn  = "\x1b[?1049h\x1b[?25l"
+	ansiAltScreenOff = "\x1b[?25h\x1b[?1049l"
+	ansiClearScreen  = "\x1b[H\x1b[2J"
+)
+
+type topOptions struct {
+	space    string
+	app      string
+	interval time.Duration
+	sortBy   string
+}
+
+// NewTopCommand creates the live process stats dashboard.
+func NewTopCommand() *cobra.Command {
+	opts := &topOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Live dashboard of application instance stats",
+		Long: `Show CPU, memory, disk and log rate of every application instance in a
+space, or of one application, refreshed on an interval.
+
+The app view aggregates each application's instances; press enter to see the
+instances of the selected application and esc to go back. Rows turn yellow at
+75% and red at 90% of their memory or disk quota. Crashes and restarts are
+counted from when top was started.
+
+Keys: up/down or j/k select, enter drill in, esc back, c/m/n sort by
+CPU/memory/name, q quit.
+
+When stdout is not a terminal a single snapshot is printed.`,
+		Example: `  # Watch the targeted space
+  capi top
+
+  # Watch one app, sorted by memory, every two seconds
+  capi top --app my-app --sort mem --interval 2s`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runTop(cmd, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.space, "space", "s", "", "space name or GUID (defaults to the targeted space)")
+	cmd.Flags().StringVar(&opts.app, "app", "", "only this app (name or GUID)")
+	cmd.Flags().DurationVar(&opts.interval, "interval", defaultTopInterval, "refresh interval")
+	cmd.Flags().StringVar(&opts.sortBy, "sort", string(topSortCPU), "initial sort: cpu, mem or name")
+	cmd.MarkFlagsMutuallyExclusive("space", "app")
+
+	return cmd
+}
+
+func runTop(cmd *cobra.Command, opts *topOptions) error {
+	sortBy := topSortKey(opts.sortBy)
+	if sortBy != topSortCPU && sortBy != topSortMemory && sortBy != topSortName {
+		return fmt.Errorf("%w: %q", ErrInvalidTopSort, opts.sortBy)
+	}
+
+	if opts.interval <= 0 {
+		return ErrInvalidTopInterval
+	}
+
+	client, err := CreateClientWithAPI(cmd.Flag("api").Value.String())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	source, err := newTopSource(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	model := newTopModel(source.label, sortBy, colorOutputEnabled())
+
+	stdin, stdout := int(os.Stdin.Fd()), int(os.Stdout.Fd()) //nolint:gosec // file descriptors fit in int
+	if !term.IsTerminal(stdin) || !term.IsTerminal(stdout) {
+		instances, err := source.collect(ctx)
+		if err != nil && instances == nil {
+			return err
+		}
+
+		model.update(instances, err, time.Now())
+		_, _ = os.Stdout.WriteString(strings.Join(model.render(0, 0), "\n") + "\n")
+
+		return nil
+	}
+
+	model.interactive = true
+
+	return runTopInteractive(ctx, source, model, opts.interval, stdin, stdout)
+}
+
+// runTopInteractive redraws the dashboard on every sample and key press
+// until q or Ctrl-C.
+func runTopInteractive(ctx context.Context, source *topSource, model *topModel, interval time.Duration, stdin, stdout int) error {
+	state, err := term.MakeRaw(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to put the terminal into raw mode: %w", err)
+	}
+
+	defer func() { _ = term.Restore(stdin, state) }()
+
+	_, _ = os.Stdout.WriteString(ansiAltScreenOn)
+	defer func() { _, _ = os.Stdout.WriteString(ansiAltScreenOff) }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	samples := source.poll(ctx, interval)
+	keys := readTopKeys()
+
+	draw := func() {
+		width, height, err := term.GetSize(stdout)
+		if err != nil {
+			width, height = defaultTerminalWidth, defaultTerminalHeight
+		}
+
+		// Raw mode does not translate \n, so lines end in \r\n.
+		_, _ = os.Stdout.WriteString(ansiClearScreen + strings.Join(model.render(width, height), "\r\n"))
+	}
+
+	draw()
+
+	for {
+		select {
+		case sample := <-samples:
+			model.update(sample.instances, sample.err, time.Now())
+		case key, ok := <-keys:
+			if !ok || model.handleKey(key) {
+				return nil
+			}
+		}
+
+		draw()
+	}
+}
+
+// readTopKeys reads key presses from stdin until it is closed.
+func readTopKeys() <-chan string {
+	keys := make(chan string, topKeyBufferSize)
+
+	go func() {
+		defer close(keys)
+
+		buf := make([]byte, topKeyBufferSize)
+
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+
+			for _, key := range parseTopKeys(buf[:n]) {
+				keys <- key
+			}
+		}
+	}()
+
+	return keys
+}
+
+// topSource collects the instances shown by capi top: those of one app, or
+// of every app in a space.
+type topSource struct {
+	client    capi.Client
+	label     string
+	spaceGUID string
+	app       *capi.App
+}
+
+type topSample struct {
+	instances []topInstance
+	err       error
+}
+
+func newTopSource(ctx context.Context, client capi.Client, opts *topOptions) (*topSource, error) {
+	source := &topSource{client: client}
+
+	if opts.app != "" {
+		appGUID, appName, err := resolveApp(ctx, client, opts.app)
+		if err != nil {
+			return nil, err
+		}
+
+		source.app = &capi.App{Resource: capi.Resource{GUID: appGUID}, Name: appName}
+		source.label = "app " + appName
+
+		return source, nil
+	}
+
+	spaceGUID, err := resolveSpaceGUID(ctx, client, opts.space)
+	if err != nil {
+		return nil, err
+	}
+
+	source.spaceGUID = spaceGUID
+	source.label = "space " + spaceGUID
+
+	switch {
+	case opts.space != "":
+		source.label = "space " + opts.space
+	case viper.GetString("space") != "":
+		source.label = "space " + viper.GetString("space")
+	}
+
+	return source, nil
+}
+
+// poll samples immediately and then every interval until ctx is canceled.
+func (s *topSource) poll(ctx context.Context, interval time.Duration) <-chan topSample {
+	samples := make(chan topSample)
+
+	go func() {
+		for {
+			instances, err := s.collect(ctx)
+
+			select {
+			case samples <- topSample{instances: instances, err: err}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return samples
+}
+
+// collect fetches the stats of every process. Processes whose stats cannot
+// be read are left out and reported in the returned error.
+func (s *topSource) collect(ctx context.Context) ([]topInstance, error) {
+	names := map[string]string{}
+
+	var processOptions []capi.ProcessListOption
+
+	if s.app != nil {
+		names[s.app.GUID] = s.app.Name
+		processOptions = append(processOptions, capi.WithProcessAppGUIDs(s.app.GUID))
+	} else {
+		apps, err := capi.CollectAllPages(ctx, nil, func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.App], error) {
+			return s.client.Apps().List(ctx, params, capi.WithAppSpaceGUIDs(s.spaceGUID))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list apps: %w", err)
+		}
+
+		for _, app := range apps {
+			names[app.GUID] = app.Name
+		}
+
+		processOptions = append(processOptions, capi.WithProcessSpaceGUIDs(s.spaceGUID))
+	}
+
+	processes, err := capi.CollectAllPages(ctx, nil, func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.Process], error) {
+		return s.client.Processes().List(ctx, params, processOptions...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	return s.collectStats(ctx, processes, names)
+}
+
+func (s *topSource) collectStats(ctx context.Context, processes []capi.Process, names map[string]string) ([]topInstance, error) {
+	var (
+		mutex     sync.Mutex
+		wg        sync.WaitGroup
+		errs      []error
+		instances = []topInstance{}
+		semaphore = make(chan struct{}, topStatsConcurrency)
+	)
+
+	for _, process := range processes {
+		if process.Instances == 0 {
+			continue
+		}
+
+		appGUID := ""
+		if process.Relationships != nil && process.Relationships.App != nil && process.Relationships.App.Data != nil {
+			appGUID = process.Relationships.App.Data.GUID
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			stats, err := s.client.Processes().GetStats(ctx, process.GUID)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stats of %s/%s: %w", names[appGUID], process.Type, err))
+
+				return
+			}
+
+			for _, stat := range stats.Resources {
+				instances = append(instances, newTopInstance(appGUID, names[appGUID], process.Type, &stat))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return instances, errors.Join(errs...)
+}
+
+func newTopInstance(appGUID, appName, processType string, stat *capi.ProcessStatsDetail) topInstance {
+	instance := topInstance{
+		AppGUID:     appGUID,
+		AppName:     appName,
+		ProcessType: processType,
+		Index:       stat.Index,
+		State:       stat.State,
+		MemQuota:    stat.MemQuota,
+		DiskQuota:   stat.DiskQuota,
+		Uptime:      time.Duration(stat.Uptime) * time.Second,
+	}
+
+	if instance.AppName == "" {
+		instance.AppName = appGUID
+	}
+
+	if stat.Usage != nil {
+		instance.CPU = stat.Usage.CPU * cpuPercentMultiplier
+		instance.Mem = stat.Usage.Mem
+		instance.Disk = stat.Usage.Disk
+		instance.LogRate = stat.Usage.LogRate
+	}
+
+	return instance
+}
@@ -0,0 +1,500 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// topSortKey orders the rows of capi top.
+type topSortKey string
+
+const (
+	topSortCPU    topSortKey = "cpu"
+	topSortMemory topSortKey = "mem"
+	topSortName   topSortKey = "name"
+)
+
+// topLevel grades how close an instance is to its memory or disk quota.
+type topLevel int
+
+const (
+	topLevelOK topLevel = iota
+	topLevelWarning
+	topLevelCritical
+)
+
+const (
+	// Quota usage fractions at which rows are highlighted.
+	topWarningUsage  = 0.75
+	topCriticalUsage = 0.9
+
+	topStateCrashed = "CRASHED"
+	topStateRunning = "RUNNING"
+
+	ansiReset   = "\x1b[0m"
+	ansiReverse = "\x1b[7m"
+	ansiYellow  = "\x1b[33m"
+	ansiRed     = "\x1b[31m"
+	ansiBold    = "\x1b[1m"
+
+	topHeaderLines = 4
+)
+
+// topInstance is the latest sample of one process instance.
+type topInstance struct {
+	AppGUID     string
+	AppName     string
+	ProcessType string
+	Index       int
+	State       string
+	// CPU is a percentage of one core.
+	CPU       float64
+	Mem       int64
+	MemQuota  int64
+	Disk      int64
+	DiskQuota int64
+	LogRate   int
+	Uptime    time.Duration
+}
+
+func (i *topInstance) key() string {
+	return i.AppGUID + "/" + i.ProcessType + "/" + strconv.Itoa(i.Index)
+}
+
+// level grades the worse of memory and disk usage against the quotas.
+func (i *topInstance) level() topLevel {
+	return max(usageLevel(i.Mem, i.MemQuota), usageLevel(i.Disk, i.DiskQuota))
+}
+
+func usageLevel(used, quota int64) topLevel {
+	if quota <= 0 {
+		return topLevelOK
+	}
+
+	usage := float64(used) / float64(quota)
+
+	switch {
+	case usage >= topCriticalUsage:
+		return topLevelCritical
+	case usage >= topWarningUsage:
+		return topLevelWarning
+	default:
+		return topLevelOK
+	}
+}
+
+// topAppRow aggregates the instances of one app.
+type topAppRow struct {
+	GUID      string
+	Name      string
+	Running   int
+	Total     int
+	CPU       float64
+	Mem       int64
+	MemQuota  int64
+	Disk      int64
+	DiskQuota int64
+	Crashes   int
+	Restarts  int
+	Level     topLevel
+}
+
+// topModel holds what capi top shows: the latest samples, the crashes and
+// restarts seen since it started, the sort order and the selection.
+type topModel struct {
+	label string
+	color bool
+	// interactive shows the selection; one-shot output has none.
+	interactive bool
+	sortBy      topSortKey
+	selected    int
+	drillApp    string
+	instances   []topInstance
+	previous    map[string]topInstance
+	crashes     map[string]int
+	restarts    map[string]int
+	updated     time.Time
+	lastErr     error
+}
+
+func newTopModel(label string, sortBy topSortKey, color bool) *topModel {
+	return &topModel{
+		label:    label,
+		color:    color,
+		sortBy:   sortBy,
+		previous: map[string]topInstance{},
+		crashes:  map[string]int{},
+		restarts: map[string]int{},
+	}
+}
+
+// update records a new sample. An instance that turned CRASHED counts as a
+// crash; a running instance whose uptime went down counts as a restart.
+func (m *topModel) update(instances []topInstance, err error, now time.Time) {
+	m.lastErr = err
+	m.updated = now
+
+	if instances == nil && err != nil {
+		return
+	}
+
+	for _, instance := range instances {
+		key := instance.key()
+
+		previous, seen := m.previous[key]
+		if seen {
+			switch {
+			case instance.State == topStateCrashed && previous.State != topStateCrashed:
+				m.crashes[key]++
+			case instance.State == topStateRunning && previous.State == topStateRunning && instance.Uptime < previous.Uptime:
+				m.restarts[key]++
+			}
+		}
+
+		m.previous[key] = instance
+	}
+
+	m.instances = instances
+	m.clampSelection()
+}
+
+// appRows aggregates the instances per app, sorted.
+func (m *topModel) appRows() []topAppRow {
+	rows := map[string]*topAppRow{}
+
+	for _, instance := range m.instances {
+		row, ok := rows[instance.AppGUID]
+		if !ok {
+			row = &topAppRow{GUID: instance.AppGUID, Name: instance.AppName}
+			rows[instance.AppGUID] = row
+		}
+
+		row.Total++
+		if instance.State == topStateRunning {
+			row.Running++
+		}
+
+		row.CPU += instance.CPU
+		row.Mem += instance.Mem
+		row.MemQuota += instance.MemQuota
+		row.Disk += instance.Disk
+		row.DiskQuota += instance.DiskQuota
+		row.Crashes += m.crashes[instance.key()]
+		row.Restarts += m.restarts[instance.key()]
+		row.Level = max(row.Level, instance.level())
+	}
+
+	sorted := make([]topAppRow, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+
+	sort.Slice(sorted, func(a, b int) bool {
+		return m.less(sorted[a].CPU, sorted[b].CPU, sorted[a].Mem, sorted[b].Mem,
+			sorted[a].Name, sorted[b].Name)
+	})
+
+	return sorted
+}
+
+// appInstances returns the instances of the drilled-into app, sorted.
+func (m *topModel) appInstances() []topInstance {
+	var instances []topInstance
+
+	for _, instance := range m.instances {
+		if instance.AppGUID == m.drillApp {
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(a, b int) bool {
+		return m.less(instances[a].CPU, instances[b].CPU, instances[a].Mem, instances[b].Mem,
+			instances[a].ProcessType+"/"+fmt.Sprintf("%05d", instances[a].Index),
+			instances[b].ProcessType+"/"+fmt.Sprintf("%05d", instances[b].Index))
+	})
+
+	return instances
+}
+
+func (m *topModel) less(cpuA, cpuB float64, memA, memB int64, nameA, nameB string) bool {
+	switch {
+	case m.sortBy == topSortCPU && cpuA != cpuB:
+		return cpuA > cpuB
+	case m.sortBy == topSortMemory && memA != memB:
+		return memA > memB
+	default:
+		return nameA < nameB
+	}
+}
+
+func (m *topModel) rowCount() int {
+	if m.drillApp != "" {
+		return len(m.appInstances())
+	}
+
+	return len(m.appRows())
+}
+
+func (m *topModel) clampSelection() {
+	m.selected = max(0, min(m.selected, m.rowCount()-1))
+}
+
+// handleKey applies a key press and reports whether top should quit.
+func (m *topModel) handleKey(key string) bool {
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "up", "k":
+		m.selected--
+	case "down", "j":
+		m.selected++
+	case "enter":
+		if m.drillApp == "" {
+			rows := m.appRows()
+			if m.selected < len(rows) {
+				m.drillApp = rows[m.selected].GUID
+				m.selected = 0
+			}
+		}
+	case "esc", "backspace":
+		m.drillApp = ""
+		m.selected = 0
+	case "c":
+		m.sortBy = topSortCPU
+	case "m":
+		m.sortBy = topSortMemory
+	case "n":
+		m.sortBy = topSortName
+	}
+
+	m.clampSelection()
+
+	return false
+}
+
+// render draws the current view into at most height lines of at most width
+// columns; zero means unlimited.
+func (m *topModel) render(width, height int) []string {
+	lines := make([]string, 0, topHeaderLines)
+	lines = append(lines, truncate(m.headerLine(), width), m.statusLine(width), "")
+
+	var header string
+
+	var rows []string
+
+	var levels []topLevel
+
+	if m.drillApp != "" {
+		header, rows, levels = m.renderInstances()
+	} else {
+		header, rows, levels = m.renderApps()
+	}
+
+	lines = append(lines, m.style(truncate(header, width), ansiBold))
+
+	// Keep the selected row on screen.
+	visible := len(rows)
+	if height > 0 {
+		visible = max(0, height-len(lines))
+	}
+
+	offset := max(0, m.selected-visible+1)
+
+	for i := offset; i < len(rows) && i < offset+visible; i++ {
+		lines = append(lines, m.styleRow(truncate(rows[i], width), m.interactive && i == m.selected, levels[i]))
+	}
+
+	return lines
+}
+
+func (m *topModel) headerLine() string {
+	view := m.label
+	if m.drillApp != "" {
+		for _, instance := range m.instances {
+			if instance.AppGUID == m.drillApp {
+				view += " > " + instance.AppName
+
+				break
+			}
+		}
+	}
+
+	updated := "never"
+	if !m.updated.IsZero() {
+		updated = m.updated.Format(time.TimeOnly)
+	}
+
+	return fmt.Sprintf("capi top - %s - %d instances - updated %s - sort: %s", view, len(m.instances), updated, m.sortBy)
+}
+
+func (m *topModel) statusLine(width int) string {
+	if m.lastErr != nil {
+		return m.style(truncate("Error: "+m.lastErr.Error(), width), ansiRed)
+	}
+
+	if m.drillApp != "" {
+		return truncate("up/down select  esc back  c/m/n sort by cpu/memory/name  q quit", width)
+	}
+
+	return truncate("up/down select  enter instances  c/m/n sort by cpu/memory/name  q quit", width)
+}
+
+func (m *topModel) renderApps() (string, []string, []topLevel) {
+	header := fmt.Sprintf("  %-32s %9s %7s %21s %21s %7s %8s",
+		"APP", "INSTANCES", "CPU%", "MEMORY", "DISK", "CRASHES", "RESTARTS")
+
+	appRows := m.appRows()
+	rows := make([]string, 0, len(appRows))
+	levels := make([]topLevel, 0, len(appRows))
+
+	for _, row := range appRows {
+		rows = append(rows, fmt.Sprintf("  %-32s %9s %7.1f %21s %21s %7d %8d",
+			row.Name, fmt.Sprintf("%d/%d", row.Running, row.Total), row.CPU,
+			formatUsage(row.Mem, row.MemQuota), formatUsage(row.Disk, row.DiskQuota),
+			row.Crashes, row.Restarts))
+		levels = append(levels, row.Level)
+	}
+
+	return header, rows, levels
+}
+
+func (m *topModel) renderInstances() (string, []string, []topLevel) {
+	header := fmt.Sprintf("  %-12s %5s %-9s %7s %21s %21s %10s %12s %7s %8s",
+		"PROCESS", "INDEX", "STATE", "CPU%", "MEMORY", "DISK", "LOG RATE", "UPTIME", "CRASHES", "RESTARTS")
+
+	instances := m.appInstances()
+	rows := make([]string, 0, len(instances))
+	levels := make([]topLevel, 0, len(instances))
+
+	for _, instance := range instances {
+		rows = append(rows, fmt.Sprintf("  %-12s %5d %-9s %7.1f %21s %21s %10s %12s %7d %8d",
+			instance.ProcessType, instance.Index, instance.State, instance.CPU,
+			formatUsage(instance.Mem, instance.MemQuota), formatUsage(instance.Disk, instance.DiskQuota),
+			formatBytes(int64(instance.LogRate))+"/s", instance.Uptime.Truncate(time.Second).String(),
+			m.crashes[instance.key()], m.restarts[instance.key()]))
+		levels = append(levels, instance.level())
+	}
+
+	return header, rows, levels
+}
+
+// styleRow marks the selected row and highlights rows near their quotas.
+// Without color, a marker in the first column stands in for both.
+func (m *topModel) styleRow(row string, selected bool, level topLevel) string {
+	if !m.color {
+		marker := ' '
+
+		switch level {
+		case topLevelCritical:
+			marker = '!'
+		case topLevelWarning:
+			marker = '~'
+		case topLevelOK:
+		}
+
+		if selected {
+			return ">" + string(marker) + row[min(2, len(row)):] //nolint:mnd // replaces the two-column gutter
+		}
+
+		return string(marker) + row[min(1, len(row)):]
+	}
+
+	var codes string
+
+	switch level {
+	case topLevelCritical:
+		codes += ansiRed
+	case topLevelWarning:
+		codes += ansiYellow
+	case topLevelOK:
+	}
+
+	if selected {
+		codes += ansiReverse
+	}
+
+	return m.style(row, codes)
+}
+
+func (m *topModel) style(text, codes string) string {
+	if !m.color || codes == "" {
+		return text
+	}
+
+	return codes + text + ansiReset
+}
+
+// formatUsage formats used/quota bytes, e.g. "312.0M/1.0G".
+func formatUsage(used, quota int64) string {
+	if quota <= 0 {
+		return formatBytes(used)
+	}
+
+	return formatBytes(used) + "/" + formatBytes(quota)
+}
+
+// formatBytes formats a byte count with a binary unit suffix.
+func formatBytes(value int64) string {
+	const unit = 1024
+
+	if value < unit {
+		return strconv.FormatInt(value, 10) + "B"
+	}
+
+	size := float64(value)
+	suffixes := []string{"K", "M", "G", "T"}
+	suffix := ""
+
+	for _, s := range suffixes {
+		if size < unit {
+			break
+		}
+
+		size /= unit
+		suffix = s
+	}
+
+	return fmt.Sprintf("%.1f%s", size, suffix)
+}
+
+func truncate(line string, width int) string {
+	if width <= 0 || len(line) <= width {
+		return line
+	}
+
+	return line[:width]
+}
+
+// parseTopKeys splits raw terminal input into key names.
+func parseTopKeys(input []byte) []string {
+	var keys []string
+
+	for i := 0; i < len(input); i++ {
+		switch b := input[i]; {
+		case b == 0x1b && i+2 < len(input) && input[i+1] == '[':
+			switch input[i+2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			}
+
+			i += 2
+		case b == 0x1b:
+			keys = append(keys, "esc")
+		case b == 0x03: //nolint:mnd // ETX, Ctrl-C in raw mode
+			keys = append(keys, "ctrl-c")
+		case b == '\r' || b == '\n':
+			keys = append(keys, "enter")
+		case b == 0x7f || b == 0x08: //nolint:mnd // DEL and BS
+			keys = append(keys, "backspace")
+		default:
+			keys = append(keys, strings.ToLower(string(rune(b))))
+		}
+	}
+
+	return keys
+}
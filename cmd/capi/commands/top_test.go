@@ -0,0 +1,217 @@
+//nolint:testpackage // needs access to the unexported top model and the newClientFunc seam
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mib = 1024 * 1024
+
+type topAppsStub struct {
+	capi.AppsClient
+
+	apps []capi.App
+}
+
+func (s *topAppsStub) List(context.Context, *capi.QueryParams, ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	return &capi.ListResponse[capi.App]{Resources: s.apps}, nil
+}
+
+type topProcessesStub struct {
+	capi.ProcessesClient
+
+	processes []capi.Process
+	stats     map[string][]capi.ProcessStatsDetail
+}
+
+func (s *topProcessesStub) List(context.Context, *capi.QueryParams, ...capi.ProcessListOption) (*capi.ListResponse[capi.Process], error) {
+	return &capi.ListResponse[capi.Process]{Resources: s.processes}, nil
+}
+
+func (s *topProcessesStub) GetStats(_ context.Context, guid string) (*capi.ProcessStats, error) {
+	return &capi.ProcessStats{Resources: s.stats[guid]}, nil
+}
+
+func topProcess(guid, appGUID, processType string, instances int) capi.Process {
+	return capi.Process{
+		Resource:      capi.Resource{GUID: guid},
+		Type:          processType,
+		Instances:     instances,
+		Relationships: &capi.ProcessRelationships{App: &capi.Relationship{Data: &capi.RelationshipData{GUID: appGUID}}},
+	}
+}
+
+func topStat(index int, state string, cpu float64, memMiB, uptime int) capi.ProcessStatsDetail {
+	return capi.ProcessStatsDetail{
+		Index:     index,
+		State:     state,
+		Uptime:    uptime,
+		MemQuota:  1024 * mib,
+		DiskQuota: 1024 * mib,
+		Usage:     &capi.ProcessUsage{CPU: cpu, Mem: int64(memMiB) * mib, Disk: 100 * mib, LogRate: 2048},
+	}
+}
+
+func TestTopCommand_PrintsSnapshotWhenNotATerminal(t *testing.T) {
+	withStubClient(t, &fakeClient{
+		apps: &topAppsStub{apps: []capi.App{
+			{Resource: capi.Resource{GUID: "api-guid"}, Name: "api"},
+			{Resource: capi.Resource{GUID: "worker-guid"}, Name: "worker"},
+		}},
+		processes: &topProcessesStub{
+			processes: []capi.Process{
+				topProcess("api-web", "api-guid", "web", 2),
+				topProcess("worker-worker", "worker-guid", "worker", 1),
+				topProcess("api-task", "api-guid", "task", 0),
+			},
+			stats: map[string][]capi.ProcessStatsDetail{
+				"api-web":       {topStat(0, "RUNNING", 0.10, 200, 60), topStat(1, "RUNNING", 0.05, 950, 60)},
+				"worker-worker": {topStat(0, "RUNNING", 0.50, 100, 60)},
+			},
+		},
+	})
+
+	original := viper.GetString("space_guid")
+	viper.Set("space_guid", "space-guid")
+	t.Cleanup(func() { viper.Set("space_guid", original) })
+
+	root := newTestRootCommand(NewTopCommand())
+	root.SetArgs([]string{"top", "--sort", "cpu"})
+
+	output := captureStdout(t, func() {
+		require.NoError(t, root.Execute())
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 6)
+	assert.Contains(t, lines[0], "3 instances")
+	assert.Contains(t, lines[3], "APP")
+	// Sorted by CPU: worker (50%) before api (15%); api is flagged because
+	// instance 1 uses 950M of 1G.
+	assert.Contains(t, lines[4], "worker")
+	assert.Contains(t, lines[5], "api")
+	assert.Contains(t, lines[5], "2/2")
+	assert.True(t, strings.HasPrefix(lines[5], "!"), lines[5])
+}
+
+func TestTopCommand_RejectsUnknownSort(t *testing.T) {
+	root := newTestRootCommand(NewTopCommand())
+	root.SetArgs([]string{"top", "--sort", "disk"})
+
+	require.ErrorIs(t, root.Execute(), ErrInvalidTopSort)
+}
+
+func TestTopModel_CountsCrashesAndRestarts(t *testing.T) {
+	t.Parallel()
+
+	model := newTopModel("space dev", topSortCPU, false)
+	instance := topInstance{AppGUID: "app", AppName: "api", ProcessType: "web", State: "RUNNING", Uptime: time.Hour}
+
+	model.update([]topInstance{instance}, nil, time.Now())
+
+	restarted := instance
+	restarted.Uptime = time.Minute
+	model.update([]topInstance{restarted}, nil, time.Now())
+
+	crashed := restarted
+	crashed.State = "CRASHED"
+	model.update([]topInstance{crashed}, nil, time.Now())
+	model.update([]topInstance{crashed}, nil, time.Now())
+
+	rows := model.appRows()
+	require.Len(t, rows, 1)
+	assert.Equal(t, 1, rows[0].Restarts)
+	assert.Equal(t, 1, rows[0].Crashes)
+	assert.Equal(t, 0, rows[0].Running)
+}
+
+func TestTopModel_SortAndDrillDown(t *testing.T) {
+	t.Parallel()
+
+	model := newTopModel("space dev", topSortCPU, false)
+	model.interactive = true
+	model.update([]topInstance{
+		{AppGUID: "a", AppName: "alpha", ProcessType: "web", Index: 0, State: "RUNNING", CPU: 5, Mem: 300 * mib},
+		{AppGUID: "b", AppName: "beta", ProcessType: "web", Index: 0, State: "RUNNING", CPU: 50, Mem: 100 * mib},
+		{AppGUID: "b", AppName: "beta", ProcessType: "web", Index: 1, State: "RUNNING", CPU: 70, Mem: 100 * mib},
+	}, nil, time.Now())
+
+	assert.Equal(t, "beta", model.appRows()[0].Name)
+
+	model.handleKey("m")
+	assert.Equal(t, "alpha", model.appRows()[0].Name)
+
+	model.handleKey("n")
+	model.handleKey("down")
+	assert.False(t, model.handleKey("enter"))
+	assert.Equal(t, "b", model.drillApp)
+
+	instances := model.appInstances()
+	require.Len(t, instances, 2)
+	assert.Equal(t, 0, instances[0].Index)
+
+	lines := model.render(0, 0)
+	assert.Contains(t, lines[0], "space dev > beta")
+	assert.True(t, strings.HasPrefix(lines[4], ">"), lines[4])
+
+	model.handleKey("esc")
+	assert.Empty(t, model.drillApp)
+	assert.True(t, model.handleKey("q"))
+}
+
+func TestTopModel_RenderFitsTheTerminal(t *testing.T) {
+	t.Parallel()
+
+	model := newTopModel("space dev", topSortName, true)
+	model.interactive = true
+
+	instances := make([]topInstance, 0, 20)
+	for i := range 20 {
+		instances = append(instances, topInstance{AppGUID: string(rune('a' + i)), AppName: string(rune('a' + i)), State: "RUNNING"})
+	}
+
+	model.update(instances, nil, time.Now())
+
+	for range 15 {
+		model.handleKey("down")
+	}
+
+	lines := model.render(40, 10)
+	require.Len(t, lines, 10)
+	// The selected row (p) is the last one shown, in reverse video.
+	assert.Contains(t, lines[9], ansiReverse)
+	assert.Contains(t, lines[9], "p")
+}
+
+func TestUsageLevel(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, topLevelOK, usageLevel(10, 0))
+	assert.Equal(t, topLevelOK, usageLevel(70, 100))
+	assert.Equal(t, topLevelWarning, usageLevel(75, 100))
+	assert.Equal(t, topLevelCritical, usageLevel(95, 100))
+}
+
+func TestParseTopKeys(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"up", "down", "enter", "esc", "q", "ctrl-c", "backspace"},
+		parseTopKeys([]byte("\x1b[A\x1b[B\r\x1bQ\x03\x7f")))
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "512B", formatBytes(512))
+	assert.Equal(t, "1.5K", formatBytes(1536))
+	assert.Equal(t, "950.0M", formatBytes(950*mib))
+	assert.Equal(t, "950.0M/1.0G", formatUsage(950*mib, 1024*mib))
+}
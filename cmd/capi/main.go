@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -125,6 +126,8 @@ func addResourceCommands(cmd *cobra.Command) {
 	cmd.AddCommand(commands.NewOrgsCommand())
 	cmd.AddCommand(commands.NewSpacesCommand())
 	cmd.AddCommand(commands.NewAppsCommand())
+	cmd.AddCommand(commands.NewLogsCommand())
+	cmd.AddCommand(commands.NewTopCommand())
 	cmd.AddCommand(commands.NewServicesCommand())
 	cmd.AddCommand(commands.NewDomainsCommand())
 	cmd.AddCommand(commands.NewRoutesCommand())
@@ -150,6 +153,13 @@ func addAPIv3Commands(cmd *cobra.Command) {
 	cmd.AddCommand(commands.NewFeatureFlagsCommand())
 	cmd.AddCommand(commands.NewManifestsCommand())
 	cmd.AddCommand(commands.NewAdminCommand())
+	cmd.AddCommand(commands.NewBatchCommand())
+	cmd.AddCommand(commands.NewLabelCommand())
+	cmd.AddCommand(commands.NewUnlabelCommand())
+	cmd.AddCommand(commands.NewAnnotateCommand())
+	cmd.AddCommand(commands.NewConfigAsCodeCommand())
+	cmd.AddCommand(commands.NewExportCommand())
+	cmd.AddCommand(commands.NewSnapshotCommand())
 }
 
 func initConfig() {
@@ -200,6 +210,15 @@ func main() {
 
 	err := rootCmd.Execute()
 	if err != nil {
+		var exitErr *commands.ExitCodeError
+		if errors.As(err, &exitErr) {
+			if exitErr.Err != nil {
+				fmt.Fprintln(os.Stderr, exitErr.Err)
+			}
+
+			os.Exit(exitErr.Code)
+		}
+
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
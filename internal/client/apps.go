@@ -440,9 +440,21 @@ func (c *AppsClient) GetRecentLogs(ctx context.Context, guid string, lines int)
 	}, nil
 }
 
-// StreamLogs implements capi.AppsClient.StreamLogs.
-
+// StreamLogs implements capi.AppsClient.StreamLogs. Logs are streamed through
+// the RLP gateway when the API advertises one, and otherwise polled from Log
+// Cache.
 func (c *AppsClient) StreamLogs(ctx context.Context, guid string) (<-chan capi.LogMessage, error) {
+	stream := &LogStreamClient{apps: c}
+
+	envelopes, err := stream.Stream(ctx, &capi.LogStreamOptions{SourceIDs: []string{guid}})
+	if err == nil {
+		logChan := make(chan capi.LogMessage, constants.BufferSize)
+
+		go c.forwardLogEnvelopes(ctx, guid, envelopes, logChan)
+
+		return logChan, nil
+	}
+
 	// Get the log_cache endpoint URL
 	logCacheURL, err := c.getLogCacheURL(ctx)
 	if err != nil {
@@ -551,6 +563,50 @@ func (c *AppsClient) getLogCacheURL(ctx context.Context) (string, error) {
 	return logCacheURL, nil
 }
 
+// getLogStreamURL returns the RLP gateway URL from the log_stream root link,
+// or "" when the API does not advertise one.
+func (c *AppsClient) getLogStreamURL(ctx context.Context) (string, error) {
+	if url, exists := c.apiLinks["log_stream"]; exists {
+		return url, nil
+	}
+
+	// The log_stream link is only part of the API root, not of /v3.
+	rootResp, err := c.httpClient.Get(ctx, "/", nil)
+	if err != nil {
+		return "", fmt.Errorf("getting API root: %w", err)
+	}
+
+	var root capi.RootInfo
+
+	err = json.Unmarshal(rootResp.Body, &root)
+	if err != nil {
+		return "", fmt.Errorf("parsing API root response: %w", err)
+	}
+
+	if link, exists := root.Links["log_stream"]; exists {
+		return link.Href, nil
+	}
+
+	return "", nil
+}
+
+// forwardLogEnvelopes converts streamed log envelopes to LogMessages.
+func (c *AppsClient) forwardLogEnvelopes(ctx context.Context, guid string, envelopes <-chan capi.LogCacheEnvelope, logChan chan<- capi.LogMessage) {
+	defer close(logChan)
+
+	for envelope := range envelopes {
+		if envelope.Log == nil {
+			continue
+		}
+
+		select {
+		case logChan <- *c.processLogEnvelope(envelope, guid):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // getBaselineTimestamp gets the most recent log timestamp to start streaming from.
 func (c *AppsClient) getBaselineTimestamp(ctx context.Context, logCacheURL, guid string) int64 {
 	logCacheEndpoint, err := c.buildLogCacheURL(logCacheURL, "/api/v1/read/"+guid)
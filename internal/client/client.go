@@ -65,6 +65,10 @@ type Client struct {
 	resourceMatches           capi.ResourceMatchesClient
 	manifests                 capi.ManifestsClient
 	routing                   capi.RoutingClient
+	logCache                  capi.LogCacheClient
+	logStream                 capi.LogStreamClient
+	ssh                       capi.SSHClient
+	metadataClient            capi.MetadataClient
 }
 
 // New creates a new CF API client.
@@ -292,8 +296,10 @@ func (c *Client) FetchAPILinks(ctx context.Context) error {
 		}
 
 		c.apiLinks = apiLinks
-		// Re-initialize apps client with API links
+		// Re-initialize link-dependent clients with API links
 		c.apps = NewAppsClientWithLinks(c.httpClient, apiLinks)
+		c.logCache = NewLogCacheClient(c.httpClient, apiLinks)
+		c.logStream = NewLogStreamClient(c.httpClient, apiLinks)
 	}
 
 	return nil
@@ -557,11 +563,31 @@ func (c *Client) Manifests() capi.ManifestsClient {
 	return c.manifests
 }
 
+// LogCache implements capi.Client.LogCache.
+func (c *Client) LogCache() capi.LogCacheClient {
+	return c.logCache
+}
+
+// LogStream implements capi.Client.LogStream.
+func (c *Client) LogStream() capi.LogStreamClient {
+	return c.logStream
+}
+
+// Metadata implements capi.Client.Metadata.
+func (c *Client) Metadata() capi.MetadataClient {
+	return c.metadataClient
+}
+
 // Routing implements capi.Client.Routing.
 func (c *Client) Routing() capi.RoutingClient {
 	return c.routing
 }
 
+// SSH implements capi.Client.SSH.
+func (c *Client) SSH() capi.SSHClient {
+	return c.ssh
+}
+
 // GetToken returns the current access token from the token manager.
 func (c *Client) GetToken(ctx context.Context) (string, error) {
 	if c.tokenManager == nil {
@@ -614,6 +640,9 @@ func (c *Client) initializeResourceClients() {
 	c.resourceMatches = NewResourceMatchesClient(c.httpClient)
 	c.manifests = NewManifestsClient(c.httpClient)
 	c.routing = NewRoutingClient(c.httpClient)
+	c.logCache = NewLogCacheClient(c.httpClient, nil)
+	c.logStream = NewLogStreamClient(c.httpClient, nil)
+	c.ssh = NewSSHClient(c.httpClient)
 }
 
 // staticTokenManager provides a static token.
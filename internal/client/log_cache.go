@@ -0,0 +1,187 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	internalhttp "github.com/fivetwenty-io/capi/v3/internal/http"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+)
+
+// ErrLogCacheRequestFailed is returned when Log Cache answers with a non-2xx
+// status. Unlike AppsClient's log helpers, LogCacheClient surfaces failures.
+var ErrLogCacheRequestFailed = errors.New("log cache request failed")
+
+// logCacheErrorBodyLimit bounds how much of an error body is quoted.
+const logCacheErrorBodyLimit = 512
+
+// LogCacheClient implements the capi.LogCacheClient interface.
+type LogCacheClient struct {
+	// apps provides Log Cache URL discovery and authenticated requests.
+	apps *AppsClient
+}
+
+// NewLogCacheClient creates a new LogCacheClient. apiLinks may be nil, in
+// which case the Log Cache URL is discovered through /v3/info.
+func NewLogCacheClient(httpClient *internalhttp.Client, apiLinks map[string]string) *LogCacheClient {
+	return &LogCacheClient{apps: NewAppsClientWithLinks(httpClient, apiLinks)}
+}
+
+// Read implements capi.LogCacheClient.Read.
+func (c *LogCacheClient) Read(ctx context.Context, sourceID string, opts *capi.LogCacheReadOptions) ([]capi.LogCacheEnvelope, error) {
+	query := url.Values{}
+
+	if opts != nil {
+		for _, envelopeType := range opts.EnvelopeTypes {
+			query.Add("envelope_types", string(envelopeType))
+		}
+
+		if !opts.Start.IsZero() {
+			query.Set("start_time", strconv.FormatInt(opts.Start.UnixNano(), 10))
+		}
+
+		if !opts.End.IsZero() {
+			query.Set("end_time", strconv.FormatInt(opts.End.UnixNano(), 10))
+		}
+
+		if opts.Limit > 0 {
+			query.Set("limit", strconv.Itoa(opts.Limit))
+		}
+
+		if opts.Descending {
+			query.Set("descending", "true")
+		}
+	}
+
+	body, err := c.get(ctx, "/api/v1/read/"+url.PathEscape(sourceID), query)
+	if err != nil {
+		return nil, fmt.Errorf("reading envelopes for %s: %w", sourceID, err)
+	}
+
+	var response capi.LogCacheResponse
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log cache read response: %w", err)
+	}
+
+	return response.Envelopes.Batch, nil
+}
+
+// Meta implements capi.LogCacheClient.Meta.
+func (c *LogCacheClient) Meta(ctx context.Context) (map[string]capi.LogCacheMetaInfo, error) {
+	body, err := c.get(ctx, "/api/v1/meta", nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading log cache meta: %w", err)
+	}
+
+	var response capi.LogCacheMetaResponse
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log cache meta response: %w", err)
+	}
+
+	return response.Meta, nil
+}
+
+// Query implements capi.LogCacheClient.Query.
+func (c *LogCacheClient) Query(ctx context.Context, query string, at time.Time) (*capi.PromQLResult, error) {
+	params := url.Values{"query": {query}}
+
+	if !at.IsZero() {
+		params.Set("time", formatPromQLTime(at))
+	}
+
+	body, err := c.get(ctx, "/api/v1/query", params)
+	if err != nil {
+		return nil, fmt.Errorf("querying log cache: %w", err)
+	}
+
+	result, err := capi.ParsePromQLResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("querying log cache: %w", err)
+	}
+
+	return result, nil
+}
+
+// QueryRange implements capi.LogCacheClient.QueryRange.
+func (c *LogCacheClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*capi.PromQLResult, error) {
+	params := url.Values{
+		"query": {query},
+		"start": {formatPromQLTime(start)},
+		"end":   {formatPromQLTime(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+
+	body, err := c.get(ctx, "/api/v1/query_range", params)
+	if err != nil {
+		return nil, fmt.Errorf("querying log cache range: %w", err)
+	}
+
+	result, err := capi.ParsePromQLResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("querying log cache range: %w", err)
+	}
+
+	return result, nil
+}
+
+// formatPromQLTime renders t as fractional unix seconds.
+func formatPromQLTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/float64(time.Second), 'f', 3, 64) //nolint:mnd // millisecond precision
+}
+
+// get issues an authenticated GET against Log Cache and returns the body of
+// a 2xx response.
+func (c *LogCacheClient) get(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	logCacheURL, err := c.apps.getLogCacheURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := c.apps.buildLogCacheURL(logCacheURL, path)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.apps.createLogCacheRequest(ctx, endpoint, query)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: constants.DefaultHTTPTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", path, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		if len(body) > logCacheErrorBodyLimit {
+			body = body[:logCacheErrorBodyLimit]
+		}
+
+		return nil, fmt.Errorf("%w: %s returned %d: %s", ErrLogCacheRequestFailed, path, resp.StatusCode, body)
+	}
+
+	return body, nil
+}
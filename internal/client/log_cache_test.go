@@ -0,0 +1,205 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/fivetwenty-io/capi/v3/internal/client"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const logCacheReadBody = `{"envelopes":{"batch":[
+  {"timestamp":"1700000000000000000","source_id":"app-guid","instance_id":"0","tags":{"source_type":"APP/PROC/WEB"},
+   "log":{"payload":"aGVsbG8=","type":"OUT"}},
+  {"timestamp":"1700000001000000000","source_id":"app-guid","instance_id":"0",
+   "gauge":{"metrics":{"cpu":{"unit":"percentage","value":12.5},"memory":{"unit":"bytes","value":1048576}}}},
+  {"timestamp":"1700000002000000000","source_id":"app-guid","instance_id":"1",
+   "counter":{"name":"requests","delta":"3","total":"1200"}},
+  {"timestamp":"1700000003000000000","source_id":"app-guid","instance_id":"1",
+   "timer":{"name":"http","start":"1700000002900000000","stop":"1700000003000000000"}},
+  {"timestamp":"1700000004000000000","source_id":"app-guid",
+   "event":{"title":"app crashed","body":"exit status 1"}}
+]}}`
+
+// newLogCacheServer serves /v3/info advertising itself as Log Cache, plus
+// the given Log Cache handler.
+func newLogCacheServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/v3/info" {
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"links":{"log_cache":{"href":"` + server.URL + `"}}}`))
+
+			return
+		}
+
+		handler(writer, request)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestLogCacheClient_Read(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(0, 1700000000000000000)
+	end := start.Add(time.Minute)
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/read/app-guid", request.URL.Path)
+
+		query := request.URL.Query()
+		assert.Equal(t, []string{"GAUGE", "TIMER"}, query["envelope_types"])
+		assert.Equal(t, "1700000000000000000", query.Get("start_time"))
+		assert.Equal(t, "1700000060000000000", query.Get("end_time"))
+		assert.Equal(t, "50", query.Get("limit"))
+		assert.Equal(t, "true", query.Get("descending"))
+
+		_, _ = writer.Write([]byte(logCacheReadBody))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	envelopes, err := client.LogCache().Read(context.Background(), "app-guid", &capi.LogCacheReadOptions{
+		EnvelopeTypes: []capi.LogCacheEnvelopeType{capi.LogCacheEnvelopeTypeGauge, capi.LogCacheEnvelopeTypeTimer},
+		Start:         start,
+		End:           end,
+		Limit:         50,
+		Descending:    true,
+	})
+	require.NoError(t, err)
+	require.Len(t, envelopes, 5)
+
+	assert.Equal(t, capi.LogCacheEnvelopeTypeLog, envelopes[0].Type())
+	assert.Equal(t, "hello", string(envelopes[0].Log.Payload))
+	assert.Equal(t, start, envelopes[0].Time())
+
+	assert.Equal(t, capi.LogCacheEnvelopeTypeGauge, envelopes[1].Type())
+	assert.InDelta(t, 12.5, envelopes[1].Gauge.Metrics["cpu"].Value, 0.001)
+	assert.Equal(t, "bytes", envelopes[1].Gauge.Metrics["memory"].Unit)
+
+	assert.Equal(t, capi.LogCacheEnvelopeTypeCounter, envelopes[2].Type())
+	assert.Equal(t, uint64(3), envelopes[2].Counter.Delta)
+	assert.Equal(t, uint64(1200), envelopes[2].Counter.Total)
+
+	assert.Equal(t, capi.LogCacheEnvelopeTypeTimer, envelopes[3].Type())
+	assert.Equal(t, 100*time.Millisecond, envelopes[3].Timer.Duration())
+
+	assert.Equal(t, capi.LogCacheEnvelopeTypeEvent, envelopes[4].Type())
+	assert.Equal(t, "app crashed", envelopes[4].Event.Title)
+}
+
+func TestLogCacheClient_Meta(t *testing.T) {
+	t.Parallel()
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/meta", request.URL.Path)
+
+		_, _ = writer.Write([]byte(`{"meta":{"app-guid":{"count":"42","expired":"7","oldest_timestamp":"1700000000000000000","newest_timestamp":"1700000060000000000"}}}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	meta, err := client.LogCache().Meta(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, capi.LogCacheMetaInfo{
+		Count:           42,
+		Expired:         7,
+		OldestTimestamp: 1700000000000000000,
+		NewestTimestamp: 1700000060000000000,
+	}, meta["app-guid"])
+}
+
+func TestLogCacheClient_Error(t *testing.T) {
+	t.Parallel()
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusForbidden)
+		_, _ = writer.Write([]byte(`{"error":"forbidden"}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.LogCache().Read(context.Background(), "app-guid", nil)
+	require.ErrorIs(t, err, ErrLogCacheRequestFailed)
+	assert.Contains(t, err.Error(), "403")
+}
+
+func TestLogCacheClient_Query(t *testing.T) {
+	t.Parallel()
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/query", request.URL.Path)
+		assert.Equal(t, `cpu{source_id="app-guid"}`, request.URL.Query().Get("query"))
+		assert.Equal(t, "1700000000.000", request.URL.Query().Get("time"))
+
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+  {"metric":{"source_id":"app-guid","instance_id":"0"},"value":[1700000000.5,"12.5"]}]}}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	result, err := client.LogCache().Query(context.Background(), `cpu{source_id="app-guid"}`, time.Unix(1700000000, 0))
+	require.NoError(t, err)
+	assert.Equal(t, capi.PromQLResultTypeVector, result.ResultType)
+	require.Len(t, result.Vector, 1)
+	assert.Equal(t, "0", result.Vector[0].Metric["instance_id"])
+	assert.InDelta(t, 12.5, result.Vector[0].Point.Value, 0.001)
+	assert.Equal(t, time.Unix(1700000000, 500000000).UTC(), result.Vector[0].Point.Time)
+}
+
+func TestLogCacheClient_QueryRange(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1700000000, 0)
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/query_range", request.URL.Path)
+
+		query := request.URL.Query()
+		assert.Equal(t, "1700000000.000", query.Get("start"))
+		assert.Equal(t, "1700003600.000", query.Get("end"))
+		assert.Equal(t, "60", query.Get("step"))
+
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+  {"metric":{"source_id":"app-guid"},"values":[[1700000000,"1"],[1700000060,"2.5"]]}]}}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	result, err := client.LogCache().QueryRange(context.Background(), "avg(cpu)", start, start.Add(time.Hour), time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, capi.PromQLResultTypeMatrix, result.ResultType)
+	require.Len(t, result.Matrix, 1)
+	require.Len(t, result.Matrix[0].Points, 2)
+	assert.InDelta(t, 2.5, result.Matrix[0].Points[1].Value, 0.001)
+}
+
+func TestLogCacheClient_QueryError(t *testing.T) {
+	t.Parallel()
+
+	server := newLogCacheServer(t, func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.LogCache().Query(context.Background(), "cpu{", time.Time{})
+	require.ErrorIs(t, err, capi.ErrPromQLQueryFailed)
+	assert.Contains(t, err.Error(), "parse error")
+}
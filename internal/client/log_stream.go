@@ -0,0 +1,359 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	internalhttp "github.com/fivetwenty-io/capi/v3/internal/http"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrLogStreamRequestFailed = errors.New("log stream request failed")
+	ErrLogStreamClosed        = errors.New("log stream closed by the gateway")
+)
+
+const (
+	defaultLogStreamReconnectDelay = time.Second
+	maxLogStreamReconnectDelay     = time.Minute
+	// logStreamBackfillLimit is the largest page Log Cache serves.
+	logStreamBackfillLimit = 1000
+	logStreamShardIDBytes  = 16
+)
+
+// LogStreamClient implements the capi.LogStreamClient interface.
+type LogStreamClient struct {
+	// apps provides root link discovery and authenticated requests.
+	apps *AppsClient
+}
+
+// NewLogStreamClient creates a new LogStreamClient. apiLinks may be nil, in
+// which case the gateway URL is discovered through the API root.
+func NewLogStreamClient(httpClient *internalhttp.Client, apiLinks map[string]string) *LogStreamClient {
+	return &LogStreamClient{apps: NewAppsClientWithLinks(httpClient, apiLinks)}
+}
+
+// Stream implements capi.LogStreamClient.Stream.
+func (c *LogStreamClient) Stream(ctx context.Context, opts *capi.LogStreamOptions) (<-chan capi.LogCacheEnvelope, error) {
+	gatewayURL, err := c.apps.getLogStreamURL(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if gatewayURL == "" {
+		return nil, capi.ErrLogStreamUnavailable
+	}
+
+	session := &logStreamSession{
+		client:     c,
+		gatewayURL: gatewayURL,
+		out:        make(chan capi.LogCacheEnvelope, constants.BufferSize),
+	}
+
+	if opts != nil {
+		session.opts = *opts
+	}
+
+	if len(session.opts.EnvelopeTypes) == 0 {
+		session.opts.EnvelopeTypes = []capi.LogCacheEnvelopeType{capi.LogCacheEnvelopeTypeLog}
+	}
+
+	if session.opts.ReconnectDelay <= 0 {
+		session.opts.ReconnectDelay = defaultLogStreamReconnectDelay
+	}
+
+	if session.opts.ShardID == "" {
+		session.opts.ShardID = randomShardID()
+	}
+
+	go session.run(ctx)
+
+	return session.out, nil
+}
+
+// logStreamSession is the state of one Stream call across reconnects.
+type logStreamSession struct {
+	client     *LogStreamClient
+	gatewayURL string
+	opts       capi.LogStreamOptions
+	out        chan capi.LogCacheEnvelope
+
+	// last is the time of the newest envelope delivered so far.
+	last time.Time
+	// backfilled holds the keys of envelopes delivered from Log Cache after
+	// the latest reconnect, so that the stream does not repeat them.
+	backfilled map[string]struct{}
+}
+
+func (s *logStreamSession) run(ctx context.Context) {
+	defer close(s.out)
+
+	delay := s.opts.ReconnectDelay
+
+	for {
+		body, err := s.connect(ctx)
+		if err == nil {
+			delay = s.opts.ReconnectDelay
+
+			s.resume(ctx)
+
+			err = s.consume(ctx, body)
+			_ = body.Close()
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if s.opts.OnError != nil {
+			s.opts.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*constants.ExponentialBackoffBase, maxLogStreamReconnectDelay)
+	}
+}
+
+// connect opens the server-sent-events stream.
+func (s *logStreamSession) connect(ctx context.Context) (io.ReadCloser, error) {
+	endpoint, err := s.client.apps.buildLogCacheURL(s.gatewayURL, "/v2/read")
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for _, envelopeType := range s.opts.EnvelopeTypes {
+		query.Set(strings.ToLower(string(envelopeType)), "")
+	}
+
+	for _, sourceID := range s.opts.SourceIDs {
+		query.Add("source_id", sourceID)
+	}
+
+	query.Set("shard_id", s.opts.ShardID)
+
+	req, err := s.client.apps.createLogCacheRequest(ctx, endpoint, query)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	// No client timeout: the response body stays open for as long as the
+	// stream lasts and is bounded by ctx instead.
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to log stream: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, logCacheErrorBodyLimit))
+		_ = resp.Body.Close()
+
+		return nil, fmt.Errorf("%w: /v2/read returned %d: %s", ErrLogStreamRequestFailed, resp.StatusCode, body)
+	}
+
+	return resp.Body, nil
+}
+
+// resume delivers the envelopes Log Cache received since the newest one
+// delivered before the connection dropped, up to now; later envelopes
+// arrive on the new stream.
+func (s *logStreamSession) resume(ctx context.Context) {
+	s.backfilled = nil
+
+	if s.last.IsZero() || s.opts.DisableResume {
+		return
+	}
+
+	backfilled := map[string]struct{}{}
+	start := s.last.Add(time.Nanosecond)
+	end := time.Now()
+
+	for _, sourceID := range s.opts.SourceIDs {
+		err := s.backfill(ctx, sourceID, start, end, backfilled)
+		if err != nil && s.opts.OnError != nil {
+			s.opts.OnError(fmt.Errorf("resuming %s from log cache: %w", sourceID, err))
+		}
+	}
+
+	s.backfilled = backfilled
+}
+
+// backfill pages through the envelopes of sourceID between start and end.
+// Each page starts at the time of the previous page's newest envelope, which
+// may share its timestamp with envelopes on the next page; those already
+// delivered are skipped.
+func (s *logStreamSession) backfill(ctx context.Context, sourceID string, start, end time.Time, backfilled map[string]struct{}) error {
+	logCache := &LogCacheClient{apps: s.client.apps}
+
+	for ctx.Err() == nil {
+		envelopes, err := logCache.Read(ctx, sourceID, &capi.LogCacheReadOptions{
+			EnvelopeTypes: s.opts.EnvelopeTypes,
+			Start:         start,
+			End:           end,
+			Limit:         logStreamBackfillLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		next := start
+
+		for _, envelope := range envelopes {
+			key := envelopeKey(&envelope)
+			if _, ok := backfilled[key]; ok {
+				continue
+			}
+
+			backfilled[key] = struct{}{}
+
+			s.deliver(ctx, envelope)
+
+			if t := envelope.Time(); t.After(next) {
+				next = t
+			}
+		}
+
+		if len(envelopes) < logStreamBackfillLimit {
+			return nil
+		}
+
+		// A full page of one timestamp would otherwise be read forever.
+		if !next.After(start) {
+			next = start.Add(time.Nanosecond)
+		}
+
+		start = next
+	}
+
+	return nil
+}
+
+// consume reads server-sent events until the stream ends. Only data events
+// carry envelopes; heartbeats are ignored and a closing event ends the
+// stream so that it is reopened.
+func (s *logStreamSession) consume(ctx context.Context, body io.Reader) error {
+	reader := bufio.NewReader(body)
+
+	var event, data strings.Builder
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return ErrLogStreamClosed
+			}
+
+			return fmt.Errorf("reading log stream: %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+
+			switch field {
+			case "event":
+				event.Reset()
+				event.WriteString(value)
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+
+				data.WriteString(value)
+			}
+
+			continue
+		}
+
+		switch event.String() {
+		case "heartbeat":
+		case "closing":
+			return ErrLogStreamClosed
+		default:
+			s.dispatch(ctx, data.String())
+		}
+
+		event.Reset()
+		data.Reset()
+	}
+}
+
+func (s *logStreamSession) dispatch(ctx context.Context, data string) {
+	if data == "" {
+		return
+	}
+
+	var batch struct {
+		Batch []capi.LogCacheEnvelope `json:"batch"`
+	}
+
+	err := json.Unmarshal([]byte(data), &batch)
+	if err != nil {
+		if s.opts.OnError != nil {
+			s.opts.OnError(fmt.Errorf("parsing log stream event: %w", err))
+		}
+
+		return
+	}
+
+	for _, envelope := range batch.Batch {
+		if _, ok := s.backfilled[envelopeKey(&envelope)]; ok {
+			continue
+		}
+
+		s.deliver(ctx, envelope)
+	}
+}
+
+func (s *logStreamSession) deliver(ctx context.Context, envelope capi.LogCacheEnvelope) {
+	if t := envelope.Time(); t.After(s.last) {
+		s.last = t
+	}
+
+	select {
+	case s.out <- envelope:
+	case <-ctx.Done():
+	}
+}
+
+// envelopeKey identifies an envelope by its source, instance, timestamp and
+// payload, so that distinct envelopes sharing a timestamp are told apart.
+func envelopeKey(envelope *capi.LogCacheEnvelope) string {
+	payload, _ := json.Marshal(struct {
+		Log     *capi.LogCacheLogEnvelope
+		Counter *capi.LogCacheCounterEnvelope
+		Gauge   *capi.LogCacheGaugeEnvelope
+		Timer   *capi.LogCacheTimerEnvelope
+		Event   *capi.LogCacheEventEnvelope
+	}{envelope.Log, envelope.Counter, envelope.Gauge, envelope.Timer, envelope.Event})
+
+	return envelope.SourceID + "/" + envelope.InstanceID + "/" + envelope.Timestamp + "/" + string(payload)
+}
+
+func randomShardID() string {
+	buf := make([]byte, logStreamShardIDBytes)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,275 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/fivetwenty-io/capi/v3/internal/client"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLogStreamServer advertises itself as both the RLP gateway and Log Cache
+// and serves /v2/read with the given handler.
+func newLogStreamServer(t *testing.T, stream http.HandlerFunc, logCache http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/":
+			_, _ = writer.Write([]byte(`{"links":{"log_stream":{"href":"` + server.URL + `"}}}`))
+		case "/v3/info":
+			_, _ = writer.Write([]byte(`{"links":{"log_cache":{"href":"` + server.URL + `"}}}`))
+		case "/v2/read":
+			writer.Header().Set("Content-Type", "text/event-stream")
+			stream(writer, request)
+		default:
+			if logCache == nil {
+				writer.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			logCache(writer, request)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func logEnvelopeJSON(timestamp int64, payload string) string {
+	return fmt.Sprintf(`{"timestamp":"%d","source_id":"app-guid","instance_id":"0","tags":{"source_type":"APP/PROC/WEB"},"log":{"payload":"%s","type":"OUT"}}`,
+		timestamp, payload)
+}
+
+func writeEvent(writer http.ResponseWriter, event, data string) {
+	if event != "" {
+		_, _ = fmt.Fprintf(writer, "event: %s\n", event)
+	}
+
+	_, _ = fmt.Fprintf(writer, "data: %s\n\n", data)
+	writer.(http.Flusher).Flush()
+}
+
+func receiveEnvelopes(t *testing.T, envelopes <-chan capi.LogCacheEnvelope, count int) []capi.LogCacheEnvelope {
+	t.Helper()
+
+	var received []capi.LogCacheEnvelope
+
+	for len(received) < count {
+		select {
+		case envelope, ok := <-envelopes:
+			require.True(t, ok, "stream closed early")
+
+			received = append(received, envelope)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for envelopes", "received %d of %d", len(received), count)
+		}
+	}
+
+	return received
+}
+
+func TestLogStreamClient_Stream(t *testing.T) {
+	t.Parallel()
+
+	server := newLogStreamServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		query := request.URL.Query()
+		assert.Contains(t, query, "log")
+		assert.Contains(t, query, "gauge")
+		assert.Equal(t, []string{"app-guid"}, query["source_id"])
+		assert.Equal(t, "consumers", query.Get("shard_id"))
+		assert.Equal(t, "text/event-stream", request.Header.Get("Accept"))
+
+		writeEvent(writer, "heartbeat", "1700000000")
+		writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(1700000000000000000, "aGVsbG8=")+`,{"timestamp":"1700000000000000001","source_id":"app-guid","instance_id":"0","gauge":{"metrics":{"cpu":{"unit":"percentage","value":3}}}}]}`)
+
+		<-request.Context().Done()
+	}, nil)
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	envelopes, err := client.LogStream().Stream(ctx, &capi.LogStreamOptions{
+		SourceIDs:     []string{"app-guid"},
+		EnvelopeTypes: []capi.LogCacheEnvelopeType{capi.LogCacheEnvelopeTypeLog, capi.LogCacheEnvelopeTypeGauge},
+		ShardID:       "consumers",
+	})
+	require.NoError(t, err)
+
+	received := receiveEnvelopes(t, envelopes, 2)
+	assert.Equal(t, "hello", string(received[0].Log.Payload))
+	assert.Equal(t, capi.LogCacheEnvelopeTypeGauge, received[1].Type())
+
+	// Canceling the context closes the channel.
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, open := <-envelopes
+
+		return !open
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestLogStreamClient_ResumesFromLogCache(t *testing.T) {
+	t.Parallel()
+
+	var connections atomic.Int32
+
+	server := newLogStreamServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		if connections.Add(1) == 1 {
+			writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(1000, "b25l")+`]}`)
+
+			return
+		}
+
+		// The envelope missed while disconnected arrives both from Log Cache
+		// and from the new stream; it must be delivered once. Another line
+		// with the same timestamp is not a repeat.
+		writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(2000, "dHdv")+`,`+logEnvelopeJSON(2000, "ZGV1eA==")+`,`+logEnvelopeJSON(3000, "dGhyZWU=")+`]}`)
+
+		<-request.Context().Done()
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/read/app-guid", request.URL.Path)
+		assert.Equal(t, "1001", request.URL.Query().Get("start_time"))
+
+		_, _ = writer.Write([]byte(`{"envelopes":{"batch":[` + logEnvelopeJSON(2000, "dHdv") + `]}}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reconnects atomic.Int32
+
+	envelopes, err := client.LogStream().Stream(ctx, &capi.LogStreamOptions{
+		SourceIDs:      []string{"app-guid"},
+		ReconnectDelay: time.Millisecond,
+		OnError: func(err error) {
+			assert.ErrorIs(t, err, ErrLogStreamClosed)
+			reconnects.Add(1)
+		},
+	})
+	require.NoError(t, err)
+
+	received := receiveEnvelopes(t, envelopes, 4)
+	assert.Equal(t, []string{"one", "two", "deux", "three"}, []string{
+		string(received[0].Log.Payload), string(received[1].Log.Payload), string(received[2].Log.Payload), string(received[3].Log.Payload),
+	})
+	assert.Equal(t, int32(1), reconnects.Load())
+}
+
+func TestLogStreamClient_ResumesAcrossLogCachePages(t *testing.T) {
+	t.Parallel()
+
+	var connections atomic.Int32
+
+	server := newLogStreamServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		if connections.Add(1) == 1 {
+			writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(1000, "b25l")+`]}`)
+
+			return
+		}
+
+		writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(5000, "bGFzdA==")+`]}`)
+
+		<-request.Context().Done()
+	}, func(writer http.ResponseWriter, request *http.Request) {
+		start, err := strconv.ParseInt(request.URL.Query().Get("start_time"), 10, 64)
+		assert.NoError(t, err)
+
+		// A full page of 1000 envelopes, then the rest of the gap starting at
+		// the newest timestamp of the first page.
+		var batch []string
+
+		switch start {
+		case 1001:
+			for timestamp := int64(1001); timestamp <= 2000; timestamp++ {
+				batch = append(batch, logEnvelopeJSON(timestamp, "Z2Fw"))
+			}
+		case 2000:
+			batch = append(batch, logEnvelopeJSON(2000, "Z2Fw"), logEnvelopeJSON(2001, "ZW5k"))
+		default:
+			assert.Failf(t, "unexpected start_time", "%d", start)
+		}
+
+		_, _ = writer.Write([]byte(`{"envelopes":{"batch":[` + strings.Join(batch, ",") + `]}}`))
+	})
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	envelopes, err := client.LogStream().Stream(ctx, &capi.LogStreamOptions{
+		SourceIDs:      []string{"app-guid"},
+		ReconnectDelay: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	received := receiveEnvelopes(t, envelopes, 1003)
+	assert.Equal(t, "one", string(received[0].Log.Payload))
+	assert.Equal(t, "1001", received[1].Timestamp)
+	assert.Equal(t, "2000", received[1000].Timestamp)
+	assert.Equal(t, "end", string(received[1001].Log.Payload))
+	assert.Equal(t, "last", string(received[1002].Log.Payload))
+}
+
+func TestLogStreamClient_Unavailable(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`{"links":{}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.LogStream().Stream(context.Background(), nil)
+	require.ErrorIs(t, err, capi.ErrLogStreamUnavailable)
+}
+
+func TestAppsClient_StreamLogsUsesGateway(t *testing.T) {
+	t.Parallel()
+
+	server := newLogStreamServer(t, func(writer http.ResponseWriter, request *http.Request) {
+		writeEvent(writer, "", `{"batch":[`+logEnvelopeJSON(1700000000000000000, "aGVsbG8=")+`]}`)
+
+		<-request.Context().Done()
+	}, nil)
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := client.Apps().StreamLogs(ctx, "app-guid")
+	require.NoError(t, err)
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, "hello", message.Message)
+		assert.Equal(t, "APP/PROC/WEB", message.SourceType)
+		assert.Equal(t, "app-guid", message.AppID)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for a log message")
+	}
+}
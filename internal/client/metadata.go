@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	internalhttp "github.com/fivetwenty-io/capi/v3/internal/http"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+)
+
+// MetadataClient implements capi.MetadataClient.
+type MetadataClient struct {
+	httpClient *internalhttp.Client
+}
+
+// NewMetadataClient creates a new metadata client.
+func NewMetadataClient(httpClient *internalhttp.Client) *MetadataClient {
+	return &MetadataClient{
+		httpClient: httpClient,
+	}
+}
+
+// metadataEnvelope decodes the metadata of any resource.
+type metadataEnvelope struct {
+	Metadata *capi.Metadata `json:"metadata"`
+}
+
+// Get implements capi.MetadataClient.Get.
+func (c *MetadataClient) Get(ctx context.Context, resourceType, guid string) (*capi.Metadata, error) {
+	path, err := capi.MetadataResourcePath(resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("getting metadata: %w", err)
+	}
+
+	resp, err := c.httpClient.Get(ctx, "/v3/"+path+"/"+guid, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s metadata: %w", resourceType, err)
+	}
+
+	return parseMetadata(resp.Body)
+}
+
+// Patch implements capi.MetadataClient.Patch. Some updates, such as those to
+// managed service instances, are asynchronous; their metadata is read back
+// once the request is accepted.
+func (c *MetadataClient) Patch(ctx context.Context, resourceType, guid string, patch capi.MetadataPatch) (*capi.Metadata, error) {
+	path, err := capi.MetadataResourcePath(resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("patching metadata: %w", err)
+	}
+
+	err = patch.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("patching %s metadata: %w", resourceType, err)
+	}
+
+	resp, err := c.httpClient.Patch(ctx, "/v3/"+path+"/"+guid, patch.Request())
+	if err != nil {
+		return nil, fmt.Errorf("patching %s metadata: %w", resourceType, err)
+	}
+
+	if resp.StatusCode == http.StatusAccepted || len(resp.Body) == 0 {
+		return c.Get(ctx, resourceType, guid)
+	}
+
+	return parseMetadata(resp.Body)
+}
+
+// List implements capi.MetadataClient.List.
+func (c *MetadataClient) List(ctx context.Context, resourceType string, params *capi.QueryParams) (*capi.ListResponse[capi.MetadataResource], error) {
+	path, err := capi.MetadataResourcePath(resourceType)
+	if err != nil {
+		return nil, fmt.Errorf("listing metadata: %w", err)
+	}
+
+	var queryParams url.Values
+	if params != nil {
+		queryParams = params.ToValues()
+	}
+
+	resp, err := c.httpClient.Get(ctx, "/v3/"+path, queryParams)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", path, err)
+	}
+
+	var list capi.ListResponse[capi.MetadataResource]
+
+	err = json.Unmarshal(resp.Body, &list)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s list: %w", path, err)
+	}
+
+	return &list, nil
+}
+
+func parseMetadata(body []byte) (*capi.Metadata, error) {
+	var envelope metadataEnvelope
+
+	err := json.Unmarshal(body, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("parsing metadata: %w", err)
+	}
+
+	metadata := envelope.Metadata
+	if metadata == nil {
+		metadata = &capi.Metadata{}
+	}
+
+	if metadata.Labels == nil {
+		metadata.Labels = map[string]string{}
+	}
+
+	if metadata.Annotations == nil {
+		metadata.Annotations = map[string]string{}
+	}
+
+	return metadata, nil
+}
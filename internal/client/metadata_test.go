@@ -0,0 +1,100 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/fivetwenty-io/capi/v3/internal/client"
+	internalhttp "github.com/fivetwenty-io/capi/v3/internal/http"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataClient_Patch(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/v3/routes/route-guid", request.URL.Path)
+		assert.Equal(t, "PATCH", request.Method)
+
+		body, err := io.ReadAll(request.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"metadata": {"labels": {"team": "web", "old": null}}}`, string(body))
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"guid": "route-guid", "metadata": {"labels": {"team": "web"}, "annotations": {}}}`))
+	}))
+	defer server.Close()
+
+	metadata := NewMetadataClient(internalhttp.NewClient(server.URL, nil))
+
+	result, err := metadata.Patch(context.Background(), "route", "route-guid", capi.MetadataPatch{
+		SetLabels:    map[string]string{"team": "web"},
+		RemoveLabels: []string{"old"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "web"}, result.Labels)
+	assert.Empty(t, result.Annotations)
+}
+
+func TestMetadataClient_PatchAsync(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/v3/service_instances/si-guid", request.URL.Path)
+
+		if request.Method == http.MethodPatch {
+			writer.Header().Set("Location", "/v3/jobs/job-guid")
+			writer.WriteHeader(http.StatusAccepted)
+
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"guid": "si-guid", "metadata": {"annotations": {"contact": "ops"}}}`))
+	}))
+	defer server.Close()
+
+	metadata := NewMetadataClient(internalhttp.NewClient(server.URL, nil))
+
+	result, err := metadata.Patch(context.Background(), "service_instances", "si-guid", capi.MetadataPatch{
+		SetAnnotations: map[string]string{"contact": "ops"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"contact": "ops"}, result.Annotations)
+	assert.NotNil(t, result.Labels)
+}
+
+func TestMetadataClient_List(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/v3/apps", request.URL.Path)
+		assert.Equal(t, "team=web", request.URL.Query().Get("label_selector"))
+
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(map[string]interface{}{
+			"pagination": map[string]interface{}{"total_results": 1},
+			"resources": []map[string]interface{}{
+				{"guid": "app-guid", "name": "api", "metadata": map[string]interface{}{"labels": map[string]string{"team": "web"}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	metadata := NewMetadataClient(internalhttp.NewClient(server.URL, nil))
+
+	list, err := metadata.List(context.Background(), "app", capi.NewQueryParams().WithLabelSelector("team=web"))
+	require.NoError(t, err)
+	require.Len(t, list.Resources, 1)
+	assert.Equal(t, "api", list.Resources[0].Name)
+	assert.Equal(t, "web", list.Resources[0].Metadata.Labels["team"])
+
+	_, err = metadata.Get(context.Background(), "quota", "guid")
+	require.ErrorIs(t, err, capi.ErrUnsupportedMetadataResource)
+}
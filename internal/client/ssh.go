@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	internalhttp "github.com/fivetwenty-io/capi/v3/internal/http"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrSSHAuthorizationUnavailable is returned when the API root advertises
+// neither a login nor a uaa link to request one-time codes from.
+var ErrSSHAuthorizationUnavailable = errors.New("UAA endpoint is not advertised by the API")
+
+// SSHClient implements the capi.SSHClient interface.
+type SSHClient struct {
+	httpClient *internalhttp.Client
+}
+
+// NewSSHClient creates a new SSHClient.
+func NewSSHClient(httpClient *internalhttp.Client) *SSHClient {
+	return &SSHClient{httpClient: httpClient}
+}
+
+// GetEndpoint implements capi.SSHClient.GetEndpoint.
+func (c *SSHClient) GetEndpoint(ctx context.Context) (*capi.SSHEndpoint, error) {
+	links, err := c.getLinks(ctx, "/")
+	if err != nil {
+		return nil, err
+	}
+
+	link, exists := links["app_ssh"]
+	if !exists || link.Href == "" {
+		// Some deployments only list app_ssh among the /v3/info links.
+		links, err = c.getLinks(ctx, "/v3/info")
+		if err != nil {
+			return nil, err
+		}
+
+		link, exists = links["app_ssh"]
+		if !exists || link.Href == "" {
+			return nil, capi.ErrSSHUnavailable
+		}
+	}
+
+	endpoint := &capi.SSHEndpoint{
+		Address:     link.Href,
+		OAuthClient: capi.DefaultSSHOAuthClient,
+	}
+
+	if fingerprint, ok := link.Meta["host_key_fingerprint"].(string); ok {
+		endpoint.HostKeyFingerprint = fingerprint
+	}
+
+	if oauthClient, ok := link.Meta["oauth_client"].(string); ok && oauthClient != "" {
+		endpoint.OAuthClient = oauthClient
+	}
+
+	return endpoint, nil
+}
+
+// GetCode implements capi.SSHClient.GetCode.
+func (c *SSHClient) GetCode(ctx context.Context) (string, error) {
+	endpoint, err := c.GetEndpoint(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return c.getCode(ctx, endpoint.OAuthClient)
+}
+
+// Dial implements capi.SSHClient.Dial.
+func (c *SSHClient) Dial(ctx context.Context, processGUID string, index int) (*ssh.Client, error) {
+	endpoint, err := c.GetEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := c.getCode(ctx, endpoint.OAuthClient)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:            capi.SSHUser(processGUID, index),
+		Auth:            []ssh.AuthMethod{ssh.Password(code)},
+		HostKeyCallback: capi.SSHHostKeyFingerprintCallback(endpoint.HostKeyFingerprint),
+		Timeout:         constants.DefaultHTTPTimeout,
+	}
+
+	dialer := &net.Dialer{Timeout: constants.DefaultHTTPTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint.Address)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to SSH proxy %s: %w", endpoint.Address, err)
+	}
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, endpoint.Address, config)
+	if err != nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("SSH handshake with %s: %w", endpoint.Address, err)
+	}
+
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// getCode asks UAA to authorize oauthClient on behalf of the current token
+// and reads the one-time code from the redirect it answers with.
+func (c *SSHClient) getCode(ctx context.Context, oauthClient string) (string, error) {
+	links, err := c.getLinks(ctx, "/")
+	if err != nil {
+		return "", err
+	}
+
+	authorizeURL := ""
+
+	for _, name := range []string{"login", "uaa"} {
+		if link, exists := links[name]; exists && link.Href != "" {
+			authorizeURL = strings.TrimSuffix(link.Href, "/") + "/oauth/authorize"
+
+			break
+		}
+	}
+
+	if authorizeURL == "" {
+		return "", ErrSSHAuthorizationUnavailable
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", oauthClient)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, authorizeURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("creating authorize request: %w", err)
+	}
+
+	token, err := c.httpClient.GetAuthToken(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting token for SSH authorization: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// The code is carried by the redirect itself, so it must not be
+	// followed.
+	client := &http.Client{
+		Timeout: constants.DefaultHTTPTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting SSH authorization code: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", fmt.Errorf("parsing authorize redirect: %w", err)
+	}
+
+	code := location.Query().Get("code")
+	if code == "" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, logCacheErrorBodyLimit))
+
+		return "", fmt.Errorf("%w: /oauth/authorize returned %d: %s", capi.ErrSSHCodeUnavailable, resp.StatusCode, body)
+	}
+
+	return code, nil
+}
+
+func (c *SSHClient) getLinks(ctx context.Context, path string) (capi.Links, error) {
+	resp, err := c.httpClient.Get(ctx, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", path, err)
+	}
+
+	var document struct {
+		Links capi.Links `json:"links"`
+	}
+
+	err = json.Unmarshal(resp.Body, &document)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s response: %w", path, err)
+	}
+
+	return document.Links, nil
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/fivetwenty-io/capi/v3/internal/client"
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHAPIServer serves an API root advertising sshAddress as the app_ssh
+// link, and a UAA that issues the one-time code "one-time-code".
+func newSSHAPIServer(t *testing.T, sshAddress, fingerprint string) *httptest.Server {
+	t.Helper()
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/":
+			_, _ = writer.Write([]byte(`{"links":{` +
+				`"login":{"href":"` + server.URL + `/login"},` +
+				`"app_ssh":{"href":"` + sshAddress + `","meta":{"host_key_fingerprint":"` + fingerprint + `","oauth_client":"ssh-proxy"}}}}`))
+		case "/login/oauth/authorize":
+			assert.Equal(t, "code", request.URL.Query().Get("response_type"))
+			assert.Equal(t, "ssh-proxy", request.URL.Query().Get("client_id"))
+			assert.Equal(t, "Bearer test-token", request.Header.Get("Authorization"))
+
+			writer.Header().Set("Location", server.URL+"/login?code=one-time-code")
+			writer.WriteHeader(http.StatusFound)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newSSHProxy starts an SSH server that accepts cf:process-guid/1 with the
+// password "one-time-code" and returns its address and fingerprint.
+func newSSHProxy(t *testing.T) (string, string) {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "cf:process-guid/1" && string(password) == "one-time-code" {
+				return &ssh.Permissions{}, nil
+			}
+
+			return nil, assert.AnError
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+
+				go ssh.DiscardRequests(requests)
+
+				for channel := range channels {
+					_ = channel.Reject(ssh.Prohibited, "no sessions in tests")
+				}
+			}()
+		}
+	}()
+
+	sum := sha256.Sum256(signer.PublicKey().Marshal())
+
+	return listener.Addr().String(), base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+func TestSSHClient_GetEndpoint(t *testing.T) {
+	t.Parallel()
+
+	server := newSSHAPIServer(t, "ssh.example.com:2222", "AAAA")
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL, AccessToken: "test-token"})
+	require.NoError(t, err)
+
+	endpoint, err := client.SSH().GetEndpoint(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, &capi.SSHEndpoint{
+		Address:            "ssh.example.com:2222",
+		HostKeyFingerprint: "AAAA",
+		OAuthClient:        "ssh-proxy",
+	}, endpoint)
+}
+
+func TestSSHClient_GetEndpointFallsBackToInfo(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/":
+			_, _ = writer.Write([]byte(`{"links":{}}`))
+		case "/v3/info":
+			_, _ = writer.Write([]byte(`{"links":{"app_ssh":{"href":"ssh.example.com:2222","meta":{"host_key_fingerprint":"AAAA"}}}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	endpoint, err := client.SSH().GetEndpoint(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, "ssh.example.com:2222", endpoint.Address)
+	assert.Equal(t, capi.DefaultSSHOAuthClient, endpoint.OAuthClient)
+}
+
+func TestSSHClient_GetEndpointUnavailable(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		_, _ = writer.Write([]byte(`{"links":{}}`))
+	}))
+	defer server.Close()
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL})
+	require.NoError(t, err)
+
+	_, err = client.SSH().GetEndpoint(context.Background())
+	require.ErrorIs(t, err, capi.ErrSSHUnavailable)
+}
+
+func TestSSHClient_GetCode(t *testing.T) {
+	t.Parallel()
+
+	server := newSSHAPIServer(t, "ssh.example.com:2222", "AAAA")
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL, AccessToken: "test-token"})
+	require.NoError(t, err)
+
+	code, err := client.SSH().GetCode(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "one-time-code", code)
+}
+
+func TestSSHClient_Dial(t *testing.T) {
+	t.Parallel()
+
+	address, fingerprint := newSSHProxy(t)
+	server := newSSHAPIServer(t, address, fingerprint)
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL, AccessToken: "test-token"})
+	require.NoError(t, err)
+
+	sshClient, err := client.SSH().Dial(context.Background(), "process-guid", 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, "cf:process-guid/1", sshClient.User())
+	require.NoError(t, sshClient.Close())
+}
+
+func TestSSHClient_DialRejectsUnexpectedHostKey(t *testing.T) {
+	t.Parallel()
+
+	address, _ := newSSHProxy(t)
+	server := newSSHAPIServer(t, address, "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+	client, err := New(context.Background(), &capi.Config{APIEndpoint: server.URL, AccessToken: "test-token"})
+	require.NoError(t, err)
+
+	_, err = client.SSH().Dial(context.Background(), "process-guid", 1)
+	require.ErrorIs(t, err, capi.ErrSSHHostKeyMismatch)
+}
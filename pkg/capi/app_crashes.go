@@ -0,0 +1,379 @@
+package capi
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AuditEventTypeAppProcessCrash is the audit event recorded when an app
+// instance crashes.
+const AuditEventTypeAppProcessCrash = "audit.app.process.crash"
+
+const (
+	defaultCrashLogWindow = 30 * time.Second
+	defaultCrashLogLines  = 20
+	// noExitStatus marks crashes whose exit status is unknown.
+	noExitStatus = -1
+)
+
+// exitStatusPattern finds the status in exit descriptions such as
+// "APP/PROC/WEB: Exited with status 137 (out of memory)".
+var exitStatusPattern = regexp.MustCompile(`(?i)status (\d+)`)
+
+// AppCrash is one crash of an app instance, decoded from an
+// audit.app.process.crash event.
+type AppCrash struct {
+	Time            time.Time `json:"time"                  yaml:"time"`
+	EventGUID       string    `json:"event_guid"            yaml:"event_guid"`
+	ProcessType     string    `json:"process_type"          yaml:"process_type"`
+	Index           int       `json:"index"                 yaml:"index"`
+	InstanceGUID    string    `json:"instance_guid"         yaml:"instance_guid"`
+	CellID          string    `json:"cell_id,omitempty"     yaml:"cell_id,omitempty"`
+	Reason          string    `json:"reason"                yaml:"reason"`
+	ExitDescription string    `json:"exit_description"      yaml:"exit_description"`
+	// ExitStatus is the process exit status, or -1 when unknown.
+	ExitStatus int `json:"exit_status" yaml:"exit_status"`
+	// CrashCount is the number of consecutive crashes of the instance
+	// reported by Diego.
+	CrashCount int `json:"crash_count,omitempty" yaml:"crash_count,omitempty"`
+	// Logs are the log lines of the instance around Time, oldest first.
+	Logs []LogMessage `json:"logs,omitempty" yaml:"logs,omitempty"`
+}
+
+// NewAppCrash decodes an audit.app.process.crash event. The crashed
+// process is the event's actor.
+func NewAppCrash(event *AuditEvent) AppCrash {
+	crash := AppCrash{
+		Time:            event.CreatedAt,
+		EventGUID:       event.GUID,
+		ProcessType:     event.Actor.Name,
+		Index:           eventDataInt(event.Data, "index", 0),
+		InstanceGUID:    eventDataString(event.Data, "instance"),
+		CellID:          eventDataString(event.Data, "cell_id"),
+		Reason:          eventDataString(event.Data, "reason"),
+		ExitDescription: eventDataString(event.Data, "exit_description"),
+		ExitStatus:      eventDataInt(event.Data, "exit_status", noExitStatus),
+		CrashCount:      eventDataInt(event.Data, "crash_count", 0),
+	}
+
+	if crash.ExitStatus == noExitStatus {
+		match := exitStatusPattern.FindStringSubmatch(crash.ExitDescription)
+		if match != nil {
+			crash.ExitStatus, _ = strconv.Atoi(match[1])
+		}
+	}
+
+	return crash
+}
+
+func eventDataString(data map[string]interface{}, key string) string {
+	value, ok := data[key]
+	if !ok || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// eventDataInt reads a number that may have been decoded from JSON as a
+// float64 or sent as a string.
+func eventDataInt(data map[string]interface{}, key string, fallback int) int {
+	switch value := data[key].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	case string:
+		number, err := strconv.Atoi(value)
+		if err == nil {
+			return number
+		}
+	}
+
+	return fallback
+}
+
+// AppCrashGroup collects the crashes that share a reason and exit status.
+type AppCrashGroup struct {
+	Reason     string `json:"reason"      yaml:"reason"`
+	ExitStatus int    `json:"exit_status" yaml:"exit_status"`
+	// ExitDescription is the description of the most recent crash.
+	ExitDescription string    `json:"exit_description" yaml:"exit_description"`
+	Count           int       `json:"count"            yaml:"count"`
+	ProcessTypes    []string  `json:"process_types"    yaml:"process_types"`
+	Indexes         []int     `json:"indexes"          yaml:"indexes"`
+	First           time.Time `json:"first"            yaml:"first"`
+	Last            time.Time `json:"last"             yaml:"last"`
+}
+
+// GroupAppCrashes groups crashes by reason and exit status, most frequent
+// first.
+func GroupAppCrashes(crashes []AppCrash) []AppCrashGroup {
+	type groupKey struct {
+		reason     string
+		exitStatus int
+	}
+
+	groups := map[groupKey]*AppCrashGroup{}
+
+	for _, crash := range crashes {
+		key := groupKey{reason: crash.Reason, exitStatus: crash.ExitStatus}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &AppCrashGroup{Reason: crash.Reason, ExitStatus: crash.ExitStatus, First: crash.Time, Last: crash.Time}
+			groups[key] = group
+		}
+
+		group.Count++
+
+		if !crash.Time.Before(group.Last) {
+			group.Last = crash.Time
+			group.ExitDescription = crash.ExitDescription
+		}
+
+		if crash.Time.Before(group.First) {
+			group.First = crash.Time
+		}
+
+		group.ProcessTypes = appendUnique(group.ProcessTypes, crash.ProcessType)
+		group.Indexes = appendUnique(group.Indexes, crash.Index)
+	}
+
+	result := make([]AppCrashGroup, 0, len(groups))
+
+	for _, group := range groups {
+		sort.Strings(group.ProcessTypes)
+		sort.Ints(group.Indexes)
+		result = append(result, *group)
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Count != result[b].Count {
+			return result[a].Count > result[b].Count
+		}
+
+		return result[a].Last.After(result[b].Last)
+	})
+
+	return result
+}
+
+func appendUnique[T comparable](values []T, value T) []T {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
+
+// AppInstanceState is the current state of one instance of a process.
+type AppInstanceState struct {
+	ProcessType string `json:"process_type" yaml:"process_type"`
+	ProcessGUID string `json:"process_guid" yaml:"process_guid"`
+	Index       int    `json:"index"        yaml:"index"`
+	State       string `json:"state"        yaml:"state"`
+	// Since is how long the instance has been in State.
+	Since time.Duration `json:"since" yaml:"since"`
+}
+
+// AppCrashOptions configures AnalyzeAppCrashes.
+type AppCrashOptions struct {
+	// Since restricts the analysis to crashes after this time. Zero means
+	// every crash event Cloud Controller still holds.
+	Since time.Time
+	// LogWindow is how far before and after each crash logs are read.
+	// Defaults to 30 seconds; a negative value skips reading logs.
+	LogWindow time.Duration
+	// LogLines caps the log lines kept per crash, the closest to the crash
+	// first. Defaults to 20.
+	LogLines int
+	// OnError, if set, receives errors that do not stop the analysis, such
+	// as Log Cache being unavailable.
+	OnError func(error)
+}
+
+// AppCrashReport is the result of AnalyzeAppCrashes.
+type AppCrashReport struct {
+	AppGUID string `json:"app_guid" yaml:"app_guid"`
+	// Crashes are ordered newest first.
+	Crashes   []AppCrash         `json:"crashes"   yaml:"crashes"`
+	Groups    []AppCrashGroup    `json:"groups"    yaml:"groups"`
+	Instances []AppInstanceState `json:"instances" yaml:"instances"`
+}
+
+// AnalyzeAppCrashes correlates an app's crash audit events with the current
+// state of its instances and the logs each crashed instance wrote around
+// the crash.
+func AnalyzeAppCrashes(ctx context.Context, client Client, appGUID string, opts AppCrashOptions) (*AppCrashReport, error) {
+	if opts.LogWindow == 0 {
+		opts.LogWindow = defaultCrashLogWindow
+	}
+
+	if opts.LogLines <= 0 {
+		opts.LogLines = defaultCrashLogLines
+	}
+
+	params := NewQueryParams().WithOrderBy("-created_at")
+	if !opts.Since.IsZero() {
+		params.WithFilter("created_ats[gt]", opts.Since.UTC().Format(time.RFC3339))
+	}
+
+	events, err := CollectAllPages(ctx, params, func(ctx context.Context, params *QueryParams) (*ListResponse[AuditEvent], error) {
+		return client.AuditEvents().List(ctx, params,
+			WithAuditEventTypes(AuditEventTypeAppProcessCrash), WithAuditEventTargetGUIDs(appGUID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list crash events: %w", err)
+	}
+
+	report := &AppCrashReport{AppGUID: appGUID, Crashes: make([]AppCrash, 0, len(events))}
+
+	for i := range events {
+		report.Crashes = append(report.Crashes, NewAppCrash(&events[i]))
+	}
+
+	sort.SliceStable(report.Crashes, func(a, b int) bool {
+		return report.Crashes[a].Time.After(report.Crashes[b].Time)
+	})
+
+	report.Groups = GroupAppCrashes(report.Crashes)
+
+	report.Instances, err = appInstanceStates(ctx, client, appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.LogWindow > 0 {
+		for i := range report.Crashes {
+			report.Crashes[i].Logs, err = crashLogs(ctx, client, appGUID, &report.Crashes[i], opts)
+			if err != nil && opts.OnError != nil {
+				opts.OnError(err)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func appInstanceStates(ctx context.Context, client Client, appGUID string) ([]AppInstanceState, error) {
+	processes, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Process], error) {
+		return client.Processes().List(ctx, params, WithProcessAppGUIDs(appGUID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	states := []AppInstanceState{}
+
+	for _, process := range processes {
+		if process.Instances == 0 {
+			continue
+		}
+
+		instances, err := client.Processes().ListInstances(ctx, process.GUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instances of the %s process: %w", process.Type, err)
+		}
+
+		for _, instance := range instances.Resources {
+			states = append(states, AppInstanceState{
+				ProcessType: process.Type,
+				ProcessGUID: process.GUID,
+				Index:       instance.Index,
+				State:       instance.State,
+				Since:       time.Duration(instance.Since) * time.Second,
+			})
+		}
+	}
+
+	sort.Slice(states, func(a, b int) bool {
+		if states[a].ProcessType != states[b].ProcessType {
+			return states[a].ProcessType < states[b].ProcessType
+		}
+
+		return states[a].Index < states[b].Index
+	})
+
+	return states, nil
+}
+
+// crashLogs reads the logs the crashed instance wrote within LogWindow of
+// the crash and keeps the LogLines closest to it.
+func crashLogs(ctx context.Context, client Client, appGUID string, crash *AppCrash, opts AppCrashOptions) ([]LogMessage, error) {
+	envelopes, err := client.LogCache().Read(ctx, appGUID, &LogCacheReadOptions{
+		EnvelopeTypes: []LogCacheEnvelopeType{LogCacheEnvelopeTypeLog},
+		Start:         crash.Time.Add(-opts.LogWindow),
+		End:           crash.Time.Add(opts.LogWindow),
+		Limit:         logTailReplayLimit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs around the crash at %s: %w", crash.Time.Format(time.RFC3339), err)
+	}
+
+	index := strconv.Itoa(crash.Index)
+	messages := []LogMessage{}
+
+	for i := range envelopes {
+		if envelopes[i].InstanceID != index {
+			continue
+		}
+
+		// Instance indexes repeat across processes, so lines of another
+		// process with the same index are skipped.
+		processType := envelopeProcessType(&envelopes[i])
+		if crash.ProcessType != "" && processType != "" && !strings.EqualFold(processType, crash.ProcessType) {
+			continue
+		}
+
+		message, ok := LogMessageFromEnvelope(&envelopes[i])
+		if ok {
+			messages = append(messages, message)
+		}
+	}
+
+	if len(messages) > opts.LogLines {
+		sort.SliceStable(messages, func(a, b int) bool {
+			return absDuration(messages[a].Timestamp.Sub(crash.Time)) < absDuration(messages[b].Timestamp.Sub(crash.Time))
+		})
+
+		messages = messages[:opts.LogLines]
+	}
+
+	sort.SliceStable(messages, func(a, b int) bool {
+		return messages[a].Timestamp.Before(messages[b].Timestamp)
+	})
+
+	return messages, nil
+}
+
+// envelopeProcessType returns the process type an envelope was logged by,
+// from its process_type tag or else its APP/PROC/<TYPE> source type, or ""
+// when it carries neither.
+func envelopeProcessType(envelope *LogCacheEnvelope) string {
+	if processType := envelope.Tags["process_type"]; processType != "" {
+		return processType
+	}
+
+	sourceType, ok := strings.CutPrefix(envelope.Tags["source_type"], "APP/PROC/")
+	if !ok {
+		return ""
+	}
+
+	return sourceType
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
@@ -0,0 +1,236 @@
+package capi_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errLogCacheDown = errors.New("log cache down")
+
+var crashTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) //nolint:gochecknoglobals // shared fixture time
+
+type crashAuditEvents struct {
+	capi.AuditEventsClient
+
+	events []capi.AuditEvent
+	query  string
+}
+
+func (s *crashAuditEvents) List(_ context.Context, params *capi.QueryParams, opts ...capi.AuditEventListOption) (*capi.ListResponse[capi.AuditEvent], error) {
+	s.query = capi.ApplyQueryOptions(params.ToValues(), opts).Encode()
+
+	return &capi.ListResponse[capi.AuditEvent]{Resources: s.events}, nil
+}
+
+type crashProcesses struct {
+	capi.ProcessesClient
+
+	processes []capi.Process
+	instances map[string][]capi.ProcessInstance
+}
+
+func (s *crashProcesses) List(context.Context, *capi.QueryParams, ...capi.ProcessListOption) (*capi.ListResponse[capi.Process], error) {
+	return &capi.ListResponse[capi.Process]{Resources: s.processes}, nil
+}
+
+func (s *crashProcesses) ListInstances(_ context.Context, guid string) (*capi.ListResponse[capi.ProcessInstance], error) {
+	return &capi.ListResponse[capi.ProcessInstance]{Resources: s.instances[guid]}, nil
+}
+
+type crashLogCache struct {
+	capi.LogCacheClient
+
+	envelopes []capi.LogCacheEnvelope
+	err       error
+	reads     []*capi.LogCacheReadOptions
+}
+
+func (s *crashLogCache) Read(_ context.Context, _ string, opts *capi.LogCacheReadOptions) ([]capi.LogCacheEnvelope, error) {
+	s.reads = append(s.reads, opts)
+
+	return s.envelopes, s.err
+}
+
+func crashEvent(guid string, at time.Time, data map[string]interface{}) capi.AuditEvent {
+	return capi.AuditEvent{
+		Resource: capi.Resource{GUID: guid, CreatedAt: at},
+		Type:     capi.AuditEventTypeAppProcessCrash,
+		Actor:    capi.AuditEventActor{GUID: "web-guid", Type: "process", Name: "web"},
+		Target:   capi.AuditEventTarget{GUID: "app-guid", Type: "app", Name: "api"},
+		Data:     data,
+	}
+}
+
+func logEnvelope(at time.Time, index int, message string) capi.LogCacheEnvelope {
+	return capi.LogCacheEnvelope{
+		Timestamp:  strconv.FormatInt(at.UnixNano(), 10),
+		SourceID:   "app-guid",
+		InstanceID: strconv.Itoa(index),
+		Tags:       map[string]string{"source_type": "APP/PROC/WEB"},
+		Log:        &capi.LogCacheLogEnvelope{Payload: []byte(message), Type: "OUT"},
+	}
+}
+
+// processLogEnvelope is a log line of another process, identified by its
+// process_type tag or only by its source type.
+func processLogEnvelope(at time.Time, index int, processType, sourceType, message string) capi.LogCacheEnvelope {
+	envelope := logEnvelope(at, index, message)
+	envelope.Tags = map[string]string{"process_type": processType, "source_type": sourceType}
+
+	return envelope
+}
+
+func TestNewAppCrash(t *testing.T) {
+	t.Parallel()
+
+	event := crashEvent("event-1", crashTime, map[string]interface{}{
+		"index":            float64(2),
+		"instance":         "instance-guid",
+		"cell_id":          "cell-1",
+		"reason":           "CRASHED",
+		"exit_description": "APP/PROC/WEB: Exited with status 137 (out of memory)",
+		"crash_count":      float64(3),
+	})
+
+	crash := capi.NewAppCrash(&event)
+
+	assert.Equal(t, crashTime, crash.Time)
+	assert.Equal(t, "web", crash.ProcessType)
+	assert.Equal(t, 2, crash.Index)
+	assert.Equal(t, "instance-guid", crash.InstanceGUID)
+	assert.Equal(t, "cell-1", crash.CellID)
+	assert.Equal(t, "CRASHED", crash.Reason)
+	assert.Equal(t, 137, crash.ExitStatus)
+	assert.Equal(t, 3, crash.CrashCount)
+
+	event = crashEvent("event-2", crashTime, map[string]interface{}{"exit_description": "failed to start"})
+	assert.Equal(t, -1, capi.NewAppCrash(&event).ExitStatus)
+
+	event = crashEvent("event-3", crashTime, map[string]interface{}{"exit_status": "1", "exit_description": "status 2"})
+	assert.Equal(t, 1, capi.NewAppCrash(&event).ExitStatus)
+}
+
+func TestGroupAppCrashes(t *testing.T) {
+	t.Parallel()
+
+	crashes := []capi.AppCrash{
+		{Time: crashTime, Reason: "CRASHED", ExitStatus: 137, Index: 1, ProcessType: "web", ExitDescription: "oom old"},
+		{Time: crashTime.Add(time.Hour), Reason: "CRASHED", ExitStatus: 137, Index: 0, ProcessType: "web", ExitDescription: "oom new"},
+		{Time: crashTime.Add(time.Minute), Reason: "CRASHED", ExitStatus: 137, Index: 1, ProcessType: "worker"},
+		{Time: crashTime.Add(2 * time.Hour), Reason: "CRASHED", ExitStatus: 1, Index: 0, ProcessType: "web"},
+	}
+
+	groups := capi.GroupAppCrashes(crashes)
+
+	require.Len(t, groups, 2)
+	assert.Equal(t, 137, groups[0].ExitStatus)
+	assert.Equal(t, 3, groups[0].Count)
+	assert.Equal(t, []int{0, 1}, groups[0].Indexes)
+	assert.Equal(t, []string{"web", "worker"}, groups[0].ProcessTypes)
+	assert.Equal(t, crashTime, groups[0].First)
+	assert.Equal(t, crashTime.Add(time.Hour), groups[0].Last)
+	assert.Equal(t, "oom new", groups[0].ExitDescription)
+	assert.Equal(t, 1, groups[1].ExitStatus)
+	assert.Equal(t, 1, groups[1].Count)
+}
+
+func TestAnalyzeAppCrashes(t *testing.T) {
+	t.Parallel()
+
+	events := &crashAuditEvents{events: []capi.AuditEvent{
+		crashEvent("older", crashTime.Add(-time.Hour), map[string]interface{}{"index": float64(0), "reason": "CRASHED", "exit_description": "Exited with status 1"}),
+		crashEvent("newer", crashTime, map[string]interface{}{"index": float64(1), "reason": "CRASHED", "exit_description": "Exited with status 1"}),
+	}}
+	logCache := &crashLogCache{envelopes: []capi.LogCacheEnvelope{
+		logEnvelope(crashTime.Add(-20*time.Second), 1, "far before"),
+		logEnvelope(crashTime.Add(-2*time.Second), 1, "panic: boom"),
+		logEnvelope(crashTime.Add(-time.Second), 0, "other instance"),
+		processLogEnvelope(crashTime.Add(-time.Second), 1, "worker", "", "worker instance 1"),
+		processLogEnvelope(crashTime.Add(-time.Second), 1, "", "APP/PROC/WORKER", "untagged worker instance 1"),
+		logEnvelope(crashTime.Add(time.Second), 1, "restarting"),
+	}}
+	client := &stubClient{
+		auditEvents: events,
+		logCache:    logCache,
+		processes: &crashProcesses{
+			processes: []capi.Process{{Resource: capi.Resource{GUID: "web-guid"}, Type: "web", Instances: 2}},
+			instances: map[string][]capi.ProcessInstance{"web-guid": {
+				{Index: 1, State: "CRASHED", Since: 30},
+				{Index: 0, State: "RUNNING", Since: 3600},
+			}},
+		},
+	}
+
+	report, err := capi.AnalyzeAppCrashes(context.Background(), client, "app-guid", capi.AppCrashOptions{
+		Since:    crashTime.Add(-24 * time.Hour),
+		LogLines: 2,
+	})
+	require.NoError(t, err)
+
+	assert.Contains(t, events.query, "types=audit.app.process.crash")
+	assert.Contains(t, events.query, "target_guids=app-guid")
+	assert.Contains(t, events.query, "created_ats%5Bgt%5D=2024-04-30T12%3A00%3A00Z")
+
+	require.Len(t, report.Crashes, 2)
+	assert.Equal(t, "newer", report.Crashes[0].EventGUID)
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, 2, report.Groups[0].Count)
+
+	require.Len(t, report.Instances, 2)
+	assert.Equal(t, 0, report.Instances[0].Index)
+	assert.Equal(t, "CRASHED", report.Instances[1].State)
+	assert.Equal(t, 30*time.Second, report.Instances[1].Since)
+
+	// Only the web process's instance 1 lines, the two closest to the crash,
+	// oldest first.
+	logs := report.Crashes[0].Logs
+	require.Len(t, logs, 2)
+	assert.Equal(t, "panic: boom", logs[0].Message)
+	assert.Equal(t, "restarting", logs[1].Message)
+
+	require.Len(t, logCache.reads, 2)
+	assert.Equal(t, crashTime.Add(-30*time.Second), logCache.reads[0].Start)
+	assert.Equal(t, crashTime.Add(30*time.Second), logCache.reads[0].End)
+}
+
+func TestAnalyzeAppCrashes_LogCacheErrorsAreReported(t *testing.T) {
+	t.Parallel()
+
+	client := &stubClient{
+		auditEvents: &crashAuditEvents{events: []capi.AuditEvent{crashEvent("event", crashTime, nil)}},
+		logCache:    &crashLogCache{err: errLogCacheDown},
+		processes:   &crashProcesses{},
+	}
+
+	var reported []error
+
+	report, err := capi.AnalyzeAppCrashes(context.Background(), client, "app-guid", capi.AppCrashOptions{
+		OnError: func(err error) { reported = append(reported, err) },
+	})
+	require.NoError(t, err)
+
+	require.Len(t, report.Crashes, 1)
+	assert.Empty(t, report.Crashes[0].Logs)
+	require.Len(t, reported, 1)
+	require.ErrorIs(t, reported[0], errLogCacheDown)
+}
+
+func TestAnalyzeAppCrashes_NegativeLogWindowSkipsLogs(t *testing.T) {
+	t.Parallel()
+
+	client := &stubClient{
+		auditEvents: &crashAuditEvents{events: []capi.AuditEvent{crashEvent("event", crashTime, nil)}},
+		processes:   &crashProcesses{},
+	}
+
+	report, err := capi.AnalyzeAppCrashes(context.Background(), client, "app-guid", capi.AppCrashOptions{LogWindow: -1})
+	require.NoError(t, err)
+	assert.Len(t, report.Crashes, 1)
+}
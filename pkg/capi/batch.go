@@ -160,9 +160,13 @@ func createCRUDOperationConfig[TCreateRequest, TUpdateRequest, TResponse any](
 type BatchOperation struct {
 	ID       string
 	Type     string // "create", "update", "delete", "get"
-	Resource string // "app", "space", "org", etc.
+	Resource string // "app", "space", "organization", "route", etc.; see ParseBatchOperations
 	Data     interface{}
-	Callback func(result *BatchResult)
+	// DependsOn lists the IDs of operations that must succeed before this
+	// one runs. Operations referenced from Data with Ref are added
+	// implicitly.
+	DependsOn []string
+	Callback  func(result *BatchResult)
 }
 
 // BatchResult represents the result of a batch operation.
@@ -172,6 +176,14 @@ type BatchResult struct {
 	Data     interface{}
 	Error    error
 	Duration time.Duration
+	// Skipped is set when the operation did not run because a dependency
+	// failed or was skipped; Error wraps ErrBatchDependencyFailed.
+	Skipped bool
+	// Level is the operation's depth in the dependency graph: 0 without
+	// dependencies, otherwise one more than its deepest dependency.
+	Level int
+	// StartedAt is when the operation started running, zero if skipped.
+	StartedAt time.Time
 }
 
 // BatchExecutor executes batch operations.
@@ -199,9 +211,25 @@ func (b *BatchExecutor) SetTimeout(timeout time.Duration) {
 	b.timeout = timeout
 }
 
-// Execute runs a batch of operations.
+// Execute runs a batch of operations. Operations answered with a CF job
+// only succeed once the job completed. Each operation starts once all of
+// its dependencies succeeded, and at most concurrency operations run at a
+// time; dependents of a failed or skipped operation are skipped. Results are
+// returned in the order of operations. An error is returned, before anything
+// runs, only when the dependencies are invalid: unknown or ambiguous IDs, or
+// a cycle.
 func (b *BatchExecutor) Execute(ctx context.Context, operations []BatchOperation) ([]BatchResult, error) {
+	graph, err := newBatchGraph(operations)
+	if err != nil {
+		return nil, err
+	}
+
 	results := make([]BatchResult, len(operations))
+	finished := make([]chan struct{}, len(operations))
+
+	for index := range operations {
+		finished[index] = make(chan struct{})
+	}
 
 	var waitGroup sync.WaitGroup
 
@@ -212,19 +240,10 @@ func (b *BatchExecutor) Execute(ctx context.Context, operations []BatchOperation
 
 		go func(index int, operation BatchOperation) {
 			defer waitGroup.Done()
+			defer close(finished[index])
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-
-			defer func() { <-semaphore }()
-
-			// Execute operation with timeout
-			opCtx, cancel := context.WithTimeout(ctx, b.timeout)
-			defer cancel()
-
-			start := time.Now()
-			result := b.executeOperation(opCtx, operation)
-			result.Duration = time.Since(start)
+			result := b.runScheduled(ctx, operation, graph.dependencies[index], results, finished, semaphore)
+			result.Level = graph.levels[index]
 			results[index] = *result
 
 			// Call callback if provided
@@ -239,6 +258,85 @@ func (b *BatchExecutor) Execute(ctx context.Context, operations []BatchOperation
 	return results, nil
 }
 
+// runScheduled waits for the dependencies of operation, then runs it unless
+// one of them did not succeed.
+func (b *BatchExecutor) runScheduled(
+	ctx context.Context,
+	operation BatchOperation,
+	dependencies []int,
+	results []BatchResult,
+	finished []chan struct{},
+	semaphore chan struct{},
+) *BatchResult {
+	dependencyResults := make(map[string]*BatchResult, len(dependencies))
+
+	for _, dependency := range dependencies {
+		<-finished[dependency]
+
+		if !results[dependency].Success {
+			return &BatchResult{
+				ID:      operation.ID,
+				Skipped: true,
+				Error:   fmt.Errorf("%w: %s", ErrBatchDependencyFailed, results[dependency].ID),
+			}
+		}
+
+		dependencyResults[results[dependency].ID] = &results[dependency]
+	}
+
+	// Acquire semaphore
+	semaphore <- struct{}{}
+
+	defer func() { <-semaphore }()
+
+	start := time.Now()
+
+	if len(dependencies) > 0 {
+		data, err := resolveBatchRefs(operation.Data, dependencyResults)
+		if err != nil {
+			return &BatchResult{ID: operation.ID, Error: err, StartedAt: start, Duration: time.Since(start)}
+		}
+
+		operation.Data = data
+	}
+
+	// Execute operation with timeout
+	opCtx, cancel := context.WithTimeout(ctx, b.timeout)
+	defer cancel()
+
+	result := b.executeOperation(opCtx, operation)
+	if result.Success {
+		b.awaitJob(ctx, result)
+	}
+
+	result.StartedAt = start
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// awaitJob waits for the job an operation was accepted with, such as an
+// organization delete or a managed service instance create, so that the
+// operation only succeeds, and its dependents only start, once the job
+// completed. The job polling is bounded by the jobs client, not by the
+// executor's per-request timeout.
+func (b *BatchExecutor) awaitJob(ctx context.Context, result *BatchResult) {
+	job, ok := result.Data.(*Job)
+	if !ok || job == nil || job.GUID == "" {
+		return
+	}
+
+	completed, err := b.client.Jobs().PollUntilComplete(ctx, job.GUID)
+	if completed != nil {
+		result.Data = completed
+	}
+
+	if err != nil {
+		result.Success = false
+		result.Error = fmt.Errorf("job %s did not complete: %w", job.GUID, err)
+	}
+}
+
 // executeGenericCrudOperation handles generic CRUD operations using the provided configuration.
 func (b *BatchExecutor) executeGenericCrudOperation(ctx context.Context, operation BatchOperation, config CRUDOperationConfig) *BatchResult {
 	return handleCrudOperation(operation,
@@ -341,6 +439,34 @@ func (b *BatchExecutor) executeOperation(ctx context.Context, operation BatchOpe
 		result = b.executeRouteOperation(ctx, operation)
 	case "service_instance":
 		result = b.executeServiceInstanceOperation(ctx, operation)
+	case "security_group":
+		result = b.executeGenericCrudOperation(ctx, operation, b.createSecurityGroupOperationConfig())
+	case "organization_quota":
+		result = b.executeGenericCrudOperation(ctx, operation, b.createOrganizationQuotaOperationConfig())
+	case "space_quota":
+		result = b.executeGenericCrudOperation(ctx, operation, b.createSpaceQuotaOperationConfig())
+	case "domain":
+		result = b.executeGenericCrudOperation(ctx, operation, b.createDomainOperationConfig())
+	case "role":
+		result = b.executeRoleOperation(ctx, operation)
+	case "service_credential_binding":
+		result = b.executeServiceCredentialBindingOperation(ctx, operation)
+	case "route_destination":
+		result = b.executeRouteDestinationOperation(ctx, operation)
+	case "security_group_binding":
+		result = b.executeSecurityGroupBindingOperation(ctx, operation)
+	case "metadata":
+		result = b.executeMetadataOperation(ctx, operation)
+	case "feature_flag":
+		result = b.executeFeatureFlagOperation(ctx, operation)
+	case "environment_variable_group":
+		result = b.executeEnvironmentVariableGroupOperation(ctx, operation)
+	case "isolation_segment_entitlement":
+		result = b.executeIsolationSegmentEntitlementOperation(ctx, operation)
+	case "organization_quota_assignment":
+		result = b.executeOrganizationQuotaAssignmentOperation(ctx, operation)
+	case "space_quota_assignment":
+		result = b.executeSpaceQuotaAssignmentOperation(ctx, operation)
 	default:
 		result.Success = false
 		result.Error = fmt.Errorf("%w: %s", ErrUnsupportedResourceType, operation.Resource)
@@ -559,6 +685,17 @@ func (b *BatchBuilder) AddOperation(operation BatchOperation) *BatchBuilder {
 	return b
 }
 
+// DependsOn makes the most recently added operation wait for the
+// operations with the given IDs.
+func (b *BatchBuilder) DependsOn(ids ...string) *BatchBuilder {
+	if len(b.operations) > 0 {
+		last := &b.operations[len(b.operations)-1]
+		last.DependsOn = append(last.DependsOn, ids...)
+	}
+
+	return b
+}
+
 // Build returns the built operations.
 func (b *BatchBuilder) Build() []BatchOperation {
 	return b.operations
@@ -566,19 +703,26 @@ func (b *BatchBuilder) Build() []BatchOperation {
 
 // BatchTransaction represents a transactional batch of operations.
 type BatchTransaction struct {
-	operations []BatchOperation
-	results    []BatchResult
-	executor   *BatchExecutor
-	rollback   bool
+	operations   []BatchOperation
+	results      []BatchResult
+	executor     *BatchExecutor
+	rollback     bool
+	journalStore BatchJournalStore
+	journalID    string
+	journal      *BatchJournal
 }
 
 // NewBatchTransaction creates a new batch transaction.
 //
-// Rollback is disabled by default: it deletes resources created by the
-// transaction's successful operations, which is destructive and cannot
-// recreate resources removed or mutated by "delete"/"update" operations.
-// Opt in with SetRollback(true) when every operation is a "create" and
-// automatic cleanup of partial work is desired.
+// Rollback is disabled by default because it is destructive: resources
+// created by the transaction are deleted, resources it updated are PATCHed
+// back to the state snapshotted before the transaction ran, and resources it
+// deleted are recreated from their snapshot where the API allows it (spaces,
+// routes with their destinations, security groups and quotas); labels and
+// annotations an update added are removed. Apps, organizations and service
+// instances cannot be recreated, which is reported in ErrRollbackIncomplete.
+// Opt in with SetRollback(true), and use SetJournal to persist the progress
+// so an interrupted rollback can be resumed.
 func NewBatchTransaction(executor *BatchExecutor) *BatchTransaction {
 	return &BatchTransaction{
 		executor:   executor,
@@ -601,11 +745,47 @@ func (t *BatchTransaction) SetRollback(rollback bool) *BatchTransaction {
 	return t
 }
 
-// Execute executes the transaction.
+// SetJournal persists the transaction's journal in store under id. The
+// journal is saved after the snapshots are taken, after the operations ran
+// and after every rollback step.
+func (t *BatchTransaction) SetJournal(store BatchJournalStore, id string) *BatchTransaction {
+	t.journalStore = store
+	t.journalID = id
+
+	return t
+}
+
+// Journal returns the journal of the last execution or resumed rollback, or
+// nil when rollback is disabled.
+func (t *BatchTransaction) Journal() *BatchJournal {
+	return t.journal
+}
+
+// Execute executes the transaction. With rollback enabled, the resources
+// targeted by updates and deletes are snapshotted first; a snapshot that
+// fails aborts the transaction before any operation runs.
 func (t *BatchTransaction) Execute(ctx context.Context) ([]BatchResult, error) {
+	t.journal = nil
+
+	if t.rollback {
+		err := t.snapshotOperations(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	results, err := t.executor.Execute(ctx, t.operations)
 	t.results = results
 
+	if t.journal != nil {
+		t.journal.record(results, err)
+
+		saveErr := t.saveJournal()
+		if saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}
+
 	// Check for failures
 	var failedOps []string
 
@@ -620,7 +800,7 @@ func (t *BatchTransaction) Execute(ctx context.Context) ([]BatchResult, error) {
 		failed := fmt.Errorf("%w, %d operations failed: %v", ErrTransactionFailed, len(failedOps), failedOps)
 
 		// Attempt to undo the successful operations.
-		rollbackErr := t.performRollback(ctx)
+		rollbackErr := t.rollbackJournal(ctx)
 		if rollbackErr != nil {
 			return results, fmt.Errorf("%w; %w", failed, rollbackErr)
 		}
@@ -631,64 +811,22 @@ func (t *BatchTransaction) Execute(ctx context.Context) ([]BatchResult, error) {
 	return results, err
 }
 
-// performRollback undoes the successful operations of a failed transaction by
-// deleting the resources they created, in reverse order so dependent
-// resources are removed before their dependencies. Only "create" operations
-// have a well-defined inverse: "delete" and "update" operations cannot be
-// reversed automatically (the prior state is not retained) and are reported
-// for manual intervention. It returns ErrRollbackIncomplete describing any
-// operation that could not be reversed and any delete that failed, or nil when
-// every successful operation was undone.
-func (t *BatchTransaction) performRollback(ctx context.Context) error {
-	var (
-		rollbackOps  []BatchOperation
-		irreversible []string
-	)
-
-	for i := len(t.results) - 1; i >= 0; i-- {
-		if !t.results[i].Success {
-			continue
-		}
-
-		original := t.operations[i]
-
-		switch original.Type {
-		case "create":
-			guid, ok := guidFromResult(t.results[i].Data)
-			if !ok {
-				irreversible = append(irreversible,
-					fmt.Sprintf("%s (created %s, no GUID in result)", original.ID, original.Resource))
-
-				continue
-			}
-
-			rollbackOps = append(rollbackOps, BatchOperation{
-				ID:       "rollback_" + original.ID,
-				Type:     "delete",
-				Resource: original.Resource,
-				Data:     guid,
-			})
-		case "delete", "update":
-			irreversible = append(irreversible,
-				fmt.Sprintf("%s (%s %s cannot be auto-reversed)", original.ID, original.Type, original.Resource))
-		}
+// ResumeRollback loads the journal id from the transaction's store and
+// finishes its rollback: operations not yet undone, or whose undo failed,
+// are undone now. It returns ErrRollbackIncomplete when some still cannot
+// be.
+func (t *BatchTransaction) ResumeRollback(ctx context.Context, id string) error {
+	if t.journalStore == nil {
+		return ErrBatchJournalNotSet
 	}
 
-	var rollbackFailures []string
-
-	if len(rollbackOps) > 0 {
-		rollbackResults, _ := t.executor.Execute(ctx, rollbackOps)
-		for _, result := range rollbackResults {
-			if !result.Success {
-				rollbackFailures = append(rollbackFailures, fmt.Sprintf("%s: %v", result.ID, result.Error))
-			}
-		}
+	journal, err := t.journalStore.Load(id)
+	if err != nil {
+		return fmt.Errorf("failed to load batch journal: %w", err)
 	}
 
-	if len(irreversible) == 0 && len(rollbackFailures) == 0 {
-		return nil
-	}
+	t.journal = journal
+	t.journalID = id
 
-	return fmt.Errorf("%w: irreversible operations %v; failed deletes %v",
-		ErrRollbackIncomplete, irreversible, rollbackFailures)
+	return t.rollbackJournal(ctx)
 }
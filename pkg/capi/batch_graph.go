@@ -0,0 +1,372 @@
+package capi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrBatchUnknownDependency   = errors.New("batch operation depends on an unknown operation")
+	ErrBatchAmbiguousDependency = errors.New("batch operation depends on an ID shared by several operations")
+	ErrBatchDependencyCycle     = errors.New("batch operations have a dependency cycle")
+	ErrBatchDependencyFailed    = errors.New("skipped because a dependency did not succeed")
+	ErrBatchUnresolvedRef       = errors.New("batch reference cannot be resolved")
+)
+
+// batchRefPattern matches the placeholders produced by BatchRef:
+// ${ref:<operation id>.<field path>}.
+var batchRefPattern = regexp.MustCompile(`\$\{ref:([^}]+)\.([A-Za-z_][A-Za-z0-9_.]*)\}`)
+
+// BatchRef refers to the result of another operation of the same batch.
+// Its methods return placeholders that can be stored in any string field of
+// an operation's Data; the executor replaces them with the referenced value
+// just before the operation runs, and makes the operation depend on the
+// referenced one.
+//
+//	builder.
+//		AddCreateOrganization("org1", &capi.OrganizationCreateRequest{Name: "team"}).
+//		AddCreateSpace("space1", &capi.SpaceCreateRequest{
+//			Name: "dev",
+//			Relationships: capi.SpaceRelationships{Organization: capi.Relationship{
+//				Data: &capi.RelationshipData{GUID: capi.Ref("org1").GUID()},
+//			}},
+//		})
+type BatchRef struct {
+	id string
+}
+
+// Ref refers to the result of the operation with the given ID.
+func Ref(id string) BatchRef {
+	return BatchRef{id: id}
+}
+
+// GUID is a placeholder for the GUID of the referenced operation's result.
+func (r BatchRef) GUID() string {
+	return r.Field("GUID")
+}
+
+// Field is a placeholder for a field of the referenced operation's result,
+// given as a dot-separated path of Go field names such as "Name" or
+// "Relationships.Space.Data.GUID".
+func (r BatchRef) Field(path string) string {
+	return "${ref:" + r.id + "." + path + "}"
+}
+
+// batchRefIDs returns the IDs of the operations referenced anywhere in data.
+func batchRefIDs(data interface{}) []string {
+	var ids []string
+
+	walkBatchStrings(reflect.ValueOf(data), func(value string) {
+		for _, match := range batchRefPattern.FindAllStringSubmatch(value, -1) {
+			ids = append(ids, match[1])
+		}
+	})
+
+	return ids
+}
+
+func walkBatchStrings(value reflect.Value, visit func(string)) {
+	switch value.Kind() {
+	case reflect.String:
+		visit(value.String())
+	case reflect.Pointer, reflect.Interface:
+		if !value.IsNil() {
+			walkBatchStrings(value.Elem(), visit)
+		}
+	case reflect.Struct:
+		for i := range value.NumField() {
+			if value.Type().Field(i).IsExported() {
+				walkBatchStrings(value.Field(i), visit)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range value.Len() {
+			walkBatchStrings(value.Index(i), visit)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			walkBatchStrings(iter.Value(), visit)
+		}
+	default:
+	}
+}
+
+// resolveBatchRefs returns a copy of data in which every placeholder is
+// replaced by the value it refers to in results, keyed by operation ID. data
+// itself is never modified, so a batch can be executed again.
+func resolveBatchRefs(data interface{}, results map[string]*BatchResult) (interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	var resolveErr error
+
+	resolved := copyBatchValue(reflect.ValueOf(data), func(value string) string {
+		return batchRefPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+			match := batchRefPattern.FindStringSubmatch(placeholder)
+
+			replacement, err := batchRefValue(results[match[1]], match[1], match[2])
+			if err != nil && resolveErr == nil {
+				resolveErr = err
+			}
+
+			return replacement
+		})
+	})
+
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+
+	return resolved.Interface(), nil
+}
+
+func batchRefValue(result *BatchResult, id, path string) (string, error) {
+	if result == nil || !result.Success {
+		return "", fmt.Errorf("%w: %s.%s: operation did not succeed", ErrBatchUnresolvedRef, id, path)
+	}
+
+	value := reflect.ValueOf(result.Data)
+
+	for _, name := range strings.Split(path, ".") {
+		for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+			if value.IsNil() {
+				return "", fmt.Errorf("%w: %s.%s: nil before %s", ErrBatchUnresolvedRef, id, path, name)
+			}
+
+			value = value.Elem()
+		}
+
+		if value.Kind() != reflect.Struct {
+			return "", fmt.Errorf("%w: %s.%s: no field %s", ErrBatchUnresolvedRef, id, path, name)
+		}
+
+		value = value.FieldByName(name)
+		if !value.IsValid() {
+			return "", fmt.Errorf("%w: %s.%s: no field %s", ErrBatchUnresolvedRef, id, path, name)
+		}
+	}
+
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "", fmt.Errorf("%w: %s.%s is nil", ErrBatchUnresolvedRef, id, path)
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Kind() == reflect.String {
+		return value.String(), nil
+	}
+
+	return fmt.Sprint(value.Interface()), nil
+}
+
+// copyBatchValue deep-copies value, passing every string through replace.
+// Unexported struct fields are copied as they are.
+func copyBatchValue(value reflect.Value, replace func(string) string) reflect.Value {
+	switch value.Kind() {
+	case reflect.String:
+		copied := reflect.New(value.Type()).Elem()
+		copied.SetString(replace(value.String()))
+
+		return copied
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(copyBatchValue(value.Elem(), replace))
+
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(copyBatchValue(value.Elem(), replace))
+
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+
+		for i := range value.NumField() {
+			if value.Type().Field(i).IsExported() {
+				copied.Field(i).Set(copyBatchValue(value.Field(i), replace))
+			}
+		}
+
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := range value.Len() {
+			copied.Index(i).Set(copyBatchValue(value.Index(i), replace))
+		}
+
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return value
+		}
+
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), copyBatchValue(iter.Value(), replace))
+		}
+
+		return copied
+	default:
+		return value
+	}
+}
+
+// batchGraph is the dependency graph of a batch: dependencies[i] lists the
+// indexes of the operations operation i waits for, and levels[i] is its
+// depth, 0 for operations without dependencies.
+type batchGraph struct {
+	dependencies [][]int
+	levels       []int
+}
+
+// newBatchGraph combines every operation's DependsOn with the operations its
+// Data references, and rejects unknown or ambiguous IDs and cycles.
+func newBatchGraph(operations []BatchOperation) (*batchGraph, error) {
+	indexes := make(map[string][]int, len(operations))
+	for i, operation := range operations {
+		indexes[operation.ID] = append(indexes[operation.ID], i)
+	}
+
+	graph := &batchGraph{
+		dependencies: make([][]int, len(operations)),
+		levels:       make([]int, len(operations)),
+	}
+
+	for i, operation := range operations {
+		seen := map[int]bool{}
+
+		for _, id := range append(append([]string(nil), operation.DependsOn...), batchRefIDs(operation.Data)...) {
+			candidates := indexes[id]
+
+			switch {
+			case len(candidates) == 0:
+				return nil, fmt.Errorf("%w: %s depends on %q", ErrBatchUnknownDependency, operation.ID, id)
+			case len(candidates) > 1:
+				return nil, fmt.Errorf("%w: %s depends on %q", ErrBatchAmbiguousDependency, operation.ID, id)
+			case candidates[0] == i:
+				return nil, fmt.Errorf("%w: %s depends on itself", ErrBatchDependencyCycle, operation.ID)
+			}
+
+			if !seen[candidates[0]] {
+				seen[candidates[0]] = true
+				graph.dependencies[i] = append(graph.dependencies[i], candidates[0])
+			}
+		}
+	}
+
+	err := graph.computeLevels(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+// computeLevels assigns levels with a depth-first search, which also finds
+// cycles.
+func (g *batchGraph) computeLevels(operations []BatchOperation) error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make([]int, len(operations))
+
+	var visit func(i int) error
+
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: involving %s", ErrBatchDependencyCycle, operations[i].ID)
+		}
+
+		state[i] = visiting
+
+		for _, dependency := range g.dependencies[i] {
+			err := visit(dependency)
+			if err != nil {
+				return err
+			}
+
+			g.levels[i] = max(g.levels[i], g.levels[dependency]+1)
+		}
+
+		state[i] = done
+
+		return nil
+	}
+
+	for i := range operations {
+		err := visit(i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BatchPlanStep describes how an operation would be scheduled.
+type BatchPlanStep struct {
+	ID       string `json:"id"       yaml:"id"`
+	Type     string `json:"type"     yaml:"type"`
+	Resource string `json:"resource" yaml:"resource"`
+	// Level is the operation's depth in the dependency graph, as in
+	// BatchResult.
+	Level int `json:"level" yaml:"level"`
+	// DependsOn lists the explicit dependencies and the operations Data
+	// references.
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+}
+
+// PlanBatchOperations validates the dependencies of operations like Execute
+// does and returns their schedule without running anything.
+func PlanBatchOperations(operations []BatchOperation) ([]BatchPlanStep, error) {
+	graph, err := newBatchGraph(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]BatchPlanStep, len(operations))
+
+	for i, operation := range operations {
+		steps[i] = BatchPlanStep{
+			ID:       operation.ID,
+			Type:     operation.Type,
+			Resource: operation.Resource,
+			Level:    graph.levels[i],
+		}
+
+		for _, dependency := range graph.dependencies[i] {
+			steps[i].DependsOn = append(steps[i].DependsOn, operations[dependency].ID)
+		}
+	}
+
+	return steps, nil
+}
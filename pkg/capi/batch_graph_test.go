@@ -0,0 +1,369 @@
+package capi_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// graphRecorder records the order of create calls and how many ran at once.
+type graphRecorder struct {
+	mu      sync.Mutex
+	calls   []string
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (r *graphRecorder) enter(name string) func() {
+	r.mu.Lock()
+	r.calls = append(r.calls, name)
+	r.mu.Unlock()
+
+	running := r.running.Add(1)
+	for {
+		peak := r.peak.Load()
+		if running <= peak || r.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	return func() { r.running.Add(-1) }
+}
+
+type graphOrgs struct {
+	capi.OrganizationsClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphOrgs) Create(_ context.Context, request *capi.OrganizationCreateRequest) (*capi.Organization, error) {
+	defer s.recorder.enter("org " + request.Name)()
+
+	if request.Name == "broken" {
+		return nil, errQuotaExceeded
+	}
+
+	return &capi.Organization{Resource: capi.Resource{GUID: request.Name + "-guid"}, Name: request.Name}, nil
+}
+
+type graphSpaces struct {
+	capi.SpacesClient
+
+	recorder *graphRecorder
+	requests sync.Map
+}
+
+func (s *graphSpaces) Create(_ context.Context, request *capi.SpaceCreateRequest) (*capi.Space, error) {
+	defer s.recorder.enter("space " + request.Name)()
+
+	s.requests.Store(request.Name, request)
+
+	return &capi.Space{Resource: capi.Resource{GUID: request.Name + "-guid"}, Name: request.Name}, nil
+}
+
+type graphApps struct {
+	capi.AppsClient
+
+	recorder *graphRecorder
+	requests sync.Map
+}
+
+func (s *graphApps) Create(_ context.Context, request *capi.AppCreateRequest) (*capi.App, error) {
+	defer s.recorder.enter("app " + request.Name)()
+
+	s.requests.Store(request.Name, request)
+
+	return &capi.App{Resource: capi.Resource{GUID: request.Name + "-guid"}, Name: request.Name}, nil
+}
+
+func graphClient() (*stubClient, *graphRecorder) {
+	recorder := &graphRecorder{}
+
+	return &stubClient{
+		organizations: &graphOrgs{recorder: recorder},
+		spaces:        &graphSpaces{recorder: recorder},
+		apps:          &graphApps{recorder: recorder},
+	}, recorder
+}
+
+func spaceIn(name, orgGUID string) *capi.SpaceCreateRequest {
+	return &capi.SpaceCreateRequest{
+		Name: name,
+		Relationships: capi.SpaceRelationships{Organization: capi.Relationship{
+			Data: &capi.RelationshipData{GUID: orgGUID},
+		}},
+	}
+}
+
+func appIn(name, spaceGUID string) *capi.AppCreateRequest {
+	return &capi.AppCreateRequest{
+		Name:                 name,
+		Relationships:        capi.AppRelationships{Space: capi.Relationship{Data: &capi.RelationshipData{GUID: spaceGUID}}},
+		EnvironmentVariables: map[string]interface{}{"ORG": "created in " + capi.Ref("org1").Field("Name")},
+	}
+}
+
+func TestBatchExecutor_ResolvesReferences(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	space := spaceIn("dev", capi.Ref("org1").GUID())
+	operations := capi.NewBatchBuilder().
+		AddCreateApp("app1", appIn("api", capi.Ref("space1").GUID())).
+		AddCreateSpace("space1", space).
+		AddCreateOrganization("org1", &capi.OrganizationCreateRequest{Name: "team"}).
+		Build()
+
+	results, err := capi.NewBatchExecutor(client, 5).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	for _, result := range results {
+		require.NoError(t, result.Error, result.ID)
+		assert.True(t, result.Success)
+		assert.False(t, result.StartedAt.IsZero())
+	}
+
+	assert.Equal(t, []string{"org team", "space dev", "app api"}, recorder.calls)
+	assert.Equal(t, 2, results[0].Level)
+	assert.Equal(t, 1, results[1].Level)
+	assert.Equal(t, 0, results[2].Level)
+	assert.False(t, results[1].StartedAt.Before(results[2].StartedAt.Add(results[2].Duration)))
+
+	created, ok := client.spaces.(*graphSpaces).requests.Load("dev")
+	require.True(t, ok)
+	assert.Equal(t, "team-guid", created.(*capi.SpaceCreateRequest).Relationships.Organization.Data.GUID)
+
+	app, ok := client.apps.(*graphApps).requests.Load("api")
+	require.True(t, ok)
+	assert.Equal(t, "dev-guid", app.(*capi.AppCreateRequest).Relationships.Space.Data.GUID)
+	assert.Equal(t, "created in team", app.(*capi.AppCreateRequest).EnvironmentVariables["ORG"])
+
+	// The caller's request still holds the placeholder.
+	assert.Equal(t, capi.Ref("org1").GUID(), space.Relationships.Organization.Data.GUID)
+}
+
+func TestBatchExecutor_RunsIndependentBranchesConcurrently(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	builder := capi.NewBatchBuilder().
+		AddCreateOrganization("org-a", &capi.OrganizationCreateRequest{Name: "a"}).
+		AddCreateOrganization("org-b", &capi.OrganizationCreateRequest{Name: "b"}).
+		AddCreateOrganization("org-c", &capi.OrganizationCreateRequest{Name: "c"})
+
+	for _, org := range []string{"a", "b", "c"} {
+		builder.AddCreateSpace("space-"+org, spaceIn("space-"+org, capi.Ref("org-"+org).GUID()))
+	}
+
+	results, err := capi.NewBatchExecutor(client, 2).Execute(context.Background(), builder.Build())
+	require.NoError(t, err)
+
+	for _, result := range results {
+		assert.True(t, result.Success, result.ID)
+	}
+
+	assert.Equal(t, int32(2), recorder.peak.Load())
+}
+
+func TestBatchExecutor_SkipsDependentsOfFailures(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	operations := capi.NewBatchBuilder().
+		AddCreateOrganization("org1", &capi.OrganizationCreateRequest{Name: "broken"}).
+		AddCreateSpace("space1", spaceIn("dev", capi.Ref("org1").GUID())).
+		AddCreateApp("app1", &capi.AppCreateRequest{Name: "api"}).DependsOn("space1").
+		AddCreateOrganization("org2", &capi.OrganizationCreateRequest{Name: "other"}).
+		Build()
+
+	var callbacks atomic.Int32
+
+	for i := range operations {
+		operations[i].Callback = func(*capi.BatchResult) { callbacks.Add(1) }
+	}
+
+	results, err := capi.NewBatchExecutor(client, 5).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, results[0].Error, errQuotaExceeded)
+	assert.False(t, results[0].Skipped)
+
+	assert.True(t, results[1].Skipped)
+	require.ErrorIs(t, results[1].Error, capi.ErrBatchDependencyFailed)
+	assert.Contains(t, results[1].Error.Error(), "org1")
+	assert.True(t, results[2].Skipped)
+	assert.Contains(t, results[2].Error.Error(), "space1")
+	assert.True(t, results[2].StartedAt.IsZero())
+
+	assert.True(t, results[3].Success)
+	assert.ElementsMatch(t, []string{"org broken", "org other"}, recorder.calls)
+	assert.Equal(t, int32(4), callbacks.Load())
+}
+
+func TestBatchExecutor_RejectsInvalidDependencies(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	executor := capi.NewBatchExecutor(client, 5)
+
+	tests := []struct {
+		name       string
+		operations []capi.BatchOperation
+		want       error
+	}{
+		{
+			name: "unknown dependency",
+			operations: capi.NewBatchBuilder().
+				AddCreateOrganization("org1", &capi.OrganizationCreateRequest{Name: "a"}).DependsOn("missing").
+				Build(),
+			want: capi.ErrBatchUnknownDependency,
+		},
+		{
+			name: "unknown reference",
+			operations: capi.NewBatchBuilder().
+				AddCreateSpace("space1", spaceIn("dev", capi.Ref("missing").GUID())).
+				Build(),
+			want: capi.ErrBatchUnknownDependency,
+		},
+		{
+			name: "ambiguous dependency",
+			operations: capi.NewBatchBuilder().
+				AddCreateOrganization("org", &capi.OrganizationCreateRequest{Name: "a"}).
+				AddCreateOrganization("org", &capi.OrganizationCreateRequest{Name: "b"}).
+				AddCreateSpace("space1", spaceIn("dev", capi.Ref("org").GUID())).
+				Build(),
+			want: capi.ErrBatchAmbiguousDependency,
+		},
+		{
+			name: "cycle",
+			operations: capi.NewBatchBuilder().
+				AddCreateOrganization("a", &capi.OrganizationCreateRequest{Name: "a"}).DependsOn("c").
+				AddCreateOrganization("b", &capi.OrganizationCreateRequest{Name: "b"}).DependsOn("a").
+				AddCreateOrganization("c", &capi.OrganizationCreateRequest{Name: "c"}).DependsOn("b").
+				Build(),
+			want: capi.ErrBatchDependencyCycle,
+		},
+		{
+			name: "self reference",
+			operations: capi.NewBatchBuilder().
+				AddCreateOrganization("a", &capi.OrganizationCreateRequest{Name: "a"}).DependsOn("a").
+				Build(),
+			want: capi.ErrBatchDependencyCycle,
+		},
+	}
+
+	for _, tt := range tests {
+		results, err := executor.Execute(context.Background(), tt.operations)
+		require.ErrorIs(t, err, tt.want, tt.name)
+		assert.Nil(t, results, tt.name)
+	}
+
+	assert.Empty(t, recorder.calls)
+}
+
+func TestBatchExecutor_UnresolvableReferenceFailsOperation(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	operations := capi.NewBatchBuilder().
+		AddCreateOrganization("org1", &capi.OrganizationCreateRequest{Name: "team"}).
+		AddCreateSpace("space1", spaceIn("dev", capi.Ref("org1").Field("NoSuchField"))).
+		Build()
+
+	results, err := capi.NewBatchExecutor(client, 5).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	assert.True(t, results[0].Success)
+	require.ErrorIs(t, results[1].Error, capi.ErrBatchUnresolvedRef)
+	assert.False(t, results[1].Skipped)
+	assert.Equal(t, []string{"org team"}, recorder.calls)
+}
+
+var errJobFailed = errors.New("job failed")
+
+// graphJobSpaces deletes spaces through a job named after the space.
+type graphJobSpaces struct {
+	capi.SpacesClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphJobSpaces) Delete(_ context.Context, guid string) (*capi.Job, error) {
+	defer s.recorder.enter("delete " + guid)()
+
+	return &capi.Job{Resource: capi.Resource{GUID: "job-" + guid}, State: "PROCESSING"}, nil
+}
+
+// graphJobs completes every job after a while, except those of spaces named
+// broken, which fail.
+type graphJobs struct {
+	capi.JobsClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphJobs) PollUntilComplete(_ context.Context, guid string) (*capi.Job, error) {
+	time.Sleep(10 * time.Millisecond)
+
+	s.recorder.mu.Lock()
+	defer s.recorder.mu.Unlock()
+
+	if guid == "job-broken" {
+		return &capi.Job{Resource: capi.Resource{GUID: guid}, State: "FAILED"}, errJobFailed
+	}
+
+	s.recorder.calls = append(s.recorder.calls, "complete "+guid)
+
+	return &capi.Job{Resource: capi.Resource{GUID: guid}, State: "COMPLETE"}, nil
+}
+
+func TestBatchExecutor_WaitsForJobs(t *testing.T) {
+	t.Parallel()
+
+	client, recorder := graphClient()
+	client.spaces = &graphJobSpaces{recorder: recorder}
+	client.jobs = &graphJobs{recorder: recorder}
+
+	operations := []capi.BatchOperation{
+		{ID: "delete-broken", Type: "delete", Resource: "space", Data: "broken"},
+		{ID: "after-broken", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "x"}, DependsOn: []string{"delete-broken"}},
+		{ID: "delete-dev", Type: "delete", Resource: "space", Data: "dev"},
+		{ID: "after-dev", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "y"}, DependsOn: []string{"delete-dev"}},
+	}
+
+	results, err := capi.NewBatchExecutor(client, 5).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	// A job that fails after being queued fails the operation and skips its
+	// dependents.
+	assert.False(t, results[0].Success)
+	require.ErrorIs(t, results[0].Error, errJobFailed)
+	assert.True(t, results[1].Skipped)
+
+	// Dependents of a job start only once it completed.
+	assert.True(t, results[2].Success)
+	assert.Equal(t, "COMPLETE", results[2].Data.(*capi.Job).State) //nolint:forcetypeassert // a delete returns its job
+	assert.True(t, results[3].Success)
+	require.Contains(t, recorder.calls, "complete job-dev")
+	assert.Less(t, slices.Index(recorder.calls, "complete job-dev"), slices.Index(recorder.calls, "org y"))
+	assert.NotContains(t, recorder.calls, "org x")
+}
+
+func TestBatchRef(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "${ref:org1.GUID}", capi.Ref("org1").GUID())
+	assert.Equal(t, "${ref:app.Relationships.Space.Data.GUID}", capi.Ref("app").Field("Relationships.Space.Data.GUID"))
+}
@@ -0,0 +1,232 @@
+package capi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrBatchJournalNotFound   = errors.New("batch journal not found")
+	ErrInvalidBatchJournalID  = errors.New("invalid batch journal ID")
+	ErrBatchJournalNotSet     = errors.New("batch transaction has no journal store")
+	ErrBatchSnapshotFailed    = errors.New("failed to snapshot resource before batch operation")
+	ErrBatchJournalSaveFailed = errors.New("failed to save batch journal")
+)
+
+// BatchJournalState is the progress of one operation of a transaction
+// journal.
+type BatchJournalState string
+
+// Batch journal entry states.
+const (
+	// BatchJournalPending operations have not reported a result yet. When a
+	// journal is resumed they are treated as possibly applied.
+	BatchJournalPending BatchJournalState = "pending"
+	// BatchJournalApplied operations succeeded and are undone on rollback.
+	BatchJournalApplied BatchJournalState = "applied"
+	// BatchJournalFailed operations failed or were skipped; there is nothing
+	// to undo.
+	BatchJournalFailed BatchJournalState = "failed"
+	// BatchJournalRolledBack operations were undone.
+	BatchJournalRolledBack BatchJournalState = "rolled_back"
+	// BatchJournalRollbackFailed operations could not be undone yet; resuming
+	// the rollback retries them.
+	BatchJournalRollbackFailed BatchJournalState = "rollback_failed"
+	// BatchJournalIrreversible operations cannot be undone automatically.
+	BatchJournalIrreversible BatchJournalState = "irreversible"
+)
+
+// BatchJournalEntry records one operation of a transaction and what is
+// needed to undo it.
+type BatchJournalEntry struct {
+	OperationID string `json:"operation_id"`
+	Type        string `json:"type"`
+	// Resource is the operation's resource, or for metadata updates the
+	// resource whose metadata they change.
+	Resource string `json:"resource"`
+	Level    int    `json:"level"`
+	// GUID is the target of an update or delete, or the resource a create
+	// produced.
+	GUID string `json:"guid,omitempty"`
+	// Snapshot is the resource as it was before an update or delete.
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+	// AddedMetadata lists the labels and annotations an update adds, as
+	// "label KEY" or "annotation KEY". Restoring the snapshot cannot remove
+	// them, so a rollback removes them separately.
+	AddedMetadata []string          `json:"added_metadata,omitempty"`
+	State         BatchJournalState `json:"state"`
+	// RestoredGUID is the GUID of the resource recreated after a delete.
+	RestoredGUID string `json:"restored_guid,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// BatchJournal is the persisted record of a transaction: one entry per
+// operation, in the transaction's order.
+type BatchJournal struct {
+	ID        string              `json:"id"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Entries   []BatchJournalEntry `json:"entries"`
+}
+
+// BatchJournalStore persists transaction journals so an interrupted rollback
+// can be resumed.
+type BatchJournalStore interface {
+	Save(journal *BatchJournal) error
+	Load(id string) (*BatchJournal, error)
+}
+
+// FileBatchJournalStore keeps each journal as <dir>/<id>.json.
+type FileBatchJournalStore struct {
+	dir string
+}
+
+// NewFileBatchJournalStore creates a store writing journals to dir, which is
+// created on the first save.
+func NewFileBatchJournalStore(dir string) *FileBatchJournalStore {
+	return &FileBatchJournalStore{dir: dir}
+}
+
+// Save writes the journal atomically: to a temporary file first, then
+// renamed over the previous version, so a crash never leaves a truncated
+// journal behind.
+func (s *FileBatchJournalStore) Save(journal *BatchJournal) error {
+	path, err := s.path(journal.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode batch journal: %w", err)
+	}
+
+	err = os.MkdirAll(s.dir, 0o700) //nolint:mnd // owner-only directory permissions
+	if err != nil {
+		return fmt.Errorf("failed to create batch journal directory: %w", err)
+	}
+
+	file, err := os.CreateTemp(s.dir, journal.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create batch journal: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(file.Name())
+
+		return fmt.Errorf("failed to write batch journal: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads a journal written by Save.
+func (s *FileBatchJournalStore) Load(id string) (*BatchJournal, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // path is built from a validated journal ID
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrBatchJournalNotFound, id)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch journal: %w", err)
+	}
+
+	var journal BatchJournal
+
+	err = json.Unmarshal(data, &journal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode batch journal %s: %w", id, err)
+	}
+
+	return &journal, nil
+}
+
+func (s *FileBatchJournalStore) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidBatchJournalID, id)
+	}
+
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// newBatchJournal starts a journal with one pending entry per operation.
+func newBatchJournal(id string, operations []BatchOperation) *BatchJournal {
+	now := time.Now().UTC()
+	journal := &BatchJournal{
+		ID:        id,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Entries:   make([]BatchJournalEntry, len(operations)),
+	}
+
+	for i, operation := range operations {
+		journal.Entries[i] = BatchJournalEntry{
+			OperationID: operation.ID,
+			Type:        operation.Type,
+			Resource:    operation.Resource,
+			State:       BatchJournalPending,
+		}
+	}
+
+	return journal
+}
+
+// record stores the outcome of each operation. Without results, as when the
+// batch was rejected before running, every entry is marked failed.
+func (j *BatchJournal) record(results []BatchResult, err error) {
+	if results == nil {
+		for i := range j.Entries {
+			j.Entries[i].State = BatchJournalFailed
+			if err != nil {
+				j.Entries[i].Error = err.Error()
+			}
+		}
+
+		return
+	}
+
+	for i, result := range results {
+		entry := &j.Entries[i]
+		entry.Level = result.Level
+
+		if !result.Success {
+			entry.State = BatchJournalFailed
+			if result.Error != nil {
+				entry.Error = result.Error.Error()
+			}
+
+			continue
+		}
+
+		entry.State = BatchJournalApplied
+
+		if entry.Type == "create" {
+			entry.GUID, _ = guidFromResult(result.Data)
+		}
+	}
+}
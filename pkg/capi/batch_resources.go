@@ -0,0 +1,639 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidDataTypeSecurityGroup            = errors.New("invalid data type for security group operation")
+	ErrInvalidDataTypeOrganizationQuota        = errors.New("invalid data type for organization quota operation")
+	ErrInvalidDataTypeSpaceQuota               = errors.New("invalid data type for space quota operation")
+	ErrInvalidDataTypeDomain                   = errors.New("invalid data type for domain operation")
+	ErrInvalidDataTypeRole                     = errors.New("invalid data type for role operation")
+	ErrInvalidDataTypeServiceCredentialBinding = errors.New("invalid data type for service credential binding operation")
+	ErrInvalidDataTypeRouteDestination         = errors.New("invalid data type for route destination operation")
+	ErrInvalidDataTypeSecurityGroupBinding     = errors.New("invalid data type for security group binding operation")
+	ErrInvalidDataTypeMetadata                 = errors.New("invalid data type for metadata operation")
+	ErrInvalidSecurityGroupLifecycle           = errors.New("security group lifecycle must be running or staging")
+	ErrInvalidDataTypeFeatureFlag              = errors.New("invalid data type for feature flag operation")
+	ErrInvalidDataTypeEnvironmentVariableGroup = errors.New("invalid data type for environment variable group operation")
+	ErrInvalidDataTypeIsolationSegmentEntitle  = errors.New("invalid data type for isolation segment entitlement operation")
+	ErrInvalidDataTypeQuotaAssignment          = errors.New("invalid data type for quota assignment operation")
+)
+
+// Security group binding lifecycles.
+const (
+	SecurityGroupLifecycleRunning = "running"
+	SecurityGroupLifecycleStaging = "staging"
+)
+
+// RouteDestinationsChange is the Data of "route_destination" operations:
+// "create" inserts the destinations into the route, "update" replaces all of
+// its destinations with them.
+type RouteDestinationsChange struct {
+	RouteGUID    string             `json:"route_guid"   yaml:"route_guid"`
+	Destinations []RouteDestination `json:"destinations" yaml:"destinations"`
+}
+
+// RouteDestinationRef is the Data of "route_destination" deletes: the
+// destination to remove from the route.
+type RouteDestinationRef struct {
+	RouteGUID       string `json:"route_guid"       yaml:"route_guid"`
+	DestinationGUID string `json:"destination_guid" yaml:"destination_guid"`
+}
+
+// SecurityGroupBinding is the Data of "security_group_binding" operations:
+// "create" binds the security group to the spaces for the lifecycle, "delete"
+// unbinds it from them.
+type SecurityGroupBinding struct {
+	SecurityGroupGUID string `json:"security_group_guid" yaml:"security_group_guid"`
+	// Lifecycle is SecurityGroupLifecycleRunning or SecurityGroupLifecycleStaging.
+	Lifecycle  string   `json:"lifecycle"   yaml:"lifecycle"`
+	SpaceGUIDs []string `json:"space_guids" yaml:"space_guids"`
+}
+
+// MetadataUpdate is the Data of "metadata" updates: it sets labels and
+// annotations on a resource of any type that carries metadata, given by its
+// batch resource name ("app", "space", "organization", "route", "domain",
+// "service_instance", "service_credential_binding", "organization_quota" or
+// "space_quota").
+type MetadataUpdate struct {
+	Resource string    `json:"resource" yaml:"resource"`
+	GUID     string    `json:"guid"     yaml:"guid"`
+	Metadata *Metadata `json:"metadata" yaml:"metadata"`
+}
+
+// FeatureFlagChange is the Data of "feature_flag" updates.
+type FeatureFlagChange struct {
+	Name               string  `json:"name"                           yaml:"name"`
+	Enabled            bool    `json:"enabled"                        yaml:"enabled"`
+	CustomErrorMessage *string `json:"custom_error_message,omitempty" yaml:"custom_error_message,omitempty"`
+}
+
+// EnvironmentVariableGroupChange is the Data of "environment_variable_group"
+// updates: it sets the variables of the running or staging group, and
+// removes those whose value is nil.
+type EnvironmentVariableGroupChange struct {
+	Name string                 `json:"name" yaml:"name"`
+	Var  map[string]interface{} `json:"var"  yaml:"var"`
+}
+
+// IsolationSegmentEntitlement is the Data of "isolation_segment_entitlement"
+// operations: "create" entitles the organizations to the isolation segment,
+// "delete" revokes their entitlement.
+type IsolationSegmentEntitlement struct {
+	IsolationSegmentGUID string   `json:"isolation_segment_guid" yaml:"isolation_segment_guid"`
+	OrganizationGUIDs    []string `json:"organization_guids"     yaml:"organization_guids"`
+}
+
+// QuotaAssignment is the Data of "organization_quota_assignment" and
+// "space_quota_assignment" operations: "create" applies the quota to the
+// organizations or spaces, "delete" removes a space quota from the spaces.
+// Organizations always have a quota, so theirs can only be replaced.
+type QuotaAssignment struct {
+	QuotaGUID string   `json:"quota_guid" yaml:"quota_guid"`
+	GUIDs     []string `json:"guids"      yaml:"guids"`
+}
+
+// createSecurityGroupOperationConfig creates CRUD operation configuration for security groups.
+func (b *BatchExecutor) createSecurityGroupOperationConfig() CRUDOperationConfig {
+	return createCRUDOperationConfig(ErrInvalidDataTypeSecurityGroup, b.client.SecurityGroups())
+}
+
+// createOrganizationQuotaOperationConfig creates CRUD operation configuration for organization quotas.
+func (b *BatchExecutor) createOrganizationQuotaOperationConfig() CRUDOperationConfig {
+	return createCRUDOperationConfig(ErrInvalidDataTypeOrganizationQuota, b.client.OrganizationQuotas())
+}
+
+// createSpaceQuotaOperationConfig creates CRUD operation configuration for space quotas.
+func (b *BatchExecutor) createSpaceQuotaOperationConfig() CRUDOperationConfig {
+	return createCRUDOperationConfig(ErrInvalidDataTypeSpaceQuota, b.client.SpaceQuotas())
+}
+
+// createDomainOperationConfig creates CRUD operation configuration for domains.
+func (b *BatchExecutor) createDomainOperationConfig() CRUDOperationConfig {
+	return createCRUDOperationConfig(ErrInvalidDataTypeDomain, b.client.Domains())
+}
+
+// unsupportedOperation reports an operation type a resource does not have.
+func unsupportedOperation(operation BatchOperation) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnsupportedOperationType, operation.Type, operation.Resource)
+	}
+}
+
+// executeRoleOperation handles roles, which cannot be updated.
+func (b *BatchExecutor) executeRoleOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if req, ok := operation.Data.(*RoleCreateRequest); ok {
+				return b.client.Roles().Create(ctx, req)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeRole)
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			if guid, ok := operation.Data.(string); ok {
+				return b.client.Roles().Delete(ctx, guid)
+			}
+
+			return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeRole)
+		},
+		func() (interface{}, error) {
+			if guid, ok := operation.Data.(string); ok {
+				return b.client.Roles().Get(ctx, guid)
+			}
+
+			return nil, fmt.Errorf("%w get", ErrInvalidDataTypeRole)
+		},
+	)
+}
+
+// executeServiceCredentialBindingOperation handles service credential
+// bindings, whose creates return a job for managed instances.
+func (b *BatchExecutor) executeServiceCredentialBindingOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if req, ok := operation.Data.(*ServiceCredentialBindingCreateRequest); ok {
+				return b.client.ServiceCredentialBindings().Create(ctx, req)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeServiceCredentialBinding)
+		},
+		func() (interface{}, error) {
+			if data, ok := operation.Data.(*UpdateDataWrapper[ServiceCredentialBindingUpdateRequest]); ok {
+				return b.client.ServiceCredentialBindings().Update(ctx, data.GUID, data.Request)
+			}
+
+			return nil, fmt.Errorf("%w update", ErrInvalidDataTypeServiceCredentialBinding)
+		},
+		func() (interface{}, error) {
+			if guid, ok := operation.Data.(string); ok {
+				return b.client.ServiceCredentialBindings().Delete(ctx, guid)
+			}
+
+			return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeServiceCredentialBinding)
+		},
+		func() (interface{}, error) {
+			if guid, ok := operation.Data.(string); ok {
+				return b.client.ServiceCredentialBindings().Get(ctx, guid)
+			}
+
+			return nil, fmt.Errorf("%w get", ErrInvalidDataTypeServiceCredentialBinding)
+		},
+	)
+}
+
+// executeRouteDestinationOperation inserts, replaces, removes or lists the
+// destinations of a route.
+func (b *BatchExecutor) executeRouteDestinationOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if data, ok := operation.Data.(*RouteDestinationsChange); ok {
+				return b.client.Routes().InsertDestinations(ctx, data.RouteGUID, data.Destinations)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeRouteDestination)
+		},
+		func() (interface{}, error) {
+			if data, ok := operation.Data.(*RouteDestinationsChange); ok {
+				return b.client.Routes().ReplaceDestinations(ctx, data.RouteGUID, data.Destinations)
+			}
+
+			return nil, fmt.Errorf("%w update", ErrInvalidDataTypeRouteDestination)
+		},
+		func() (interface{}, error) {
+			if data, ok := operation.Data.(*RouteDestinationRef); ok {
+				return nil, b.client.Routes().RemoveDestination(ctx, data.RouteGUID, data.DestinationGUID)
+			}
+
+			return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeRouteDestination)
+		},
+		func() (interface{}, error) {
+			if routeGUID, ok := operation.Data.(string); ok {
+				return b.client.Routes().ListDestinations(ctx, routeGUID)
+			}
+
+			return nil, fmt.Errorf("%w get", ErrInvalidDataTypeRouteDestination)
+		},
+	)
+}
+
+// executeSecurityGroupBindingOperation binds or unbinds a security group.
+func (b *BatchExecutor) executeSecurityGroupBindingOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			binding, ok := operation.Data.(*SecurityGroupBinding)
+			if !ok {
+				return nil, fmt.Errorf("%w create", ErrInvalidDataTypeSecurityGroupBinding)
+			}
+
+			switch binding.Lifecycle {
+			case SecurityGroupLifecycleRunning:
+				return b.client.SecurityGroups().BindRunningSpaces(ctx, binding.SecurityGroupGUID, binding.SpaceGUIDs)
+			case SecurityGroupLifecycleStaging:
+				return b.client.SecurityGroups().BindStagingSpaces(ctx, binding.SecurityGroupGUID, binding.SpaceGUIDs)
+			default:
+				return nil, fmt.Errorf("%w: %q", ErrInvalidSecurityGroupLifecycle, binding.Lifecycle)
+			}
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			binding, ok := operation.Data.(*SecurityGroupBinding)
+			if !ok {
+				return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeSecurityGroupBinding)
+			}
+
+			return nil, b.unbindSecurityGroup(ctx, binding)
+		},
+		unsupportedOperation(operation),
+	)
+}
+
+func (b *BatchExecutor) unbindSecurityGroup(ctx context.Context, binding *SecurityGroupBinding) error {
+	unbind := b.client.SecurityGroups().UnbindRunningSpace
+
+	switch binding.Lifecycle {
+	case SecurityGroupLifecycleRunning:
+	case SecurityGroupLifecycleStaging:
+		unbind = b.client.SecurityGroups().UnbindStagingSpace
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidSecurityGroupLifecycle, binding.Lifecycle)
+	}
+
+	for _, spaceGUID := range binding.SpaceGUIDs {
+		err := unbind(ctx, binding.SecurityGroupGUID, spaceGUID)
+		if err != nil {
+			return fmt.Errorf("failed to unbind space %s: %w", spaceGUID, err)
+		}
+	}
+
+	return nil
+}
+
+// executeFeatureFlagOperation updates or gets a feature flag by name.
+func (b *BatchExecutor) executeFeatureFlagOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			if change, ok := operation.Data.(*FeatureFlagChange); ok {
+				return b.client.FeatureFlags().Update(ctx, change.Name, &FeatureFlagUpdateRequest{
+					Enabled:            change.Enabled,
+					CustomErrorMessage: change.CustomErrorMessage,
+				})
+			}
+
+			return nil, fmt.Errorf("%w update", ErrInvalidDataTypeFeatureFlag)
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			if name, ok := operation.Data.(string); ok {
+				return b.client.FeatureFlags().Get(ctx, name)
+			}
+
+			return nil, fmt.Errorf("%w get", ErrInvalidDataTypeFeatureFlag)
+		},
+	)
+}
+
+// executeEnvironmentVariableGroupOperation updates or gets the running or
+// staging environment variable group.
+func (b *BatchExecutor) executeEnvironmentVariableGroupOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			if change, ok := operation.Data.(*EnvironmentVariableGroupChange); ok {
+				return b.client.EnvironmentVariableGroups().Update(ctx, change.Name, change.Var)
+			}
+
+			return nil, fmt.Errorf("%w update", ErrInvalidDataTypeEnvironmentVariableGroup)
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			if name, ok := operation.Data.(string); ok {
+				return b.client.EnvironmentVariableGroups().Get(ctx, name)
+			}
+
+			return nil, fmt.Errorf("%w get", ErrInvalidDataTypeEnvironmentVariableGroup)
+		},
+	)
+}
+
+// executeIsolationSegmentEntitlementOperation entitles organizations to an
+// isolation segment or revokes their entitlement.
+func (b *BatchExecutor) executeIsolationSegmentEntitlementOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if entitlement, ok := operation.Data.(*IsolationSegmentEntitlement); ok {
+				return b.client.IsolationSegments().EntitleOrganizations(ctx, entitlement.IsolationSegmentGUID, entitlement.OrganizationGUIDs)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeIsolationSegmentEntitle)
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			entitlement, ok := operation.Data.(*IsolationSegmentEntitlement)
+			if !ok {
+				return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeIsolationSegmentEntitle)
+			}
+
+			for _, orgGUID := range entitlement.OrganizationGUIDs {
+				err := b.client.IsolationSegments().RevokeOrganization(ctx, entitlement.IsolationSegmentGUID, orgGUID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to revoke organization %s: %w", orgGUID, err)
+				}
+			}
+
+			return nil, nil
+		},
+		unsupportedOperation(operation),
+	)
+}
+
+// executeOrganizationQuotaAssignmentOperation applies an organization quota
+// to organizations.
+func (b *BatchExecutor) executeOrganizationQuotaAssignmentOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if assignment, ok := operation.Data.(*QuotaAssignment); ok {
+				return b.client.OrganizationQuotas().ApplyToOrganizations(ctx, assignment.QuotaGUID, assignment.GUIDs)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeQuotaAssignment)
+		},
+		unsupportedOperation(operation),
+		unsupportedOperation(operation),
+		unsupportedOperation(operation),
+	)
+}
+
+// executeSpaceQuotaAssignmentOperation applies a space quota to spaces or
+// removes it from them.
+func (b *BatchExecutor) executeSpaceQuotaAssignmentOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	return handleCrudOperation(operation,
+		func() (interface{}, error) {
+			if assignment, ok := operation.Data.(*QuotaAssignment); ok {
+				return b.client.SpaceQuotas().ApplyToSpaces(ctx, assignment.QuotaGUID, assignment.GUIDs)
+			}
+
+			return nil, fmt.Errorf("%w create", ErrInvalidDataTypeQuotaAssignment)
+		},
+		unsupportedOperation(operation),
+		func() (interface{}, error) {
+			assignment, ok := operation.Data.(*QuotaAssignment)
+			if !ok {
+				return nil, fmt.Errorf("%w delete", ErrInvalidDataTypeQuotaAssignment)
+			}
+
+			for _, spaceGUID := range assignment.GUIDs {
+				err := b.client.SpaceQuotas().RemoveFromSpace(ctx, assignment.QuotaGUID, spaceGUID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to remove space quota from space %s: %w", spaceGUID, err)
+				}
+			}
+
+			return nil, nil
+		},
+		unsupportedOperation(operation),
+	)
+}
+
+// executeMetadataOperation runs a MetadataUpdate as an update of the target
+// resource that carries only metadata.
+func (b *BatchExecutor) executeMetadataOperation(ctx context.Context, operation BatchOperation) *BatchResult {
+	update, ok := operation.Data.(*MetadataUpdate)
+	if !ok || operation.Type != "update" {
+		return &BatchResult{
+			ID:    operation.ID,
+			Error: fmt.Errorf("%w %s", ErrInvalidDataTypeMetadata, operation.Type),
+		}
+	}
+
+	data, err := metadataUpdateData(update)
+	if err != nil {
+		return &BatchResult{ID: operation.ID, Error: err}
+	}
+
+	return b.executeOperation(ctx, BatchOperation{
+		ID:       operation.ID,
+		Type:     "update",
+		Resource: update.Resource,
+		Data:     data,
+	})
+}
+
+// metadataUpdateData builds the update request of the target resource.
+func metadataUpdateData(update *MetadataUpdate) (interface{}, error) {
+	switch update.Resource {
+	case "app":
+		return &UpdateDataWrapper[AppUpdateRequest]{GUID: update.GUID, Request: &AppUpdateRequest{Metadata: update.Metadata}}, nil
+	case "space":
+		return &UpdateDataWrapper[SpaceUpdateRequest]{GUID: update.GUID, Request: &SpaceUpdateRequest{Metadata: update.Metadata}}, nil
+	case "organization":
+		return &UpdateDataWrapper[OrganizationUpdateRequest]{GUID: update.GUID, Request: &OrganizationUpdateRequest{Metadata: update.Metadata}}, nil
+	case "route":
+		return &UpdateDataWrapper[RouteUpdateRequest]{GUID: update.GUID, Request: &RouteUpdateRequest{Metadata: update.Metadata}}, nil
+	case "domain":
+		return &UpdateDataWrapper[DomainUpdateRequest]{GUID: update.GUID, Request: &DomainUpdateRequest{Metadata: update.Metadata}}, nil
+	case "service_instance":
+		return &UpdateDataWrapper[ServiceInstanceUpdateRequest]{
+			GUID:    update.GUID,
+			Request: &ServiceInstanceUpdateRequest{Metadata: update.Metadata},
+		}, nil
+	case "service_credential_binding":
+		return &UpdateDataWrapper[ServiceCredentialBindingUpdateRequest]{
+			GUID:    update.GUID,
+			Request: &ServiceCredentialBindingUpdateRequest{Metadata: update.Metadata},
+		}, nil
+	case "organization_quota":
+		return &UpdateDataWrapper[OrganizationQuotaUpdateRequest]{
+			GUID:    update.GUID,
+			Request: &OrganizationQuotaUpdateRequest{Metadata: update.Metadata},
+		}, nil
+	case "space_quota":
+		return &UpdateDataWrapper[SpaceQuotaV3UpdateRequest]{
+			GUID:    update.GUID,
+			Request: &SpaceQuotaV3UpdateRequest{Metadata: update.Metadata},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: metadata of %s", ErrUnsupportedResourceType, update.Resource)
+	}
+}
+
+func (b *BatchBuilder) add(id, operationType, resource string, data interface{}) *BatchBuilder {
+	b.operations = append(b.operations, BatchOperation{
+		ID:       id,
+		Type:     operationType,
+		Resource: resource,
+		Data:     data,
+	})
+
+	return b
+}
+
+// AddDelete adds a deletion of the resource with the given GUID. It serves
+// every resource deleted by GUID; route destinations and security group
+// bindings have their own helpers.
+func (b *BatchBuilder) AddDelete(id, resource, guid string) *BatchBuilder {
+	return b.add(id, "delete", resource, guid)
+}
+
+// AddGet adds a get of the resource with the given GUID.
+func (b *BatchBuilder) AddGet(id, resource, guid string) *BatchBuilder {
+	return b.add(id, "get", resource, guid)
+}
+
+// AddCreateRoute adds a route creation operation.
+func (b *BatchBuilder) AddCreateRoute(id string, request *RouteCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "route", request)
+}
+
+// AddUpdateRoute adds a route update operation.
+func (b *BatchBuilder) AddUpdateRoute(id, guid string, request *RouteUpdateRequest) *BatchBuilder {
+	return b.add(id, "update", "route", &UpdateDataWrapper[RouteUpdateRequest]{GUID: guid, Request: request})
+}
+
+// AddCreateServiceInstance adds a service instance creation operation.
+func (b *BatchBuilder) AddCreateServiceInstance(id string, request *ServiceInstanceCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "service_instance", request)
+}
+
+// AddCreateServiceCredentialBinding adds a service binding or key creation
+// operation.
+func (b *BatchBuilder) AddCreateServiceCredentialBinding(id string, request *ServiceCredentialBindingCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "service_credential_binding", request)
+}
+
+// AddCreateRole adds a role creation operation.
+func (b *BatchBuilder) AddCreateRole(id string, request *RoleCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "role", request)
+}
+
+// AddCreateDomain adds a domain creation operation.
+func (b *BatchBuilder) AddCreateDomain(id string, request *DomainCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "domain", request)
+}
+
+// AddCreateSecurityGroup adds a security group creation operation.
+func (b *BatchBuilder) AddCreateSecurityGroup(id string, request *SecurityGroupCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "security_group", request)
+}
+
+// AddUpdateSecurityGroup adds a security group update operation.
+func (b *BatchBuilder) AddUpdateSecurityGroup(id, guid string, request *SecurityGroupUpdateRequest) *BatchBuilder {
+	return b.add(id, "update", "security_group", &UpdateDataWrapper[SecurityGroupUpdateRequest]{GUID: guid, Request: request})
+}
+
+// AddBindSecurityGroup adds an operation binding a security group to spaces
+// for the running or staging lifecycle.
+func (b *BatchBuilder) AddBindSecurityGroup(id, securityGroupGUID, lifecycle string, spaceGUIDs ...string) *BatchBuilder {
+	return b.add(id, "create", "security_group_binding", &SecurityGroupBinding{
+		SecurityGroupGUID: securityGroupGUID,
+		Lifecycle:         lifecycle,
+		SpaceGUIDs:        spaceGUIDs,
+	})
+}
+
+// AddUnbindSecurityGroup adds an operation unbinding a security group from
+// spaces.
+func (b *BatchBuilder) AddUnbindSecurityGroup(id, securityGroupGUID, lifecycle string, spaceGUIDs ...string) *BatchBuilder {
+	return b.add(id, "delete", "security_group_binding", &SecurityGroupBinding{
+		SecurityGroupGUID: securityGroupGUID,
+		Lifecycle:         lifecycle,
+		SpaceGUIDs:        spaceGUIDs,
+	})
+}
+
+// AddCreateOrganizationQuota adds an organization quota creation operation.
+func (b *BatchBuilder) AddCreateOrganizationQuota(id string, request *OrganizationQuotaCreateRequest) *BatchBuilder {
+	return b.add(id, "create", "organization_quota", request)
+}
+
+// AddUpdateOrganizationQuota adds an organization quota update operation.
+func (b *BatchBuilder) AddUpdateOrganizationQuota(id, guid string, request *OrganizationQuotaUpdateRequest) *BatchBuilder {
+	return b.add(id, "update", "organization_quota", &UpdateDataWrapper[OrganizationQuotaUpdateRequest]{GUID: guid, Request: request})
+}
+
+// AddCreateSpaceQuota adds a space quota creation operation.
+func (b *BatchBuilder) AddCreateSpaceQuota(id string, request *SpaceQuotaV3CreateRequest) *BatchBuilder {
+	return b.add(id, "create", "space_quota", request)
+}
+
+// AddUpdateSpaceQuota adds a space quota update operation.
+func (b *BatchBuilder) AddUpdateSpaceQuota(id, guid string, request *SpaceQuotaV3UpdateRequest) *BatchBuilder {
+	return b.add(id, "update", "space_quota", &UpdateDataWrapper[SpaceQuotaV3UpdateRequest]{GUID: guid, Request: request})
+}
+
+// AddInsertRouteDestinations adds an operation mapping a route to more
+// destinations.
+func (b *BatchBuilder) AddInsertRouteDestinations(id, routeGUID string, destinations ...RouteDestination) *BatchBuilder {
+	return b.add(id, "create", "route_destination", &RouteDestinationsChange{RouteGUID: routeGUID, Destinations: destinations})
+}
+
+// AddReplaceRouteDestinations adds an operation replacing all destinations of
+// a route.
+func (b *BatchBuilder) AddReplaceRouteDestinations(id, routeGUID string, destinations ...RouteDestination) *BatchBuilder {
+	return b.add(id, "update", "route_destination", &RouteDestinationsChange{RouteGUID: routeGUID, Destinations: destinations})
+}
+
+// AddRemoveRouteDestination adds an operation removing one destination from
+// a route.
+func (b *BatchBuilder) AddRemoveRouteDestination(id, routeGUID, destinationGUID string) *BatchBuilder {
+	return b.add(id, "delete", "route_destination", &RouteDestinationRef{RouteGUID: routeGUID, DestinationGUID: destinationGUID})
+}
+
+// AddUpdateMetadata adds an operation setting labels and annotations on a
+// resource.
+func (b *BatchBuilder) AddUpdateMetadata(id, resource, guid string, metadata *Metadata) *BatchBuilder {
+	return b.add(id, "update", "metadata", &MetadataUpdate{Resource: resource, GUID: guid, Metadata: metadata})
+}
+
+// AddUpdateFeatureFlag adds an operation enabling or disabling a feature
+// flag.
+func (b *BatchBuilder) AddUpdateFeatureFlag(id, name string, enabled bool) *BatchBuilder {
+	return b.add(id, "update", "feature_flag", &FeatureFlagChange{Name: name, Enabled: enabled})
+}
+
+// AddUpdateEnvironmentVariableGroup adds an operation changing the running
+// or staging environment variable group; nil values remove variables.
+func (b *BatchBuilder) AddUpdateEnvironmentVariableGroup(id, name string, vars map[string]interface{}) *BatchBuilder {
+	return b.add(id, "update", "environment_variable_group", &EnvironmentVariableGroupChange{Name: name, Var: vars})
+}
+
+// AddEntitleIsolationSegment adds an operation entitling organizations to an
+// isolation segment.
+func (b *BatchBuilder) AddEntitleIsolationSegment(id, isolationSegmentGUID string, orgGUIDs ...string) *BatchBuilder {
+	return b.add(id, "create", "isolation_segment_entitlement", &IsolationSegmentEntitlement{
+		IsolationSegmentGUID: isolationSegmentGUID,
+		OrganizationGUIDs:    orgGUIDs,
+	})
+}
+
+// AddRevokeIsolationSegment adds an operation revoking the entitlement of
+// organizations to an isolation segment.
+func (b *BatchBuilder) AddRevokeIsolationSegment(id, isolationSegmentGUID string, orgGUIDs ...string) *BatchBuilder {
+	return b.add(id, "delete", "isolation_segment_entitlement", &IsolationSegmentEntitlement{
+		IsolationSegmentGUID: isolationSegmentGUID,
+		OrganizationGUIDs:    orgGUIDs,
+	})
+}
+
+// AddApplyOrganizationQuota adds an operation applying an organization quota
+// to organizations.
+func (b *BatchBuilder) AddApplyOrganizationQuota(id, quotaGUID string, orgGUIDs ...string) *BatchBuilder {
+	return b.add(id, "create", "organization_quota_assignment", &QuotaAssignment{QuotaGUID: quotaGUID, GUIDs: orgGUIDs})
+}
+
+// AddApplySpaceQuota adds an operation applying a space quota to spaces.
+func (b *BatchBuilder) AddApplySpaceQuota(id, quotaGUID string, spaceGUIDs ...string) *BatchBuilder {
+	return b.add(id, "create", "space_quota_assignment", &QuotaAssignment{QuotaGUID: quotaGUID, GUIDs: spaceGUIDs})
+}
+
+// AddRemoveSpaceQuota adds an operation removing a space quota from spaces.
+func (b *BatchBuilder) AddRemoveSpaceQuota(id, quotaGUID string, spaceGUIDs ...string) *BatchBuilder {
+	return b.add(id, "delete", "space_quota_assignment", &QuotaAssignment{QuotaGUID: quotaGUID, GUIDs: spaceGUIDs})
+}
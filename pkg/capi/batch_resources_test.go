@@ -0,0 +1,343 @@
+package capi_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callLog records the calls made to the resource stubs below.
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls = append(l.calls, call)
+}
+
+type batchRoutes struct {
+	capi.RoutesClient
+
+	log *callLog
+}
+
+func (s *batchRoutes) Create(_ context.Context, request *capi.RouteCreateRequest) (*capi.Route, error) {
+	s.log.add("create route " + *request.Host)
+
+	return &capi.Route{Resource: capi.Resource{GUID: *request.Host + "-guid"}}, nil
+}
+
+func (s *batchRoutes) InsertDestinations(_ context.Context, guid string, destinations []capi.RouteDestination) (*capi.RouteDestinations, error) {
+	s.log.add("insert " + guid + " " + destinations[0].App.GUID)
+
+	return &capi.RouteDestinations{Destinations: destinations}, nil
+}
+
+func (s *batchRoutes) RemoveDestination(_ context.Context, guid, destGUID string) error {
+	s.log.add("remove " + guid + " " + destGUID)
+
+	return nil
+}
+
+func (s *batchRoutes) Update(_ context.Context, guid string, request *capi.RouteUpdateRequest) (*capi.Route, error) {
+	s.log.add("update route " + guid + " team=" + request.Metadata.Labels["team"])
+
+	return &capi.Route{Resource: capi.Resource{GUID: guid}}, nil
+}
+
+type batchSecurityGroups struct {
+	capi.SecurityGroupsClient
+
+	log *callLog
+}
+
+func (s *batchSecurityGroups) BindRunningSpaces(_ context.Context, guid string, spaceGUIDs []string) (*capi.ToManyRelationship, error) {
+	s.log.add("bind running " + guid + " " + spaceGUIDs[0])
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+func (s *batchSecurityGroups) UnbindStagingSpace(_ context.Context, guid, spaceGUID string) error {
+	s.log.add("unbind staging " + guid + " " + spaceGUID)
+
+	return nil
+}
+
+type batchRoles struct {
+	capi.RolesClient
+
+	log *callLog
+}
+
+func (s *batchRoles) Create(_ context.Context, request *capi.RoleCreateRequest) (*capi.Role, error) {
+	s.log.add("create role " + request.Type + " " + request.Relationships.User.Data.GUID)
+
+	return &capi.Role{Resource: capi.Resource{GUID: "role-guid"}}, nil
+}
+
+func TestBatchExecutor_ExtendedResources(t *testing.T) {
+	t.Parallel()
+
+	log := &callLog{}
+	client := &stubClient{
+		routes:         &batchRoutes{log: log},
+		securityGroups: &batchSecurityGroups{log: log},
+		roles:          &batchRoles{log: log},
+	}
+	host := "www"
+
+	operations := capi.NewBatchBuilder().
+		AddCreateRoute("route", &capi.RouteCreateRequest{Host: &host}).
+		AddInsertRouteDestinations("map", capi.Ref("route").GUID(), capi.RouteDestination{App: capi.RouteDestinationApp{GUID: "app-guid"}}).
+		AddRemoveRouteDestination("unmap", "old-route", "dest-guid").
+		AddBindSecurityGroup("bind", "sg-guid", capi.SecurityGroupLifecycleRunning, "space-guid").
+		AddUnbindSecurityGroup("unbind", "sg-guid", capi.SecurityGroupLifecycleStaging, "space-guid").
+		AddCreateRole("role", &capi.RoleCreateRequest{Type: "space_developer", Relationships: capi.RoleRelationships{
+			User: capi.Relationship{Data: &capi.RelationshipData{GUID: "user-guid"}},
+		}}).
+		AddUpdateMetadata("label", "route", capi.Ref("route").GUID(), &capi.Metadata{Labels: map[string]string{"team": "web"}}).
+		AddBindSecurityGroup("bad", "sg-guid", "sometimes", "space-guid").
+		AddOperation(capi.BatchOperation{ID: "role-update", Type: "update", Resource: "role", Data: "role-guid"}).
+		Build()
+
+	results, err := capi.NewBatchExecutor(client, 1).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	for _, result := range results[:7] {
+		require.NoError(t, result.Error, result.ID)
+	}
+
+	require.ErrorIs(t, results[7].Error, capi.ErrInvalidSecurityGroupLifecycle)
+	require.ErrorIs(t, results[8].Error, capi.ErrUnsupportedOperationType)
+
+	assert.ElementsMatch(t, []string{
+		"create route www",
+		"insert www-guid app-guid",
+		"remove old-route dest-guid",
+		"bind running sg-guid space-guid",
+		"unbind staging sg-guid space-guid",
+		"create role space_developer user-guid",
+		"update route www-guid team=web",
+	}, log.calls)
+}
+
+type batchFeatureFlags struct {
+	capi.FeatureFlagsClient
+
+	log *callLog
+}
+
+func (s *batchFeatureFlags) Update(_ context.Context, name string, request *capi.FeatureFlagUpdateRequest) (*capi.FeatureFlag, error) {
+	s.log.add(fmt.Sprintf("flag %s=%t", name, request.Enabled))
+
+	return &capi.FeatureFlag{Name: name, Enabled: request.Enabled}, nil
+}
+
+type batchEnvVarGroups struct {
+	capi.EnvironmentVariableGroupsClient
+
+	log *callLog
+}
+
+func (s *batchEnvVarGroups) Update(_ context.Context, name string, vars map[string]interface{}) (*capi.EnvironmentVariableGroup, error) {
+	s.log.add(fmt.Sprintf("env %s %v", name, vars))
+
+	return &capi.EnvironmentVariableGroup{Name: name}, nil
+}
+
+type batchIsolationSegments struct {
+	capi.IsolationSegmentsClient
+
+	log *callLog
+}
+
+func (s *batchIsolationSegments) EntitleOrganizations(_ context.Context, guid string, orgGUIDs []string) (*capi.ToManyRelationship, error) {
+	s.log.add("entitle " + guid + " " + strings.Join(orgGUIDs, ","))
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+func (s *batchIsolationSegments) RevokeOrganization(_ context.Context, guid, orgGUID string) error {
+	s.log.add("revoke " + guid + " " + orgGUID)
+
+	return nil
+}
+
+type batchOrganizationQuotas struct {
+	capi.OrganizationQuotasClient
+
+	log *callLog
+}
+
+func (s *batchOrganizationQuotas) ApplyToOrganizations(_ context.Context, quotaGUID string, orgGUIDs []string) (*capi.ToManyRelationship, error) {
+	s.log.add("apply org quota " + quotaGUID + " " + strings.Join(orgGUIDs, ","))
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+type batchSpaceQuotas struct {
+	capi.SpaceQuotasClient
+
+	log *callLog
+}
+
+func (s *batchSpaceQuotas) ApplyToSpaces(_ context.Context, quotaGUID string, spaceGUIDs []string) (*capi.ToManyRelationship, error) {
+	s.log.add("apply space quota " + quotaGUID + " " + strings.Join(spaceGUIDs, ","))
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+func (s *batchSpaceQuotas) RemoveFromSpace(_ context.Context, quotaGUID, spaceGUID string) error {
+	s.log.add("remove space quota " + quotaGUID + " " + spaceGUID)
+
+	return nil
+}
+
+func TestBatchExecutor_FoundationResources(t *testing.T) {
+	t.Parallel()
+
+	log := &callLog{}
+	client := &stubClient{
+		featureFlags: &batchFeatureFlags{log: log},
+		envVarGroups: &batchEnvVarGroups{log: log},
+		isoSegments:  &batchIsolationSegments{log: log},
+		orgQuotas:    &batchOrganizationQuotas{log: log},
+		spaceQuotas:  &batchSpaceQuotas{log: log},
+	}
+
+	operations := capi.NewBatchBuilder().
+		AddUpdateFeatureFlag("flag", "diego_docker", true).
+		AddUpdateEnvironmentVariableGroup("env", "running", map[string]interface{}{"HTTP_PROXY": nil}).
+		AddEntitleIsolationSegment("entitle", "seg-guid", "org-1", "org-2").
+		AddRevokeIsolationSegment("revoke", "seg-guid", "org-3").
+		AddApplyOrganizationQuota("org-quota", "quota-guid", "org-1").
+		AddApplySpaceQuota("space-quota", "space-quota-guid", "space-1").
+		AddRemoveSpaceQuota("unquota", "space-quota-guid", "space-2").
+		AddOperation(capi.BatchOperation{ID: "flag-delete", Type: "delete", Resource: "feature_flag", Data: "diego_docker"}).
+		Build()
+
+	results, err := capi.NewBatchExecutor(client, 1).Execute(context.Background(), operations)
+	require.NoError(t, err)
+
+	for _, result := range results[:7] {
+		require.NoError(t, result.Error, result.ID)
+	}
+
+	require.ErrorIs(t, results[7].Error, capi.ErrUnsupportedOperationType)
+
+	assert.ElementsMatch(t, []string{
+		"flag diego_docker=true",
+		"env running map[HTTP_PROXY:<nil>]",
+		"entitle seg-guid org-1,org-2",
+		"revoke seg-guid org-3",
+		"apply org quota quota-guid org-1",
+		"apply space quota space-quota-guid space-1",
+		"remove space quota space-quota-guid space-2",
+	}, log.calls)
+}
+
+func TestParseBatchOperations(t *testing.T) {
+	t.Parallel()
+
+	operations, err := capi.ParseBatchOperations([]byte(`
+operations:
+  - id: org
+    type: create
+    resource: organization
+    data: {name: team}
+  - id: dev
+    type: create
+    resource: space
+    data:
+      name: dev
+      relationships: {organization: {data: {guid: "${ref:org.GUID}"}}}
+  - id: rename
+    type: update
+    resource: app
+    guid: app-guid
+    depends_on: [dev]
+    data: {name: api}
+  - id: old
+    type: delete
+    resource: space
+    guid: old-guid
+  - id: label
+    type: update
+    resource: metadata
+    guid: app-guid
+    data: {resource: app, metadata: {labels: {team: web}}}
+  - id: unmap
+    type: delete
+    resource: route_destination
+    data: {route_guid: route-guid, destination_guid: dest-guid}
+  - id: flag
+    type: update
+    resource: feature_flag
+    guid: diego_docker
+    data: {enabled: true}
+  - id: revoke
+    type: delete
+    resource: isolation_segment_entitlement
+    data: {isolation_segment_guid: seg-guid, organization_guids: [org-guid]}
+`))
+	require.NoError(t, err)
+	require.Len(t, operations, 8)
+
+	assert.Equal(t, "team", operations[0].Data.(*capi.OrganizationCreateRequest).Name)
+	assert.Equal(t, capi.Ref("org").GUID(), operations[1].Data.(*capi.SpaceCreateRequest).Relationships.Organization.Data.GUID)
+
+	update := operations[2].Data.(*capi.UpdateDataWrapper[capi.AppUpdateRequest])
+	assert.Equal(t, "app-guid", update.GUID)
+	assert.Equal(t, "api", *update.Request.Name)
+	assert.Equal(t, []string{"dev"}, operations[2].DependsOn)
+
+	assert.Equal(t, "old-guid", operations[3].Data)
+	assert.Equal(t, &capi.MetadataUpdate{
+		Resource: "app",
+		GUID:     "app-guid",
+		Metadata: &capi.Metadata{Labels: map[string]string{"team": "web"}},
+	}, operations[4].Data)
+	assert.Equal(t, &capi.RouteDestinationRef{RouteGUID: "route-guid", DestinationGUID: "dest-guid"}, operations[5].Data)
+	assert.Equal(t, &capi.FeatureFlagChange{Name: "diego_docker", Enabled: true}, operations[6].Data)
+	assert.Equal(t, &capi.IsolationSegmentEntitlement{IsolationSegmentGUID: "seg-guid", OrganizationGUIDs: []string{"org-guid"}}, operations[7].Data)
+
+	plan, err := capi.PlanBatchOperations(operations)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"org"}, plan[1].DependsOn)
+	assert.Equal(t, 2, plan[2].Level)
+}
+
+func TestParseBatchOperations_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		spec string
+		want error
+	}{
+		{name: "not a list", spec: `{operations: {id: x}}`, want: capi.ErrInvalidBatchSpec},
+		{name: "missing id", spec: `[{type: get, resource: app, guid: g}]`, want: capi.ErrInvalidBatchSpec},
+		{name: "unknown resource", spec: `[{id: a, type: get, resource: widget, guid: g}]`, want: capi.ErrUnsupportedResourceType},
+		{name: "role update", spec: `[{id: a, type: update, resource: role, guid: g, data: {}}]`, want: capi.ErrUnsupportedOperationType},
+		{name: "missing guid", spec: `[{id: a, type: delete, resource: space}]`, want: capi.ErrInvalidBatchSpec},
+		{name: "missing data", spec: `[{id: a, type: create, resource: space}]`, want: capi.ErrInvalidBatchSpec},
+		{name: "unknown field", spec: `[{id: a, type: create, resource: space, data: {nmae: dev}}]`, want: capi.ErrInvalidBatchSpec},
+		{name: "unknown key", spec: `[{id: a, type: get, resource: app, uuid: g}]`, want: capi.ErrInvalidBatchSpec},
+		{name: "unnamed flag", spec: `[{id: a, type: update, resource: feature_flag, data: {enabled: true}}]`, want: capi.ErrInvalidBatchSpec},
+	}
+
+	for _, tt := range tests {
+		_, err := capi.ParseBatchOperations([]byte(tt.spec))
+		require.ErrorIs(t, err, tt.want, tt.name)
+	}
+}
@@ -0,0 +1,762 @@
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// batchRollbackHandler knows how to snapshot one resource type and put a
+// snapshot back. restore and recreate are nil when the resource cannot be
+// restored that way.
+type batchRollbackHandler struct {
+	snapshot func(ctx context.Context, client Client, guid string) (interface{}, error)
+	// restore PATCHes the snapshot's mutable fields back onto the resource.
+	restore func(ctx context.Context, client Client, guid string, snapshot json.RawMessage) error
+	// recreate creates a deleted resource again and returns its new GUID.
+	recreate func(ctx context.Context, client Client, snapshot json.RawMessage) (string, error)
+}
+
+// batchRollbackHandlerFor returns the handler of a batch resource type.
+// Apps, organizations and service instances are restored after updates but
+// not recreated after deletes: their packages, droplets, spaces or broker
+// state are gone with them.
+func batchRollbackHandlerFor(resource string) (batchRollbackHandler, bool) {
+	switch resource {
+	case "app":
+		return appRollbackHandler(), true
+	case "space":
+		return spaceRollbackHandler(), true
+	case "organization":
+		return organizationRollbackHandler(), true
+	case "route":
+		return routeRollbackHandler(), true
+	case "service_instance":
+		return serviceInstanceRollbackHandler(), true
+	case "security_group":
+		return securityGroupRollbackHandler(), true
+	case "organization_quota":
+		return organizationQuotaRollbackHandler(), true
+	case "space_quota":
+		return spaceQuotaRollbackHandler(), true
+	default:
+		return batchRollbackHandler{}, false
+	}
+}
+
+func decodeBatchSnapshot[T any](raw json.RawMessage) (*T, error) {
+	var value T
+
+	err := json.Unmarshal(raw, &value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return &value, nil
+}
+
+func appRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.Apps().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			app, err := decodeBatchSnapshot[App](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.Apps().Update(ctx, guid, &AppUpdateRequest{
+				Name:      &app.Name,
+				Lifecycle: &app.Lifecycle,
+				Metadata:  app.Metadata,
+			})
+
+			return err
+		},
+	}
+}
+
+func spaceRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.Spaces().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			space, err := decodeBatchSnapshot[Space](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.Spaces().Update(ctx, guid, &SpaceUpdateRequest{Name: &space.Name, Metadata: space.Metadata})
+
+			return err
+		},
+		recreate: func(ctx context.Context, client Client, raw json.RawMessage) (string, error) {
+			space, err := decodeBatchSnapshot[Space](raw)
+			if err != nil {
+				return "", err
+			}
+
+			created, err := client.Spaces().Create(ctx, &SpaceCreateRequest{
+				Name:          space.Name,
+				Relationships: SpaceRelationships{Organization: space.Relationships.Organization},
+				Metadata:      space.Metadata,
+			})
+			if err != nil {
+				return "", err
+			}
+
+			quota := space.Relationships.Quota
+			if quota != nil && quota.Data != nil && quota.Data.GUID != "" {
+				_, err = client.SpaceQuotas().ApplyToSpaces(ctx, quota.Data.GUID, []string{created.GUID})
+				if err != nil {
+					return created.GUID, fmt.Errorf("recreated space %s but failed to apply its quota: %w", created.GUID, err)
+				}
+			}
+
+			return created.GUID, nil
+		},
+	}
+}
+
+func organizationRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.Organizations().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			org, err := decodeBatchSnapshot[Organization](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.Organizations().Update(ctx, guid, &OrganizationUpdateRequest{
+				Name:      &org.Name,
+				Suspended: &org.Suspended,
+				Metadata:  org.Metadata,
+			})
+
+			return err
+		},
+	}
+}
+
+func routeRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.Routes().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			route, err := decodeBatchSnapshot[Route](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.Routes().Update(ctx, guid, &RouteUpdateRequest{Options: route.Options, Metadata: route.Metadata})
+
+			return err
+		},
+		recreate: recreateRoute,
+	}
+}
+
+// recreateRoute creates the route again and maps it back to the apps it was
+// mapped to.
+func recreateRoute(ctx context.Context, client Client, raw json.RawMessage) (string, error) {
+	route, err := decodeBatchSnapshot[Route](raw)
+	if err != nil {
+		return "", err
+	}
+
+	request := &RouteCreateRequest{
+		Port:          route.Port,
+		Relationships: route.Relationships,
+		Options:       route.Options,
+		Metadata:      route.Metadata,
+	}
+
+	if route.Host != "" {
+		request.Host = &route.Host
+	}
+
+	if route.Path != "" {
+		request.Path = &route.Path
+	}
+
+	created, err := client.Routes().Create(ctx, request)
+	if err != nil {
+		return "", err
+	}
+
+	if len(route.Destinations) == 0 {
+		return created.GUID, nil
+	}
+
+	destinations := make([]RouteDestination, 0, len(route.Destinations))
+
+	for _, destination := range route.Destinations {
+		destination.GUID = ""
+		if destination.App.Process != nil {
+			destination.App.Process = &Process{Type: destination.App.Process.Type}
+		}
+
+		destinations = append(destinations, destination)
+	}
+
+	_, err = client.Routes().InsertDestinations(ctx, created.GUID, destinations)
+	if err != nil {
+		return created.GUID, fmt.Errorf("recreated route %s but failed to restore its destinations: %w", created.GUID, err)
+	}
+
+	return created.GUID, nil
+}
+
+func serviceInstanceRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.ServiceInstances().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			instance, err := decodeBatchSnapshot[ServiceInstance](raw)
+			if err != nil {
+				return err
+			}
+
+			request := &ServiceInstanceUpdateRequest{
+				Name:     &instance.Name,
+				Tags:     instance.Tags,
+				Metadata: instance.Metadata,
+			}
+
+			if instance.Type == "user-provided" {
+				request.SyslogDrainURL = instance.SyslogDrainURL
+				request.RouteServiceURL = instance.RouteServiceURL
+			}
+
+			_, err = client.ServiceInstances().Update(ctx, guid, request)
+
+			return err
+		},
+	}
+}
+
+func securityGroupRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.SecurityGroups().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			group, err := decodeBatchSnapshot[SecurityGroup](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.SecurityGroups().Update(ctx, guid, &SecurityGroupUpdateRequest{
+				Name:            &group.Name,
+				GloballyEnabled: &group.GloballyEnabled,
+				Rules:           group.Rules,
+			})
+
+			return err
+		},
+		recreate: func(ctx context.Context, client Client, raw json.RawMessage) (string, error) {
+			group, err := decodeBatchSnapshot[SecurityGroup](raw)
+			if err != nil {
+				return "", err
+			}
+
+			created, err := client.SecurityGroups().Create(ctx, &SecurityGroupCreateRequest{
+				Name:            group.Name,
+				GloballyEnabled: &group.GloballyEnabled,
+				Rules:           group.Rules,
+				Relationships:   &group.Relationships,
+			})
+			if err != nil {
+				return "", err
+			}
+
+			return created.GUID, nil
+		},
+	}
+}
+
+func organizationQuotaRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.OrganizationQuotas().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			quota, err := decodeBatchSnapshot[OrganizationQuota](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.OrganizationQuotas().Update(ctx, guid, &OrganizationQuotaUpdateRequest{
+				Name:     &quota.Name,
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+				Domains:  quota.Domains,
+				Metadata: quota.Metadata,
+			})
+
+			return err
+		},
+		recreate: func(ctx context.Context, client Client, raw json.RawMessage) (string, error) {
+			quota, err := decodeBatchSnapshot[OrganizationQuota](raw)
+			if err != nil {
+				return "", err
+			}
+
+			created, err := client.OrganizationQuotas().Create(ctx, &OrganizationQuotaCreateRequest{
+				Name:     quota.Name,
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+				Domains:  quota.Domains,
+				Metadata: quota.Metadata,
+			})
+			if err != nil {
+				return "", err
+			}
+
+			if quota.Relationships == nil || len(quota.Relationships.Organizations.Data) == 0 {
+				return created.GUID, nil
+			}
+
+			orgGUIDs := make([]string, 0, len(quota.Relationships.Organizations.Data))
+			for _, org := range quota.Relationships.Organizations.Data {
+				orgGUIDs = append(orgGUIDs, org.GUID)
+			}
+
+			_, err = client.OrganizationQuotas().ApplyToOrganizations(ctx, created.GUID, orgGUIDs)
+			if err != nil {
+				return created.GUID, fmt.Errorf("recreated organization quota %s but failed to apply it: %w", created.GUID, err)
+			}
+
+			return created.GUID, nil
+		},
+	}
+}
+
+func spaceQuotaRollbackHandler() batchRollbackHandler {
+	return batchRollbackHandler{
+		snapshot: func(ctx context.Context, client Client, guid string) (interface{}, error) {
+			return client.SpaceQuotas().Get(ctx, guid)
+		},
+		restore: func(ctx context.Context, client Client, guid string, raw json.RawMessage) error {
+			quota, err := decodeBatchSnapshot[SpaceQuotaV3](raw)
+			if err != nil {
+				return err
+			}
+
+			_, err = client.SpaceQuotas().Update(ctx, guid, &SpaceQuotaV3UpdateRequest{
+				Name:     &quota.Name,
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+				Metadata: quota.Metadata,
+			})
+
+			return err
+		},
+		recreate: func(ctx context.Context, client Client, raw json.RawMessage) (string, error) {
+			quota, err := decodeBatchSnapshot[SpaceQuotaV3](raw)
+			if err != nil {
+				return "", err
+			}
+
+			request := &SpaceQuotaV3CreateRequest{
+				Name:     quota.Name,
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+				Metadata: quota.Metadata,
+			}
+
+			if quota.Relationships != nil {
+				request.Relationships = *quota.Relationships
+			}
+
+			created, err := client.SpaceQuotas().Create(ctx, request)
+			if err != nil {
+				return "", err
+			}
+
+			return created.GUID, nil
+		},
+	}
+}
+
+// batchOperationTarget returns the GUID an update or delete operation acts
+// on and, for updates, its request.
+func batchOperationTarget(operation BatchOperation) (string, interface{}) {
+	if guid, ok := operation.Data.(string); ok {
+		return guid, nil
+	}
+
+	value := reflect.ValueOf(operation.Data)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return "", nil
+	}
+
+	guid := value.Elem().FieldByName("GUID")
+	request := value.Elem().FieldByName("Request")
+
+	if !guid.IsValid() || guid.Kind() != reflect.String || !request.IsValid() {
+		return "", nil
+	}
+
+	return guid.String(), request.Interface()
+}
+
+// batchMetadata returns the Metadata field of a resource or request, if any.
+func batchMetadata(data interface{}) *Metadata {
+	value := reflect.ValueOf(data)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	field := value.FieldByName("Metadata")
+	if !field.IsValid() {
+		return nil
+	}
+
+	metadata, _ := field.Interface().(*Metadata)
+
+	return metadata
+}
+
+// addedMetadataKeys lists the labels and annotations request sets that
+// before does not have.
+func addedMetadataKeys(before, request *Metadata) []string {
+	if request == nil {
+		return nil
+	}
+
+	if before == nil {
+		before = &Metadata{}
+	}
+
+	var added []string
+
+	for key := range request.Labels {
+		if _, ok := before.Labels[key]; !ok {
+			added = append(added, "label "+key)
+		}
+	}
+
+	for key := range request.Annotations {
+		if _, ok := before.Annotations[key]; !ok {
+			added = append(added, "annotation "+key)
+		}
+	}
+
+	sort.Strings(added)
+
+	return added
+}
+
+// snapshotOperations starts the transaction's journal and records the state
+// of every resource an update or delete is about to change. Targets given as
+// Ref placeholders were created by the transaction itself and need no
+// snapshot. Any failure aborts the transaction before it runs.
+func (t *BatchTransaction) snapshotOperations(ctx context.Context) error {
+	t.journal = newBatchJournal(t.journalID, t.operations)
+
+	for i, operation := range t.operations {
+		if operation.Type != "update" && operation.Type != "delete" {
+			continue
+		}
+
+		entry := &t.journal.Entries[i]
+
+		guid, request := batchOperationTarget(operation)
+
+		// Metadata updates are restored like updates of the resource they change.
+		if update, ok := operation.Data.(*MetadataUpdate); ok {
+			guid, request = update.GUID, update
+			entry.Resource = update.Resource
+		}
+
+		entry.GUID = guid
+
+		handler, ok := batchRollbackHandlerFor(entry.Resource)
+		if !ok || guid == "" || batchRefPattern.MatchString(guid) {
+			continue
+		}
+
+		snapshot, err := handler.snapshot(ctx, t.executor.client, guid)
+		if err != nil {
+			return fmt.Errorf("%w: %s (%s %s): %w", ErrBatchSnapshotFailed, operation.ID, operation.Resource, guid, err)
+		}
+
+		entry.Snapshot, err = json.Marshal(snapshot)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrBatchSnapshotFailed, operation.ID, err)
+		}
+
+		if operation.Type == "update" {
+			entry.AddedMetadata = addedMetadataKeys(batchMetadata(snapshot), batchMetadata(request))
+		}
+	}
+
+	return t.saveJournal()
+}
+
+// saveJournal persists the journal when the transaction has a store.
+func (t *BatchTransaction) saveJournal() error {
+	if t.journalStore == nil || t.journal == nil {
+		return nil
+	}
+
+	t.journal.UpdatedAt = time.Now().UTC()
+
+	err := t.journalStore.Save(t.journal)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBatchJournalSaveFailed, err)
+	}
+
+	return nil
+}
+
+// rollbackJournal undoes every applied operation of the journal, deepest
+// dependency level first and in reverse order within a level, so dependent
+// resources are handled before their dependencies. It runs sequentially and
+// saves the journal after each step, so an interrupted rollback can be
+// resumed where it stopped. Pending entries, left by a transaction that was
+// interrupted while running, are undone as if they had been applied.
+func (t *BatchTransaction) rollbackJournal(ctx context.Context) error {
+	var order []int
+
+	for i, entry := range t.journal.Entries {
+		switch entry.State {
+		case BatchJournalApplied, BatchJournalRollbackFailed, BatchJournalPending:
+			if entry.Type == "create" || entry.Type == "update" || entry.Type == "delete" {
+				order = append(order, i)
+			}
+		case BatchJournalFailed, BatchJournalRolledBack, BatchJournalIrreversible:
+		}
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		first, second := t.journal.Entries[order[a]], t.journal.Entries[order[b]]
+		if first.Level != second.Level {
+			return first.Level > second.Level
+		}
+
+		return order[a] > order[b]
+	})
+
+	var saveErr error
+
+	for _, i := range order {
+		entry := &t.journal.Entries[i]
+		wasPending := entry.State == BatchJournalPending
+
+		entry.Error = ""
+
+		err := t.rollbackEntry(ctx, entry, wasPending)
+		if err != nil {
+			entry.State = BatchJournalRollbackFailed
+			entry.Error = err.Error()
+		}
+
+		err = t.saveJournal()
+		if err != nil && saveErr == nil {
+			saveErr = err
+		}
+	}
+
+	return errors.Join(t.rollbackReport(), saveErr)
+}
+
+// rollbackEntry undoes one operation and sets the entry's state, unless it
+// returns an error.
+func (t *BatchTransaction) rollbackEntry(ctx context.Context, entry *BatchJournalEntry, wasPending bool) error {
+	if entry.Type == "create" {
+		return t.deleteCreated(ctx, entry, wasPending)
+	}
+
+	// The target was created by this transaction; undoing its create
+	// removes it.
+	if batchRefPattern.MatchString(entry.GUID) {
+		entry.State = BatchJournalRolledBack
+
+		return nil
+	}
+
+	handler, ok := batchRollbackHandlerFor(entry.Resource)
+
+	switch {
+	case !ok || entry.Snapshot == nil:
+		markIrreversible(entry, "no snapshot of the prior state")
+
+		return nil
+	case entry.Type == "update" && handler.restore == nil:
+		markIrreversible(entry, "cannot be restored")
+
+		return nil
+	case entry.Type == "delete" && handler.recreate == nil:
+		markIrreversible(entry, "cannot be recreated")
+
+		return nil
+	case entry.Type == "update":
+		err := handler.restore(ctx, t.executor.client, entry.GUID, entry.Snapshot)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s %s: %w", entry.Resource, entry.GUID, err)
+		}
+
+		err = t.removeAddedMetadata(ctx, entry)
+		if err != nil {
+			return err
+		}
+	default:
+		if entry.RestoredGUID != "" {
+			entry.State = BatchJournalRolledBack
+
+			return nil
+		}
+
+		// A pending delete may never have reached the API.
+		if wasPending {
+			_, err := handler.snapshot(ctx, t.executor.client, entry.GUID)
+			if err == nil {
+				entry.State = BatchJournalRolledBack
+
+				return nil
+			}
+		}
+
+		guid, err := handler.recreate(ctx, t.executor.client, entry.Snapshot)
+		entry.RestoredGUID = guid
+
+		if err != nil {
+			return fmt.Errorf("failed to recreate %s %s: %w", entry.Resource, entry.GUID, err)
+		}
+	}
+
+	entry.State = BatchJournalRolledBack
+
+	return nil
+}
+
+// deleteCreated removes a resource the transaction created. A resource that
+// is already gone counts as removed.
+func (t *BatchTransaction) deleteCreated(ctx context.Context, entry *BatchJournalEntry, wasPending bool) error {
+	if entry.GUID == "" {
+		reason := "no GUID in result"
+		if wasPending {
+			reason = "interrupted before its result was recorded"
+		}
+
+		markIrreversible(entry, reason)
+
+		return nil
+	}
+
+	results, err := t.executor.Execute(ctx, []BatchOperation{{
+		ID:       "rollback_" + entry.OperationID,
+		Type:     "delete",
+		Resource: entry.Resource,
+		Data:     entry.GUID,
+	}})
+	if err != nil {
+		return err
+	}
+
+	if !results[0].Success && !IsNotFound(results[0].Error) {
+		return fmt.Errorf("failed to delete %s %s: %w", entry.Resource, entry.GUID, results[0].Error)
+	}
+
+	entry.State = BatchJournalRolledBack
+
+	return nil
+}
+
+// removeAddedMetadata removes the labels and annotations an update added,
+// which restoring the snapshot leaves in place because the API merges
+// metadata. Resource types the metadata client does not cover keep them,
+// and the rollback reports them.
+func (t *BatchTransaction) removeAddedMetadata(ctx context.Context, entry *BatchJournalEntry) error {
+	if len(entry.AddedMetadata) == 0 {
+		return nil
+	}
+
+	_, err := MetadataResourcePath(entry.Resource)
+	if err != nil {
+		return nil //nolint:nilerr // reported by rollbackReport instead
+	}
+
+	var patch MetadataPatch
+
+	for _, added := range entry.AddedMetadata {
+		kind, key, _ := strings.Cut(added, " ")
+
+		switch kind {
+		case "label":
+			patch.RemoveLabels = append(patch.RemoveLabels, key)
+		case "annotation":
+			patch.RemoveAnnotations = append(patch.RemoveAnnotations, key)
+		}
+	}
+
+	_, err = t.executor.client.Metadata().Patch(ctx, entry.Resource, entry.GUID, patch)
+	if err != nil {
+		return fmt.Errorf("failed to remove added metadata of %s %s: %w", entry.Resource, entry.GUID, err)
+	}
+
+	entry.AddedMetadata = nil
+
+	return nil
+}
+
+func markIrreversible(entry *BatchJournalEntry, reason string) {
+	entry.State = BatchJournalIrreversible
+	entry.Error = reason
+}
+
+// rollbackReport returns ErrRollbackIncomplete describing every entry that
+// could not be undone and every label or annotation a restored update left
+// on a resource type without metadata support, or nil when the rollback is
+// complete.
+func (t *BatchTransaction) rollbackReport() error {
+	var irreversible, failures []string
+
+	for _, entry := range t.journal.Entries {
+		switch entry.State {
+		case BatchJournalIrreversible:
+			irreversible = append(irreversible,
+				fmt.Sprintf("%s (%s %s: %s)", entry.OperationID, entry.Type, entry.Resource, entry.Error))
+		case BatchJournalRollbackFailed:
+			failures = append(failures, fmt.Sprintf("%s: %s", entry.OperationID, entry.Error))
+		case BatchJournalRolledBack:
+			if entry.Type == "update" && len(entry.AddedMetadata) > 0 {
+				irreversible = append(irreversible, fmt.Sprintf("%s (%s %s left %s)",
+					entry.OperationID, entry.Type, entry.Resource, strings.Join(entry.AddedMetadata, ", ")))
+			}
+		case BatchJournalPending, BatchJournalApplied, BatchJournalFailed:
+		}
+	}
+
+	if len(irreversible) == 0 && len(failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: irreversible operations %v; failed rollbacks %v",
+		ErrRollbackIncomplete, irreversible, failures)
+}
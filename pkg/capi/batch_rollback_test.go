@@ -0,0 +1,344 @@
+package capi_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errSpaceCreateDown = errors.New("space create unavailable")
+
+// rollbackSpaces keeps spaces in memory and applies updates the way the API
+// does: labels and annotations are merged, not replaced.
+type rollbackSpaces struct {
+	capi.SpacesClient
+
+	mu        sync.Mutex
+	spaces    map[string]*capi.Space
+	createErr error
+	created   int
+}
+
+func (s *rollbackSpaces) Get(_ context.Context, guid string, _ ...capi.SpaceGetOption) (*capi.Space, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	space, ok := s.spaces[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	copied := *space
+
+	return &copied, nil
+}
+
+func (s *rollbackSpaces) Create(_ context.Context, request *capi.SpaceCreateRequest) (*capi.Space, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.createErr != nil {
+		return nil, s.createErr
+	}
+
+	s.created++
+
+	space := &capi.Space{
+		Resource:      capi.Resource{GUID: request.Name + "-recreated"},
+		Name:          request.Name,
+		Relationships: request.Relationships,
+		Metadata:      request.Metadata,
+	}
+	s.spaces[space.GUID] = space
+
+	return space, nil
+}
+
+func (s *rollbackSpaces) Update(_ context.Context, guid string, request *capi.SpaceUpdateRequest) (*capi.Space, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	space, ok := s.spaces[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	if request.Name != nil {
+		space.Name = *request.Name
+	}
+
+	if request.Metadata != nil {
+		if space.Metadata == nil {
+			space.Metadata = &capi.Metadata{Labels: map[string]string{}}
+		}
+
+		for key, value := range request.Metadata.Labels {
+			space.Metadata.Labels[key] = value
+		}
+	}
+
+	return space, nil
+}
+
+func (s *rollbackSpaces) Delete(_ context.Context, guid string) (*capi.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.spaces[guid]; !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	delete(s.spaces, guid)
+
+	return &capi.Job{}, nil
+}
+
+// rollbackMetadata patches the labels of rollbackSpaces.
+type rollbackMetadata struct {
+	capi.MetadataClient
+
+	spaces *rollbackSpaces
+}
+
+func (s *rollbackMetadata) Patch(_ context.Context, resourceType, guid string, patch capi.MetadataPatch) (*capi.Metadata, error) {
+	s.spaces.mu.Lock()
+	defer s.spaces.mu.Unlock()
+
+	space, ok := s.spaces.spaces[guid]
+	if resourceType != "space" || !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	for _, key := range patch.RemoveLabels {
+		delete(space.Metadata.Labels, key)
+	}
+
+	return space.Metadata, nil
+}
+
+func rollbackFixture() (*stubClient, *rollbackSpaces) {
+	spaces := &rollbackSpaces{spaces: map[string]*capi.Space{
+		"dev-guid": {
+			Resource: capi.Resource{GUID: "dev-guid"},
+			Name:     "dev",
+			Metadata: &capi.Metadata{Labels: map[string]string{"team": "a"}},
+		},
+		"old-guid": {
+			Resource: capi.Resource{GUID: "old-guid"},
+			Name:     "old",
+			Relationships: capi.SpaceRelationships{Organization: capi.Relationship{
+				Data: &capi.RelationshipData{GUID: "org-guid"},
+			}},
+		},
+	}}
+
+	return &stubClient{
+		spaces:        spaces,
+		organizations: &graphOrgs{recorder: &graphRecorder{}},
+		metadata:      &rollbackMetadata{spaces: spaces},
+	}, spaces
+}
+
+func journalStates(journal *capi.BatchJournal) map[string]capi.BatchJournalState {
+	states := map[string]capi.BatchJournalState{}
+	for _, entry := range journal.Entries {
+		states[entry.OperationID] = entry.State
+	}
+
+	return states
+}
+
+func TestBatchTransaction_RollbackRestoresUpdatesAndDeletes(t *testing.T) {
+	t.Parallel()
+
+	client, spaces := rollbackFixture()
+	store := capi.NewFileBatchJournalStore(t.TempDir())
+	renamed := "renamed"
+
+	operations := capi.NewBatchBuilder().
+		AddUpdateSpace("rename", "dev-guid", &capi.SpaceUpdateRequest{
+			Name:     &renamed,
+			Metadata: &capi.Metadata{Labels: map[string]string{"team": "b", "env": "prod"}},
+		}).
+		AddDeleteSpace("drop", "old-guid").
+		AddCreateSpace("add", spaceIn("new", "org-guid")).
+		AddCreateOrganization("org", &capi.OrganizationCreateRequest{Name: "broken"}).
+		Build()
+
+	transaction := capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).
+		SetRollback(true).
+		SetJournal(store, "tx-1")
+
+	for _, operation := range operations {
+		transaction.Add(operation)
+	}
+
+	_, err := transaction.Execute(context.Background())
+	require.ErrorIs(t, err, capi.ErrTransactionFailed)
+	require.NotErrorIs(t, err, capi.ErrRollbackIncomplete)
+
+	restored := spaces.spaces["dev-guid"]
+	assert.Equal(t, "dev", restored.Name)
+	assert.Equal(t, map[string]string{"team": "a"}, restored.Metadata.Labels)
+
+	recreated, ok := spaces.spaces["old-recreated"]
+	require.True(t, ok)
+	assert.Equal(t, "org-guid", recreated.Relationships.Organization.Data.GUID)
+	assert.NotContains(t, spaces.spaces, "new-recreated")
+	assert.Equal(t, 2, spaces.created)
+
+	journal, err := store.Load("tx-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]capi.BatchJournalState{
+		"rename": capi.BatchJournalRolledBack,
+		"drop":   capi.BatchJournalRolledBack,
+		"add":    capi.BatchJournalRolledBack,
+		"org":    capi.BatchJournalFailed,
+	}, journalStates(journal))
+	assert.Equal(t, "old-recreated", journal.Entries[1].RestoredGUID)
+	assert.Empty(t, journal.Entries[0].AddedMetadata)
+	assert.Equal(t, journalStates(journal), journalStates(transaction.Journal()))
+}
+
+func TestBatchTransaction_ResumeRollback(t *testing.T) {
+	t.Parallel()
+
+	client, spaces := rollbackFixture()
+	spaces.createErr = errSpaceCreateDown
+	store := capi.NewFileBatchJournalStore(t.TempDir())
+
+	transaction := capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).
+		SetRollback(true).
+		SetJournal(store, "tx-2").
+		Add(capi.BatchOperation{ID: "drop", Type: "delete", Resource: "space", Data: "old-guid"}).
+		Add(capi.BatchOperation{ID: "org", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "broken"}})
+
+	_, err := transaction.Execute(context.Background())
+	require.ErrorIs(t, err, capi.ErrRollbackIncomplete)
+	assert.Contains(t, err.Error(), errSpaceCreateDown.Error())
+	assert.Equal(t, capi.BatchJournalRollbackFailed, transaction.Journal().Entries[0].State)
+
+	spaces.mu.Lock()
+	spaces.createErr = nil
+	spaces.mu.Unlock()
+
+	resumed := capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).SetJournal(store, "tx-2")
+	require.NoError(t, resumed.ResumeRollback(context.Background(), "tx-2"))
+
+	journal, err := store.Load("tx-2")
+	require.NoError(t, err)
+	assert.Equal(t, capi.BatchJournalRolledBack, journal.Entries[0].State)
+	assert.Equal(t, "old-recreated", journal.Entries[0].RestoredGUID)
+	assert.Empty(t, journal.Entries[0].Error)
+	assert.Contains(t, spaces.spaces, "old-recreated")
+
+	// Resuming a finished rollback changes nothing.
+	require.NoError(t, resumed.ResumeRollback(context.Background(), "tx-2"))
+	assert.Equal(t, 1, spaces.created)
+
+	err = capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).ResumeRollback(context.Background(), "tx-2")
+	require.ErrorIs(t, err, capi.ErrBatchJournalNotSet)
+}
+
+func TestBatchTransaction_SnapshotFailureAbortsBeforeRunning(t *testing.T) {
+	t.Parallel()
+
+	client, _ := rollbackFixture()
+	recorder := client.organizations.(*graphOrgs).recorder
+
+	transaction := capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).
+		SetRollback(true).
+		Add(capi.BatchOperation{ID: "org", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "team"}}).
+		Add(capi.BatchOperation{ID: "drop", Type: "delete", Resource: "space", Data: "missing-guid"})
+
+	results, err := transaction.Execute(context.Background())
+	require.ErrorIs(t, err, capi.ErrBatchSnapshotFailed)
+	require.ErrorIs(t, err, capi.ErrNotFound)
+	assert.Nil(t, results)
+	assert.Empty(t, recorder.calls)
+}
+
+// rollbackOrgs adds Get and Delete to graphOrgs.
+type rollbackOrgs struct {
+	*graphOrgs
+}
+
+func (s *rollbackOrgs) Get(_ context.Context, guid string) (*capi.Organization, error) {
+	return &capi.Organization{Resource: capi.Resource{GUID: guid}, Name: "gone"}, nil
+}
+
+func (s *rollbackOrgs) Delete(context.Context, string) (*capi.Job, error) {
+	return &capi.Job{}, nil
+}
+
+func TestBatchTransaction_RollbackReportsIrreversibleOperations(t *testing.T) {
+	t.Parallel()
+
+	client, _ := rollbackFixture()
+	client.organizations = &rollbackOrgs{graphOrgs: &graphOrgs{recorder: &graphRecorder{}}}
+
+	transaction := capi.NewBatchTransaction(capi.NewBatchExecutor(client, 1)).
+		SetRollback(true).
+		Add(capi.BatchOperation{ID: "drop", Type: "delete", Resource: "organization", Data: "gone-guid"}).
+		Add(capi.BatchOperation{ID: "team", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "team"}}).
+		Add(capi.BatchOperation{ID: "rename", Type: "update", Resource: "space", Data: &capi.UpdateDataWrapper[capi.SpaceUpdateRequest]{
+			GUID:    capi.Ref("team").GUID(),
+			Request: &capi.SpaceUpdateRequest{},
+		}}).
+		Add(capi.BatchOperation{ID: "org", Type: "create", Resource: "organization", Data: &capi.OrganizationCreateRequest{Name: "broken"}})
+
+	_, err := transaction.Execute(context.Background())
+	require.ErrorIs(t, err, capi.ErrRollbackIncomplete)
+	assert.Contains(t, err.Error(), "drop (delete organization: cannot be recreated)")
+
+	journal := transaction.Journal()
+	assert.Equal(t, map[string]capi.BatchJournalState{
+		"drop":   capi.BatchJournalIrreversible,
+		"team":   capi.BatchJournalRolledBack,
+		"rename": capi.BatchJournalFailed,
+		"org":    capi.BatchJournalFailed,
+	}, journalStates(journal))
+	assert.Nil(t, journal.Entries[2].Snapshot)
+}
+
+func TestFileBatchJournalStore(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	store := capi.NewFileBatchJournalStore(dir)
+
+	_, err := store.Load("missing")
+	require.ErrorIs(t, err, capi.ErrBatchJournalNotFound)
+
+	_, err = store.Load("../escape")
+	require.ErrorIs(t, err, capi.ErrInvalidBatchJournalID)
+
+	journal := &capi.BatchJournal{ID: "tx", Entries: []capi.BatchJournalEntry{{
+		OperationID: "op",
+		Type:        "delete",
+		Resource:    "space",
+		GUID:        "guid",
+		Snapshot:    []byte(`{"name":"dev"}`),
+		State:       capi.BatchJournalApplied,
+	}}}
+	require.NoError(t, store.Save(journal))
+
+	journal.Entries[0].State = capi.BatchJournalRolledBack
+	require.NoError(t, store.Save(journal))
+
+	loaded, err := store.Load("tx")
+	require.NoError(t, err)
+	assert.Equal(t, capi.BatchJournalRolledBack, loaded.Entries[0].State)
+	assert.JSONEq(t, `{"name":"dev"}`, string(loaded.Entries[0].Snapshot))
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "tx.json", files[0].Name())
+}
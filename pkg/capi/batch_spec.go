@@ -0,0 +1,301 @@
+package capi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidBatchSpec = errors.New("invalid batch operation")
+)
+
+// BatchOperationSpec is the serialized form of a BatchOperation. Data holds
+// the request of creates and updates in the API's JSON field names; deletes
+// and gets name their target with GUID instead, except route destination,
+// security group binding, isolation segment entitlement and space quota
+// assignment deletes, whose Data is a RouteDestinationRef, a
+// SecurityGroupBinding, an IsolationSegmentEntitlement or a QuotaAssignment.
+// Feature flags and environment variable groups are addressed by name.
+type BatchOperationSpec struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Resource  string          `json:"resource"`
+	GUID      string          `json:"guid,omitempty"`
+	DependsOn []string        `json:"depends_on,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// batchSpecDecoder decodes the Data of one resource type's operations. A nil
+// function means the operation type takes a GUID, or is not supported when
+// create or update.
+type batchSpecDecoder struct {
+	create func(raw json.RawMessage) (interface{}, error)
+	update func(guid string, raw json.RawMessage) (interface{}, error)
+	remove func(raw json.RawMessage) (interface{}, error)
+}
+
+func decodeBatchSpecData[T any](raw json.RawMessage) (interface{}, error) {
+	return decodeBatchSpec[T](raw)
+}
+
+func decodeBatchSpec[T any](raw json.RawMessage) (*T, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, fmt.Errorf("%w: data is required", ErrInvalidBatchSpec)
+	}
+
+	var value T
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBatchSpec, err)
+	}
+
+	return &value, nil
+}
+
+func decodeBatchSpecUpdate[T any](guid string, raw json.RawMessage) (interface{}, error) {
+	if guid == "" {
+		return nil, fmt.Errorf("%w: guid is required", ErrInvalidBatchSpec)
+	}
+
+	request, err := decodeBatchSpec[T](raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UpdateDataWrapper[T]{GUID: guid, Request: request}, nil
+}
+
+func crudBatchSpecDecoder[TCreate, TUpdate any]() batchSpecDecoder {
+	return batchSpecDecoder{
+		create: decodeBatchSpecData[TCreate],
+		update: decodeBatchSpecUpdate[TUpdate],
+	}
+}
+
+// batchSpecDecoderFor returns the decoder of a batch resource type.
+func batchSpecDecoderFor(resource string) (batchSpecDecoder, bool) {
+	switch resource {
+	case "app":
+		return crudBatchSpecDecoder[AppCreateRequest, AppUpdateRequest](), true
+	case "space":
+		return crudBatchSpecDecoder[SpaceCreateRequest, SpaceUpdateRequest](), true
+	case "organization":
+		return crudBatchSpecDecoder[OrganizationCreateRequest, OrganizationUpdateRequest](), true
+	case "route":
+		return crudBatchSpecDecoder[RouteCreateRequest, RouteUpdateRequest](), true
+	case "service_instance":
+		return crudBatchSpecDecoder[ServiceInstanceCreateRequest, ServiceInstanceUpdateRequest](), true
+	case "service_credential_binding":
+		return crudBatchSpecDecoder[ServiceCredentialBindingCreateRequest, ServiceCredentialBindingUpdateRequest](), true
+	case "security_group":
+		return crudBatchSpecDecoder[SecurityGroupCreateRequest, SecurityGroupUpdateRequest](), true
+	case "organization_quota":
+		return crudBatchSpecDecoder[OrganizationQuotaCreateRequest, OrganizationQuotaUpdateRequest](), true
+	case "space_quota":
+		return crudBatchSpecDecoder[SpaceQuotaV3CreateRequest, SpaceQuotaV3UpdateRequest](), true
+	case "domain":
+		return crudBatchSpecDecoder[DomainCreateRequest, DomainUpdateRequest](), true
+	case "role":
+		return batchSpecDecoder{create: decodeBatchSpecData[RoleCreateRequest]}, true
+	case "route_destination":
+		return batchSpecDecoder{
+			create: decodeBatchSpecData[RouteDestinationsChange],
+			update: func(_ string, raw json.RawMessage) (interface{}, error) {
+				return decodeBatchSpecData[RouteDestinationsChange](raw)
+			},
+			remove: decodeBatchSpecData[RouteDestinationRef],
+		}, true
+	case "security_group_binding":
+		return batchSpecDecoder{
+			create: decodeBatchSpecData[SecurityGroupBinding],
+			remove: decodeBatchSpecData[SecurityGroupBinding],
+		}, true
+	case "metadata":
+		return batchSpecDecoder{update: decodeMetadataUpdateSpec}, true
+	case "feature_flag":
+		return batchSpecDecoder{update: decodeFeatureFlagSpec}, true
+	case "environment_variable_group":
+		return batchSpecDecoder{update: decodeEnvironmentVariableGroupSpec}, true
+	case "isolation_segment_entitlement":
+		return batchSpecDecoder{
+			create: decodeBatchSpecData[IsolationSegmentEntitlement],
+			remove: decodeBatchSpecData[IsolationSegmentEntitlement],
+		}, true
+	case "organization_quota_assignment":
+		return batchSpecDecoder{create: decodeBatchSpecData[QuotaAssignment]}, true
+	case "space_quota_assignment":
+		return batchSpecDecoder{
+			create: decodeBatchSpecData[QuotaAssignment],
+			remove: decodeBatchSpecData[QuotaAssignment],
+		}, true
+	default:
+		return batchSpecDecoder{}, false
+	}
+}
+
+// decodeMetadataUpdateSpec decodes a MetadataUpdate whose GUID defaults to
+// the spec's.
+func decodeMetadataUpdateSpec(guid string, raw json.RawMessage) (interface{}, error) {
+	update, err := decodeBatchSpec[MetadataUpdate](raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.GUID == "" {
+		update.GUID = guid
+	}
+
+	if update.Resource == "" || update.GUID == "" {
+		return nil, fmt.Errorf("%w: metadata updates need a resource and a guid", ErrInvalidBatchSpec)
+	}
+
+	return update, nil
+}
+
+// decodeFeatureFlagSpec decodes a FeatureFlagChange whose name defaults to
+// the spec's guid.
+func decodeFeatureFlagSpec(guid string, raw json.RawMessage) (interface{}, error) {
+	change, err := decodeBatchSpec[FeatureFlagChange](raw)
+	if err != nil {
+		return nil, err
+	}
+
+	change.Name, err = batchSpecName(change.Name, guid)
+
+	return change, err
+}
+
+// decodeEnvironmentVariableGroupSpec decodes an EnvironmentVariableGroupChange
+// whose name defaults to the spec's guid.
+func decodeEnvironmentVariableGroupSpec(guid string, raw json.RawMessage) (interface{}, error) {
+	change, err := decodeBatchSpec[EnvironmentVariableGroupChange](raw)
+	if err != nil {
+		return nil, err
+	}
+
+	change.Name, err = batchSpecName(change.Name, guid)
+
+	return change, err
+}
+
+// batchSpecName returns the name of a resource addressed by name, falling
+// back to the spec's guid.
+func batchSpecName(name, guid string) (string, error) {
+	if name == "" {
+		name = guid
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%w: a name is required", ErrInvalidBatchSpec)
+	}
+
+	return name, nil
+}
+
+// Operation converts the spec into a BatchOperation with typed Data.
+func (s BatchOperationSpec) Operation() (BatchOperation, error) {
+	operation := BatchOperation{ID: s.ID, Type: s.Type, Resource: s.Resource, DependsOn: s.DependsOn}
+
+	if s.ID == "" {
+		return operation, fmt.Errorf("%w: id is required", ErrInvalidBatchSpec)
+	}
+
+	decoder, ok := batchSpecDecoderFor(s.Resource)
+	if !ok {
+		return operation, fmt.Errorf("%w: %s", ErrUnsupportedResourceType, s.Resource)
+	}
+
+	var err error
+
+	switch {
+	case s.Type == "create" && decoder.create != nil:
+		operation.Data, err = decoder.create(s.Data)
+	case s.Type == "update" && decoder.update != nil:
+		operation.Data, err = decoder.update(s.GUID, s.Data)
+	case s.Type == "delete" && decoder.remove != nil:
+		operation.Data, err = decoder.remove(s.Data)
+	case (s.Type == "delete" || s.Type == "get") && s.Resource != "metadata" && s.Resource != "security_group_binding":
+		if s.GUID == "" {
+			return operation, fmt.Errorf("%w: guid is required", ErrInvalidBatchSpec)
+		}
+
+		operation.Data = s.GUID
+	default:
+		return operation, fmt.Errorf("%w: %s %s", ErrUnsupportedOperationType, s.Type, s.Resource)
+	}
+
+	return operation, err
+}
+
+// ParseBatchOperations reads a list of BatchOperationSpec from YAML or JSON,
+// either at the top level or under an "operations" key, and converts them
+// into operations ready for a BatchExecutor or BatchTransaction.
+//
+//	operations:
+//	  - id: org
+//	    type: create
+//	    resource: organization
+//	    data: {name: team}
+//	  - id: dev
+//	    type: create
+//	    resource: space
+//	    data:
+//	      name: dev
+//	      relationships: {organization: {data: {guid: "${ref:org.GUID}"}}}
+//	  - id: old
+//	    type: delete
+//	    resource: space
+//	    guid: 5b0f7a4e-...
+func ParseBatchOperations(data []byte) ([]BatchOperation, error) {
+	var document interface{}
+
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse batch operations: %w", err)
+	}
+
+	if mapping, ok := document.(map[string]interface{}); ok {
+		document = mapping["operations"]
+	}
+
+	if _, ok := document.([]interface{}); !ok {
+		return nil, fmt.Errorf("%w: expected a list of operations", ErrInvalidBatchSpec)
+	}
+
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse batch operations: %w", err)
+	}
+
+	var specs []BatchOperationSpec
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(&specs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidBatchSpec, err)
+	}
+
+	operations := make([]BatchOperation, 0, len(specs))
+
+	for i, spec := range specs {
+		operation, err := spec.Operation()
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i+1, spec.ID, err)
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
@@ -546,6 +546,39 @@ func (m *MockClient) ResourceMatches() capi.ResourceMatchesClient {
 	return client
 }
 
+func (m *MockClient) LogCache() capi.LogCacheClient {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	client, _ := args.Get(0).(capi.LogCacheClient)
+
+	return client
+}
+
+func (m *MockClient) LogStream() capi.LogStreamClient {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	client, _ := args.Get(0).(capi.LogStreamClient)
+
+	return client
+}
+
+func (m *MockClient) Metadata() capi.MetadataClient {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	client, _ := args.Get(0).(capi.MetadataClient)
+
+	return client
+}
+
 func (m *MockClient) Manifests() capi.ManifestsClient {
 	args := m.Called()
 	if args.Get(0) == nil {
@@ -568,6 +601,17 @@ func (m *MockClient) Routing() capi.RoutingClient {
 	return client
 }
 
+func (m *MockClient) SSH() capi.SSHClient {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil
+	}
+
+	client, _ := args.Get(0).(capi.SSHClient)
+
+	return client
+}
+
 // MockAppsClient implements capi.AppsClient for testing.
 type MockAppsClient struct {
 	mock.Mock
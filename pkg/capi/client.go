@@ -28,6 +28,7 @@ type InfrastructureClients interface {
 	IsolationSegments() IsolationSegmentsClient
 	Stacks() StacksClient
 	Routing() RoutingClient
+	SSH() SSHClient
 }
 
 // ServiceClients provides access to service-related resource clients.
@@ -60,6 +61,7 @@ type ConfigurationClients interface {
 	OrganizationQuotas() OrganizationQuotasClient
 	SpaceQuotas() SpaceQuotasClient
 	EnvironmentVariableGroups() EnvironmentVariableGroupsClient
+	Metadata() MetadataClient
 }
 
 // MonitoringClients provides access to monitoring and audit resource clients.
@@ -69,6 +71,8 @@ type MonitoringClients interface {
 	ServiceUsageEvents() ServiceUsageEventsClient
 	AuditEvents() AuditEventsClient
 	ResourceMatches() ResourceMatchesClient
+	LogCache() LogCacheClient
+	LogStream() LogStreamClient
 }
 
 // ResourceClients provides access to all resource-specific clients.
@@ -0,0 +1,476 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+)
+
+// Deployment strategies accepted by DeploymentCreateRequest.Strategy.
+const (
+	DeploymentStrategyRolling = "rolling"
+	DeploymentStrategyCanary  = "canary"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrDeploymentAppRequired       = errors.New("deployment request must reference an app")
+	ErrDeploymentCanceled          = errors.New("deployment was canceled")
+	ErrDeploymentSuperseded        = errors.New("deployment was superseded by a newer deployment")
+	ErrDeploymentHealthCheckFailed = errors.New("deployment health check failed")
+	ErrDeploymentRolledBack        = errors.New("deployment rolled back")
+	ErrDeploymentTimeout           = errors.New("deployment timed out")
+	ErrHealthProbeFailed           = errors.New("health probe failed")
+	ErrInstancesCrashed            = errors.New("too many crashed instances")
+)
+
+const (
+	defaultHealthProbeAttempts = 3
+	defaultHealthProbeInterval = time.Second
+	processInstanceStateCrash  = "CRASHED"
+)
+
+// DeploymentHealthCheck decides whether a paused canary deployment is healthy
+// enough to continue. A nil error continues the deployment; any error cancels
+// it, which rolls the app back to the previous droplet.
+type DeploymentHealthCheck interface {
+	Check(ctx context.Context, deployment *Deployment) error
+}
+
+// DeploymentHealthCheckFunc adapts a function to DeploymentHealthCheck.
+type DeploymentHealthCheckFunc func(ctx context.Context, deployment *Deployment) error
+
+// Check calls f.
+func (f DeploymentHealthCheckFunc) Check(ctx context.Context, deployment *Deployment) error {
+	return f(ctx, deployment)
+}
+
+// HTTPProbeHealthCheck probes a URL, typically a route of the app, and fails
+// unless every attempt answers with a 2xx status.
+type HTTPProbeHealthCheck struct {
+	URL string
+	// Client defaults to an http.Client with constants.ShortHTTPTimeout.
+	Client *http.Client
+	// Attempts is the number of probes that must all succeed. Defaults to 3.
+	Attempts int
+	// Interval is the pause between probes. Defaults to one second.
+	Interval time.Duration
+}
+
+// Check implements DeploymentHealthCheck.
+func (h *HTTPProbeHealthCheck) Check(ctx context.Context, _ *Deployment) error {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: constants.ShortHTTPTimeout}
+	}
+
+	attempts := h.Attempts
+	if attempts <= 0 {
+		attempts = defaultHealthProbeAttempts
+	}
+
+	interval := h.Interval
+	if interval <= 0 {
+		interval = defaultHealthProbeInterval
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			err := sleepContext(ctx, interval)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := h.probe(ctx, client)
+		if err != nil {
+			return fmt.Errorf("attempt %d/%d: %w", attempt, attempts, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *HTTPProbeHealthCheck) probe(ctx context.Context, client *http.Client) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build probe request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrHealthProbeFailed, err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %s returned %d", ErrHealthProbeFailed, h.URL, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// CrashCountHealthCheck counts CRASHED instances across the deployment's new
+// processes and fails when there are more than MaxCrashed.
+type CrashCountHealthCheck struct {
+	Client     Client
+	MaxCrashed int
+}
+
+// Check implements DeploymentHealthCheck.
+func (c *CrashCountHealthCheck) Check(ctx context.Context, deployment *Deployment) error {
+	crashed := 0
+
+	for _, process := range deployment.NewProcesses {
+		stats, err := c.Client.Processes().GetStats(ctx, process.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to get stats for process %s: %w", process.GUID, err)
+		}
+
+		for _, instance := range stats.Resources {
+			if instance.State == processInstanceStateCrash {
+				crashed++
+			}
+		}
+	}
+
+	if crashed > c.MaxCrashed {
+		return fmt.Errorf("%w: %d crashed, at most %d allowed", ErrInstancesCrashed, crashed, c.MaxCrashed)
+	}
+
+	return nil
+}
+
+// DeploymentEventType identifies what a DeploymentEvent reports.
+type DeploymentEventType string
+
+// Events emitted by DeploymentRunner.
+const (
+	DeploymentEventCreated           DeploymentEventType = "created"
+	DeploymentEventStatusChanged     DeploymentEventType = "status_changed"
+	DeploymentEventHealthCheckPassed DeploymentEventType = "health_check_passed"
+	DeploymentEventHealthCheckFailed DeploymentEventType = "health_check_failed"
+	DeploymentEventContinued         DeploymentEventType = "continued"
+	DeploymentEventRollback          DeploymentEventType = "rollback"
+	DeploymentEventFinished          DeploymentEventType = "finished"
+)
+
+// DeploymentEvent is one observation made while driving a deployment.
+type DeploymentEvent struct {
+	Type    DeploymentEventType `json:"type"              yaml:"type"`
+	Time    time.Time           `json:"time"              yaml:"time"`
+	State   string              `json:"state"             yaml:"state"`
+	Reason  string              `json:"reason"            yaml:"reason"`
+	Step    int                 `json:"step,omitempty"    yaml:"step,omitempty"`
+	Steps   int                 `json:"steps,omitempty"   yaml:"steps,omitempty"`
+	Message string              `json:"message,omitempty" yaml:"message,omitempty"`
+}
+
+// DeploymentRunnerOptions configures a DeploymentRunner.
+type DeploymentRunnerOptions struct {
+	// PollInterval defaults to constants.DefaultPollInterval.
+	PollInterval time.Duration
+	// Timeout bounds the whole deployment; zero means no limit. A deployment
+	// still running at the deadline is canceled unless DisableRollback is set.
+	Timeout time.Duration
+	// HealthCheck gates every canary pause. When nil, paused deployments are
+	// continued immediately.
+	HealthCheck DeploymentHealthCheck
+	// DisableRollback leaves a deployment paused instead of canceling it when
+	// the health check fails or the timeout expires.
+	DisableRollback bool
+	// OnEvent, when set, is called synchronously for every event.
+	OnEvent func(DeploymentEvent)
+}
+
+// DeploymentResult is the outcome of DeploymentRunner.Run or Watch.
+type DeploymentResult struct {
+	Deployment *Deployment       `json:"deployment"  yaml:"deployment"`
+	RolledBack bool              `json:"rolled_back" yaml:"rolled_back"`
+	Events     []DeploymentEvent `json:"events"      yaml:"events"`
+}
+
+// DeploymentRunner creates a deployment and drives it to a final state:
+// it polls status transitions, runs the health check at each canary pause,
+// continues healthy deployments, and cancels unhealthy ones so CF rolls the
+// app back to its previous droplet.
+type DeploymentRunner struct {
+	client Client
+	opts   DeploymentRunnerOptions
+}
+
+// NewDeploymentRunner returns a runner for client. opts may be nil.
+func NewDeploymentRunner(client Client, opts *DeploymentRunnerOptions) *DeploymentRunner {
+	runner := &DeploymentRunner{client: client}
+	if opts != nil {
+		runner.opts = *opts
+	}
+
+	if runner.opts.PollInterval <= 0 {
+		runner.opts.PollInterval = constants.DefaultPollInterval
+	}
+
+	return runner
+}
+
+// Run creates the deployment described by request and watches it until it
+// is finalized. The returned result is non-nil whenever the deployment was
+// created, even if an error is returned.
+func (r *DeploymentRunner) Run(ctx context.Context, request *DeploymentCreateRequest) (*DeploymentResult, error) {
+	if request == nil || request.Relationships.App == nil || request.Relationships.App.Data == nil {
+		return nil, ErrDeploymentAppRequired
+	}
+
+	deployment, err := r.client.Deployments().Create(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	watch := r.newWatch(deployment)
+	watch.emit(DeploymentEventCreated, deployment, "strategy "+deployment.Strategy)
+
+	return watch.run(ctx)
+}
+
+// Watch drives an existing deployment until it is finalized.
+func (r *DeploymentRunner) Watch(ctx context.Context, deploymentGUID string) (*DeploymentResult, error) {
+	deployment, err := r.client.Deployments().Get(ctx, deploymentGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %s: %w", deploymentGUID, err)
+	}
+
+	return r.newWatch(deployment).run(ctx)
+}
+
+// deploymentWatch holds the state of a single Run or Watch call.
+type deploymentWatch struct {
+	runner      *DeploymentRunner
+	result      *DeploymentResult
+	lastState   string
+	lastReason  string
+	gatedStep   int
+	rollingBack bool
+	gateErr     error
+}
+
+func (r *DeploymentRunner) newWatch(deployment *Deployment) *deploymentWatch {
+	return &deploymentWatch{
+		runner:     r,
+		result:     &DeploymentResult{Deployment: deployment},
+		lastState:  deployment.State,
+		lastReason: deployment.Status.Reason,
+	}
+}
+
+func (w *deploymentWatch) emit(eventType DeploymentEventType, deployment *Deployment, message string) {
+	step, steps := canaryStep(deployment)
+
+	event := DeploymentEvent{
+		Type:    eventType,
+		Time:    time.Now(),
+		State:   deployment.State,
+		Reason:  deployment.Status.Reason,
+		Step:    step,
+		Steps:   steps,
+		Message: message,
+	}
+
+	w.result.Events = append(w.result.Events, event)
+
+	if w.runner.opts.OnEvent != nil {
+		w.runner.opts.OnEvent(event)
+	}
+}
+
+func (w *deploymentWatch) run(ctx context.Context) (*DeploymentResult, error) {
+	parent := ctx
+
+	if w.runner.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, w.runner.opts.Timeout)
+		defer cancel()
+	}
+
+	deployment := w.result.Deployment
+
+	for {
+		done, err := w.observe(ctx, deployment)
+		if err != nil && deadlineExpired(parent, ctx) {
+			return w.result, w.timedOut(parent)
+		}
+
+		if done || err != nil {
+			return w.result, err
+		}
+
+		err = sleepContext(ctx, w.runner.opts.PollInterval)
+		if err == nil {
+			deployment, err = w.runner.client.Deployments().Get(ctx, deployment.GUID)
+		}
+
+		if err != nil {
+			if deadlineExpired(parent, ctx) {
+				return w.result, w.timedOut(parent)
+			}
+
+			return w.result, fmt.Errorf("failed to get deployment %s: %w", w.result.Deployment.GUID, err)
+		}
+
+		w.result.Deployment = deployment
+	}
+}
+
+// observe records a status transition and reacts to it. It returns true once
+// the deployment is finalized.
+func (w *deploymentWatch) observe(ctx context.Context, deployment *Deployment) (bool, error) {
+	if deployment.State != w.lastState || deployment.Status.Reason != w.lastReason {
+		w.lastState = deployment.State
+		w.lastReason = deployment.Status.Reason
+		w.emit(DeploymentEventStatusChanged, deployment, "")
+	}
+
+	if deployment.Status.Value == string(DeploymentStatusValueFinalized) {
+		return true, w.finished(deployment)
+	}
+
+	if deployment.Status.Reason != string(DeploymentStatusReasonPaused) || w.rollingBack {
+		return false, nil
+	}
+
+	step, _ := canaryStep(deployment)
+	if step == w.gatedStep {
+		return false, nil
+	}
+
+	w.gatedStep = step
+
+	return false, w.gate(ctx, deployment)
+}
+
+// gate runs the health check for the current canary step and continues or
+// cancels the deployment accordingly.
+func (w *deploymentWatch) gate(ctx context.Context, deployment *Deployment) error {
+	if w.runner.opts.HealthCheck != nil {
+		err := w.runner.opts.HealthCheck.Check(ctx, deployment)
+		if err != nil && ctx.Err() != nil {
+			return fmt.Errorf("health check interrupted: %w", ctx.Err())
+		}
+
+		if err != nil {
+			w.emit(DeploymentEventHealthCheckFailed, deployment, err.Error())
+			w.gateErr = err
+
+			if w.runner.opts.DisableRollback {
+				return fmt.Errorf("%w: %w", ErrDeploymentHealthCheckFailed, err)
+			}
+
+			w.rollingBack = true
+			w.result.RolledBack = true
+			w.emit(DeploymentEventRollback, deployment, "canceling deployment")
+
+			return w.cancel(ctx, deployment.GUID)
+		}
+
+		w.emit(DeploymentEventHealthCheckPassed, deployment, "")
+	}
+
+	err := w.runner.client.Deployments().Continue(ctx, deployment.GUID)
+	if err != nil {
+		return fmt.Errorf("failed to continue deployment %s: %w", deployment.GUID, err)
+	}
+
+	w.emit(DeploymentEventContinued, deployment, "")
+
+	return nil
+}
+
+func (w *deploymentWatch) finished(deployment *Deployment) error {
+	w.emit(DeploymentEventFinished, deployment, "")
+
+	switch DeploymentStatusReason(deployment.Status.Reason) {
+	case DeploymentStatusReasonDeployed:
+		return nil
+	case DeploymentStatusReasonSuperseded:
+		return ErrDeploymentSuperseded
+	default:
+		if w.gateErr != nil {
+			return fmt.Errorf("%w: %w: %w", ErrDeploymentRolledBack, ErrDeploymentHealthCheckFailed, w.gateErr)
+		}
+
+		return ErrDeploymentCanceled
+	}
+}
+
+// timedOut cancels a deployment that outlived the runner's timeout, whichever
+// stage the deadline expired in.
+func (w *deploymentWatch) timedOut(parent context.Context) error {
+	deployment := w.result.Deployment
+
+	if w.runner.opts.DisableRollback || w.rollingBack {
+		return fmt.Errorf("%w after %s", ErrDeploymentTimeout, w.runner.opts.Timeout)
+	}
+
+	w.result.RolledBack = true
+	w.emit(DeploymentEventRollback, deployment, "timeout expired, canceling deployment")
+
+	err := w.cancel(parent, deployment.GUID)
+	if err != nil {
+		return fmt.Errorf("%w after %s; %w", ErrDeploymentTimeout, w.runner.opts.Timeout, err)
+	}
+
+	return fmt.Errorf("%w after %s", ErrDeploymentTimeout, w.runner.opts.Timeout)
+}
+
+// cancel cancels the deployment with a context detached from ctx's deadline,
+// so a rollback still goes out when the runner's timeout has just expired.
+func (w *deploymentWatch) cancel(ctx context.Context, deploymentGUID string) error {
+	cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), constants.ShortHTTPTimeout)
+	defer cancel()
+
+	err := w.runner.client.Deployments().Cancel(cancelCtx, deploymentGUID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel deployment %s: %w", deploymentGUID, err)
+	}
+
+	return nil
+}
+
+// deadlineExpired reports whether ctx ended because of the runner's own
+// timeout rather than because the caller's parent context ended.
+func deadlineExpired(parent, ctx context.Context) bool {
+	return parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded)
+}
+
+// canaryStep reports the current and total canary steps. Deployments without
+// step information count as a single step.
+func canaryStep(deployment *Deployment) (int, int) {
+	if deployment.Status.Canary == nil {
+		if deployment.Strategy == DeploymentStrategyCanary {
+			return 1, 1
+		}
+
+		return 0, 0
+	}
+
+	return deployment.Status.Canary.Steps.Current, deployment.Status.Canary.Steps.Total
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("wait interrupted: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,294 @@
+package capi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDeployments simulates a canary deployment: each poll of a deploying
+// deployment pauses it at the current step, Continue advances to the next
+// step (or finishes after the last), and Cancel finalizes it as CANCELED.
+type stubDeployments struct {
+	capi.DeploymentsClient
+
+	mu        sync.Mutex
+	steps     int
+	step      int
+	state     string
+	reason    string
+	value     string
+	stuck     bool
+	continues int
+	cancels   int
+	cancelErr error
+	created   *capi.DeploymentCreateRequest
+}
+
+func newStubDeployments(steps int) *stubDeployments {
+	return &stubDeployments{
+		steps:  steps,
+		step:   1,
+		state:  string(capi.DeploymentStateDeploying),
+		reason: string(capi.DeploymentStatusReasonDeploying),
+		value:  string(capi.DeploymentStatusValueActive),
+	}
+}
+
+func (s *stubDeployments) snapshot() *capi.Deployment {
+	deployment := &capi.Deployment{
+		Resource: capi.Resource{GUID: "deployment-guid"},
+		State:    s.state,
+		Strategy: capi.DeploymentStrategyCanary,
+		Status: capi.DeploymentStatus{
+			Value:  s.value,
+			Reason: s.reason,
+			Canary: &capi.DeploymentCanaryStatus{Steps: capi.DeploymentCanarySteps{Current: s.step, Total: s.steps}},
+		},
+		NewProcesses: []capi.DeploymentProcess{{GUID: "process-guid", Type: "web"}},
+	}
+
+	return deployment
+}
+
+func (s *stubDeployments) Create(_ context.Context, request *capi.DeploymentCreateRequest) (*capi.Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.created = request
+
+	return s.snapshot(), nil
+}
+
+func (s *stubDeployments) Get(_ context.Context, _ string) (*capi.Deployment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == string(capi.DeploymentStateDeploying) && !s.stuck {
+		s.state = string(capi.DeploymentStatePaused)
+		s.reason = string(capi.DeploymentStatusReasonPaused)
+	}
+
+	return s.snapshot(), nil
+}
+
+func (s *stubDeployments) Continue(_ context.Context, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.continues++
+
+	if s.step == s.steps {
+		s.state = string(capi.DeploymentStateDeployed)
+		s.reason = string(capi.DeploymentStatusReasonDeployed)
+		s.value = string(capi.DeploymentStatusValueFinalized)
+
+		return nil
+	}
+
+	s.step++
+	s.state = string(capi.DeploymentStateDeploying)
+	s.reason = string(capi.DeploymentStatusReasonDeploying)
+
+	return nil
+}
+
+func (s *stubDeployments) Cancel(ctx context.Context, _ string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancels++
+	s.cancelErr = ctx.Err()
+	s.state = string(capi.DeploymentStateCanceled)
+	s.reason = string(capi.DeploymentStatusReasonCanceled)
+	s.value = string(capi.DeploymentStatusValueFinalized)
+
+	return nil
+}
+
+// stubProcesses serves fixed instance states for every process.
+type stubProcesses struct {
+	capi.ProcessesClient
+
+	states []string
+}
+
+func (s *stubProcesses) GetStats(_ context.Context, _ string) (*capi.ProcessStats, error) {
+	stats := &capi.ProcessStats{}
+	for i, state := range s.states {
+		stats.Resources = append(stats.Resources, capi.ProcessStatsDetail{Type: "web", Index: i, State: state})
+	}
+
+	return stats, nil
+}
+
+func deploymentRequest() *capi.DeploymentCreateRequest {
+	strategy := capi.DeploymentStrategyCanary
+
+	return &capi.DeploymentCreateRequest{
+		Strategy:      &strategy,
+		Relationships: capi.DeploymentRelationships{App: &capi.Relationship{Data: &capi.RelationshipData{GUID: "app-guid"}}},
+	}
+}
+
+func TestDeploymentRunner_ContinuesHealthyCanary(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(3)
+	client := &stubClient{deployments: deployments}
+
+	checks := 0
+	runner := capi.NewDeploymentRunner(client, &capi.DeploymentRunnerOptions{
+		PollInterval: time.Millisecond,
+		HealthCheck: capi.DeploymentHealthCheckFunc(func(context.Context, *capi.Deployment) error {
+			checks++
+
+			return nil
+		}),
+	})
+
+	result, err := runner.Run(context.Background(), deploymentRequest())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, checks)
+	assert.Equal(t, 3, deployments.continues)
+	assert.Zero(t, deployments.cancels)
+	assert.False(t, result.RolledBack)
+	assert.Equal(t, string(capi.DeploymentStateDeployed), result.Deployment.State)
+	assert.Equal(t, capi.DeploymentEventCreated, result.Events[0].Type)
+	assert.Equal(t, capi.DeploymentEventFinished, result.Events[len(result.Events)-1].Type)
+}
+
+func TestDeploymentRunner_RollsBackOnCrashes(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(2)
+	client := &stubClient{
+		deployments: deployments,
+		processes:   &stubProcesses{states: []string{"RUNNING", "CRASHED"}},
+	}
+
+	var events []capi.DeploymentEventType
+
+	runner := capi.NewDeploymentRunner(client, &capi.DeploymentRunnerOptions{
+		PollInterval: time.Millisecond,
+		HealthCheck:  &capi.CrashCountHealthCheck{Client: client},
+		OnEvent:      func(event capi.DeploymentEvent) { events = append(events, event.Type) },
+	})
+
+	result, err := runner.Run(context.Background(), deploymentRequest())
+	require.ErrorIs(t, err, capi.ErrDeploymentRolledBack)
+	require.ErrorIs(t, err, capi.ErrInstancesCrashed)
+
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, 1, deployments.cancels)
+	assert.Zero(t, deployments.continues)
+	assert.Contains(t, events, capi.DeploymentEventHealthCheckFailed)
+	assert.Contains(t, events, capi.DeploymentEventRollback)
+}
+
+func TestDeploymentRunner_DisableRollbackLeavesPaused(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(2)
+	client := &stubClient{deployments: deployments}
+
+	runner := capi.NewDeploymentRunner(client, &capi.DeploymentRunnerOptions{
+		PollInterval:    time.Millisecond,
+		DisableRollback: true,
+		HealthCheck: capi.DeploymentHealthCheckFunc(func(context.Context, *capi.Deployment) error {
+			return capi.ErrHealthProbeFailed
+		}),
+	})
+
+	result, err := runner.Run(context.Background(), deploymentRequest())
+	require.ErrorIs(t, err, capi.ErrDeploymentHealthCheckFailed)
+
+	assert.False(t, result.RolledBack)
+	assert.Zero(t, deployments.cancels)
+	assert.Equal(t, string(capi.DeploymentStatePaused), result.Deployment.State)
+}
+
+func TestDeploymentRunner_TimeoutCancels(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(1)
+	deployments.stuck = true
+	client := &stubClient{deployments: deployments}
+
+	runner := capi.NewDeploymentRunner(client, &capi.DeploymentRunnerOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+	})
+
+	result, err := runner.Run(context.Background(), deploymentRequest())
+	require.ErrorIs(t, err, capi.ErrDeploymentTimeout)
+
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, 1, deployments.cancels)
+	assert.NoError(t, deployments.cancelErr)
+}
+
+func TestDeploymentRunner_TimeoutDuringHealthCheckCancels(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(2)
+	client := &stubClient{deployments: deployments}
+
+	runner := capi.NewDeploymentRunner(client, &capi.DeploymentRunnerOptions{
+		PollInterval: time.Millisecond,
+		Timeout:      20 * time.Millisecond,
+		HealthCheck: capi.DeploymentHealthCheckFunc(func(ctx context.Context, _ *capi.Deployment) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		}),
+	})
+
+	result, err := runner.Run(context.Background(), deploymentRequest())
+	require.ErrorIs(t, err, capi.ErrDeploymentTimeout)
+
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, 1, deployments.cancels)
+	assert.NoError(t, deployments.cancelErr)
+	assert.Zero(t, deployments.continues)
+}
+
+func TestDeploymentRunner_RequiresApp(t *testing.T) {
+	t.Parallel()
+
+	runner := capi.NewDeploymentRunner(&stubClient{}, nil)
+
+	_, err := runner.Run(context.Background(), &capi.DeploymentCreateRequest{})
+	require.ErrorIs(t, err, capi.ErrDeploymentAppRequired)
+}
+
+func TestHTTPProbeHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	var status atomic.Int32
+
+	status.Store(http.StatusOK)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	probe := &capi.HTTPProbeHealthCheck{URL: server.URL, Attempts: 2, Interval: time.Millisecond}
+	require.NoError(t, probe.Check(context.Background(), nil))
+
+	status.Store(http.StatusBadGateway)
+
+	err := probe.Check(context.Background(), nil)
+	require.ErrorIs(t, err, capi.ErrHealthProbeFailed)
+	assert.Contains(t, err.Error(), "502")
+}
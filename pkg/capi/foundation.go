@@ -0,0 +1,308 @@
+package capi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidFoundation     = errors.New("invalid foundation document")
+	ErrFoundationUnresolved  = errors.New("foundation document refers to a resource that does not exist")
+	errFoundationNameMissing = errors.New("name is required")
+	errFoundationNameTaken   = errors.New("duplicate name")
+	errFoundationEmptyKey    = errors.New("empty name")
+)
+
+// FoundationManagedLabel marks the organizations, spaces and quotas a
+// foundation document manages. Its value is the document's ManagedBy, so
+// that several documents can share a foundation.
+const FoundationManagedLabel = "capi.fivetwenty.io/managed-by"
+
+// DefaultFoundationManagedBy is the ManagedBy of documents that set none.
+const DefaultFoundationManagedBy = "config-as-code"
+
+// Foundation is the desired state of a Cloud Foundry foundation: its
+// organizations and spaces with their quotas and role assignments, security
+// groups, isolation segment entitlements, feature flags and environment
+// variable groups.
+//
+// A document only manages what it mentions. A nil list or map leaves the
+// current value alone, while an empty one means "none": `managers: []`
+// removes every space manager, an absent `managers` key keeps them.
+//
+//	managed_by: platform-team
+//	feature_flags:
+//	  diego_docker: true
+//	environment_variable_groups:
+//	  running: {HTTP_PROXY: http://proxy:8080}
+//	organization_quotas:
+//	  - name: small
+//	    apps: {total_memory_in_mb: 10240}
+//	security_groups:
+//	  - name: internal
+//	    rules: [{protocol: tcp, destination: 10.0.0.0/8, ports: "443"}]
+//	organizations:
+//	  - name: payments
+//	    quota: small
+//	    isolation_segments: [secure]
+//	    users: {managers: [alice]}
+//	    spaces:
+//	      - name: prod
+//	        users: {developers: [bob, carol]}
+//	        security_groups: {running: [internal]}
+type Foundation struct {
+	// ManagedBy is the value of the FoundationManagedLabel label; it
+	// defaults to DefaultFoundationManagedBy.
+	ManagedBy                 string                               `json:"managed_by,omitempty"                  yaml:"managed_by,omitempty"`
+	FeatureFlags              map[string]bool                      `json:"feature_flags,omitempty"               yaml:"feature_flags,omitempty"`
+	EnvironmentVariableGroups *FoundationEnvironmentVariableGroups `json:"environment_variable_groups,omitempty" yaml:"environment_variable_groups,omitempty"`
+	OrganizationQuotas        []FoundationOrganizationQuota        `json:"organization_quotas,omitempty"         yaml:"organization_quotas,omitempty"`
+	SecurityGroups            []FoundationSecurityGroup            `json:"security_groups,omitempty"             yaml:"security_groups,omitempty"`
+	Organizations             []FoundationOrganization             `json:"organizations,omitempty"               yaml:"organizations,omitempty"`
+}
+
+// FoundationEnvironmentVariableGroups is the exact content of the running
+// and staging environment variable groups; variables not listed are removed.
+type FoundationEnvironmentVariableGroups struct {
+	Running map[string]string `json:"running,omitempty" yaml:"running,omitempty"`
+	Staging map[string]string `json:"staging,omitempty" yaml:"staging,omitempty"`
+}
+
+// FoundationOrganizationQuota is an organization quota; limits left out are
+// not managed.
+type FoundationOrganizationQuota struct {
+	Name     string                     `json:"name"               yaml:"name"`
+	Apps     *OrganizationQuotaApps     `json:"apps,omitempty"     yaml:"apps,omitempty"`
+	Services *OrganizationQuotaServices `json:"services,omitempty" yaml:"services,omitempty"`
+	Routes   *OrganizationQuotaRoutes   `json:"routes,omitempty"   yaml:"routes,omitempty"`
+	Domains  *OrganizationQuotaDomains  `json:"domains,omitempty"  yaml:"domains,omitempty"`
+}
+
+// FoundationSpaceQuota is a space quota of an organization; limits left out
+// are not managed.
+type FoundationSpaceQuota struct {
+	Name     string              `json:"name"               yaml:"name"`
+	Apps     *SpaceQuotaApps     `json:"apps,omitempty"     yaml:"apps,omitempty"`
+	Services *SpaceQuotaServices `json:"services,omitempty" yaml:"services,omitempty"`
+	Routes   *SpaceQuotaRoutes   `json:"routes,omitempty"   yaml:"routes,omitempty"`
+}
+
+// FoundationSecurityGroup is a security group. Security groups carry no
+// labels, so they are never pruned.
+type FoundationSecurityGroup struct {
+	Name            string                        `json:"name"                       yaml:"name"`
+	Rules           []SecurityGroupRule           `json:"rules,omitempty"            yaml:"rules,omitempty"`
+	GloballyEnabled *SecurityGroupGloballyEnabled `json:"globally_enabled,omitempty" yaml:"globally_enabled,omitempty"`
+}
+
+// FoundationOrganization is an organization with its spaces.
+type FoundationOrganization struct {
+	Name string `json:"name" yaml:"name"`
+	// Quota names an organization quota, declared in the document or not.
+	Quota string `json:"quota,omitempty" yaml:"quota,omitempty"`
+	// IsolationSegments lists the isolation segments the organization is
+	// entitled to.
+	IsolationSegments []string                    `json:"isolation_segments,omitempty" yaml:"isolation_segments,omitempty"`
+	Users             FoundationOrganizationUsers `json:"users,omitempty"              yaml:"users,omitempty"`
+	SpaceQuotas       []FoundationSpaceQuota      `json:"space_quotas,omitempty"       yaml:"space_quotas,omitempty"`
+	Spaces            []FoundationSpace           `json:"spaces,omitempty"             yaml:"spaces,omitempty"`
+}
+
+// FoundationOrganizationUsers lists the usernames holding each organization
+// role.
+type FoundationOrganizationUsers struct {
+	Managers        []string `json:"managers,omitempty"         yaml:"managers,omitempty"`
+	BillingManagers []string `json:"billing_managers,omitempty" yaml:"billing_managers,omitempty"`
+	Auditors        []string `json:"auditors,omitempty"         yaml:"auditors,omitempty"`
+}
+
+// FoundationSpace is a space of an organization.
+type FoundationSpace struct {
+	Name string `json:"name" yaml:"name"`
+	// Quota names a space quota of the organization, declared in the
+	// document or not.
+	Quota          string                        `json:"quota,omitempty"           yaml:"quota,omitempty"`
+	Users          FoundationSpaceUsers          `json:"users,omitempty"           yaml:"users,omitempty"`
+	SecurityGroups FoundationSpaceSecurityGroups `json:"security_groups,omitempty" yaml:"security_groups,omitempty"`
+}
+
+// FoundationSpaceUsers lists the usernames holding each space role. Users
+// that are not yet members of the organization are added to it.
+type FoundationSpaceUsers struct {
+	Managers   []string `json:"managers,omitempty"   yaml:"managers,omitempty"`
+	Developers []string `json:"developers,omitempty" yaml:"developers,omitempty"`
+	Auditors   []string `json:"auditors,omitempty"   yaml:"auditors,omitempty"`
+	Supporters []string `json:"supporters,omitempty" yaml:"supporters,omitempty"`
+}
+
+// FoundationSpaceSecurityGroups lists the security groups bound to a space
+// for each lifecycle.
+type FoundationSpaceSecurityGroups struct {
+	Running []string `json:"running,omitempty" yaml:"running,omitempty"`
+	Staging []string `json:"staging,omitempty" yaml:"staging,omitempty"`
+}
+
+// foundationRoleList is a role type with the usernames a document gives it.
+type foundationRoleList struct {
+	field     string
+	roleType  RoleType
+	usernames []string
+}
+
+func (u FoundationOrganizationUsers) roles() []foundationRoleList {
+	return []foundationRoleList{
+		{"users.managers", RoleTypeOrganizationManager, u.Managers},
+		{"users.billing_managers", RoleTypeOrganizationBillingManager, u.BillingManagers},
+		{"users.auditors", RoleTypeOrganizationAuditor, u.Auditors},
+	}
+}
+
+func (u FoundationSpaceUsers) roles() []foundationRoleList {
+	return []foundationRoleList{
+		{"users.managers", RoleTypeSpaceManager, u.Managers},
+		{"users.developers", RoleTypeSpaceDeveloper, u.Developers},
+		{"users.auditors", RoleTypeSpaceAuditor, u.Auditors},
+		{"users.supporters", RoleTypeSpaceSupporter, u.Supporters},
+	}
+}
+
+// ParseFoundation reads a foundation document from YAML or JSON and
+// validates it. Unknown keys are errors.
+func ParseFoundation(data []byte) (*Foundation, error) {
+	var foundation Foundation
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(&foundation)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFoundation, err)
+	}
+
+	err = foundation.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &foundation, nil
+}
+
+// ManagedByValue returns ManagedBy or its default.
+func (f *Foundation) ManagedByValue() string {
+	if f.ManagedBy == "" {
+		return DefaultFoundationManagedBy
+	}
+
+	return f.ManagedBy
+}
+
+// Validate checks that names are given and unique and that usernames and
+// variable names are not empty. References to quotas, security groups,
+// isolation segments and users are resolved when planning.
+func (f *Foundation) Validate() error {
+	var errs []error
+
+	err := ValidateLabelValue(f.ManagedByValue())
+	if err != nil {
+		errs = append(errs, fmt.Errorf("managed_by: %w", err))
+	}
+
+	for name := range f.FeatureFlags {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("feature_flags: %w", errFoundationEmptyKey))
+		}
+	}
+
+	if f.EnvironmentVariableGroups != nil {
+		errs = append(errs, validateFoundationVariables("environment_variable_groups.running", f.EnvironmentVariableGroups.Running)...)
+		errs = append(errs, validateFoundationVariables("environment_variable_groups.staging", f.EnvironmentVariableGroups.Staging)...)
+	}
+
+	errs = append(errs, validateFoundationNames("organization_quotas", len(f.OrganizationQuotas), func(i int) string {
+		return f.OrganizationQuotas[i].Name
+	})...)
+	errs = append(errs, validateFoundationNames("security_groups", len(f.SecurityGroups), func(i int) string {
+		return f.SecurityGroups[i].Name
+	})...)
+	errs = append(errs, validateFoundationNames("organizations", len(f.Organizations), func(i int) string {
+		return f.Organizations[i].Name
+	})...)
+
+	for _, org := range f.Organizations {
+		errs = append(errs, org.validate()...)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidFoundation, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (o FoundationOrganization) validate() []error {
+	path := "organizations[" + o.Name + "]"
+
+	errs := validateFoundationNames(path+".space_quotas", len(o.SpaceQuotas), func(i int) string {
+		return o.SpaceQuotas[i].Name
+	})
+	errs = append(errs, validateFoundationNames(path+".spaces", len(o.Spaces), func(i int) string {
+		return o.Spaces[i].Name
+	})...)
+	errs = append(errs, validateFoundationUsernames(path, o.Users.roles())...)
+
+	for _, space := range o.Spaces {
+		errs = append(errs, validateFoundationUsernames(path+".spaces["+space.Name+"]", space.Users.roles())...)
+	}
+
+	return errs
+}
+
+func validateFoundationNames(path string, count int, name func(int) string) []error {
+	var errs []error
+
+	seen := make(map[string]bool, count)
+
+	for i := range count {
+		switch {
+		case name(i) == "":
+			errs = append(errs, fmt.Errorf("%s[%d]: %w", path, i, errFoundationNameMissing))
+		case seen[name(i)]:
+			errs = append(errs, fmt.Errorf("%s: %w %q", path, errFoundationNameTaken, name(i)))
+		}
+
+		seen[name(i)] = true
+	}
+
+	return errs
+}
+
+func validateFoundationUsernames(path string, roles []foundationRoleList) []error {
+	var errs []error
+
+	for _, role := range roles {
+		for _, username := range role.usernames {
+			if username == "" {
+				errs = append(errs, fmt.Errorf("%s.%s: %w", path, role.field, errFoundationEmptyKey))
+			}
+		}
+	}
+
+	return errs
+}
+
+func validateFoundationVariables(path string, vars map[string]string) []error {
+	var errs []error
+
+	for name := range vars {
+		if name == "" {
+			errs = append(errs, fmt.Errorf("%s: %w", path, errFoundationEmptyKey))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,658 @@
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// FoundationExport is the configuration of a foundation as it is: a
+// Foundation document that config-as-code can plan against, with the
+// domains, isolation segments and service plan visibility that a document
+// does not manage.
+type FoundationExport struct {
+	Foundation            *Foundation                        `json:"foundation"                        yaml:"foundation"`
+	Domains               []FoundationDomain                 `json:"domains,omitempty"                 yaml:"domains,omitempty"`
+	IsolationSegments     []FoundationExportIsolationSegment `json:"isolation_segments,omitempty"      yaml:"isolation_segments,omitempty"`
+	ServicePlanVisibility []FoundationServicePlanVisibility  `json:"service_plan_visibility,omitempty" yaml:"service_plan_visibility,omitempty"`
+}
+
+// FoundationDomain is a domain with its owning and sharing organizations by
+// name. Shared domains have no organization.
+type FoundationDomain struct {
+	Name                string   `json:"name"                           yaml:"name"`
+	Internal            bool     `json:"internal,omitempty"             yaml:"internal,omitempty"`
+	RouterGroup         string   `json:"router_group,omitempty"         yaml:"router_group,omitempty"`
+	Organization        string   `json:"organization,omitempty"         yaml:"organization,omitempty"`
+	SharedOrganizations []string `json:"shared_organizations,omitempty" yaml:"shared_organizations,omitempty"`
+}
+
+// FoundationExportIsolationSegment is an isolation segment with the names of
+// the organizations entitled to it.
+type FoundationExportIsolationSegment struct {
+	Name          string   `json:"name"                    yaml:"name"`
+	Organizations []string `json:"organizations,omitempty" yaml:"organizations,omitempty"`
+}
+
+// FoundationServicePlanVisibility is who can see a service plan. Visibility
+// is public, admin, organization or space; Organizations lists the
+// organizations of an organization-visible plan, and Space the
+// "organization/space" of a space-scoped one.
+type FoundationServicePlanVisibility struct {
+	ServiceBroker   string   `json:"service_broker"          yaml:"service_broker"`
+	ServiceOffering string   `json:"service_offering"        yaml:"service_offering"`
+	Plan            string   `json:"plan"                    yaml:"plan"`
+	Visibility      string   `json:"visibility"              yaml:"visibility"`
+	Organizations   []string `json:"organizations,omitempty" yaml:"organizations,omitempty"`
+	Space           string   `json:"space,omitempty"         yaml:"space,omitempty"`
+}
+
+// FoundationExportOptions selects what ExportFoundation reads.
+type FoundationExportOptions struct {
+	// Organizations names the organizations to export; empty exports all.
+	// Foundation-wide settings, quotas, security groups, isolation segments,
+	// shared domains and public plans are exported either way. Private
+	// domains and plans visible to some organizations only are exported when
+	// they concern a selected organization.
+	Organizations []string
+}
+
+// ExportFoundation reads the configuration of a foundation. Every list in
+// the result is sorted, so that exports of an unchanged foundation are
+// identical.
+func ExportFoundation(ctx context.Context, client Client, opts FoundationExportOptions) (*FoundationExport, error) {
+	orgs, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Organization], error) {
+		return client.Organizations().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+
+	selected, err := selectExportOrganizations(orgs, opts.Organizations)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := readFoundationExportState(ctx, client, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &FoundationExport{
+		Foundation:        state.Foundation(),
+		IsolationSegments: exportIsolationSegments(state, orgs),
+	}
+
+	export.Domains, err = exportDomains(ctx, client, orgs, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	export.ServicePlanVisibility, err = exportServicePlanVisibility(ctx, client, state, orgs, selected)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// selectExportOrganizations returns the organizations named, or all of them.
+func selectExportOrganizations(orgs []Organization, names []string) ([]Organization, error) {
+	if len(names) == 0 {
+		return orgs, nil
+	}
+
+	byName := make(map[string]Organization, len(orgs))
+	for _, org := range orgs {
+		byName[org.Name] = org
+	}
+
+	selected := make([]Organization, 0, len(names))
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		org, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: organization %q", ErrNotFound, name)
+		}
+
+		if !seen[name] {
+			seen[name] = true
+
+			selected = append(selected, org)
+		}
+	}
+
+	return selected, nil
+}
+
+// readFoundationExportState reads everything a Foundation document can
+// hold for the selected organizations.
+func readFoundationExportState(ctx context.Context, client Client, orgs []Organization) (*FoundationState, error) {
+	state := &FoundationState{}
+
+	readers := []func(context.Context, Client, *FoundationState) error{
+		readFoundationFeatureFlags,
+		func(ctx context.Context, client Client, state *FoundationState) error {
+			return readFoundationEnvironmentVariableGroup(ctx, client, "running", state)
+		},
+		func(ctx context.Context, client Client, state *FoundationState) error {
+			return readFoundationEnvironmentVariableGroup(ctx, client, "staging", state)
+		},
+		readFoundationOrganizationQuotas,
+		readFoundationSecurityGroups,
+		readFoundationIsolationSegments,
+	}
+
+	for _, read := range readers {
+		err := read(ctx, client, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, org := range orgs {
+		orgState, err := fetchFoundationOrganization(ctx, client, org)
+		if err != nil {
+			return nil, err
+		}
+
+		state.Organizations = append(state.Organizations, *orgState)
+	}
+
+	sort.Slice(state.Organizations, func(i, j int) bool {
+		return state.Organizations[i].Name < state.Organizations[j].Name
+	})
+
+	err := fetchFoundationUsers(ctx, client, &Foundation{}, false, state)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Foundation returns the document describing the state: planning it
+// against the same state changes nothing but the management labels.
+// Users without a username are given by GUID, and environment variables
+// that are not strings are given as JSON.
+func (s *FoundationState) Foundation() *Foundation {
+	foundation := &Foundation{FeatureFlags: s.FeatureFlags}
+
+	if s.EnvironmentVariableGroups != nil {
+		foundation.EnvironmentVariableGroups = &FoundationEnvironmentVariableGroups{
+			Running: foundationVariables(s.EnvironmentVariableGroups["running"]),
+			Staging: foundationVariables(s.EnvironmentVariableGroups["staging"]),
+		}
+	}
+
+	quotaNames := map[string]string{}
+
+	for _, quota := range s.OrganizationQuotas {
+		quotaNames[quota.GUID] = quota.Name
+		foundation.OrganizationQuotas = append(foundation.OrganizationQuotas, FoundationOrganizationQuota{
+			Name:     quota.Name,
+			Apps:     quota.Apps,
+			Services: quota.Services,
+			Routes:   quota.Routes,
+			Domains:  quota.Domains,
+		})
+	}
+
+	sort.Slice(foundation.OrganizationQuotas, func(i, j int) bool {
+		return foundation.OrganizationQuotas[i].Name < foundation.OrganizationQuotas[j].Name
+	})
+
+	for _, group := range s.SecurityGroups {
+		enabled := group.GloballyEnabled
+		foundation.SecurityGroups = append(foundation.SecurityGroups, FoundationSecurityGroup{
+			Name:            group.Name,
+			Rules:           group.Rules,
+			GloballyEnabled: &enabled,
+		})
+	}
+
+	sort.Slice(foundation.SecurityGroups, func(i, j int) bool {
+		return foundation.SecurityGroups[i].Name < foundation.SecurityGroups[j].Name
+	})
+
+	usernames := make(map[string]string, len(s.Users))
+
+	for _, user := range s.Users {
+		if user.Username != "" {
+			usernames[user.GUID] = user.Username
+		}
+	}
+
+	for _, org := range s.Organizations {
+		foundation.Organizations = append(foundation.Organizations, s.foundationOrganization(org, quotaNames, usernames))
+	}
+
+	sort.Slice(foundation.Organizations, func(i, j int) bool {
+		return foundation.Organizations[i].Name < foundation.Organizations[j].Name
+	})
+
+	return foundation
+}
+
+func (s *FoundationState) foundationOrganization(org FoundationOrganizationState, quotaNames, usernames map[string]string) FoundationOrganization {
+	holders := func(roleType RoleType, scope func(Role) bool) []string {
+		names := []string{}
+
+		for _, role := range org.Roles {
+			if role.Type != string(roleType) || !scope(role) || role.Relationships.User.Data == nil {
+				continue
+			}
+
+			guid := role.Relationships.User.Data.GUID
+			if name, ok := usernames[guid]; ok {
+				guid = name
+			}
+
+			names = append(names, guid)
+		}
+
+		sort.Strings(names)
+
+		return names
+	}
+
+	result := FoundationOrganization{Name: org.Name}
+
+	if org.Relationships != nil && org.Relationships.Quota.Data != nil {
+		result.Quota = quotaNames[org.Relationships.Quota.Data.GUID]
+	}
+
+	if s.IsolationSegments != nil {
+		result.IsolationSegments = []string{}
+
+		for _, segment := range s.IsolationSegments {
+			if slices.Contains(segment.OrganizationGUIDs, org.GUID) {
+				result.IsolationSegments = append(result.IsolationSegments, segment.Name)
+			}
+		}
+
+		sort.Strings(result.IsolationSegments)
+	}
+
+	inOrg := func(role Role) bool { return role.Relationships.Organization != nil }
+	result.Users = FoundationOrganizationUsers{
+		Managers:        holders(RoleTypeOrganizationManager, inOrg),
+		BillingManagers: holders(RoleTypeOrganizationBillingManager, inOrg),
+		Auditors:        holders(RoleTypeOrganizationAuditor, inOrg),
+	}
+
+	for _, quota := range org.SpaceQuotas {
+		result.SpaceQuotas = append(result.SpaceQuotas, FoundationSpaceQuota{
+			Name:     quota.Name,
+			Apps:     quota.Apps,
+			Services: quota.Services,
+			Routes:   quota.Routes,
+		})
+	}
+
+	sort.Slice(result.SpaceQuotas, func(i, j int) bool {
+		return result.SpaceQuotas[i].Name < result.SpaceQuotas[j].Name
+	})
+
+	result.Spaces = s.foundationSpaces(org, holders)
+
+	return result
+}
+
+func (s *FoundationState) foundationSpaces(org FoundationOrganizationState, holders func(RoleType, func(Role) bool) []string) []FoundationSpace {
+	quotaNames := make(map[string]string, len(org.SpaceQuotas))
+	for _, quota := range org.SpaceQuotas {
+		quotaNames[quota.GUID] = quota.Name
+	}
+
+	spaces := make([]FoundationSpace, 0, len(org.Spaces))
+
+	for _, space := range org.Spaces {
+		inSpace := func(role Role) bool {
+			return role.Relationships.Space != nil && role.Relationships.Space.Data != nil && role.Relationships.Space.Data.GUID == space.GUID
+		}
+
+		exported := FoundationSpace{
+			Name: space.Name,
+			Users: FoundationSpaceUsers{
+				Managers:   holders(RoleTypeSpaceManager, inSpace),
+				Developers: holders(RoleTypeSpaceDeveloper, inSpace),
+				Auditors:   holders(RoleTypeSpaceAuditor, inSpace),
+				Supporters: holders(RoleTypeSpaceSupporter, inSpace),
+			},
+			SecurityGroups: s.foundationSpaceSecurityGroups(space.GUID),
+		}
+
+		if space.Relationships.Quota != nil && space.Relationships.Quota.Data != nil {
+			exported.Quota = quotaNames[space.Relationships.Quota.Data.GUID]
+		}
+
+		spaces = append(spaces, exported)
+	}
+
+	sort.Slice(spaces, func(i, j int) bool {
+		return spaces[i].Name < spaces[j].Name
+	})
+
+	return spaces
+}
+
+func (s *FoundationState) foundationSpaceSecurityGroups(spaceGUID string) FoundationSpaceSecurityGroups {
+	bindings := FoundationSpaceSecurityGroups{Running: []string{}, Staging: []string{}}
+
+	for _, group := range s.SecurityGroups {
+		for _, space := range group.Relationships.RunningSpaces.Data {
+			if space.GUID == spaceGUID {
+				bindings.Running = append(bindings.Running, group.Name)
+			}
+		}
+
+		for _, space := range group.Relationships.StagingSpaces.Data {
+			if space.GUID == spaceGUID {
+				bindings.Staging = append(bindings.Staging, group.Name)
+			}
+		}
+	}
+
+	sort.Strings(bindings.Running)
+	sort.Strings(bindings.Staging)
+
+	return bindings
+}
+
+// foundationVariables gives environment variables as strings, the only type
+// a document holds.
+func foundationVariables(vars map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(vars))
+
+	for key, value := range vars {
+		result[key] = foundationVariable(value)
+	}
+
+	return result
+}
+
+// foundationVariable gives a variable as apps see it: strings as they are,
+// numbers, booleans and nested values JSON-encoded.
+func foundationVariable(value interface{}) string {
+	text, ok := value.(string)
+	if ok {
+		return text
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(data)
+}
+
+func exportIsolationSegments(state *FoundationState, orgs []Organization) []FoundationExportIsolationSegment {
+	names := newFoundationOrganizationNames(orgs)
+	segments := make([]FoundationExportIsolationSegment, 0, len(state.IsolationSegments))
+
+	for _, segment := range state.IsolationSegments {
+		exported := FoundationExportIsolationSegment{Name: segment.Name}
+		for _, guid := range segment.OrganizationGUIDs {
+			exported.Organizations = append(exported.Organizations, names.name(guid))
+		}
+
+		sort.Strings(exported.Organizations)
+
+		segments = append(segments, exported)
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Name < segments[j].Name
+	})
+
+	return segments
+}
+
+// foundationNames maps GUIDs to names; unknown GUIDs stand for themselves.
+type foundationNames map[string]string
+
+func (n foundationNames) name(guid string) string {
+	if name, ok := n[guid]; ok {
+		return name
+	}
+
+	return guid
+}
+
+func newFoundationOrganizationNames(orgs []Organization) foundationNames {
+	names := make(foundationNames, len(orgs))
+	for _, org := range orgs {
+		names[org.GUID] = org.Name
+	}
+
+	return names
+}
+
+// exportDomains reads the shared domains and the private domains owned by
+// or shared with a selected organization.
+func exportDomains(ctx context.Context, client Client, orgs, selected []Organization) ([]FoundationDomain, error) {
+	domains, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Domain], error) {
+		return client.Domains().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	names := newFoundationOrganizationNames(orgs)
+	wanted := newFoundationOrganizationNames(selected)
+
+	routerGroups, err := exportRouterGroupNames(ctx, client, domains)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]FoundationDomain, 0, len(domains))
+
+	for _, domain := range domains {
+		result := FoundationDomain{Name: domain.Name, Internal: domain.Internal}
+		include := domain.Relationships.Organization == nil || domain.Relationships.Organization.Data == nil
+
+		if !include {
+			owner := domain.Relationships.Organization.Data.GUID
+			_, include = wanted[owner]
+			result.Organization = names.name(owner)
+		}
+
+		if domain.Relationships.SharedOrganizations != nil {
+			for _, shared := range domain.Relationships.SharedOrganizations.Data {
+				if _, ok := wanted[shared.GUID]; ok {
+					include = true
+				}
+
+				result.SharedOrganizations = append(result.SharedOrganizations, names.name(shared.GUID))
+			}
+		}
+
+		if !include {
+			continue
+		}
+
+		if domain.RouterGroup != nil {
+			result.RouterGroup = routerGroups.name(domain.RouterGroup.GUID)
+		}
+
+		sort.Strings(result.SharedOrganizations)
+
+		exported = append(exported, result)
+	}
+
+	sort.Slice(exported, func(i, j int) bool {
+		return exported[i].Name < exported[j].Name
+	})
+
+	return exported, nil
+}
+
+// exportRouterGroupNames resolves router group names through the routing
+// API, which is only asked when a domain has a router group.
+func exportRouterGroupNames(ctx context.Context, client Client, domains []Domain) (foundationNames, error) {
+	names := foundationNames{}
+
+	for _, domain := range domains {
+		if domain.RouterGroup == nil {
+			continue
+		}
+
+		groups, err := client.Routing().ListRouterGroups(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list router groups: %w", err)
+		}
+
+		for _, group := range groups {
+			names[group.GUID] = group.Name
+		}
+
+		break
+	}
+
+	return names, nil
+}
+
+// exportServicePlanVisibility reads the visibility of every service plan
+// that is public, admin-only or visible to a selected organization or one of
+// its spaces.
+func exportServicePlanVisibility(
+	ctx context.Context,
+	client Client,
+	state *FoundationState,
+	orgs, selected []Organization,
+) ([]FoundationServicePlanVisibility, error) {
+	brokers, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceBroker], error) {
+		return client.ServiceBrokers().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service brokers: %w", err)
+	}
+
+	offerings, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceOffering], error) {
+		return client.ServiceOfferings().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service offerings: %w", err)
+	}
+
+	plans, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServicePlan], error) {
+		return client.ServicePlans().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service plans: %w", err)
+	}
+
+	brokerNames := make(map[string]string, len(brokers))
+	for _, broker := range brokers {
+		brokerNames[broker.GUID] = broker.Name
+	}
+
+	offeringsByGUID := make(map[string]ServiceOffering, len(offerings))
+	for _, offering := range offerings {
+		offeringsByGUID[offering.GUID] = offering
+	}
+
+	spaces := map[string]string{}
+
+	for _, org := range state.Organizations {
+		for _, space := range org.Spaces {
+			spaces[space.GUID] = org.Name + "/" + space.Name
+		}
+	}
+
+	names := newFoundationOrganizationNames(orgs)
+	wanted := newFoundationOrganizationNames(selected)
+	exported := make([]FoundationServicePlanVisibility, 0, len(plans))
+
+	for _, plan := range plans {
+		result := FoundationServicePlanVisibility{Plan: plan.Name, Visibility: plan.VisibilityType}
+
+		if plan.Relationships.ServiceOffering.Data != nil {
+			offering := offeringsByGUID[plan.Relationships.ServiceOffering.Data.GUID]
+			result.ServiceOffering = offering.Name
+
+			if offering.Relationships.ServiceBroker.Data != nil {
+				result.ServiceBroker = brokerNames[offering.Relationships.ServiceBroker.Data.GUID]
+			}
+		}
+
+		include, err := exportPlanAudience(ctx, client, plan, &result, names, wanted, spaces)
+		if err != nil {
+			return nil, err
+		}
+
+		if include {
+			exported = append(exported, result)
+		}
+	}
+
+	sort.Slice(exported, func(i, j int) bool {
+		a, b := exported[i], exported[j]
+		if a.ServiceBroker != b.ServiceBroker {
+			return a.ServiceBroker < b.ServiceBroker
+		}
+
+		if a.ServiceOffering != b.ServiceOffering {
+			return a.ServiceOffering < b.ServiceOffering
+		}
+
+		return a.Plan < b.Plan
+	})
+
+	return exported, nil
+}
+
+// exportPlanAudience fills in who can see a plan scoped to organizations or
+// a space, and reports whether that concerns a selected organization.
+func exportPlanAudience(
+	ctx context.Context,
+	client Client,
+	plan ServicePlan,
+	result *FoundationServicePlanVisibility,
+	names, wanted foundationNames,
+	spaces map[string]string,
+) (bool, error) {
+	switch plan.VisibilityType {
+	case "organization":
+		visibility, err := client.ServicePlans().GetVisibility(ctx, plan.GUID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get visibility of service plan %s: %w", plan.Name, err)
+		}
+
+		include := false
+
+		for _, org := range visibility.Organizations {
+			if _, ok := wanted[org.GUID]; ok {
+				include = true
+			}
+
+			name := names.name(org.GUID)
+			if name == org.GUID && org.Name != "" {
+				name = org.Name
+			}
+
+			result.Organizations = append(result.Organizations, name)
+		}
+
+		sort.Strings(result.Organizations)
+
+		return include, nil
+	case "space":
+		if plan.Relationships.Space == nil || plan.Relationships.Space.Data == nil {
+			return false, nil
+		}
+
+		space, ok := spaces[plan.Relationships.Space.Data.GUID]
+		result.Space = space
+
+		return ok, nil
+	}
+
+	return true, nil
+}
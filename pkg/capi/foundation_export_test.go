@@ -0,0 +1,324 @@
+package capi_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportState() *capi.FoundationState {
+	memory := 10240
+	ports := "443"
+
+	return &capi.FoundationState{
+		FeatureFlags: map[string]bool{"diego_docker": true},
+		EnvironmentVariableGroups: map[string]map[string]interface{}{
+			"running": {"HTTP_PROXY": "http://proxy:8080", "RETRIES": float64(3)},
+			"staging": {},
+		},
+		OrganizationQuotas: []capi.OrganizationQuota{{
+			Resource: capi.Resource{GUID: "quota-small"},
+			Name:     "small",
+			Apps:     &capi.OrganizationQuotaApps{TotalMemoryInMB: &memory},
+		}},
+		SecurityGroups: []capi.SecurityGroup{{
+			Resource: capi.Resource{GUID: "sg-internal"},
+			Name:     "internal",
+			Rules:    []capi.SecurityGroupRule{{Protocol: "tcp", Destination: "10.0.0.0/8", Ports: &ports}},
+			Relationships: capi.SecurityGroupRelationships{
+				RunningSpaces: capi.ToManyRelationship{Data: []capi.RelationshipData{{GUID: "space-prod"}}},
+			},
+		}},
+		IsolationSegments: []capi.FoundationIsolationSegment{
+			{IsolationSegment: capi.IsolationSegment{Resource: capi.Resource{GUID: "iso-secure"}, Name: "secure"}, OrganizationGUIDs: []string{"org-payments"}},
+		},
+		Organizations: []capi.FoundationOrganizationState{{
+			Organization: capi.Organization{
+				Resource:      capi.Resource{GUID: "org-payments"},
+				Name:          "payments",
+				Relationships: &capi.OrgRelationships{Quota: capi.Relationship{Data: &capi.RelationshipData{GUID: "quota-small"}}},
+			},
+			Spaces: []capi.Space{
+				{Resource: capi.Resource{GUID: "space-prod"}, Name: "prod"},
+				{
+					Resource:      capi.Resource{GUID: "space-dev"},
+					Name:          "dev",
+					Relationships: capi.SpaceRelationships{Quota: &capi.Relationship{Data: &capi.RelationshipData{GUID: "sq-dev"}}},
+				},
+			},
+			SpaceQuotas: []capi.SpaceQuotaV3{{Resource: capi.Resource{GUID: "sq-dev"}, Name: "dev"}},
+			Roles: []capi.Role{
+				orgRole("role-carol", "organization_manager", "u-carol", "org-payments"),
+				orgRole("role-alice", "organization_manager", "u-alice", "org-payments"),
+				orgRole("role-client", "organization_auditor", "client-guid", "org-payments"),
+				{
+					Resource: capi.Resource{GUID: "role-bob"},
+					Type:     "space_developer",
+					Relationships: capi.RoleRelationships{
+						User:  capi.Relationship{Data: &capi.RelationshipData{GUID: "u-bob"}},
+						Space: &capi.Relationship{Data: &capi.RelationshipData{GUID: "space-prod"}},
+					},
+				},
+			},
+		}},
+		Users: []capi.User{
+			{Resource: capi.Resource{GUID: "u-alice"}, Username: "alice", Origin: "uaa"},
+			{Resource: capi.Resource{GUID: "u-bob"}, Username: "bob", Origin: "uaa"},
+			{Resource: capi.Resource{GUID: "u-carol"}, Username: "carol", Origin: "uaa"},
+			{Resource: capi.Resource{GUID: "client-guid"}, Origin: "uaa"},
+		},
+	}
+}
+
+func TestFoundationState_Foundation(t *testing.T) {
+	t.Parallel()
+
+	foundation := exportState().Foundation()
+
+	assert.Equal(t, map[string]string{"HTTP_PROXY": "http://proxy:8080", "RETRIES": "3"}, foundation.EnvironmentVariableGroups.Running)
+	require.Len(t, foundation.OrganizationQuotas, 1)
+	assert.Equal(t, 10240, *foundation.OrganizationQuotas[0].Apps.TotalMemoryInMB)
+	require.Len(t, foundation.SecurityGroups, 1)
+	assert.Equal(t, &capi.SecurityGroupGloballyEnabled{}, foundation.SecurityGroups[0].GloballyEnabled)
+
+	require.Len(t, foundation.Organizations, 1)
+
+	org := foundation.Organizations[0]
+	assert.Equal(t, "small", org.Quota)
+	assert.Equal(t, []string{"secure"}, org.IsolationSegments)
+	assert.Equal(t, []string{"alice", "carol"}, org.Users.Managers)
+	assert.Equal(t, []string{"client-guid"}, org.Users.Auditors, "users without a username are given by GUID")
+	assert.Empty(t, org.Users.BillingManagers)
+
+	require.Len(t, org.Spaces, 2)
+	assert.Equal(t, "dev", org.Spaces[0].Name)
+	assert.Equal(t, "dev", org.Spaces[0].Quota)
+	assert.Equal(t, "prod", org.Spaces[1].Name)
+	assert.Equal(t, []string{"bob"}, org.Spaces[1].Users.Developers)
+	assert.Equal(t, []string{"internal"}, org.Spaces[1].SecurityGroups.Running)
+
+	// Planning the export against the state it came from only adopts.
+	plan, err := capi.PlanFoundation(foundation, exportState(), capi.FoundationPlanOptions{})
+	require.NoError(t, err)
+
+	for _, change := range plan.Changes {
+		assert.Equal(t, capi.FoundationActionUpdate, change.Action, change.Name)
+
+		for _, field := range change.Fields {
+			assert.True(t, strings.HasPrefix(field.Field, "labels."), "%s %s: %s", change.Resource, change.Name, field.Field)
+		}
+	}
+}
+
+// exportOrganizations serves payments and other.
+type exportOrganizations struct {
+	capi.OrganizationsClient
+}
+
+func (s *exportOrganizations) List(_ context.Context, _ *capi.QueryParams, _ ...capi.OrganizationListOption) (*capi.ListResponse[capi.Organization], error) {
+	return &capi.ListResponse[capi.Organization]{Resources: []capi.Organization{
+		{Resource: capi.Resource{GUID: "org-payments"}, Name: "payments"},
+		{Resource: capi.Resource{GUID: "org-other"}, Name: "other"},
+	}}, nil
+}
+
+type exportFeatureFlags struct {
+	capi.FeatureFlagsClient
+}
+
+func (s *exportFeatureFlags) List(_ context.Context, _ *capi.QueryParams) (*capi.ListResponse[capi.FeatureFlag], error) {
+	return &capi.ListResponse[capi.FeatureFlag]{Resources: []capi.FeatureFlag{{Name: "diego_docker", Enabled: true}}}, nil
+}
+
+type exportEnvVarGroups struct {
+	capi.EnvironmentVariableGroupsClient
+}
+
+func (s *exportEnvVarGroups) Get(_ context.Context, name string) (*capi.EnvironmentVariableGroup, error) {
+	return &capi.EnvironmentVariableGroup{Name: name, Var: map[string]interface{}{"GROUP": name}}, nil
+}
+
+type exportSecurityGroups struct {
+	capi.SecurityGroupsClient
+}
+
+func (s *exportSecurityGroups) List(_ context.Context, _ *capi.QueryParams, _ ...capi.SecurityGroupListOption) (*capi.ListResponse[capi.SecurityGroup], error) {
+	return &capi.ListResponse[capi.SecurityGroup]{Resources: []capi.SecurityGroup{{
+		Resource: capi.Resource{GUID: "sg-internal"},
+		Name:     "internal",
+		Relationships: capi.SecurityGroupRelationships{
+			StagingSpaces: capi.ToManyRelationship{Data: []capi.RelationshipData{{GUID: "space-prod"}}},
+		},
+	}}}, nil
+}
+
+type exportIsolationSegments struct {
+	capi.IsolationSegmentsClient
+}
+
+func (s *exportIsolationSegments) List(_ context.Context, _ *capi.QueryParams, _ ...capi.IsolationSegmentListOption) (*capi.ListResponse[capi.IsolationSegment], error) {
+	return &capi.ListResponse[capi.IsolationSegment]{Resources: []capi.IsolationSegment{
+		{Resource: capi.Resource{GUID: "iso-secure"}, Name: "secure"},
+	}}, nil
+}
+
+func (s *exportIsolationSegments) ListOrganizations(_ context.Context, _ string, _ *capi.QueryParams) (*capi.ListResponse[capi.Organization], error) {
+	return &capi.ListResponse[capi.Organization]{Resources: []capi.Organization{
+		{Resource: capi.Resource{GUID: "org-payments"}},
+		{Resource: capi.Resource{GUID: "org-other"}},
+	}}, nil
+}
+
+// exportDomains serves a shared TCP domain and one private domain of each
+// organization.
+type exportDomains struct {
+	capi.DomainsClient
+}
+
+func (s *exportDomains) List(_ context.Context, _ *capi.QueryParams, _ ...capi.DomainListOption) (*capi.ListResponse[capi.Domain], error) {
+	owned := func(name, orgGUID string) capi.Domain {
+		return capi.Domain{Name: name, Relationships: capi.DomainRelationships{
+			Organization: &capi.Relationship{Data: &capi.RelationshipData{GUID: orgGUID}},
+		}}
+	}
+
+	return &capi.ListResponse[capi.Domain]{Resources: []capi.Domain{
+		owned("pay.example.com", "org-payments"),
+		owned("other.example.com", "org-other"),
+		{Name: "tcp.example.com", RouterGroup: &capi.RouterGroup{GUID: "rg-guid"}},
+	}}, nil
+}
+
+type exportRouting struct {
+	capi.RoutingClient
+}
+
+func (s *exportRouting) ListRouterGroups(_ context.Context) ([]capi.RouterGroup, error) {
+	return []capi.RouterGroup{{GUID: "rg-guid", Name: "default-tcp"}}, nil
+}
+
+type exportServiceBrokers struct {
+	capi.ServiceBrokersClient
+}
+
+func (s *exportServiceBrokers) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ServiceBrokerListOption) (*capi.ListResponse[capi.ServiceBroker], error) {
+	return &capi.ListResponse[capi.ServiceBroker]{Resources: []capi.ServiceBroker{{Resource: capi.Resource{GUID: "broker"}, Name: "db-broker"}}}, nil
+}
+
+type exportServiceOfferings struct {
+	capi.ServiceOfferingsClient
+}
+
+func (s *exportServiceOfferings) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ServiceOfferingListOption) (*capi.ListResponse[capi.ServiceOffering], error) {
+	return &capi.ListResponse[capi.ServiceOffering]{Resources: []capi.ServiceOffering{{
+		Resource:      capi.Resource{GUID: "offering"},
+		Name:          "postgres",
+		Relationships: capi.ServiceOfferingRelationships{ServiceBroker: capi.Relationship{Data: &capi.RelationshipData{GUID: "broker"}}},
+	}}}, nil
+}
+
+// exportServicePlans serves a public plan, a plan visible to other only and
+// a plan scoped to the prod space of payments.
+type exportServicePlans struct {
+	capi.ServicePlansClient
+}
+
+func (s *exportServicePlans) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ServicePlanListOption) (*capi.ListResponse[capi.ServicePlan], error) {
+	plan := func(guid, name, visibility string) capi.ServicePlan {
+		return capi.ServicePlan{
+			Resource:       capi.Resource{GUID: guid},
+			Name:           name,
+			VisibilityType: visibility,
+			Relationships: capi.ServicePlanRelationships{
+				ServiceOffering: capi.Relationship{Data: &capi.RelationshipData{GUID: "offering"}},
+			},
+		}
+	}
+
+	scoped := plan("plan-space", "dev", "space")
+	scoped.Relationships.Space = &capi.Relationship{Data: &capi.RelationshipData{GUID: "space-prod"}}
+
+	return &capi.ListResponse[capi.ServicePlan]{Resources: []capi.ServicePlan{
+		plan("plan-small", "small", "public"),
+		plan("plan-large", "large", "organization"),
+		scoped,
+	}}, nil
+}
+
+func (s *exportServicePlans) GetVisibility(_ context.Context, _ string) (*capi.ServicePlanVisibility, error) {
+	return &capi.ServicePlanVisibility{Type: "organization", Organizations: []capi.ServicePlanVisibilityOrg{{GUID: "org-other"}}}, nil
+}
+
+func exportClient() *stubClient {
+	return &stubClient{
+		organizations:    &exportOrganizations{},
+		spaces:           &foundationSpaces{},
+		spaceQuotas:      &foundationSpaceQuotas{},
+		orgQuotas:        &foundationOrganizationQuotas{},
+		roles:            &foundationRoles{},
+		users:            &foundationUsersClient{},
+		featureFlags:     &exportFeatureFlags{},
+		envVarGroups:     &exportEnvVarGroups{},
+		securityGroups:   &exportSecurityGroups{},
+		isoSegments:      &exportIsolationSegments{},
+		domains:          &exportDomains{},
+		routing:          &exportRouting{},
+		serviceBrokers:   &exportServiceBrokers{},
+		serviceOfferings: &exportServiceOfferings{},
+		servicePlans:     &exportServicePlans{},
+	}
+}
+
+func TestExportFoundation_SelectedOrganization(t *testing.T) {
+	t.Parallel()
+
+	export, err := capi.ExportFoundation(context.Background(), exportClient(), capi.FoundationExportOptions{Organizations: []string{"payments"}})
+	require.NoError(t, err)
+
+	foundation := export.Foundation
+	assert.Equal(t, map[string]bool{"diego_docker": true}, foundation.FeatureFlags)
+	assert.Equal(t, map[string]string{"GROUP": "staging"}, foundation.EnvironmentVariableGroups.Staging)
+
+	require.Len(t, foundation.Organizations, 1)
+
+	org := foundation.Organizations[0]
+	assert.Equal(t, "payments", org.Name)
+	assert.Equal(t, []string{"secure"}, org.IsolationSegments)
+	assert.Equal(t, []string{"alice"}, org.Users.Managers)
+	require.Len(t, org.Spaces, 1)
+	assert.Equal(t, []string{"carol"}, org.Spaces[0].Users.Developers)
+	assert.Equal(t, []string{"internal"}, org.Spaces[0].SecurityGroups.Staging)
+
+	assert.Equal(t, []capi.FoundationDomain{
+		{Name: "pay.example.com", Organization: "payments"},
+		{Name: "tcp.example.com", RouterGroup: "default-tcp"},
+	}, export.Domains)
+
+	assert.Equal(t, []capi.FoundationExportIsolationSegment{{Name: "secure", Organizations: []string{"other", "payments"}}}, export.IsolationSegments)
+
+	assert.Equal(t, []capi.FoundationServicePlanVisibility{
+		{ServiceBroker: "db-broker", ServiceOffering: "postgres", Plan: "dev", Visibility: "space", Space: "payments/prod"},
+		{ServiceBroker: "db-broker", ServiceOffering: "postgres", Plan: "small", Visibility: "public"},
+	}, export.ServicePlanVisibility)
+}
+
+func TestExportFoundation_AllOrganizations(t *testing.T) {
+	t.Parallel()
+
+	export, err := capi.ExportFoundation(context.Background(), exportClient(), capi.FoundationExportOptions{})
+	require.NoError(t, err)
+
+	require.Len(t, export.Foundation.Organizations, 2)
+	assert.Equal(t, "other", export.Foundation.Organizations[0].Name)
+	assert.Len(t, export.Domains, 3)
+	require.Len(t, export.ServicePlanVisibility, 3)
+	assert.Equal(t, "large", export.ServicePlanVisibility[1].Plan)
+	assert.Equal(t, []string{"other"}, export.ServicePlanVisibility[1].Organizations)
+
+	_, err = capi.ExportFoundation(context.Background(), exportClient(), capi.FoundationExportOptions{Organizations: []string{"missing"}})
+	require.ErrorIs(t, err, capi.ErrNotFound)
+}
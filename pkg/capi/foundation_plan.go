@@ -0,0 +1,605 @@
+package capi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FoundationAction is what a plan does to a resource.
+type FoundationAction string
+
+// Foundation plan actions.
+const (
+	FoundationActionCreate FoundationAction = "create"
+	FoundationActionUpdate FoundationAction = "update"
+	FoundationActionDelete FoundationAction = "delete"
+)
+
+// FoundationFieldChange is the change of one field of a resource. Old is
+// empty for fields being set and New for fields being removed.
+type FoundationFieldChange struct {
+	Field string `json:"field"         yaml:"field"`
+	Old   string `json:"old,omitempty" yaml:"old,omitempty"`
+	New   string `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// FoundationChange is the creation, update or deletion of one resource,
+// along with the batch operations that carry it out.
+type FoundationChange struct {
+	Action FoundationAction `json:"action"   yaml:"action"`
+	// Resource is the batch resource name, e.g. "organization" or
+	// "feature_flag".
+	Resource string `json:"resource" yaml:"resource"`
+	// Name identifies the resource; spaces and space quotas are named
+	// "<organization>/<name>".
+	Name   string                  `json:"name"             yaml:"name"`
+	Fields []FoundationFieldChange `json:"fields,omitempty" yaml:"fields,omitempty"`
+
+	operations []BatchOperation
+}
+
+// FoundationPlan is the list of changes turning the current state of a
+// foundation into the state a Foundation document describes.
+type FoundationPlan struct {
+	Changes []FoundationChange `json:"changes" yaml:"changes"`
+}
+
+// FoundationPlanOptions control PlanFoundation.
+type FoundationPlanOptions struct {
+	// Prune deletes the organizations, spaces, organization quotas and space
+	// quotas that carry the document's management label but are no longer
+	// in it. Deleting an organization deletes its spaces.
+	Prune bool
+}
+
+// HasChanges reports whether applying the plan changes anything.
+func (p *FoundationPlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// Count returns the number of changes with the given action.
+func (p *FoundationPlan) Count(action FoundationAction) int {
+	count := 0
+
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Summary returns a one-line count of the changes.
+func (p *FoundationPlan) Summary() string {
+	if !p.HasChanges() {
+		return "No changes. The foundation matches the document."
+	}
+
+	return fmt.Sprintf("Plan: %d to create, %d to update, %d to delete.",
+		p.Count(FoundationActionCreate), p.Count(FoundationActionUpdate), p.Count(FoundationActionDelete))
+}
+
+// Operations returns the batch operations applying the plan. Operations
+// refer to each other with Ref and DependsOn, so BatchExecutor runs them in
+// dependency order.
+func (p *FoundationPlan) Operations() []BatchOperation {
+	var operations []BatchOperation
+
+	for _, change := range p.Changes {
+		operations = append(operations, change.operations...)
+	}
+
+	return operations
+}
+
+// WriteText writes the plan in the style of terraform plan: "+" for
+// creations, "~" for updates and "-" for deletions, followed by the summary.
+func (p *FoundationPlan) WriteText(w io.Writer, color bool) error {
+	buf := bufio.NewWriter(w)
+
+	for _, change := range p.Changes {
+		symbol, ansi := foundationActionStyle(change.Action)
+
+		_, _ = buf.WriteString(colorize(fmt.Sprintf("  %s %s %q", symbol, change.Resource, change.Name), ansi, color) + "\n")
+
+		for _, field := range change.Fields {
+			_, _ = buf.WriteString("      " + formatFoundationField(field, color) + "\n")
+		}
+	}
+
+	if p.HasChanges() {
+		_, _ = buf.WriteString("\n")
+	}
+
+	_, _ = buf.WriteString(colorize(p.Summary(), ansiBold, color) + "\n")
+
+	err := buf.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write foundation plan: %w", err)
+	}
+
+	return nil
+}
+
+func foundationActionStyle(action FoundationAction) (string, string) {
+	switch action {
+	case FoundationActionCreate:
+		return "+", ansiGreen
+	case FoundationActionDelete:
+		return "-", ansiRed
+	default:
+		return "~", ansiYellow
+	}
+}
+
+func formatFoundationField(field FoundationFieldChange, color bool) string {
+	switch {
+	case field.Old == "":
+		return colorize(fmt.Sprintf("+ %s = %s", field.Field, field.New), ansiGreen, color)
+	case field.New == "":
+		return colorize(fmt.Sprintf("- %s = %s", field.Field, field.Old), ansiRed, color)
+	default:
+		return colorize(fmt.Sprintf("~ %s: %s -> %s", field.Field, field.Old, field.New), ansiYellow, color)
+	}
+}
+
+// PlanFoundation compares the desired foundation with its current state and
+// returns the changes to make. A document only manages what it mentions:
+// resources it leaves out are kept unless opts.Prune is set and they carry
+// its management label, and fields it leaves out are kept as they are.
+// Declared organizations, spaces and quotas that exist without the label are
+// adopted by adding it.
+//
+// It fails with ErrFoundationUnresolved when the document names users,
+// quotas, security groups, isolation segments or feature flags that neither
+// exist nor are declared.
+func PlanFoundation(desired *Foundation, state *FoundationState, opts FoundationPlanOptions) (*FoundationPlan, error) {
+	planner := newFoundationPlanner(desired, state, opts)
+
+	planner.planFeatureFlags()
+	planner.planEnvironmentVariableGroups()
+	planner.planOrganizationQuotas()
+	planner.planSecurityGroups()
+
+	for _, org := range desired.Organizations {
+		planner.planOrganization(org)
+	}
+
+	if opts.Prune {
+		planner.pruneOrganizations()
+		planner.pruneOrganizationQuotas()
+	}
+
+	if len(planner.errs) > 0 {
+		return nil, errors.Join(planner.errs...)
+	}
+
+	return &planner.plan, nil
+}
+
+type foundationPlanner struct {
+	desired   *Foundation
+	state     *FoundationState
+	opts      FoundationPlanOptions
+	managedBy string
+	plan      FoundationPlan
+	errs      []error
+
+	// users maps usernames to users, preferring the uaa origin when a
+	// username exists in several, and users without a username, like UAA
+	// clients, by GUID; usernames maps user GUIDs back.
+	users     map[string]User
+	usernames map[string]string
+	// The GUIDs of resources by name: existing ones, or references to the
+	// operations creating them.
+	orgQuotaGUIDs      map[string]string
+	orgQuotaNames      map[string]string
+	securityGroupGUIDs map[string]string
+	// orgQuotaDependencies are the operations to run before pruned
+	// organization quotas can be deleted.
+	orgQuotaDependencies []string
+}
+
+func newFoundationPlanner(desired *Foundation, state *FoundationState, opts FoundationPlanOptions) *foundationPlanner {
+	planner := &foundationPlanner{
+		desired:            desired,
+		state:              state,
+		opts:               opts,
+		managedBy:          desired.ManagedByValue(),
+		users:              map[string]User{},
+		usernames:          map[string]string{},
+		orgQuotaGUIDs:      map[string]string{},
+		orgQuotaNames:      map[string]string{},
+		securityGroupGUIDs: map[string]string{},
+	}
+
+	for _, user := range state.Users {
+		if user.Username == "" {
+			planner.users[user.GUID] = user
+
+			continue
+		}
+
+		planner.usernames[user.GUID] = user.Username
+
+		existing, ok := planner.users[user.Username]
+		if !ok || (existing.Origin != "uaa" && user.Origin == "uaa") {
+			planner.users[user.Username] = user
+		}
+	}
+
+	return planner
+}
+
+func (p *foundationPlanner) unresolved(path, kind, name string) {
+	p.errs = append(p.errs, fmt.Errorf("%w: %s: %s %q", ErrFoundationUnresolved, path, kind, name))
+}
+
+// add records a change unless it is an update with nothing to do.
+func (p *foundationPlanner) add(change FoundationChange) {
+	if change.Action == FoundationActionUpdate && len(change.operations) == 0 {
+		return
+	}
+
+	p.plan.Changes = append(p.plan.Changes, change)
+}
+
+func (p *foundationPlanner) managedMetadata() *Metadata {
+	return &Metadata{Labels: map[string]string{FoundationManagedLabel: p.managedBy}}
+}
+
+func (p *foundationPlanner) isManaged(metadata *Metadata) bool {
+	return metadata != nil && metadata.Labels[FoundationManagedLabel] == p.managedBy
+}
+
+// adopt labels an existing resource the document declares.
+func (p *foundationPlanner) adopt(change *FoundationChange, ops *BatchBuilder, id, resource, guid string, metadata *Metadata) {
+	if p.isManaged(metadata) {
+		return
+	}
+
+	old := ""
+	if metadata != nil {
+		old = metadata.Labels[FoundationManagedLabel]
+	}
+
+	change.Fields = append(change.Fields, FoundationFieldChange{Field: "labels." + FoundationManagedLabel, Old: old, New: p.managedBy})
+	ops.AddUpdateMetadata(id+"#label", resource, guid, p.managedMetadata())
+}
+
+func (p *foundationPlanner) planFeatureFlags() {
+	for _, name := range sortedKeys(p.desired.FeatureFlags) {
+		enabled := p.desired.FeatureFlags[name]
+
+		current, ok := p.state.FeatureFlags[name]
+		if !ok {
+			p.unresolved("feature_flags", "feature flag", name)
+
+			continue
+		}
+
+		if current == enabled {
+			continue
+		}
+
+		p.add(FoundationChange{
+			Action:     FoundationActionUpdate,
+			Resource:   "feature_flag",
+			Name:       name,
+			Fields:     []FoundationFieldChange{{Field: "enabled", Old: strconv.FormatBool(current), New: strconv.FormatBool(enabled)}},
+			operations: NewBatchBuilder().AddUpdateFeatureFlag("feature_flag:"+name, name, enabled).Build(),
+		})
+	}
+}
+
+// planEnvironmentVariableGroups makes the declared groups hold exactly the
+// declared variables. Current values that are not strings are compared in
+// their JSON encoding, the form a document holds them in.
+func (p *foundationPlanner) planEnvironmentVariableGroups() {
+	groups := p.desired.EnvironmentVariableGroups
+	if groups == nil {
+		return
+	}
+
+	for _, name := range []string{"running", "staging"} {
+		declared := groups.Running
+		if name == "staging" {
+			declared = groups.Staging
+		}
+
+		if declared == nil {
+			continue
+		}
+
+		current := p.state.EnvironmentVariableGroups[name]
+		change := FoundationChange{Action: FoundationActionUpdate, Resource: "environment_variable_group", Name: name}
+		vars := map[string]interface{}{}
+
+		for _, key := range sortedKeys(declared) {
+			old, ok := current[key]
+			if ok && foundationVariable(old) == declared[key] {
+				continue
+			}
+
+			field := FoundationFieldChange{Field: key, New: declared[key]}
+			if ok {
+				field.Old = foundationVariable(old)
+			}
+
+			change.Fields = append(change.Fields, field)
+			vars[key] = declared[key]
+		}
+
+		for _, key := range sortedKeys(current) {
+			if _, ok := declared[key]; !ok {
+				change.Fields = append(change.Fields, FoundationFieldChange{Field: key, Old: foundationVariable(current[key])})
+				vars[key] = nil
+			}
+		}
+
+		if len(vars) > 0 {
+			change.operations = NewBatchBuilder().AddUpdateEnvironmentVariableGroup("environment_variable_group:"+name, name, vars).Build()
+		}
+
+		p.add(change)
+	}
+}
+
+func (p *foundationPlanner) planOrganizationQuotas() {
+	existing := make(map[string]OrganizationQuota, len(p.state.OrganizationQuotas))
+
+	for _, quota := range p.state.OrganizationQuotas {
+		existing[quota.Name] = quota
+		p.orgQuotaGUIDs[quota.Name] = quota.GUID
+		p.orgQuotaNames[quota.GUID] = quota.Name
+	}
+
+	for _, quota := range p.desired.OrganizationQuotas {
+		id := "organization_quota:" + quota.Name
+		fields := diffFoundationLimits("apps", nil, quota.Apps)
+		fields = append(fields, diffFoundationLimits("services", nil, quota.Services)...)
+		fields = append(fields, diffFoundationLimits("routes", nil, quota.Routes)...)
+		fields = append(fields, diffFoundationLimits("domains", nil, quota.Domains)...)
+
+		current, ok := existing[quota.Name]
+		if !ok {
+			p.orgQuotaGUIDs[quota.Name] = Ref(id).GUID()
+			p.add(FoundationChange{
+				Action:   FoundationActionCreate,
+				Resource: "organization_quota",
+				Name:     quota.Name,
+				Fields:   fields,
+				operations: NewBatchBuilder().AddCreateOrganizationQuota(id, &OrganizationQuotaCreateRequest{
+					Name:     quota.Name,
+					Apps:     quota.Apps,
+					Services: quota.Services,
+					Routes:   quota.Routes,
+					Domains:  quota.Domains,
+					Metadata: p.managedMetadata(),
+				}).Build(),
+			})
+
+			continue
+		}
+
+		change := FoundationChange{Action: FoundationActionUpdate, Resource: "organization_quota", Name: quota.Name}
+		change.Fields = diffFoundationLimits("apps", current.Apps, quota.Apps)
+		change.Fields = append(change.Fields, diffFoundationLimits("services", current.Services, quota.Services)...)
+		change.Fields = append(change.Fields, diffFoundationLimits("routes", current.Routes, quota.Routes)...)
+		change.Fields = append(change.Fields, diffFoundationLimits("domains", current.Domains, quota.Domains)...)
+
+		ops := NewBatchBuilder()
+		if len(change.Fields) > 0 {
+			ops.AddUpdateOrganizationQuota(id, current.GUID, &OrganizationQuotaUpdateRequest{
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+				Domains:  quota.Domains,
+			})
+		}
+
+		p.adopt(&change, ops, id, "organization_quota", current.GUID, current.Metadata)
+		change.operations = ops.Build()
+		p.add(change)
+	}
+}
+
+// planSecurityGroups creates the declared security groups and updates the
+// rules and global enablement they declare.
+func (p *foundationPlanner) planSecurityGroups() {
+	existing := make(map[string]SecurityGroup, len(p.state.SecurityGroups))
+
+	for _, group := range p.state.SecurityGroups {
+		existing[group.Name] = group
+		p.securityGroupGUIDs[group.Name] = group.GUID
+	}
+
+	for _, group := range p.desired.SecurityGroups {
+		id := "security_group:" + group.Name
+
+		current, ok := existing[group.Name]
+		if !ok {
+			p.securityGroupGUIDs[group.Name] = Ref(id).GUID()
+			p.add(FoundationChange{
+				Action:   FoundationActionCreate,
+				Resource: "security_group",
+				Name:     group.Name,
+				Fields:   diffFoundationSecurityGroup(nil, group),
+				operations: NewBatchBuilder().AddCreateSecurityGroup(id, &SecurityGroupCreateRequest{
+					Name:            group.Name,
+					Rules:           group.Rules,
+					GloballyEnabled: group.GloballyEnabled,
+				}).Build(),
+			})
+
+			continue
+		}
+
+		change := FoundationChange{
+			Action:   FoundationActionUpdate,
+			Resource: "security_group",
+			Name:     group.Name,
+			Fields:   diffFoundationSecurityGroup(&current, group),
+		}
+
+		if len(change.Fields) > 0 {
+			request := &SecurityGroupUpdateRequest{Rules: group.Rules, GloballyEnabled: group.GloballyEnabled}
+			change.operations = NewBatchBuilder().AddUpdateSecurityGroup(id, current.GUID, request).Build()
+		}
+
+		p.add(change)
+	}
+}
+
+func diffFoundationSecurityGroup(current *SecurityGroup, desired FoundationSecurityGroup) []FoundationFieldChange {
+	var fields []FoundationFieldChange
+
+	if desired.Rules != nil {
+		field := FoundationFieldChange{Field: "rules", New: formatFoundationRules(desired.Rules)}
+		if current != nil {
+			field.Old = formatFoundationRules(current.Rules)
+		}
+
+		if field.Old != field.New {
+			fields = append(fields, field)
+		}
+	}
+
+	if desired.GloballyEnabled != nil {
+		var enabled *SecurityGroupGloballyEnabled
+		if current != nil {
+			enabled = &current.GloballyEnabled
+		}
+
+		for _, lifecycle := range []struct {
+			name    string
+			desired bool
+			current func(*SecurityGroupGloballyEnabled) bool
+		}{
+			{"running", desired.GloballyEnabled.Running, func(e *SecurityGroupGloballyEnabled) bool { return e.Running }},
+			{"staging", desired.GloballyEnabled.Staging, func(e *SecurityGroupGloballyEnabled) bool { return e.Staging }},
+		} {
+			field := FoundationFieldChange{Field: "globally_enabled." + lifecycle.name, New: strconv.FormatBool(lifecycle.desired)}
+			if enabled != nil {
+				field.Old = strconv.FormatBool(lifecycle.current(enabled))
+			}
+
+			if field.Old != field.New {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+func formatFoundationRules(rules []SecurityGroupRule) string {
+	if len(rules) == 0 {
+		return "[]"
+	}
+
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Sprint(rules)
+	}
+
+	return string(encoded)
+}
+
+// diffFoundationLimits compares the limits set in desired, a pointer to a
+// quota section such as *OrganizationQuotaApps, with current, a pointer of
+// the same type. Limits desired leaves nil are not compared. Fields are
+// named "<section>.<json name>".
+func diffFoundationLimits(section string, current, desired interface{}) []FoundationFieldChange {
+	want := reflect.ValueOf(desired)
+	if !want.IsValid() || want.IsNil() {
+		return nil
+	}
+
+	have := reflect.ValueOf(current)
+	if have.IsValid() && (have.IsNil() || have.Type() != want.Type()) {
+		have = reflect.Value{}
+	}
+
+	var fields []FoundationFieldChange
+
+	for i := range want.Elem().NumField() {
+		value := want.Elem().Field(i)
+		if value.IsNil() {
+			continue
+		}
+
+		tag, _, _ := strings.Cut(want.Elem().Type().Field(i).Tag.Get("json"), ",")
+		field := FoundationFieldChange{Field: section + "." + tag, New: fmt.Sprint(value.Elem().Interface())}
+
+		if have.IsValid() && !have.Elem().Field(i).IsNil() {
+			field.Old = fmt.Sprint(have.Elem().Field(i).Elem().Interface())
+		}
+
+		if field.Old != field.New {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// pruneOrganizations deletes the managed organizations the document left
+// out.
+func (p *foundationPlanner) pruneOrganizations() {
+	declared := make(map[string]bool, len(p.desired.Organizations))
+	for _, org := range p.desired.Organizations {
+		declared[org.Name] = true
+	}
+
+	for _, org := range p.state.Organizations {
+		if declared[org.Name] || !p.isManaged(org.Metadata) {
+			continue
+		}
+
+		id := "organization:" + org.Name
+		p.orgQuotaDependencies = append(p.orgQuotaDependencies, id)
+		p.add(FoundationChange{
+			Action:     FoundationActionDelete,
+			Resource:   "organization",
+			Name:       org.Name,
+			operations: NewBatchBuilder().AddDelete(id, "organization", org.GUID).Build(),
+		})
+	}
+}
+
+// pruneOrganizationQuotas deletes the managed organization quotas the
+// document left out, once the organizations using them moved or the jobs
+// deleting them completed; BatchExecutor holds dependents until then.
+func (p *foundationPlanner) pruneOrganizationQuotas() {
+	declared := make(map[string]bool, len(p.desired.OrganizationQuotas))
+	for _, quota := range p.desired.OrganizationQuotas {
+		declared[quota.Name] = true
+	}
+
+	for _, quota := range p.state.OrganizationQuotas {
+		if declared[quota.Name] || !p.isManaged(quota.Metadata) {
+			continue
+		}
+
+		id := "organization_quota:" + quota.Name
+		p.add(FoundationChange{
+			Action:     FoundationActionDelete,
+			Resource:   "organization_quota",
+			Name:       quota.Name,
+			operations: NewBatchBuilder().AddDelete(id, "organization_quota", quota.GUID).DependsOn(p.orgQuotaDependencies...).Build(),
+		})
+	}
+}
+
+// joinFoundationNames renders a set of names for a field change.
+func joinFoundationNames(names map[string]bool) string {
+	return strings.Join(sortedKeys(names), ", ")
+}
@@ -0,0 +1,569 @@
+package capi
+
+// foundationOrgPlan is the planning state of one declared organization.
+type foundationOrgPlan struct {
+	desired FoundationOrganization
+	// current is nil when the organization is created.
+	current *FoundationOrganizationState
+	id      string
+	// guid is the organization GUID, or a reference to its creation.
+	guid   string
+	change FoundationChange
+	ops    *BatchBuilder
+	// members holds the GUIDs of users with a role in the organization,
+	// mapped to the operation granting it or "" when they already have one.
+	members map[string]string
+	// newMembers are the usernames added as organization users because the
+	// document gives them a space role.
+	newMembers map[string]bool
+	// spaceQuotaGUIDs maps space quota names to GUIDs or references.
+	spaceQuotaGUIDs map[string]string
+	// spaceQuotaDependencies are the operations to run before pruned space
+	// quotas can be deleted.
+	spaceQuotaDependencies []string
+}
+
+func (p *foundationPlanner) planOrganization(desired FoundationOrganization) {
+	org := &foundationOrgPlan{
+		desired:         desired,
+		id:              "organization:" + desired.Name,
+		change:          FoundationChange{Action: FoundationActionUpdate, Resource: "organization", Name: desired.Name},
+		ops:             NewBatchBuilder(),
+		members:         map[string]string{},
+		newMembers:      map[string]bool{},
+		spaceQuotaGUIDs: map[string]string{},
+	}
+
+	for i := range p.state.Organizations {
+		if p.state.Organizations[i].Name == desired.Name {
+			org.current = &p.state.Organizations[i]
+		}
+	}
+
+	if org.current == nil {
+		org.change.Action = FoundationActionCreate
+		org.guid = Ref(org.id).GUID()
+		org.ops.AddCreateOrganization(org.id, &OrganizationCreateRequest{Name: desired.Name, Metadata: p.managedMetadata()})
+	} else {
+		org.guid = org.current.GUID
+		p.adopt(&org.change, org.ops, org.id, "organization", org.guid, org.current.Metadata)
+
+		for _, role := range org.current.Roles {
+			if role.Relationships.Organization != nil && role.Relationships.User.Data != nil {
+				org.members[role.Relationships.User.Data.GUID] = ""
+			}
+		}
+	}
+
+	p.planOrganizationQuota(org)
+	p.planIsolationSegments(org)
+
+	var roles []Role
+	if org.current != nil {
+		roles = org.current.Roles
+	}
+
+	for _, list := range desired.Users.roles() {
+		p.planRoles(&org.change, org.ops, org.id, list, roles, func(role Role) bool {
+			return role.Relationships.Organization != nil
+		}, func(request *RoleCreateRequest, userGUID, opID string) []string {
+			request.Relationships.Organization = &Relationship{Data: &RelationshipData{GUID: org.guid}}
+			org.members[userGUID] = opID
+
+			return nil
+		})
+	}
+
+	// Spaces are planned first because their roles may add organization
+	// users, but are applied after the organization.
+	spaceChanges := p.planSpaceQuotas(org)
+	for _, space := range desired.Spaces {
+		spaceChanges = append(spaceChanges, p.planSpace(org, space))
+	}
+
+	if len(org.newMembers) > 0 {
+		org.change.Fields = append(org.change.Fields, FoundationFieldChange{
+			Field: "users.organization_users",
+			New:   joinFoundationNames(org.newMembers),
+		})
+	}
+
+	org.change.operations = org.ops.Build()
+	p.add(org.change)
+
+	for _, change := range spaceChanges {
+		p.add(change)
+	}
+
+	if p.opts.Prune && org.current != nil {
+		p.pruneSpaces(org)
+	}
+}
+
+func (p *foundationPlanner) planOrganizationQuota(org *foundationOrgPlan) {
+	if org.desired.Quota == "" {
+		return
+	}
+
+	guid, ok := p.orgQuotaGUIDs[org.desired.Quota]
+	if !ok {
+		p.unresolved("organizations["+org.desired.Name+"].quota", "organization quota", org.desired.Quota)
+
+		return
+	}
+
+	current := ""
+	if org.current != nil && org.current.Relationships != nil && org.current.Relationships.Quota.Data != nil {
+		current = org.current.Relationships.Quota.Data.GUID
+	}
+
+	if current == guid {
+		return
+	}
+
+	id := org.id + "#quota"
+	org.change.Fields = append(org.change.Fields, FoundationFieldChange{Field: "quota", Old: p.orgQuotaNames[current], New: org.desired.Quota})
+	org.ops.AddApplyOrganizationQuota(id, guid, org.guid)
+	p.orgQuotaDependencies = append(p.orgQuotaDependencies, id)
+}
+
+// planIsolationSegments entitles the organization to exactly the declared
+// isolation segments.
+func (p *foundationPlanner) planIsolationSegments(org *foundationOrgPlan) {
+	if org.desired.IsolationSegments == nil {
+		return
+	}
+
+	desired := map[string]bool{}
+
+	for _, name := range org.desired.IsolationSegments {
+		desired[name] = true
+	}
+
+	current := map[string]bool{}
+	guids := map[string]string{}
+
+	for _, segment := range p.state.IsolationSegments {
+		guids[segment.Name] = segment.GUID
+
+		for _, guid := range segment.OrganizationGUIDs {
+			if org.current != nil && guid == org.current.GUID {
+				current[segment.Name] = true
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(desired) {
+		if _, ok := guids[name]; !ok {
+			p.unresolved("organizations["+org.desired.Name+"].isolation_segments", "isolation segment", name)
+
+			return
+		}
+	}
+
+	changed := false
+
+	for _, name := range sortedKeys(desired) {
+		if !current[name] {
+			changed = true
+
+			org.ops.AddEntitleIsolationSegment(org.id+"#isolation_segment:"+name, guids[name], org.guid)
+		}
+	}
+
+	for _, name := range sortedKeys(current) {
+		if !desired[name] {
+			changed = true
+
+			org.ops.AddRevokeIsolationSegment(org.id+"#isolation_segment:"+name, guids[name], org.guid)
+		}
+	}
+
+	if changed {
+		org.change.Fields = append(org.change.Fields, FoundationFieldChange{
+			Field: "isolation_segments",
+			Old:   joinFoundationNames(current),
+			New:   joinFoundationNames(desired),
+		})
+	}
+}
+
+// planRoles gives the role list's type to exactly its usernames among the
+// roles in scope. scope picks the current roles of the organization or
+// space; bind completes the creation request of a role for a user and
+// returns the operations it must wait for.
+func (p *foundationPlanner) planRoles(
+	change *FoundationChange,
+	ops *BatchBuilder,
+	id string,
+	list foundationRoleList,
+	roles []Role,
+	scope func(Role) bool,
+	bind func(request *RoleCreateRequest, userGUID, opID string) []string,
+) {
+	if list.usernames == nil {
+		return
+	}
+
+	desired := map[string]string{}
+
+	for _, username := range list.usernames {
+		user, ok := p.users[username]
+		if !ok {
+			p.unresolved(change.Resource+"s["+change.Name+"]."+list.field, "user", username)
+
+			continue
+		}
+
+		desired[user.GUID] = username
+	}
+
+	current := map[string]Role{}
+
+	for _, role := range roles {
+		if role.Type == string(list.roleType) && scope(role) && role.Relationships.User.Data != nil {
+			current[role.Relationships.User.Data.GUID] = role
+		}
+	}
+
+	oldNames := map[string]bool{}
+	newNames := map[string]bool{}
+	changed := false
+
+	for guid := range current {
+		oldNames[p.username(guid)] = true
+	}
+
+	for _, guid := range sortedKeys(desired) {
+		newNames[desired[guid]] = true
+
+		if _, ok := current[guid]; ok {
+			continue
+		}
+
+		changed = true
+		opID := id + "#" + string(list.roleType) + ":" + desired[guid]
+		request := &RoleCreateRequest{
+			Type:          string(list.roleType),
+			Relationships: RoleRelationships{User: Relationship{Data: &RelationshipData{GUID: guid}}},
+		}
+
+		dependencies := bind(request, guid, opID)
+		ops.AddCreateRole(opID, request).DependsOn(dependencies...)
+	}
+
+	for _, guid := range sortedKeys(current) {
+		if _, ok := desired[guid]; !ok {
+			changed = true
+
+			ops.AddDelete(id+"#"+string(list.roleType)+":"+p.username(guid), "role", current[guid].GUID)
+		}
+	}
+
+	if changed {
+		change.Fields = append(change.Fields, FoundationFieldChange{
+			Field: list.field,
+			Old:   joinFoundationNames(oldNames),
+			New:   joinFoundationNames(newNames),
+		})
+	}
+}
+
+func (p *foundationPlanner) username(guid string) string {
+	if name, ok := p.usernames[guid]; ok {
+		return name
+	}
+
+	return guid
+}
+
+// membership returns the operation making the user a member of the
+// organization, adding an organization_user role when the user has no role
+// there yet. It returns "" when the user already is a member.
+func (p *foundationPlanner) membership(org *foundationOrgPlan, userGUID string) string {
+	if opID, ok := org.members[userGUID]; ok {
+		return opID
+	}
+
+	username := p.username(userGUID)
+	opID := org.id + "#" + string(RoleTypeOrganizationUser) + ":" + username
+
+	org.ops.AddCreateRole(opID, &RoleCreateRequest{
+		Type: string(RoleTypeOrganizationUser),
+		Relationships: RoleRelationships{
+			User:         Relationship{Data: &RelationshipData{GUID: userGUID}},
+			Organization: &Relationship{Data: &RelationshipData{GUID: org.guid}},
+		},
+	})
+
+	org.members[userGUID] = opID
+	org.newMembers[username] = true
+
+	return opID
+}
+
+func (p *foundationPlanner) planSpaceQuotas(org *foundationOrgPlan) []FoundationChange {
+	existing := map[string]SpaceQuotaV3{}
+
+	if org.current != nil {
+		for _, quota := range org.current.SpaceQuotas {
+			existing[quota.Name] = quota
+			org.spaceQuotaGUIDs[quota.Name] = quota.GUID
+		}
+	}
+
+	changes := make([]FoundationChange, 0, len(org.desired.SpaceQuotas))
+
+	for _, quota := range org.desired.SpaceQuotas {
+		name := org.desired.Name + "/" + quota.Name
+		id := "space_quota:" + name
+
+		current, ok := existing[quota.Name]
+		if !ok {
+			fields := diffFoundationLimits("apps", nil, quota.Apps)
+			fields = append(fields, diffFoundationLimits("services", nil, quota.Services)...)
+			fields = append(fields, diffFoundationLimits("routes", nil, quota.Routes)...)
+
+			org.spaceQuotaGUIDs[quota.Name] = Ref(id).GUID()
+			changes = append(changes, FoundationChange{
+				Action:   FoundationActionCreate,
+				Resource: "space_quota",
+				Name:     name,
+				Fields:   fields,
+				operations: NewBatchBuilder().AddCreateSpaceQuota(id, &SpaceQuotaV3CreateRequest{
+					Name:     quota.Name,
+					Apps:     quota.Apps,
+					Services: quota.Services,
+					Routes:   quota.Routes,
+					Relationships: SpaceQuotaRelationships{
+						Organization: Relationship{Data: &RelationshipData{GUID: org.guid}},
+					},
+					Metadata: p.managedMetadata(),
+				}).Build(),
+			})
+
+			continue
+		}
+
+		change := FoundationChange{Action: FoundationActionUpdate, Resource: "space_quota", Name: name}
+		change.Fields = diffFoundationLimits("apps", current.Apps, quota.Apps)
+		change.Fields = append(change.Fields, diffFoundationLimits("services", current.Services, quota.Services)...)
+		change.Fields = append(change.Fields, diffFoundationLimits("routes", current.Routes, quota.Routes)...)
+
+		ops := NewBatchBuilder()
+		if len(change.Fields) > 0 {
+			ops.AddUpdateSpaceQuota(id, current.GUID, &SpaceQuotaV3UpdateRequest{
+				Apps:     quota.Apps,
+				Services: quota.Services,
+				Routes:   quota.Routes,
+			})
+		}
+
+		p.adopt(&change, ops, id, "space_quota", current.GUID, current.Metadata)
+		change.operations = ops.Build()
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func (p *foundationPlanner) planSpace(org *foundationOrgPlan, desired FoundationSpace) FoundationChange {
+	name := org.desired.Name + "/" + desired.Name
+	id := "space:" + name
+	change := FoundationChange{Action: FoundationActionUpdate, Resource: "space", Name: name}
+	ops := NewBatchBuilder()
+
+	var current *Space
+
+	if org.current != nil {
+		for i := range org.current.Spaces {
+			if org.current.Spaces[i].Name == desired.Name {
+				current = &org.current.Spaces[i]
+			}
+		}
+	}
+
+	guid := Ref(id).GUID()
+
+	if current == nil {
+		change.Action = FoundationActionCreate
+		ops.AddCreateSpace(id, &SpaceCreateRequest{
+			Name:          desired.Name,
+			Relationships: SpaceRelationships{Organization: Relationship{Data: &RelationshipData{GUID: org.guid}}},
+			Metadata:      p.managedMetadata(),
+		})
+	} else {
+		guid = current.GUID
+		p.adopt(&change, ops, id, "space", guid, current.Metadata)
+	}
+
+	p.planSpaceQuota(org, &change, ops, id, guid, current, desired.Quota)
+
+	var roles []Role
+	if org.current != nil && current != nil {
+		roles = org.current.Roles
+	}
+
+	for _, list := range desired.Users.roles() {
+		p.planRoles(&change, ops, id, list, roles, func(role Role) bool {
+			return role.Relationships.Space != nil && role.Relationships.Space.Data != nil && role.Relationships.Space.Data.GUID == guid
+		}, func(request *RoleCreateRequest, userGUID, _ string) []string {
+			request.Relationships.Space = &Relationship{Data: &RelationshipData{GUID: guid}}
+
+			if member := p.membership(org, userGUID); member != "" {
+				return []string{member}
+			}
+
+			return nil
+		})
+	}
+
+	p.planSecurityGroupBindings(&change, ops, id, guid, current, "running", desired.SecurityGroups.Running)
+	p.planSecurityGroupBindings(&change, ops, id, guid, current, "staging", desired.SecurityGroups.Staging)
+
+	change.operations = ops.Build()
+
+	return change
+}
+
+func (p *foundationPlanner) planSpaceQuota(org *foundationOrgPlan, change *FoundationChange, ops *BatchBuilder, id, guid string, current *Space, quota string) {
+	if quota == "" {
+		return
+	}
+
+	quotaGUID, ok := org.spaceQuotaGUIDs[quota]
+	if !ok {
+		p.unresolved("spaces["+change.Name+"].quota", "space quota", quota)
+
+		return
+	}
+
+	old := ""
+	if current != nil && current.Relationships.Quota != nil && current.Relationships.Quota.Data != nil {
+		old = current.Relationships.Quota.Data.GUID
+	}
+
+	if old == quotaGUID {
+		return
+	}
+
+	oldName := old
+	for name, guid := range org.spaceQuotaGUIDs {
+		if guid == old {
+			oldName = name
+		}
+	}
+
+	change.Fields = append(change.Fields, FoundationFieldChange{Field: "quota", Old: oldName, New: quota})
+	ops.AddApplySpaceQuota(id+"#quota", quotaGUID, guid)
+	org.spaceQuotaDependencies = append(org.spaceQuotaDependencies, id+"#quota")
+}
+
+// planSecurityGroupBindings binds exactly the declared security groups to
+// the space for the lifecycle.
+func (p *foundationPlanner) planSecurityGroupBindings(change *FoundationChange, ops *BatchBuilder, id, guid string, current *Space, lifecycle string, names []string) {
+	if names == nil {
+		return
+	}
+
+	desired := map[string]bool{}
+
+	for _, name := range names {
+		if _, ok := p.securityGroupGUIDs[name]; !ok {
+			p.unresolved("spaces["+change.Name+"].security_groups."+lifecycle, "security group", name)
+
+			continue
+		}
+
+		desired[name] = true
+	}
+
+	bound := map[string]bool{}
+
+	for _, group := range p.state.SecurityGroups {
+		spaces := group.Relationships.RunningSpaces.Data
+		if lifecycle == "staging" {
+			spaces = group.Relationships.StagingSpaces.Data
+		}
+
+		for _, space := range spaces {
+			if current != nil && space.GUID == current.GUID {
+				bound[group.Name] = true
+			}
+		}
+	}
+
+	changed := false
+
+	for _, name := range sortedKeys(desired) {
+		if !bound[name] {
+			changed = true
+
+			ops.AddBindSecurityGroup(id+"#security_group:"+lifecycle+":"+name, p.securityGroupGUIDs[name], lifecycle, guid)
+		}
+	}
+
+	for _, name := range sortedKeys(bound) {
+		if !desired[name] {
+			changed = true
+
+			ops.AddUnbindSecurityGroup(id+"#security_group:"+lifecycle+":"+name, p.securityGroupGUIDs[name], lifecycle, guid)
+		}
+	}
+
+	if changed {
+		change.Fields = append(change.Fields, FoundationFieldChange{
+			Field: "security_groups." + lifecycle,
+			Old:   joinFoundationNames(bound),
+			New:   joinFoundationNames(desired),
+		})
+	}
+}
+
+// pruneSpaces deletes the managed spaces and space quotas of a declared
+// organization that the document left out. The quotas wait for the space
+// delete jobs to complete.
+func (p *foundationPlanner) pruneSpaces(org *foundationOrgPlan) {
+	declared := map[string]bool{}
+	for _, space := range org.desired.Spaces {
+		declared[space.Name] = true
+	}
+
+	for _, space := range org.current.Spaces {
+		if declared[space.Name] || !p.isManaged(space.Metadata) {
+			continue
+		}
+
+		name := org.desired.Name + "/" + space.Name
+		id := "space:" + name
+		org.spaceQuotaDependencies = append(org.spaceQuotaDependencies, id)
+		p.add(FoundationChange{
+			Action:     FoundationActionDelete,
+			Resource:   "space",
+			Name:       name,
+			operations: NewBatchBuilder().AddDelete(id, "space", space.GUID).Build(),
+		})
+	}
+
+	declared = map[string]bool{}
+	for _, quota := range org.desired.SpaceQuotas {
+		declared[quota.Name] = true
+	}
+
+	for _, quota := range org.current.SpaceQuotas {
+		if declared[quota.Name] || !p.isManaged(quota.Metadata) {
+			continue
+		}
+
+		name := org.desired.Name + "/" + quota.Name
+		p.add(FoundationChange{
+			Action:   FoundationActionDelete,
+			Resource: "space_quota",
+			Name:     name,
+			operations: NewBatchBuilder().
+				AddDelete("space_quota:"+name, "space_quota", quota.GUID).
+				DependsOn(org.spaceQuotaDependencies...).
+				Build(),
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// FoundationState is the current state of the resources a Foundation
+// document mentions, and with pruning of the resources carrying its
+// management label. FetchFoundationState reads it; tests and other tools can
+// build one directly.
+type FoundationState struct {
+	FeatureFlags map[string]bool `json:"feature_flags,omitempty" yaml:"feature_flags,omitempty"`
+	// EnvironmentVariableGroups holds the running and staging groups by name.
+	EnvironmentVariableGroups map[string]map[string]interface{} `json:"environment_variable_groups,omitempty" yaml:"environment_variable_groups,omitempty"`
+	OrganizationQuotas        []OrganizationQuota               `json:"organization_quotas,omitempty"         yaml:"organization_quotas,omitempty"`
+	SecurityGroups            []SecurityGroup                   `json:"security_groups,omitempty"             yaml:"security_groups,omitempty"`
+	IsolationSegments         []FoundationIsolationSegment      `json:"isolation_segments,omitempty"          yaml:"isolation_segments,omitempty"`
+	Organizations             []FoundationOrganizationState     `json:"organizations,omitempty"               yaml:"organizations,omitempty"`
+	// Users are the users named by the document or holding a role in one of
+	// its organizations or spaces.
+	Users []User `json:"users,omitempty" yaml:"users,omitempty"`
+}
+
+// FoundationIsolationSegment is an isolation segment with the organizations
+// entitled to it.
+type FoundationIsolationSegment struct {
+	IsolationSegment
+
+	OrganizationGUIDs []string `json:"organization_guids" yaml:"organization_guids"`
+}
+
+// FoundationOrganizationState is an organization with its spaces, space
+// quotas and the organization and space roles of its users.
+type FoundationOrganizationState struct {
+	Organization
+
+	Spaces      []Space        `json:"spaces,omitempty"       yaml:"spaces,omitempty"`
+	SpaceQuotas []SpaceQuotaV3 `json:"space_quotas,omitempty" yaml:"space_quotas,omitempty"`
+	Roles       []Role         `json:"roles,omitempty"        yaml:"roles,omitempty"`
+}
+
+// FetchFoundationState reads the current state of what foundation mentions.
+// With prune it also reads the organizations carrying the foundation's
+// management label, and every space and space quota of the organizations it
+// reads, so that PlanFoundation can delete those left out of the document.
+func FetchFoundationState(ctx context.Context, client Client, foundation *Foundation, prune bool) (*FoundationState, error) {
+	state := &FoundationState{}
+
+	steps := []func(context.Context, Client, *Foundation, bool, *FoundationState) error{
+		fetchFoundationSettings,
+		fetchFoundationQuotas,
+		fetchFoundationSecurityGroups,
+		fetchFoundationIsolationSegments,
+		fetchFoundationOrganizations,
+		fetchFoundationUsers,
+	}
+
+	for _, step := range steps {
+		err := step(ctx, client, foundation, prune, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+func fetchFoundationSettings(ctx context.Context, client Client, foundation *Foundation, _ bool, state *FoundationState) error {
+	if len(foundation.FeatureFlags) > 0 {
+		err := readFoundationFeatureFlags(ctx, client, state)
+		if err != nil {
+			return err
+		}
+	}
+
+	groups := foundation.EnvironmentVariableGroups
+	if groups == nil {
+		return nil
+	}
+
+	if groups.Running != nil {
+		err := readFoundationEnvironmentVariableGroup(ctx, client, "running", state)
+		if err != nil {
+			return err
+		}
+	}
+
+	if groups.Staging != nil {
+		return readFoundationEnvironmentVariableGroup(ctx, client, "staging", state)
+	}
+
+	return nil
+}
+
+func readFoundationFeatureFlags(ctx context.Context, client Client, state *FoundationState) error {
+	flags, err := CollectAllPages(ctx, nil, client.FeatureFlags().List)
+	if err != nil {
+		return fmt.Errorf("failed to list feature flags: %w", err)
+	}
+
+	state.FeatureFlags = make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		state.FeatureFlags[flag.Name] = flag.Enabled
+	}
+
+	return nil
+}
+
+func readFoundationEnvironmentVariableGroup(ctx context.Context, client Client, name string, state *FoundationState) error {
+	group, err := client.EnvironmentVariableGroups().Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get %s environment variable group: %w", name, err)
+	}
+
+	if state.EnvironmentVariableGroups == nil {
+		state.EnvironmentVariableGroups = map[string]map[string]interface{}{}
+	}
+
+	state.EnvironmentVariableGroups[name] = group.Var
+
+	return nil
+}
+
+func fetchFoundationQuotas(ctx context.Context, client Client, foundation *Foundation, prune bool, state *FoundationState) error {
+	if !prune && len(foundation.OrganizationQuotas) == 0 && !foundationUsesOrganizationQuotas(foundation) {
+		return nil
+	}
+
+	return readFoundationOrganizationQuotas(ctx, client, state)
+}
+
+func readFoundationOrganizationQuotas(ctx context.Context, client Client, state *FoundationState) error {
+	quotas, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[OrganizationQuota], error) {
+		return client.OrganizationQuotas().List(ctx, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list organization quotas: %w", err)
+	}
+
+	state.OrganizationQuotas = quotas
+
+	return nil
+}
+
+func foundationUsesOrganizationQuotas(foundation *Foundation) bool {
+	for _, org := range foundation.Organizations {
+		if org.Quota != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func fetchFoundationSecurityGroups(ctx context.Context, client Client, foundation *Foundation, _ bool, state *FoundationState) error {
+	if len(foundation.SecurityGroups) == 0 && !foundationBindsSecurityGroups(foundation) {
+		return nil
+	}
+
+	return readFoundationSecurityGroups(ctx, client, state)
+}
+
+func readFoundationSecurityGroups(ctx context.Context, client Client, state *FoundationState) error {
+	groups, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[SecurityGroup], error) {
+		return client.SecurityGroups().List(ctx, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list security groups: %w", err)
+	}
+
+	state.SecurityGroups = groups
+
+	return nil
+}
+
+func foundationBindsSecurityGroups(foundation *Foundation) bool {
+	for _, org := range foundation.Organizations {
+		for _, space := range org.Spaces {
+			if space.SecurityGroups.Running != nil || space.SecurityGroups.Staging != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func fetchFoundationIsolationSegments(ctx context.Context, client Client, foundation *Foundation, _ bool, state *FoundationState) error {
+	// A declared list is authoritative, so every segment is read to find the
+	// entitlements to revoke as well as those to grant.
+	for _, org := range foundation.Organizations {
+		if org.IsolationSegments != nil {
+			return readFoundationIsolationSegments(ctx, client, state)
+		}
+	}
+
+	return nil
+}
+
+func readFoundationIsolationSegments(ctx context.Context, client Client, state *FoundationState) error {
+	segments, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[IsolationSegment], error) {
+		return client.IsolationSegments().List(ctx, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list isolation segments: %w", err)
+	}
+
+	state.IsolationSegments = make([]FoundationIsolationSegment, 0, len(segments))
+
+	for _, segment := range segments {
+		orgs, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Organization], error) {
+			return client.IsolationSegments().ListOrganizations(ctx, segment.GUID, params)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list organizations entitled to isolation segment %s: %w", segment.Name, err)
+		}
+
+		entitled := FoundationIsolationSegment{IsolationSegment: segment, OrganizationGUIDs: make([]string, 0, len(orgs))}
+		for _, org := range orgs {
+			entitled.OrganizationGUIDs = append(entitled.OrganizationGUIDs, org.GUID)
+		}
+
+		state.IsolationSegments = append(state.IsolationSegments, entitled)
+	}
+
+	return nil
+}
+
+func fetchFoundationOrganizations(ctx context.Context, client Client, foundation *Foundation, prune bool, state *FoundationState) error {
+	orgs := map[string]Organization{}
+
+	if len(foundation.Organizations) > 0 {
+		names := make([]string, 0, len(foundation.Organizations))
+		for _, org := range foundation.Organizations {
+			names = append(names, org.Name)
+		}
+
+		declared, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Organization], error) {
+			return client.Organizations().List(ctx, params, WithOrganizationNames(names...))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list organizations: %w", err)
+		}
+
+		for _, org := range declared {
+			orgs[org.GUID] = org
+		}
+	}
+
+	if prune {
+		params := NewQueryParams().WithSelector(NewLabelSelector().Equals(FoundationManagedLabel, foundation.ManagedByValue()))
+
+		managed, err := CollectAllPages(ctx, params, func(ctx context.Context, params *QueryParams) (*ListResponse[Organization], error) {
+			return client.Organizations().List(ctx, params)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list managed organizations: %w", err)
+		}
+
+		for _, org := range managed {
+			orgs[org.GUID] = org
+		}
+	}
+
+	for _, org := range orgs {
+		orgState, err := fetchFoundationOrganization(ctx, client, org)
+		if err != nil {
+			return err
+		}
+
+		state.Organizations = append(state.Organizations, *orgState)
+	}
+
+	sort.Slice(state.Organizations, func(i, j int) bool {
+		return state.Organizations[i].Name < state.Organizations[j].Name
+	})
+
+	return nil
+}
+
+func fetchFoundationOrganization(ctx context.Context, client Client, org Organization) (*FoundationOrganizationState, error) {
+	state := &FoundationOrganizationState{Organization: org}
+
+	var err error
+
+	state.Spaces, err = CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Space], error) {
+		return client.Spaces().List(ctx, params, WithSpaceOrganizationGUIDs(org.GUID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spaces of organization %s: %w", org.Name, err)
+	}
+
+	state.SpaceQuotas, err = CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[SpaceQuotaV3], error) {
+		return client.SpaceQuotas().List(ctx, params, WithSpaceQuotaOrganizationGUIDs(org.GUID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list space quotas of organization %s: %w", org.Name, err)
+	}
+
+	state.Roles, err = CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Role], error) {
+		return client.Roles().List(ctx, params, WithRoleOrganizationGUIDs(org.GUID))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles in organization %s: %w", org.Name, err)
+	}
+
+	for start := 0; start < len(state.Spaces); start += foundationGUIDBatch {
+		guids := make([]string, 0, foundationGUIDBatch)
+		for _, space := range state.Spaces[start:min(start+foundationGUIDBatch, len(state.Spaces))] {
+			guids = append(guids, space.GUID)
+		}
+
+		roles, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Role], error) {
+			return client.Roles().List(ctx, params, WithRoleSpaceGUIDs(guids...))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list roles in spaces of organization %s: %w", org.Name, err)
+		}
+
+		state.Roles = append(state.Roles, roles...)
+	}
+
+	return state, nil
+}
+
+// foundationGUIDBatch bounds the GUIDs of one filter, keeping URLs short.
+const foundationGUIDBatch = 50
+
+// fetchFoundationUsers reads the users named by the document and those
+// holding the roles read, to show and resolve them by username.
+func fetchFoundationUsers(ctx context.Context, client Client, foundation *Foundation, _ bool, state *FoundationState) error {
+	usernames := foundationUsernames(foundation)
+
+	guids := map[string]bool{}
+
+	for _, org := range state.Organizations {
+		for _, role := range org.Roles {
+			if role.Relationships.User.Data != nil {
+				guids[role.Relationships.User.Data.GUID] = true
+			}
+		}
+	}
+
+	filters := make([]UserListOption, 0, len(usernames)/foundationGUIDBatch+len(guids)/foundationGUIDBatch+2)
+
+	for _, batch := range chunkStrings(usernames, foundationGUIDBatch) {
+		filters = append(filters, WithUserUsernames(batch...))
+	}
+
+	for _, batch := range chunkStrings(sortedKeys(guids), foundationGUIDBatch) {
+		filters = append(filters, WithUserGUIDs(batch...))
+	}
+
+	seen := map[string]bool{}
+
+	for _, filter := range filters {
+		users, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[User], error) {
+			return client.Users().List(ctx, params, filter)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list users: %w", err)
+		}
+
+		for _, user := range users {
+			if !seen[user.GUID] {
+				seen[user.GUID] = true
+
+				state.Users = append(state.Users, user)
+			}
+		}
+	}
+
+	return nil
+}
+
+func foundationUsernames(foundation *Foundation) []string {
+	names := map[string]bool{}
+
+	add := func(roles []foundationRoleList) {
+		for _, role := range roles {
+			for _, username := range role.usernames {
+				names[username] = true
+			}
+		}
+	}
+
+	for _, org := range foundation.Organizations {
+		add(org.Users.roles())
+
+		for _, space := range org.Spaces {
+			add(space.Users.roles())
+		}
+	}
+
+	return sortedKeys(names)
+}
+
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+
+	for start := 0; start < len(values); start += size {
+		chunks = append(chunks, values[start:min(start+size, len(values))])
+	}
+
+	return chunks
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
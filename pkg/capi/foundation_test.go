@@ -0,0 +1,635 @@
+package capi_test
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func foundationUsers() []capi.User {
+	return []capi.User{
+		{Resource: capi.Resource{GUID: "u-alice"}, Username: "alice", Origin: "uaa"},
+		{Resource: capi.Resource{GUID: "u-bob-ldap"}, Username: "bob", Origin: "ldap"},
+		{Resource: capi.Resource{GUID: "u-bob"}, Username: "bob", Origin: "uaa"},
+		{Resource: capi.Resource{GUID: "u-carol"}, Username: "carol", Origin: "uaa"},
+	}
+}
+
+func managedBy(value string) *capi.Metadata {
+	return &capi.Metadata{Labels: map[string]string{capi.FoundationManagedLabel: value}}
+}
+
+func orgRole(guid, roleType, userGUID, orgGUID string) capi.Role {
+	return capi.Role{
+		Resource: capi.Resource{GUID: guid},
+		Type:     roleType,
+		Relationships: capi.RoleRelationships{
+			User:         capi.Relationship{Data: &capi.RelationshipData{GUID: userGUID}},
+			Organization: &capi.Relationship{Data: &capi.RelationshipData{GUID: orgGUID}},
+		},
+	}
+}
+
+func findFoundationChange(t *testing.T, plan *capi.FoundationPlan, resource, name string) capi.FoundationChange {
+	t.Helper()
+
+	for _, change := range plan.Changes {
+		if change.Resource == resource && change.Name == name {
+			return change
+		}
+	}
+
+	require.Failf(t, "change not found", "%s %q", resource, name)
+
+	return capi.FoundationChange{}
+}
+
+func findBatchOperation(t *testing.T, operations []capi.BatchOperation, id string) capi.BatchOperation {
+	t.Helper()
+
+	for _, operation := range operations {
+		if operation.ID == id {
+			return operation
+		}
+	}
+
+	require.Failf(t, "operation not found", "%s", id)
+
+	return capi.BatchOperation{}
+}
+
+func TestParseFoundation(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+feature_flags: {diego_docker: true}
+organizations:
+  - name: payments
+    users: {managers: []}
+    spaces: [{name: prod}]
+`))
+	require.NoError(t, err)
+	assert.Equal(t, capi.DefaultFoundationManagedBy, foundation.ManagedByValue())
+	assert.NotNil(t, foundation.Organizations[0].Users.Managers, "an empty list is kept to remove every manager")
+	assert.Nil(t, foundation.Organizations[0].Users.Auditors)
+
+	tests := []struct {
+		name     string
+		document string
+		contains []string
+	}{
+		{"unknown key", "organisations: []", []string{"organisations"}},
+		{"bad label value", "managed_by: not a label", []string{"managed_by"}},
+		{
+			"duplicate and missing names",
+			"organizations: [{name: a, spaces: [{name: s}, {name: s}]}, {name: a}, {quota: q}]",
+			[]string{`organizations: duplicate name "a"`, "organizations[2]: name is required", `organizations[a].spaces: duplicate name "s"`},
+		},
+		{"empty username", `organizations: [{name: a, spaces: [{name: s, users: {developers: [""]}}]}]`, []string{"organizations[a].spaces[s].users.developers"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := capi.ParseFoundation([]byte(tc.document))
+			require.ErrorIs(t, err, capi.ErrInvalidFoundation)
+
+			for _, text := range tc.contains {
+				assert.Contains(t, err.Error(), text)
+			}
+		})
+	}
+}
+
+func TestPlanFoundation_CreatesFromScratch(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+managed_by: platform
+feature_flags: {diego_docker: true}
+organization_quotas:
+  - name: small
+    apps: {total_memory_in_mb: 1024}
+security_groups:
+  - name: internal
+    rules: [{protocol: tcp, destination: 10.0.0.0/8}]
+organizations:
+  - name: payments
+    quota: small
+    isolation_segments: [secure]
+    users: {managers: [alice]}
+    space_quotas:
+      - name: dev
+        apps: {total_instances: 10}
+    spaces:
+      - name: prod
+        quota: dev
+        users: {developers: [alice, bob]}
+        security_groups: {running: [internal]}
+`))
+	require.NoError(t, err)
+
+	state := &capi.FoundationState{
+		FeatureFlags: map[string]bool{"diego_docker": false},
+		IsolationSegments: []capi.FoundationIsolationSegment{
+			{IsolationSegment: capi.IsolationSegment{Resource: capi.Resource{GUID: "iso-secure"}, Name: "secure"}},
+		},
+		Users: foundationUsers(),
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "Plan: 5 to create, 1 to update, 0 to delete.", plan.Summary())
+
+	org := findFoundationChange(t, plan, "organization", "payments")
+	assert.Equal(t, capi.FoundationActionCreate, org.Action)
+	assert.Equal(t, []capi.FoundationFieldChange{
+		{Field: "quota", New: "small"},
+		{Field: "isolation_segments", New: "secure"},
+		{Field: "users.managers", New: "alice"},
+		{Field: "users.organization_users", New: "bob"},
+	}, org.Fields)
+
+	quota := findFoundationChange(t, plan, "organization_quota", "small")
+	assert.Equal(t, []capi.FoundationFieldChange{{Field: "apps.total_memory_in_mb", New: "1024"}}, quota.Fields)
+
+	operations := plan.Operations()
+
+	create := findBatchOperation(t, operations, "organization:payments")
+	request, ok := create.Data.(*capi.OrganizationCreateRequest)
+	require.True(t, ok)
+	assert.Equal(t, "platform", request.Metadata.Labels[capi.FoundationManagedLabel])
+
+	// Space roles wait for the organization roles making their users members.
+	alice := findBatchOperation(t, operations, "space:payments/prod#space_developer:alice")
+	assert.Equal(t, []string{"organization:payments#organization_manager:alice"}, alice.DependsOn)
+
+	bob := findBatchOperation(t, operations, "space:payments/prod#space_developer:bob")
+	assert.Equal(t, []string{"organization:payments#organization_user:bob"}, bob.DependsOn)
+
+	role, ok := bob.Data.(*capi.RoleCreateRequest)
+	require.True(t, ok)
+	assert.Equal(t, "u-bob", role.Relationships.User.Data.GUID, "the uaa user is preferred")
+
+	steps, err := capi.PlanBatchOperations(operations)
+	require.NoError(t, err)
+
+	for _, step := range steps {
+		if step.ID == "space:payments/prod#security_group:running:internal" {
+			assert.ElementsMatch(t, []string{"security_group:internal", "space:payments/prod"}, step.DependsOn)
+		}
+
+		if step.ID == "space:payments/prod#quota" {
+			assert.ElementsMatch(t, []string{"space_quota:payments/dev", "space:payments/prod"}, step.DependsOn)
+		}
+	}
+}
+
+func TestPlanFoundation_UpdatesAdoptsAndPrunes(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+managed_by: platform
+environment_variable_groups:
+  running: {A: "1", C: "3"}
+organization_quotas:
+  - name: small
+    apps: {total_memory_in_mb: 1024}
+organizations:
+  - name: payments
+    quota: small
+    users: {managers: [alice]}
+    spaces:
+      - name: prod
+`))
+	require.NoError(t, err)
+
+	memory := 512
+	state := &capi.FoundationState{
+		EnvironmentVariableGroups: map[string]map[string]interface{}{"running": {"A": "1", "B": "2"}},
+		OrganizationQuotas: []capi.OrganizationQuota{
+			{Resource: capi.Resource{GUID: "q-small"}, Name: "small", Apps: &capi.OrganizationQuotaApps{TotalMemoryInMB: &memory}, Metadata: managedBy("platform")},
+			{Resource: capi.Resource{GUID: "q-default"}, Name: "default"},
+			{Resource: capi.Resource{GUID: "q-stale"}, Name: "stale", Metadata: managedBy("platform")},
+		},
+		Organizations: []capi.FoundationOrganizationState{
+			{
+				Organization: capi.Organization{
+					Resource: capi.Resource{GUID: "org-payments"},
+					Name:     "payments",
+					Relationships: &capi.OrgRelationships{
+						Quota: capi.Relationship{Data: &capi.RelationshipData{GUID: "q-default"}},
+					},
+				},
+				Spaces: []capi.Space{
+					{Resource: capi.Resource{GUID: "space-prod"}, Name: "prod", Metadata: managedBy("platform")},
+					{Resource: capi.Resource{GUID: "space-old"}, Name: "old", Metadata: managedBy("platform")},
+					{Resource: capi.Resource{GUID: "space-manual"}, Name: "manual"},
+				},
+				SpaceQuotas: []capi.SpaceQuotaV3{
+					{Resource: capi.Resource{GUID: "sq-legacy"}, Name: "legacy", Metadata: managedBy("platform")},
+				},
+				Roles: []capi.Role{
+					orgRole("role-alice", "organization_manager", "u-alice", "org-payments"),
+					orgRole("role-carol", "organization_manager", "u-carol", "org-payments"),
+				},
+			},
+			{Organization: capi.Organization{Resource: capi.Resource{GUID: "org-retired"}, Name: "retired", Metadata: managedBy("platform")}},
+			{Organization: capi.Organization{Resource: capi.Resource{GUID: "org-other"}, Name: "other", Metadata: managedBy("someone-else")}},
+		},
+		Users: foundationUsers(),
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{Prune: true})
+	require.NoError(t, err)
+
+	var summary []string
+	for _, change := range plan.Changes {
+		summary = append(summary, string(change.Action)+" "+change.Resource+" "+change.Name)
+	}
+
+	assert.Equal(t, []string{
+		"update environment_variable_group running",
+		"update organization_quota small",
+		"update organization payments",
+		"delete space payments/old",
+		"delete space_quota payments/legacy",
+		"delete organization retired",
+		"delete organization_quota stale",
+	}, summary)
+
+	assert.Equal(t, []capi.FoundationFieldChange{
+		{Field: "C", New: "3"},
+		{Field: "B", Old: "2"},
+	}, plan.Changes[0].Fields)
+	assert.Equal(t, []capi.FoundationFieldChange{
+		{Field: "labels." + capi.FoundationManagedLabel, New: "platform"},
+		{Field: "quota", Old: "default", New: "small"},
+		{Field: "users.managers", Old: "alice, carol", New: "alice"},
+	}, plan.Changes[2].Fields)
+
+	operations := plan.Operations()
+
+	vars, ok := findBatchOperation(t, operations, "environment_variable_group:running").Data.(*capi.EnvironmentVariableGroupChange)
+	require.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"B": nil, "C": "3"}, vars.Var)
+
+	removal := findBatchOperation(t, operations, "organization:payments#organization_manager:carol")
+	assert.Equal(t, "delete", removal.Type)
+	assert.Equal(t, "role-carol", removal.Data)
+
+	assert.Equal(t, []string{"space:payments/old"}, findBatchOperation(t, operations, "space_quota:payments/legacy").DependsOn)
+	assert.Equal(t, []string{"organization:payments#quota", "organization:retired"},
+		findBatchOperation(t, operations, "organization_quota:stale").DependsOn)
+
+	_, err = capi.PlanBatchOperations(operations)
+	require.NoError(t, err)
+
+	plan, err = capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, plan.Count(capi.FoundationActionDelete), "nothing is deleted without prune")
+}
+
+// graphJobOrgs deletes organizations through a job named after the
+// organization.
+type graphJobOrgs struct {
+	capi.OrganizationsClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphJobOrgs) Delete(_ context.Context, guid string) (*capi.Job, error) {
+	defer s.recorder.enter("delete " + guid)()
+
+	return &capi.Job{Resource: capi.Resource{GUID: "job-" + guid}, State: "PROCESSING"}, nil
+}
+
+type graphOrgQuotas struct {
+	capi.OrganizationQuotasClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphOrgQuotas) Delete(_ context.Context, guid string) (*capi.Job, error) {
+	defer s.recorder.enter("delete " + guid)()
+
+	return nil, nil //nolint:nilnil // deleted without a job
+}
+
+type graphSpaceQuotas struct {
+	capi.SpaceQuotasClient
+
+	recorder *graphRecorder
+}
+
+func (s *graphSpaceQuotas) Delete(_ context.Context, guid string) (*capi.Job, error) {
+	defer s.recorder.enter("delete " + guid)()
+
+	return nil, nil //nolint:nilnil // deleted without a job
+}
+
+func TestFoundationPlan_PrunesQuotasAfterDeleteJobs(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+managed_by: platform
+organizations:
+  - name: payments
+`))
+	require.NoError(t, err)
+
+	state := &capi.FoundationState{
+		OrganizationQuotas: []capi.OrganizationQuota{
+			{Resource: capi.Resource{GUID: "q-stale"}, Name: "stale", Metadata: managedBy("platform")},
+		},
+		Organizations: []capi.FoundationOrganizationState{
+			{
+				Organization: capi.Organization{Resource: capi.Resource{GUID: "org-payments"}, Name: "payments", Metadata: managedBy("platform")},
+				Spaces: []capi.Space{
+					{Resource: capi.Resource{GUID: "space-old"}, Name: "old", Metadata: managedBy("platform")},
+				},
+				SpaceQuotas: []capi.SpaceQuotaV3{
+					{Resource: capi.Resource{GUID: "sq-legacy"}, Name: "legacy", Metadata: managedBy("platform")},
+				},
+			},
+			{Organization: capi.Organization{Resource: capi.Resource{GUID: "org-retired"}, Name: "retired", Metadata: managedBy("platform")}},
+		},
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{Prune: true})
+	require.NoError(t, err)
+
+	recorder := &graphRecorder{}
+	client := &stubClient{
+		organizations: &graphJobOrgs{recorder: recorder},
+		spaces:        &graphJobSpaces{recorder: recorder},
+		orgQuotas:     &graphOrgQuotas{recorder: recorder},
+		spaceQuotas:   &graphSpaceQuotas{recorder: recorder},
+		jobs:          &graphJobs{recorder: recorder},
+	}
+
+	results, err := capi.NewBatchExecutor(client, 5).Execute(context.Background(), plan.Operations())
+	require.NoError(t, err)
+
+	for _, result := range results {
+		require.True(t, result.Success, "%s: %v", result.ID, result.Error)
+	}
+
+	// The quotas are deleted only once the jobs deleting the organization and
+	// space using them completed, not when the deletes were accepted.
+	require.Contains(t, recorder.calls, "complete job-org-retired")
+	assert.Less(t, slices.Index(recorder.calls, "complete job-org-retired"), slices.Index(recorder.calls, "delete q-stale"))
+	require.Contains(t, recorder.calls, "complete job-space-old")
+	assert.Less(t, slices.Index(recorder.calls, "complete job-space-old"), slices.Index(recorder.calls, "delete sq-legacy"))
+}
+
+func TestPlanFoundation_ReportsUnresolvedReferences(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+feature_flags: {no_such_flag: true}
+organizations:
+  - name: payments
+    quota: huge
+    isolation_segments: [nope]
+    users: {managers: [mallory]}
+    spaces:
+      - name: prod
+        quota: tiny
+        security_groups: {staging: [missing]}
+`))
+	require.NoError(t, err)
+
+	_, err = capi.PlanFoundation(foundation, &capi.FoundationState{FeatureFlags: map[string]bool{}}, capi.FoundationPlanOptions{})
+	require.ErrorIs(t, err, capi.ErrFoundationUnresolved)
+
+	for _, text := range []string{
+		`feature_flags: feature flag "no_such_flag"`,
+		`organizations[payments].quota: organization quota "huge"`,
+		`organizations[payments].isolation_segments: isolation segment "nope"`,
+		`organizations[payments].users.managers: user "mallory"`,
+		`spaces[payments/prod].quota: space quota "tiny"`,
+		`spaces[payments/prod].security_groups.staging: security group "missing"`,
+	} {
+		assert.Contains(t, err.Error(), text)
+	}
+}
+
+func TestFoundationPlan_EnvironmentVariableGroupsCompareTypedValues(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+environment_variable_groups:
+  running: {PORT: "8080", LIMIT: "1000000", DEBUG: "true", CONFIG: '{"level":"info"}'}
+`))
+	require.NoError(t, err)
+
+	state := &capi.FoundationState{
+		EnvironmentVariableGroups: map[string]map[string]interface{}{"running": {
+			"PORT":   float64(8080),
+			"LIMIT":  float64(1000000),
+			"DEBUG":  true,
+			"CONFIG": map[string]interface{}{"level": "info"},
+		}},
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{})
+	require.NoError(t, err)
+
+	assert.Empty(t, plan.Changes)
+	assert.False(t, plan.HasChanges())
+}
+
+func TestFoundationPlan_WriteText(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+feature_flags: {diego_docker: true}
+environment_variable_groups: {staging: {A: "2", C: "3"}}
+`))
+	require.NoError(t, err)
+
+	state := &capi.FoundationState{
+		FeatureFlags:              map[string]bool{"diego_docker": false},
+		EnvironmentVariableGroups: map[string]map[string]interface{}{"staging": {"A": "1", "B": "2"}},
+	}
+
+	plan, err := capi.PlanFoundation(foundation, state, capi.FoundationPlanOptions{})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, plan.WriteText(&out, false))
+
+	assert.Equal(t, `  ~ feature_flag "diego_docker"
+      ~ enabled: false -> true
+  ~ environment_variable_group "staging"
+      ~ A: 1 -> 2
+      + C = 3
+      - B = 2
+
+Plan: 0 to create, 2 to update, 0 to delete.
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, plan.WriteText(&out, true))
+	assert.Contains(t, out.String(), "\x1b[")
+
+	out.Reset()
+	require.NoError(t, (&capi.FoundationPlan{}).WriteText(&out, false))
+	assert.Equal(t, "No changes. The foundation matches the document.\n", out.String())
+}
+
+// foundationOrganizations serves payments by name and retired by label
+// selector, recording each query.
+type foundationOrganizations struct {
+	capi.OrganizationsClient
+
+	queries []string
+}
+
+func (s *foundationOrganizations) List(_ context.Context, params *capi.QueryParams, opts ...capi.OrganizationListOption) (*capi.ListResponse[capi.Organization], error) {
+	query := capi.ApplyQueryOptions(params.ToValues(), opts)
+	s.queries = append(s.queries, query.Encode())
+
+	org := capi.Organization{Resource: capi.Resource{GUID: "org-payments"}, Name: "payments"}
+	if query.Get("label_selector") != "" {
+		org = capi.Organization{Resource: capi.Resource{GUID: "org-retired"}, Name: "retired", Metadata: managedBy("platform")}
+	}
+
+	return &capi.ListResponse[capi.Organization]{Resources: []capi.Organization{org}}, nil
+}
+
+type foundationSpaces struct {
+	capi.SpacesClient
+}
+
+func (s *foundationSpaces) List(_ context.Context, params *capi.QueryParams, opts ...capi.SpaceListOption) (*capi.ListResponse[capi.Space], error) {
+	var spaces []capi.Space
+	if capi.ApplyQueryOptions(params.ToValues(), opts).Get("organization_guids") == "org-payments" {
+		spaces = append(spaces, capi.Space{Resource: capi.Resource{GUID: "space-prod"}, Name: "prod"})
+	}
+
+	return &capi.ListResponse[capi.Space]{Resources: spaces}, nil
+}
+
+type foundationSpaceQuotas struct {
+	capi.SpaceQuotasClient
+}
+
+func (s *foundationSpaceQuotas) List(_ context.Context, _ *capi.QueryParams, _ ...capi.SpaceQuotaListOption) (*capi.ListResponse[capi.SpaceQuotaV3], error) {
+	return &capi.ListResponse[capi.SpaceQuotaV3]{}, nil
+}
+
+type foundationOrganizationQuotas struct {
+	capi.OrganizationQuotasClient
+}
+
+func (s *foundationOrganizationQuotas) List(_ context.Context, _ *capi.QueryParams, _ ...capi.OrganizationQuotaListOption) (*capi.ListResponse[capi.OrganizationQuota], error) {
+	return &capi.ListResponse[capi.OrganizationQuota]{}, nil
+}
+
+// foundationRoles gives alice a role in payments and carol one in its prod
+// space.
+type foundationRoles struct {
+	capi.RolesClient
+}
+
+func (s *foundationRoles) List(_ context.Context, params *capi.QueryParams, opts ...capi.RoleListOption) (*capi.ListResponse[capi.Role], error) {
+	query := capi.ApplyQueryOptions(params.ToValues(), opts)
+
+	var roles []capi.Role
+
+	switch {
+	case query.Get("organization_guids") == "org-payments":
+		roles = append(roles, orgRole("role-alice", "organization_manager", "u-alice", "org-payments"))
+	case query.Get("space_guids") == "space-prod":
+		roles = append(roles, capi.Role{
+			Resource: capi.Resource{GUID: "role-carol"},
+			Type:     "space_developer",
+			Relationships: capi.RoleRelationships{
+				User:  capi.Relationship{Data: &capi.RelationshipData{GUID: "u-carol"}},
+				Space: &capi.Relationship{Data: &capi.RelationshipData{GUID: "space-prod"}},
+			},
+		})
+	}
+
+	return &capi.ListResponse[capi.Role]{Resources: roles}, nil
+}
+
+// foundationUsersClient serves foundationUsers filtered by username or GUID.
+type foundationUsersClient struct {
+	capi.UsersClient
+}
+
+func (s *foundationUsersClient) List(_ context.Context, params *capi.QueryParams, opts ...capi.UserListOption) (*capi.ListResponse[capi.User], error) {
+	query := capi.ApplyQueryOptions(params.ToValues(), opts)
+
+	var users []capi.User
+
+	for _, user := range foundationUsers() {
+		if containsQueryValue(query, "usernames", user.Username) || containsQueryValue(query, "guids", user.GUID) {
+			users = append(users, user)
+		}
+	}
+
+	return &capi.ListResponse[capi.User]{Resources: users}, nil
+}
+
+func containsQueryValue(query url.Values, key, value string) bool {
+	for _, candidate := range strings.Split(query.Get(key), ",") {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestFetchFoundationState_ReadsDeclaredAndManagedOrganizations(t *testing.T) {
+	t.Parallel()
+
+	foundation, err := capi.ParseFoundation([]byte(`
+managed_by: platform
+organizations:
+  - name: payments
+    users: {managers: [alice]}
+`))
+	require.NoError(t, err)
+
+	orgs := &foundationOrganizations{}
+	client := &stubClient{
+		organizations: orgs,
+		spaces:        &foundationSpaces{},
+		spaceQuotas:   &foundationSpaceQuotas{},
+		orgQuotas:     &foundationOrganizationQuotas{},
+		roles:         &foundationRoles{},
+		users:         &foundationUsersClient{},
+	}
+
+	state, err := capi.FetchFoundationState(context.Background(), client, foundation, true)
+	require.NoError(t, err)
+
+	require.Len(t, state.Organizations, 2)
+	assert.Equal(t, "payments", state.Organizations[0].Name)
+	assert.Equal(t, "retired", state.Organizations[1].Name)
+	assert.Len(t, state.Organizations[0].Roles, 2)
+	assert.Equal(t, "prod", state.Organizations[0].Spaces[0].Name)
+
+	assert.Contains(t, orgs.queries[0], "names=payments")
+	assert.Contains(t, orgs.queries[1], "label_selector=capi.fivetwenty.io%2Fmanaged-by%3Dplatform")
+
+	var usernames []string
+	for _, user := range state.Users {
+		usernames = append(usernames, user.Username)
+	}
+
+	assert.ElementsMatch(t, []string{"alice", "carol"}, usernames)
+}
@@ -0,0 +1,386 @@
+package capi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidLabelSelector = errors.New("invalid label selector")
+	ErrInvalidLabelKey      = errors.New("invalid label key")
+	ErrInvalidLabelValue    = errors.New("invalid label value")
+	ErrReservedLabelPrefix  = errors.New("label prefix is reserved")
+)
+
+// Label syntax limits enforced by Cloud Controller.
+const (
+	maxLabelNameLength   = 63
+	maxLabelPrefixLength = 253
+	reservedLabelPrefix  = "cloudfoundry.org"
+)
+
+var (
+	// labelNamePattern matches label names and non-empty values: alphanumeric
+	// at both ends, with '-', '_' and '.' allowed in between.
+	labelNamePattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	// labelPrefixPattern matches DNS subdomains.
+	labelPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?(\.[A-Za-z0-9]([-A-Za-z0-9]*[A-Za-z0-9])?)*$`)
+	// labelSetPattern matches the "key in (a,b)" and "key notin (a,b)" forms.
+	labelSetPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// LabelSelectorOperator is the comparison of one LabelRequirement.
+type LabelSelectorOperator string
+
+// Label selector operators.
+const (
+	LabelSelectorEquals    LabelSelectorOperator = "="
+	LabelSelectorNotEquals LabelSelectorOperator = "!="
+	LabelSelectorIn        LabelSelectorOperator = "in"
+	LabelSelectorNotIn     LabelSelectorOperator = "notin"
+	LabelSelectorExists    LabelSelectorOperator = "exists"
+	LabelSelectorNotExists LabelSelectorOperator = "!exists"
+)
+
+// LabelRequirement is one comma-separated term of a label selector.
+type LabelRequirement struct {
+	Key      string
+	Operator LabelSelectorOperator
+	// Values holds the value of = and !=, and the set of in and notin.
+	Values []string
+}
+
+// String formats the requirement in Cloud Controller's grammar.
+func (r LabelRequirement) String() string {
+	switch r.Operator {
+	case LabelSelectorEquals, LabelSelectorNotEquals:
+		return r.Key + string(r.Operator) + strings.Join(r.Values, "")
+	case LabelSelectorIn, LabelSelectorNotIn:
+		return r.Key + " " + string(r.Operator) + " (" + strings.Join(r.Values, ",") + ")"
+	case LabelSelectorNotExists:
+		return "!" + r.Key
+	case LabelSelectorExists:
+		return r.Key
+	default:
+		return r.Key
+	}
+}
+
+// Matches reports whether labels satisfy the requirement. As on the server,
+// != and notin also match resources without the key.
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case LabelSelectorEquals:
+		return ok && len(r.Values) == 1 && value == r.Values[0]
+	case LabelSelectorNotEquals:
+		return !ok || len(r.Values) != 1 || value != r.Values[0]
+	case LabelSelectorIn:
+		return ok && slices.Contains(r.Values, value)
+	case LabelSelectorNotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case LabelSelectorExists:
+		return ok
+	case LabelSelectorNotExists:
+		return !ok
+	default:
+		return false
+	}
+}
+
+// Validate checks the key, the values and their number for the operator.
+func (r LabelRequirement) Validate() error {
+	err := ValidateLabelKey(r.Key)
+	if err != nil {
+		return err
+	}
+
+	switch r.Operator {
+	case LabelSelectorEquals, LabelSelectorNotEquals:
+		if len(r.Values) != 1 {
+			return fmt.Errorf("%w: %s needs exactly one value", ErrInvalidLabelSelector, r.Operator)
+		}
+	case LabelSelectorIn, LabelSelectorNotIn:
+		if len(r.Values) == 0 {
+			return fmt.Errorf("%w: %s needs at least one value", ErrInvalidLabelSelector, r.Operator)
+		}
+	case LabelSelectorExists, LabelSelectorNotExists:
+		if len(r.Values) != 0 {
+			return fmt.Errorf("%w: %s takes no value", ErrInvalidLabelSelector, r.Operator)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operator %q", ErrInvalidLabelSelector, r.Operator)
+	}
+
+	for _, value := range r.Values {
+		err := ValidateLabelValue(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LabelSelector is a typed label selector: every requirement must match.
+// Build one with NewLabelSelector or parse one with ParseLabelSelector, and
+// pass it to a list with QueryParams.WithSelector.
+//
+//	selector := capi.NewLabelSelector().
+//		Equals("team", "payments").
+//		In("env", "staging", "prod").
+//		NotExists("deprecated")
+//	// team=payments,env in (staging,prod),!deprecated
+type LabelSelector struct {
+	Requirements []LabelRequirement
+}
+
+// NewLabelSelector creates an empty selector, which matches everything.
+func NewLabelSelector() *LabelSelector {
+	return &LabelSelector{}
+}
+
+func (s *LabelSelector) add(key string, operator LabelSelectorOperator, values ...string) *LabelSelector {
+	s.Requirements = append(s.Requirements, LabelRequirement{Key: key, Operator: operator, Values: values})
+
+	return s
+}
+
+// Equals requires the label key to have value.
+func (s *LabelSelector) Equals(key, value string) *LabelSelector {
+	return s.add(key, LabelSelectorEquals, value)
+}
+
+// NotEquals requires the label key to be absent or to have another value.
+func (s *LabelSelector) NotEquals(key, value string) *LabelSelector {
+	return s.add(key, LabelSelectorNotEquals, value)
+}
+
+// In requires the label key to have one of values.
+func (s *LabelSelector) In(key string, values ...string) *LabelSelector {
+	return s.add(key, LabelSelectorIn, values...)
+}
+
+// NotIn requires the label key to be absent or to have none of values.
+func (s *LabelSelector) NotIn(key string, values ...string) *LabelSelector {
+	return s.add(key, LabelSelectorNotIn, values...)
+}
+
+// Exists requires the label key to be set.
+func (s *LabelSelector) Exists(key string) *LabelSelector {
+	return s.add(key, LabelSelectorExists)
+}
+
+// NotExists requires the label key to be absent.
+func (s *LabelSelector) NotExists(key string) *LabelSelector {
+	return s.add(key, LabelSelectorNotExists)
+}
+
+// IsEmpty reports whether the selector has no requirements.
+func (s *LabelSelector) IsEmpty() bool {
+	return s == nil || len(s.Requirements) == 0
+}
+
+// String formats the selector as the label_selector query parameter.
+func (s *LabelSelector) String() string {
+	if s == nil {
+		return ""
+	}
+
+	terms := make([]string, len(s.Requirements))
+	for i, requirement := range s.Requirements {
+		terms[i] = requirement.String()
+	}
+
+	return strings.Join(terms, ",")
+}
+
+// Validate checks every requirement.
+func (s *LabelSelector) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	for _, requirement := range s.Requirements {
+		err := requirement.Validate()
+		if err != nil {
+			return fmt.Errorf("%s: %w", requirement, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches evaluates the selector against the labels of metadata the way
+// Cloud Controller does. A nil selector or metadata has no requirements or
+// labels respectively.
+func (s *LabelSelector) Matches(metadata *Metadata) bool {
+	if s == nil {
+		return true
+	}
+
+	var labels map[string]string
+	if metadata != nil {
+		labels = metadata.Labels
+	}
+
+	for _, requirement := range s.Requirements {
+		if !requirement.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseLabelSelector parses and validates a selector in Cloud Controller's
+// grammar: comma-separated requirements of the forms key=value (or
+// key==value), key!=value, key in (a,b), key notin (a,b), key and !key. An
+// empty string yields an empty selector.
+func ParseLabelSelector(selector string) (*LabelSelector, error) {
+	parsed := NewLabelSelector()
+
+	terms, err := splitLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range terms {
+		requirement, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed.Requirements = append(parsed.Requirements, requirement)
+	}
+
+	err = parsed.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// splitLabelSelector splits a selector on the commas outside parentheses.
+func splitLabelSelector(selector string) ([]string, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	var (
+		terms []string
+		depth int
+		start int
+	)
+
+	for i, char := range selector {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, strings.TrimSpace(selector[start:i]))
+				start = i + 1
+			}
+		}
+
+		if depth < 0 || depth > 1 {
+			return nil, fmt.Errorf("%w: unbalanced parentheses in %q", ErrInvalidLabelSelector, selector)
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced parentheses in %q", ErrInvalidLabelSelector, selector)
+	}
+
+	return append(terms, strings.TrimSpace(selector[start:])), nil
+}
+
+func parseLabelRequirement(term string) (LabelRequirement, error) {
+	if term == "" {
+		return LabelRequirement{}, fmt.Errorf("%w: empty requirement", ErrInvalidLabelSelector)
+	}
+
+	if match := labelSetPattern.FindStringSubmatch(term); match != nil {
+		var values []string
+
+		if strings.TrimSpace(match[3]) != "" {
+			for _, value := range strings.Split(match[3], ",") {
+				value = strings.TrimSpace(value)
+				if value == "" {
+					return LabelRequirement{}, fmt.Errorf("%w: empty value in %q", ErrInvalidLabelSelector, term)
+				}
+
+				values = append(values, value)
+			}
+		}
+
+		return LabelRequirement{Key: match[1], Operator: LabelSelectorOperator(match[2]), Values: values}, nil
+	}
+
+	if key, value, ok := strings.Cut(term, "!="); ok {
+		return LabelRequirement{Key: strings.TrimSpace(key), Operator: LabelSelectorNotEquals, Values: []string{strings.TrimSpace(value)}}, nil
+	}
+
+	if key, value, ok := strings.Cut(term, "="); ok {
+		value = strings.TrimPrefix(value, "=")
+
+		return LabelRequirement{Key: strings.TrimSpace(key), Operator: LabelSelectorEquals, Values: []string{strings.TrimSpace(value)}}, nil
+	}
+
+	if strings.HasPrefix(term, "!") {
+		return LabelRequirement{Key: strings.TrimSpace(term[1:]), Operator: LabelSelectorNotExists}, nil
+	}
+
+	return LabelRequirement{Key: term, Operator: LabelSelectorExists}, nil
+}
+
+// ValidateLabelKey checks a label key: an optional DNS subdomain prefix of
+// at most 253 characters followed by '/', and a name of 1 to 63 characters
+// that starts and ends alphanumeric and may contain '-', '_' and '.'. The
+// cloudfoundry.org prefix is reserved.
+func ValidateLabelKey(key string) error {
+	prefix, name, hasPrefix := strings.Cut(key, "/")
+	if !hasPrefix {
+		prefix, name = "", key
+	}
+
+	if hasPrefix {
+		switch {
+		case prefix == "" || len(prefix) > maxLabelPrefixLength || !labelPrefixPattern.MatchString(prefix):
+			return fmt.Errorf("%w: %q: prefix must be a DNS subdomain of at most %d characters", ErrInvalidLabelKey, key, maxLabelPrefixLength)
+		case strings.EqualFold(prefix, reservedLabelPrefix) || strings.HasSuffix(strings.ToLower(prefix), "."+reservedLabelPrefix):
+			return fmt.Errorf("%w: %q", ErrReservedLabelPrefix, key)
+		}
+	}
+
+	if name == "" || len(name) > maxLabelNameLength || !labelNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: %q: name must be 1-%d alphanumeric characters, '-', '_' or '.', starting and ending alphanumeric",
+			ErrInvalidLabelKey, key, maxLabelNameLength)
+	}
+
+	return nil
+}
+
+// ValidateLabelValue checks a label value: empty, or at most 63 characters
+// that start and end alphanumeric and may contain '-', '_' and '.'.
+func ValidateLabelValue(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(value) > maxLabelNameLength || !labelNamePattern.MatchString(value) {
+		return fmt.Errorf("%w: %q: must be at most %d alphanumeric characters, '-', '_' or '.', starting and ending alphanumeric",
+			ErrInvalidLabelValue, value, maxLabelNameLength)
+	}
+
+	return nil
+}
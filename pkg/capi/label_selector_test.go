@@ -0,0 +1,104 @@
+package capi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelSelector_BuildAndParse(t *testing.T) {
+	t.Parallel()
+
+	selector := capi.NewLabelSelector().
+		Equals("team", "payments").
+		NotEquals("example.com/tier", "frontend").
+		In("env", "staging", "prod").
+		NotIn("region", "eu").
+		Exists("owner").
+		NotExists("deprecated")
+	require.NoError(t, selector.Validate())
+
+	formatted := selector.String()
+	assert.Equal(t, "team=payments,example.com/tier!=frontend,env in (staging,prod),region notin (eu),owner,!deprecated", formatted)
+	assert.Equal(t, formatted, capi.NewQueryParams().WithSelector(selector).ToValues().Get("label_selector"))
+
+	parsed, err := capi.ParseLabelSelector(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, selector, parsed)
+
+	parsed, err = capi.ParseLabelSelector(" team == payments , env in ( staging , prod ) ")
+	require.NoError(t, err)
+	assert.Equal(t, "team=payments,env in (staging,prod)", parsed.String())
+
+	parsed, err = capi.ParseLabelSelector("")
+	require.NoError(t, err)
+	assert.True(t, parsed.IsEmpty())
+}
+
+func TestLabelSelector_Matches(t *testing.T) {
+	t.Parallel()
+
+	metadata := &capi.Metadata{Labels: map[string]string{"team": "payments", "env": "prod", "empty": ""}}
+
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"team=payments", true},
+		{"team=storefront", false},
+		{"team!=storefront", true},
+		{"missing!=x", true},
+		{"env in (staging,prod)", true},
+		{"env notin (staging,prod)", false},
+		{"missing notin (a)", true},
+		{"missing in (a)", false},
+		{"empty=", true},
+		{"team", true},
+		{"!team", false},
+		{"!missing", true},
+		{"team=payments,!env", false},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		selector, err := capi.ParseLabelSelector(tt.selector)
+		require.NoError(t, err, tt.selector)
+		assert.Equal(t, tt.want, selector.Matches(metadata), tt.selector)
+	}
+
+	selector, err := capi.ParseLabelSelector("!team")
+	require.NoError(t, err)
+	assert.True(t, selector.Matches(nil))
+}
+
+func TestLabelSelector_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		selector string
+		want     error
+	}{
+		{"team=pay ments", capi.ErrInvalidLabelValue},
+		{"team=" + strings.Repeat("a", 64), capi.ErrInvalidLabelValue},
+		{"-team=a", capi.ErrInvalidLabelKey},
+		{"team_=a", capi.ErrInvalidLabelKey},
+		{"/team=a", capi.ErrInvalidLabelKey},
+		{"bad_prefix.com/team=a", capi.ErrInvalidLabelKey},
+		{"cloudfoundry.org/team=a", capi.ErrReservedLabelPrefix},
+		{"env in ()", capi.ErrInvalidLabelSelector},
+		{"env in (a,,b)", capi.ErrInvalidLabelSelector},
+		{"env in (a", capi.ErrInvalidLabelSelector},
+		{"team=a,,env=b", capi.ErrInvalidLabelSelector},
+	}
+
+	for _, tt := range tests {
+		_, err := capi.ParseLabelSelector(tt.selector)
+		require.ErrorIs(t, err, tt.want, tt.selector)
+	}
+
+	require.ErrorIs(t, capi.NewLabelSelector().In("env").Validate(), capi.ErrInvalidLabelSelector)
+	require.NoError(t, capi.ValidateLabelKey("sub.example.com/team-name_1.x"))
+}
@@ -0,0 +1,203 @@
+package capi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrPromQLQueryFailed       = errors.New("PromQL query failed")
+	ErrPromQLUnknownResultType = errors.New("unknown PromQL result type")
+	ErrPromQLInvalidPoint      = errors.New("invalid PromQL point")
+	ErrLogStreamUnavailable    = errors.New("log stream gateway is not advertised by the API")
+)
+
+const promQLStatusSuccess = "success"
+
+// Type reports which variant the envelope carries, or "" if none.
+func (e *LogCacheEnvelope) Type() LogCacheEnvelopeType {
+	switch {
+	case e.Log != nil:
+		return LogCacheEnvelopeTypeLog
+	case e.Counter != nil:
+		return LogCacheEnvelopeTypeCounter
+	case e.Gauge != nil:
+		return LogCacheEnvelopeTypeGauge
+	case e.Timer != nil:
+		return LogCacheEnvelopeTypeTimer
+	case e.Event != nil:
+		return LogCacheEnvelopeTypeEvent
+	default:
+		return ""
+	}
+}
+
+// Time parses the envelope's nanosecond timestamp. It returns the zero time
+// when the timestamp is missing or malformed.
+func (e *LogCacheEnvelope) Time() time.Time {
+	nanos, err := strconv.ParseInt(e.Timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanos)
+}
+
+// Duration returns Stop - Start.
+func (t *LogCacheTimerEnvelope) Duration() time.Duration {
+	return time.Duration(t.Stop - t.Start)
+}
+
+// LogMessageFromEnvelope converts an envelope carrying a log into a
+// LogMessage. It returns false for other envelope types.
+func LogMessageFromEnvelope(envelope *LogCacheEnvelope) (LogMessage, bool) {
+	if envelope.Log == nil {
+		return LogMessage{}, false
+	}
+
+	sourceType := "APP"
+	if tag, ok := envelope.Tags["source_type"]; ok {
+		sourceType = tag
+	}
+
+	return LogMessage{
+		Message:     string(envelope.Log.Payload),
+		MessageType: envelope.Log.Type,
+		Timestamp:   envelope.Time(),
+		AppID:       envelope.SourceID,
+		SourceType:  sourceType,
+		SourceID:    envelope.InstanceID,
+	}, true
+}
+
+// UnmarshalJSON decodes the Prometheus [unix_seconds, "value"] pair.
+func (p *PromQLPoint) UnmarshalJSON(data []byte) error {
+	var pair []json.RawMessage
+
+	err := json.Unmarshal(data, &pair)
+	if err != nil || len(pair) != 2 { //nolint:mnd // [timestamp, value]
+		return fmt.Errorf("%w: %s", ErrPromQLInvalidPoint, data)
+	}
+
+	var seconds float64
+
+	err = json.Unmarshal(pair[0], &seconds)
+	if err != nil {
+		return fmt.Errorf("%w: timestamp %s", ErrPromQLInvalidPoint, pair[0])
+	}
+
+	var text string
+
+	err = json.Unmarshal(pair[1], &text)
+	if err != nil {
+		return fmt.Errorf("%w: value %s", ErrPromQLInvalidPoint, pair[1])
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("%w: value %q", ErrPromQLInvalidPoint, text)
+	}
+
+	whole, fraction := math.Modf(seconds)
+	p.Time = time.Unix(int64(whole), int64(math.Round(fraction*float64(time.Second)))).UTC()
+	p.Value = value
+
+	return nil
+}
+
+// MarshalJSON encodes the point as {"time", "value"}. Values JSON numbers
+// cannot hold are written as the strings "NaN", "+Inf" and "-Inf" of the
+// Prometheus wire format.
+func (p PromQLPoint) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.output())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode PromQL point: %w", err)
+	}
+
+	return data, nil
+}
+
+// MarshalYAML encodes the point like MarshalJSON.
+func (p PromQLPoint) MarshalYAML() (interface{}, error) {
+	return p.output(), nil
+}
+
+// promQLPointOutput is the encoded form of a PromQLPoint.
+type promQLPointOutput struct {
+	Time  time.Time   `json:"time"  yaml:"time"`
+	Value interface{} `json:"value" yaml:"value"`
+}
+
+func (p PromQLPoint) output() promQLPointOutput {
+	if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+		return promQLPointOutput{Time: p.Time, Value: strconv.FormatFloat(p.Value, 'f', -1, 64)}
+	}
+
+	return promQLPointOutput{Time: p.Time, Value: p.Value}
+}
+
+// ParsePromQLResponse decodes a Prometheus-style query response body as
+// returned by Log Cache's /api/v1/query and /api/v1/query_range.
+func ParsePromQLResponse(body []byte) (*PromQLResult, error) {
+	var response struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, fmt.Errorf("parsing PromQL response: %w", err)
+	}
+
+	if response.Status != promQLStatusSuccess {
+		return nil, fmt.Errorf("%w: %s: %s", ErrPromQLQueryFailed, response.ErrorType, response.Error)
+	}
+
+	result := &PromQLResult{ResultType: response.Data.ResultType}
+
+	switch response.Data.ResultType {
+	case PromQLResultTypeVector:
+		var samples []struct {
+			Metric map[string]string `json:"metric"`
+			Value  PromQLPoint       `json:"value"`
+		}
+
+		err = json.Unmarshal(response.Data.Result, &samples)
+
+		for _, sample := range samples {
+			result.Vector = append(result.Vector, PromQLSample{Metric: sample.Metric, Point: sample.Value})
+		}
+	case PromQLResultTypeMatrix:
+		var series []struct {
+			Metric map[string]string `json:"metric"`
+			Values []PromQLPoint     `json:"values"`
+		}
+
+		err = json.Unmarshal(response.Data.Result, &series)
+
+		for _, s := range series {
+			result.Matrix = append(result.Matrix, PromQLSeries{Metric: s.Metric, Points: s.Values})
+		}
+	case PromQLResultTypeScalar:
+		result.Scalar = &PromQLPoint{}
+		err = json.Unmarshal(response.Data.Result, result.Scalar)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrPromQLUnknownResultType, response.Data.ResultType)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("parsing PromQL %s result: %w", response.Data.ResultType, err)
+	}
+
+	return result, nil
+}
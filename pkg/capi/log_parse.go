@@ -0,0 +1,320 @@
+package capi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrNotAccessLog        = errors.New("not a gorouter access log line")
+	ErrInvalidStatusFilter = errors.New("invalid status filter (expected e.g. 404, 5xx or 4xx,503)")
+)
+
+// LogFormat is the structure ParseLogMessage recognized in a message.
+type LogFormat string
+
+// Recognized log formats.
+const (
+	LogFormatText   LogFormat = "text"
+	LogFormatJSON   LogFormat = "json"
+	LogFormatAccess LogFormat = "access"
+)
+
+const (
+	httpStatusClassDivisor = 100
+	// serverErrorClass is the status class counted as errors in summaries.
+	serverErrorClass = 5
+)
+
+var (
+	// accessLogPattern matches the fixed prefix of a gorouter access log line:
+	// host, timestamp, request line, status, bytes received and sent,
+	// referer, user agent, remote and backend address.
+	accessLogPattern = regexp.MustCompile(`^(\S+) - \[([^\]]+)\] "(\S+) (\S+) ([^"]+)" (\d{3}) (\d+) (\d+) "([^"]*)" "([^"]*)" "([^"]*)" "([^"]*)"(.*)$`)
+	// accessLogFieldPattern matches the key:value and key:"value" pairs that
+	// follow the prefix.
+	accessLogFieldPattern = regexp.MustCompile(`(\w+):("(?:[^"\\]|\\.)*"|\S+)`)
+)
+
+// AccessLogRecord is a decoded gorouter (RTR) access log line.
+type AccessLogRecord struct {
+	Host            string            `json:"host"                        yaml:"host"`
+	Timestamp       time.Time         `json:"timestamp"                   yaml:"timestamp"`
+	Method          string            `json:"method"                      yaml:"method"`
+	Path            string            `json:"path"                        yaml:"path"`
+	Protocol        string            `json:"protocol"                    yaml:"protocol"`
+	Status          int               `json:"status"                      yaml:"status"`
+	BytesReceived   int64             `json:"bytes_received"              yaml:"bytes_received"`
+	BytesSent       int64             `json:"bytes_sent"                  yaml:"bytes_sent"`
+	Referer         string            `json:"referer,omitempty"           yaml:"referer,omitempty"`
+	UserAgent       string            `json:"user_agent,omitempty"        yaml:"user_agent,omitempty"`
+	RemoteAddr      string            `json:"remote_addr,omitempty"       yaml:"remote_addr,omitempty"`
+	BackendAddr     string            `json:"backend_addr,omitempty"      yaml:"backend_addr,omitempty"`
+	XForwardedFor   []string          `json:"x_forwarded_for,omitempty"   yaml:"x_forwarded_for,omitempty"`
+	XForwardedProto string            `json:"x_forwarded_proto,omitempty" yaml:"x_forwarded_proto,omitempty"`
+	VcapRequestID   string            `json:"vcap_request_id,omitempty"   yaml:"vcap_request_id,omitempty"`
+	ResponseTime    time.Duration     `json:"response_time"               yaml:"response_time"`
+	AppID           string            `json:"app_id,omitempty"            yaml:"app_id,omitempty"`
+	AppIndex        int               `json:"app_index"                   yaml:"app_index"`
+	Fields          map[string]string `json:"fields,omitempty"            yaml:"fields,omitempty"`
+}
+
+// ParsedLogMessage is a LogMessage with its structure decoded.
+type ParsedLogMessage struct {
+	LogMessage `yaml:",inline"`
+
+	Format LogFormat `json:"format" yaml:"format"`
+	// Fields holds the top-level fields of a JSON application log.
+	Fields map[string]interface{} `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Access is set for gorouter access log lines.
+	Access *AccessLogRecord `json:"access,omitempty" yaml:"access,omitempty"`
+}
+
+// ParseLogMessage detects JSON application logs and gorouter access logs.
+// Anything else is returned as LogFormatText.
+func ParseLogMessage(message LogMessage) ParsedLogMessage {
+	parsed := ParsedLogMessage{LogMessage: message, Format: LogFormatText}
+
+	if strings.HasPrefix(strings.ToUpper(message.SourceType), "RTR") {
+		record, err := ParseAccessLog(message.Message)
+		if err == nil {
+			parsed.Format = LogFormatAccess
+			parsed.Access = record
+
+			return parsed
+		}
+	}
+
+	text := strings.TrimSpace(message.Message)
+	if !strings.HasPrefix(text, "{") {
+		return parsed
+	}
+
+	var fields map[string]interface{}
+
+	err := json.Unmarshal([]byte(text), &fields)
+	if err == nil {
+		parsed.Format = LogFormatJSON
+		parsed.Fields = fields
+	}
+
+	return parsed
+}
+
+// ParseAccessLog decodes a gorouter access log line. Well-known trailing
+// fields are promoted to the record; all of them are kept in Fields.
+func ParseAccessLog(line string) (*AccessLogRecord, error) {
+	match := accessLogPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return nil, ErrNotAccessLog
+	}
+
+	record := &AccessLogRecord{
+		Host:        match[1],
+		Method:      match[3],
+		Path:        match[4],
+		Protocol:    match[5],
+		Referer:     dashToEmpty(match[9]),
+		UserAgent:   dashToEmpty(match[10]),
+		RemoteAddr:  dashToEmpty(match[11]),
+		BackendAddr: dashToEmpty(match[12]),
+		AppIndex:    -1,
+		Fields:      map[string]string{},
+	}
+
+	record.Timestamp, _ = time.Parse(time.RFC3339Nano, match[2])
+	record.Status, _ = strconv.Atoi(match[6])
+	record.BytesReceived, _ = strconv.ParseInt(match[7], 10, 64)
+	record.BytesSent, _ = strconv.ParseInt(match[8], 10, 64)
+
+	for _, field := range accessLogFieldPattern.FindAllStringSubmatch(match[13], -1) {
+		value := field[2]
+
+		unquoted, err := strconv.Unquote(value)
+		if err == nil {
+			value = unquoted
+		}
+
+		record.Fields[field[1]] = value
+	}
+
+	record.applyFields()
+
+	return record, nil
+}
+
+func (r *AccessLogRecord) applyFields() {
+	if value := dashToEmpty(r.Fields["x_forwarded_for"]); value != "" {
+		for _, address := range strings.Split(value, ",") {
+			r.XForwardedFor = append(r.XForwardedFor, strings.TrimSpace(address))
+		}
+	}
+
+	r.XForwardedProto = dashToEmpty(r.Fields["x_forwarded_proto"])
+	r.VcapRequestID = dashToEmpty(r.Fields["vcap_request_id"])
+	r.AppID = dashToEmpty(r.Fields["app_id"])
+
+	seconds, err := strconv.ParseFloat(r.Fields["response_time"], 64)
+	if err == nil {
+		r.ResponseTime = time.Duration(seconds * float64(time.Second))
+	}
+
+	index, err := strconv.Atoi(r.Fields["app_index"])
+	if err == nil {
+		r.AppIndex = index
+	}
+}
+
+func dashToEmpty(value string) string {
+	if value == "-" {
+		return ""
+	}
+
+	return value
+}
+
+// StatusFilter matches HTTP status codes against a comma-separated list of
+// codes (404) and classes (5xx).
+type StatusFilter struct {
+	codes   map[int]bool
+	classes map[int]bool
+}
+
+// ParseStatusFilter parses a filter such as "5xx" or "404,429,5xx".
+func ParseStatusFilter(spec string) (*StatusFilter, error) {
+	filter := &StatusFilter{codes: map[int]bool{}, classes: map[int]bool{}}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+
+		if len(part) != 3 { //nolint:mnd // HTTP status codes have three digits
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStatusFilter, part)
+		}
+
+		if strings.HasSuffix(part, "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > serverErrorClass {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidStatusFilter, part)
+			}
+
+			filter.classes[class] = true
+
+			continue
+		}
+
+		code, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStatusFilter, part)
+		}
+
+		filter.codes[code] = true
+	}
+
+	return filter, nil
+}
+
+// Match reports whether status is selected by the filter.
+func (f *StatusFilter) Match(status int) bool {
+	return f.codes[status] || f.classes[status/httpStatusClassDivisor]
+}
+
+// AccessLogPathSummary aggregates the requests to one method and path.
+type AccessLogPathSummary struct {
+	Method    string        `json:"method"     yaml:"method"`
+	Path      string        `json:"path"       yaml:"path"`
+	Requests  int           `json:"requests"   yaml:"requests"`
+	Errors    int           `json:"errors"     yaml:"errors"`
+	ErrorRate float64       `json:"error_rate" yaml:"error_rate"`
+	P50       time.Duration `json:"p50"        yaml:"p50"`
+	P95       time.Duration `json:"p95"        yaml:"p95"`
+	P99       time.Duration `json:"p99"        yaml:"p99"`
+}
+
+// AccessLogSummary collects access log records and reports latency
+// percentiles and 5xx error rates per method and path. Query strings are
+// ignored when grouping.
+type AccessLogSummary struct {
+	paths map[string]*accessLogPathStats
+}
+
+type accessLogPathStats struct {
+	method, path string
+	errors       int
+	latencies    []float64
+}
+
+// NewAccessLogSummary creates an empty summary.
+func NewAccessLogSummary() *AccessLogSummary {
+	return &AccessLogSummary{paths: map[string]*accessLogPathStats{}}
+}
+
+// Add records one request.
+func (s *AccessLogSummary) Add(record *AccessLogRecord) {
+	path, _, _ := strings.Cut(record.Path, "?")
+	key := record.Method + " " + path
+
+	stats, ok := s.paths[key]
+	if !ok {
+		stats = &accessLogPathStats{method: record.Method, path: path}
+		s.paths[key] = stats
+	}
+
+	if record.Status/httpStatusClassDivisor == serverErrorClass {
+		stats.errors++
+	}
+
+	stats.latencies = append(stats.latencies, float64(record.ResponseTime))
+}
+
+// Paths returns the per-path summaries, busiest first.
+func (s *AccessLogSummary) Paths() []AccessLogPathSummary {
+	summaries := make([]AccessLogPathSummary, 0, len(s.paths))
+
+	for _, stats := range s.paths {
+		sorted := append([]float64(nil), stats.latencies...)
+		sort.Float64s(sorted)
+
+		requests := len(sorted)
+		summaries = append(summaries, AccessLogPathSummary{
+			Method:    stats.method,
+			Path:      stats.path,
+			Requests:  requests,
+			Errors:    stats.errors,
+			ErrorRate: float64(stats.errors) / float64(requests),
+			P50:       time.Duration(percentile(sorted, 50)), //nolint:mnd // median
+			P95:       time.Duration(percentile(sorted, 95)), //nolint:mnd // 95th percentile
+			P99:       time.Duration(percentile(sorted, 99)), //nolint:mnd // 99th percentile
+		})
+	}
+
+	sort.Slice(summaries, func(a, b int) bool {
+		if summaries[a].Requests != summaries[b].Requests {
+			return summaries[a].Requests > summaries[b].Requests
+		}
+
+		return summaries[a].Method+summaries[a].Path < summaries[b].Method+summaries[b].Path
+	})
+
+	return summaries
+}
+
+// percentile returns the nearest-rank pth percentile of sorted values, or
+// zero for no values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted)))) //nolint:mnd // percent
+	rank = max(rank, 1)
+
+	return sorted[rank-1]
+}
@@ -0,0 +1,110 @@
+package capi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const accessLogLine = `my-app.example.com - [2024-05-01T12:00:00.123456789Z] "GET /orders?page=2 HTTP/1.1" 503 12 345 "-" "curl/8.4.0" "10.0.0.1:54321" "10.0.1.5:61001" ` +
+	`x_forwarded_for:"203.0.113.7, 10.0.0.1" x_forwarded_proto:"https" vcap_request_id:"9f1c-req" response_time:0.250000 gorouter_time:0.000123 ` +
+	`app_id:"app-guid" app_index:"2" instance_id:"inst-1" x_cf_routererror:"-"`
+
+func TestParseAccessLog(t *testing.T) {
+	t.Parallel()
+
+	record, err := capi.ParseAccessLog(accessLogLine)
+	require.NoError(t, err)
+
+	assert.Equal(t, "my-app.example.com", record.Host)
+	assert.Equal(t, time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC), record.Timestamp)
+	assert.Equal(t, "GET", record.Method)
+	assert.Equal(t, "/orders?page=2", record.Path)
+	assert.Equal(t, "HTTP/1.1", record.Protocol)
+	assert.Equal(t, 503, record.Status)
+	assert.Equal(t, int64(12), record.BytesReceived)
+	assert.Equal(t, int64(345), record.BytesSent)
+	assert.Empty(t, record.Referer)
+	assert.Equal(t, "curl/8.4.0", record.UserAgent)
+	assert.Equal(t, "10.0.1.5:61001", record.BackendAddr)
+	assert.Equal(t, []string{"203.0.113.7", "10.0.0.1"}, record.XForwardedFor)
+	assert.Equal(t, "https", record.XForwardedProto)
+	assert.Equal(t, "9f1c-req", record.VcapRequestID)
+	assert.Equal(t, 250*time.Millisecond, record.ResponseTime)
+	assert.Equal(t, "app-guid", record.AppID)
+	assert.Equal(t, 2, record.AppIndex)
+	assert.Equal(t, "inst-1", record.Fields["instance_id"])
+
+	_, err = capi.ParseAccessLog("listening on :8080")
+	require.ErrorIs(t, err, capi.ErrNotAccessLog)
+}
+
+func TestParseLogMessage(t *testing.T) {
+	t.Parallel()
+
+	access := capi.ParseLogMessage(capi.LogMessage{Message: accessLogLine, SourceType: "RTR/1"})
+	assert.Equal(t, capi.LogFormatAccess, access.Format)
+	require.NotNil(t, access.Access)
+	assert.Equal(t, 503, access.Access.Status)
+
+	structured := capi.ParseLogMessage(capi.LogMessage{Message: `{"level":"error","msg":"boom","attempt":3}`, SourceType: "APP/PROC/WEB"})
+	assert.Equal(t, capi.LogFormatJSON, structured.Format)
+	assert.Equal(t, "error", structured.Fields["level"])
+	assert.InDelta(t, 3, structured.Fields["attempt"], 0)
+
+	text := capi.ParseLogMessage(capi.LogMessage{Message: "{not json", SourceType: "APP/PROC/WEB"})
+	assert.Equal(t, capi.LogFormatText, text.Format)
+	assert.Nil(t, text.Fields)
+}
+
+func TestParseStatusFilter(t *testing.T) {
+	t.Parallel()
+
+	filter, err := capi.ParseStatusFilter("5xx, 404")
+	require.NoError(t, err)
+	assert.True(t, filter.Match(500))
+	assert.True(t, filter.Match(503))
+	assert.True(t, filter.Match(404))
+	assert.False(t, filter.Match(200))
+	assert.False(t, filter.Match(401))
+
+	for _, spec := range []string{"", "5x", "9xx", "abc", "50000"} {
+		_, err = capi.ParseStatusFilter(spec)
+		require.ErrorIs(t, err, capi.ErrInvalidStatusFilter, spec)
+	}
+}
+
+func TestAccessLogSummary(t *testing.T) {
+	t.Parallel()
+
+	summary := capi.NewAccessLogSummary()
+
+	for i := 1; i <= 100; i++ {
+		status := 200
+		if i%10 == 0 {
+			status = 502
+		}
+
+		summary.Add(&capi.AccessLogRecord{Method: "GET", Path: "/orders?page=" + string(rune('0'+i%10)), Status: status, ResponseTime: time.Duration(i) * time.Millisecond})
+	}
+
+	summary.Add(&capi.AccessLogRecord{Method: "POST", Path: "/orders", Status: 201, ResponseTime: time.Second})
+
+	paths := summary.Paths()
+	require.Len(t, paths, 2)
+
+	assert.Equal(t, "GET", paths[0].Method)
+	assert.Equal(t, "/orders", paths[0].Path)
+	assert.Equal(t, 100, paths[0].Requests)
+	assert.Equal(t, 10, paths[0].Errors)
+	assert.InDelta(t, 0.1, paths[0].ErrorRate, 0.0001)
+	assert.Equal(t, 50*time.Millisecond, paths[0].P50)
+	assert.Equal(t, 95*time.Millisecond, paths[0].P95)
+	assert.Equal(t, 99*time.Millisecond, paths[0].P99)
+
+	assert.Equal(t, "POST", paths[1].Method)
+	assert.Equal(t, time.Second, paths[1].P99)
+}
@@ -0,0 +1,374 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrLogTailSpaceRequired = errors.New("a space is required to tail logs")
+	ErrLogTailStreamClosed  = errors.New("log stream closed")
+)
+
+const (
+	defaultLogTailRefreshInterval = 30 * time.Second
+	logTailBufferSize             = 100
+	// logTailReplayLimit is the largest page Log Cache serves.
+	logTailReplayLimit = 1000
+	// logTailResubscribeDelay is the delay before the first resubscribe
+	// after an app's stream closes; it doubles up to a minute.
+	logTailResubscribeDelay    = time.Second
+	maxLogTailResubscribeDelay = time.Minute
+	// logTailDedupWindow is how far behind the newest line a repeated line
+	// is still recognized, which covers the overlap between a Log Cache
+	// backfill and the stream it follows.
+	logTailDedupWindow = time.Minute
+)
+
+// AppLogMessage is a LogMessage tagged with the name of the app it came
+// from.
+type AppLogMessage struct {
+	LogMessage `yaml:",inline"`
+
+	AppName string `json:"app_name" yaml:"app_name"`
+}
+
+// LogTailOptions selects the apps TailLogs follows.
+type LogTailOptions struct {
+	// SpaceGUID restricts the tail to one space. Required.
+	SpaceGUID string
+	// LabelSelector restricts the tail to apps matching a label selector,
+	// e.g. "team=payments".
+	LabelSelector string
+	// AppNames restricts the tail to the named apps.
+	AppNames []string
+	// Since replays logs this old from Log Cache before following.
+	Since time.Duration
+	// RefreshInterval is how often the app list is re-read to pick up apps
+	// created while tailing. Defaults to 30 seconds.
+	RefreshInterval time.Duration
+	// Filter, if set, drops the messages for which it returns false.
+	Filter func(*AppLogMessage) bool
+	// OnError, if set, receives errors that do not stop the tail, such as a
+	// failed app list refresh.
+	OnError func(error)
+}
+
+// TailLogs follows the logs of every app matching opts concurrently and
+// merges them into one channel, which is closed once ctx is canceled. Apps
+// created while tailing are picked up on the next refresh and replayed from
+// the start of the tail. An app whose stream closes is resubscribed and
+// backfilled from its last line.
+func TailLogs(ctx context.Context, client Client, opts LogTailOptions) (<-chan AppLogMessage, error) {
+	if opts.SpaceGUID == "" {
+		return nil, ErrLogTailSpaceRequired
+	}
+
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = defaultLogTailRefreshInterval
+	}
+
+	tail := &logTail{
+		client:  client,
+		opts:    opts,
+		started: time.Now(),
+		tailed:  map[string]bool{},
+		out:     make(chan AppLogMessage, logTailBufferSize),
+	}
+
+	apps, err := tail.listApps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tail.follow(ctx, apps, tail.started.Add(-opts.Since), opts.Since > 0)
+
+	go tail.refresh(ctx)
+
+	return tail.out, nil
+}
+
+type logTail struct {
+	client  Client
+	opts    LogTailOptions
+	started time.Time
+	// tailed holds the GUIDs of the apps being followed; only the refresh
+	// goroutine touches it after TailLogs returns.
+	tailed map[string]bool
+	wg     sync.WaitGroup
+	out    chan AppLogMessage
+}
+
+func (t *logTail) listApps(ctx context.Context) ([]App, error) {
+	apps, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[App], error) {
+		if t.opts.LabelSelector != "" {
+			params.WithLabelSelector(t.opts.LabelSelector)
+		}
+
+		options := []AppListOption{WithAppSpaceGUIDs(t.opts.SpaceGUID)}
+		if len(t.opts.AppNames) > 0 {
+			options = append(options, WithAppNames(t.opts.AppNames...))
+		}
+
+		return t.client.Apps().List(ctx, params, options...)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list apps in space %s: %w", t.opts.SpaceGUID, err)
+	}
+
+	return apps, nil
+}
+
+// follow starts a tail for every app not yet followed, replaying logs from
+// replayFrom first when replay is set.
+func (t *logTail) follow(ctx context.Context, apps []App, replayFrom time.Time, replay bool) {
+	for _, app := range apps {
+		if t.tailed[app.GUID] {
+			continue
+		}
+
+		t.tailed[app.GUID] = true
+		t.wg.Add(1)
+
+		go t.followApp(ctx, app, replayFrom, replay)
+	}
+}
+
+// refresh re-reads the app list until ctx is canceled, then closes the
+// output once every app tail has stopped.
+func (t *logTail) refresh(ctx context.Context) {
+	defer func() {
+		t.wg.Wait()
+		close(t.out)
+	}()
+
+	ticker := time.NewTicker(t.opts.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			apps, err := t.listApps(ctx)
+			if err != nil {
+				t.reportError(err)
+
+				continue
+			}
+
+			// Apps appearing now were created after the tail started;
+			// replay them from the start so that no line is missed.
+			t.follow(ctx, apps, t.started, true)
+		}
+	}
+}
+
+// followApp subscribes to the app's log stream and, once it is open,
+// backfills from Log Cache what was logged since replayFrom, or since the
+// last delivered line after a resubscribe, so that no line is lost between
+// the two. A stream that closes is resubscribed with backoff until ctx is
+// canceled.
+func (t *logTail) followApp(ctx context.Context, app App, replayFrom time.Time, replay bool) {
+	defer t.wg.Done()
+
+	follow := &appTail{logTail: t, app: app, from: replayFrom, seen: map[logLineKey]struct{}{}}
+	delay := logTailResubscribeDelay
+
+	for {
+		messages, err := t.client.Apps().StreamLogs(ctx, app.GUID)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			t.reportError(fmt.Errorf("failed to stream logs of app %s: %w", app.Name, err))
+		} else {
+			if replay {
+				follow.backfill(ctx)
+			}
+
+			replay = true
+
+			if follow.drain(ctx, messages) {
+				delay = logTailResubscribeDelay
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			t.reportError(fmt.Errorf("%w for app %s, resubscribing in %s", ErrLogTailStreamClosed, app.Name, delay))
+		}
+
+		err = sleepContext(ctx, delay)
+		if err != nil {
+			return
+		}
+
+		delay = min(delay*constants.ExponentialBackoffBase, maxLogTailResubscribeDelay)
+	}
+}
+
+// appTail is the state of one app's tail: where to backfill from, the
+// newest line delivered and the recent lines, so that lines read from both
+// Log Cache and the stream are emitted once.
+type appTail struct {
+	*logTail
+
+	app  App
+	from time.Time
+	last time.Time
+	seen map[logLineKey]struct{}
+	// order holds the seen lines in delivery order for pruning.
+	order []seenLogLine
+}
+
+// seenLogLine is a delivered line remembered for deduplication.
+type seenLogLine struct {
+	key       logLineKey
+	timestamp time.Time
+}
+
+// logLineKey identifies a log line across Log Cache and the stream.
+type logLineKey struct {
+	timestamp  int64
+	sourceType string
+	instance   string
+	message    string
+}
+
+// drain delivers the stream's lines until it closes and reports whether it
+// delivered any.
+func (f *appTail) drain(ctx context.Context, messages <-chan LogMessage) bool {
+	delivered := false
+
+	for message := range messages {
+		delivered = true
+
+		f.deliver(ctx, message)
+	}
+
+	return delivered
+}
+
+// backfill reads from Log Cache what was logged since the newest delivered
+// line, or since the start of the tail before any line was delivered, up to
+// now. It pages through Log Cache until the gap is filled; each page starts
+// at the newest timestamp of the previous one, whose lines deliver skips.
+func (f *appTail) backfill(ctx context.Context) {
+	start := f.from
+	if !f.last.IsZero() {
+		start = f.last
+	}
+
+	end := time.Now()
+
+	for ctx.Err() == nil {
+		envelopes, err := f.client.LogCache().Read(ctx, f.app.GUID, &LogCacheReadOptions{
+			EnvelopeTypes: []LogCacheEnvelopeType{LogCacheEnvelopeTypeLog},
+			Start:         start,
+			End:           end,
+			Limit:         logTailReplayLimit,
+		})
+		if err != nil {
+			f.reportError(fmt.Errorf("failed to read recent logs of app %s: %w", f.app.Name, err))
+
+			return
+		}
+
+		next := start
+
+		for i := range envelopes {
+			if timestamp := envelopes[i].Time(); timestamp.After(next) {
+				next = timestamp
+			}
+
+			message, ok := LogMessageFromEnvelope(&envelopes[i])
+			if !ok {
+				continue
+			}
+
+			message.AppID = f.app.GUID
+
+			f.deliver(ctx, message)
+		}
+
+		if len(envelopes) < logTailReplayLimit {
+			return
+		}
+
+		// A full page of one timestamp would otherwise be read forever.
+		if !next.After(start) {
+			next = start.Add(time.Nanosecond)
+		}
+
+		start = next
+	}
+}
+
+// deliver emits message unless it was already delivered. Lines without a
+// timestamp cannot be matched and are always emitted.
+func (f *appTail) deliver(ctx context.Context, message LogMessage) {
+	if !message.Timestamp.IsZero() {
+		key := logLineKey{
+			timestamp:  message.Timestamp.UnixNano(),
+			sourceType: message.SourceType,
+			instance:   message.SourceID,
+			message:    message.Message,
+		}
+
+		if _, ok := f.seen[key]; ok {
+			return
+		}
+
+		f.seen[key] = struct{}{}
+		f.order = append(f.order, seenLogLine{key: key, timestamp: message.Timestamp})
+
+		if message.Timestamp.After(f.last) {
+			f.last = message.Timestamp
+		}
+
+		f.prune()
+	}
+
+	f.emit(ctx, AppLogMessage{LogMessage: message, AppName: f.app.Name})
+}
+
+// prune forgets the oldest delivered lines that fell out of the dedup
+// window. Lines arrive roughly in time order, so only the front of order is
+// checked; a line delivered out of order is kept until those before it go.
+func (f *appTail) prune() {
+	cutoff := f.last.Add(-logTailDedupWindow)
+
+	expired := 0
+	for expired < len(f.order) && f.order[expired].timestamp.Before(cutoff) {
+		delete(f.seen, f.order[expired].key)
+		expired++
+	}
+
+	f.order = f.order[expired:]
+}
+
+func (t *logTail) emit(ctx context.Context, message AppLogMessage) {
+	if t.opts.Filter != nil && !t.opts.Filter(&message) {
+		return
+	}
+
+	select {
+	case t.out <- message:
+	case <-ctx.Done():
+	}
+}
+
+func (t *logTail) reportError(err error) {
+	if t.opts.OnError != nil {
+		t.opts.OnError(err)
+	}
+}
@@ -0,0 +1,342 @@
+package capi_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tailApps serves a mutable app list and streams one scripted line per app.
+type tailApps struct {
+	capi.AppsClient
+
+	mu   sync.Mutex
+	apps []capi.App
+}
+
+func (s *tailApps) add(app capi.App) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apps = append(s.apps, app)
+}
+
+func (s *tailApps) List(_ context.Context, params *capi.QueryParams, _ ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var apps []capi.App
+
+	for _, app := range s.apps {
+		if params.LabelSelector == "" || app.Metadata != nil && app.Metadata.Labels["team"] == strings.TrimPrefix(params.LabelSelector, "team=") {
+			apps = append(apps, app)
+		}
+	}
+
+	return &capi.ListResponse[capi.App]{Resources: apps}, nil
+}
+
+func (s *tailApps) StreamLogs(ctx context.Context, guid string) (<-chan capi.LogMessage, error) {
+	messages := make(chan capi.LogMessage, 1)
+	messages <- capi.LogMessage{Message: "live from " + guid, AppID: guid, SourceType: "APP/PROC/WEB", SourceID: "0"}
+
+	go func() {
+		<-ctx.Done()
+		close(messages)
+	}()
+
+	return messages, nil
+}
+
+// tailLogCache replays one line per app.
+type tailLogCache struct {
+	capi.LogCacheClient
+
+	mu    sync.Mutex
+	start map[string]time.Time
+}
+
+func (s *tailLogCache) Read(_ context.Context, sourceID string, opts *capi.LogCacheReadOptions) ([]capi.LogCacheEnvelope, error) {
+	s.mu.Lock()
+	s.start[sourceID] = opts.Start
+	s.mu.Unlock()
+
+	return []capi.LogCacheEnvelope{
+		{Timestamp: "1700000000000000000", SourceID: sourceID, InstanceID: "1", Tags: map[string]string{"source_type": "RTR"},
+			Log: &capi.LogCacheLogEnvelope{Payload: []byte("replayed"), Type: "OUT"}},
+		{Timestamp: "1700000000000000001", SourceID: sourceID, Gauge: &capi.LogCacheGaugeEnvelope{}},
+	}, nil
+}
+
+func collectTail(t *testing.T, messages <-chan capi.AppLogMessage, count int) []capi.AppLogMessage {
+	t.Helper()
+
+	var received []capi.AppLogMessage
+
+	for len(received) < count {
+		select {
+		case message := <-messages:
+			received = append(received, message)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for log messages", "received %d of %d", len(received), count)
+		}
+	}
+
+	return received
+}
+
+func TestTailLogs(t *testing.T) {
+	t.Parallel()
+
+	apps := &tailApps{apps: []capi.App{
+		{Resource: capi.Resource{GUID: "guid-api"}, Name: "api", Metadata: &capi.Metadata{Labels: map[string]string{"team": "payments"}}},
+		{Resource: capi.Resource{GUID: "guid-web"}, Name: "web", Metadata: &capi.Metadata{Labels: map[string]string{"team": "frontend"}}},
+	}}
+	logCache := &tailLogCache{start: map[string]time.Time{}}
+	client := &stubClient{apps: apps, logCache: logCache}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := time.Now()
+
+	messages, err := capi.TailLogs(ctx, client, capi.LogTailOptions{
+		SpaceGUID:       "space-guid",
+		LabelSelector:   "team=payments",
+		Since:           10 * time.Minute,
+		RefreshInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	received := collectTail(t, messages, 2)
+	assert.Equal(t, []string{"replayed", "live from guid-api"}, []string{received[0].Message, received[1].Message})
+	assert.Equal(t, "api", received[0].AppName)
+	assert.Equal(t, "guid-api", received[0].AppID)
+	assert.Equal(t, "RTR", received[0].SourceType)
+
+	logCache.mu.Lock()
+	assert.WithinDuration(t, before.Add(-10*time.Minute), logCache.start["guid-api"], time.Second)
+	logCache.mu.Unlock()
+
+	// An app created while tailing is picked up and replayed.
+	apps.add(capi.App{Resource: capi.Resource{GUID: "guid-worker"}, Name: "worker", Metadata: &capi.Metadata{Labels: map[string]string{"team": "payments"}}})
+
+	received = collectTail(t, messages, 2)
+	assert.Equal(t, "worker", received[0].AppName)
+	assert.Equal(t, "worker", received[1].AppName)
+
+	// Canceling the context closes the channel once every app tail stopped.
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, open := <-messages
+
+		return !open
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestTailLogsFilter(t *testing.T) {
+	t.Parallel()
+
+	apps := &tailApps{apps: []capi.App{{Resource: capi.Resource{GUID: "guid-api"}, Name: "api"}}}
+	client := &stubClient{apps: apps, logCache: &tailLogCache{start: map[string]time.Time{}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := capi.TailLogs(ctx, client, capi.LogTailOptions{
+		SpaceGUID: "space-guid",
+		Since:     time.Minute,
+		Filter: func(message *capi.AppLogMessage) bool {
+			return strings.HasPrefix(message.SourceType, "APP")
+		},
+	})
+	require.NoError(t, err)
+
+	received := collectTail(t, messages, 1)
+	assert.Equal(t, "live from guid-api", received[0].Message)
+}
+
+// resubscribeApps closes the first stream after one line; the second stream
+// repeats a line Log Cache also serves, then stays open.
+type resubscribeApps struct {
+	capi.AppsClient
+
+	mu            sync.Mutex
+	subscriptions int
+}
+
+func (s *resubscribeApps) List(_ context.Context, _ *capi.QueryParams, _ ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	return &capi.ListResponse[capi.App]{Resources: []capi.App{{Resource: capi.Resource{GUID: "guid-api"}, Name: "api"}}}, nil
+}
+
+func (s *resubscribeApps) StreamLogs(ctx context.Context, _ string) (<-chan capi.LogMessage, error) {
+	s.mu.Lock()
+	s.subscriptions++
+	first := s.subscriptions == 1
+	s.mu.Unlock()
+
+	messages := make(chan capi.LogMessage, 2)
+
+	if first {
+		messages <- tailLine(0, "first")
+		close(messages)
+
+		return messages, nil
+	}
+
+	messages <- tailLine(1, "missed")
+	messages <- tailLine(2, "second")
+
+	go func() {
+		<-ctx.Done()
+		close(messages)
+	}()
+
+	return messages, nil
+}
+
+func tailLine(offset int64, text string) capi.LogMessage {
+	return capi.LogMessage{Message: text, Timestamp: time.Unix(0, 1700000000000000000+offset), SourceType: "APP/PROC/WEB", SourceID: "0"}
+}
+
+// backfillLogCache serves the first line again and the line logged while
+// the stream was closed.
+type backfillLogCache struct {
+	capi.LogCacheClient
+
+	mu    sync.Mutex
+	start time.Time
+}
+
+func (s *backfillLogCache) Read(_ context.Context, sourceID string, opts *capi.LogCacheReadOptions) ([]capi.LogCacheEnvelope, error) {
+	s.mu.Lock()
+	s.start = opts.Start
+	s.mu.Unlock()
+
+	envelope := func(timestamp, text string) capi.LogCacheEnvelope {
+		return capi.LogCacheEnvelope{Timestamp: timestamp, SourceID: sourceID, InstanceID: "0", Tags: map[string]string{"source_type": "APP/PROC/WEB"},
+			Log: &capi.LogCacheLogEnvelope{Payload: []byte(text), Type: "OUT"}}
+	}
+
+	return []capi.LogCacheEnvelope{envelope("1700000000000000000", "first"), envelope("1700000000000000001", "missed")}, nil
+}
+
+func TestTailLogsResubscribes(t *testing.T) {
+	t.Parallel()
+
+	logCache := &backfillLogCache{}
+	client := &stubClient{apps: &resubscribeApps{}, logCache: logCache}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+
+	messages, err := capi.TailLogs(ctx, client, capi.LogTailOptions{
+		SpaceGUID: "space-guid",
+		OnError: func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			reported = append(reported, err)
+		},
+	})
+	require.NoError(t, err)
+
+	received := collectTail(t, messages, 3)
+	assert.Equal(t, []string{"first", "missed", "second"}, []string{received[0].Message, received[1].Message, received[2].Message})
+
+	select {
+	case message := <-messages:
+		assert.Failf(t, "unexpected repeated line", "%s", message.Message)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	logCache.mu.Lock()
+	assert.Equal(t, time.Unix(0, 1700000000000000000), logCache.start)
+	logCache.mu.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Len(t, reported, 1)
+	require.ErrorIs(t, reported[0], capi.ErrLogTailStreamClosed)
+}
+
+// pagingLogCache serves a full page of lines, then the rest of the gap from
+// the newest timestamp of that page on.
+type pagingLogCache struct {
+	capi.LogCacheClient
+
+	base   time.Time
+	mu     sync.Mutex
+	starts []time.Time
+}
+
+func (s *pagingLogCache) Read(_ context.Context, sourceID string, opts *capi.LogCacheReadOptions) ([]capi.LogCacheEnvelope, error) {
+	s.mu.Lock()
+	s.starts = append(s.starts, opts.Start)
+	page := len(s.starts)
+	s.mu.Unlock()
+
+	envelope := func(offset int64, text string) capi.LogCacheEnvelope {
+		return capi.LogCacheEnvelope{Timestamp: strconv.FormatInt(s.base.UnixNano()+offset, 10), SourceID: sourceID, InstanceID: "0",
+			Tags: map[string]string{"source_type": "APP/PROC/WEB"}, Log: &capi.LogCacheLogEnvelope{Payload: []byte(text), Type: "OUT"}}
+	}
+
+	var envelopes []capi.LogCacheEnvelope
+
+	switch page {
+	case 1:
+		for offset := range int64(1000) {
+			envelopes = append(envelopes, envelope(offset, "old "+strconv.FormatInt(offset, 10)))
+		}
+	case 2: //nolint:mnd // second page
+		envelopes = append(envelopes, envelope(999, "old 999"), envelope(1000, "newest"))
+	}
+
+	return envelopes, nil
+}
+
+func TestTailLogsBackfillsEveryPage(t *testing.T) {
+	t.Parallel()
+
+	apps := &tailApps{apps: []capi.App{{Resource: capi.Resource{GUID: "guid-api"}, Name: "api"}}}
+	logCache := &pagingLogCache{base: time.Now().Add(-time.Minute)}
+	client := &stubClient{apps: apps, logCache: logCache}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := capi.TailLogs(ctx, client, capi.LogTailOptions{SpaceGUID: "space-guid", Since: time.Hour})
+	require.NoError(t, err)
+
+	received := collectTail(t, messages, 1002)
+	assert.Equal(t, "old 0", received[0].Message)
+	assert.Equal(t, "old 999", received[999].Message)
+	assert.Equal(t, "newest", received[1000].Message)
+	assert.Equal(t, "live from guid-api", received[1001].Message)
+
+	logCache.mu.Lock()
+	defer logCache.mu.Unlock()
+
+	require.Len(t, logCache.starts, 2)
+	assert.Equal(t, logCache.base.UnixNano()+999, logCache.starts[1].UnixNano())
+}
+
+func TestTailLogsRequiresSpace(t *testing.T) {
+	t.Parallel()
+
+	_, err := capi.TailLogs(context.Background(), &stubClient{}, capi.LogTailOptions{})
+	require.ErrorIs(t, err, capi.ErrLogTailSpaceRequired)
+}
@@ -0,0 +1,788 @@
+package capi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest diff operations returned by the manifest_diff endpoint.
+const (
+	ManifestDiffOpAdd     = "add"
+	ManifestDiffOpRemove  = "remove"
+	ManifestDiffOpReplace = "replace"
+)
+
+// DefaultManifestDiffContextLines is the number of unchanged lines shown
+// around each change in the unified view, matching diff(1).
+const DefaultManifestDiffContextLines = 3
+
+// ANSI escape sequences used when ManifestDiffRenderOptions.Color is set.
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+	ansiBold   = "\033[1m"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidManifest          = errors.New("invalid manifest")
+	ErrManifestDiffNil          = errors.New("manifest diff is nil")
+	errManifestPathInvalid      = errors.New("manifest path does not resolve")
+	errManifestPathIndexInvalid = errors.New("manifest path index out of range")
+)
+
+// HasChanges reports whether the diff contains at least one entry.
+func (d *ManifestDiff) HasChanges() bool {
+	return d != nil && len(d.Diff) > 0
+}
+
+// ManifestDiffChange is a ManifestDiffEntry resolved against the manifest it
+// was computed from, naming the application, process, route, sidecar or
+// service the operation touches instead of its positional JSON pointer.
+type ManifestDiffChange struct {
+	Op          string      `json:"op"                    yaml:"op"`
+	Path        string      `json:"path"                  yaml:"path"`
+	Application string      `json:"application,omitempty" yaml:"application,omitempty"`
+	Process     string      `json:"process,omitempty"     yaml:"process,omitempty"`
+	Route       string      `json:"route,omitempty"       yaml:"route,omitempty"`
+	Sidecar     string      `json:"sidecar,omitempty"     yaml:"sidecar,omitempty"`
+	Service     string      `json:"service,omitempty"     yaml:"service,omitempty"`
+	Field       string      `json:"field,omitempty"       yaml:"field,omitempty"`
+	Was         interface{} `json:"was,omitempty"         yaml:"was,omitempty"`
+	Value       interface{} `json:"value,omitempty"       yaml:"value,omitempty"`
+}
+
+// Target describes what the change touches, e.g.
+// `app "web" process "worker": instances`.
+func (c ManifestDiffChange) Target() string {
+	parts := make([]string, 0, 3)
+
+	if c.Application != "" {
+		parts = append(parts, fmt.Sprintf("app %q", c.Application))
+	}
+
+	for _, element := range []struct{ kind, name string }{
+		{"process", c.Process},
+		{"route", c.Route},
+		{"sidecar", c.Sidecar},
+		{"service", c.Service},
+	} {
+		if element.name != "" {
+			parts = append(parts, fmt.Sprintf("%s %q", element.kind, element.name))
+		}
+	}
+
+	target := strings.Join(parts, " ")
+
+	switch {
+	case target == "":
+		return c.Field
+	case c.Field == "":
+		return target
+	default:
+		return target + ": " + c.Field
+	}
+}
+
+// ManifestDiffReport is the machine-readable form of a rendered manifest diff.
+type ManifestDiffReport struct {
+	HasChanges bool                 `json:"has_changes" yaml:"has_changes"`
+	Changes    []ManifestDiffChange `json:"changes"     yaml:"changes"`
+}
+
+// ManifestDiffRenderOptions controls how a ManifestDiffRenderer writes output.
+type ManifestDiffRenderOptions struct {
+	// ContextLines is the number of unchanged lines shown around each change.
+	ContextLines int
+	// Color wraps added, removed and hunk header lines in ANSI colors.
+	Color bool
+	// FromLabel and ToLabel name the two sides in the unified diff header.
+	// They default to "current" and "proposed".
+	FromLabel string
+	ToLabel   string
+}
+
+// ManifestDiffRenderer turns the JSON-pointer operations returned by
+// ManifestsClient.CreateManifestDiff into a human-readable view. It resolves
+// every operation path against the proposed manifest and reconstructs the
+// current manifest by reverting the operations, so the two documents can be
+// compared line by line.
+//
+// Reconstruction is best effort: an operation whose path cannot be resolved
+// still appears in Changes, but does not contribute to the unified view.
+type ManifestDiffRenderer struct {
+	changes  []ManifestDiffChange
+	current  []string
+	proposed []string
+}
+
+// NewManifestDiffRenderer builds a renderer for diff, which must have been
+// computed from manifest.
+func NewManifestDiffRenderer(manifest []byte, diff *ManifestDiff) (*ManifestDiffRenderer, error) {
+	if diff == nil {
+		return nil, ErrManifestDiffNil
+	}
+
+	proposedRoot, err := parseManifestNode(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	currentRoot, err := parseManifestNode(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Operations apply in order to turn current into proposed, so undoing
+	// them in reverse order recovers current from proposed.
+	for i := len(diff.Diff) - 1; i >= 0; i-- {
+		_ = revertManifestDiffEntry(currentRoot, diff.Diff[i])
+	}
+
+	changes := make([]ManifestDiffChange, 0, len(diff.Diff))
+	for _, entry := range diff.Diff {
+		changes = append(changes, resolveManifestDiffEntry(proposedRoot, currentRoot, entry))
+	}
+
+	currentLines, err := manifestNodeLines(currentRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	proposedLines, err := manifestNodeLines(proposedRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManifestDiffRenderer{
+		changes:  changes,
+		current:  currentLines,
+		proposed: proposedLines,
+	}, nil
+}
+
+// HasChanges reports whether the diff contains at least one change.
+func (r *ManifestDiffRenderer) HasChanges() bool {
+	return len(r.changes) > 0
+}
+
+// Changes returns the resolved changes in the order the API returned them.
+func (r *ManifestDiffRenderer) Changes() []ManifestDiffChange {
+	return r.changes
+}
+
+// Report returns the machine-readable representation of the diff.
+func (r *ManifestDiffRenderer) Report() *ManifestDiffReport {
+	return &ManifestDiffReport{
+		HasChanges: r.HasChanges(),
+		Changes:    r.changes,
+	}
+}
+
+// WriteSummary writes one line per change, prefixed with "+", "-" or "~" for
+// add, remove and replace operations.
+func (r *ManifestDiffRenderer) WriteSummary(w io.Writer, opts ManifestDiffRenderOptions) error {
+	buf := bufio.NewWriter(w)
+
+	if !r.HasChanges() {
+		_, _ = buf.WriteString("No differences found\n")
+
+		return flushManifestDiff(buf)
+	}
+
+	_, _ = fmt.Fprintf(buf, "Found %d difference(s):\n", len(r.changes))
+
+	for _, change := range r.changes {
+		var line string
+
+		switch change.Op {
+		case ManifestDiffOpAdd:
+			line = fmt.Sprintf("  + %s: %s", change.Target(), formatManifestDiffValue(change.Value))
+			line = colorize(line, ansiGreen, opts.Color)
+		case ManifestDiffOpRemove:
+			line = fmt.Sprintf("  - %s: %s", change.Target(), formatManifestDiffValue(change.Was))
+			line = colorize(line, ansiRed, opts.Color)
+		default:
+			line = fmt.Sprintf("  ~ %s: %s => %s", change.Target(),
+				formatManifestDiffValue(change.Was), formatManifestDiffValue(change.Value))
+		}
+
+		_, _ = buf.WriteString(line + "\n")
+	}
+
+	return flushManifestDiff(buf)
+}
+
+// WriteUnified writes a unified diff between the current and proposed
+// manifests, with opts.ContextLines lines of context around each hunk.
+func (r *ManifestDiffRenderer) WriteUnified(w io.Writer, opts ManifestDiffRenderOptions) error {
+	if !r.HasChanges() {
+		return nil
+	}
+
+	fromLabel := opts.FromLabel
+	if fromLabel == "" {
+		fromLabel = "current"
+	}
+
+	toLabel := opts.ToLabel
+	if toLabel == "" {
+		toLabel = "proposed"
+	}
+
+	buf := bufio.NewWriter(w)
+
+	_, _ = buf.WriteString(colorize("--- "+fromLabel, ansiBold, opts.Color) + "\n")
+	_, _ = buf.WriteString(colorize("+++ "+toLabel, ansiBold, opts.Color) + "\n")
+
+	edits := diffLines(r.current, r.proposed)
+	for _, hunk := range buildHunks(edits, max(opts.ContextLines, 0)) {
+		_, _ = buf.WriteString(colorize(hunk.header(), ansiCyan, opts.Color) + "\n")
+
+		for _, edit := range hunk.edits {
+			switch edit.kind {
+			case lineAdded:
+				_, _ = buf.WriteString(colorize("+"+edit.text, ansiGreen, opts.Color) + "\n")
+			case lineRemoved:
+				_, _ = buf.WriteString(colorize("-"+edit.text, ansiRed, opts.Color) + "\n")
+			case lineKept:
+				_, _ = buf.WriteString(" " + edit.text + "\n")
+			}
+		}
+	}
+
+	return flushManifestDiff(buf)
+}
+
+func flushManifestDiff(buf *bufio.Writer) error {
+	err := buf.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write manifest diff: %w", err)
+	}
+
+	return nil
+}
+
+func colorize(text, color string, enabled bool) string {
+	if !enabled {
+		return text
+	}
+
+	return color + text + ansiReset
+}
+
+// formatManifestDiffValue renders a diff value on a single line.
+func formatManifestDiffValue(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+
+	switch typed := normalizeManifestDiffValue(value).(type) {
+	case string:
+		return strconv.Quote(typed)
+	case int64:
+		return strconv.FormatInt(typed, 10)
+	default:
+		encoded, err := json.Marshal(typed)
+		if err != nil {
+			return fmt.Sprintf("%v", typed)
+		}
+
+		return string(encoded)
+	}
+}
+
+// normalizeManifestDiffValue converts the float64 numbers produced by JSON
+// decoding back to integers where they are integral, so "2" is not rendered
+// as "2.0" or "1e+06".
+func normalizeManifestDiffValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case float64:
+		if typed == float64(int64(typed)) {
+			return int64(typed)
+		}
+
+		return typed
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			out[key] = normalizeManifestDiffValue(item)
+		}
+
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, item := range typed {
+			out[i] = normalizeManifestDiffValue(item)
+		}
+
+		return out
+	default:
+		return value
+	}
+}
+
+// parseManifestNode decodes manifest into its root mapping node, preserving
+// key order so the unified view matches the file as written.
+func parseManifestNode(manifest []byte) (*yaml.Node, error) {
+	var document yaml.Node
+
+	err := yaml.Unmarshal(manifest, &document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+
+	return document.Content[0], nil
+}
+
+func manifestNodeLines(root *yaml.Node) ([]string, error) {
+	encoded, err := encodeManifestNode(root)
+	if err != nil {
+		return nil, err
+	}
+
+	text := strings.TrimRight(string(encoded), "\n")
+	if text == "" || text == "{}" {
+		return nil, nil
+	}
+
+	return strings.Split(text, "\n"), nil
+}
+
+// splitManifestPath splits a JSON pointer (RFC 6901) into unescaped tokens.
+func splitManifestPath(path string) []string {
+	trimmed := strings.TrimPrefix(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+
+	tokens := strings.Split(trimmed, "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens
+}
+
+// lookupManifestNode follows tokens from root, returning nil when any token
+// does not resolve.
+func lookupManifestNode(root *yaml.Node, tokens []string) *yaml.Node {
+	node := root
+	for _, token := range tokens {
+		node = manifestChild(node, token)
+		if node == nil {
+			return nil
+		}
+	}
+
+	return node
+}
+
+func manifestChild(node *yaml.Node, token string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == token {
+				return node.Content[i+1]
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(token)
+		if err == nil && index >= 0 && index < len(node.Content) {
+			return node.Content[index]
+		}
+	case yaml.DocumentNode, yaml.ScalarNode, yaml.AliasNode:
+	}
+
+	return nil
+}
+
+// revertManifestDiffEntry applies the inverse of entry to root.
+func revertManifestDiffEntry(root *yaml.Node, entry ManifestDiffEntry) error {
+	tokens := splitManifestPath(entry.Path)
+	if len(tokens) == 0 {
+		return errManifestPathInvalid
+	}
+
+	parent := lookupManifestNode(root, tokens[:len(tokens)-1])
+	if parent == nil {
+		return errManifestPathInvalid
+	}
+
+	last := tokens[len(tokens)-1]
+
+	switch entry.Op {
+	case ManifestDiffOpAdd:
+		return removeManifestChild(parent, last)
+	case ManifestDiffOpRemove:
+		return insertManifestChild(parent, last, entry.Was)
+	case ManifestDiffOpReplace:
+		if entry.Was == nil {
+			return removeManifestChild(parent, last)
+		}
+
+		return replaceManifestChild(parent, last, entry.Was)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedOperationType, entry.Op)
+	}
+}
+
+func removeManifestChild(parent *yaml.Node, token string) error {
+	switch parent.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == token {
+				parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+
+				return nil
+			}
+		}
+	case yaml.SequenceNode:
+		index := len(parent.Content) - 1
+		if token != "-" {
+			parsed, err := strconv.Atoi(token)
+			if err != nil {
+				return errManifestPathInvalid
+			}
+
+			index = parsed
+		}
+
+		if index < 0 || index >= len(parent.Content) {
+			return errManifestPathIndexInvalid
+		}
+
+		parent.Content = append(parent.Content[:index], parent.Content[index+1:]...)
+
+		return nil
+	case yaml.DocumentNode, yaml.ScalarNode, yaml.AliasNode:
+	}
+
+	return errManifestPathInvalid
+}
+
+// replaceManifestChild swaps the value at token in place, keeping its
+// position so the unified view shows a single changed line.
+func replaceManifestChild(parent *yaml.Node, token string, value interface{}) error {
+	existing := manifestChild(parent, token)
+	if existing == nil {
+		return insertManifestChild(parent, token, value)
+	}
+
+	valueNode, err := encodeManifestValue(value)
+	if err != nil {
+		return err
+	}
+
+	*existing = *valueNode
+
+	return nil
+}
+
+func encodeManifestValue(value interface{}) (*yaml.Node, error) {
+	valueNode := &yaml.Node{}
+
+	err := valueNode.Encode(normalizeManifestDiffValue(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest value: %w", err)
+	}
+
+	return valueNode, nil
+}
+
+func insertManifestChild(parent *yaml.Node, token string, value interface{}) error {
+	valueNode, err := encodeManifestValue(value)
+	if err != nil {
+		return err
+	}
+
+	switch parent.Kind {
+	case yaml.MappingNode:
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: token}
+		parent.Content = append(parent.Content, keyNode, valueNode)
+
+		return nil
+	case yaml.SequenceNode:
+		index := len(parent.Content)
+		if token != "-" {
+			parsed, err := strconv.Atoi(token)
+			if err != nil {
+				return errManifestPathInvalid
+			}
+
+			index = min(max(parsed, 0), len(parent.Content))
+		}
+
+		parent.Content = append(parent.Content[:index], append([]*yaml.Node{valueNode}, parent.Content[index:]...)...)
+
+		return nil
+	case yaml.DocumentNode, yaml.ScalarNode, yaml.AliasNode:
+	}
+
+	return errManifestPathInvalid
+}
+
+// manifestElementName returns the name of an element of an application
+// sub-collection: a process type, route, sidecar or service name.
+func manifestElementName(collection string, element *yaml.Node) string {
+	if element == nil {
+		return ""
+	}
+
+	if element.Kind == yaml.ScalarNode {
+		return element.Value
+	}
+
+	key := map[string]string{
+		"applications": "name",
+		"processes":    "type",
+		"routes":       "route",
+		"sidecars":     "name",
+		"services":     "name",
+	}[collection]
+
+	named := manifestChild(element, key)
+	if named == nil {
+		return ""
+	}
+
+	return named.Value
+}
+
+// resolveManifestDiffEntry names the application and element an entry
+// touches. Elements are looked up in the proposed manifest first and in the
+// reconstructed current manifest for removals.
+func resolveManifestDiffEntry(proposed, current *yaml.Node, entry ManifestDiffEntry) ManifestDiffChange {
+	change := ManifestDiffChange{
+		Op:    entry.Op,
+		Path:  entry.Path,
+		Was:   normalizeManifestDiffValue(entry.Was),
+		Value: normalizeManifestDiffValue(entry.Value),
+	}
+
+	tokens := splitManifestPath(entry.Path)
+	if len(tokens) < 2 || tokens[0] != "applications" {
+		change.Field = strings.Join(tokens, ".")
+
+		return change
+	}
+
+	lookup := func(path []string) *yaml.Node {
+		node := lookupManifestNode(proposed, path)
+		if node == nil || entry.Op == ManifestDiffOpRemove {
+			if fallback := lookupManifestNode(current, path); fallback != nil {
+				return fallback
+			}
+		}
+
+		return node
+	}
+
+	change.Application = manifestElementName("applications", lookup(tokens[:2]))
+	if change.Application == "" {
+		change.Application = "#" + tokens[1]
+	}
+
+	rest := tokens[2:]
+	if len(rest) >= 2 {
+		name := manifestElementName(rest[0], lookup(tokens[:4]))
+
+		switch rest[0] {
+		case "processes":
+			change.Process = name
+		case "routes":
+			change.Route = name
+		case "sidecars":
+			change.Sidecar = name
+		case "services":
+			change.Service = name
+		default:
+			change.Field = strings.Join(rest, ".")
+
+			return change
+		}
+
+		rest = rest[2:]
+	}
+
+	change.Field = strings.Join(rest, ".")
+
+	return change
+}
+
+// lineEditKind classifies one line of a line-based diff.
+type lineEditKind int
+
+const (
+	lineKept lineEditKind = iota
+	lineRemoved
+	lineAdded
+)
+
+type lineEdit struct {
+	kind lineEditKind
+	text string
+	// fromLine and toLine are 1-based positions in the current and proposed
+	// documents; zero on the side the line does not appear in.
+	fromLine int
+	toLine   int
+}
+
+// diffLines computes a minimal line edit script between from and to using
+// the longest common subsequence. Common prefixes and suffixes are trimmed
+// first so typical manifests with a handful of changes stay cheap.
+func diffLines(from, to []string) []lineEdit {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix &&
+		from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	midFrom := from[prefix : len(from)-suffix]
+	midTo := to[prefix : len(to)-suffix]
+
+	// lcs[i][j] is the LCS length of midFrom[i:] and midTo[j:].
+	lcs := make([][]int, len(midFrom)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midTo)+1)
+	}
+
+	for i := len(midFrom) - 1; i >= 0; i-- {
+		for j := len(midTo) - 1; j >= 0; j-- {
+			if midFrom[i] == midTo[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	edits := make([]lineEdit, 0, len(from)+len(to))
+	fromLine, toLine := 1, 1
+
+	keep := func(text string) {
+		edits = append(edits, lineEdit{kind: lineKept, text: text, fromLine: fromLine, toLine: toLine})
+		fromLine++
+		toLine++
+	}
+
+	for _, line := range from[:prefix] {
+		keep(line)
+	}
+
+	i, j := 0, 0
+	for i < len(midFrom) || j < len(midTo) {
+		switch {
+		case i < len(midFrom) && j < len(midTo) && midFrom[i] == midTo[j]:
+			keep(midFrom[i])
+			i++
+			j++
+		case i < len(midFrom) && (j == len(midTo) || lcs[i+1][j] >= lcs[i][j+1]):
+			// Removals are emitted before additions, as diff(1) does.
+			edits = append(edits, lineEdit{kind: lineRemoved, text: midFrom[i], fromLine: fromLine})
+			fromLine++
+			i++
+		default:
+			edits = append(edits, lineEdit{kind: lineAdded, text: midTo[j], toLine: toLine})
+			toLine++
+			j++
+		}
+	}
+
+	for _, line := range from[len(from)-suffix:] {
+		keep(line)
+	}
+
+	return edits
+}
+
+type diffHunk struct {
+	edits     []lineEdit
+	fromStart int
+	fromCount int
+	toStart   int
+	toCount   int
+}
+
+func (h diffHunk) header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.fromStart, h.fromCount, h.toStart, h.toCount)
+}
+
+// buildHunks groups edits into unified diff hunks with contextLines of
+// unchanged lines around every change; hunks whose context overlaps merge.
+func buildHunks(edits []lineEdit, contextLines int) []diffHunk {
+	var hunks []diffHunk
+
+	start, end := -1, -1
+
+	flush := func() {
+		if start < 0 {
+			return
+		}
+
+		hunk := diffHunk{edits: edits[start:end]}
+		for _, edit := range hunk.edits {
+			if edit.kind != lineAdded {
+				hunk.fromCount++
+				if hunk.fromStart == 0 {
+					hunk.fromStart = edit.fromLine
+				}
+			}
+
+			if edit.kind != lineRemoved {
+				hunk.toCount++
+				if hunk.toStart == 0 {
+					hunk.toStart = edit.toLine
+				}
+			}
+		}
+
+		hunks = append(hunks, hunk)
+	}
+
+	for index, edit := range edits {
+		if edit.kind == lineKept {
+			continue
+		}
+
+		from := max(index-contextLines, 0)
+		to := min(index+contextLines+1, len(edits))
+
+		if start >= 0 && from <= end {
+			end = max(end, to)
+
+			continue
+		}
+
+		flush()
+
+		start, end = from, to
+	}
+
+	flush()
+
+	return hunks
+}
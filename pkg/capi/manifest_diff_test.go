@@ -0,0 +1,136 @@
+package capi_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffTestManifest = `applications:
+- name: web
+  memory: 1G
+  env:
+    A: "1"
+  routes:
+  - route: web.example.com
+  - route: web2.example.com
+  processes:
+  - type: web
+    instances: 4
+- name: worker
+  memory: 512M
+`
+
+func diffTestEntries() *capi.ManifestDiff {
+	return &capi.ManifestDiff{Diff: []capi.ManifestDiffEntry{
+		{Op: capi.ManifestDiffOpReplace, Path: "/applications/0/processes/0/instances", Was: float64(2), Value: float64(4)},
+		{Op: capi.ManifestDiffOpAdd, Path: "/applications/0/routes/1", Value: map[string]interface{}{"route": "web2.example.com"}},
+		{Op: capi.ManifestDiffOpRemove, Path: "/applications/0/env/B", Was: "secret"},
+		{Op: capi.ManifestDiffOpAdd, Path: "/applications/1", Value: map[string]interface{}{"name": "worker", "memory": "512M"}},
+	}}
+}
+
+func TestManifestDiffRenderer_ResolvesTargets(t *testing.T) {
+	t.Parallel()
+
+	renderer, err := capi.NewManifestDiffRenderer([]byte(diffTestManifest), diffTestEntries())
+	require.NoError(t, err)
+	require.True(t, renderer.HasChanges())
+
+	changes := renderer.Changes()
+	require.Len(t, changes, 4)
+
+	assert.Equal(t, "web", changes[0].Application)
+	assert.Equal(t, "web", changes[0].Process)
+	assert.Equal(t, "instances", changes[0].Field)
+	assert.Equal(t, int64(2), changes[0].Was)
+	assert.Equal(t, `app "web" process "web": instances`, changes[0].Target())
+
+	assert.Equal(t, "web2.example.com", changes[1].Route)
+	assert.Empty(t, changes[1].Field)
+
+	assert.Equal(t, "web", changes[2].Application)
+	assert.Equal(t, "env.B", changes[2].Field)
+
+	assert.Equal(t, "worker", changes[3].Application)
+	assert.Equal(t, `app "worker"`, changes[3].Target())
+}
+
+func TestManifestDiffRenderer_WriteUnified(t *testing.T) {
+	t.Parallel()
+
+	renderer, err := capi.NewManifestDiffRenderer([]byte(diffTestManifest), diffTestEntries())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+
+	err = renderer.WriteUnified(&out, capi.ManifestDiffRenderOptions{ContextLines: 1})
+	require.NoError(t, err)
+
+	expected := `--- current
++++ proposed
+@@ -5,7 +5,9 @@
+       A: "1"
+-      B: secret
+     routes:
+       - route: web.example.com
++      - route: web2.example.com
+     processes:
+       - type: web
+-        instances: 2
++        instances: 4
++  - name: worker
++    memory: 512M
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestManifestDiffRenderer_WriteSummary(t *testing.T) {
+	t.Parallel()
+
+	renderer, err := capi.NewManifestDiffRenderer([]byte(diffTestManifest), diffTestEntries())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+
+	require.NoError(t, renderer.WriteSummary(&out, capi.ManifestDiffRenderOptions{}))
+	assert.Contains(t, out.String(), "Found 4 difference(s):")
+	assert.Contains(t, out.String(), `~ app "web" process "web": instances: 2 => 4`)
+	assert.Contains(t, out.String(), `- app "web": env.B: "secret"`)
+	assert.NotContains(t, out.String(), "\033[")
+
+	out.Reset()
+	require.NoError(t, renderer.WriteSummary(&out, capi.ManifestDiffRenderOptions{Color: true}))
+	assert.Contains(t, out.String(), "\033[32m")
+}
+
+func TestManifestDiffRenderer_NoChanges(t *testing.T) {
+	t.Parallel()
+
+	renderer, err := capi.NewManifestDiffRenderer([]byte(diffTestManifest), &capi.ManifestDiff{})
+	require.NoError(t, err)
+
+	assert.False(t, renderer.HasChanges())
+	assert.False(t, renderer.Report().HasChanges)
+
+	var out bytes.Buffer
+
+	require.NoError(t, renderer.WriteUnified(&out, capi.ManifestDiffRenderOptions{}))
+	assert.Empty(t, out.String())
+
+	require.NoError(t, renderer.WriteSummary(&out, capi.ManifestDiffRenderOptions{}))
+	assert.Equal(t, "No differences found\n", out.String())
+}
+
+func TestManifestDiffRenderer_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := capi.NewManifestDiffRenderer([]byte(diffTestManifest), nil)
+	require.ErrorIs(t, err, capi.ErrManifestDiffNil)
+
+	_, err = capi.NewManifestDiffRenderer([]byte("applications: [\n"), &capi.ManifestDiff{})
+	require.ErrorIs(t, err, capi.ErrInvalidManifest)
+}
@@ -0,0 +1,380 @@
+package capi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSecretEnvPattern matches environment variable names that commonly
+// carry credentials. It is used when ManifestGenerateOptions.StripSecrets is
+// set and no SecretPatterns are given. AUTH only counts as a whole
+// underscore-separated word at the end of the name (BASIC_AUTH, AUTH), so
+// names such as AUTHOR or OAUTH_ENABLED are kept.
+const DefaultSecretEnvPattern = `(?i)(pass(word|wd)?|secret|token|api[_-]?key|private[_-]?key|credential|access[_-]?key|(^|_)auth$)`
+
+// ManifestGenerateOptions configures GenerateSpaceManifests.
+type ManifestGenerateOptions struct {
+	// Concurrency bounds the number of GenerateManifest calls in flight.
+	// Defaults to 3.
+	Concurrency int
+	// StripSecrets removes environment variables whose names match any of
+	// SecretPatterns (DefaultSecretEnvPattern when empty).
+	StripSecrets   bool
+	SecretPatterns []*regexp.Regexp
+	// IncludeServiceInstances collects a definition for every service
+	// instance in each space.
+	IncludeServiceInstances bool
+}
+
+// GeneratedAppManifest is the manifest CF generated for one application.
+type GeneratedAppManifest struct {
+	AppGUID string
+	AppName string
+	// Manifest is the single-application manifest, after secret stripping.
+	Manifest []byte
+	// StrippedEnv lists the environment variable names removed from Manifest.
+	StrippedEnv []string
+}
+
+// ManifestServiceInstance describes a service instance well enough to
+// recreate it: managed instances by offering and plan, user-provided
+// instances by their URLs. Credentials and parameters are never included.
+type ManifestServiceInstance struct {
+	Name            string            `json:"name"                        yaml:"name"`
+	Type            string            `json:"type"                        yaml:"type"`
+	Offering        string            `json:"offering,omitempty"          yaml:"offering,omitempty"`
+	Plan            string            `json:"plan,omitempty"              yaml:"plan,omitempty"`
+	Tags            []string          `json:"tags,omitempty"              yaml:"tags,omitempty"`
+	SyslogDrainURL  string            `json:"syslog_drain_url,omitempty"  yaml:"syslog_drain_url,omitempty"`
+	RouteServiceURL string            `json:"route_service_url,omitempty" yaml:"route_service_url,omitempty"`
+	Metadata        *ManifestMetadata `json:"metadata,omitempty"          yaml:"metadata,omitempty"`
+}
+
+// SpaceManifest holds the generated manifests of every application in a
+// space, sorted by application name.
+type SpaceManifest struct {
+	SpaceGUID        string
+	SpaceName        string
+	Apps             []GeneratedAppManifest
+	ServiceInstances []ManifestServiceInstance
+}
+
+// Merged returns a single multi-application manifest for the space.
+func (m *SpaceManifest) Merged() ([]byte, error) {
+	applications := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+
+	for _, app := range m.Apps {
+		root, err := parseManifestNode(app.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("app %s: %w", app.AppName, err)
+		}
+
+		if apps := manifestChild(root, "applications"); apps != nil {
+			applications.Content = append(applications.Content, apps.Content...)
+		}
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: "1"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: "applications"},
+		applications,
+	}}
+
+	return encodeManifestNode(root)
+}
+
+// ServiceInstancesYAML renders the service instance definitions as a YAML
+// document with a single service_instances key.
+func (m *SpaceManifest) ServiceInstancesYAML() ([]byte, error) {
+	document := struct {
+		ServiceInstances []ManifestServiceInstance `yaml:"service_instances"`
+	}{ServiceInstances: m.ServiceInstances}
+
+	var out bytes.Buffer
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2) //nolint:mnd // two-space YAML indentation
+
+	err := encoder.Encode(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode service instances: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// GenerateSpaceManifests generates the manifest of every application in the
+// given spaces, calling ManifestsClient.GenerateManifest concurrently. Results
+// are returned in the order of spaceGUIDs.
+func GenerateSpaceManifests(ctx context.Context, client Client, spaceGUIDs []string, opts ManifestGenerateOptions) ([]SpaceManifest, error) {
+	patterns := opts.SecretPatterns
+	if opts.StripSecrets && len(patterns) == 0 {
+		patterns = []*regexp.Regexp{regexp.MustCompile(DefaultSecretEnvPattern)}
+	}
+
+	if !opts.StripSecrets {
+		patterns = nil
+	}
+
+	spaces := make([]SpaceManifest, len(spaceGUIDs))
+
+	var jobs []manifestJob
+
+	for i, spaceGUID := range spaceGUIDs {
+		space, err := client.Spaces().Get(ctx, spaceGUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get space %s: %w", spaceGUID, err)
+		}
+
+		spaces[i] = SpaceManifest{SpaceGUID: space.GUID, SpaceName: space.Name}
+
+		apps, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[App], error) {
+			return client.Apps().List(ctx, params, WithAppSpaceGUIDs(spaceGUID))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list apps in space %s: %w", space.Name, err)
+		}
+
+		sort.Slice(apps, func(a, b int) bool { return apps[a].Name < apps[b].Name })
+
+		spaces[i].Apps = make([]GeneratedAppManifest, len(apps))
+		for j, app := range apps {
+			spaces[i].Apps[j] = GeneratedAppManifest{AppGUID: app.GUID, AppName: app.Name}
+			jobs = append(jobs, manifestJob{space: i, app: j})
+		}
+
+		if opts.IncludeServiceInstances {
+			spaces[i].ServiceInstances, err = collectManifestServiceInstances(ctx, client, spaceGUID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := runManifestJobs(ctx, client, spaces, jobs, opts.Concurrency, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return spaces, nil
+}
+
+type manifestJob struct {
+	space int
+	app   int
+}
+
+// runManifestJobs fills in spaces[job.space].Apps[job.app].Manifest for every
+// job using at most concurrency workers. The first error is returned.
+func runManifestJobs(ctx context.Context, client Client, spaces []SpaceManifest, jobs []manifestJob, concurrency int, patterns []*regexp.Regexp) error {
+	if concurrency <= 0 {
+		concurrency = constants.DefaultConcurrencyLimit
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	semaphore := make(chan struct{}, concurrency)
+
+	for _, job := range jobs {
+		wg.Add(1)
+
+		go func(job manifestJob) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			app := &spaces[job.space].Apps[job.app]
+
+			err := generateAppManifest(ctx, client, app, patterns)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(job)
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+func generateAppManifest(ctx context.Context, client Client, app *GeneratedAppManifest, patterns []*regexp.Regexp) error {
+	manifest, err := client.Manifests().GenerateManifest(ctx, app.AppGUID)
+	if err != nil {
+		return fmt.Errorf("failed to generate manifest for app %s: %w", app.AppName, err)
+	}
+
+	if len(patterns) == 0 {
+		app.Manifest = manifest
+
+		return nil
+	}
+
+	root, err := parseManifestNode(manifest)
+	if err != nil {
+		return fmt.Errorf("app %s: %w", app.AppName, err)
+	}
+
+	if applications := manifestChild(root, "applications"); applications != nil {
+		for _, application := range applications.Content {
+			app.StrippedEnv = append(app.StrippedEnv, stripSecretEnv(application, patterns)...)
+		}
+	}
+
+	app.Manifest, err = encodeManifestNode(root)
+	if err != nil {
+		return fmt.Errorf("app %s: %w", app.AppName, err)
+	}
+
+	return nil
+}
+
+// stripSecretEnv removes env entries whose names match any pattern from an
+// application node and returns the removed names.
+func stripSecretEnv(application *yaml.Node, patterns []*regexp.Regexp) []string {
+	env := manifestChild(application, "env")
+	if env == nil || env.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var (
+		removed []string
+		kept    []*yaml.Node
+	)
+
+	for i := 0; i+1 < len(env.Content); i += 2 {
+		name := env.Content[i].Value
+		if matchesAnyPattern(name, patterns) {
+			removed = append(removed, name)
+
+			continue
+		}
+
+		kept = append(kept, env.Content[i], env.Content[i+1])
+	}
+
+	env.Content = kept
+
+	return removed
+}
+
+func matchesAnyPattern(value string, patterns []*regexp.Regexp) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func encodeManifestNode(root *yaml.Node) ([]byte, error) {
+	var out bytes.Buffer
+
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2) //nolint:mnd // two-space YAML indentation
+
+	err := encoder.Encode(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// collectManifestServiceInstances lists the service instances of a space and
+// resolves managed instances to their offering and plan names through the
+// included service plans and offerings.
+func collectManifestServiceInstances(ctx context.Context, client Client, spaceGUID string) ([]ManifestServiceInstance, error) {
+	plans := make(map[string]ServicePlan)
+	offerings := make(map[string]string)
+
+	instances, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceInstance], error) {
+		page, err := client.ServiceInstances().List(ctx, params,
+			WithServiceInstanceSpaceGUIDs(spaceGUID),
+			ServiceInstanceIncludeServicePlanServiceOffering,
+		)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := ServiceInstanceIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, plan := range included.ServicePlans {
+			plans[plan.GUID] = plan
+		}
+
+		for _, offering := range included.ServiceOfferings {
+			offerings[offering.GUID] = offering.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service instances: %w", err)
+	}
+
+	definitions := make([]ManifestServiceInstance, 0, len(instances))
+
+	for _, instance := range instances {
+		definition := ManifestServiceInstance{
+			Name: instance.Name,
+			Type: instance.Type,
+			Tags: instance.Tags,
+		}
+
+		if instance.Relationships.ServicePlan != nil && instance.Relationships.ServicePlan.Data != nil {
+			if plan, ok := plans[instance.Relationships.ServicePlan.Data.GUID]; ok {
+				definition.Plan = plan.Name
+				if plan.Relationships.ServiceOffering.Data != nil {
+					definition.Offering = offerings[plan.Relationships.ServiceOffering.Data.GUID]
+				}
+			}
+		}
+
+		if instance.SyslogDrainURL != nil {
+			definition.SyslogDrainURL = *instance.SyslogDrainURL
+		}
+
+		if instance.RouteServiceURL != nil {
+			definition.RouteServiceURL = *instance.RouteServiceURL
+		}
+
+		if instance.Metadata != nil && (len(instance.Metadata.Labels) > 0 || len(instance.Metadata.Annotations) > 0) {
+			definition.Metadata = &ManifestMetadata{
+				Labels:      instance.Metadata.Labels,
+				Annotations: instance.Metadata.Annotations,
+			}
+		}
+
+		definitions = append(definitions, definition)
+	}
+
+	sort.Slice(definitions, func(a, b int) bool { return definitions[a].Name < definitions[b].Name })
+
+	return definitions, nil
+}
@@ -0,0 +1,163 @@
+package capi_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newManifestGenerateStub() (*stubClient, *stubManifests) {
+	manifests := &stubManifests{manifests: map[string]string{
+		"app-web": `applications:
+- name: web
+  memory: 1G
+  env:
+    LOG_LEVEL: info
+    DB_PASSWORD: hunter2
+    STRIPE_API_KEY: sk_live
+`,
+		"app-api": `applications:
+- name: api
+  instances: 2
+`,
+	}}
+
+	client := &stubClient{
+		spaces: &stubSpaces{spaces: map[string]*capi.Space{
+			"space-1": {Resource: capi.Resource{GUID: "space-1"}, Name: "staging"},
+		}},
+		apps: &stubApps{apps: []capi.App{
+			{Resource: capi.Resource{GUID: "app-web"}, Name: "web"},
+			{Resource: capi.Resource{GUID: "app-api"}, Name: "api"},
+		}},
+		manifests: manifests,
+	}
+
+	return client, manifests
+}
+
+func TestGenerateSpaceManifests_MergesSortedApps(t *testing.T) {
+	t.Parallel()
+
+	client, manifests := newManifestGenerateStub()
+
+	spaces, err := capi.GenerateSpaceManifests(context.Background(), client, []string{"space-1"}, capi.ManifestGenerateOptions{})
+	require.NoError(t, err)
+	require.Len(t, spaces, 1)
+
+	space := spaces[0]
+	assert.Equal(t, "staging", space.SpaceName)
+	require.Len(t, space.Apps, 2)
+	assert.Equal(t, "api", space.Apps[0].AppName)
+	assert.Equal(t, "web", space.Apps[1].AppName)
+	assert.ElementsMatch(t, []string{"app-web", "app-api"}, manifests.generated)
+	assert.Nil(t, space.ServiceInstances)
+
+	merged, err := space.Merged()
+	require.NoError(t, err)
+	assert.Equal(t, `version: 1
+applications:
+  - name: api
+    instances: 2
+  - name: web
+    memory: 1G
+    env:
+      LOG_LEVEL: info
+      DB_PASSWORD: hunter2
+      STRIPE_API_KEY: sk_live
+`, string(merged))
+}
+
+func TestGenerateSpaceManifests_StripSecrets(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newManifestGenerateStub()
+
+	spaces, err := capi.GenerateSpaceManifests(context.Background(), client, []string{"space-1"},
+		capi.ManifestGenerateOptions{StripSecrets: true})
+	require.NoError(t, err)
+
+	web := spaces[0].Apps[1]
+	assert.Equal(t, []string{"DB_PASSWORD", "STRIPE_API_KEY"}, web.StrippedEnv)
+	assert.Contains(t, string(web.Manifest), "LOG_LEVEL: info")
+	assert.NotContains(t, string(web.Manifest), "hunter2")
+
+	spaces, err = capi.GenerateSpaceManifests(context.Background(), client, []string{"space-1"},
+		capi.ManifestGenerateOptions{StripSecrets: true, SecretPatterns: []*regexp.Regexp{regexp.MustCompile(`^LOG_`)}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"LOG_LEVEL"}, spaces[0].Apps[1].StrippedEnv)
+}
+
+func TestDefaultSecretEnvPattern(t *testing.T) {
+	t.Parallel()
+
+	pattern := regexp.MustCompile(capi.DefaultSecretEnvPattern)
+
+	for _, name := range []string{"DB_PASSWORD", "CLIENT_SECRET", "AUTH_TOKEN", "BASIC_AUTH", "AUTH", "stripe_api_key"} {
+		assert.True(t, pattern.MatchString(name), name)
+	}
+
+	for _, name := range []string{"AUTHOR", "OAUTH_ENABLED", "AUTH_ENABLED", "LOG_LEVEL", "AUTHORITY_URL"} {
+		assert.False(t, pattern.MatchString(name), name)
+	}
+}
+
+func TestGenerateSpaceManifests_IncludesServiceInstances(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newManifestGenerateStub()
+	drain := "syslog://logs.example.com"
+	client.serviceInstances = &stubServiceInstances{
+		instances: []capi.ServiceInstance{
+			{
+				Name: "db",
+				Type: "managed",
+				Tags: []string{"postgres"},
+				Relationships: capi.ServiceInstanceRelationships{
+					ServicePlan: &capi.Relationship{Data: &capi.RelationshipData{GUID: "plan-1"}},
+				},
+			},
+			{Name: "drain", Type: "user-provided", SyslogDrainURL: &drain},
+		},
+		included: map[string][]interface{}{
+			"service_plans": {capi.ServicePlan{
+				Resource: capi.Resource{GUID: "plan-1"},
+				Name:     "small",
+				Relationships: capi.ServicePlanRelationships{
+					ServiceOffering: capi.Relationship{Data: &capi.RelationshipData{GUID: "offering-1"}},
+				},
+			}},
+			"service_offerings": {capi.ServiceOffering{Resource: capi.Resource{GUID: "offering-1"}, Name: "postgres"}},
+		},
+	}
+
+	spaces, err := capi.GenerateSpaceManifests(context.Background(), client, []string{"space-1"},
+		capi.ManifestGenerateOptions{IncludeServiceInstances: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, []capi.ManifestServiceInstance{
+		{Name: "db", Type: "managed", Offering: "postgres", Plan: "small", Tags: []string{"postgres"}},
+		{Name: "drain", Type: "user-provided", SyslogDrainURL: drain},
+	}, spaces[0].ServiceInstances)
+
+	services, err := spaces[0].ServiceInstancesYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(services), "service_instances:\n  - name: db\n")
+}
+
+func TestGenerateSpaceManifests_PropagatesErrors(t *testing.T) {
+	t.Parallel()
+
+	client, manifests := newManifestGenerateStub()
+	delete(manifests.manifests, "app-api")
+
+	_, err := capi.GenerateSpaceManifests(context.Background(), client, []string{"space-1"}, capi.ManifestGenerateOptions{})
+	require.ErrorIs(t, err, capi.ErrNotFound)
+
+	_, err = capi.GenerateSpaceManifests(context.Background(), client, []string{"missing"}, capi.ManifestGenerateOptions{})
+	require.ErrorIs(t, err, capi.ErrNotFound)
+}
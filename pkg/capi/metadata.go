@@ -0,0 +1,170 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrUnsupportedMetadataResource = errors.New("resource type has no metadata")
+	ErrEmptyMetadataPatch          = errors.New("metadata patch changes nothing")
+	ErrConflictingMetadataPatch    = errors.New("metadata patch both sets and removes a key")
+)
+
+// metadataResourcePaths maps the resource types that carry labels and
+// annotations to their collection path under /v3.
+var metadataResourcePaths = map[string]string{
+	"app":                        "apps",
+	"build":                      "builds",
+	"buildpack":                  "buildpacks",
+	"deployment":                 "deployments",
+	"domain":                     "domains",
+	"droplet":                    "droplets",
+	"isolation_segment":          "isolation_segments",
+	"organization":               "organizations",
+	"package":                    "packages",
+	"process":                    "processes",
+	"revision":                   "revisions",
+	"route":                      "routes",
+	"service_broker":             "service_brokers",
+	"service_credential_binding": "service_credential_bindings",
+	"service_instance":           "service_instances",
+	"service_offering":           "service_offerings",
+	"service_plan":               "service_plans",
+	"service_route_binding":      "service_route_bindings",
+	"space":                      "spaces",
+	"stack":                      "stacks",
+	"task":                       "tasks",
+	"user":                       "users",
+}
+
+// MetadataResourceTypes lists the resource types a MetadataClient accepts,
+// in the singular form used by batch operations ("app", "service_instance").
+func MetadataResourceTypes() []string {
+	types := make([]string, 0, len(metadataResourcePaths))
+	for resourceType := range metadataResourcePaths {
+		types = append(types, resourceType)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// MetadataResourcePath returns the /v3 collection path of a resource type,
+// given either in its singular form or as the collection name itself
+// ("app" or "apps").
+func MetadataResourcePath(resourceType string) (string, error) {
+	if path, ok := metadataResourcePaths[resourceType]; ok {
+		return path, nil
+	}
+
+	for _, path := range metadataResourcePaths {
+		if path == resourceType {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedMetadataResource, resourceType)
+}
+
+// MetadataPatch changes some labels and annotations of a resource and leaves
+// the others untouched. Removed keys are sent as null, which a Metadata map
+// cannot express.
+type MetadataPatch struct {
+	SetLabels         map[string]string
+	RemoveLabels      []string
+	SetAnnotations    map[string]string
+	RemoveAnnotations []string
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p MetadataPatch) IsEmpty() bool {
+	return len(p.SetLabels) == 0 && len(p.RemoveLabels) == 0 &&
+		len(p.SetAnnotations) == 0 && len(p.RemoveAnnotations) == 0
+}
+
+// Validate rejects empty patches and patches that set and remove the same
+// key.
+func (p MetadataPatch) Validate() error {
+	if p.IsEmpty() {
+		return ErrEmptyMetadataPatch
+	}
+
+	for _, key := range p.RemoveLabels {
+		if _, ok := p.SetLabels[key]; ok {
+			return fmt.Errorf("%w: label %s", ErrConflictingMetadataPatch, key)
+		}
+	}
+
+	for _, key := range p.RemoveAnnotations {
+		if _, ok := p.SetAnnotations[key]; ok {
+			return fmt.Errorf("%w: annotation %s", ErrConflictingMetadataPatch, key)
+		}
+	}
+
+	return nil
+}
+
+// MetadataPatchRequest is the PATCH body of a MetadataPatch.
+type MetadataPatchRequest struct {
+	Metadata MetadataPatchBody `json:"metadata"`
+}
+
+// MetadataPatchBody holds the changed keys; a nil value removes the key.
+type MetadataPatchBody struct {
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
+}
+
+// Request builds the PATCH body of the patch.
+func (p MetadataPatch) Request() *MetadataPatchRequest {
+	return &MetadataPatchRequest{Metadata: MetadataPatchBody{
+		Labels:      metadataPatchValues(p.SetLabels, p.RemoveLabels),
+		Annotations: metadataPatchValues(p.SetAnnotations, p.RemoveAnnotations),
+	}}
+}
+
+func metadataPatchValues(set map[string]string, remove []string) map[string]*string {
+	if len(set) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	values := make(map[string]*string, len(set)+len(remove))
+
+	for key, value := range set {
+		values[key] = &value
+	}
+
+	for _, key := range remove {
+		values[key] = nil
+	}
+
+	return values
+}
+
+// MetadataResource is the part of any resource a MetadataClient lists: its
+// GUID, name (empty for resources without one, like routes) and metadata.
+type MetadataResource struct {
+	Resource
+
+	Name     string    `json:"name,omitempty" yaml:"name,omitempty"`
+	Metadata *Metadata `json:"metadata"       yaml:"metadata"`
+}
+
+// MetadataClient reads and changes the labels and annotations of any
+// resource type listed by MetadataResourceTypes, without going through each
+// resource's own update request.
+type MetadataClient interface {
+	// Get returns the metadata of a resource; its maps are never nil.
+	Get(ctx context.Context, resourceType, guid string) (*Metadata, error)
+	// Patch applies patch with PATCH /v3/{resources}/{guid} and returns the
+	// resulting metadata.
+	Patch(ctx context.Context, resourceType, guid string, patch MetadataPatch) (*Metadata, error)
+	// List lists resources of a type, typically filtered with a label
+	// selector or by name.
+	List(ctx context.Context, resourceType string, params *QueryParams) (*ListResponse[MetadataResource], error)
+}
@@ -0,0 +1,52 @@
+package capi_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataPatch_Request(t *testing.T) {
+	t.Parallel()
+
+	patch := capi.MetadataPatch{
+		SetLabels:         map[string]string{"team": "payments"},
+		RemoveLabels:      []string{"tier"},
+		RemoveAnnotations: []string{"contact"},
+	}
+	require.NoError(t, patch.Validate())
+
+	body, err := json.Marshal(patch.Request())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata": {
+		"labels": {"team": "payments", "tier": null},
+		"annotations": {"contact": null}
+	}}`, string(body))
+}
+
+func TestMetadataPatch_Validate(t *testing.T) {
+	t.Parallel()
+
+	require.ErrorIs(t, capi.MetadataPatch{}.Validate(), capi.ErrEmptyMetadataPatch)
+	require.ErrorIs(t, capi.MetadataPatch{
+		SetAnnotations:    map[string]string{"contact": "a@example.com"},
+		RemoveAnnotations: []string{"contact"},
+	}.Validate(), capi.ErrConflictingMetadataPatch)
+}
+
+func TestMetadataResourcePath(t *testing.T) {
+	t.Parallel()
+
+	for _, resourceType := range []string{"service_instance", "service_instances"} {
+		path, err := capi.MetadataResourcePath(resourceType)
+		require.NoError(t, err)
+		assert.Equal(t, "service_instances", path)
+	}
+
+	_, err := capi.MetadataResourcePath("security_group")
+	require.ErrorIs(t, err, capi.ErrUnsupportedMetadataResource)
+	assert.Contains(t, capi.MetadataResourceTypes(), "process")
+}
@@ -338,3 +338,47 @@ type PageResult[T any] struct {
 	Total   int
 	Err     error
 }
+
+// CollectAllPages calls list with successive page numbers until the last page
+// and returns the resources of every page in order. Page and PerPage are set
+// on a shallow copy of params, so params itself keeps its paging fields, but
+// its Filters, Fields and Include are shared with the copy and list must not
+// modify them. When params is nil or leaves PerPage unset, StandardPageSize
+// items are requested per page. It is the building block for workflows that need a
+// complete collection from a typed List method, e.g.
+//
+//	apps, err := capi.CollectAllPages(ctx, nil,
+//		func(ctx context.Context, params *capi.QueryParams) (*capi.ListResponse[capi.App], error) {
+//			return client.Apps().List(ctx, params, capi.WithAppSpaceGUIDs(spaceGUID))
+//		})
+func CollectAllPages[T any](
+	ctx context.Context,
+	params *QueryParams,
+	list func(ctx context.Context, params *QueryParams) (*ListResponse[T], error),
+) ([]T, error) {
+	pageParams := QueryParams{}
+	if params != nil {
+		pageParams = *params
+	}
+
+	if pageParams.PerPage == 0 {
+		pageParams.PerPage = constants.StandardPageSize
+	}
+
+	var all []T
+
+	for page := 1; ; page++ {
+		pageParams.Page = page
+
+		response, err := list(ctx, &pageParams)
+		if err != nil {
+			return nil, fmt.Errorf("fetching page %d: %w", page, err)
+		}
+
+		all = append(all, response.Resources...)
+
+		if len(response.Resources) == 0 || response.Pagination.Next == nil || response.Pagination.Next.Href == "" {
+			return all, nil
+		}
+	}
+}
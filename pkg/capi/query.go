@@ -127,6 +127,14 @@ func (q *QueryParams) WithLabelSelector(selector string) *QueryParams {
 	return q
 }
 
+// WithSelector sets the label selector from a typed LabelSelector; callers
+// building one from input should Validate it first.
+func (q *QueryParams) WithSelector(selector *LabelSelector) *QueryParams {
+	q.LabelSelector = selector.String()
+
+	return q
+}
+
 // WithInclude adds include parameters, skipping values already present.
 // Dedup semantics match appendInclude in query_options.go.
 func (q *QueryParams) WithInclude(includes ...string) *QueryParams {
@@ -3,6 +3,9 @@ package capi
 import (
 	"context"
 	"io"
+	"time"
+
+	"golang.org/x/crypto/ssh"
 )
 
 // AppsClient defines operations for apps.
@@ -518,6 +521,50 @@ type ResourceMatchesClient interface {
 	Create(ctx context.Context, request *ResourceMatchesRequest) (*ResourceMatches, error)
 }
 
+// LogCacheClient reads envelopes from Log Cache, whose URL is discovered
+// from the log_cache link of the API root (falling back to the
+// log-cache.<system domain> convention). Source IDs are app GUIDs for app
+// logs and container metrics.
+type LogCacheClient interface {
+	// Read issues GET /api/v1/read/{sourceID}. opts may be nil.
+	Read(ctx context.Context, sourceID string, opts *LogCacheReadOptions) ([]LogCacheEnvelope, error)
+	// Meta issues GET /api/v1/meta and returns the cached source IDs.
+	Meta(ctx context.Context) (map[string]LogCacheMetaInfo, error)
+	// Query evaluates a PromQL expression at a single instant
+	// (GET /api/v1/query); a zero at means now.
+	Query(ctx context.Context, query string, at time.Time) (*PromQLResult, error)
+	// QueryRange evaluates a PromQL expression over [start, end] every step
+	// (GET /api/v1/query_range).
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*PromQLResult, error)
+}
+
+// LogStreamClient follows envelopes live through the Reverse Log Proxy
+// gateway advertised as the log_stream link of the API root.
+type LogStreamClient interface {
+	// Stream issues GET /v2/read and delivers envelopes as server-sent
+	// events arrive, reconnecting when the connection drops, until ctx is
+	// canceled; the channel is then closed. It returns
+	// ErrLogStreamUnavailable when the gateway is not advertised. opts may
+	// be nil.
+	Stream(ctx context.Context, opts *LogStreamOptions) (<-chan LogCacheEnvelope, error)
+}
+
+// SSHClient connects to application instances through the Diego SSH proxy
+// advertised as the app_ssh link of the API root.
+type SSHClient interface {
+	// GetEndpoint returns the proxy address and host key fingerprint from
+	// the API root, falling back to the links of /v3/info. It returns
+	// ErrSSHUnavailable when neither advertises the proxy.
+	GetEndpoint(ctx context.Context) (*SSHEndpoint, error)
+	// GetCode obtains a one-time authorization code for the proxy's OAuth
+	// client from UAA (GET /oauth/authorize).
+	GetCode(ctx context.Context) (string, error)
+	// Dial connects to the proxy as SSHUser(processGUID, index), using a
+	// one-time code as the password, and rejects any host key that does not
+	// match the advertised fingerprint.
+	Dial(ctx context.Context, processGUID string, index int) (*ssh.Client, error)
+}
+
 // RoutingClient provides access to the CF Routing API (/routing/v1/).
 // The Routing API is a separate microservice from the Cloud Controller (CF API v3),
 // but typically shares the same base URL and UAA authentication in most CF deployments.
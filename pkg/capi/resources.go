@@ -1557,13 +1557,31 @@ type AppLogs struct {
 	Messages []LogMessage `json:"messages" yaml:"messages"`
 }
 
-// LogCacheEnvelope represents a log cache response envelope.
+// LogCacheEnvelopeType is a Loggregator v2 envelope variant, as accepted by
+// the Log Cache envelope_types query parameter.
+type LogCacheEnvelopeType string
+
+// Loggregator v2 envelope variants.
+const (
+	LogCacheEnvelopeTypeLog     LogCacheEnvelopeType = "LOG"
+	LogCacheEnvelopeTypeCounter LogCacheEnvelopeType = "COUNTER"
+	LogCacheEnvelopeTypeGauge   LogCacheEnvelopeType = "GAUGE"
+	LogCacheEnvelopeTypeTimer   LogCacheEnvelopeType = "TIMER"
+	LogCacheEnvelopeTypeEvent   LogCacheEnvelopeType = "EVENT"
+)
+
+// LogCacheEnvelope represents a log cache response envelope. Exactly one of
+// Log, Counter, Gauge, Timer and Event is set.
 type LogCacheEnvelope struct {
-	Timestamp  string               `json:"timestamp"     yaml:"timestamp"`
-	SourceID   string               `json:"source_id"     yaml:"source_id"`
-	InstanceID string               `json:"instance_id"   yaml:"instance_id"`
-	Tags       map[string]string    `json:"tags"          yaml:"tags"`
-	Log        *LogCacheLogEnvelope `json:"log,omitempty" yaml:"log,omitempty"`
+	Timestamp  string                   `json:"timestamp"         yaml:"timestamp"`
+	SourceID   string                   `json:"source_id"         yaml:"source_id"`
+	InstanceID string                   `json:"instance_id"       yaml:"instance_id"`
+	Tags       map[string]string        `json:"tags"              yaml:"tags"`
+	Log        *LogCacheLogEnvelope     `json:"log,omitempty"     yaml:"log,omitempty"`
+	Counter    *LogCacheCounterEnvelope `json:"counter,omitempty" yaml:"counter,omitempty"`
+	Gauge      *LogCacheGaugeEnvelope   `json:"gauge,omitempty"   yaml:"gauge,omitempty"`
+	Timer      *LogCacheTimerEnvelope   `json:"timer,omitempty"   yaml:"timer,omitempty"`
+	Event      *LogCacheEventEnvelope   `json:"event,omitempty"   yaml:"event,omitempty"`
 }
 
 // LogCacheLogEnvelope represents the log content within a log cache envelope.
@@ -1572,6 +1590,128 @@ type LogCacheLogEnvelope struct {
 	Type    string `json:"type"    yaml:"type"`
 }
 
+// LogCacheCounterEnvelope is a monotonically increasing counter, such as
+// gorouter request counts. 64-bit values are transmitted as JSON strings.
+type LogCacheCounterEnvelope struct {
+	Name  string `json:"name"                   yaml:"name"`
+	Delta uint64 `json:"delta,string,omitempty" yaml:"delta,omitempty"`
+	Total uint64 `json:"total,string,omitempty" yaml:"total,omitempty"`
+}
+
+// LogCacheGaugeEnvelope carries point-in-time values keyed by metric name,
+// such as container cpu, memory and disk.
+type LogCacheGaugeEnvelope struct {
+	Metrics map[string]LogCacheGaugeValue `json:"metrics" yaml:"metrics"`
+}
+
+// LogCacheGaugeValue is a single gauge metric.
+type LogCacheGaugeValue struct {
+	Unit  string  `json:"unit"  yaml:"unit"`
+	Value float64 `json:"value" yaml:"value"`
+}
+
+// LogCacheTimerEnvelope measures an interval, such as the latency of an HTTP
+// request through gorouter. Start and Stop are Unix nanoseconds.
+type LogCacheTimerEnvelope struct {
+	Name  string `json:"name"                   yaml:"name"`
+	Start int64  `json:"start,string,omitempty" yaml:"start,omitempty"`
+	Stop  int64  `json:"stop,string,omitempty"  yaml:"stop,omitempty"`
+}
+
+// LogCacheEventEnvelope is a titled event, such as an app crash.
+type LogCacheEventEnvelope struct {
+	Title string `json:"title" yaml:"title"`
+	Body  string `json:"body"  yaml:"body"`
+}
+
+// LogCacheReadOptions configures LogCacheClient.Read. Zero values are left to
+// Log Cache's defaults.
+type LogCacheReadOptions struct {
+	// EnvelopeTypes restricts the variants returned; empty means all.
+	EnvelopeTypes []LogCacheEnvelopeType
+	// Start is inclusive; zero reads from the oldest cached envelope.
+	Start time.Time
+	// End is exclusive; zero reads up to now.
+	End time.Time
+	// Limit caps the number of envelopes (Log Cache allows at most 1000);
+	// zero uses the server default of 100.
+	Limit int
+	// Descending returns the newest envelopes first.
+	Descending bool
+}
+
+// LogCacheMetaInfo describes what Log Cache holds for one source ID.
+// 64-bit values are transmitted as JSON strings.
+type LogCacheMetaInfo struct {
+	Count           int64 `json:"count,string,omitempty"            yaml:"count,omitempty"`
+	Expired         int64 `json:"expired,string,omitempty"          yaml:"expired,omitempty"`
+	OldestTimestamp int64 `json:"oldest_timestamp,string,omitempty" yaml:"oldest_timestamp,omitempty"`
+	NewestTimestamp int64 `json:"newest_timestamp,string,omitempty" yaml:"newest_timestamp,omitempty"`
+}
+
+// LogCacheMetaResponse is the body of GET /api/v1/meta.
+type LogCacheMetaResponse struct {
+	Meta map[string]LogCacheMetaInfo `json:"meta" yaml:"meta"`
+}
+
+// PromQL result types returned by LogCacheClient.Query and QueryRange.
+const (
+	PromQLResultTypeVector = "vector"
+	PromQLResultTypeMatrix = "matrix"
+	PromQLResultTypeScalar = "scalar"
+)
+
+// PromQLResult is the data of a Log Cache PromQL response. Vector is set for
+// instant queries, Matrix for range queries and Scalar for scalar
+// expressions, according to ResultType.
+type PromQLResult struct {
+	ResultType string         `json:"result_type"      yaml:"result_type"`
+	Vector     []PromQLSample `json:"vector,omitempty" yaml:"vector,omitempty"`
+	Matrix     []PromQLSeries `json:"matrix,omitempty" yaml:"matrix,omitempty"`
+	Scalar     *PromQLPoint   `json:"scalar,omitempty" yaml:"scalar,omitempty"`
+}
+
+// PromQLSample is one labelled value of an instant vector.
+type PromQLSample struct {
+	Metric map[string]string `json:"metric" yaml:"metric"`
+	Point  PromQLPoint       `json:"point"  yaml:"point"`
+}
+
+// PromQLSeries is one labelled series of a range matrix.
+type PromQLSeries struct {
+	Metric map[string]string `json:"metric" yaml:"metric"`
+	Points []PromQLPoint     `json:"points" yaml:"points"`
+}
+
+// PromQLPoint is a timestamped value. On the wire it is the Prometheus pair
+// [unix_seconds, "value"]; it is encoded as {"time", "value"}.
+type PromQLPoint struct {
+	Time  time.Time `json:"time"  yaml:"time"`
+	Value float64   `json:"value" yaml:"value"`
+}
+
+// LogStreamOptions selects what LogStreamClient.Stream follows.
+type LogStreamOptions struct {
+	// SourceIDs restricts the stream to these sources (app GUIDs for apps).
+	// When empty, every source the token may read is streamed.
+	SourceIDs []string
+	// EnvelopeTypes defaults to LogCacheEnvelopeTypeLog.
+	EnvelopeTypes []LogCacheEnvelopeType
+	// ShardID lets consumers that share it split the envelopes between them
+	// instead of each receiving every envelope. A random shard is used when
+	// empty.
+	ShardID string
+	// ReconnectDelay is the delay before the first reconnect after the stream
+	// drops; it doubles on every failed attempt up to a minute. Defaults to
+	// one second.
+	ReconnectDelay time.Duration
+	// DisableResume skips backfilling the envelopes missed while
+	// disconnected from Log Cache.
+	DisableResume bool
+	// OnError, if set, receives the errors that cause a reconnect.
+	OnError func(error) `json:"-" yaml:"-"`
+}
+
 // LogCacheResponse represents the response from log cache API.
 type LogCacheResponse struct {
 	Envelopes LogCacheEnvelopesWrapper `json:"envelopes" yaml:"envelopes"`
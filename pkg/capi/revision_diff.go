@@ -0,0 +1,275 @@
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RevisionDiffMask replaces environment variable values in a RevisionDiff
+// unless RevisionDiffOptions.ShowValues is set.
+const RevisionDiffMask = "****"
+
+// Sections of a RevisionDiff, in the order changes are reported.
+const (
+	RevisionDiffSectionDroplet     = "droplet"
+	RevisionDiffSectionBuildpacks  = "buildpacks"
+	RevisionDiffSectionProcesses   = "processes"
+	RevisionDiffSectionSidecars    = "sidecars"
+	RevisionDiffSectionEnv         = "env"
+	RevisionDiffSectionLabels      = "labels"
+	RevisionDiffSectionAnnotations = "annotations"
+)
+
+// RevisionChangeKind classifies a RevisionChange.
+type RevisionChangeKind string
+
+// Kinds of revision changes.
+const (
+	RevisionChangeAdded   RevisionChangeKind = "added"
+	RevisionChangeRemoved RevisionChangeKind = "removed"
+	RevisionChangeChanged RevisionChangeKind = "changed"
+)
+
+// RevisionChange is a single difference between two revisions.
+type RevisionChange struct {
+	Section string             `json:"section"        yaml:"section"`
+	Key     string             `json:"key"            yaml:"key"`
+	Kind    RevisionChangeKind `json:"kind"           yaml:"kind"`
+	From    string             `json:"from,omitempty" yaml:"from,omitempty"`
+	To      string             `json:"to,omitempty"   yaml:"to,omitempty"`
+}
+
+// RevisionDiffSide identifies one of the compared revisions.
+type RevisionDiffSide struct {
+	GUID    string `json:"guid"    yaml:"guid"`
+	Version int    `json:"version" yaml:"version"`
+}
+
+// RevisionDiff lists the differences between two revisions of an app.
+type RevisionDiff struct {
+	From    RevisionDiffSide `json:"from"    yaml:"from"`
+	To      RevisionDiffSide `json:"to"      yaml:"to"`
+	Changes []RevisionChange `json:"changes" yaml:"changes"`
+}
+
+// HasChanges reports whether the revisions differ.
+func (d *RevisionDiff) HasChanges() bool {
+	return len(d.Changes) > 0
+}
+
+// RevisionDiffOptions configures CompareRevisions and DiffRevisions.
+type RevisionDiffOptions struct {
+	// ShowValues reports environment variable values instead of
+	// RevisionDiffMask.
+	ShowValues bool
+}
+
+// RevisionState is everything a RevisionDiff compares for one revision.
+type RevisionState struct {
+	Revision *Revision
+	// Env holds the revision's environment variables.
+	Env map[string]interface{}
+	// Buildpacks are those of the revision's droplet; nil when the droplet
+	// no longer exists.
+	Buildpacks []DetectedBuildpack
+}
+
+// LoadRevisionState fetches a revision together with its environment
+// variables and the buildpacks of its droplet.
+func LoadRevisionState(ctx context.Context, client Client, revisionGUID string) (*RevisionState, error) {
+	revision, err := client.Revisions().Get(ctx, revisionGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision %s: %w", revisionGUID, err)
+	}
+
+	env, err := client.Revisions().GetEnvironmentVariables(ctx, revisionGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment variables of revision %s: %w", revisionGUID, err)
+	}
+
+	state := &RevisionState{Revision: revision, Env: env}
+
+	if revision.Droplet.GUID == "" {
+		return state, nil
+	}
+
+	droplet, err := client.Droplets().Get(ctx, revision.Droplet.GUID)
+
+	switch {
+	case err == nil:
+		state.Buildpacks = droplet.Buildpacks
+	case !IsNotFound(err):
+		return nil, fmt.Errorf("failed to get droplet %s: %w", revision.Droplet.GUID, err)
+	}
+
+	return state, nil
+}
+
+// DiffRevisions loads two revisions and compares them.
+func DiffRevisions(ctx context.Context, client Client, fromGUID, toGUID string, opts RevisionDiffOptions) (*RevisionDiff, error) {
+	from, err := LoadRevisionState(ctx, client, fromGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := LoadRevisionState(ctx, client, toGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return CompareRevisions(from, to, opts), nil
+}
+
+// CompareRevisions reports the droplet, buildpack, process command, sidecar,
+// environment and metadata differences between two revisions.
+func CompareRevisions(from, to *RevisionState, opts RevisionDiffOptions) *RevisionDiff {
+	diff := &RevisionDiff{
+		From: RevisionDiffSide{GUID: from.Revision.GUID, Version: from.Revision.Version},
+		To:   RevisionDiffSide{GUID: to.Revision.GUID, Version: to.Revision.Version},
+	}
+
+	if from.Revision.Droplet.GUID != to.Revision.Droplet.GUID {
+		diff.Changes = append(diff.Changes, RevisionChange{
+			Section: RevisionDiffSectionDroplet,
+			Key:     "guid",
+			Kind:    RevisionChangeChanged,
+			From:    from.Revision.Droplet.GUID,
+			To:      to.Revision.Droplet.GUID,
+		})
+	}
+
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionBuildpacks, buildpackVersions(from.Buildpacks), buildpackVersions(to.Buildpacks), nil)...)
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionProcesses, processCommands(from.Revision), processCommands(to.Revision), nil)...)
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionSidecars, sidecarSummaries(from.Revision), sidecarSummaries(to.Revision), nil)...)
+
+	mask := func(value string) string { return value }
+	if !opts.ShowValues {
+		mask = func(string) string { return RevisionDiffMask }
+	}
+
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionEnv, envStrings(from.Env), envStrings(to.Env), mask)...)
+
+	fromMetadata, toMetadata := metadataOrEmpty(from.Revision.Metadata), metadataOrEmpty(to.Revision.Metadata)
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionLabels, fromMetadata.Labels, toMetadata.Labels, nil)...)
+	diff.Changes = append(diff.Changes, diffStringMaps(RevisionDiffSectionAnnotations, fromMetadata.Annotations, toMetadata.Annotations, nil)...)
+
+	return diff
+}
+
+// diffStringMaps compares two maps key by key in sorted order. display, when
+// set, transforms the values written to the change.
+func diffStringMaps(section string, from, to map[string]string, display func(string) string) []RevisionChange {
+	if display == nil {
+		display = func(value string) string { return value }
+	}
+
+	keys := slices.Collect(maps.Keys(from))
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	var changes []RevisionChange
+
+	for _, key := range keys {
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+
+		switch {
+		case !inFrom:
+			changes = append(changes, RevisionChange{Section: section, Key: key, Kind: RevisionChangeAdded, To: display(toValue)})
+		case !inTo:
+			changes = append(changes, RevisionChange{Section: section, Key: key, Kind: RevisionChangeRemoved, From: display(fromValue)})
+		case fromValue != toValue:
+			changes = append(changes, RevisionChange{Section: section, Key: key, Kind: RevisionChangeChanged, From: display(fromValue), To: display(toValue)})
+		}
+	}
+
+	return changes
+}
+
+func buildpackVersions(buildpacks []DetectedBuildpack) map[string]string {
+	versions := make(map[string]string, len(buildpacks))
+
+	for _, buildpack := range buildpacks {
+		name := buildpack.Name
+		if buildpack.BuildpackName != nil && *buildpack.BuildpackName != "" {
+			name = *buildpack.BuildpackName
+		}
+
+		version := ""
+		if buildpack.Version != nil {
+			version = *buildpack.Version
+		}
+
+		versions[name] = version
+	}
+
+	return versions
+}
+
+func processCommands(revision *Revision) map[string]string {
+	commands := make(map[string]string, len(revision.Processes))
+
+	for processType, process := range revision.Processes {
+		command := ""
+		if process.Command != nil {
+			command = *process.Command
+		}
+
+		commands[processType] = command
+	}
+
+	return commands
+}
+
+func sidecarSummaries(revision *Revision) map[string]string {
+	summaries := make(map[string]string, len(revision.Sidecars))
+
+	for _, sidecar := range revision.Sidecars {
+		summary := fmt.Sprintf("%s [%s]", sidecar.Command, strings.Join(sidecar.ProcessTypes, ","))
+		if sidecar.MemoryInMB != nil {
+			summary += fmt.Sprintf(" %dMB", *sidecar.MemoryInMB)
+		}
+
+		summaries[sidecar.Name] = summary
+	}
+
+	return summaries
+}
+
+func envStrings(env map[string]interface{}) map[string]string {
+	values := make(map[string]string, len(env))
+
+	for key, value := range env {
+		if text, ok := value.(string); ok {
+			values[key] = text
+
+			continue
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded = []byte(fmt.Sprint(value))
+		}
+
+		values[key] = string(encoded)
+	}
+
+	return values
+}
+
+func metadataOrEmpty(metadata *Metadata) *Metadata {
+	if metadata == nil {
+		return &Metadata{}
+	}
+
+	return metadata
+}
@@ -0,0 +1,118 @@
+package capi_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubDroplets serves droplets from a map keyed by GUID.
+type stubDroplets struct {
+	capi.DropletsClient
+
+	droplets map[string]*capi.Droplet
+}
+
+func (s *stubDroplets) Get(_ context.Context, guid string) (*capi.Droplet, error) {
+	droplet, ok := s.droplets[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	return droplet, nil
+}
+
+func revisionDiffClient() *stubClient {
+	webV1, webV2 := "bundle exec puma", "bundle exec puma -C config/puma.rb"
+	ruby1, ruby2 := "1.8.0", "1.9.2"
+	memory := 64
+
+	return &stubClient{
+		revisions: &stubRevisions{
+			revisions: []capi.Revision{
+				{
+					Resource:  capi.Resource{GUID: "rev-a"},
+					Version:   3,
+					Droplet:   capi.RevisionDropletRef{GUID: "droplet-a"},
+					Processes: map[string]capi.Process{"web": {Command: &webV1}, "worker": {}},
+					Metadata:  &capi.Metadata{Labels: map[string]string{"team": "a"}},
+				},
+				{
+					Resource:  capi.Resource{GUID: "rev-b"},
+					Version:   5,
+					Droplet:   capi.RevisionDropletRef{GUID: "droplet-b"},
+					Processes: map[string]capi.Process{"web": {Command: &webV2}},
+					Sidecars:  []capi.Sidecar{{Name: "proxy", Command: "./proxy", ProcessTypes: []string{"web"}, MemoryInMB: &memory}},
+					Metadata:  &capi.Metadata{Labels: map[string]string{"team": "a"}, Annotations: map[string]string{"note": "hotfix"}},
+				},
+			},
+			env: map[string]map[string]interface{}{
+				"rev-a": {"DB_PASSWORD": "old", "LOG_LEVEL": "info"},
+				"rev-b": {"DB_PASSWORD": "new", "LOG_LEVEL": "info", "WORKERS": float64(4)},
+			},
+		},
+		droplets: &stubDroplets{droplets: map[string]*capi.Droplet{
+			"droplet-a": {Buildpacks: []capi.DetectedBuildpack{{Name: "ruby_buildpack", Version: &ruby1}}},
+			"droplet-b": {Buildpacks: []capi.DetectedBuildpack{{Name: "ruby_buildpack", Version: &ruby2}}},
+		}},
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	t.Parallel()
+
+	diff, err := capi.DiffRevisions(context.Background(), revisionDiffClient(), "rev-a", "rev-b", capi.RevisionDiffOptions{})
+	require.NoError(t, err)
+	require.True(t, diff.HasChanges())
+
+	assert.Equal(t, capi.RevisionDiffSide{GUID: "rev-a", Version: 3}, diff.From)
+	assert.Equal(t, capi.RevisionDiffSide{GUID: "rev-b", Version: 5}, diff.To)
+
+	assert.Equal(t, []capi.RevisionChange{
+		{Section: "droplet", Key: "guid", Kind: capi.RevisionChangeChanged, From: "droplet-a", To: "droplet-b"},
+		{Section: "buildpacks", Key: "ruby_buildpack", Kind: capi.RevisionChangeChanged, From: "1.8.0", To: "1.9.2"},
+		{Section: "processes", Key: "web", Kind: capi.RevisionChangeChanged, From: "bundle exec puma", To: "bundle exec puma -C config/puma.rb"},
+		{Section: "processes", Key: "worker", Kind: capi.RevisionChangeRemoved},
+		{Section: "sidecars", Key: "proxy", Kind: capi.RevisionChangeAdded, To: "./proxy [web] 64MB"},
+		{Section: "env", Key: "DB_PASSWORD", Kind: capi.RevisionChangeChanged, From: capi.RevisionDiffMask, To: capi.RevisionDiffMask},
+		{Section: "env", Key: "WORKERS", Kind: capi.RevisionChangeAdded, To: capi.RevisionDiffMask},
+		{Section: "annotations", Key: "note", Kind: capi.RevisionChangeAdded, To: "hotfix"},
+	}, diff.Changes)
+}
+
+func TestDiffRevisions_ShowValues(t *testing.T) {
+	t.Parallel()
+
+	diff, err := capi.DiffRevisions(context.Background(), revisionDiffClient(), "rev-a", "rev-b", capi.RevisionDiffOptions{ShowValues: true})
+	require.NoError(t, err)
+
+	var env []capi.RevisionChange
+
+	for _, change := range diff.Changes {
+		if change.Section == capi.RevisionDiffSectionEnv {
+			env = append(env, change)
+		}
+	}
+
+	require.Len(t, env, 2)
+	assert.Equal(t, "old", env[0].From)
+	assert.Equal(t, "new", env[0].To)
+	assert.Equal(t, "4", env[1].To)
+}
+
+func TestDiffRevisions_Identical(t *testing.T) {
+	t.Parallel()
+
+	client := revisionDiffClient()
+	client.droplets = &stubDroplets{} // expired droplets are tolerated
+
+	diff, err := capi.DiffRevisions(context.Background(), client, "rev-a", "rev-a", capi.RevisionDiffOptions{})
+	require.NoError(t, err)
+	assert.False(t, diff.HasChanges())
+
+	_, err = capi.DiffRevisions(context.Background(), client, "rev-a", "missing", capi.RevisionDiffOptions{})
+	require.ErrorIs(t, err, capi.ErrNotFound)
+}
@@ -0,0 +1,158 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrRollbackTargetConflict = errors.New("a rollback targets either a revision or a droplet, not both")
+	ErrRevisionNotFound       = errors.New("revision not found")
+	ErrRevisionNotDeployable  = errors.New("revision is not deployable")
+	ErrNoPreviousRevision     = errors.New("no previous deployable revision")
+)
+
+// RollbackOptions configures Rollback.
+type RollbackOptions struct {
+	// RevisionVersion selects the revision to roll back to. When zero and
+	// DropletGUID is empty, the newest deployable revision older than the
+	// currently deployed one is used.
+	RevisionVersion int
+	// RevisionGUID deploys a revision the caller has already resolved, for
+	// example through RollbackRevision, without listing the history again.
+	// It takes precedence over RevisionVersion.
+	RevisionGUID string
+	// DropletGUID rolls back to a droplet instead of a revision.
+	DropletGUID string
+	// Strategy defaults to DeploymentStrategyRolling.
+	Strategy    string
+	MaxInFlight int
+	// Runner configures how the rollback deployment is followed.
+	Runner *DeploymentRunnerOptions
+}
+
+// AppRevisions lists the revisions of an app, newest version first.
+func AppRevisions(ctx context.Context, client Client, appGUID string) ([]Revision, error) {
+	revisions, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Revision], error) {
+		return client.Revisions().ListForApp(ctx, appGUID, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions for app %s: %w", appGUID, err)
+	}
+
+	sort.Slice(revisions, func(a, b int) bool { return revisions[a].Version > revisions[b].Version })
+
+	return revisions, nil
+}
+
+// DeployedRevisionVersion returns the highest version among the app's
+// deployed revisions, or zero when none is deployed.
+func DeployedRevisionVersion(ctx context.Context, client Client, appGUID string) (int, error) {
+	deployed, err := client.Revisions().GetDeployedForApp(ctx, appGUID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get deployed revisions for app %s: %w", appGUID, err)
+	}
+
+	version := 0
+
+	for _, revision := range deployed.Resources {
+		version = max(version, revision.Version)
+	}
+
+	return version, nil
+}
+
+// RollbackRevision resolves the revision Rollback would deploy for version,
+// where zero means the previous deployable revision. revisions must be
+// ordered newest first, as returned by AppRevisions.
+func RollbackRevision(revisions []Revision, deployedVersion, version int) (*Revision, error) {
+	if version > 0 {
+		for i := range revisions {
+			if revisions[i].Version != version {
+				continue
+			}
+
+			if !revisions[i].Deployable {
+				return nil, fmt.Errorf("%w: version %d", ErrRevisionNotDeployable, version)
+			}
+
+			return &revisions[i], nil
+		}
+
+		return nil, fmt.Errorf("%w: version %d", ErrRevisionNotFound, version)
+	}
+
+	// Without a deployed revision, treat the newest revision as current.
+	if deployedVersion == 0 && len(revisions) > 0 {
+		deployedVersion = revisions[0].Version
+	}
+
+	for i := range revisions {
+		if revisions[i].Version < deployedVersion && revisions[i].Deployable {
+			return &revisions[i], nil
+		}
+	}
+
+	return nil, ErrNoPreviousRevision
+}
+
+// Rollback deploys an earlier revision or droplet of an app and waits for the
+// deployment to finish. The result is non-nil whenever a deployment was
+// created.
+func Rollback(ctx context.Context, client Client, appGUID string, opts RollbackOptions) (*DeploymentResult, error) {
+	if opts.DropletGUID != "" && (opts.RevisionVersion > 0 || opts.RevisionGUID != "") {
+		return nil, ErrRollbackTargetConflict
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = DeploymentStrategyRolling
+	}
+
+	request := &DeploymentCreateRequest{
+		Strategy:      &strategy,
+		Relationships: DeploymentRelationships{App: &Relationship{Data: &RelationshipData{GUID: appGUID}}},
+	}
+
+	if opts.MaxInFlight > 0 {
+		maxInFlight := opts.MaxInFlight
+		request.Options = &DeploymentOptions{MaxInFlight: &maxInFlight}
+	}
+
+	switch {
+	case opts.DropletGUID != "":
+		request.Droplet = &DeploymentDropletRef{GUID: opts.DropletGUID}
+	case opts.RevisionGUID != "":
+		request.Revision = &DeploymentRevisionRef{GUID: opts.RevisionGUID}
+	default:
+		revision, err := resolveRollbackRevision(ctx, client, appGUID, opts.RevisionVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Revision = &DeploymentRevisionRef{GUID: revision.GUID}
+	}
+
+	return NewDeploymentRunner(client, opts.Runner).Run(ctx, request)
+}
+
+func resolveRollbackRevision(ctx context.Context, client Client, appGUID string, version int) (*Revision, error) {
+	revisions, err := AppRevisions(ctx, client, appGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	deployedVersion := 0
+
+	if version == 0 {
+		deployedVersion, err = DeployedRevisionVersion(ctx, client, appGUID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return RollbackRevision(revisions, deployedVersion, version)
+}
@@ -0,0 +1,152 @@
+package capi_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRevisions serves a fixed revision history and deployed version, and
+// each revision's environment variables keyed by GUID.
+type stubRevisions struct {
+	capi.RevisionsClient
+
+	revisions []capi.Revision
+	deployed  int
+	env       map[string]map[string]interface{}
+}
+
+func (s *stubRevisions) Get(_ context.Context, guid string) (*capi.Revision, error) {
+	for i := range s.revisions {
+		if s.revisions[i].GUID == guid {
+			return &s.revisions[i], nil
+		}
+	}
+
+	return nil, capi.ErrNotFound
+}
+
+func (s *stubRevisions) GetEnvironmentVariables(_ context.Context, guid string) (map[string]interface{}, error) {
+	return s.env[guid], nil
+}
+
+func (s *stubRevisions) ListForApp(_ context.Context, _ string, _ *capi.QueryParams) (*capi.ListResponse[capi.Revision], error) {
+	return &capi.ListResponse[capi.Revision]{Resources: s.revisions}, nil
+}
+
+func (s *stubRevisions) GetDeployedForApp(_ context.Context, _ string) (*capi.ListResponse[capi.Revision], error) {
+	response := &capi.ListResponse[capi.Revision]{}
+
+	for _, revision := range s.revisions {
+		if revision.Version == s.deployed {
+			response.Resources = append(response.Resources, revision)
+		}
+	}
+
+	return response, nil
+}
+
+func revisionHistory() []capi.Revision {
+	return []capi.Revision{
+		{Resource: capi.Resource{GUID: "rev-1"}, Version: 1, Deployable: true},
+		{Resource: capi.Resource{GUID: "rev-2"}, Version: 2, Deployable: false},
+		{Resource: capi.Resource{GUID: "rev-3"}, Version: 3, Deployable: true},
+		{Resource: capi.Resource{GUID: "rev-4"}, Version: 4, Deployable: true},
+	}
+}
+
+func TestRollbackRevision(t *testing.T) {
+	t.Parallel()
+
+	revisions := revisionHistory()
+	// AppRevisions orders newest first.
+	ordered := []capi.Revision{revisions[3], revisions[2], revisions[1], revisions[0]}
+
+	revision, err := capi.RollbackRevision(ordered, 4, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, revision.Version)
+
+	revision, err = capi.RollbackRevision(ordered, 3, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, revision.Version, "non-deployable revisions are skipped")
+
+	revision, err = capi.RollbackRevision(ordered, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, revision.Version, "newest revision is current when none is deployed")
+
+	_, err = capi.RollbackRevision(ordered, 1, 0)
+	require.ErrorIs(t, err, capi.ErrNoPreviousRevision)
+
+	_, err = capi.RollbackRevision(ordered, 4, 2)
+	require.ErrorIs(t, err, capi.ErrRevisionNotDeployable)
+
+	_, err = capi.RollbackRevision(ordered, 4, 9)
+	require.ErrorIs(t, err, capi.ErrRevisionNotFound)
+}
+
+func TestRollback_PreviousRevision(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(1)
+	client := &stubClient{
+		deployments: deployments,
+		revisions:   &stubRevisions{revisions: revisionHistory(), deployed: 4},
+	}
+
+	result, err := capi.Rollback(context.Background(), client, "app-guid", capi.RollbackOptions{
+		MaxInFlight: 2,
+		Runner:      &capi.DeploymentRunnerOptions{PollInterval: time.Millisecond},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result)
+
+	request := deployments.created
+	require.NotNil(t, request.Revision)
+	assert.Equal(t, "rev-3", request.Revision.GUID)
+	assert.Nil(t, request.Droplet)
+	assert.Equal(t, capi.DeploymentStrategyRolling, *request.Strategy)
+	assert.Equal(t, 2, *request.Options.MaxInFlight)
+	assert.Equal(t, "app-guid", request.Relationships.App.Data.GUID)
+}
+
+func TestRollback_Droplet(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(1)
+	client := &stubClient{deployments: deployments}
+
+	_, err := capi.Rollback(context.Background(), client, "app-guid", capi.RollbackOptions{
+		DropletGUID: "droplet-guid",
+		Runner:      &capi.DeploymentRunnerOptions{PollInterval: time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "droplet-guid", deployments.created.Droplet.GUID)
+	assert.Nil(t, deployments.created.Revision)
+
+	_, err = capi.Rollback(context.Background(), client, "app-guid", capi.RollbackOptions{DropletGUID: "d", RevisionVersion: 2})
+	require.ErrorIs(t, err, capi.ErrRollbackTargetConflict)
+
+	_, err = capi.Rollback(context.Background(), client, "app-guid", capi.RollbackOptions{DropletGUID: "d", RevisionGUID: "rev-1"})
+	require.ErrorIs(t, err, capi.ErrRollbackTargetConflict)
+}
+
+func TestRollback_ResolvedRevision(t *testing.T) {
+	t.Parallel()
+
+	deployments := newStubDeployments(1)
+	// No revisions client: a resolved revision is deployed without listing.
+	client := &stubClient{deployments: deployments}
+
+	_, err := capi.Rollback(context.Background(), client, "app-guid", capi.RollbackOptions{
+		RevisionGUID: "rev-1",
+		Runner:       &capi.DeploymentRunnerOptions{PollInterval: time.Millisecond},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "rev-1", deployments.created.Revision.GUID)
+}
@@ -0,0 +1,516 @@
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// SnapshotVersion is the format version CreateSnapshot writes and
+// ParseSnapshot accepts.
+const SnapshotVersion = 1
+
+// Snapshot is an inventory of a foundation at one point in time: its apps,
+// routes, service instances, service bindings and roles. Resources are
+// identified by name ("org/space/app") rather than GUID, so that two
+// snapshots can be compared with DiffSnapshots. Every list is sorted.
+type Snapshot struct {
+	Version   int       `json:"version"           yaml:"version"`
+	CreatedAt time.Time `json:"created_at"        yaml:"created_at"`
+	// API is the API endpoint the snapshot was taken of, when known.
+	API              string                    `json:"api,omitempty"     yaml:"api,omitempty"`
+	Apps             []SnapshotApp             `json:"apps"              yaml:"apps"`
+	Routes           []SnapshotRoute           `json:"routes"            yaml:"routes"`
+	ServiceInstances []SnapshotServiceInstance `json:"service_instances" yaml:"service_instances"`
+	ServiceBindings  []SnapshotServiceBinding  `json:"service_bindings"  yaml:"service_bindings"`
+	Roles            []SnapshotRole            `json:"roles"             yaml:"roles"`
+}
+
+// SnapshotApp is an app with the scale of each of its processes. Droplet is
+// the GUID of the current droplet, empty when the app has none.
+type SnapshotApp struct {
+	GUID      string                     `json:"guid"                yaml:"guid"`
+	Space     string                     `json:"space"               yaml:"space"`
+	Name      string                     `json:"name"                yaml:"name"`
+	State     string                     `json:"state"               yaml:"state"`
+	Lifecycle string                     `json:"lifecycle"           yaml:"lifecycle"`
+	Stack     string                     `json:"stack,omitempty"     yaml:"stack,omitempty"`
+	Droplet   string                     `json:"droplet,omitempty"   yaml:"droplet,omitempty"`
+	Processes map[string]SnapshotProcess `json:"processes,omitempty" yaml:"processes,omitempty"`
+}
+
+// SnapshotProcess is the scale of one process type of an app.
+type SnapshotProcess struct {
+	Instances  int `json:"instances"    yaml:"instances"`
+	MemoryInMB int `json:"memory_in_mb" yaml:"memory_in_mb"`
+}
+
+// SnapshotRoute is a route with the apps it is mapped to, as "org/space/app".
+type SnapshotRoute struct {
+	GUID         string   `json:"guid"                   yaml:"guid"`
+	URL          string   `json:"url"                    yaml:"url"`
+	Space        string   `json:"space"                  yaml:"space"`
+	Destinations []string `json:"destinations,omitempty" yaml:"destinations,omitempty"`
+}
+
+// SnapshotServiceInstance is a service instance; Offering and Plan are set
+// for managed instances.
+type SnapshotServiceInstance struct {
+	GUID     string `json:"guid"               yaml:"guid"`
+	Space    string `json:"space"              yaml:"space"`
+	Name     string `json:"name"               yaml:"name"`
+	Type     string `json:"type"               yaml:"type"`
+	Offering string `json:"offering,omitempty" yaml:"offering,omitempty"`
+	Plan     string `json:"plan,omitempty"     yaml:"plan,omitempty"`
+}
+
+// SnapshotServiceBinding is an app binding or a service key of a service
+// instance, both given as "org/space/name".
+type SnapshotServiceBinding struct {
+	GUID            string `json:"guid"             yaml:"guid"`
+	Type            string `json:"type"             yaml:"type"`
+	Name            string `json:"name,omitempty"   yaml:"name,omitempty"`
+	ServiceInstance string `json:"service_instance" yaml:"service_instance"`
+	App             string `json:"app,omitempty"    yaml:"app,omitempty"`
+}
+
+// SnapshotRole is a role of a user, given by username when it has one, in
+// an organization or an "org/space".
+type SnapshotRole struct {
+	GUID         string `json:"guid"                   yaml:"guid"`
+	Type         string `json:"type"                   yaml:"type"`
+	User         string `json:"user"                   yaml:"user"`
+	Organization string `json:"organization,omitempty" yaml:"organization,omitempty"`
+	Space        string `json:"space,omitempty"        yaml:"space,omitempty"`
+}
+
+// SnapshotOptions configures CreateSnapshot.
+type SnapshotOptions struct {
+	// Concurrency bounds the current droplet lookups in flight, one per
+	// app. Defaults to 3.
+	Concurrency int
+}
+
+// CreateSnapshot takes an inventory of the foundation with the list
+// endpoints, resolving names through included resources, and looks up the
+// current droplet of each app.
+func CreateSnapshot(ctx context.Context, client Client, opts SnapshotOptions) (*Snapshot, error) {
+	names, err := readSnapshotSpaces(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC()}
+
+	var appKeys map[string]string
+
+	snapshot.Apps, appKeys, err = readSnapshotApps(ctx, client, names, opts.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Routes, err = readSnapshotRoutes(ctx, client, names, appKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceKeys map[string]string
+
+	snapshot.ServiceInstances, instanceKeys, err = readSnapshotServiceInstances(ctx, client, names)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.ServiceBindings, err = readSnapshotServiceBindings(ctx, client, appKeys, instanceKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot.Roles, err = readSnapshotRoles(ctx, client, names)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// ParseSnapshot reads a snapshot written as JSON.
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+
+	err := json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrInvalidSnapshot, snapshot.Version, SnapshotVersion)
+	}
+
+	return &snapshot, nil
+}
+
+// snapshotNames resolves organization and space GUIDs to names.
+type snapshotNames struct {
+	orgs   foundationNames
+	spaces foundationNames
+}
+
+func readSnapshotSpaces(ctx context.Context, client Client) (*snapshotNames, error) {
+	names := &snapshotNames{orgs: foundationNames{}, spaces: foundationNames{}}
+
+	spaces, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Space], error) {
+		page, err := client.Spaces().List(ctx, params, SpaceIncludeOrganization)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := SpaceIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, org := range included.Organizations {
+			names.orgs[org.GUID] = org.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list spaces: %w", err)
+	}
+
+	for _, space := range spaces {
+		org := ""
+		if space.Relationships.Organization.Data != nil {
+			org = names.orgs.name(space.Relationships.Organization.Data.GUID)
+		}
+
+		names.spaces[space.GUID] = org + "/" + space.Name
+	}
+
+	return names, nil
+}
+
+// readSnapshotApps returns the apps and their "org/space/app" keys by GUID.
+func readSnapshotApps(ctx context.Context, client Client, names *snapshotNames, concurrency int) ([]SnapshotApp, map[string]string, error) {
+	apps, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[App], error) {
+		return client.Apps().List(ctx, params)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	processes, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Process], error) {
+		return client.Processes().List(ctx, params)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	scale := map[string]map[string]SnapshotProcess{}
+
+	for _, process := range processes {
+		if process.Relationships == nil || process.Relationships.App == nil || process.Relationships.App.Data == nil {
+			continue
+		}
+
+		appGUID := process.Relationships.App.Data.GUID
+		if scale[appGUID] == nil {
+			scale[appGUID] = map[string]SnapshotProcess{}
+		}
+
+		scale[appGUID][process.Type] = SnapshotProcess{Instances: process.Instances, MemoryInMB: process.MemoryInMB}
+	}
+
+	result := make([]SnapshotApp, 0, len(apps))
+	keys := make(map[string]string, len(apps))
+
+	for _, app := range apps {
+		entry := SnapshotApp{
+			GUID:      app.GUID,
+			Name:      app.Name,
+			State:     app.State,
+			Lifecycle: app.Lifecycle.Type,
+			Processes: scale[app.GUID],
+		}
+
+		if app.Relationships.Space.Data != nil {
+			entry.Space = names.spaces.name(app.Relationships.Space.Data.GUID)
+		}
+
+		if stack, ok := app.Lifecycle.Data["stack"].(string); ok {
+			entry.Stack = stack
+		}
+
+		keys[app.GUID] = entry.snapshotKey()
+		result = append(result, entry)
+	}
+
+	err = readSnapshotDroplets(ctx, client, result, concurrency)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortSnapshotEntries(result)
+
+	return result, keys, nil
+}
+
+// readSnapshotDroplets fills in the current droplet of every app using at
+// most concurrency lookups at once. Apps without a droplet keep none.
+func readSnapshotDroplets(ctx context.Context, client Client, apps []SnapshotApp, concurrency int) error {
+	if concurrency <= 0 {
+		concurrency = constants.DefaultConcurrencyLimit
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	semaphore := make(chan struct{}, concurrency)
+
+	for i := range apps {
+		wg.Add(1)
+
+		go func(app *SnapshotApp) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			droplet, err := client.Apps().GetCurrentDroplet(ctx, app.GUID)
+
+			switch {
+			case err == nil:
+				app.Droplet = droplet.GUID
+			case !IsNotFound(err):
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to get current droplet of app %s: %w", app.snapshotKey(), err)
+
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(&apps[i])
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+func readSnapshotRoutes(ctx context.Context, client Client, names *snapshotNames, appKeys map[string]string) ([]SnapshotRoute, error) {
+	routes, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Route], error) {
+		return client.Routes().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	result := make([]SnapshotRoute, 0, len(routes))
+
+	for _, route := range routes {
+		entry := SnapshotRoute{GUID: route.GUID, URL: route.URL}
+		if route.Relationships.Space.Data != nil {
+			entry.Space = names.spaces.name(route.Relationships.Space.Data.GUID)
+		}
+
+		seen := map[string]bool{}
+
+		for _, destination := range route.Destinations {
+			app, ok := appKeys[destination.App.GUID]
+			if !ok {
+				app = destination.App.GUID
+			}
+
+			if !seen[app] {
+				seen[app] = true
+
+				entry.Destinations = append(entry.Destinations, app)
+			}
+		}
+
+		sort.Strings(entry.Destinations)
+
+		result = append(result, entry)
+	}
+
+	sortSnapshotEntries(result)
+
+	return result, nil
+}
+
+// readSnapshotServiceInstances returns the service instances and their
+// "org/space/name" keys by GUID.
+func readSnapshotServiceInstances(ctx context.Context, client Client, names *snapshotNames) ([]SnapshotServiceInstance, map[string]string, error) {
+	plans := map[string]ServicePlan{}
+	offerings := foundationNames{}
+
+	instances, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceInstance], error) {
+		page, err := client.ServiceInstances().List(ctx, params, ServiceInstanceIncludeServicePlanServiceOffering)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := ServiceInstanceIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, plan := range included.ServicePlans {
+			plans[plan.GUID] = plan
+		}
+
+		for _, offering := range included.ServiceOfferings {
+			offerings[offering.GUID] = offering.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list service instances: %w", err)
+	}
+
+	result := make([]SnapshotServiceInstance, 0, len(instances))
+	keys := make(map[string]string, len(instances))
+
+	for _, instance := range instances {
+		entry := SnapshotServiceInstance{GUID: instance.GUID, Name: instance.Name, Type: instance.Type}
+		if instance.Relationships.Space.Data != nil {
+			entry.Space = names.spaces.name(instance.Relationships.Space.Data.GUID)
+		}
+
+		if instance.Relationships.ServicePlan != nil && instance.Relationships.ServicePlan.Data != nil {
+			if plan, ok := plans[instance.Relationships.ServicePlan.Data.GUID]; ok {
+				entry.Plan = plan.Name
+				if plan.Relationships.ServiceOffering.Data != nil {
+					entry.Offering = offerings.name(plan.Relationships.ServiceOffering.Data.GUID)
+				}
+			}
+		}
+
+		keys[instance.GUID] = entry.snapshotKey()
+		result = append(result, entry)
+	}
+
+	sortSnapshotEntries(result)
+
+	return result, keys, nil
+}
+
+func readSnapshotServiceBindings(ctx context.Context, client Client, appKeys, instanceKeys map[string]string) ([]SnapshotServiceBinding, error) {
+	bindings, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceCredentialBinding], error) {
+		return client.ServiceCredentialBindings().List(ctx, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service credential bindings: %w", err)
+	}
+
+	result := make([]SnapshotServiceBinding, 0, len(bindings))
+
+	for _, binding := range bindings {
+		entry := SnapshotServiceBinding{GUID: binding.GUID, Type: binding.Type, Name: binding.Name}
+
+		if binding.Relationships.ServiceInstance.Data != nil {
+			instance := binding.Relationships.ServiceInstance.Data.GUID
+
+			entry.ServiceInstance = instanceKeys[instance]
+			if entry.ServiceInstance == "" {
+				entry.ServiceInstance = instance
+			}
+		}
+
+		if binding.Relationships.App != nil && binding.Relationships.App.Data != nil {
+			app := binding.Relationships.App.Data.GUID
+
+			entry.App = appKeys[app]
+			if entry.App == "" {
+				entry.App = app
+			}
+		}
+
+		result = append(result, entry)
+	}
+
+	sortSnapshotEntries(result)
+
+	return result, nil
+}
+
+func readSnapshotRoles(ctx context.Context, client Client, names *snapshotNames) ([]SnapshotRole, error) {
+	usernames := foundationNames{}
+
+	roles, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Role], error) {
+		page, err := client.Roles().List(ctx, params, RoleIncludeUser, RoleIncludeOrganization)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := RoleIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range included.Users {
+			if user.Username != "" {
+				usernames[user.GUID] = user.Username
+			}
+		}
+
+		for _, org := range included.Organizations {
+			names.orgs[org.GUID] = org.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	result := make([]SnapshotRole, 0, len(roles))
+
+	for _, role := range roles {
+		entry := SnapshotRole{GUID: role.GUID, Type: role.Type}
+		if role.Relationships.User.Data != nil {
+			entry.User = usernames.name(role.Relationships.User.Data.GUID)
+		}
+
+		if role.Relationships.Organization != nil && role.Relationships.Organization.Data != nil {
+			entry.Organization = names.orgs.name(role.Relationships.Organization.Data.GUID)
+		}
+
+		if role.Relationships.Space != nil && role.Relationships.Space.Data != nil {
+			entry.Space = names.spaces.name(role.Relationships.Space.Data.GUID)
+		}
+
+		result = append(result, entry)
+	}
+
+	sortSnapshotEntries(result)
+
+	return result, nil
+}
+
+func sortSnapshotEntries[E snapshotEntry](entries []E) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].snapshotKey() < entries[j].snapshotKey()
+	})
+}
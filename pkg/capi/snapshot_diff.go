@@ -0,0 +1,221 @@
+package capi
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sections of a SnapshotDiff, in the order changes are reported.
+const (
+	SnapshotSectionApps             = "apps"
+	SnapshotSectionRoutes           = "routes"
+	SnapshotSectionServiceInstances = "service_instances"
+	SnapshotSectionServiceBindings  = "service_bindings"
+	SnapshotSectionRoles            = "roles"
+)
+
+// SnapshotChangeKind classifies a SnapshotChange.
+type SnapshotChangeKind string
+
+// Kinds of snapshot changes.
+const (
+	SnapshotChangeAdded   SnapshotChangeKind = "added"
+	SnapshotChangeRemoved SnapshotChangeKind = "removed"
+	SnapshotChangeChanged SnapshotChangeKind = "changed"
+)
+
+// SnapshotChange is a resource added or removed between two snapshots, or
+// one field of a resource in both that changed. A changed guid means the
+// resource was deleted and recreated under the same name.
+type SnapshotChange struct {
+	Section string             `json:"section"         yaml:"section"`
+	Key     string             `json:"key"             yaml:"key"`
+	Kind    SnapshotChangeKind `json:"kind"            yaml:"kind"`
+	Field   string             `json:"field,omitempty" yaml:"field,omitempty"`
+	From    string             `json:"from,omitempty"  yaml:"from,omitempty"`
+	To      string             `json:"to,omitempty"    yaml:"to,omitempty"`
+}
+
+// SnapshotDiffSide identifies one of the compared snapshots.
+type SnapshotDiffSide struct {
+	CreatedAt time.Time `json:"created_at"    yaml:"created_at"`
+	API       string    `json:"api,omitempty" yaml:"api,omitempty"`
+}
+
+// SnapshotDiff lists the differences between two snapshots.
+type SnapshotDiff struct {
+	From    SnapshotDiffSide `json:"from"    yaml:"from"`
+	To      SnapshotDiffSide `json:"to"      yaml:"to"`
+	Changes []SnapshotChange `json:"changes" yaml:"changes"`
+}
+
+// HasChanges reports whether the snapshots differ.
+func (d *SnapshotDiff) HasChanges() bool {
+	return len(d.Changes) > 0
+}
+
+// Count returns the number of resources added, removed or changed; a
+// resource with several changed fields counts once.
+func (d *SnapshotDiff) Count(kind SnapshotChangeKind) int {
+	seen := map[string]bool{}
+
+	for _, change := range d.Changes {
+		if change.Kind == kind {
+			seen[change.Section+"\x00"+change.Key] = true
+		}
+	}
+
+	return len(seen)
+}
+
+// DiffSnapshots reports the resources added to, removed from and changed
+// in the foundation between from and to. Resources are matched by key: an
+// app by "org/space/name", a route by URL, a binding by service instance
+// and app or key name, a role by type, user and organization or space.
+func DiffSnapshots(from, to *Snapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		From: SnapshotDiffSide{CreatedAt: from.CreatedAt, API: from.API},
+		To:   SnapshotDiffSide{CreatedAt: to.CreatedAt, API: to.API},
+	}
+
+	diff.Changes = append(diff.Changes, diffSnapshotSection(SnapshotSectionApps, from.Apps, to.Apps)...)
+	diff.Changes = append(diff.Changes, diffSnapshotSection(SnapshotSectionRoutes, from.Routes, to.Routes)...)
+	diff.Changes = append(diff.Changes, diffSnapshotSection(SnapshotSectionServiceInstances, from.ServiceInstances, to.ServiceInstances)...)
+	diff.Changes = append(diff.Changes, diffSnapshotSection(SnapshotSectionServiceBindings, from.ServiceBindings, to.ServiceBindings)...)
+	diff.Changes = append(diff.Changes, diffSnapshotSection(SnapshotSectionRoles, from.Roles, to.Roles)...)
+
+	return diff
+}
+
+// snapshotEntry is a resource of a snapshot, identified by its key, with
+// the fields DiffSnapshots compares.
+type snapshotEntry interface {
+	snapshotKey() string
+	snapshotFields() map[string]string
+}
+
+func diffSnapshotSection[E snapshotEntry](section string, from, to []E) []SnapshotChange {
+	index := func(entries []E) map[string]map[string]string {
+		fields := make(map[string]map[string]string, len(entries))
+		for _, entry := range entries {
+			fields[entry.snapshotKey()] = entry.snapshotFields()
+		}
+
+		return fields
+	}
+
+	fromEntries, toEntries := index(from), index(to)
+
+	keys := slices.Collect(maps.Keys(fromEntries))
+	for key := range toEntries {
+		if _, ok := fromEntries[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	var changes []SnapshotChange
+
+	for _, key := range keys {
+		fromFields, inFrom := fromEntries[key]
+		toFields, inTo := toEntries[key]
+
+		switch {
+		case !inFrom:
+			changes = append(changes, SnapshotChange{Section: section, Key: key, Kind: SnapshotChangeAdded})
+		case !inTo:
+			changes = append(changes, SnapshotChange{Section: section, Key: key, Kind: SnapshotChangeRemoved})
+		default:
+			for _, change := range diffStringMaps(section, fromFields, toFields, nil) {
+				changes = append(changes, SnapshotChange{
+					Section: section,
+					Key:     key,
+					Kind:    SnapshotChangeChanged,
+					Field:   change.Key,
+					From:    change.From,
+					To:      change.To,
+				})
+			}
+		}
+	}
+
+	return changes
+}
+
+func (a SnapshotApp) snapshotKey() string {
+	return a.Space + "/" + a.Name
+}
+
+func (a SnapshotApp) snapshotFields() map[string]string {
+	fields := map[string]string{
+		"guid":      a.GUID,
+		"state":     a.State,
+		"lifecycle": a.Lifecycle,
+		"stack":     a.Stack,
+		"droplet":   a.Droplet,
+	}
+
+	for processType, process := range a.Processes {
+		fields["processes."+processType+".instances"] = strconv.Itoa(process.Instances)
+		fields["processes."+processType+".memory_in_mb"] = strconv.Itoa(process.MemoryInMB)
+	}
+
+	return fields
+}
+
+func (r SnapshotRoute) snapshotKey() string {
+	return r.URL
+}
+
+func (r SnapshotRoute) snapshotFields() map[string]string {
+	return map[string]string{
+		"guid":         r.GUID,
+		"space":        r.Space,
+		"destinations": strings.Join(r.Destinations, ", "),
+	}
+}
+
+func (s SnapshotServiceInstance) snapshotKey() string {
+	return s.Space + "/" + s.Name
+}
+
+func (s SnapshotServiceInstance) snapshotFields() map[string]string {
+	return map[string]string{
+		"guid":     s.GUID,
+		"type":     s.Type,
+		"offering": s.Offering,
+		"plan":     s.Plan,
+	}
+}
+
+func (b SnapshotServiceBinding) snapshotKey() string {
+	if b.App != "" {
+		return b.ServiceInstance + " -> " + b.App
+	}
+
+	return b.ServiceInstance + " key " + b.Name
+}
+
+func (b SnapshotServiceBinding) snapshotFields() map[string]string {
+	return map[string]string{
+		"guid": b.GUID,
+		"name": b.Name,
+	}
+}
+
+func (r SnapshotRole) snapshotKey() string {
+	scope := r.Organization
+	if r.Space != "" {
+		scope = r.Space
+	}
+
+	return r.Type + " " + r.User + " in " + scope
+}
+
+func (r SnapshotRole) snapshotFields() map[string]string {
+	return map[string]string{"guid": r.GUID}
+}
@@ -0,0 +1,301 @@
+package capi_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotSpaces struct {
+	capi.SpacesClient
+}
+
+func (s *snapshotSpaces) List(_ context.Context, params *capi.QueryParams, opts ...capi.SpaceListOption) (*capi.ListResponse[capi.Space], error) {
+	if capi.ApplyQueryOptions(params.ToValues(), opts).Get("include") != "organization" {
+		return &capi.ListResponse[capi.Space]{}, nil
+	}
+
+	included, err := encodeIncluded(map[string][]interface{}{
+		"organizations": {capi.Organization{Resource: capi.Resource{GUID: "org-1"}, Name: "payments"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &capi.ListResponse[capi.Space]{
+		Resources: []capi.Space{{
+			Resource:      capi.Resource{GUID: "space-1"},
+			Name:          "prod",
+			Relationships: capi.SpaceRelationships{Organization: capi.Relationship{Data: &capi.RelationshipData{GUID: "org-1"}}},
+		}},
+		Included: included,
+	}, nil
+}
+
+// snapshotApps serves current droplets by app GUID; apps without one are
+// not found.
+type snapshotApps struct {
+	stubApps
+
+	droplets map[string]string
+}
+
+func (s *snapshotApps) GetCurrentDroplet(_ context.Context, guid string) (*capi.Droplet, error) {
+	droplet, ok := s.droplets[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	return &capi.Droplet{Resource: capi.Resource{GUID: droplet}}, nil
+}
+
+type snapshotProcesses struct {
+	capi.ProcessesClient
+}
+
+func (s *snapshotProcesses) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ProcessListOption) (*capi.ListResponse[capi.Process], error) {
+	process := func(appGUID, processType string, instances, memory int) capi.Process {
+		return capi.Process{
+			Type:          processType,
+			Instances:     instances,
+			MemoryInMB:    memory,
+			Relationships: &capi.ProcessRelationships{App: &capi.Relationship{Data: &capi.RelationshipData{GUID: appGUID}}},
+		}
+	}
+
+	return &capi.ListResponse[capi.Process]{Resources: []capi.Process{
+		process("app-1", "web", 2, 512),
+		process("app-1", "worker", 1, 256),
+		process("app-2", "web", 0, 1024),
+	}}, nil
+}
+
+type snapshotRoutes struct {
+	capi.RoutesClient
+}
+
+func (s *snapshotRoutes) List(_ context.Context, _ *capi.QueryParams, _ ...capi.RouteListOption) (*capi.ListResponse[capi.Route], error) {
+	port := 8081
+
+	return &capi.ListResponse[capi.Route]{Resources: []capi.Route{{
+		Resource: capi.Resource{GUID: "route-1"},
+		URL:      "api.example.com",
+		Destinations: []capi.RouteDestination{
+			{App: capi.RouteDestinationApp{GUID: "app-1"}},
+			{App: capi.RouteDestinationApp{GUID: "app-1"}, Port: &port},
+		},
+		Relationships: capi.RouteRelationships{Space: capi.Relationship{Data: &capi.RelationshipData{GUID: "space-1"}}},
+	}}}, nil
+}
+
+type snapshotBindings struct {
+	capi.ServiceCredentialBindingsClient
+}
+
+func (s *snapshotBindings) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ServiceCredentialBindingListOption) (*capi.ListResponse[capi.ServiceCredentialBinding], error) {
+	instance := capi.Relationship{Data: &capi.RelationshipData{GUID: "si-1"}}
+
+	return &capi.ListResponse[capi.ServiceCredentialBinding]{Resources: []capi.ServiceCredentialBinding{
+		{
+			Resource: capi.Resource{GUID: "binding-1"},
+			Type:     "app",
+			Relationships: capi.ServiceCredentialBindingRelationships{
+				App:             &capi.Relationship{Data: &capi.RelationshipData{GUID: "app-1"}},
+				ServiceInstance: instance,
+			},
+		},
+		{
+			Resource:      capi.Resource{GUID: "key-1"},
+			Type:          "key",
+			Name:          "admin-key",
+			Relationships: capi.ServiceCredentialBindingRelationships{ServiceInstance: instance},
+		},
+	}}, nil
+}
+
+// snapshotRoles serves an organization manager and a space developer
+// without a username, with their users and organization included.
+type snapshotRoles struct {
+	capi.RolesClient
+}
+
+func (s *snapshotRoles) List(_ context.Context, params *capi.QueryParams, opts ...capi.RoleListOption) (*capi.ListResponse[capi.Role], error) {
+	if capi.ApplyQueryOptions(params.ToValues(), opts).Get("include") != "user,organization" {
+		return &capi.ListResponse[capi.Role]{}, nil
+	}
+
+	included, err := encodeIncluded(map[string][]interface{}{
+		"users": {
+			capi.User{Resource: capi.Resource{GUID: "u-alice"}, Username: "alice"},
+			capi.User{Resource: capi.Resource{GUID: "u-client"}},
+		},
+		"organizations": {capi.Organization{Resource: capi.Resource{GUID: "org-1"}, Name: "payments"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &capi.ListResponse[capi.Role]{
+		Resources: []capi.Role{
+			orgRole("role-1", "organization_manager", "u-alice", "org-1"),
+			{
+				Resource: capi.Resource{GUID: "role-2"},
+				Type:     "space_developer",
+				Relationships: capi.RoleRelationships{
+					User:  capi.Relationship{Data: &capi.RelationshipData{GUID: "u-client"}},
+					Space: &capi.Relationship{Data: &capi.RelationshipData{GUID: "space-1"}},
+				},
+			},
+		},
+		Included: included,
+	}, nil
+}
+
+func snapshotClient() *stubClient {
+	space := capi.AppRelationships{Space: capi.Relationship{Data: &capi.RelationshipData{GUID: "space-1"}}}
+
+	return &stubClient{
+		spaces: &snapshotSpaces{},
+		apps: &snapshotApps{
+			stubApps: stubApps{apps: []capi.App{
+				{
+					Resource:      capi.Resource{GUID: "app-2"},
+					Name:          "batch",
+					State:         "STOPPED",
+					Lifecycle:     capi.Lifecycle{Type: "docker"},
+					Relationships: space,
+				},
+				{
+					Resource:      capi.Resource{GUID: "app-1"},
+					Name:          "api",
+					State:         "STARTED",
+					Lifecycle:     capi.Lifecycle{Type: "buildpack", Data: map[string]interface{}{"stack": "cflinuxfs4"}},
+					Relationships: space,
+				},
+			}},
+			droplets: map[string]string{"app-1": "droplet-1"},
+		},
+		processes: &snapshotProcesses{},
+		routes:    &snapshotRoutes{},
+		serviceInstances: &stubServiceInstances{
+			instances: []capi.ServiceInstance{{
+				Resource: capi.Resource{GUID: "si-1"},
+				Name:     "db",
+				Type:     "managed",
+				Relationships: capi.ServiceInstanceRelationships{
+					Space:       capi.Relationship{Data: &capi.RelationshipData{GUID: "space-1"}},
+					ServicePlan: &capi.Relationship{Data: &capi.RelationshipData{GUID: "plan-1"}},
+				},
+			}},
+			included: map[string][]interface{}{
+				"service_plans": {capi.ServicePlan{
+					Resource: capi.Resource{GUID: "plan-1"},
+					Name:     "small",
+					Relationships: capi.ServicePlanRelationships{
+						ServiceOffering: capi.Relationship{Data: &capi.RelationshipData{GUID: "offering-1"}},
+					},
+				}},
+				"service_offerings": {capi.ServiceOffering{Resource: capi.Resource{GUID: "offering-1"}, Name: "postgres"}},
+			},
+		},
+		bindings: &snapshotBindings{},
+		roles:    &snapshotRoles{},
+	}
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	t.Parallel()
+
+	snapshot, err := capi.CreateSnapshot(context.Background(), snapshotClient(), capi.SnapshotOptions{})
+	require.NoError(t, err)
+
+	assert.Equal(t, capi.SnapshotVersion, snapshot.Version)
+	assert.False(t, snapshot.CreatedAt.IsZero())
+
+	assert.Equal(t, []capi.SnapshotApp{
+		{
+			GUID: "app-1", Space: "payments/prod", Name: "api", State: "STARTED", Lifecycle: "buildpack",
+			Stack: "cflinuxfs4", Droplet: "droplet-1",
+			Processes: map[string]capi.SnapshotProcess{
+				"web":    {Instances: 2, MemoryInMB: 512},
+				"worker": {Instances: 1, MemoryInMB: 256},
+			},
+		},
+		{
+			GUID: "app-2", Space: "payments/prod", Name: "batch", State: "STOPPED", Lifecycle: "docker",
+			Processes: map[string]capi.SnapshotProcess{"web": {Instances: 0, MemoryInMB: 1024}},
+		},
+	}, snapshot.Apps)
+
+	assert.Equal(t, []capi.SnapshotRoute{
+		{GUID: "route-1", URL: "api.example.com", Space: "payments/prod", Destinations: []string{"payments/prod/api"}},
+	}, snapshot.Routes)
+
+	assert.Equal(t, []capi.SnapshotServiceInstance{
+		{GUID: "si-1", Space: "payments/prod", Name: "db", Type: "managed", Offering: "postgres", Plan: "small"},
+	}, snapshot.ServiceInstances)
+
+	assert.Equal(t, []capi.SnapshotServiceBinding{
+		{GUID: "binding-1", Type: "app", ServiceInstance: "payments/prod/db", App: "payments/prod/api"},
+		{GUID: "key-1", Type: "key", Name: "admin-key", ServiceInstance: "payments/prod/db"},
+	}, snapshot.ServiceBindings)
+
+	assert.Equal(t, []capi.SnapshotRole{
+		{GUID: "role-1", Type: "organization_manager", User: "alice", Organization: "payments"},
+		{GUID: "role-2", Type: "space_developer", User: "u-client", Space: "payments/prod"},
+	}, snapshot.Roles)
+
+	data, err := json.Marshal(snapshot)
+	require.NoError(t, err)
+
+	parsed, err := capi.ParseSnapshot(data)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Apps, parsed.Apps)
+
+	_, err = capi.ParseSnapshot([]byte(`{"version": 99}`))
+	require.ErrorIs(t, err, capi.ErrInvalidSnapshot)
+
+	_, err = capi.ParseSnapshot([]byte(`not json`))
+	require.ErrorIs(t, err, capi.ErrInvalidSnapshot)
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	t.Parallel()
+
+	from := &capi.Snapshot{
+		Apps: []capi.SnapshotApp{
+			{GUID: "app-1", Space: "o/s", Name: "api", State: "STARTED", Droplet: "d-1",
+				Processes: map[string]capi.SnapshotProcess{"web": {Instances: 2, MemoryInMB: 512}}},
+			{GUID: "app-2", Space: "o/s", Name: "batch", State: "STOPPED"},
+		},
+		Roles: []capi.SnapshotRole{{GUID: "role-1", Type: "space_developer", User: "bob", Space: "o/s"}},
+	}
+	to := &capi.Snapshot{
+		Apps: []capi.SnapshotApp{
+			{GUID: "app-1", Space: "o/s", Name: "api", State: "STARTED", Droplet: "d-2",
+				Processes: map[string]capi.SnapshotProcess{"web": {Instances: 3, MemoryInMB: 512}}},
+		},
+		Routes: []capi.SnapshotRoute{{GUID: "route-1", URL: "api.example.com", Space: "o/s"}},
+		Roles:  []capi.SnapshotRole{{GUID: "role-1", Type: "space_developer", User: "bob", Space: "o/s"}},
+	}
+
+	diff := capi.DiffSnapshots(from, to)
+
+	assert.Equal(t, []capi.SnapshotChange{
+		{Section: "apps", Key: "o/s/api", Kind: capi.SnapshotChangeChanged, Field: "droplet", From: "d-1", To: "d-2"},
+		{Section: "apps", Key: "o/s/api", Kind: capi.SnapshotChangeChanged, Field: "processes.web.instances", From: "2", To: "3"},
+		{Section: "apps", Key: "o/s/batch", Kind: capi.SnapshotChangeRemoved},
+		{Section: "routes", Key: "api.example.com", Kind: capi.SnapshotChangeAdded},
+	}, diff.Changes)
+
+	assert.True(t, diff.HasChanges())
+	assert.Equal(t, 1, diff.Count(capi.SnapshotChangeAdded))
+	assert.Equal(t, 1, diff.Count(capi.SnapshotChangeRemoved))
+	assert.Equal(t, 1, diff.Count(capi.SnapshotChangeChanged))
+
+	assert.False(t, capi.DiffSnapshots(to, to).HasChanges())
+}
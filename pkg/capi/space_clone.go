@@ -0,0 +1,1241 @@
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/internal/constants"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrSpaceAlreadyExists = errors.New("space already exists")
+	ErrDropletCopyFailed  = errors.New("droplet copy failed")
+)
+
+// Resources reported in SpaceCloneStep.
+const (
+	SpaceCloneResourceSpace           = "space"
+	SpaceCloneResourceSpaceQuota      = "space_quota"
+	SpaceCloneResourceSecurityGroup   = "security_group"
+	SpaceCloneResourceRole            = "role"
+	SpaceCloneResourceServiceInstance = "service_instance"
+	SpaceCloneResourceApp             = "app"
+	SpaceCloneResourceServiceBinding  = "service_binding"
+	SpaceCloneResourceRoute           = "route"
+)
+
+// SpaceCloneAction is what CloneSpace did with a resource.
+type SpaceCloneAction string
+
+// Actions reported in SpaceCloneStep.
+const (
+	SpaceCloneCreated SpaceCloneAction = "created"
+	SpaceCloneApplied SpaceCloneAction = "applied"
+	SpaceCloneBound   SpaceCloneAction = "bound"
+	SpaceCloneCopied  SpaceCloneAction = "copied"
+	SpaceCloneScaled  SpaceCloneAction = "scaled"
+	SpaceCloneMapped  SpaceCloneAction = "mapped"
+	SpaceCloneStarted SpaceCloneAction = "started"
+	SpaceCloneSkipped SpaceCloneAction = "skipped"
+)
+
+// SpaceCloneStep records one thing CloneSpace did, or skipped and why.
+type SpaceCloneStep struct {
+	Resource string           `json:"resource"         yaml:"resource"`
+	Name     string           `json:"name"             yaml:"name"`
+	Action   SpaceCloneAction `json:"action"           yaml:"action"`
+	Detail   string           `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// SpaceCloneResult is the outcome of CloneSpace.
+type SpaceCloneResult struct {
+	Space *Space           `json:"space" yaml:"space"`
+	Steps []SpaceCloneStep `json:"steps" yaml:"steps"`
+}
+
+// Skipped returns the steps that were skipped.
+func (r *SpaceCloneResult) Skipped() []SpaceCloneStep {
+	var skipped []SpaceCloneStep
+
+	for _, step := range r.Steps {
+		if step.Action == SpaceCloneSkipped {
+			skipped = append(skipped, step)
+		}
+	}
+
+	return skipped
+}
+
+// SpaceCloneOptions configures CloneSpace.
+type SpaceCloneOptions struct {
+	SourceOrganization string
+	SourceSpace        string
+	// TargetOrganization defaults to SourceOrganization.
+	TargetOrganization string
+	TargetSpace        string
+	// Domain is the domain cloned routes are created on, keeping their host
+	// and path. When empty, routes keep their own domain. Routes that
+	// already exist on the destination foundation, as they all do when
+	// cloning on the same foundation without Domain, are skipped.
+	Domain string
+	// PollInterval is the pause between droplet state checks. Defaults to
+	// constants.DefaultPollInterval.
+	PollInterval time.Duration
+	// OnStep, when set, is called synchronously for every step.
+	OnStep func(SpaceCloneStep)
+}
+
+// CloneSpace creates opts.TargetSpace as a copy of opts.SourceSpace: the
+// same space quota, security group bindings and roles, user-provided
+// service instances with their credentials, new managed service instances
+// of the same offering and plan, and apps with their environment
+// variables, process scale, current droplet, service bindings and routes.
+// Apps that were started are started once everything is in place.
+//
+// The source space is read with source and the copy is created with
+// target, which may be a client of another foundation. When target is
+// source, droplets are copied server side. Across foundations they are
+// downloaded into memory and uploaded, and users, security groups, service
+// plans, domains, and the buildpacks and stack of apps are matched by name.
+// Resources that cannot be matched are skipped and reported in the result.
+//
+// The returned result is non-nil whenever the destination space was
+// created, even if an error is returned.
+func CloneSpace(ctx context.Context, source, target Client, opts SpaceCloneOptions) (*SpaceCloneResult, error) {
+	if opts.TargetOrganization == "" {
+		opts.TargetOrganization = opts.SourceOrganization
+	}
+
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = constants.DefaultPollInterval
+	}
+
+	clone := &spaceClone{
+		source:         source,
+		target:         target,
+		opts:           opts,
+		sameFoundation: source == target,
+		apps:           map[string]string{},
+		instances:      map[string]string{},
+		domains:        map[string]*Domain{},
+		lifecycleNames: map[string]bool{},
+	}
+
+	err := clone.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	space, err := target.Spaces().Create(ctx, &SpaceCreateRequest{
+		Name:          opts.TargetSpace,
+		Relationships: SpaceRelationships{Organization: Relationship{Data: &RelationshipData{GUID: clone.targetOrg}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create space %s: %w", opts.TargetSpace, err)
+	}
+
+	clone.result = &SpaceCloneResult{Space: space}
+	clone.step(SpaceCloneResourceSpace, space.Name, SpaceCloneCreated, "")
+
+	for _, copyStep := range []func(context.Context) error{
+		clone.cloneSpaceQuota,
+		clone.cloneSecurityGroups,
+		clone.cloneRoles,
+		clone.cloneServiceInstances,
+		clone.cloneApps,
+		clone.cloneServiceBindings,
+		clone.cloneRoutes,
+		clone.startApps,
+	} {
+		err = copyStep(ctx)
+		if err != nil {
+			return clone.result, err
+		}
+	}
+
+	return clone.result, nil
+}
+
+// spaceClone carries the state of one CloneSpace call.
+type spaceClone struct {
+	source, target Client
+	opts           SpaceCloneOptions
+	sameFoundation bool
+
+	sourceSpace Space
+	targetOrg   string
+	result      *SpaceCloneResult
+
+	// apps and instances map source GUIDs to their copies.
+	apps      map[string]string
+	instances map[string]string
+	// started lists the source apps to start once cloned.
+	started []App
+	// domains caches target domains by name; nil when not found.
+	domains map[string]*Domain
+	// lifecycleNames caches whether target buildpacks and stacks exist, by
+	// "buildpack NAME" and "stack NAME".
+	lifecycleNames map[string]bool
+}
+
+func (c *spaceClone) step(resource, name string, action SpaceCloneAction, detail string) {
+	step := SpaceCloneStep{Resource: resource, Name: name, Action: action, Detail: detail}
+	c.result.Steps = append(c.result.Steps, step)
+
+	if c.opts.OnStep != nil {
+		c.opts.OnStep(step)
+	}
+}
+
+// resolve finds the source space and the target organization, checks that
+// the destination space and domain can be used, before anything is created.
+func (c *spaceClone) resolve(ctx context.Context) error {
+	sourceOrg, err := findOrganizationByName(ctx, c.source, c.opts.SourceOrganization)
+	if err != nil {
+		return err
+	}
+
+	sourceSpace, err := findSpaceByName(ctx, c.source, sourceOrg, c.opts.SourceSpace)
+	if err != nil {
+		return err
+	}
+
+	if sourceSpace == nil {
+		return fmt.Errorf("%w: space %q in organization %q", ErrNotFound, c.opts.SourceSpace, c.opts.SourceOrganization)
+	}
+
+	c.sourceSpace = *sourceSpace
+
+	c.targetOrg, err = findOrganizationByName(ctx, c.target, c.opts.TargetOrganization)
+	if err != nil {
+		return err
+	}
+
+	existing, err := findSpaceByName(ctx, c.target, c.targetOrg, c.opts.TargetSpace)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return fmt.Errorf("%w: space %q in organization %q", ErrSpaceAlreadyExists, c.opts.TargetSpace, c.opts.TargetOrganization)
+	}
+
+	if c.opts.Domain != "" {
+		domain, err := c.targetDomain(ctx, c.opts.Domain)
+		if err != nil {
+			return err
+		}
+
+		if domain == nil {
+			return fmt.Errorf("%w: domain %q", ErrNotFound, c.opts.Domain)
+		}
+	}
+
+	return nil
+}
+
+func findOrganizationByName(ctx context.Context, client Client, name string) (string, error) {
+	orgs, err := client.Organizations().List(ctx, nil, WithOrganizationNames(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to find organization %s: %w", name, err)
+	}
+
+	if len(orgs.Resources) == 0 {
+		return "", fmt.Errorf("%w: organization %q", ErrNotFound, name)
+	}
+
+	return orgs.Resources[0].GUID, nil
+}
+
+// findSpaceByName returns the named space of an organization, or nil.
+func findSpaceByName(ctx context.Context, client Client, orgGUID, name string) (*Space, error) {
+	spaces, err := client.Spaces().List(ctx, nil, WithSpaceNames(name), WithSpaceOrganizationGUIDs(orgGUID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find space %s: %w", name, err)
+	}
+
+	if len(spaces.Resources) == 0 {
+		return nil, nil //nolint:nilnil // a missing space is not an error here
+	}
+
+	return &spaces.Resources[0], nil
+}
+
+// cloneSpaceQuota applies the space quota of the same name in the target
+// organization, creating it with the same limits when there is none.
+func (c *spaceClone) cloneSpaceQuota(ctx context.Context) error {
+	quota := c.sourceSpace.Relationships.Quota
+	if quota == nil || quota.Data == nil {
+		return nil
+	}
+
+	sourceQuota, err := c.source.SpaceQuotas().Get(ctx, quota.Data.GUID)
+	if err != nil {
+		return fmt.Errorf("failed to get space quota: %w", err)
+	}
+
+	existing, err := c.target.SpaceQuotas().List(ctx, nil,
+		WithSpaceQuotaNames(sourceQuota.Name), WithSpaceQuotaOrganizationGUIDs(c.targetOrg))
+	if err != nil {
+		return fmt.Errorf("failed to find space quota %s: %w", sourceQuota.Name, err)
+	}
+
+	spaces := &ToManyRelationship{Data: []RelationshipData{{GUID: c.result.Space.GUID}}}
+
+	if len(existing.Resources) > 0 {
+		_, err = c.target.SpaceQuotas().ApplyToSpaces(ctx, existing.Resources[0].GUID, []string{c.result.Space.GUID})
+		if err != nil {
+			return fmt.Errorf("failed to apply space quota %s: %w", sourceQuota.Name, err)
+		}
+
+		c.step(SpaceCloneResourceSpaceQuota, sourceQuota.Name, SpaceCloneApplied, "")
+
+		return nil
+	}
+
+	_, err = c.target.SpaceQuotas().Create(ctx, &SpaceQuotaV3CreateRequest{
+		Name:     sourceQuota.Name,
+		Apps:     sourceQuota.Apps,
+		Services: sourceQuota.Services,
+		Routes:   sourceQuota.Routes,
+		Relationships: SpaceQuotaRelationships{
+			Organization: Relationship{Data: &RelationshipData{GUID: c.targetOrg}},
+			Spaces:       spaces,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create space quota %s: %w", sourceQuota.Name, err)
+	}
+
+	c.step(SpaceCloneResourceSpaceQuota, sourceQuota.Name, SpaceCloneCreated, "")
+
+	return nil
+}
+
+// cloneSecurityGroups binds the security groups bound to the source space
+// for running and for staging to the destination space.
+func (c *spaceClone) cloneSecurityGroups(ctx context.Context) error {
+	for _, lifecycle := range []string{"running", "staging"} {
+		option := WithSecurityGroupRunningSpaceGUIDs(c.sourceSpace.GUID)
+		bind := c.target.SecurityGroups().BindRunningSpaces
+
+		if lifecycle == "staging" {
+			option = WithSecurityGroupStagingSpaceGUIDs(c.sourceSpace.GUID)
+			bind = c.target.SecurityGroups().BindStagingSpaces
+		}
+
+		groups, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[SecurityGroup], error) {
+			return c.source.SecurityGroups().List(ctx, params, option)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list %s security groups: %w", lifecycle, err)
+		}
+
+		for _, group := range groups {
+			guid := group.GUID
+
+			if !c.sameFoundation {
+				targetGroups, err := c.target.SecurityGroups().List(ctx, nil, WithSecurityGroupNames(group.Name))
+				if err != nil {
+					return fmt.Errorf("failed to find security group %s: %w", group.Name, err)
+				}
+
+				if len(targetGroups.Resources) == 0 {
+					c.step(SpaceCloneResourceSecurityGroup, group.Name, SpaceCloneSkipped, "not found on the destination foundation")
+
+					continue
+				}
+
+				guid = targetGroups.Resources[0].GUID
+			}
+
+			_, err = bind(ctx, guid, []string{c.result.Space.GUID})
+			if err != nil {
+				return fmt.Errorf("failed to bind security group %s: %w", group.Name, err)
+			}
+
+			c.step(SpaceCloneResourceSecurityGroup, group.Name, SpaceCloneBound, lifecycle)
+		}
+	}
+
+	return nil
+}
+
+// cloneRoles gives the users of the source space the same roles in the
+// destination space, first adding them to the target organization when
+// they are not members.
+func (c *spaceClone) cloneRoles(ctx context.Context) error {
+	users := map[string]User{}
+
+	roles, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Role], error) {
+		page, err := c.source.Roles().List(ctx, params, WithRoleSpaceGUIDs(c.sourceSpace.GUID), RoleIncludeUser)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := RoleIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range included.Users {
+			users[user.GUID] = user
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list space roles: %w", err)
+	}
+
+	members, err := c.organizationMembers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		if role.Relationships.User.Data == nil {
+			continue
+		}
+
+		user := users[role.Relationships.User.Data.GUID]
+		if user.GUID == "" {
+			user.GUID = role.Relationships.User.Data.GUID
+		}
+
+		err = c.cloneRole(ctx, role.Type, user, members)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// organizationMembers returns the GUIDs of the users with a role in the
+// target organization.
+func (c *spaceClone) organizationMembers(ctx context.Context) (map[string]bool, error) {
+	roles, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Role], error) {
+		return c.target.Roles().List(ctx, params, WithRoleOrganizationGUIDs(c.targetOrg))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization roles: %w", err)
+	}
+
+	members := make(map[string]bool, len(roles))
+
+	for _, role := range roles {
+		if role.Relationships.User.Data != nil {
+			members[role.Relationships.User.Data.GUID] = true
+		}
+	}
+
+	return members, nil
+}
+
+func (c *spaceClone) cloneRole(ctx context.Context, roleType string, user User, members map[string]bool) error {
+	username := user.Username
+	if username == "" {
+		username = user.GUID
+	}
+
+	name := roleType + " " + username
+	userGUID := user.GUID
+
+	if !c.sameFoundation {
+		targetUser, err := c.targetUser(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if targetUser == "" {
+			c.step(SpaceCloneResourceRole, name, SpaceCloneSkipped, "user not found on the destination foundation")
+
+			return nil
+		}
+
+		userGUID = targetUser
+	}
+
+	if !members[userGUID] {
+		_, err := c.target.Roles().Create(ctx, &RoleCreateRequest{
+			Type: string(RoleTypeOrganizationUser),
+			Relationships: RoleRelationships{
+				User:         Relationship{Data: &RelationshipData{GUID: userGUID}},
+				Organization: &Relationship{Data: &RelationshipData{GUID: c.targetOrg}},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add user %s to organization %s: %w", userGUID, c.opts.TargetOrganization, err)
+		}
+
+		members[userGUID] = true
+
+		c.step(SpaceCloneResourceRole, string(RoleTypeOrganizationUser)+" "+username, SpaceCloneCreated, "")
+	}
+
+	_, err := c.target.Roles().Create(ctx, &RoleCreateRequest{
+		Type: roleType,
+		Relationships: RoleRelationships{
+			User:  Relationship{Data: &RelationshipData{GUID: userGUID}},
+			Space: &Relationship{Data: &RelationshipData{GUID: c.result.Space.GUID}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create role %s: %w", name, err)
+	}
+
+	c.step(SpaceCloneResourceRole, name, SpaceCloneCreated, "")
+
+	return nil
+}
+
+// targetUser returns the GUID of the user with the same username and
+// origin on the target foundation, or "" when there is none. Users without
+// a username, such as UAA clients, cannot be matched.
+func (c *spaceClone) targetUser(ctx context.Context, user User) (string, error) {
+	if user.Username == "" {
+		return "", nil
+	}
+
+	opts := []UserListOption{WithUserUsernames(user.Username)}
+	if user.Origin != "" {
+		opts = append(opts, WithUserOrigins(user.Origin))
+	}
+
+	users, err := c.target.Users().List(ctx, nil, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to find user %s: %w", user.Username, err)
+	}
+
+	if len(users.Resources) == 0 {
+		return "", nil
+	}
+
+	return users.Resources[0].GUID, nil
+}
+
+// cloneServiceInstances recreates the service instances of the source
+// space and waits for the managed ones to be provisioned.
+func (c *spaceClone) cloneServiceInstances(ctx context.Context) error {
+	plans := map[string]ServicePlan{}
+	offerings := foundationNames{}
+
+	instances, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceInstance], error) {
+		page, err := c.source.ServiceInstances().List(ctx, params,
+			WithServiceInstanceSpaceGUIDs(c.sourceSpace.GUID), ServiceInstanceIncludeServicePlanServiceOffering)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := ServiceInstanceIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, plan := range included.ServicePlans {
+			plans[plan.GUID] = plan
+		}
+
+		for _, offering := range included.ServiceOfferings {
+			offerings[offering.GUID] = offering.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list service instances: %w", err)
+	}
+
+	var jobs []*Job
+
+	for _, instance := range instances {
+		request, err := c.serviceInstanceRequest(ctx, instance, plans, offerings)
+		if err != nil {
+			return err
+		}
+
+		if request == nil {
+			continue
+		}
+
+		created, err := c.target.ServiceInstances().Create(ctx, request)
+		if err != nil {
+			return fmt.Errorf("failed to create service instance %s: %w", instance.Name, err)
+		}
+
+		if job, ok := created.(*Job); ok && job != nil && job.GUID != "" {
+			jobs = append(jobs, job)
+		}
+
+		c.step(SpaceCloneResourceServiceInstance, instance.Name, SpaceCloneCreated, request.Type)
+	}
+
+	for _, job := range jobs {
+		_, err = c.target.Jobs().PollUntilComplete(ctx, job.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to provision service instance: %w", err)
+		}
+	}
+
+	return c.mapServiceInstances(ctx, instances)
+}
+
+// serviceInstanceRequest describes the copy of instance, or returns nil
+// after recording a skipped step when its plan is not available.
+func (c *spaceClone) serviceInstanceRequest(
+	ctx context.Context, instance ServiceInstance, plans map[string]ServicePlan, offerings foundationNames,
+) (*ServiceInstanceCreateRequest, error) {
+	request := &ServiceInstanceCreateRequest{
+		Type:          instance.Type,
+		Name:          instance.Name,
+		Tags:          instance.Tags,
+		Relationships: ServiceInstanceRelationships{Space: Relationship{Data: &RelationshipData{GUID: c.result.Space.GUID}}},
+	}
+
+	if instance.Type == "user-provided" {
+		credentials, err := c.source.ServiceInstances().GetCredentials(ctx, instance.GUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credentials of service instance %s: %w", instance.Name, err)
+		}
+
+		request.Credentials = credentials.Credentials
+		request.SyslogDrainURL = instance.SyslogDrainURL
+		request.RouteServiceURL = instance.RouteServiceURL
+
+		return request, nil
+	}
+
+	if instance.Relationships.ServicePlan == nil || instance.Relationships.ServicePlan.Data == nil {
+		c.step(SpaceCloneResourceServiceInstance, instance.Name, SpaceCloneSkipped, "no service plan")
+
+		return nil, nil //nolint:nilnil // skipped
+	}
+
+	planGUID := instance.Relationships.ServicePlan.Data.GUID
+
+	if !c.sameFoundation {
+		plan := plans[planGUID]
+
+		offering := ""
+		if plan.Relationships.ServiceOffering.Data != nil {
+			offering = offerings.name(plan.Relationships.ServiceOffering.Data.GUID)
+		}
+
+		targetPlans, err := c.target.ServicePlans().List(ctx, nil,
+			WithServicePlanNames(plan.Name), WithServicePlanServiceOfferingNames(offering))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find service plan %s of %s: %w", plan.Name, offering, err)
+		}
+
+		if len(targetPlans.Resources) == 0 {
+			c.step(SpaceCloneResourceServiceInstance, instance.Name, SpaceCloneSkipped,
+				fmt.Sprintf("plan %s of %s not found on the destination foundation", plan.Name, offering))
+
+			return nil, nil //nolint:nilnil // skipped
+		}
+
+		planGUID = targetPlans.Resources[0].GUID
+	}
+
+	request.Relationships.ServicePlan = &Relationship{Data: &RelationshipData{GUID: planGUID}}
+
+	return request, nil
+}
+
+// mapServiceInstances records the copy of each source instance, matched
+// by name, as managed instances are only known by name once provisioned.
+func (c *spaceClone) mapServiceInstances(ctx context.Context, sources []ServiceInstance) error {
+	copies, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceInstance], error) {
+		return c.target.ServiceInstances().List(ctx, params, WithServiceInstanceSpaceGUIDs(c.result.Space.GUID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list cloned service instances: %w", err)
+	}
+
+	byName := make(map[string]string, len(copies))
+	for _, instance := range copies {
+		byName[instance.Name] = instance.GUID
+	}
+
+	for _, instance := range sources {
+		if guid, ok := byName[instance.Name]; ok {
+			c.instances[instance.GUID] = guid
+		}
+	}
+
+	return nil
+}
+
+// cloneApps recreates the apps of the source space with their environment
+// variables and current droplet, and scales their processes.
+func (c *spaceClone) cloneApps(ctx context.Context) error {
+	apps, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[App], error) {
+		return c.source.Apps().List(ctx, params, WithAppSpaceGUIDs(c.sourceSpace.GUID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list apps: %w", err)
+	}
+
+	processes, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Process], error) {
+		return c.source.Processes().List(ctx, params, WithProcessSpaceGUIDs(c.sourceSpace.GUID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	byApp := map[string][]Process{}
+
+	for _, process := range processes {
+		if process.Relationships != nil && process.Relationships.App != nil && process.Relationships.App.Data != nil {
+			appGUID := process.Relationships.App.Data.GUID
+			byApp[appGUID] = append(byApp[appGUID], process)
+		}
+	}
+
+	for _, app := range apps {
+		err = c.cloneApp(ctx, app, byApp[app.GUID])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *spaceClone) cloneApp(ctx context.Context, app App, processes []Process) error {
+	env, err := c.source.Apps().GetEnvVars(ctx, app.GUID)
+	if err != nil {
+		return fmt.Errorf("failed to get environment variables of app %s: %w", app.Name, err)
+	}
+
+	lifecycle, missing, err := c.targetLifecycle(ctx, app.Lifecycle)
+	if err != nil {
+		return fmt.Errorf("failed to match lifecycle of app %s: %w", app.Name, err)
+	}
+
+	created, err := c.target.Apps().Create(ctx, &AppCreateRequest{
+		Name:                 app.Name,
+		Relationships:        AppRelationships{Space: Relationship{Data: &RelationshipData{GUID: c.result.Space.GUID}}},
+		Lifecycle:            &lifecycle,
+		EnvironmentVariables: env,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create app %s: %w", app.Name, err)
+	}
+
+	c.apps[app.GUID] = created.GUID
+	c.step(SpaceCloneResourceApp, app.Name, SpaceCloneCreated, "")
+
+	for _, name := range missing {
+		c.step(SpaceCloneResourceApp, app.Name, SpaceCloneSkipped, name+" not found on the destination foundation")
+	}
+
+	staged, err := c.cloneDroplet(ctx, app, created.GUID)
+	if err != nil {
+		return err
+	}
+
+	err = c.scaleProcesses(ctx, app.Name, created.GUID, processes)
+	if err != nil {
+		return err
+	}
+
+	if staged && app.State == "STARTED" {
+		c.started = append(c.started, app)
+	}
+
+	return nil
+}
+
+// targetLifecycle returns the lifecycle for the copy of an app. Across
+// foundations the buildpacks and stack are matched by name; the ones the
+// target does not have are left out, so it uses its defaults, and returned
+// as missing. Buildpacks given by URL are kept.
+func (c *spaceClone) targetLifecycle(ctx context.Context, lifecycle Lifecycle) (Lifecycle, []string, error) {
+	if c.sameFoundation || lifecycle.Data == nil {
+		return lifecycle, nil, nil
+	}
+
+	data := make(map[string]interface{}, len(lifecycle.Data))
+	for key, value := range lifecycle.Data {
+		data[key] = value
+	}
+
+	var missing []string
+
+	if buildpacks, ok := lifecycle.Data["buildpacks"].([]interface{}); ok {
+		kept := []interface{}{}
+
+		for _, buildpack := range buildpacks {
+			name, ok := buildpack.(string)
+			if ok && !strings.Contains(name, "://") {
+				found, err := c.targetHasLifecycleName(ctx, "buildpack", name)
+				if err != nil {
+					return Lifecycle{}, nil, err
+				}
+
+				if !found {
+					missing = append(missing, "buildpack "+name)
+
+					continue
+				}
+			}
+
+			kept = append(kept, buildpack)
+		}
+
+		data["buildpacks"] = kept
+	}
+
+	if stack, ok := lifecycle.Data["stack"].(string); ok && stack != "" {
+		found, err := c.targetHasLifecycleName(ctx, "stack", stack)
+		if err != nil {
+			return Lifecycle{}, nil, err
+		}
+
+		if !found {
+			missing = append(missing, "stack "+stack)
+
+			delete(data, "stack")
+		}
+	}
+
+	return Lifecycle{Type: lifecycle.Type, Data: data}, missing, nil
+}
+
+// targetHasLifecycleName reports whether the target has the buildpack or
+// stack of that name.
+func (c *spaceClone) targetHasLifecycleName(ctx context.Context, kind, name string) (bool, error) {
+	key := kind + " " + name
+	if found, ok := c.lifecycleNames[key]; ok {
+		return found, nil
+	}
+
+	var found bool
+
+	if kind == "stack" {
+		stacks, err := c.target.Stacks().List(ctx, nil, WithStackNames(name))
+		if err != nil {
+			return false, fmt.Errorf("failed to find stack %s: %w", name, err)
+		}
+
+		found = len(stacks.Resources) > 0
+	} else {
+		buildpacks, err := c.target.Buildpacks().List(ctx, nil, WithBuildpackNames(name))
+		if err != nil {
+			return false, fmt.Errorf("failed to find buildpack %s: %w", name, err)
+		}
+
+		found = len(buildpacks.Resources) > 0
+	}
+
+	c.lifecycleNames[key] = found
+
+	return found, nil
+}
+
+// cloneDroplet gives the copy of app the current droplet of app and
+// reports whether it has one.
+func (c *spaceClone) cloneDroplet(ctx context.Context, app App, appGUID string) (bool, error) {
+	droplet, err := c.source.Apps().GetCurrentDroplet(ctx, app.GUID)
+	if IsNotFound(err) {
+		c.step(SpaceCloneResourceApp, app.Name, SpaceCloneSkipped, "no current droplet")
+
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to get current droplet of app %s: %w", app.Name, err)
+	}
+
+	relationships := DropletRelationships{App: &Relationship{Data: &RelationshipData{GUID: appGUID}}}
+
+	var copied *Droplet
+
+	switch {
+	case c.sameFoundation:
+		copied, err = c.target.Droplets().Copy(ctx, droplet.GUID, &DropletCopyRequest{Relationships: relationships})
+	case droplet.Lifecycle.Type == "docker":
+		c.step(SpaceCloneResourceApp, app.Name, SpaceCloneSkipped, "docker droplets cannot be downloaded; push the image to the destination")
+
+		return false, nil
+	default:
+		copied, err = c.transferDroplet(ctx, droplet, relationships)
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to copy droplet of app %s: %w", app.Name, err)
+	}
+
+	err = c.waitForDroplet(ctx, copied)
+	if err != nil {
+		return false, fmt.Errorf("failed to copy droplet of app %s: %w", app.Name, err)
+	}
+
+	_, err = c.target.Apps().SetCurrentDroplet(ctx, appGUID, copied.GUID)
+	if err != nil {
+		return false, fmt.Errorf("failed to set current droplet of app %s: %w", app.Name, err)
+	}
+
+	c.step(SpaceCloneResourceApp, app.Name, SpaceCloneCopied, "droplet")
+
+	return true, nil
+}
+
+// transferDroplet downloads a droplet from the source foundation and
+// uploads it to a new droplet on the target foundation. The droplet is
+// held in memory in between.
+func (c *spaceClone) transferDroplet(ctx context.Context, droplet *Droplet, relationships DropletRelationships) (*Droplet, error) {
+	bits, err := c.source.Droplets().Download(ctx, droplet.GUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download droplet: %w", err)
+	}
+
+	created, err := c.target.Droplets().Create(ctx, &DropletCreateRequest{
+		Relationships: relationships,
+		ProcessTypes:  droplet.ProcessTypes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create droplet: %w", err)
+	}
+
+	uploaded, err := c.target.Droplets().Upload(ctx, created.GUID, bits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload droplet: %w", err)
+	}
+
+	return uploaded, nil
+}
+
+// waitForDroplet polls a copied or uploaded droplet until it is staged.
+func (c *spaceClone) waitForDroplet(ctx context.Context, droplet *Droplet) error {
+	for {
+		switch DropletState(droplet.State) {
+		case DropletStateStaged:
+			return nil
+		case DropletStateFailed, DropletStateExpired:
+			return fmt.Errorf("%w: droplet %s is %s", ErrDropletCopyFailed, droplet.GUID, droplet.State)
+		default:
+		}
+
+		err := sleepContext(ctx, c.opts.PollInterval)
+		if err != nil {
+			return err
+		}
+
+		droplet, err = c.target.Droplets().Get(ctx, droplet.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to get droplet: %w", err)
+		}
+	}
+}
+
+// scaleProcesses gives the processes of the copy of an app the scale of
+// the source processes of the same type.
+func (c *spaceClone) scaleProcesses(ctx context.Context, appName, appGUID string, sources []Process) error {
+	copies, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Process], error) {
+		return c.target.Processes().List(ctx, params, WithProcessAppGUIDs(appGUID))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list processes of app %s: %w", appName, err)
+	}
+
+	for _, source := range sources {
+		index := slices.IndexFunc(copies, func(process Process) bool { return process.Type == source.Type })
+		if index < 0 {
+			c.step(SpaceCloneResourceApp, appName, SpaceCloneSkipped, "no "+source.Type+" process")
+
+			continue
+		}
+
+		_, err = c.target.Processes().Scale(ctx, copies[index].GUID, &ProcessScaleRequest{
+			Instances:                    &source.Instances,
+			MemoryInMB:                   &source.MemoryInMB,
+			DiskInMB:                     &source.DiskInMB,
+			LogRateLimitInBytesPerSecond: source.LogRateLimitInBytesPerSecond,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scale %s process of app %s: %w", source.Type, appName, err)
+		}
+
+		c.step(SpaceCloneResourceApp, appName, SpaceCloneScaled,
+			fmt.Sprintf("%s: %d x %dM", source.Type, source.Instances, source.MemoryInMB))
+	}
+
+	return nil
+}
+
+// cloneServiceBindings binds the copies of the apps to the copies of the
+// service instances they were bound to. Service keys are not copied.
+func (c *spaceClone) cloneServiceBindings(ctx context.Context) error {
+	if len(c.apps) == 0 {
+		return nil
+	}
+
+	appGUIDs := make([]string, 0, len(c.apps))
+	for guid := range c.apps {
+		appGUIDs = append(appGUIDs, guid)
+	}
+
+	slices.Sort(appGUIDs)
+
+	bindings, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[ServiceCredentialBinding], error) {
+		return c.source.ServiceCredentialBindings().List(ctx, params,
+			WithServiceCredentialBindingAppGUIDs(appGUIDs...), WithServiceCredentialBindingType(ServiceCredentialBindingTypeApp))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list service bindings: %w", err)
+	}
+
+	for _, binding := range bindings {
+		err = c.cloneServiceBinding(ctx, binding)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *spaceClone) cloneServiceBinding(ctx context.Context, binding ServiceCredentialBinding) error {
+	relationships := binding.Relationships
+	if relationships.App == nil || relationships.App.Data == nil || relationships.ServiceInstance.Data == nil {
+		return nil
+	}
+
+	appGUID := c.apps[relationships.App.Data.GUID]
+	instanceGUID, ok := c.instances[relationships.ServiceInstance.Data.GUID]
+
+	if !ok {
+		c.step(SpaceCloneResourceServiceBinding, binding.GUID, SpaceCloneSkipped, "service instance not cloned")
+
+		return nil
+	}
+
+	request := &ServiceCredentialBindingCreateRequest{
+		Type: string(ServiceCredentialBindingTypeApp),
+		Relationships: ServiceCredentialBindingRelationships{
+			App:             &Relationship{Data: &RelationshipData{GUID: appGUID}},
+			ServiceInstance: Relationship{Data: &RelationshipData{GUID: instanceGUID}},
+		},
+	}
+
+	if binding.Name != "" {
+		request.Name = &binding.Name
+	}
+
+	created, err := c.target.ServiceCredentialBindings().Create(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to create service binding %s: %w", binding.GUID, err)
+	}
+
+	if job, ok := created.(*Job); ok && job != nil && job.GUID != "" {
+		_, err = c.target.Jobs().PollUntilComplete(ctx, job.GUID)
+		if err != nil {
+			return fmt.Errorf("failed to create service binding %s: %w", binding.GUID, err)
+		}
+	}
+
+	c.step(SpaceCloneResourceServiceBinding, binding.GUID, SpaceCloneCreated, "")
+
+	return nil
+}
+
+// cloneRoutes recreates the routes of the source space, on opts.Domain
+// when set, and maps them to the copies of their apps.
+func (c *spaceClone) cloneRoutes(ctx context.Context) error {
+	domains := foundationNames{}
+
+	routes, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Route], error) {
+		page, err := c.source.Routes().List(ctx, params, WithRouteSpaceGUIDs(c.sourceSpace.GUID), RouteIncludeDomain)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by CollectAllPages
+		}
+
+		included, err := RouteIncludedFrom(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, domain := range included.Domains {
+			domains[domain.GUID] = domain.Name
+		}
+
+		return page, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list routes: %w", err)
+	}
+
+	for _, route := range routes {
+		domainName := c.opts.Domain
+		if domainName == "" && route.Relationships.Domain.Data != nil {
+			domainName = domains.name(route.Relationships.Domain.Data.GUID)
+		}
+
+		err = c.cloneRoute(ctx, route, domainName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *spaceClone) cloneRoute(ctx context.Context, route Route, domainName string) error {
+	url := routeURL(route.Host, domainName, route.Path, route.Port)
+
+	domain, err := c.targetDomain(ctx, domainName)
+	if err != nil {
+		return err
+	}
+
+	if domain == nil {
+		c.step(SpaceCloneResourceRoute, url, SpaceCloneSkipped, "domain not found on the destination foundation")
+
+		return nil
+	}
+
+	exists, err := c.targetRouteExists(ctx, route, domain.GUID)
+	if err != nil {
+		return fmt.Errorf("failed to find route %s: %w", url, err)
+	}
+
+	if exists {
+		c.step(SpaceCloneResourceRoute, url, SpaceCloneSkipped, "route already exists on the destination foundation")
+
+		return nil
+	}
+
+	request := &RouteCreateRequest{
+		Port: route.Port,
+		Relationships: RouteRelationships{
+			Space:  Relationship{Data: &RelationshipData{GUID: c.result.Space.GUID}},
+			Domain: Relationship{Data: &RelationshipData{GUID: domain.GUID}},
+		},
+	}
+
+	if route.Host != "" {
+		request.Host = &route.Host
+	}
+
+	if route.Path != "" {
+		request.Path = &route.Path
+	}
+
+	created, err := c.target.Routes().Create(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to create route %s: %w", url, err)
+	}
+
+	c.step(SpaceCloneResourceRoute, url, SpaceCloneCreated, "")
+
+	var destinations []RouteDestination
+
+	for _, destination := range route.Destinations {
+		appGUID, ok := c.apps[destination.App.GUID]
+		if !ok {
+			continue
+		}
+
+		mapped := RouteDestination{App: RouteDestinationApp{GUID: appGUID}, Port: destination.Port, Protocol: destination.Protocol}
+		if destination.App.Process != nil {
+			mapped.App.Process = &Process{Type: destination.App.Process.Type}
+		}
+
+		destinations = append(destinations, mapped)
+	}
+
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	_, err = c.target.Routes().InsertDestinations(ctx, created.GUID, destinations)
+	if err != nil {
+		return fmt.Errorf("failed to map route %s: %w", url, err)
+	}
+
+	c.step(SpaceCloneResourceRoute, url, SpaceCloneMapped, strconv.Itoa(len(destinations))+" destinations")
+
+	return nil
+}
+
+// targetRouteExists reports whether the target already has a route with
+// the host, path and port of route on the domain.
+func (c *spaceClone) targetRouteExists(ctx context.Context, route Route, domainGUID string) (bool, error) {
+	opts := []RouteListOption{WithRouteDomainGUIDs(domainGUID)}
+	if route.Host != "" {
+		opts = append(opts, WithRouteHosts(route.Host))
+	}
+
+	if route.Path != "" {
+		opts = append(opts, WithRoutePaths(route.Path))
+	}
+
+	existing, err := CollectAllPages(ctx, nil, func(ctx context.Context, params *QueryParams) (*ListResponse[Route], error) {
+		return c.target.Routes().List(ctx, params, opts...)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(existing, func(other Route) bool {
+		return other.Host == route.Host && other.Path == route.Path &&
+			(other.Port == nil) == (route.Port == nil) && (route.Port == nil || *other.Port == *route.Port)
+	}), nil
+}
+
+// targetDomain returns the target domain of that name, or nil.
+func (c *spaceClone) targetDomain(ctx context.Context, name string) (*Domain, error) {
+	if domain, ok := c.domains[name]; ok {
+		return domain, nil
+	}
+
+	domains, err := c.target.Domains().List(ctx, nil, WithDomainNames(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find domain %s: %w", name, err)
+	}
+
+	var domain *Domain
+	if len(domains.Resources) > 0 {
+		domain = &domains.Resources[0]
+	}
+
+	c.domains[name] = domain
+
+	return domain, nil
+}
+
+func routeURL(host, domain, path string, port *int) string {
+	url := domain
+	if host != "" {
+		url = host + "." + domain
+	}
+
+	if port != nil {
+		url += ":" + strconv.Itoa(*port)
+	}
+
+	return url + path
+}
+
+// startApps starts the copies of the apps that were started.
+func (c *spaceClone) startApps(ctx context.Context) error {
+	for _, app := range c.started {
+		_, err := c.target.Apps().Start(ctx, c.apps[app.GUID])
+		if err != nil {
+			return fmt.Errorf("failed to start app %s: %w", app.Name, err)
+		}
+
+		c.step(SpaceCloneResourceApp, app.Name, SpaceCloneStarted, "")
+	}
+
+	return nil
+}
@@ -0,0 +1,741 @@
+package capi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cloneFoundation is an in-memory foundation holding the space "dev" of
+// organization "payments", with two apps, two service instances and a
+// route. Every write is recorded in log, shared between foundations.
+type cloneFoundation struct {
+	log *[]string
+
+	existingSpace bool
+	existingQuota bool
+	// members are the users with a role in the organization.
+	members []string
+	// users maps usernames to user GUIDs for Users().List.
+	users map[string]string
+	// missingGroup is a security group name that cannot be found by name.
+	missingGroup string
+	// buildpacks and stacks are the names Buildpacks().List and
+	// Stacks().List find.
+	buildpacks []string
+	stacks     []string
+	// routes are the routes of the foundation as "host path domain-GUID".
+	// Unless set, the foundation has the route of "dev".
+	routes map[string]bool
+}
+
+func (f *cloneFoundation) record(format string, args ...interface{}) {
+	*f.log = append(*f.log, fmt.Sprintf(format, args...))
+}
+
+func cloneQuery[O capi.QueryOption](opts []O) url.Values {
+	return capi.ApplyQueryOptions(url.Values{}, opts)
+}
+
+func newCloneClient(f *cloneFoundation) *stubClient {
+	if f.routes == nil {
+		f.routes = map[string]bool{"api /v1 d-apps.example.com": true}
+	}
+
+	return &stubClient{
+		organizations:    &cloneOrgs{},
+		spaces:           &cloneSpaces{f: f},
+		spaceQuotas:      &cloneSpaceQuotas{f: f},
+		securityGroups:   &cloneSecurityGroups{f: f},
+		roles:            &cloneRoles{f: f},
+		users:            &cloneUsers{f: f},
+		serviceInstances: &cloneServiceInstances{f: f},
+		servicePlans:     &cloneServicePlans{},
+		jobs:             &cloneJobs{f: f},
+		apps:             &cloneApps{f: f},
+		droplets:         &cloneDroplets{f: f},
+		processes:        &cloneProcesses{f: f},
+		bindings:         &cloneBindings{f: f},
+		routes:           &cloneRoutes{f: f},
+		domains:          &cloneDomains{},
+		buildpacks:       &cloneBuildpacks{f: f},
+		stacks:           &cloneStacks{f: f},
+	}
+}
+
+func cloneRelationship(guid string) capi.Relationship {
+	return capi.Relationship{Data: &capi.RelationshipData{GUID: guid}}
+}
+
+type cloneOrgs struct {
+	capi.OrganizationsClient
+}
+
+func (o *cloneOrgs) List(_ context.Context, _ *capi.QueryParams, opts ...capi.OrganizationListOption) (*capi.ListResponse[capi.Organization], error) {
+	return &capi.ListResponse[capi.Organization]{Resources: []capi.Organization{
+		{Resource: capi.Resource{GUID: "org-1"}, Name: cloneQuery(opts).Get("names")},
+	}}, nil
+}
+
+type cloneSpaces struct {
+	capi.SpacesClient
+
+	f *cloneFoundation
+}
+
+func (s *cloneSpaces) List(_ context.Context, _ *capi.QueryParams, opts ...capi.SpaceListOption) (*capi.ListResponse[capi.Space], error) {
+	quota := cloneRelationship("quota-1")
+
+	switch cloneQuery(opts).Get("names") {
+	case "dev":
+		return &capi.ListResponse[capi.Space]{Resources: []capi.Space{{
+			Resource:      capi.Resource{GUID: "space-1"},
+			Name:          "dev",
+			Relationships: capi.SpaceRelationships{Organization: cloneRelationship("org-1"), Quota: &quota},
+		}}}, nil
+	case "feature-x":
+		if s.f.existingSpace {
+			return &capi.ListResponse[capi.Space]{Resources: []capi.Space{{Resource: capi.Resource{GUID: "space-2"}}}}, nil
+		}
+	}
+
+	return &capi.ListResponse[capi.Space]{}, nil
+}
+
+func (s *cloneSpaces) Create(_ context.Context, request *capi.SpaceCreateRequest) (*capi.Space, error) {
+	s.f.record("create space %s in %s", request.Name, request.Relationships.Organization.Data.GUID)
+
+	return &capi.Space{Resource: capi.Resource{GUID: "space-2"}, Name: request.Name}, nil
+}
+
+type cloneSpaceQuotas struct {
+	capi.SpaceQuotasClient
+
+	f *cloneFoundation
+}
+
+func (q *cloneSpaceQuotas) Get(_ context.Context, guid string) (*capi.SpaceQuotaV3, error) {
+	memory := 1024
+
+	return &capi.SpaceQuotaV3{
+		Resource: capi.Resource{GUID: guid},
+		Name:     "small",
+		Apps:     &capi.SpaceQuotaApps{TotalMemoryInMB: &memory},
+	}, nil
+}
+
+func (q *cloneSpaceQuotas) List(_ context.Context, _ *capi.QueryParams, _ ...capi.SpaceQuotaListOption) (*capi.ListResponse[capi.SpaceQuotaV3], error) {
+	if !q.f.existingQuota {
+		return &capi.ListResponse[capi.SpaceQuotaV3]{}, nil
+	}
+
+	return &capi.ListResponse[capi.SpaceQuotaV3]{Resources: []capi.SpaceQuotaV3{{Resource: capi.Resource{GUID: "quota-1"}, Name: "small"}}}, nil
+}
+
+func (q *cloneSpaceQuotas) ApplyToSpaces(_ context.Context, quotaGUID string, spaceGUIDs []string) (*capi.ToManyRelationship, error) {
+	q.f.record("apply space quota %s to %v", quotaGUID, spaceGUIDs)
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+func (q *cloneSpaceQuotas) Create(_ context.Context, request *capi.SpaceQuotaV3CreateRequest) (*capi.SpaceQuotaV3, error) {
+	q.f.record("create space quota %s with %dM for %s", request.Name, *request.Apps.TotalMemoryInMB, request.Relationships.Spaces.Data[0].GUID)
+
+	return &capi.SpaceQuotaV3{Name: request.Name}, nil
+}
+
+type cloneSecurityGroups struct {
+	capi.SecurityGroupsClient
+
+	f *cloneFoundation
+}
+
+func (g *cloneSecurityGroups) List(_ context.Context, _ *capi.QueryParams, opts ...capi.SecurityGroupListOption) (*capi.ListResponse[capi.SecurityGroup], error) {
+	query := cloneQuery(opts)
+
+	var groups []capi.SecurityGroup
+
+	switch {
+	case query.Get("running_space_guids") == "space-1":
+		groups = []capi.SecurityGroup{{Resource: capi.Resource{GUID: "sg-1"}, Name: "public"}}
+	case query.Get("staging_space_guids") == "space-1":
+		groups = []capi.SecurityGroup{{Resource: capi.Resource{GUID: "sg-2"}, Name: "dns"}}
+	case query.Get("names") != "" && query.Get("names") != g.f.missingGroup:
+		groups = []capi.SecurityGroup{{Resource: capi.Resource{GUID: "t-" + query.Get("names")}}}
+	}
+
+	return &capi.ListResponse[capi.SecurityGroup]{Resources: groups}, nil
+}
+
+func (g *cloneSecurityGroups) BindRunningSpaces(_ context.Context, guid string, spaceGUIDs []string) (*capi.ToManyRelationship, error) {
+	g.f.record("bind running %s to %v", guid, spaceGUIDs)
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+func (g *cloneSecurityGroups) BindStagingSpaces(_ context.Context, guid string, spaceGUIDs []string) (*capi.ToManyRelationship, error) {
+	g.f.record("bind staging %s to %v", guid, spaceGUIDs)
+
+	return &capi.ToManyRelationship{}, nil
+}
+
+type cloneRoles struct {
+	capi.RolesClient
+
+	f *cloneFoundation
+}
+
+func (r *cloneRoles) List(_ context.Context, _ *capi.QueryParams, opts ...capi.RoleListOption) (*capi.ListResponse[capi.Role], error) {
+	query := cloneQuery(opts)
+
+	if query.Get("space_guids") == "space-1" {
+		included, err := encodeIncluded(map[string][]interface{}{
+			"users": {capi.User{Resource: capi.Resource{GUID: "u-alice"}, Username: "alice", Origin: "uaa"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &capi.ListResponse[capi.Role]{
+			Resources: []capi.Role{{
+				Type:          "space_developer",
+				Relationships: capi.RoleRelationships{User: cloneRelationship("u-alice")},
+			}},
+			Included: included,
+		}, nil
+	}
+
+	roles := make([]capi.Role, 0, len(r.f.members))
+	for _, member := range r.f.members {
+		roles = append(roles, orgRole("role-"+member, "organization_user", member, "org-1"))
+	}
+
+	return &capi.ListResponse[capi.Role]{Resources: roles}, nil
+}
+
+func (r *cloneRoles) Create(_ context.Context, request *capi.RoleCreateRequest) (*capi.Role, error) {
+	scope := request.Relationships.Organization
+	if request.Relationships.Space != nil {
+		scope = request.Relationships.Space
+	}
+
+	r.f.record("create role %s for %s in %s", request.Type, request.Relationships.User.Data.GUID, scope.Data.GUID)
+
+	return &capi.Role{}, nil
+}
+
+type cloneUsers struct {
+	capi.UsersClient
+
+	f *cloneFoundation
+}
+
+func (u *cloneUsers) List(_ context.Context, _ *capi.QueryParams, opts ...capi.UserListOption) (*capi.ListResponse[capi.User], error) {
+	guid, ok := u.f.users[cloneQuery(opts).Get("usernames")]
+	if !ok {
+		return &capi.ListResponse[capi.User]{}, nil
+	}
+
+	return &capi.ListResponse[capi.User]{Resources: []capi.User{{Resource: capi.Resource{GUID: guid}}}}, nil
+}
+
+type cloneServiceInstances struct {
+	capi.ServiceInstancesClient
+
+	f *cloneFoundation
+}
+
+func (s *cloneServiceInstances) List(_ context.Context, _ *capi.QueryParams, opts ...capi.ServiceInstanceListOption) (*capi.ListResponse[capi.ServiceInstance], error) {
+	if cloneQuery(opts).Get("space_guids") == "space-2" {
+		return &capi.ListResponse[capi.ServiceInstance]{Resources: []capi.ServiceInstance{
+			{Resource: capi.Resource{GUID: "si-1-copy"}, Name: "creds"},
+			{Resource: capi.Resource{GUID: "si-2-copy"}, Name: "db"},
+		}}, nil
+	}
+
+	included, err := encodeIncluded(map[string][]interface{}{
+		"service_plans": {capi.ServicePlan{
+			Resource:      capi.Resource{GUID: "plan-1"},
+			Name:          "small",
+			Relationships: capi.ServicePlanRelationships{ServiceOffering: cloneRelationship("offering-1")},
+		}},
+		"service_offerings": {capi.ServiceOffering{Resource: capi.Resource{GUID: "offering-1"}, Name: "postgres"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	drain := "syslog://logs.example.com"
+	plan := cloneRelationship("plan-1")
+
+	return &capi.ListResponse[capi.ServiceInstance]{
+		Resources: []capi.ServiceInstance{
+			{Resource: capi.Resource{GUID: "si-1"}, Name: "creds", Type: "user-provided", SyslogDrainURL: &drain},
+			{Resource: capi.Resource{GUID: "si-2"}, Name: "db", Type: "managed", Tags: []string{"sql"},
+				Relationships: capi.ServiceInstanceRelationships{ServicePlan: &plan}},
+		},
+		Included: included,
+	}, nil
+}
+
+func (s *cloneServiceInstances) GetCredentials(_ context.Context, _ string) (*capi.ServiceInstanceCredentials, error) {
+	return &capi.ServiceInstanceCredentials{Credentials: map[string]interface{}{"password": "s3cret"}}, nil
+}
+
+func (s *cloneServiceInstances) Create(_ context.Context, request *capi.ServiceInstanceCreateRequest) (interface{}, error) {
+	if request.Type == "user-provided" {
+		s.f.record("create user-provided service instance %s with %v and drain %s",
+			request.Name, request.Credentials, *request.SyslogDrainURL)
+
+		return &capi.ServiceInstance{}, nil
+	}
+
+	s.f.record("create managed service instance %s on %s tagged %v", request.Name, request.Relationships.ServicePlan.Data.GUID, request.Tags)
+
+	return &capi.Job{Resource: capi.Resource{GUID: "job-" + request.Name}}, nil
+}
+
+type cloneServicePlans struct {
+	capi.ServicePlansClient
+}
+
+func (p *cloneServicePlans) List(_ context.Context, _ *capi.QueryParams, opts ...capi.ServicePlanListOption) (*capi.ListResponse[capi.ServicePlan], error) {
+	query := cloneQuery(opts)
+	if query.Get("names") != "small" || query.Get("service_offering_names") != "postgres" {
+		return &capi.ListResponse[capi.ServicePlan]{}, nil
+	}
+
+	return &capi.ListResponse[capi.ServicePlan]{Resources: []capi.ServicePlan{{Resource: capi.Resource{GUID: "t-plan-1"}}}}, nil
+}
+
+type cloneJobs struct {
+	capi.JobsClient
+
+	f *cloneFoundation
+}
+
+func (j *cloneJobs) PollUntilComplete(_ context.Context, guid string) (*capi.Job, error) {
+	j.f.record("wait for %s", guid)
+
+	return &capi.Job{State: "COMPLETE"}, nil
+}
+
+// cloneApps serves the started app "api", with a droplet, and the stopped
+// app "worker", without one.
+type cloneApps struct {
+	capi.AppsClient
+
+	f *cloneFoundation
+}
+
+func (a *cloneApps) List(_ context.Context, _ *capi.QueryParams, _ ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	lifecycle := capi.Lifecycle{Type: "buildpack", Data: map[string]interface{}{
+		"stack":      "cflinuxfs4",
+		"buildpacks": []interface{}{"ruby_buildpack", "go_buildpack", "https://example.com/bp.git"},
+	}}
+
+	return &capi.ListResponse[capi.App]{Resources: []capi.App{
+		{Resource: capi.Resource{GUID: "app-api"}, Name: "api", State: "STARTED", Lifecycle: lifecycle},
+		{Resource: capi.Resource{GUID: "app-worker"}, Name: "worker", State: "STOPPED", Lifecycle: lifecycle},
+	}}, nil
+}
+
+func (a *cloneApps) GetEnvVars(_ context.Context, guid string) (map[string]interface{}, error) {
+	return map[string]interface{}{"SOURCE": guid}, nil
+}
+
+func (a *cloneApps) Create(_ context.Context, request *capi.AppCreateRequest) (*capi.App, error) {
+	a.f.record("create app %s in %s with %v on %v %v", request.Name, request.Relationships.Space.Data.GUID,
+		request.EnvironmentVariables, request.Lifecycle.Data["stack"], request.Lifecycle.Data["buildpacks"])
+
+	return &capi.App{Resource: capi.Resource{GUID: "new-" + request.Name}, Name: request.Name}, nil
+}
+
+func (a *cloneApps) GetCurrentDroplet(_ context.Context, guid string) (*capi.Droplet, error) {
+	if guid != "app-api" {
+		return nil, capi.ErrNotFound
+	}
+
+	return &capi.Droplet{
+		Resource:     capi.Resource{GUID: "droplet-1"},
+		Lifecycle:    capi.Lifecycle{Type: "buildpack"},
+		ProcessTypes: map[string]string{"web": "bundle exec puma"},
+	}, nil
+}
+
+func (a *cloneApps) SetCurrentDroplet(_ context.Context, guid, dropletGUID string) (*capi.Relationship, error) {
+	a.f.record("set droplet %s on %s", dropletGUID, guid)
+
+	return &capi.Relationship{}, nil
+}
+
+func (a *cloneApps) Start(_ context.Context, guid string) (*capi.Job, error) {
+	a.f.record("start %s", guid)
+
+	return &capi.Job{}, nil
+}
+
+// cloneDroplets reports copied and uploaded droplets as still processing
+// until they are read back.
+type cloneDroplets struct {
+	capi.DropletsClient
+
+	f *cloneFoundation
+}
+
+func (d *cloneDroplets) Copy(_ context.Context, sourceGUID string, request *capi.DropletCopyRequest) (*capi.Droplet, error) {
+	d.f.record("copy droplet %s to %s", sourceGUID, request.Relationships.App.Data.GUID)
+
+	return &capi.Droplet{Resource: capi.Resource{GUID: "droplet-2"}, State: string(capi.DropletStateCopying)}, nil
+}
+
+func (d *cloneDroplets) Download(_ context.Context, guid string) ([]byte, error) {
+	d.f.record("download droplet %s", guid)
+
+	return []byte("bits"), nil
+}
+
+func (d *cloneDroplets) Create(_ context.Context, request *capi.DropletCreateRequest) (*capi.Droplet, error) {
+	d.f.record("create droplet for %s with %v", request.Relationships.App.Data.GUID, request.ProcessTypes)
+
+	return &capi.Droplet{Resource: capi.Resource{GUID: "droplet-2"}, State: string(capi.DropletStateAwaitingUpload)}, nil
+}
+
+func (d *cloneDroplets) Upload(_ context.Context, guid string, bits []byte) (*capi.Droplet, error) {
+	d.f.record("upload %d bytes to %s", len(bits), guid)
+
+	return &capi.Droplet{Resource: capi.Resource{GUID: guid}, State: string(capi.DropletStateProcessingUpload)}, nil
+}
+
+func (d *cloneDroplets) Get(_ context.Context, guid string) (*capi.Droplet, error) {
+	return &capi.Droplet{Resource: capi.Resource{GUID: guid}, State: string(capi.DropletStateStaged)}, nil
+}
+
+type cloneBuildpacks struct {
+	capi.BuildpacksClient
+
+	f *cloneFoundation
+}
+
+func (b *cloneBuildpacks) List(_ context.Context, _ *capi.QueryParams, opts ...capi.BuildpackListOption) (*capi.ListResponse[capi.Buildpack], error) {
+	name := cloneQuery(opts).Get("names")
+	if !slices.Contains(b.f.buildpacks, name) {
+		return &capi.ListResponse[capi.Buildpack]{}, nil
+	}
+
+	return &capi.ListResponse[capi.Buildpack]{Resources: []capi.Buildpack{{Name: name}}}, nil
+}
+
+type cloneStacks struct {
+	capi.StacksClient
+
+	f *cloneFoundation
+}
+
+func (s *cloneStacks) List(_ context.Context, _ *capi.QueryParams, opts ...capi.StackListOption) (*capi.ListResponse[capi.Stack], error) {
+	name := cloneQuery(opts).Get("names")
+	if !slices.Contains(s.f.stacks, name) {
+		return &capi.ListResponse[capi.Stack]{}, nil
+	}
+
+	return &capi.ListResponse[capi.Stack]{Resources: []capi.Stack{{Name: name}}}, nil
+}
+
+type cloneProcesses struct {
+	capi.ProcessesClient
+
+	f *cloneFoundation
+}
+
+func (p *cloneProcesses) List(_ context.Context, _ *capi.QueryParams, opts ...capi.ProcessListOption) (*capi.ListResponse[capi.Process], error) {
+	query := cloneQuery(opts)
+
+	if query.Get("app_guids") == "new-api" {
+		return &capi.ListResponse[capi.Process]{Resources: []capi.Process{{Resource: capi.Resource{GUID: "new-api-web"}, Type: "web"}}}, nil
+	}
+
+	if query.Get("space_guids") != "space-1" {
+		return &capi.ListResponse[capi.Process]{}, nil
+	}
+
+	app := &capi.ProcessRelationships{App: &capi.Relationship{Data: &capi.RelationshipData{GUID: "app-api"}}}
+
+	return &capi.ListResponse[capi.Process]{Resources: []capi.Process{
+		{Type: "web", Instances: 2, MemoryInMB: 512, DiskInMB: 1024, Relationships: app},
+		{Type: "worker", Instances: 1, MemoryInMB: 256, DiskInMB: 1024, Relationships: app},
+	}}, nil
+}
+
+func (p *cloneProcesses) Scale(_ context.Context, guid string, request *capi.ProcessScaleRequest) (*capi.Job, error) {
+	p.f.record("scale %s to %d x %dM", guid, *request.Instances, *request.MemoryInMB)
+
+	return &capi.Job{}, nil
+}
+
+type cloneBindings struct {
+	capi.ServiceCredentialBindingsClient
+
+	f *cloneFoundation
+}
+
+func (b *cloneBindings) List(_ context.Context, _ *capi.QueryParams, opts ...capi.ServiceCredentialBindingListOption) (*capi.ListResponse[capi.ServiceCredentialBinding], error) {
+	query := cloneQuery(opts)
+	if query.Get("app_guids") != "app-api,app-worker" || query.Get("type") != "app" {
+		return &capi.ListResponse[capi.ServiceCredentialBinding]{}, nil
+	}
+
+	app := cloneRelationship("app-api")
+
+	return &capi.ListResponse[capi.ServiceCredentialBinding]{Resources: []capi.ServiceCredentialBinding{{
+		Resource:      capi.Resource{GUID: "binding-1"},
+		Type:          "app",
+		Relationships: capi.ServiceCredentialBindingRelationships{App: &app, ServiceInstance: cloneRelationship("si-2")},
+	}}}, nil
+}
+
+func (b *cloneBindings) Create(_ context.Context, request *capi.ServiceCredentialBindingCreateRequest) (interface{}, error) {
+	b.f.record("bind %s to %s", request.Relationships.App.Data.GUID, request.Relationships.ServiceInstance.Data.GUID)
+
+	return &capi.ServiceCredentialBinding{}, nil
+}
+
+var errCloneRouteTaken = errors.New("the route is already in use")
+
+type cloneRoutes struct {
+	capi.RoutesClient
+
+	f *cloneFoundation
+}
+
+func (r *cloneRoutes) List(_ context.Context, _ *capi.QueryParams, opts ...capi.RouteListOption) (*capi.ListResponse[capi.Route], error) {
+	query := cloneQuery(opts)
+	if domain := query.Get("domain_guids"); domain != "" {
+		key := query.Get("hosts") + " " + query.Get("paths") + " " + domain
+		if !r.f.routes[key] {
+			return &capi.ListResponse[capi.Route]{}, nil
+		}
+
+		return &capi.ListResponse[capi.Route]{Resources: []capi.Route{{
+			Host:          query.Get("hosts"),
+			Path:          query.Get("paths"),
+			Relationships: capi.RouteRelationships{Domain: cloneRelationship(domain)},
+		}}}, nil
+	}
+
+	included, err := encodeIncluded(map[string][]interface{}{
+		"domains": {capi.Domain{Resource: capi.Resource{GUID: "d-apps.example.com"}, Name: "apps.example.com"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &capi.ListResponse[capi.Route]{
+		Resources: []capi.Route{{
+			Host: "api",
+			Path: "/v1",
+			Destinations: []capi.RouteDestination{{
+				App: capi.RouteDestinationApp{GUID: "app-api", Process: &capi.Process{Type: "web"}},
+			}},
+			Relationships: capi.RouteRelationships{Domain: cloneRelationship("d-apps.example.com")},
+		}},
+		Included: included,
+	}, nil
+}
+
+func (r *cloneRoutes) Create(_ context.Context, request *capi.RouteCreateRequest) (*capi.Route, error) {
+	key := *request.Host + " " + *request.Path + " " + request.Relationships.Domain.Data.GUID
+	if r.f.routes[key] {
+		return nil, errCloneRouteTaken
+	}
+
+	r.f.routes[key] = true
+	r.f.record("create route %s%s on %s in %s", *request.Host, *request.Path,
+		request.Relationships.Domain.Data.GUID, request.Relationships.Space.Data.GUID)
+
+	return &capi.Route{Resource: capi.Resource{GUID: "route-2"}}, nil
+}
+
+func (r *cloneRoutes) InsertDestinations(_ context.Context, guid string, destinations []capi.RouteDestination) (*capi.RouteDestinations, error) {
+	r.f.record("map %s to %s %s", guid, destinations[0].App.GUID, destinations[0].App.Process.Type)
+
+	return &capi.RouteDestinations{}, nil
+}
+
+type cloneDomains struct {
+	capi.DomainsClient
+}
+
+func (d *cloneDomains) List(_ context.Context, _ *capi.QueryParams, opts ...capi.DomainListOption) (*capi.ListResponse[capi.Domain], error) {
+	name := cloneQuery(opts).Get("names")
+	if name == "missing.example.com" {
+		return &capi.ListResponse[capi.Domain]{}, nil
+	}
+
+	return &capi.ListResponse[capi.Domain]{Resources: []capi.Domain{{Resource: capi.Resource{GUID: "d-" + name}, Name: name}}}, nil
+}
+
+func cloneOptions() capi.SpaceCloneOptions {
+	return capi.SpaceCloneOptions{
+		SourceOrganization: "payments",
+		SourceSpace:        "dev",
+		TargetSpace:        "feature-x",
+		PollInterval:       time.Millisecond,
+	}
+}
+
+func TestCloneSpace_SameFoundation(t *testing.T) {
+	t.Parallel()
+
+	var log []string
+
+	client := newCloneClient(&cloneFoundation{log: &log, existingQuota: true, members: []string{"u-alice"}})
+
+	opts := cloneOptions()
+	opts.Domain = "feature.example.com"
+
+	result, err := capi.CloneSpace(context.Background(), client, client, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, "space-2", result.Space.GUID)
+	assert.Equal(t, []string{
+		"create space feature-x in org-1",
+		"apply space quota quota-1 to [space-2]",
+		"bind running sg-1 to [space-2]",
+		"bind staging sg-2 to [space-2]",
+		"create role space_developer for u-alice in space-2",
+		"create user-provided service instance creds with map[password:s3cret] and drain syslog://logs.example.com",
+		"create managed service instance db on plan-1 tagged [sql]",
+		"wait for job-db",
+		"create app api in space-2 with map[SOURCE:app-api] on cflinuxfs4 [ruby_buildpack go_buildpack https://example.com/bp.git]",
+		"copy droplet droplet-1 to new-api",
+		"set droplet droplet-2 on new-api",
+		"scale new-api-web to 2 x 512M",
+		"create app worker in space-2 with map[SOURCE:app-worker] on cflinuxfs4 [ruby_buildpack go_buildpack https://example.com/bp.git]",
+		"bind new-api to si-2-copy",
+		"create route api/v1 on d-feature.example.com in space-2",
+		"map route-2 to new-api web",
+		"start new-api",
+	}, log)
+
+	assert.Equal(t, []capi.SpaceCloneStep{
+		{Resource: capi.SpaceCloneResourceApp, Name: "api", Action: capi.SpaceCloneSkipped, Detail: "no worker process"},
+		{Resource: capi.SpaceCloneResourceApp, Name: "worker", Action: capi.SpaceCloneSkipped, Detail: "no current droplet"},
+	}, result.Skipped())
+	assert.Contains(t, result.Steps, capi.SpaceCloneStep{
+		Resource: capi.SpaceCloneResourceRoute, Name: "api.feature.example.com/v1", Action: capi.SpaceCloneCreated,
+	})
+}
+
+func TestCloneSpace_AcrossFoundations(t *testing.T) {
+	t.Parallel()
+
+	var log []string
+
+	source := newCloneClient(&cloneFoundation{log: &log})
+	target := newCloneClient(&cloneFoundation{
+		log:          &log,
+		users:        map[string]string{"alice": "t-alice"},
+		missingGroup: "dns",
+		routes:       map[string]bool{},
+		buildpacks:   []string{"go_buildpack"},
+	})
+
+	var steps []capi.SpaceCloneStep
+
+	opts := cloneOptions()
+	opts.OnStep = func(step capi.SpaceCloneStep) { steps = append(steps, step) }
+
+	result, err := capi.CloneSpace(context.Background(), source, target, opts)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"create space feature-x in org-1",
+		"create space quota small with 1024M for space-2",
+		"bind running t-public to [space-2]",
+		"create role organization_user for t-alice in org-1",
+		"create role space_developer for t-alice in space-2",
+		"create user-provided service instance creds with map[password:s3cret] and drain syslog://logs.example.com",
+		"create managed service instance db on t-plan-1 tagged [sql]",
+		"wait for job-db",
+		"create app api in space-2 with map[SOURCE:app-api] on <nil> [go_buildpack https://example.com/bp.git]",
+		"download droplet droplet-1",
+		"create droplet for new-api with map[web:bundle exec puma]",
+		"upload 4 bytes to droplet-2",
+		"set droplet droplet-2 on new-api",
+		"scale new-api-web to 2 x 512M",
+		"create app worker in space-2 with map[SOURCE:app-worker] on <nil> [go_buildpack https://example.com/bp.git]",
+		"bind new-api to si-2-copy",
+		"create route api/v1 on d-apps.example.com in space-2",
+		"map route-2 to new-api web",
+		"start new-api",
+	}, log)
+
+	assert.Equal(t, result.Steps, steps, "OnStep sees every step")
+	assert.Contains(t, result.Skipped(), capi.SpaceCloneStep{
+		Resource: capi.SpaceCloneResourceSecurityGroup, Name: "dns", Action: capi.SpaceCloneSkipped,
+		Detail: "not found on the destination foundation",
+	})
+	assert.Contains(t, result.Skipped(), capi.SpaceCloneStep{
+		Resource: capi.SpaceCloneResourceApp, Name: "api", Action: capi.SpaceCloneSkipped,
+		Detail: "buildpack ruby_buildpack not found on the destination foundation",
+	})
+	assert.Contains(t, result.Skipped(), capi.SpaceCloneStep{
+		Resource: capi.SpaceCloneResourceApp, Name: "worker", Action: capi.SpaceCloneSkipped,
+		Detail: "stack cflinuxfs4 not found on the destination foundation",
+	})
+}
+
+func TestCloneSpace_SkipsExistingRoutes(t *testing.T) {
+	t.Parallel()
+
+	var log []string
+
+	client := newCloneClient(&cloneFoundation{log: &log, existingQuota: true, members: []string{"u-alice"}})
+
+	result, err := capi.CloneSpace(context.Background(), client, client, cloneOptions())
+	require.NoError(t, err)
+
+	assert.NotContains(t, strings.Join(log, "\n"), "create route")
+	assert.Contains(t, log, "start new-api", "the clone finishes")
+	assert.Contains(t, result.Skipped(), capi.SpaceCloneStep{
+		Resource: capi.SpaceCloneResourceRoute, Name: "api.apps.example.com/v1", Action: capi.SpaceCloneSkipped,
+		Detail: "route already exists on the destination foundation",
+	})
+}
+
+func TestCloneSpace_ChecksDestinationFirst(t *testing.T) {
+	t.Parallel()
+
+	var log []string
+
+	client := newCloneClient(&cloneFoundation{log: &log, existingSpace: true})
+
+	result, err := capi.CloneSpace(context.Background(), client, client, cloneOptions())
+	require.ErrorIs(t, err, capi.ErrSpaceAlreadyExists)
+	assert.Nil(t, result)
+
+	client = newCloneClient(&cloneFoundation{log: &log})
+
+	opts := cloneOptions()
+	opts.Domain = "missing.example.com"
+
+	_, err = capi.CloneSpace(context.Background(), client, client, opts)
+	require.ErrorIs(t, err, capi.ErrNotFound)
+
+	opts = cloneOptions()
+	opts.SourceSpace = "staging"
+
+	_, err = capi.CloneSpace(context.Background(), client, client, opts)
+	require.ErrorIs(t, err, capi.ErrNotFound)
+
+	assert.Empty(t, log, "nothing is created")
+}
@@ -0,0 +1,218 @@
+package capi
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"  //nolint:gosec // MD5 fingerprints are still advertised by some foundations
+	"crypto/sha1" //nolint:gosec // SHA1 fingerprints are still advertised by some foundations
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Static errors for err113 compliance.
+var (
+	ErrSSHUnavailable             = errors.New("SSH proxy is not advertised by the API")
+	ErrSSHCodeUnavailable         = errors.New("UAA did not return an SSH authorization code")
+	ErrSSHHostKeyMismatch         = errors.New("SSH host key fingerprint mismatch")
+	ErrSSHUnknownFingerprintStyle = errors.New("unrecognized SSH host key fingerprint format")
+	ErrInvalidSSHForward          = errors.New("invalid port forward (expected [LOCAL_ADDRESS:]LOCAL_PORT:REMOTE_ADDRESS:REMOTE_PORT)")
+)
+
+// DefaultSSHOAuthClient is the UAA client the Diego SSH proxy accepts
+// one-time codes for.
+const DefaultSSHOAuthClient = "ssh-proxy"
+
+// Lengths of the fingerprint encodings advertised by Cloud Controller.
+const (
+	sha256FingerprintLength = 43 // unpadded base64 of 32 bytes
+	sha1FingerprintLength   = 59 // colon-separated hex of 20 bytes
+	md5FingerprintLength    = 47 // colon-separated hex of 16 bytes
+)
+
+const (
+	maxPort = 65535
+	// Field counts of a -L forward spec with and without a local address.
+	sshForwardFields            = 3
+	sshForwardFieldsWithAddress = 4
+)
+
+// SSHEndpoint describes the Diego SSH proxy, as advertised by the app_ssh
+// link of the API root.
+type SSHEndpoint struct {
+	// Address is the host:port of the proxy.
+	Address string `json:"address" yaml:"address"`
+	// HostKeyFingerprint is the proxy's host key fingerprint: unpadded
+	// base64 SHA256, or colon-separated hex SHA1 or MD5.
+	HostKeyFingerprint string `json:"host_key_fingerprint" yaml:"host_key_fingerprint"`
+	// OAuthClient is the UAA client one-time codes are issued for.
+	OAuthClient string `json:"oauth_client" yaml:"oauth_client"`
+}
+
+// SSHUser returns the user name the SSH proxy expects for an instance of
+// a process: cf:<process-guid>/<index>.
+func SSHUser(processGUID string, index int) string {
+	return "cf:" + processGUID + "/" + strconv.Itoa(index)
+}
+
+// SSHHostKeyFingerprintCallback returns a host key callback that accepts
+// only the key matching fingerprint, in any of the formats Cloud Controller
+// advertises. SHA256 fingerprints may carry OpenSSH's "SHA256:" prefix and
+// are compared exactly, since base64 is case-sensitive; hex fingerprints are
+// compared ignoring case.
+func SSHHostKeyFingerprintCallback(fingerprint string) ssh.HostKeyCallback {
+	expected := strings.TrimPrefix(fingerprint, "SHA256:")
+
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		actual, err := sshFingerprint(key, len(expected))
+		if err != nil {
+			return err
+		}
+
+		match := actual == expected
+		if len(expected) != sha256FingerprintLength {
+			match = strings.EqualFold(actual, expected)
+		}
+
+		if !match {
+			return fmt.Errorf("%w: expected %s, got %s", ErrSSHHostKeyMismatch, fingerprint, actual)
+		}
+
+		return nil
+	}
+}
+
+// sshFingerprint encodes key's fingerprint in the format implied by length.
+func sshFingerprint(key ssh.PublicKey, length int) (string, error) {
+	switch length {
+	case sha256FingerprintLength:
+		sum := sha256.Sum256(key.Marshal())
+
+		return base64.RawStdEncoding.EncodeToString(sum[:]), nil
+	case sha1FingerprintLength:
+		sum := sha1.Sum(key.Marshal()) //nolint:gosec // matching the advertised format
+
+		return colonHex(sum[:]), nil
+	case md5FingerprintLength:
+		sum := md5.Sum(key.Marshal()) //nolint:gosec // matching the advertised format
+
+		return colonHex(sum[:]), nil
+	default:
+		return "", fmt.Errorf("%w: length %d", ErrSSHUnknownFingerprintStyle, length)
+	}
+}
+
+func colonHex(sum []byte) string {
+	var buf bytes.Buffer
+
+	for i, b := range sum {
+		if i > 0 {
+			buf.WriteByte(':')
+		}
+
+		buf.WriteString(hex.EncodeToString([]byte{b}))
+	}
+
+	return buf.String()
+}
+
+// SSHLocalForward is a local port forwarded to RemoteAddress through an SSH
+// connection, like ssh -L.
+type SSHLocalForward struct {
+	// LocalAddress is the host:port to listen on.
+	LocalAddress string `json:"local_address" yaml:"local_address"`
+	// RemoteAddress is the host:port dialed from the remote end.
+	RemoteAddress string `json:"remote_address" yaml:"remote_address"`
+}
+
+// ParseSSHLocalForward parses [LOCAL_ADDRESS:]LOCAL_PORT:REMOTE_ADDRESS:REMOTE_PORT.
+// The local address defaults to localhost.
+func ParseSSHLocalForward(spec string) (SSHLocalForward, error) {
+	fields := strings.Split(spec, ":")
+
+	switch len(fields) {
+	case sshForwardFields:
+		fields = append([]string{"localhost"}, fields...)
+	case sshForwardFieldsWithAddress:
+	default:
+		return SSHLocalForward{}, fmt.Errorf("%w: %q", ErrInvalidSSHForward, spec)
+	}
+
+	localPort, err := strconv.Atoi(fields[1])
+	if err != nil || localPort < 0 || localPort > maxPort {
+		return SSHLocalForward{}, fmt.Errorf("%w: invalid local port in %q", ErrInvalidSSHForward, spec)
+	}
+
+	remotePort, err := strconv.Atoi(fields[3])
+	if err != nil || remotePort < 1 || remotePort > maxPort || fields[2] == "" {
+		return SSHLocalForward{}, fmt.Errorf("%w: invalid remote address in %q", ErrInvalidSSHForward, spec)
+	}
+
+	return SSHLocalForward{
+		LocalAddress:  net.JoinHostPort(fields[0], fields[1]),
+		RemoteAddress: net.JoinHostPort(fields[2], fields[3]),
+	}, nil
+}
+
+// ForwardSSHPort accepts connections on listener and relays each one to
+// remoteAddress through client until ctx is canceled, when the listener is
+// closed and nil is returned. Connections that cannot be relayed are passed
+// to onError, which may be nil.
+func ForwardSSHPort(ctx context.Context, client *ssh.Client, listener net.Listener, remoteAddress string, onError func(error)) error {
+	stop := context.AfterFunc(ctx, func() { _ = listener.Close() })
+	defer stop()
+
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("accepting connection on %s: %w", listener.Addr(), err)
+		}
+
+		go func() {
+			remote, err := client.Dial("tcp", remoteAddress)
+			if err != nil {
+				_ = local.Close()
+
+				if onError != nil {
+					onError(fmt.Errorf("forwarding to %s: %w", remoteAddress, err))
+				}
+
+				return
+			}
+
+			relay(local, remote)
+		}()
+	}
+}
+
+// relay copies between a and b until both directions are done.
+func relay(a, b net.Conn) {
+	var wg sync.WaitGroup
+
+	wg.Add(2) //nolint:mnd // one goroutine per direction
+
+	copyAndClose := func(dst, src net.Conn) {
+		defer wg.Done()
+
+		_, _ = io.Copy(dst, src)
+		_ = dst.Close()
+	}
+
+	go copyAndClose(a, b)
+	go copyAndClose(b, a)
+
+	wg.Wait()
+}
@@ -0,0 +1,267 @@
+package capi_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/md5"  //nolint:gosec // fingerprint format under test
+	"crypto/sha1" //nolint:gosec // fingerprint format under test
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func colonHex(sum []byte) string {
+	parts := make([]string, 0, len(sum))
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func TestSSHUser(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "cf:process-guid/2", capi.SSHUser("process-guid", 2))
+}
+
+func TestSSHHostKeyFingerprintCallback(t *testing.T) {
+	t.Parallel()
+
+	publicKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	key, err := ssh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+
+	sha256Sum := sha256.Sum256(key.Marshal())
+	sha1Sum := sha1.Sum(key.Marshal()) //nolint:gosec // fingerprint format under test
+	md5Sum := md5.Sum(key.Marshal())   //nolint:gosec // fingerprint format under test
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		wantErr     error
+	}{
+		{name: "sha256", fingerprint: base64.RawStdEncoding.EncodeToString(sha256Sum[:])},
+		{name: "sha256 with prefix", fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sha256Sum[:])},
+		{name: "sha256 other case", fingerprint: swapCase(base64.RawStdEncoding.EncodeToString(sha256Sum[:])), wantErr: capi.ErrSSHHostKeyMismatch},
+		{name: "sha1", fingerprint: colonHex(sha1Sum[:])},
+		{name: "md5 upper case", fingerprint: strings.ToUpper(colonHex(md5Sum[:]))},
+		{name: "mismatch", fingerprint: strings.Repeat("A", 43), wantErr: capi.ErrSSHHostKeyMismatch},
+		{name: "unknown format", fingerprint: "abc", wantErr: capi.ErrSSHUnknownFingerprintStyle},
+		{name: "missing", fingerprint: "", wantErr: capi.ErrSSHUnknownFingerprintStyle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := capi.SSHHostKeyFingerprintCallback(tt.fingerprint)("ssh.example.com:2222", nil, key)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseSSHLocalForward(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec    string
+		want    capi.SSHLocalForward
+		wantErr bool
+	}{
+		{spec: "5432:db.internal:5432", want: capi.SSHLocalForward{LocalAddress: "localhost:5432", RemoteAddress: "db.internal:5432"}},
+		{spec: "0.0.0.0:8080:localhost:8080", want: capi.SSHLocalForward{LocalAddress: "0.0.0.0:8080", RemoteAddress: "localhost:8080"}},
+		{spec: "5432", wantErr: true},
+		{spec: "port:db:5432", wantErr: true},
+		{spec: "5432:db:0", wantErr: true},
+		{spec: "5432::5432", wantErr: true},
+		{spec: "70000:db:5432", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			forward, err := capi.ParseSSHLocalForward(tt.spec)
+			if tt.wantErr {
+				require.ErrorIs(t, err, capi.ErrInvalidSSHForward)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, forward)
+		})
+	}
+}
+
+// startDirectTCPIPServer starts an SSH server that relays direct-tcpip
+// channels, and returns a client connected to it.
+func startDirectTCPIPServer(t *testing.T) *ssh.Client {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	// net.Pipe is unbuffered and would deadlock the version exchange, so
+	// the server listens on loopback.
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		serverConn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		_, channels, requests, err := ssh.NewServerConn(serverConn, config)
+		if err != nil {
+			return
+		}
+
+		go ssh.DiscardRequests(requests)
+
+		for newChannel := range channels {
+			var target struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+
+			err := ssh.Unmarshal(newChannel.ExtraData(), &target)
+			if newChannel.ChannelType() != "direct-tcpip" || err != nil {
+				_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+
+				continue
+			}
+
+			upstream, err := (&net.Dialer{}).DialContext(context.Background(), "tcp",
+				net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+
+				continue
+			}
+
+			channel, channelRequests, err := newChannel.Accept()
+			if err != nil {
+				_ = upstream.Close()
+
+				continue
+			}
+
+			go ssh.DiscardRequests(channelRequests)
+
+			go func() {
+				_, _ = io.Copy(channel, upstream)
+				_ = channel.Close()
+			}()
+
+			go func() {
+				_, _ = io.Copy(upstream, channel)
+				_ = upstream.Close()
+			}()
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "cf:process-guid/0",
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+func TestForwardSSHPort(t *testing.T) {
+	t.Parallel()
+
+	// The "remote" service: echoes one line back in upper case.
+	upstream, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer func() { _ = upstream.Close() }()
+
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		_, _ = conn.Write([]byte(strings.ToUpper(line)))
+		_ = conn.Close()
+	}()
+
+	client := startDirectTCPIPServer(t)
+
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- capi.ForwardSSHPort(ctx, client, listener, upstream.Addr().String(), nil) }()
+
+	conn, err := (&net.Dialer{}).DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+
+	reply, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", string(reply))
+	_ = conn.Close()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "ForwardSSHPort did not stop after cancel")
+	}
+}
+
+// swapCase flips the case of every letter, turning a base64 fingerprint into
+// one that only matches when compared ignoring case.
+func swapCase(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+
+		return unicode.ToUpper(r)
+	}, text)
+}
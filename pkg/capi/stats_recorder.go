@@ -0,0 +1,223 @@
+package capi
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultStatsRecorderCapacity is the number of samples kept per instance
+// when NewStatsRecorder is given a non-positive capacity.
+const DefaultStatsRecorderCapacity = 360
+
+// StatsSample is the usage of one process instance at one point in time.
+type StatsSample struct {
+	Time        time.Time `json:"time"         yaml:"time"`
+	ProcessGUID string    `json:"process_guid" yaml:"process_guid"`
+	ProcessType string    `json:"process_type" yaml:"process_type"`
+	Index       int       `json:"index"        yaml:"index"`
+	State       string    `json:"state"        yaml:"state"`
+	// CPU is the CPU usage in percent of one core.
+	CPU       float64 `json:"cpu"        yaml:"cpu"`
+	Mem       int64   `json:"mem"        yaml:"mem"`
+	MemQuota  int64   `json:"mem_quota"  yaml:"mem_quota"`
+	Disk      int64   `json:"disk"       yaml:"disk"`
+	DiskQuota int64   `json:"disk_quota" yaml:"disk_quota"`
+	LogRate   int     `json:"log_rate"   yaml:"log_rate"`
+	Uptime    int     `json:"uptime"     yaml:"uptime"`
+}
+
+// NewStatsSamples converts the stats of a process into one sample per
+// instance taken at the given time. Instances without usage, such as those
+// still starting, have zero usage.
+func NewStatsSamples(processGUID, processType string, stats *ProcessStats, at time.Time) []StatsSample {
+	if stats == nil {
+		return nil
+	}
+
+	samples := make([]StatsSample, 0, len(stats.Resources))
+
+	for _, stat := range stats.Resources {
+		sample := StatsSample{
+			Time:        at,
+			ProcessGUID: processGUID,
+			ProcessType: processType,
+			Index:       stat.Index,
+			State:       stat.State,
+			MemQuota:    stat.MemQuota,
+			DiskQuota:   stat.DiskQuota,
+			Uptime:      stat.Uptime,
+		}
+
+		if stat.Usage != nil {
+			sample.CPU = stat.Usage.CPU * 100 //nolint:mnd // fraction to percent
+			sample.Mem = stat.Usage.Mem
+			sample.Disk = stat.Usage.Disk
+			sample.LogRate = stat.Usage.LogRate
+		}
+
+		samples = append(samples, sample)
+	}
+
+	return samples
+}
+
+// StatsInstanceKey identifies a process instance.
+type StatsInstanceKey struct {
+	ProcessGUID string `json:"process_guid" yaml:"process_guid"`
+	Index       int    `json:"index"        yaml:"index"`
+}
+
+// StatsAggregate summarizes one metric over the recorded samples.
+type StatsAggregate struct {
+	Min float64 `json:"min" yaml:"min"`
+	Avg float64 `json:"avg" yaml:"avg"`
+	P95 float64 `json:"p95" yaml:"p95"`
+	Max float64 `json:"max" yaml:"max"`
+}
+
+// StatsSummary summarizes the recorded samples of one instance.
+type StatsSummary struct {
+	StatsInstanceKey `yaml:",inline"`
+
+	ProcessType string         `json:"process_type" yaml:"process_type"`
+	Samples     int            `json:"samples"      yaml:"samples"`
+	First       time.Time      `json:"first"        yaml:"first"`
+	Last        time.Time      `json:"last"         yaml:"last"`
+	CPU         StatsAggregate `json:"cpu"          yaml:"cpu"`
+	Mem         StatsAggregate `json:"mem"          yaml:"mem"`
+	Disk        StatsAggregate `json:"disk"         yaml:"disk"`
+	LogRate     StatsAggregate `json:"log_rate"     yaml:"log_rate"`
+}
+
+// StatsRecorder keeps the most recent samples of every instance in a ring
+// buffer and summarizes them. It is safe for concurrent use.
+type StatsRecorder struct {
+	mutex     sync.Mutex
+	capacity  int
+	instances map[StatsInstanceKey]*statsRing
+}
+
+// statsRing holds up to cap(samples) samples; next is where the following
+// sample is written once the ring is full.
+type statsRing struct {
+	samples []StatsSample
+	next    int
+}
+
+// NewStatsRecorder creates a recorder that keeps up to capacity samples per
+// instance, dropping the oldest first.
+func NewStatsRecorder(capacity int) *StatsRecorder {
+	if capacity <= 0 {
+		capacity = DefaultStatsRecorderCapacity
+	}
+
+	return &StatsRecorder{capacity: capacity, instances: map[StatsInstanceKey]*statsRing{}}
+}
+
+// Record adds samples.
+func (r *StatsRecorder) Record(samples ...StatsSample) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, sample := range samples {
+		key := StatsInstanceKey{ProcessGUID: sample.ProcessGUID, Index: sample.Index}
+
+		ring, ok := r.instances[key]
+		if !ok {
+			ring = &statsRing{samples: make([]StatsSample, 0, r.capacity)}
+			r.instances[key] = ring
+		}
+
+		if len(ring.samples) < r.capacity {
+			ring.samples = append(ring.samples, sample)
+
+			continue
+		}
+
+		ring.samples[ring.next] = sample
+		ring.next = (ring.next + 1) % r.capacity
+	}
+}
+
+// Samples returns the recorded samples of an instance, oldest first.
+func (r *StatsRecorder) Samples(key StatsInstanceKey) []StatsSample {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ring, ok := r.instances[key]
+	if !ok {
+		return nil
+	}
+
+	return ring.ordered()
+}
+
+// Summaries returns a summary per instance, ordered by process type,
+// process GUID and index.
+func (r *StatsRecorder) Summaries() []StatsSummary {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	summaries := make([]StatsSummary, 0, len(r.instances))
+
+	for key, ring := range r.instances {
+		samples := ring.ordered()
+		if len(samples) == 0 {
+			continue
+		}
+
+		summaries = append(summaries, StatsSummary{
+			StatsInstanceKey: key,
+			ProcessType:      samples[len(samples)-1].ProcessType,
+			Samples:          len(samples),
+			First:            samples[0].Time,
+			Last:             samples[len(samples)-1].Time,
+			CPU:              aggregateStats(samples, func(s StatsSample) float64 { return s.CPU }),
+			Mem:              aggregateStats(samples, func(s StatsSample) float64 { return float64(s.Mem) }),
+			Disk:             aggregateStats(samples, func(s StatsSample) float64 { return float64(s.Disk) }),
+			LogRate:          aggregateStats(samples, func(s StatsSample) float64 { return float64(s.LogRate) }),
+		})
+	}
+
+	sort.Slice(summaries, func(a, b int) bool {
+		if summaries[a].ProcessType != summaries[b].ProcessType {
+			return summaries[a].ProcessType < summaries[b].ProcessType
+		}
+
+		if summaries[a].ProcessGUID != summaries[b].ProcessGUID {
+			return summaries[a].ProcessGUID < summaries[b].ProcessGUID
+		}
+
+		return summaries[a].Index < summaries[b].Index
+	})
+
+	return summaries
+}
+
+func (ring *statsRing) ordered() []StatsSample {
+	ordered := make([]StatsSample, 0, len(ring.samples))
+	ordered = append(ordered, ring.samples[ring.next:]...)
+
+	return append(ordered, ring.samples[:ring.next]...)
+}
+
+func aggregateStats(samples []StatsSample, value func(StatsSample) float64) StatsAggregate {
+	sorted := make([]float64, 0, len(samples))
+	sum := 0.0
+
+	for _, sample := range samples {
+		v := value(sample)
+		sorted = append(sorted, v)
+		sum += v
+	}
+
+	sort.Float64s(sorted)
+
+	return StatsAggregate{
+		Min: sorted[0],
+		Avg: sum / float64(len(sorted)),
+		P95: percentile(sorted, 95), //nolint:mnd // 95th percentile
+		Max: sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,129 @@
+package capi_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStatsSamples(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stats := &capi.ProcessStats{Resources: []capi.ProcessStatsDetail{
+		{
+			Index:     0,
+			State:     "RUNNING",
+			Usage:     &capi.ProcessUsage{CPU: 0.25, Mem: 100, Disk: 200, LogRate: 30},
+			MemQuota:  1000,
+			DiskQuota: 2000,
+			Uptime:    42,
+		},
+		{Index: 1, State: "STARTING"},
+	}}
+
+	samples := capi.NewStatsSamples("process-guid", "web", stats, at)
+
+	require.Len(t, samples, 2)
+	assert.Equal(t, capi.StatsSample{
+		Time:        at,
+		ProcessGUID: "process-guid",
+		ProcessType: "web",
+		Index:       0,
+		State:       "RUNNING",
+		CPU:         25,
+		Mem:         100,
+		MemQuota:    1000,
+		Disk:        200,
+		DiskQuota:   2000,
+		LogRate:     30,
+		Uptime:      42,
+	}, samples[0])
+	assert.Equal(t, "STARTING", samples[1].State)
+	assert.Zero(t, samples[1].CPU)
+	assert.Nil(t, capi.NewStatsSamples("process-guid", "web", nil, at))
+}
+
+func TestStatsRecorder_Summaries(t *testing.T) {
+	t.Parallel()
+
+	recorder := capi.NewStatsRecorder(0)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 20; i++ {
+		recorder.Record(
+			capi.StatsSample{Time: start.Add(time.Duration(i) * time.Second), ProcessGUID: "web-guid", ProcessType: "web", Index: 0, CPU: float64(i), Mem: int64(i * 10)},
+			capi.StatsSample{Time: start, ProcessGUID: "worker-guid", ProcessType: "worker", Index: 0, CPU: 5},
+		)
+	}
+
+	recorder.Record(capi.StatsSample{Time: start, ProcessGUID: "web-guid", ProcessType: "web", Index: 1, CPU: 7})
+
+	summaries := recorder.Summaries()
+	require.Len(t, summaries, 3)
+
+	web := summaries[0]
+	assert.Equal(t, capi.StatsInstanceKey{ProcessGUID: "web-guid", Index: 0}, web.StatsInstanceKey)
+	assert.Equal(t, 20, web.Samples)
+	assert.Equal(t, start.Add(time.Second), web.First)
+	assert.Equal(t, start.Add(20*time.Second), web.Last)
+	assert.Equal(t, capi.StatsAggregate{Min: 1, Avg: 10.5, P95: 19, Max: 20}, web.CPU)
+	assert.Equal(t, capi.StatsAggregate{Min: 10, Avg: 105, P95: 190, Max: 200}, web.Mem)
+
+	assert.Equal(t, 1, summaries[1].Index)
+	assert.Equal(t, capi.StatsAggregate{Min: 7, Avg: 7, P95: 7, Max: 7}, summaries[1].CPU)
+	assert.Equal(t, "worker", summaries[2].ProcessType)
+}
+
+func TestStatsRecorder_DropsOldestSamples(t *testing.T) {
+	t.Parallel()
+
+	recorder := capi.NewStatsRecorder(3)
+	key := capi.StatsInstanceKey{ProcessGUID: "web-guid", Index: 0}
+
+	for i := 1; i <= 5; i++ {
+		recorder.Record(capi.StatsSample{ProcessGUID: key.ProcessGUID, Index: key.Index, CPU: float64(i)})
+	}
+
+	samples := recorder.Samples(key)
+	require.Len(t, samples, 3)
+	assert.InDelta(t, 3.0, samples[0].CPU, 0)
+	assert.InDelta(t, 4.0, samples[1].CPU, 0)
+	assert.InDelta(t, 5.0, samples[2].CPU, 0)
+
+	summaries := recorder.Summaries()
+	require.Len(t, summaries, 1)
+	assert.Equal(t, capi.StatsAggregate{Min: 3, Avg: 4, P95: 5, Max: 5}, summaries[0].CPU)
+	assert.Nil(t, recorder.Samples(capi.StatsInstanceKey{ProcessGUID: "unknown"}))
+}
+
+func TestStatsRecorder_ConcurrentRecord(t *testing.T) {
+	t.Parallel()
+
+	recorder := capi.NewStatsRecorder(10)
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 100 {
+				recorder.Record(capi.StatsSample{ProcessGUID: "web-guid", Index: i % 2})
+				_ = recorder.Summaries()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	summaries := recorder.Summaries()
+	require.Len(t, summaries, 2)
+	assert.Equal(t, 10, summaries[0].Samples)
+}
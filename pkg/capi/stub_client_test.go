@@ -0,0 +1,173 @@
+package capi_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/fivetwenty-io/capi/v3/pkg/capi"
+)
+
+// stubClient embeds capi.Client so it satisfies the full interface; tests set
+// only the sub-clients they exercise. Calling an accessor that was not set
+// panics on the nil embedded interface, which surfaces unexpected usage.
+type stubClient struct {
+	capi.Client
+
+	apps             capi.AppsClient
+	spaces           capi.SpacesClient
+	manifests        capi.ManifestsClient
+	serviceInstances capi.ServiceInstancesClient
+	deployments      capi.DeploymentsClient
+	processes        capi.ProcessesClient
+	revisions        capi.RevisionsClient
+	droplets         capi.DropletsClient
+	logCache         capi.LogCacheClient
+	auditEvents      capi.AuditEventsClient
+	organizations    capi.OrganizationsClient
+	routes           capi.RoutesClient
+	securityGroups   capi.SecurityGroupsClient
+	roles            capi.RolesClient
+	featureFlags     capi.FeatureFlagsClient
+	envVarGroups     capi.EnvironmentVariableGroupsClient
+	isoSegments      capi.IsolationSegmentsClient
+	orgQuotas        capi.OrganizationQuotasClient
+	spaceQuotas      capi.SpaceQuotasClient
+	users            capi.UsersClient
+	domains          capi.DomainsClient
+	serviceBrokers   capi.ServiceBrokersClient
+	serviceOfferings capi.ServiceOfferingsClient
+	servicePlans     capi.ServicePlansClient
+	routing          capi.RoutingClient
+	bindings         capi.ServiceCredentialBindingsClient
+	jobs             capi.JobsClient
+	metadata         capi.MetadataClient
+	buildpacks       capi.BuildpacksClient
+	stacks           capi.StacksClient
+}
+
+func (s *stubClient) Apps() capi.AppsClient                         { return s.apps }
+func (s *stubClient) Spaces() capi.SpacesClient                     { return s.spaces }
+func (s *stubClient) Manifests() capi.ManifestsClient               { return s.manifests }
+func (s *stubClient) ServiceInstances() capi.ServiceInstancesClient { return s.serviceInstances }
+func (s *stubClient) Deployments() capi.DeploymentsClient           { return s.deployments }
+func (s *stubClient) Processes() capi.ProcessesClient               { return s.processes }
+func (s *stubClient) Revisions() capi.RevisionsClient               { return s.revisions }
+func (s *stubClient) Droplets() capi.DropletsClient                 { return s.droplets }
+func (s *stubClient) LogCache() capi.LogCacheClient                 { return s.logCache }
+func (s *stubClient) AuditEvents() capi.AuditEventsClient           { return s.auditEvents }
+func (s *stubClient) Organizations() capi.OrganizationsClient       { return s.organizations }
+func (s *stubClient) Routes() capi.RoutesClient                     { return s.routes }
+func (s *stubClient) SecurityGroups() capi.SecurityGroupsClient     { return s.securityGroups }
+func (s *stubClient) Roles() capi.RolesClient                       { return s.roles }
+func (s *stubClient) FeatureFlags() capi.FeatureFlagsClient         { return s.featureFlags }
+func (s *stubClient) EnvironmentVariableGroups() capi.EnvironmentVariableGroupsClient {
+	return s.envVarGroups
+}
+func (s *stubClient) IsolationSegments() capi.IsolationSegmentsClient   { return s.isoSegments }
+func (s *stubClient) OrganizationQuotas() capi.OrganizationQuotasClient { return s.orgQuotas }
+func (s *stubClient) SpaceQuotas() capi.SpaceQuotasClient               { return s.spaceQuotas }
+func (s *stubClient) Users() capi.UsersClient                           { return s.users }
+func (s *stubClient) Domains() capi.DomainsClient                       { return s.domains }
+func (s *stubClient) ServiceBrokers() capi.ServiceBrokersClient         { return s.serviceBrokers }
+func (s *stubClient) ServiceOfferings() capi.ServiceOfferingsClient     { return s.serviceOfferings }
+func (s *stubClient) ServicePlans() capi.ServicePlansClient             { return s.servicePlans }
+func (s *stubClient) Routing() capi.RoutingClient                       { return s.routing }
+func (s *stubClient) ServiceCredentialBindings() capi.ServiceCredentialBindingsClient {
+	return s.bindings
+}
+
+func (s *stubClient) Jobs() capi.JobsClient             { return s.jobs }
+func (s *stubClient) Metadata() capi.MetadataClient     { return s.metadata }
+func (s *stubClient) Buildpacks() capi.BuildpacksClient { return s.buildpacks }
+func (s *stubClient) Stacks() capi.StacksClient         { return s.stacks }
+
+// stubSpaces serves spaces from a map keyed by GUID.
+type stubSpaces struct {
+	capi.SpacesClient
+
+	spaces map[string]*capi.Space
+}
+
+func (s *stubSpaces) Get(_ context.Context, guid string, _ ...capi.SpaceGetOption) (*capi.Space, error) {
+	space, ok := s.spaces[guid]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	return space, nil
+}
+
+// stubApps serves a fixed, single-page app list.
+type stubApps struct {
+	capi.AppsClient
+
+	apps []capi.App
+}
+
+func (s *stubApps) List(_ context.Context, _ *capi.QueryParams, _ ...capi.AppListOption) (*capi.ListResponse[capi.App], error) {
+	return &capi.ListResponse[capi.App]{Resources: s.apps}, nil
+}
+
+// stubManifests returns canned manifests keyed by app GUID and records calls.
+type stubManifests struct {
+	capi.ManifestsClient
+
+	mu        sync.Mutex
+	manifests map[string]string
+	generated []string
+}
+
+func (s *stubManifests) GenerateManifest(_ context.Context, appGUID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generated = append(s.generated, appGUID)
+
+	manifest, ok := s.manifests[appGUID]
+	if !ok {
+		return nil, capi.ErrNotFound
+	}
+
+	return []byte(manifest), nil
+}
+
+// stubServiceInstances serves a single page of service instances together
+// with an included block.
+type stubServiceInstances struct {
+	capi.ServiceInstancesClient
+
+	instances []capi.ServiceInstance
+	included  map[string][]interface{}
+}
+
+func (s *stubServiceInstances) List(_ context.Context, _ *capi.QueryParams, _ ...capi.ServiceInstanceListOption) (*capi.ListResponse[capi.ServiceInstance], error) {
+	included, err := encodeIncluded(s.included)
+	if err != nil {
+		return nil, err
+	}
+
+	return &capi.ListResponse[capi.ServiceInstance]{Resources: s.instances, Included: included}, nil
+}
+
+// encodeIncluded builds the included block of a list response.
+func encodeIncluded(included map[string][]interface{}) (map[string][]json.RawMessage, error) {
+	if included == nil {
+		return nil, nil
+	}
+
+	encoded := make(map[string][]json.RawMessage, len(included))
+
+	for key, values := range included {
+		for _, value := range values {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+
+			encoded[key] = append(encoded[key], raw)
+		}
+	}
+
+	return encoded, nil
+}